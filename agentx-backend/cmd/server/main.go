@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -10,6 +14,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/recover"
 
 	"github.com/agentx/agentx-backend/internal/api"
+	"github.com/agentx/agentx-backend/internal/api/middleware"
 	"github.com/agentx/agentx-backend/internal/audit"
 	"github.com/agentx/agentx-backend/internal/auth"
 	"github.com/agentx/agentx-backend/internal/config"
@@ -17,6 +22,7 @@ import (
 	"github.com/agentx/agentx-backend/internal/providers"
 	"github.com/agentx/agentx-backend/internal/repository/postgres"
 	"github.com/agentx/agentx-backend/internal/services"
+	"github.com/agentx/agentx-backend/internal/tracing"
 )
 
 func main() {
@@ -26,6 +32,13 @@ func main() {
 		log.Fatal("Failed to load configuration:", err)
 	}
 
+	// Set up tracing
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		log.Fatal("Failed to set up tracing:", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Connect to database
 	db, err := database.NewConnection(cfg.Database)
 	if err != nil {
@@ -46,10 +59,13 @@ func main() {
 
 	// Middleware
 	app.Use(recover.New())
+	app.Use(middleware.Metrics())
+	app.Use(middleware.Tracing())
 	app.Use(logger.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     getOrigins(),
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, Traceparent, Tracestate",
+		ExposeHeaders:    middleware.TraceIDHeader,
 		AllowMethods:     "GET, POST, PUT, DELETE, OPTIONS",
 		AllowCredentials: true,
 	}))
@@ -102,10 +118,27 @@ func main() {
 		port = "8080"
 	}
 
+	// Stop accepting requests on SIGINT or SIGTERM, then flush queued work
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+		<-quit
+		log.Println("AgentX Backend shutting down")
+		if err := app.ShutdownWithTimeout(30 * time.Second); err != nil {
+			log.Printf("Failed to shut down server: %v", err)
+		}
+	}()
+
 	log.Printf("AgentX Backend starting on port %s", port)
 	if err := app.Listen(":" + port); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := svc.Close(ctx); err != nil {
+		log.Printf("Failed to flush services: %v", err)
+	}
 }
 
 func customErrorHandler(c *fiber.Ctx, err error) error {
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/agentx/agentx-backend/internal/api/models"
+	"github.com/agentx/agentx-backend/internal/llm"
 	"github.com/agentx/agentx-backend/internal/providers"
 )
 
@@ -53,9 +54,9 @@ func (a BaseAdapter) NormalizeRequest(req models.UnifiedChatRequest) (providers.
 	}
 	
 	// Handle response format conversion
-	if req.ResponseFormat != "" {
-		switch req.ResponseFormat {
-		case "json":
+	if req.ResponseFormat.Type != "" {
+		switch req.ResponseFormat.Type {
+		case "json", "json_object":
 			providerReq.ResponseFormat = &providers.ResponseFormat{Type: "json_object"}
 		case "text", "markdown", "code":
 			// Most providers handle these as regular text
@@ -225,34 +226,23 @@ func ExtractErrorCode(err error) string {
 		return "model_not_found"
 	case strings.Contains(errStr, "context_length_exceeded"):
 		return "context_length_exceeded"
+	case strings.Contains(errStr, "content_filter"):
+		return "content_filter"
 	default:
 		return "unknown_error"
 	}
 }
 
-// EstimateCost calculates estimated cost based on usage
+// EstimateCost calculates estimated cost based on usage using the gateway's
+// pricing catalog
 func EstimateCost(usage models.Usage, provider, model string) float64 {
-	// Cost per 1K tokens (rough estimates)
-	costs := map[string]map[string]struct{ input, output float64 }{
-		"openai": {
-			"gpt-4":           {0.03, 0.06},
-			"gpt-4-turbo":     {0.01, 0.03},
-			"gpt-3.5-turbo":   {0.0005, 0.0015},
-		},
-		"anthropic": {
-			"claude-3-opus":   {0.015, 0.075},
-			"claude-3-sonnet": {0.003, 0.015},
-			"claude-3-haiku":  {0.00025, 0.00125},
-		},
-	}
-	
-	if providerCosts, ok := costs[provider]; ok {
-		if modelCosts, ok := providerCosts[model]; ok {
-			inputCost := float64(usage.PromptTokens) / 1000 * modelCosts.input
-			outputCost := float64(usage.CompletionTokens) / 1000 * modelCosts.output
-			return inputCost + outputCost
-		}
+	pricing, ok := llm.DefaultPricingCatalog().Lookup(model)
+	if !ok {
+		return 0
 	}
 	
-	return 0
+	return pricing.Cost(llm.Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+	})
 }
\ No newline at end of file
@@ -0,0 +1,119 @@
+package adapters
+
+import (
+	"github.com/agentx/agentx-backend/internal/api/models"
+	"github.com/agentx/agentx-backend/internal/providers"
+)
+
+// GeminiAdapter handles Google Gemini-specific adaptations
+type GeminiAdapter struct {
+	BaseAdapter
+}
+
+// NewGeminiAdapter creates a new Gemini adapter
+func NewGeminiAdapter() *GeminiAdapter {
+	return &GeminiAdapter{
+		BaseAdapter: NewBaseAdapter("gemini"),
+	}
+}
+
+// NormalizeRequest converts unified request to Gemini format
+func (a *GeminiAdapter) NormalizeRequest(req models.UnifiedChatRequest) (providers.CompletionRequest, error) {
+	providerReq, err := a.BaseAdapter.NormalizeRequest(req)
+	if err != nil {
+		return providerReq, err
+	}
+
+	// Handle images for vision models; the Gemini provider sends data URLs
+	// as inline data and other URLs as file references
+	if len(req.Images) > 0 {
+		for i, msg := range providerReq.Messages {
+			if msg.Role == "user" {
+				contents := []interface{}{
+					map[string]string{"type": "text", "text": msg.Content},
+				}
+
+				for _, img := range req.Images {
+					url := img.URL
+					if img.Base64 != "" {
+						url = "data:" + img.Type + ";base64," + img.Base64
+					}
+					contents = append(contents, map[string]interface{}{
+						"type":      "image_url",
+						"image_url": map[string]string{"url": url},
+					})
+				}
+
+				providerReq.Messages[i].Content = ""
+				providerReq.Messages[i].ContentArray = contents
+			}
+		}
+	}
+
+	return providerReq, nil
+}
+
+// NormalizeResponse converts Gemini response to unified format
+func (a *GeminiAdapter) NormalizeResponse(resp *providers.CompletionResponse) (*models.UnifiedChatResponse, error) {
+	unifiedResp, err := a.BaseAdapter.NormalizeResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	unifiedResp.Metadata = models.ResponseMetadata{
+		Provider: "gemini",
+		Model:    resp.Model,
+	}
+
+	// Calculate estimated cost
+	unifiedResp.Usage.EstimatedCost = EstimateCost(unifiedResp.Usage, "gemini", resp.Model)
+
+	return unifiedResp, nil
+}
+
+// NormalizeStreamChunk converts Gemini stream chunk to unified format
+func (a *GeminiAdapter) NormalizeStreamChunk(chunk providers.StreamChunk) (*models.UnifiedStreamChunk, error) {
+	unified, err := a.BaseAdapter.NormalizeStreamChunk(chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	if unified.Type == "meta" && unified.Metadata != nil {
+		unified.Metadata.Provider = "gemini"
+	}
+
+	return unified, nil
+}
+
+// NormalizeError converts Gemini errors to unified format
+func (a *GeminiAdapter) NormalizeError(err error, provider string) *models.UnifiedError {
+	unified := a.BaseAdapter.NormalizeError(err, provider)
+
+	// The Gemini provider leads its errors with the shared error code
+	unified.Code = ExtractErrorCode(err)
+
+	// Gemini's messages rarely use the phrases the base adapter looks for,
+	// so the code decides the type
+	switch unified.Code {
+	case "rate_limit_exceeded":
+		unified.Type = models.ErrorTypeRateLimit
+		unified.Retry = true
+	case "invalid_api_key":
+		unified.Type = models.ErrorTypeAuth
+	case "model_not_found":
+		unified.Type = models.ErrorTypeInvalid
+	case "context_length_exceeded":
+		unified.Type = models.ErrorTypeModelLimit
+		unified.Retry = true
+	}
+
+	if unified.Type == models.ErrorTypeRateLimit {
+		unified.Fallback = &models.Fallback{
+			Provider: "gemini",
+			Model:    "gemini-1.5-flash",
+			Reason:   "Gemini rate limit reached, switching to a model with higher limits",
+		}
+	}
+
+	return unified
+}
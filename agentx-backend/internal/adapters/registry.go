@@ -19,7 +19,9 @@ func NewRegistry() *Registry {
 	
 	// Register default adapters
 	r.Register("openai", NewOpenAIAdapter())
+	r.Register("azure-openai", NewOpenAIAdapter())
 	r.Register("anthropic", NewAnthropicAdapter())
+	r.Register("gemini", NewGeminiAdapter())
 	r.Register("ollama", NewOllamaAdapter())
 	r.Register("openai-compatible", NewOpenAICompatibleAdapter())
 	
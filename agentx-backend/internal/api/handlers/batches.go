@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/agentx/agentx-backend/internal/api/middleware"
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/agentx/agentx-backend/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// BatchHandlers handles batch completion job endpoints
+type BatchHandlers struct {
+	batchService *services.BatchService
+}
+
+// NewBatchHandlers creates new batch handlers
+func NewBatchHandlers(batchService *services.BatchService) *BatchHandlers {
+	return &BatchHandlers{
+		batchService: batchService,
+	}
+}
+
+// CreateBatch handles POST /api/v1/batches. The body is JSONL with one
+// completion request per line, either raw or as the "file" field of a
+// multipart form; name, concurrency and max_attempts are query parameters.
+func (h *BatchHandlers) CreateBatch(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	req := models.BatchJobCreateRequest{
+		Name:        c.Query("name"),
+		Concurrency: c.QueryInt("concurrency"),
+		MaxAttempts: c.QueryInt("max_attempts"),
+	}
+
+	var input io.Reader = bytes.NewReader(c.Body())
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Failed to read uploaded file",
+			})
+		}
+		defer f.Close()
+		input = f
+		if req.Name == "" {
+			req.Name = file.Filename
+		}
+	}
+
+	job, err := h.batchService.Create(c.Context(), userContext.UserID, req, input)
+	if err != nil {
+		return batchError(c, err, "Failed to create batch job")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// ListBatches handles GET /api/v1/batches
+func (h *BatchHandlers) ListBatches(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	jobs, err := h.batchService.List(c.Context(), userContext.UserID)
+	if err != nil {
+		return batchError(c, err, "Failed to list batch jobs")
+	}
+
+	return c.JSON(fiber.Map{
+		"batches": jobs,
+	})
+}
+
+// GetBatch handles GET /api/v1/batches/:id
+func (h *BatchHandlers) GetBatch(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	jobID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid batch ID",
+		})
+	}
+
+	job, err := h.batchService.Get(c.Context(), userContext.UserID, jobID)
+	if err != nil {
+		return batchError(c, err, "Failed to get batch job")
+	}
+
+	return c.JSON(job)
+}
+
+// ListBatchItems handles GET /api/v1/batches/:id/items, optionally filtered
+// by status and paged with offset and limit
+func (h *BatchHandlers) ListBatchItems(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	jobID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid batch ID",
+		})
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+	offset := c.QueryInt("offset")
+	if offset < 0 {
+		offset = 0
+	}
+
+	items, err := h.batchService.Items(c.Context(), userContext.UserID, jobID, c.Query("status"), offset, limit)
+	if err != nil {
+		return batchError(c, err, "Failed to list batch items")
+	}
+
+	return c.JSON(fiber.Map{
+		"items":  items,
+		"offset": offset,
+		"limit":  limit,
+	})
+}
+
+// DownloadBatchResults handles GET /api/v1/batches/:id/results, streaming the
+// results as JSONL in input order, optionally only those with a status
+func (h *BatchHandlers) DownloadBatchResults(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	jobID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid batch ID",
+		})
+	}
+
+	// Check the job before the response is committed to streaming
+	if _, err := h.batchService.Get(c.Context(), userContext.UserID, jobID); err != nil {
+		return batchError(c, err, "Failed to get batch job")
+	}
+
+	userID := userContext.UserID
+	status := c.Query("status")
+	c.Set("Content-Type", "application/x-ndjson")
+	c.Attachment(fmt.Sprintf("batch-%s-results.jsonl", jobID))
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := h.batchService.WriteResults(context.Background(), userID, jobID, status, w); err != nil {
+			fmt.Printf("[BatchHandlers] Failed to write results of job %s: %v\n", jobID, err)
+		}
+		w.Flush()
+	})
+
+	return nil
+}
+
+// CancelBatch handles POST /api/v1/batches/:id/cancel
+func (h *BatchHandlers) CancelBatch(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	jobID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid batch ID",
+		})
+	}
+
+	job, err := h.batchService.Cancel(c.Context(), userContext.UserID, jobID)
+	if err != nil {
+		return batchError(c, err, "Failed to cancel batch job")
+	}
+
+	return c.JSON(job)
+}
+
+// RetryBatch handles POST /api/v1/batches/:id/retry, running a finished
+// job's failed and cancelled items again
+func (h *BatchHandlers) RetryBatch(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	jobID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid batch ID",
+		})
+	}
+
+	job, err := h.batchService.Retry(c.Context(), userContext.UserID, jobID)
+	if err != nil {
+		return batchError(c, err, "Failed to retry batch job")
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// batchError maps batch service errors to HTTP responses
+func batchError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidBatch):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrBatchNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Batch job not found",
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fallback,
+		})
+	}
+}
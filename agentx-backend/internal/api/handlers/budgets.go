@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/agentx/agentx-backend/internal/api/middleware"
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/agentx/agentx-backend/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// BudgetHandlers handles budget and quota endpoints
+type BudgetHandlers struct {
+	budgetService *services.BudgetService
+}
+
+// NewBudgetHandlers creates new budget handlers
+func NewBudgetHandlers(budgetService *services.BudgetService) *BudgetHandlers {
+	return &BudgetHandlers{
+		budgetService: budgetService,
+	}
+}
+
+// GetStatus handles GET /api/v1/budgets/status for the authenticated user
+func (h *BudgetHandlers) GetStatus(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	statuses, err := h.budgetService.Status(c.Context(), userContext.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load budget status",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"budgets": statuses,
+	})
+}
+
+// ListBudgets handles GET /api/v1/budgets (admin only), optionally filtered
+// by the owning user_id
+func (h *BudgetHandlers) ListBudgets(c *fiber.Ctx) error {
+	var userID *uuid.UUID
+	if v := c.Query("user_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid user ID",
+			})
+		}
+		userID = &id
+	}
+
+	budgets, err := h.budgetService.List(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list budgets",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"budgets": budgets,
+	})
+}
+
+// CreateBudget handles POST /api/v1/budgets (admin only)
+func (h *BudgetHandlers) CreateBudget(c *fiber.Ctx) error {
+	var req models.BudgetCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	budget, err := h.budgetService.Create(c.Context(), &req)
+	if err != nil {
+		return budgetError(c, err, "Failed to create budget")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(budget)
+}
+
+// UpdateBudget handles PUT /api/v1/budgets/:id (admin only)
+func (h *BudgetHandlers) UpdateBudget(c *fiber.Ctx) error {
+	budgetID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid budget ID",
+		})
+	}
+
+	var req models.BudgetUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	budget, err := h.budgetService.Update(c.Context(), budgetID, &req)
+	if err != nil {
+		return budgetError(c, err, "Failed to update budget")
+	}
+
+	return c.JSON(budget)
+}
+
+// DeleteBudget handles DELETE /api/v1/budgets/:id (admin only)
+func (h *BudgetHandlers) DeleteBudget(c *fiber.Ctx) error {
+	budgetID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid budget ID",
+		})
+	}
+
+	if err := h.budgetService.Delete(c.Context(), budgetID); err != nil {
+		return budgetError(c, err, "Failed to delete budget")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Budget deleted successfully",
+	})
+}
+
+// budgetError maps budget service errors to HTTP responses
+func budgetError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidBudget):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case err.Error() == "budget not found":
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Budget not found",
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fallback,
+		})
+	}
+}
@@ -98,7 +98,7 @@ func (h *BuiltinMCPServerHandlers) ToggleBuiltinServer(c *fiber.Ctx) error {
 	}
 
 	// Toggle the server
-	if err := h.builtinManager.SetUserServerEnabled(userID, serverID, req.Enabled); err != nil {
+	if err := h.builtinManager.SetUserServerEnabled(c.UserContext(), userID, serverID, req.Enabled); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -154,7 +154,7 @@ func (h *BuiltinMCPServerHandlers) ConvertToRegularServer(c *fiber.Ctx) error {
 	}
 
 	// Disable the built-in server for this user
-	if err := h.builtinManager.SetUserServerEnabled(userID, serverID, false); err != nil {
+	if err := h.builtinManager.SetUserServerEnabled(c.UserContext(), userID, serverID, false); err != nil {
 		// Log warning but don't fail the request
 		c.Locals("warning", "Failed to disable built-in server: "+err.Error())
 	}
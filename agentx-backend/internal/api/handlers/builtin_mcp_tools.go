@@ -39,7 +39,7 @@ func (h *BuiltinMCPServerHandlers) CallBuiltinTool(c *fiber.Ctx) error {
 	// Check if server is enabled for user
 	if !h.builtinManager.IsServerEnabledForUser(userID, req.ServerID) {
 		// Try to enable it first
-		if err := h.builtinManager.SetUserServerEnabled(userID, req.ServerID, true); err != nil {
+		if err := h.builtinManager.SetUserServerEnabled(c.UserContext(), userID, req.ServerID, true); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "Server is not enabled for user",
 			})
@@ -47,7 +47,7 @@ func (h *BuiltinMCPServerHandlers) CallBuiltinTool(c *fiber.Ctx) error {
 	}
 
 	// Call the tool
-	result, err := h.builtinManager.CallTool(userID, req.ServerID, req.ToolName, req.Arguments)
+	result, err := h.builtinManager.CallTool(c.UserContext(), userID, req.ServerID, req.ToolName, req.Arguments)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
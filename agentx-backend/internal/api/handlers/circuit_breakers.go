@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"github.com/agentx/agentx-backend/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetCircuitBreakers returns the state of every circuit breaker and their
+// recent transitions
+func GetCircuitBreakers(svc *services.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		breakers := svc.Gateway.CircuitBreakers()
+		open := 0
+		for _, breaker := range breakers {
+			if breaker.State != "closed" {
+				open++
+			}
+		}
+
+		return c.JSON(fiber.Map{
+			"breakers": breakers,
+			"events":   svc.Gateway.CircuitBreakerEvents(),
+			"total":    len(breakers),
+			"open":     open,
+		})
+	}
+}
+
+// ResetCircuitBreakers closes one circuit breaker, or every breaker when no
+// key is given
+func ResetCircuitBreakers(svc *services.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req struct {
+			Key string `json:"key"`
+		}
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Invalid request body",
+				})
+			}
+		}
+
+		if req.Key == "" {
+			svc.Gateway.ResetCircuitBreakers()
+			return c.JSON(fiber.Map{"reset": "all"})
+		}
+		if !svc.Gateway.ResetCircuitBreaker(req.Key) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "circuit breaker not found",
+			})
+		}
+		return c.JSON(fiber.Map{"reset": req.Key})
+	}
+}
+
+// ProbeCircuitBreakers probes every half-open circuit breaker immediately
+func ProbeCircuitBreakers(svc *services.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		succeeded := svc.Gateway.ProbeBreakers(c.Context())
+		return c.JSON(fiber.Map{
+			"succeeded": succeeded,
+			"breakers":  svc.Gateway.CircuitBreakers(),
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/agentx/agentx-backend/internal/api/middleware"
+	"github.com/agentx/agentx-backend/internal/llm"
+	"github.com/agentx/agentx-backend/internal/providers"
+	"github.com/agentx/agentx-backend/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListConnectionModels handles GET /api/v1/connections/:id/models, listing
+// the models of one connection with their context sizes and, for
+// self-hosted models, parameter size and quantization
+func ListConnectionModels(svc *services.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userContext := middleware.GetUserContext(c)
+		if userContext == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Authentication required",
+			})
+		}
+		connectionID := c.Params("id")
+		if err := ensureConnection(c, svc, connectionID); err != nil {
+			return err
+		}
+
+		models, err := svc.Gateway.ConnectionModels(c.UserContext(), userContext.UserID.String(), connectionID)
+		if err != nil {
+			return modelManagementError(c, err)
+		}
+		return c.JSON(fiber.Map{
+			"models": models,
+		})
+	}
+}
+
+// ListRunningModels handles GET /api/v1/connections/:id/models/running
+func ListRunningModels(svc *services.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		manager, err := connectionModelManager(c, svc)
+		if err != nil || manager == nil {
+			return err
+		}
+
+		running, err := manager.RunningModels(c.UserContext())
+		if err != nil {
+			return modelManagementError(c, err)
+		}
+		if running == nil {
+			running = []providers.RunningModel{}
+		}
+		return c.JSON(fiber.Map{
+			"models": running,
+		})
+	}
+}
+
+// PullModel handles POST /api/v1/connections/:id/models/pull. Download
+// progress is streamed as server-sent events, ending with [DONE].
+func PullModel(svc *services.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req struct {
+			Model string `json:"model"`
+		}
+		if err := c.BodyParser(&req); err != nil || req.Model == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "model is required",
+			})
+		}
+
+		manager, err := connectionModelManager(c, svc)
+		if err != nil || manager == nil {
+			return err
+		}
+
+		// The download outlives the request, so a client that disconnects
+		// does not abort it
+		progress, err := manager.PullModel(context.Background(), req.Model)
+		if err != nil {
+			return modelManagementError(c, err)
+		}
+		fmt.Printf("[ConnectionModels] Pulling model %s on connection %s\n", req.Model, c.Params("id"))
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			connected := true
+			for event := range progress {
+				if !connected {
+					continue // Drain so the download completes
+				}
+				data, _ := json.Marshal(event)
+				if event.Error != "" {
+					fmt.Fprintf(w, "event: error\ndata: %s\n\n", string(data))
+				} else {
+					fmt.Fprintf(w, "data: %s\n\n", string(data))
+				}
+				if err := w.Flush(); err != nil {
+					connected = false
+				}
+			}
+			if connected {
+				fmt.Fprintf(w, "data: [DONE]\n\n")
+				w.Flush()
+			}
+			fmt.Printf("[ConnectionModels] Finished pulling model %s\n", req.Model)
+		})
+		return nil
+	}
+}
+
+// DeleteModel handles DELETE /api/v1/connections/:id/models/*, where the
+// rest of the path is the model name, e.g. llama3.1:8b
+func DeleteModel(svc *services.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		model, err := url.PathUnescape(c.Params("*"))
+		if err != nil || model == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "model is required",
+			})
+		}
+
+		manager, err := connectionModelManager(c, svc)
+		if err != nil || manager == nil {
+			return err
+		}
+
+		if err := manager.DeleteModel(c.UserContext(), model); err != nil {
+			return modelManagementError(c, err)
+		}
+		fmt.Printf("[ConnectionModels] Deleted model %s on connection %s\n", model, c.Params("id"))
+		return c.JSON(fiber.Map{
+			"deleted": model,
+		})
+	}
+}
+
+// connectionModelManager returns the model manager of the connection in the
+// path. On failure the error response has been written and manager is nil.
+func connectionModelManager(c *fiber.Ctx, svc *services.Services) (providers.ModelManager, error) {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return nil, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+	connectionID := c.Params("id")
+	if err := ensureConnection(c, svc, connectionID); err != nil {
+		return nil, err
+	}
+
+	manager, err := svc.Gateway.ModelManager(userContext.UserID.String(), connectionID)
+	if err != nil {
+		return nil, modelManagementError(c, err)
+	}
+	return manager, nil
+}
+
+// ensureConnection registers the connection with the gateway if it is not
+// yet, writing a 404 when it does not exist
+func ensureConnection(c *fiber.Ctx, svc *services.Services, connectionID string) error {
+	userContext := middleware.GetUserContext(c)
+	if svc.Gateway.HasConnection(userContext.UserID.String(), connectionID) {
+		return nil
+	}
+	if err := svc.Connection.EnsureConnectionInitialized(c.UserContext(), userContext.UserID, connectionID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return nil
+}
+
+// modelManagementError writes the response for a failed model operation
+func modelManagementError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusBadGateway
+	var providerErr *providers.ProviderError
+	switch {
+	case errors.Is(err, llm.ErrModelManagementUnsupported):
+		status = fiber.StatusBadRequest
+	case errors.As(err, &providerErr) && providerErr.StatusCode == fiber.StatusNotFound:
+		status = fiber.StatusNotFound
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
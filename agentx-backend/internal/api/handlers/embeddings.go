@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/agentx/agentx-backend/internal/api/middleware"
+	"github.com/agentx/agentx-backend/internal/llm"
+	"github.com/agentx/agentx-backend/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// embeddingsRequest is the body of an embeddings request, in the OpenAI
+// format with an optional connection to route to
+type embeddingsRequest struct {
+	Input          json.RawMessage `json:"input"`
+	Model          string          `json:"model"`
+	Dimensions     int             `json:"dimensions,omitempty"`
+	EncodingFormat string          `json:"encoding_format,omitempty"` // float or base64
+	ConnectionID   string          `json:"connection_id,omitempty"`
+}
+
+// CreateEmbeddings handles POST /api/v1/embeddings, returning the vectors
+// with routing metadata
+func CreateEmbeddings(svc *services.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req, resp, err := embed(c, svc)
+		if err != nil || resp == nil {
+			return err
+		}
+
+		data := make([]fiber.Map, len(resp.Embeddings))
+		for i, embedding := range resp.Embeddings {
+			data[i] = fiber.Map{
+				"index":     i,
+				"embedding": encodeEmbedding(embedding, req.EncodingFormat),
+			}
+		}
+		return c.JSON(fiber.Map{
+			"data":       data,
+			"model":      resp.Model,
+			"dimensions": resp.Dimensions,
+			"usage":      resp.Usage,
+			"metadata":   resp.Metadata,
+		})
+	}
+}
+
+// CreateEmbeddingsOpenAI handles POST /v1/embeddings with an OpenAI
+// compatible response
+func CreateEmbeddingsOpenAI(svc *services.Services) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req, resp, err := embed(c, svc)
+		if err != nil || resp == nil {
+			return err
+		}
+
+		data := make([]fiber.Map, len(resp.Embeddings))
+		for i, embedding := range resp.Embeddings {
+			data[i] = fiber.Map{
+				"object":    "embedding",
+				"index":     i,
+				"embedding": encodeEmbedding(embedding, req.EncodingFormat),
+			}
+		}
+		return c.JSON(fiber.Map{
+			"object": "list",
+			"data":   data,
+			"model":  resp.Model,
+			"usage": fiber.Map{
+				"prompt_tokens": resp.Usage.PromptTokens,
+				"total_tokens":  resp.Usage.TotalTokens,
+			},
+		})
+	}
+}
+
+// embed parses an embeddings request and runs it through the gateway. On
+// failure the error response has been written and resp is nil.
+func embed(c *fiber.Ctx, svc *services.Services) (*embeddingsRequest, *llm.EmbeddingResponse, error) {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return nil, nil, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+
+	var req embeddingsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return nil, nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.EncodingFormat != "" && req.EncodingFormat != "float" && req.EncodingFormat != "base64" {
+		return nil, nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("unsupported encoding_format: %s", req.EncodingFormat),
+		})
+	}
+	input, err := parseEmbeddingInput(req.Input)
+	if err != nil {
+		return nil, nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	embeddingReq := &llm.EmbeddingRequest{
+		Input:        input,
+		Model:        req.Model,
+		Dimensions:   req.Dimensions,
+		UserID:       userContext.UserID.String(),
+		ConnectionID: req.ConnectionID,
+		Metadata:     map[string]interface{}{},
+	}
+	if id := apiKeyID(c); id != "" {
+		embeddingReq.Metadata[llm.MetadataAPIKeyID] = id
+	}
+	if err := embeddingReq.Validate(); err != nil {
+		return nil, nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	resp, err := svc.Gateway.Embed(c.UserContext(), embeddingReq)
+	if err != nil {
+		fmt.Printf("[Embeddings] Request failed for user %s: %v\n", embeddingReq.UserID, err)
+		if errors.Is(err, llm.ErrEmbeddingsUnsupported) {
+			return nil, nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return nil, nil, chatError(c, err)
+	}
+	return &req, resp, nil
+}
+
+// parseEmbeddingInput accepts a single string or a list of strings
+func parseEmbeddingInput(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("input is required")
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("input must be a string or an array of strings")
+	}
+	return list, nil
+}
+
+// encodeEmbedding returns the vector as floats, or as base64 of its
+// little-endian float32 bytes when asked for
+func encodeEmbedding(embedding []float32, format string) interface{} {
+	if format != "base64" {
+		return embedding
+	}
+	buf := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	
 	"github.com/gofiber/fiber/v2"
@@ -31,6 +32,9 @@ func (h *LLMHandler) RegisterRoutes(api fiber.Router) {
 	tasksGroup := llmGroup.Group("/tasks")
 	tasksGroup.Post("/title-generation", h.HandleTitleGeneration)
 	tasksGroup.Post("/summarization", h.HandleSummarization)
+	tasksGroup.Post("/translation", h.HandleTranslation)
+	tasksGroup.Post("/code-generation", h.HandleCodeGeneration)
+	tasksGroup.Post("/extraction", h.HandleExtraction)
 	
 	// Utility endpoints
 	llmGroup.Get("/tasks", h.ListSupportedTasks)
@@ -59,7 +63,7 @@ func (h *LLMHandler) HandleCompletion(c *fiber.Ctx) error {
 	fmt.Printf("[LLM Handler] Request parsed - Task: %s, ConnectionID: %s\n", req.Task, req.ConnectionID)
 	
 	// Process completion
-	resp, err := h.llmService.Complete(c.Context(), userContext.UserID.String(), req)
+	resp, err := h.llmService.Complete(c.UserContext(), userContext.UserID.String(), req)
 	if err != nil {
 		fmt.Printf("[LLM Handler] Completion failed: %v\n", err)
 		
@@ -141,7 +145,7 @@ func (h *LLMHandler) HandleTitleGeneration(c *fiber.Ctx) error {
 		}
 	}
 	
-	resp, err := h.llmService.Complete(c.Context(), userContext.UserID.String(), completionReq)
+	resp, err := h.llmService.Complete(c.UserContext(), userContext.UserID.String(), completionReq)
 	if err != nil {
 		fmt.Printf("[LLM Handler] Title generation failed: %v\n", err)
 		
@@ -173,6 +177,199 @@ func (h *LLMHandler) HandleSummarization(c *fiber.Ctx) error {
 	})
 }
 
+// HandleTranslation handles translation requests (convenience endpoint)
+func (h *LLMHandler) HandleTranslation(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+	
+	var req struct {
+		Text           string            `json:"text"`
+		SourceLanguage string            `json:"source_language,omitempty"`
+		TargetLanguage string            `json:"target_language"`
+		Glossary       map[string]string `json:"glossary,omitempty"`
+		ConnectionID   string            `json:"connection_id,omitempty"`
+		Parameters     llm.Parameters    `json:"parameters,omitempty"`
+	}
+	
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	
+	glossary := make(map[string]interface{}, len(req.Glossary))
+	for term, translation := range req.Glossary {
+		glossary[term] = translation
+	}
+	
+	resp, err := h.llmService.Complete(c.UserContext(), userContext.UserID.String(), llm.CompletionRequest{
+		Task: llm.TaskTranslate,
+		Context: map[string]interface{}{
+			"text":            req.Text,
+			"source_language": req.SourceLanguage,
+			"target_language": req.TargetLanguage,
+			"glossary":        glossary,
+		},
+		ConnectionID: req.ConnectionID,
+		Parameters:   req.Parameters,
+	})
+	if err != nil {
+		fmt.Printf("[LLM Handler] Translation failed: %v\n", err)
+		return taskError(c, err)
+	}
+	
+	return c.JSON(fiber.Map{
+		"translation":       resp.Result,
+		"source_language":   resp.Metadata["source_language"],
+		"detected_language": resp.Metadata["detected_language"],
+		"target_language":   resp.Metadata["target_language"],
+		"glossary_missing":  resp.Metadata["glossary_missing"],
+		"provider":          resp.Provider,
+		"connection_id":     resp.ConnectionID,
+		"usage":             resp.Usage,
+		"duration_ms":       resp.Duration.Milliseconds(),
+	})
+}
+
+// HandleCodeGeneration handles code generation requests (convenience endpoint)
+func (h *LLMHandler) HandleCodeGeneration(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+	
+	var req struct {
+		Instructions string         `json:"instructions"`
+		Language     string         `json:"language"`
+		Style        []string       `json:"style,omitempty"`
+		Code         string         `json:"code,omitempty"`
+		ConnectionID string         `json:"connection_id,omitempty"`
+		Parameters   llm.Parameters `json:"parameters,omitempty"`
+	}
+	
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	
+	resp, err := h.llmService.Complete(c.UserContext(), userContext.UserID.String(), llm.CompletionRequest{
+		Task: llm.TaskCodeGeneration,
+		Context: map[string]interface{}{
+			"instructions": req.Instructions,
+			"language":     req.Language,
+			"style":        req.Style,
+			"code":         req.Code,
+		},
+		ConnectionID: req.ConnectionID,
+		Parameters:   req.Parameters,
+	})
+	if err != nil {
+		fmt.Printf("[LLM Handler] Code generation failed: %v\n", err)
+		return taskError(c, err)
+	}
+	
+	return c.JSON(fiber.Map{
+		"code":          resp.Result,
+		"language":      resp.Metadata["language"],
+		"explanation":   resp.Metadata["explanation"],
+		"blocks":        resp.Metadata["blocks"],
+		"provider":      resp.Provider,
+		"connection_id": resp.ConnectionID,
+		"usage":         resp.Usage,
+		"duration_ms":   resp.Duration.Milliseconds(),
+	})
+}
+
+// HandleExtraction handles structured extraction requests (convenience
+// endpoint), returning data validated against the request's JSON Schema
+func (h *LLMHandler) HandleExtraction(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Authentication required",
+		})
+	}
+	
+	var req struct {
+		Text         string                 `json:"text"`
+		Schema       map[string]interface{} `json:"schema"`
+		SchemaName   string                 `json:"schema_name,omitempty"`
+		Instructions string                 `json:"instructions,omitempty"`
+		MaxRepairs   *int                   `json:"max_repairs,omitempty"`
+		ConnectionID string                 `json:"connection_id,omitempty"`
+		Parameters   llm.Parameters         `json:"parameters,omitempty"`
+	}
+	
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	
+	taskContext := map[string]interface{}{
+		"text":         req.Text,
+		"schema":       req.Schema,
+		"schema_name":  req.SchemaName,
+		"instructions": req.Instructions,
+	}
+	if req.MaxRepairs != nil {
+		taskContext["max_repairs"] = *req.MaxRepairs
+	}
+	
+	resp, err := h.llmService.Complete(c.UserContext(), userContext.UserID.String(), llm.CompletionRequest{
+		Task:         llm.TaskExtract,
+		Context:      taskContext,
+		ConnectionID: req.ConnectionID,
+		Parameters:   req.Parameters,
+	})
+	if err != nil {
+		fmt.Printf("[LLM Handler] Extraction failed: %v\n", err)
+		return taskError(c, err)
+	}
+	
+	return c.JSON(fiber.Map{
+		"data":           resp.Metadata["data"],
+		"schema_name":    resp.Metadata["schema_name"],
+		"schema_repairs": resp.Metadata["schema_repairs"],
+		"provider":       resp.Provider,
+		"connection_id":  resp.ConnectionID,
+		"usage":          resp.Usage,
+		"duration_ms":    resp.Duration.Milliseconds(),
+	})
+}
+
+// taskError maps task errors to HTTP responses: validation failures are bad
+// requests and answers that never matched the schema are unprocessable
+func taskError(c *fiber.Ctx, err error) error {
+	var llmErr *llm.LLMError
+	if errors.As(err, &llmErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": llmErr.Message,
+			"code":  llmErr.Code,
+		})
+	}
+	
+	var schemaErr *llm.SchemaError
+	if errors.As(err, &schemaErr) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":   llm.ErrSchemaValidation.Error(),
+			"details": schemaErr.Errors,
+			"content": schemaErr.Content,
+		})
+	}
+	
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
 // ListSupportedTasks returns the list of supported task types
 func (h *LLMHandler) ListSupportedTasks(c *fiber.Ctx) error {
 	tasks := h.llmService.GetSupportedTasks()
@@ -61,7 +61,7 @@ func (h *MCPServerHandlers) ListServers(c *fiber.Ctx) error {
 		})
 	}
 
-	servers, err := h.mcpService.ListServers(c.Context(), userID)
+	servers, err := h.mcpService.ListServers(c.UserContext(), userID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to list MCP servers",
@@ -87,7 +87,7 @@ func (h *MCPServerHandlers) GetServer(c *fiber.Ctx) error {
 		})
 	}
 
-	server, err := h.mcpService.GetServer(c.Context(), userID, serverID)
+	server, err := h.mcpService.GetServer(c.UserContext(), userID, serverID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "MCP server not found",
@@ -118,7 +118,7 @@ func (h *MCPServerHandlers) CreateServer(c *fiber.Ctx) error {
 		})
 	}
 
-	server, err := h.mcpService.CreateServer(c.Context(), userID, &req)
+	server, err := h.mcpService.CreateServer(c.UserContext(), userID, &req)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -149,7 +149,7 @@ func (h *MCPServerHandlers) UpdateServer(c *fiber.Ctx) error {
 		})
 	}
 
-	server, err := h.mcpService.UpdateServer(c.Context(), userID, serverID, &req)
+	server, err := h.mcpService.UpdateServer(c.UserContext(), userID, serverID, &req)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -173,7 +173,7 @@ func (h *MCPServerHandlers) DeleteServer(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.mcpService.DeleteServer(c.Context(), userID, serverID); err != nil {
+	if err := h.mcpService.DeleteServer(c.UserContext(), userID, serverID); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -196,7 +196,7 @@ func (h *MCPServerHandlers) ToggleServer(c *fiber.Ctx) error {
 		})
 	}
 
-	server, err := h.mcpService.ToggleServer(c.Context(), userID, serverID)
+	server, err := h.mcpService.ToggleServer(c.UserContext(), userID, serverID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -221,7 +221,7 @@ func (h *MCPServerHandlers) TestServer(c *fiber.Ctx) error {
 	}
 
 	// Get server to verify ownership
-	server, err := h.mcpService.GetServer(c.Context(), userID, serverID)
+	server, err := h.mcpService.GetServer(c.UserContext(), userID, serverID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "MCP server not found",
@@ -253,7 +253,7 @@ func (h *MCPServerHandlers) GetServerTools(c *fiber.Ctx) error {
 	}
 
 	// Get server with tools
-	server, err := h.mcpService.GetServer(c.Context(), userID, serverID)
+	server, err := h.mcpService.GetServer(c.UserContext(), userID, serverID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "MCP server not found",
@@ -280,7 +280,7 @@ func (h *MCPServerHandlers) GetServerResources(c *fiber.Ctx) error {
 	}
 
 	// Get server with resources
-	server, err := h.mcpService.GetServer(c.Context(), userID, serverID)
+	server, err := h.mcpService.GetServer(c.UserContext(), userID, serverID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "MCP server not found",
@@ -307,14 +307,14 @@ func (h *MCPServerHandlers) CallTool(c *fiber.Ctx) error {
 	}
 
 	// Verify server ownership
-	_, err = h.mcpService.GetServer(c.Context(), userID, req.ServerID)
+	_, err = h.mcpService.GetServer(c.UserContext(), userID, req.ServerID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "MCP server not found",
 		})
 	}
 
-	result, err := h.mcpService.CallTool(c.Context(), &req)
+	result, err := h.mcpService.CallTool(c.UserContext(), &req)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -339,14 +339,14 @@ func (h *MCPServerHandlers) ReadResource(c *fiber.Ctx) error {
 	}
 
 	// Verify server ownership
-	_, err = h.mcpService.GetServer(c.Context(), userID, req.ServerID)
+	_, err = h.mcpService.GetServer(c.UserContext(), userID, req.ServerID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "MCP server not found",
 		})
 	}
 
-	result, err := h.mcpService.ReadResource(c.Context(), &req)
+	result, err := h.mcpService.ReadResource(c.UserContext(), &req)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
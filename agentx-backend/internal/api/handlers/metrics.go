@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"os"
+
+	"github.com/agentx/agentx-backend/internal/metrics"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+)
+
+// Metrics handles GET /metrics in the Prometheus or OpenMetrics format.
+// Scrapers must send AGENTX_METRICS_TOKEN as a bearer token; while it is
+// unset the endpoint is disabled, since metrics name users and connections.
+func Metrics() fiber.Handler {
+	token := os.Getenv("AGENTX_METRICS_TOKEN")
+	handler := adaptor.HTTPHandler(metrics.Handler())
+	if token == "" {
+		fmt.Printf("[Metrics] AGENTX_METRICS_TOKEN is not set, /metrics is disabled\n")
+	}
+
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Metrics are disabled",
+			})
+		}
+		auth := c.Get(fiber.HeaderAuthorization)
+		if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+token)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid metrics token",
+			})
+		}
+		return handler(c)
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/agentx/agentx-backend/internal/api/middleware"
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/agentx/agentx-backend/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// PromptHandlers handles prompt template endpoints
+type PromptHandlers struct {
+	promptService *services.PromptService
+}
+
+// NewPromptHandlers creates new prompt template handlers
+func NewPromptHandlers(promptService *services.PromptService) *PromptHandlers {
+	return &PromptHandlers{
+		promptService: promptService,
+	}
+}
+
+// ListPrompts handles GET /api/v1/prompts, returning the global templates,
+// the user's own and the built-in ones, optionally only those with a name
+func (h *PromptHandlers) ListPrompts(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	templates, err := h.promptService.List(c.Context(), userContext.UserID, c.Query("name"))
+	if err != nil {
+		return promptError(c, err, "Failed to list prompt templates")
+	}
+
+	return c.JSON(fiber.Map{
+		"templates": templates,
+		"builtins":  h.promptService.Builtins(),
+	})
+}
+
+// GetPrompt handles GET /api/v1/prompts/:id
+func (h *PromptHandlers) GetPrompt(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid prompt template ID",
+		})
+	}
+
+	tmpl, err := h.promptService.Get(c.Context(), userContext.UserID, id)
+	if err != nil {
+		return promptError(c, err, "Failed to get prompt template")
+	}
+
+	return c.JSON(tmpl)
+}
+
+// CreatePrompt handles POST /api/v1/prompts, storing a new version of a
+// template. Global templates are admin only.
+func (h *PromptHandlers) CreatePrompt(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	var req models.PromptTemplateCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	tmpl, err := h.promptService.Create(c.Context(), userContext.UserID, middleware.IsAdmin(c), &req)
+	if err != nil {
+		return promptError(c, err, "Failed to create prompt template")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(tmpl)
+}
+
+// UpdatePrompt handles PUT /api/v1/prompts/:id; setting default rolls the
+// template forward or back to this version
+func (h *PromptHandlers) UpdatePrompt(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid prompt template ID",
+		})
+	}
+
+	var req models.PromptTemplateUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	tmpl, err := h.promptService.Update(c.Context(), userContext.UserID, middleware.IsAdmin(c), id, &req)
+	if err != nil {
+		return promptError(c, err, "Failed to update prompt template")
+	}
+
+	return c.JSON(tmpl)
+}
+
+// DeletePrompt handles DELETE /api/v1/prompts/:id
+func (h *PromptHandlers) DeletePrompt(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid prompt template ID",
+		})
+	}
+
+	if err := h.promptService.Delete(c.Context(), userContext.UserID, middleware.IsAdmin(c), id); err != nil {
+		return promptError(c, err, "Failed to delete prompt template")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Prompt template deleted successfully",
+	})
+}
+
+// RenderPrompt handles POST /api/v1/prompts/render, previewing a stored or
+// inline template with the given variables
+func (h *PromptHandlers) RenderPrompt(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	var req models.PromptRenderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	rendered, err := h.promptService.Render(c.Context(), userContext.UserID, &req)
+	if err != nil {
+		return promptError(c, err, "Failed to render prompt template")
+	}
+
+	return c.JSON(rendered)
+}
+
+// promptError maps prompt service errors to HTTP responses
+func promptError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidPrompt):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrPromptForbidden):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.Is(err, services.ErrPromptNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Prompt template not found",
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fallback,
+		})
+	}
+}
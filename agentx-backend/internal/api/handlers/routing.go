@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/agentx/agentx-backend/internal/api/middleware"
+	"github.com/agentx/agentx-backend/internal/llm"
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/agentx/agentx-backend/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// RoutingHandlers handles routing rule, fallback chain and connection pool endpoints
+type RoutingHandlers struct {
+	routingService *services.RoutingService
+}
+
+// NewRoutingHandlers creates new routing handlers
+func NewRoutingHandlers(routingService *services.RoutingService) *RoutingHandlers {
+	return &RoutingHandlers{
+		routingService: routingService,
+	}
+}
+
+// ListRules handles GET /api/v1/routing/rules
+func (h *RoutingHandlers) ListRules(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	rules, err := h.routingService.ListRules(c.Context(), userContext.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list routing rules",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"rules": rules,
+	})
+}
+
+// GetRule handles GET /api/v1/routing/rules/:id
+func (h *RoutingHandlers) GetRule(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	ruleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid rule ID",
+		})
+	}
+
+	rule, err := h.routingService.GetRule(c.Context(), userContext.UserID, ruleID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Routing rule not found",
+		})
+	}
+
+	return c.JSON(rule)
+}
+
+// CreateRule handles POST /api/v1/routing/rules
+func (h *RoutingHandlers) CreateRule(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	var req models.RoutingRuleCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	rule, err := h.routingService.CreateRule(c.Context(), userContext.UserID, isAdmin(userContext), &req)
+	if err != nil {
+		return routingError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(rule)
+}
+
+// UpdateRule handles PUT /api/v1/routing/rules/:id
+func (h *RoutingHandlers) UpdateRule(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	ruleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid rule ID",
+		})
+	}
+
+	var req models.RoutingRuleUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	rule, err := h.routingService.UpdateRule(c.Context(), userContext.UserID, isAdmin(userContext), ruleID, &req)
+	if err != nil {
+		return routingError(c, err)
+	}
+
+	return c.JSON(rule)
+}
+
+// DeleteRule handles DELETE /api/v1/routing/rules/:id
+func (h *RoutingHandlers) DeleteRule(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	ruleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid rule ID",
+		})
+	}
+
+	if err := h.routingService.DeleteRule(c.Context(), userContext.UserID, isAdmin(userContext), ruleID); err != nil {
+		return routingError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Routing rule deleted successfully",
+	})
+}
+
+// ListChains handles GET /api/v1/routing/fallbacks
+func (h *RoutingHandlers) ListChains(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	chains, err := h.routingService.ListChains(c.Context(), userContext.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list fallback chains",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"chains": chains,
+	})
+}
+
+// GetChain handles GET /api/v1/routing/fallbacks/:id
+func (h *RoutingHandlers) GetChain(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	chainID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid fallback chain ID",
+		})
+	}
+
+	chain, err := h.routingService.GetChain(c.Context(), userContext.UserID, chainID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Fallback chain not found",
+		})
+	}
+
+	return c.JSON(chain)
+}
+
+// CreateChain handles POST /api/v1/routing/fallbacks
+func (h *RoutingHandlers) CreateChain(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	var req models.FallbackChainCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	chain, err := h.routingService.CreateChain(c.Context(), userContext.UserID, &req)
+	if err != nil {
+		return routingError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(chain)
+}
+
+// UpdateChain handles PUT /api/v1/routing/fallbacks/:id
+func (h *RoutingHandlers) UpdateChain(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	chainID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid fallback chain ID",
+		})
+	}
+
+	var req models.FallbackChainUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	chain, err := h.routingService.UpdateChain(c.Context(), userContext.UserID, chainID, &req)
+	if err != nil {
+		return routingError(c, err)
+	}
+
+	return c.JSON(chain)
+}
+
+// DeleteChain handles DELETE /api/v1/routing/fallbacks/:id
+func (h *RoutingHandlers) DeleteChain(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	chainID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid fallback chain ID",
+		})
+	}
+
+	if err := h.routingService.DeleteChain(c.Context(), userContext.UserID, chainID); err != nil {
+		return routingError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Fallback chain deleted successfully",
+	})
+}
+
+// ListPools handles GET /api/v1/routing/pools
+func (h *RoutingHandlers) ListPools(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	pools, err := h.routingService.ListPools(c.Context(), userContext.UserID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list connection pools",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"pools":      pools,
+		"strategies": llm.BalancingStrategies,
+	})
+}
+
+// GetPool handles GET /api/v1/routing/pools/:id
+func (h *RoutingHandlers) GetPool(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	poolID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid connection pool ID",
+		})
+	}
+
+	pool, err := h.routingService.GetPool(c.Context(), userContext.UserID, poolID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Connection pool not found",
+		})
+	}
+
+	return c.JSON(pool)
+}
+
+// CreatePool handles POST /api/v1/routing/pools
+func (h *RoutingHandlers) CreatePool(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	var req models.ConnectionPoolCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	pool, err := h.routingService.CreatePool(c.Context(), userContext.UserID, &req)
+	if err != nil {
+		return routingError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(pool)
+}
+
+// UpdatePool handles PUT /api/v1/routing/pools/:id
+func (h *RoutingHandlers) UpdatePool(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	poolID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid connection pool ID",
+		})
+	}
+
+	var req models.ConnectionPoolUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	pool, err := h.routingService.UpdatePool(c.Context(), userContext.UserID, poolID, &req)
+	if err != nil {
+		return routingError(c, err)
+	}
+
+	return c.JSON(pool)
+}
+
+// DeletePool handles DELETE /api/v1/routing/pools/:id
+func (h *RoutingHandlers) DeletePool(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	poolID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid connection pool ID",
+		})
+	}
+
+	if err := h.routingService.DeletePool(c.Context(), userContext.UserID, poolID); err != nil {
+		return routingError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Connection pool deleted successfully",
+	})
+}
+
+// isAdmin reports whether the authenticated user has the admin role
+func isAdmin(userContext *models.UserContext) bool {
+	return userContext.Role == models.RoleAdmin
+}
+
+// routingError maps routing service errors to HTTP responses
+func routingError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrInvalidRouting):
+		status = fiber.StatusBadRequest
+	case errors.Is(err, services.ErrRoutingForbidden):
+		status = fiber.StatusForbidden
+	case errors.Is(err, services.ErrRoutingRuleNotFound), errors.Is(err, services.ErrFallbackChainNotFound), errors.Is(err, services.ErrConnectionPoolNotFound):
+		status = fiber.StatusNotFound
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
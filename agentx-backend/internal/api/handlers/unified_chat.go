@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/gofiber/websocket/v2"
 	"github.com/agentx/agentx-backend/internal/api/middleware"
 	"github.com/agentx/agentx-backend/internal/api/models"
+	"github.com/agentx/agentx-backend/internal/llm"
 	"github.com/agentx/agentx-backend/internal/providers"
 	"github.com/agentx/agentx-backend/internal/services"
 )
@@ -52,17 +54,16 @@ func (h *UnifiedChatHandler) Chat(c *fiber.Ctx) error {
 		// If no connection ID, just pass the user ID
 		req.Preferences.ConnectionID = userContext.UserID.String()
 	}
+	req.APIKeyID = apiKeyID(c)
 	
 	// Debug logging
 	fmt.Printf("[Chat] UserID: %s, Request - SessionID: %s, ConnectionID: %s, Messages: %d\n", 
 		userContext.UserID.String(), req.SessionID, req.Preferences.ConnectionID, len(req.Messages))
 	
 	// Get response
-	resp, err := h.chatService.Chat(c.Context(), req)
+	resp, err := h.chatService.Chat(c.UserContext(), req)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return chatError(c, err)
 	}
 	
 	return c.JSON(resp)
@@ -149,6 +150,7 @@ func (h *UnifiedChatHandler) StreamChatSSE(c *fiber.Ctx) error {
 	if req.Preferences.ConnectionID != "" {
 		req.Preferences.ConnectionID = fmt.Sprintf("%s:%s", userContext.UserID.String(), req.Preferences.ConnectionID)
 	}
+	req.APIKeyID = apiKeyID(c)
 	
 	// Debug logging
 	fmt.Printf("[StreamChatSSE] Request - SessionID: %s, ConnectionID: %s, Messages: %d\n", 
@@ -161,9 +163,15 @@ func (h *UnifiedChatHandler) StreamChatSSE(c *fiber.Ctx) error {
 	c.Set("Transfer-Encoding", "chunked")
 	
 	// Get stream
-	stream, err := h.chatService.StreamChat(c.Context(), req)
+	stream, err := h.chatService.StreamChat(c.UserContext(), req)
 	if err != nil {
 		fmt.Printf("[StreamChatSSE] Error getting stream: %v\n", err)
+		var budgetErr *llm.BudgetError
+		if errors.As(err, &budgetErr) {
+			data, _ := json.Marshal(budgetErrorBody(budgetErr))
+			fmt.Fprintf(c, "event: error\ndata: %s\n\n", string(data))
+			return nil
+		}
 		fmt.Fprintf(c, "event: error\ndata: %s\n\n", err.Error())
 		return nil
 	}
@@ -193,6 +201,14 @@ func (h *UnifiedChatHandler) StreamChatSSE(c *fiber.Ctx) error {
 				chunk.Metadata = lastMetadata
 			}
 			
+			// Tool calls are sent as typed events
+			if strings.HasPrefix(chunk.Type, "tool_call_") {
+				data, _ := json.Marshal(chunk)
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", chunk.Type, string(data))
+				w.Flush()
+				continue
+			}
+			
 			// Convert to OpenAI format for consistency
 			openAIChunk := h.convertToOpenAIStreamChunk(chunk, streamID)
 			if len(openAIChunk) > 0 {
@@ -228,9 +244,66 @@ func (h *UnifiedChatHandler) StreamChatSSE(c *fiber.Ctx) error {
 	return nil
 }
 
+// apiKeyID returns the ID of the API key that authenticated the request, if any
+func apiKeyID(c *fiber.Ctx) string {
+	id, _ := c.Locals("api_key_id").(string)
+	return id
+}
+
+// chatError writes a chat failure. Budget and quota rejections get a
+// structured body with a distinct error code; everything else is a 500.
+func chatError(c *fiber.Ctx, err error) error {
+	var budgetErr *llm.BudgetError
+	if errors.As(err, &budgetErr) {
+		status := fiber.StatusPaymentRequired
+		if budgetErr.Code == llm.ErrCodeTokenQuotaExceeded {
+			status = fiber.StatusTooManyRequests
+		}
+		if !budgetErr.ResetAt.IsZero() {
+			c.Set("Retry-After", fmt.Sprintf("%d", int(time.Until(budgetErr.ResetAt).Seconds())+1))
+		}
+		return c.Status(status).JSON(budgetErrorBody(budgetErr))
+	}
+	
+	var schemaErr *llm.SchemaError
+	if errors.As(err, &schemaErr) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":             err.Error(),
+			"validation_errors": schemaErr.Errors,
+			"content":           schemaErr.Content,
+			"repairs":           schemaErr.Repairs,
+		})
+	}
+	
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": err.Error(),
+	})
+}
+
+// budgetErrorBody is the response body of a request refused by a budget
+func budgetErrorBody(err *llm.BudgetError) fiber.Map {
+	body := fiber.Map{
+		"error": err.Error(),
+		"code":  err.Code,
+		"scope": err.Scope,
+		"limit": err.Limit,
+		"used":  err.Used,
+	}
+	if err.ScopeID != "" {
+		body["scope_id"] = err.ScopeID
+	}
+	if err.Period != "" {
+		body["period"] = err.Period
+	}
+	if !err.ResetAt.IsZero() {
+		body["reset_at"] = err.ResetAt
+	}
+	return body
+}
+
 // GetModels handles GET /api/v1/models
 func (h *UnifiedChatHandler) GetModels(c *fiber.Ctx) error {
-	models, err := h.chatService.GetAvailableModels(c.Context())
+	models, err := h.chatService.GetAvailableModels(c.UserContext())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -251,6 +324,7 @@ func (h *UnifiedChatHandler) ChatCompletions(c *fiber.Ctx) error {
 		Temperature *float32           `json:"temperature,omitempty"`
 		MaxTokens   *int               `json:"max_tokens,omitempty"`
 		Stream      bool               `json:"stream"`
+		ResponseFormat models.ResponseFormat `json:"response_format,omitempty"`
 	}
 	
 	if err := c.BodyParser(&openAIReq); err != nil {
@@ -264,6 +338,8 @@ func (h *UnifiedChatHandler) ChatCompletions(c *fiber.Ctx) error {
 		Messages:    openAIReq.Messages,
 		Temperature: openAIReq.Temperature,
 		MaxTokens:   openAIReq.MaxTokens,
+		ResponseFormat: openAIReq.ResponseFormat,
+		APIKeyID:    apiKeyID(c),
 	}
 	
 	// If model is specified, set it as a preference
@@ -283,7 +359,7 @@ func (h *UnifiedChatHandler) ChatCompletions(c *fiber.Ctx) error {
 		c.Set("Cache-Control", "no-cache")
 		c.Set("Connection", "keep-alive")
 		
-		stream, err := h.chatService.StreamChat(c.Context(), unifiedReq)
+		stream, err := h.chatService.StreamChat(c.UserContext(), unifiedReq)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": err.Error(),
@@ -296,6 +372,9 @@ func (h *UnifiedChatHandler) ChatCompletions(c *fiber.Ctx) error {
 			for chunk := range stream {
 				// Convert to OpenAI format
 				openAIChunk := h.convertToOpenAIStreamChunk(chunk, streamID)
+				if len(openAIChunk) == 0 {
+					continue
+				}
 				data, _ := json.Marshal(openAIChunk)
 				fmt.Fprintf(w, "data: %s\n\n", string(data))
 				w.Flush()
@@ -307,21 +386,40 @@ func (h *UnifiedChatHandler) ChatCompletions(c *fiber.Ctx) error {
 		return nil
 	} else {
 		// Handle non-streaming
-		resp, err := h.chatService.Chat(c.Context(), unifiedReq)
+		resp, err := h.chatService.Chat(c.UserContext(), unifiedReq)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			return chatError(c, err)
 		}
 		
 		// Convert to OpenAI format
 		openAIResp := h.convertToOpenAIResponse(resp)
+		if resp.Metadata.Cache != "" {
+			c.Set("X-Cache", resp.Metadata.Cache)
+		}
 		return c.JSON(openAIResp)
 	}
 }
 
 // convertToOpenAIResponse converts unified response to OpenAI format
 func (h *UnifiedChatHandler) convertToOpenAIResponse(resp *models.UnifiedChatResponse) map[string]interface{} {
+	message := map[string]interface{}{
+		"role":    resp.Role,
+		"content": resp.Content,
+	}
+	if resp.Parsed != nil {
+		message["parsed"] = resp.Parsed
+	}
+	if resp.Reasoning != "" {
+		message["reasoning_content"] = resp.Reasoning
+	}
+	usage := map[string]interface{}{
+		"prompt_tokens":     resp.Usage.PromptTokens,
+		"completion_tokens": resp.Usage.CompletionTokens,
+		"total_tokens":      resp.Usage.TotalTokens,
+	}
+	if resp.Usage.CacheReadTokens > 0 {
+		usage["prompt_tokens_details"] = map[string]interface{}{"cached_tokens": resp.Usage.CacheReadTokens}
+	}
 	return map[string]interface{}{
 		"id":      resp.ID,
 		"object":  "chat.completion",
@@ -330,18 +428,11 @@ func (h *UnifiedChatHandler) convertToOpenAIResponse(resp *models.UnifiedChatRes
 		"choices": []map[string]interface{}{
 			{
 				"index": 0,
-				"message": map[string]interface{}{
-					"role":    resp.Role,
-					"content": resp.Content,
-				},
+				"message":       message,
 				"finish_reason": "stop",
 			},
 		},
-		"usage": map[string]interface{}{
-			"prompt_tokens":     resp.Usage.PromptTokens,
-			"completion_tokens": resp.Usage.CompletionTokens,
-			"total_tokens":      resp.Usage.TotalTokens,
-		},
+		"usage": usage,
 	}
 }
 
@@ -367,6 +458,82 @@ func (h *UnifiedChatHandler) convertToOpenAIStreamChunk(chunk models.UnifiedStre
 				},
 			},
 		}
+	case "reasoning":
+		// Thinking uses the reasoning_content delta field common to
+		// OpenAI-compatible reasoning APIs
+		model := "unknown"
+		if chunk.Metadata != nil {
+			model = fmt.Sprintf("%s/%s", chunk.Metadata.Provider, chunk.Metadata.Model)
+		}
+		return map[string]interface{}{
+			"id":      streamID,
+			"object":  "chat.completion.chunk",
+			"created": time.Now().Unix(),
+			"model":   model,
+			"choices": []map[string]interface{}{
+				{
+					"index": 0,
+					"delta": map[string]interface{}{
+						"reasoning_content": chunk.Content,
+					},
+				},
+			},
+		}
+	case "tool_call_start", "tool_call_delta":
+		// Streamed the way OpenAI does: the first delta of a call names it,
+		// later ones carry argument fragments
+		if chunk.Tool == nil {
+			return map[string]interface{}{}
+		}
+		call := map[string]interface{}{
+			"function": map[string]interface{}{"arguments": chunk.Tool.Function.Arguments},
+		}
+		if chunk.Tool.Index != nil {
+			call["index"] = *chunk.Tool.Index
+		}
+		if chunk.Type == "tool_call_start" {
+			call["id"] = chunk.Tool.ID
+			call["type"] = chunk.Tool.Type
+			call["function"] = map[string]interface{}{"name": chunk.Tool.Function.Name, "arguments": ""}
+		}
+		model := "unknown"
+		if chunk.Metadata != nil {
+			model = fmt.Sprintf("%s/%s", chunk.Metadata.Provider, chunk.Metadata.Model)
+		}
+		return map[string]interface{}{
+			"id":      streamID,
+			"object":  "chat.completion.chunk",
+			"created": time.Now().Unix(),
+			"model":   model,
+			"choices": []map[string]interface{}{
+				{
+					"index": 0,
+					"delta": map[string]interface{}{
+						"tool_calls": []map[string]interface{}{call},
+					},
+				},
+			},
+		}
+	case "failover":
+		// Non-standard event so clients can show that another provider took over
+		failover := map[string]interface{}{
+			"id":      streamID,
+			"object":  "chat.completion.failover",
+			"created": time.Now().Unix(),
+		}
+		if chunk.Metadata != nil {
+			failover["from"] = chunk.Metadata.FailoverFrom
+			failover["model"] = fmt.Sprintf("%s/%s", chunk.Metadata.Provider, chunk.Metadata.Model)
+		}
+		return failover
+	case "error":
+		if chunk.Error == nil {
+			return map[string]interface{}{}
+		}
+		return map[string]interface{}{
+			"id":    streamID,
+			"error": chunk.Error,
+		}
 	case "done":
 		return map[string]interface{}{
 			"id":      streamID,
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/api/middleware"
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/agentx/agentx-backend/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// UsageHandlers handles usage ledger and pricing catalog endpoints
+type UsageHandlers struct {
+	usageService *services.UsageService
+}
+
+// NewUsageHandlers creates new usage handlers
+func NewUsageHandlers(usageService *services.UsageService) *UsageHandlers {
+	return &UsageHandlers{
+		usageService: usageService,
+	}
+}
+
+// GetUsage handles GET /api/v1/usage for the authenticated user
+func (h *UsageHandlers) GetUsage(c *fiber.Ctx) error {
+	userContext := middleware.GetUserContext(c)
+	if userContext == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Not authenticated",
+		})
+	}
+
+	query, err := parseUsageQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	query.UserID = &userContext.UserID
+
+	return h.report(c, query)
+}
+
+// GetAllUsage handles GET /api/v1/usage/all (admin only), optionally
+// filtered by user_id
+func (h *UsageHandlers) GetAllUsage(c *fiber.Ctx) error {
+	query, err := parseUsageQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if v := c.Query("user_id"); v != "" {
+		userID, err := uuid.Parse(v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid user ID",
+			})
+		}
+		query.UserID = &userID
+	}
+
+	return h.report(c, query)
+}
+
+// report runs a usage query and writes the result
+func (h *UsageHandlers) report(c *fiber.Ctx, query models.UsageQuery) error {
+	report, err := h.usageService.Report(c.Context(), query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidUsageQuery) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to aggregate usage",
+		})
+	}
+
+	return c.JSON(report)
+}
+
+// ListPrices handles GET /api/v1/pricing
+func (h *UsageHandlers) ListPrices(c *fiber.Ctx) error {
+	prices, err := h.usageService.ListPrices(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list model prices",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"prices": prices,
+	})
+}
+
+// SetPrice handles PUT /api/v1/pricing (admin only)
+func (h *UsageHandlers) SetPrice(c *fiber.Ctx) error {
+	var price models.ModelPrice
+	if err := c.BodyParser(&price); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.usageService.SetPrice(c.Context(), &price); err != nil {
+		if errors.Is(err, services.ErrInvalidPrice) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save model price",
+		})
+	}
+
+	return c.JSON(price)
+}
+
+// DeletePrice handles DELETE /api/v1/pricing?model= (admin only)
+func (h *UsageHandlers) DeletePrice(c *fiber.Ctx) error {
+	model := c.Query("model")
+	if model == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "model is required",
+		})
+	}
+
+	if err := h.usageService.DeletePrice(c.Context(), model); err != nil {
+		if err.Error() == "model price not found" {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete model price",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Model price deleted successfully",
+	})
+}
+
+// parseUsageQuery reads the from, to, group_by, model, connection_id and
+// api_key_id query parameters. Dates may be RFC 3339 timestamps or YYYY-MM-DD.
+func parseUsageQuery(c *fiber.Ctx) (models.UsageQuery, error) {
+	var query models.UsageQuery
+
+	for name, dest := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", v); err != nil {
+				return query, errors.New("invalid " + name + " date")
+			}
+		}
+		*dest = t
+	}
+
+	if v := c.Query("group_by"); v != "" {
+		query.GroupBy = []string{}
+		for _, group := range strings.Split(v, ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				query.GroupBy = append(query.GroupBy, group)
+			}
+		}
+	}
+
+	query.Model = c.Query("model")
+	if v := c.Query("connection_id"); v != "" {
+		connectionID, err := uuid.Parse(v)
+		if err != nil {
+			return query, errors.New("invalid connection ID")
+		}
+		query.ConnectionID = &connectionID
+	}
+	if v := c.Query("api_key_id"); v != "" {
+		apiKeyID, err := uuid.Parse(v)
+		if err != nil {
+			return query, errors.New("invalid API key ID")
+		}
+		query.APIKeyID = &apiKeyID
+	}
+
+	return query, nil
+}
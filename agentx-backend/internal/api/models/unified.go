@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+	
 	"github.com/agentx/agentx-backend/internal/providers"
 )
 
@@ -21,7 +23,7 @@ type UnifiedChatRequest struct {
 	// Universal parameters
 	Temperature    *float32 `json:"temperature,omitempty"`
 	MaxTokens      *int     `json:"max_tokens,omitempty"`
-	ResponseFormat string   `json:"response_format,omitempty"` // text, json, markdown, code
+	ResponseFormat ResponseFormat `json:"response_format,omitempty"` // text, json, markdown, code, or a json_schema object
 	
 	// Advanced features (auto-detected and routed)
 	Functions []providers.Function `json:"functions,omitempty"`
@@ -31,6 +33,69 @@ type UnifiedChatRequest struct {
 	
 	// Force web search for this request
 	ForceWebSearch bool `json:"force_web_search,omitempty"`
+	
+	// Maximum server-side tool-calling rounds (0 disables MCP tool execution)
+	MaxToolSteps *int `json:"max_tool_steps,omitempty"`
+	
+	// How history is trimmed to fit the model's context window:
+	// sliding_window, keep_system_last_n, drop_tool_outputs or summary
+	ContextStrategy string `json:"context_strategy,omitempty"`
+	
+	// Reject the request if its estimated cost in USD exceeds this
+	MaxCost float64 `json:"max_cost,omitempty"`
+	
+	// Skip the response cache and always call the provider
+	NoCache bool `json:"no_cache,omitempty"`
+	
+	// Hedge to a second connection if the first has not answered, or sent its
+	// first token, within this many milliseconds
+	MaxLatencyMs int `json:"max_latency_ms,omitempty"`
+	
+	// Extended thinking budget, for models that support it
+	Reasoning *providers.Reasoning `json:"reasoning,omitempty"`
+	
+	// Prompt prefixes to cache; individual messages opt in with "cacheable"
+	PromptCache *providers.PromptCache `json:"prompt_cache,omitempty"`
+	
+	// API key that authenticated the request, set by the handler
+	APIKeyID string `json:"-"`
+}
+
+// ResponseFormat asks for a kind of output. It is given either as a plain
+// string ("text", "json", "markdown", "code") or as an OpenAI-style object
+// such as {"type": "json_schema", "json_schema": {"name": ..., "schema": ...}}.
+type ResponseFormat struct {
+	Type       string      `json:"type,omitempty"` // text, json, json_object, json_schema, markdown, code
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+	MaxRepairs *int        `json:"max_repairs,omitempty"` // Repair round-trips for invalid JSON answers
+}
+
+// JSONSchema is the schema a json_schema answer must match
+type JSONSchema struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema"`
+	Strict      bool                   `json:"strict,omitempty"`
+}
+
+// UnmarshalJSON accepts the plain string form as well as the object form
+func (f *ResponseFormat) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*f = ResponseFormat{Type: name}
+		return nil
+	}
+	type format ResponseFormat
+	return json.Unmarshal(data, (*format)(f))
+}
+
+// IsJSON reports whether the format asks for JSON output
+func (f ResponseFormat) IsJSON() bool {
+	switch f.Type {
+	case "json", "json_object", "json_schema":
+		return true
+	}
+	return false
 }
 
 // Preferences for routing decisions
@@ -62,8 +127,10 @@ type UnifiedChatResponse struct {
 	ID        string              `json:"id"`
 	Content   string              `json:"content"`
 	Role      string              `json:"role"`
+	Reasoning string              `json:"reasoning,omitempty"` // Thinking that preceded the answer
 	Functions []FunctionResponse  `json:"functions,omitempty"`
 	Tools     []ToolResponse      `json:"tools,omitempty"`
+	Parsed    interface{}         `json:"parsed,omitempty"` // JSON answer decoded and validated against the response format
 	Metadata  ResponseMetadata    `json:"metadata"`
 	Usage     Usage               `json:"usage"`
 }
@@ -79,6 +146,7 @@ type ToolResponse struct {
 	ID       string           `json:"id"`
 	Type     string           `json:"type"`
 	Function FunctionResponse `json:"function"`
+	Index    *int             `json:"index,omitempty"` // Position of a streamed call
 }
 
 // ResponseMetadata provides transparency about routing
@@ -88,6 +156,18 @@ type ResponseMetadata struct {
 	LatencyMs     int64   `json:"latency_ms"`
 	Confidence    float32 `json:"confidence,omitempty"`
 	RoutingReason string  `json:"routing_reason,omitempty"`
+	RoutingScore  float64 `json:"routing_score,omitempty"`
+	ToolSteps     int     `json:"tool_steps,omitempty"`  // Server-side tool-calling rounds executed
+	StopReason    string  `json:"stop_reason,omitempty"` // Why the tool-calling loop ended
+	TokensTrimmed int     `json:"tokens_trimmed,omitempty"`   // Prompt tokens dropped to fit the context window
+	ContextStrategy string `json:"context_strategy,omitempty"` // Strategy used when trimming
+	BudgetWarnings []string `json:"budget_warnings,omitempty"`  // Budgets past their warning threshold
+	Cache         string  `json:"cache,omitempty"`            // Response cache result: hit, semantic_hit, coalesced, miss or bypass
+	CacheSimilarity float64 `json:"cache_similarity,omitempty"` // Prompt similarity of a semantic cache hit
+	Hedged        bool    `json:"hedged,omitempty"`       // A hedge was sent to a second connection
+	HedgeWinner   string  `json:"hedge_winner,omitempty"` // Attempt that answered: primary or hedge
+	StructuredOutput string `json:"structured_output,omitempty"` // How JSON output was obtained: json_schema, tool or prompt
+	SchemaRepairs int     `json:"schema_repairs,omitempty"` // Repair round-trips before the answer matched the schema
 }
 
 // Usage information
@@ -96,11 +176,13 @@ type Usage struct {
 	CompletionTokens int     `json:"completion_tokens"`
 	TotalTokens      int     `json:"total_tokens"`
 	EstimatedCost    float64 `json:"estimated_cost,omitempty"`
+	CacheReadTokens     int  `json:"cache_read_tokens,omitempty"`     // Prompt tokens served from the prompt cache
+	CacheCreationTokens int  `json:"cache_creation_tokens,omitempty"` // Prompt tokens written to the prompt cache
 }
 
 // UnifiedStreamChunk for streaming responses
 type UnifiedStreamChunk struct {
-	Type     string           `json:"type"` // content, function_call, tool_use, error, meta, done
+	Type     string           `json:"type"` // content, reasoning, function_call, tool_call_start, tool_call_delta, tool_call_end, tool_use, tool_result, failover, error, meta, done
 	Content  string           `json:"content,omitempty"`
 	Function *FunctionResponse `json:"function,omitempty"`
 	Tool     *ToolResponse     `json:"tool,omitempty"`
@@ -114,6 +196,10 @@ type ChunkMetadata struct {
 	Model      string `json:"model,omitempty"`
 	TokenCount int    `json:"token_count,omitempty"`
 	LatencyMs  int64  `json:"latency_ms,omitempty"`
+	FailoverFrom string `json:"failover_from,omitempty"` // Provider that failed mid-stream
+	TokensTrimmed int   `json:"tokens_trimmed,omitempty"` // Prompt tokens dropped to fit the context window
+	BudgetWarnings []string `json:"budget_warnings,omitempty"` // Budgets past their warning threshold
+	Hedged       bool   `json:"hedged,omitempty"`        // Answered by a hedge after a slow first token
 }
 
 // UnifiedError represents normalized errors
@@ -136,6 +222,7 @@ const (
 	ErrorTypeAuth       ErrorType = "authentication"
 	ErrorTypeNetwork    ErrorType = "network"
 	ErrorTypeInvalid    ErrorType = "invalid_request"
+	ErrorTypeBudget     ErrorType = "budget"
 )
 
 // Fallback information
@@ -27,6 +27,9 @@ func SetupRoutesWithAuth(app *fiber.App, svc *services.Services, authService *au
 		})
 	})
 	
+	// Prometheus scrape endpoint (requires AGENTX_METRICS_TOKEN)
+	app.Get("/metrics", handlers.Metrics())
+	
 	// Authentication endpoints
 	auth := api.Group("/auth")
 	auth.Post("/login", middleware.AuthRateLimit(), handlers.Login(authService, auditService, svc))
@@ -57,6 +60,13 @@ func SetupRoutesWithAuth(app *fiber.App, svc *services.Services, authService *au
 	// Legacy endpoints for backward compatibility
 	protected.Post("/chat/completions", unifiedHandler.ChatCompletions)  // OpenAI-compatible
 	
+	// Embeddings through the gateway
+	protected.Post("/embeddings", handlers.CreateEmbeddings(svc))
+	
+	// OpenAI-compatible API at the root, for SDKs that append /v1 to a base URL
+	openAI := app.Group("/v1", middleware.AuthRequired(authService))
+	openAI.Post("/embeddings", handlers.CreateEmbeddingsOpenAI(svc))
+	
 	// Session management
 	protected.Post("/sessions", handlers.CreateSession(svc))
 	protected.Get("/sessions", handlers.GetSessions(svc))
@@ -74,6 +84,25 @@ func SetupRoutesWithAuth(app *fiber.App, svc *services.Services, authService *au
 	llmHandler := handlers.NewLLMHandler(svc.LLM)
 	llmHandler.RegisterRoutes(protected)
 	
+	// Batch completion jobs
+	batchHandlers := handlers.NewBatchHandlers(svc.Batches)
+	protected.Post("/batches", batchHandlers.CreateBatch)
+	protected.Get("/batches", batchHandlers.ListBatches)
+	protected.Get("/batches/:id", batchHandlers.GetBatch)
+	protected.Get("/batches/:id/items", batchHandlers.ListBatchItems)
+	protected.Get("/batches/:id/results", batchHandlers.DownloadBatchResults)
+	protected.Post("/batches/:id/cancel", batchHandlers.CancelBatch)
+	protected.Post("/batches/:id/retry", batchHandlers.RetryBatch)
+
+	// Versioned prompt templates for built-in tasks
+	promptHandlers := handlers.NewPromptHandlers(svc.Prompts)
+	protected.Get("/prompts", promptHandlers.ListPrompts)
+	protected.Post("/prompts", promptHandlers.CreatePrompt)
+	protected.Post("/prompts/render", promptHandlers.RenderPrompt)
+	protected.Get("/prompts/:id", promptHandlers.GetPrompt)
+	protected.Put("/prompts/:id", promptHandlers.UpdatePrompt)
+	protected.Delete("/prompts/:id", promptHandlers.DeletePrompt)
+	
 	// Connections management (multi-connection system)
 	connectionHandlers := handlers.NewConnectionHandlers(svc.Connection)
 	protected.Get("/connections", connectionHandlers.ListConnections)
@@ -86,6 +115,12 @@ func SetupRoutesWithAuth(app *fiber.App, svc *services.Services, authService *au
 	protected.Post("/connections/:id/test", connectionHandlers.TestConnection)
 	protected.Post("/connections/:id/set-default", connectionHandlers.SetDefaultConnection)
 	
+	// Models of a connection; self-hosted providers can pull and delete them
+	protected.Get("/connections/:id/models", handlers.ListConnectionModels(svc))
+	protected.Get("/connections/:id/models/running", handlers.ListRunningModels(svc))
+	protected.Post("/connections/:id/models/pull", handlers.PullModel(svc))
+	protected.Delete("/connections/:id/models/*", handlers.DeleteModel(svc))
+	
 	// Settings (user-specific)
 	protected.Get("/settings", handlers.GetSettings(svc))
 	protected.Put("/settings", handlers.UpdateSettings(svc))
@@ -115,6 +150,33 @@ func SetupRoutesWithAuth(app *fiber.App, svc *services.Services, authService *au
 	protected.Post("/mcp/builtin/:id/toggle", builtinMCPHandlers.ToggleBuiltinServer)
 	protected.Post("/mcp/builtin/:id/convert", builtinMCPHandlers.ConvertToRegularServer)
 	
+	// Routing rules and fallback chains
+	routingHandlers := handlers.NewRoutingHandlers(svc.Routing)
+	protected.Get("/routing/rules", routingHandlers.ListRules)
+	protected.Post("/routing/rules", routingHandlers.CreateRule)
+	protected.Get("/routing/rules/:id", routingHandlers.GetRule)
+	protected.Put("/routing/rules/:id", routingHandlers.UpdateRule)
+	protected.Delete("/routing/rules/:id", routingHandlers.DeleteRule)
+	protected.Get("/routing/fallbacks", routingHandlers.ListChains)
+	protected.Post("/routing/fallbacks", routingHandlers.CreateChain)
+	protected.Get("/routing/fallbacks/:id", routingHandlers.GetChain)
+	protected.Put("/routing/fallbacks/:id", routingHandlers.UpdateChain)
+	protected.Delete("/routing/fallbacks/:id", routingHandlers.DeleteChain)
+	protected.Get("/routing/pools", routingHandlers.ListPools)
+	protected.Post("/routing/pools", routingHandlers.CreatePool)
+	protected.Get("/routing/pools/:id", routingHandlers.GetPool)
+	protected.Put("/routing/pools/:id", routingHandlers.UpdatePool)
+	protected.Delete("/routing/pools/:id", routingHandlers.DeletePool)
+	
+	// Usage ledger and pricing catalog
+	usageHandlers := handlers.NewUsageHandlers(svc.Usage)
+	protected.Get("/usage", usageHandlers.GetUsage)
+	protected.Get("/pricing", usageHandlers.ListPrices)
+	
+	// Spend caps and token quotas
+	budgetHandlers := handlers.NewBudgetHandlers(svc.Budgets)
+	protected.Get("/budgets/status", budgetHandlers.GetStatus)
+	
 	// Context Memory management
 	contextHandlers := handlers.NewContextMemoryHandlers(svc.ContextMemory)
 	protected.Post("/context/memory", contextHandlers.StoreMemory)
@@ -138,6 +200,16 @@ func SetupRoutesWithAuth(app *fiber.App, svc *services.Services, authService *au
 	admin.Put("/providers/:id/config", handlers.UpdateProviderConfig(svc))
 	admin.Post("/providers/:id/discover", handlers.DiscoverModels(svc))
 	admin.Get("/providers/health", handlers.GetProvidersHealth(svc))
+	admin.Get("/circuit-breakers", handlers.GetCircuitBreakers(svc))
+	admin.Post("/circuit-breakers/reset", handlers.ResetCircuitBreakers(svc))
+	admin.Post("/circuit-breakers/probe", handlers.ProbeCircuitBreakers(svc))
+	admin.Get("/usage/all", usageHandlers.GetAllUsage)
+	admin.Put("/pricing", usageHandlers.SetPrice)
+	admin.Delete("/pricing", usageHandlers.DeletePrice)
+	admin.Get("/budgets", budgetHandlers.ListBudgets)
+	admin.Post("/budgets", budgetHandlers.CreateBudget)
+	admin.Put("/budgets/:id", budgetHandlers.UpdateBudget)
+	admin.Delete("/budgets/:id", budgetHandlers.DeleteBudget)
 	
 	// ========================================
 	// WebSocket routes (with auth)
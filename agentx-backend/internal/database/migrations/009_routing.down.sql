@@ -0,0 +1,13 @@
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_fallback_chains_updated_at ON fallback_chains;
+DROP TRIGGER IF EXISTS update_routing_rules_updated_at ON routing_rules;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_fallback_chains_primary;
+DROP INDEX IF EXISTS idx_fallback_chains_user_id;
+DROP INDEX IF EXISTS idx_routing_rules_enabled;
+DROP INDEX IF EXISTS idx_routing_rules_user_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS fallback_chains;
+DROP TABLE IF EXISTS routing_rules;
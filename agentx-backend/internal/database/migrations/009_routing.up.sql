@@ -0,0 +1,50 @@
+-- Create routing rules table (declarative rules evaluated by the LLM router)
+CREATE TABLE IF NOT EXISTS routing_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE, -- NULL for global rules managed by admins
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    priority INTEGER NOT NULL DEFAULT 0, -- Higher priority rules are evaluated first
+    enabled BOOLEAN DEFAULT true,
+    conditions JSONB NOT NULL DEFAULT '{}', -- models, capabilities, message size, task types, user IDs
+    target_connection_id UUID REFERENCES provider_connections(id) ON DELETE CASCADE,
+    target_provider VARCHAR(50) NOT NULL DEFAULT '', -- Any of the user's connections of this type
+    target_model VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (target_connection_id IS NOT NULL OR target_provider <> '')
+);
+
+-- Create fallback chains table (ordered connections to try when the primary fails)
+CREATE TABLE IF NOT EXISTS fallback_chains (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    primary_connection_id UUID REFERENCES provider_connections(id) ON DELETE CASCADE, -- NULL applies to any primary
+    steps JSONB NOT NULL DEFAULT '[]', -- Ordered [{connection_id, model}]
+    enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, name)
+);
+
+-- Create indexes for better query performance
+CREATE INDEX idx_routing_rules_user_id ON routing_rules(user_id);
+CREATE INDEX idx_routing_rules_enabled ON routing_rules(enabled);
+CREATE INDEX idx_fallback_chains_user_id ON fallback_chains(user_id);
+CREATE INDEX idx_fallback_chains_primary ON fallback_chains(primary_connection_id);
+
+-- Add triggers to update updated_at timestamp
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER update_routing_rules_updated_at BEFORE UPDATE ON routing_rules 
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_fallback_chains_updated_at BEFORE UPDATE ON fallback_chains 
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
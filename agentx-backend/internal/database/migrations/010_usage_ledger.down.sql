@@ -0,0 +1,11 @@
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_model_pricing_updated_at ON model_pricing;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_usage_records_model;
+DROP INDEX IF EXISTS idx_usage_records_created_at;
+DROP INDEX IF EXISTS idx_usage_records_user_created;
+
+-- Drop tables
+DROP TABLE IF EXISTS model_pricing;
+DROP TABLE IF EXISTS usage_records;
@@ -0,0 +1,36 @@
+-- Create usage ledger (one row per gateway call)
+CREATE TABLE IF NOT EXISTS usage_records (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    connection_id UUID REFERENCES provider_connections(id) ON DELETE SET NULL,
+    provider VARCHAR(50) NOT NULL DEFAULT '',
+    model VARCHAR(255) NOT NULL DEFAULT '',
+    task_type VARCHAR(50) NOT NULL DEFAULT '',
+    stream BOOLEAN NOT NULL DEFAULT false,
+    prompt_tokens INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    cost NUMERIC(14, 8) NOT NULL DEFAULT 0, -- USD
+    latency_ms INTEGER NOT NULL DEFAULT 0,
+    success BOOLEAN NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    fallback_used BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create editable pricing catalog (overrides the built-in list prices)
+CREATE TABLE IF NOT EXISTS model_pricing (
+    model VARCHAR(255) PRIMARY KEY, -- Model ID or prefix, e.g. "gpt-4o"
+    input_per_million NUMERIC(12, 6) NOT NULL DEFAULT 0, -- USD per million prompt tokens
+    output_per_million NUMERIC(12, 6) NOT NULL DEFAULT 0, -- USD per million completion tokens
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for aggregation queries
+CREATE INDEX idx_usage_records_user_created ON usage_records(user_id, created_at);
+CREATE INDEX idx_usage_records_created_at ON usage_records(created_at);
+CREATE INDEX idx_usage_records_model ON usage_records(model);
+
+-- Add trigger to update updated_at timestamp
+CREATE TRIGGER update_model_pricing_updated_at BEFORE UPDATE ON model_pricing 
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
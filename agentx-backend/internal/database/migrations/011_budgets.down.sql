@@ -0,0 +1,12 @@
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_budgets_updated_at ON budgets;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_budgets_user_id;
+DROP INDEX IF EXISTS idx_budgets_scope;
+DROP INDEX IF EXISTS idx_usage_records_connection_created;
+DROP INDEX IF EXISTS idx_usage_records_api_key_created;
+
+-- Drop tables and columns
+DROP TABLE IF EXISTS budgets;
+ALTER TABLE usage_records DROP COLUMN IF EXISTS api_key_id;
@@ -0,0 +1,32 @@
+-- Attribute usage to the API key that authenticated the request
+ALTER TABLE usage_records 
+    ADD COLUMN api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_usage_records_api_key_created ON usage_records(api_key_id, created_at) WHERE api_key_id IS NOT NULL;
+CREATE INDEX idx_usage_records_connection_created ON usage_records(connection_id, created_at) WHERE connection_id IS NOT NULL;
+
+-- Create budgets table (spend caps and token quotas enforced by the LLM gateway)
+CREATE TABLE IF NOT EXISTS budgets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- Owner of the capped user, API key or connection
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('user', 'api_key', 'connection')),
+    scope_id UUID NOT NULL,
+    period VARCHAR(10) NOT NULL DEFAULT 'monthly' CHECK (period IN ('daily', 'monthly')),
+    spend_limit NUMERIC(12, 4) NOT NULL DEFAULT 0, -- USD per period, 0 for no cap
+    warn_threshold NUMERIC(4, 3) NOT NULL DEFAULT 0.8, -- Fraction of spend_limit that adds warnings
+    tokens_per_minute INTEGER NOT NULL DEFAULT 0, -- 0 for no quota
+    action VARCHAR(20) NOT NULL DEFAULT 'reject' CHECK (action IN ('reject', 'downgrade')),
+    downgrade_model VARCHAR(255) NOT NULL DEFAULT '',
+    enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (action <> 'downgrade' OR downgrade_model <> '')
+);
+
+-- Create indexes for better query performance
+CREATE INDEX idx_budgets_scope ON budgets(scope, scope_id);
+CREATE INDEX idx_budgets_user_id ON budgets(user_id);
+
+-- Add trigger to update updated_at timestamp
+CREATE TRIGGER update_budgets_updated_at BEFORE UPDATE ON budgets 
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_response_cache_expires_at;
+DROP INDEX IF EXISTS idx_response_cache_scope;
+
+-- Drop tables
+DROP TABLE IF EXISTS response_cache;
@@ -0,0 +1,14 @@
+-- Create response_cache table (LLM gateway responses reused for repeated prompts)
+CREATE TABLE IF NOT EXISTS response_cache (
+    cache_key VARCHAR(64) PRIMARY KEY, -- Hash of the user, connection, model, messages and parameters
+    user_id VARCHAR(255) NOT NULL,
+    scope VARCHAR(64) NOT NULL, -- Hash of the same request without its final prompt
+    embedding REAL[], -- Final prompt embedding, set when semantic caching is on
+    response JSONB NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for better query performance
+CREATE INDEX idx_response_cache_scope ON response_cache(user_id, scope, expires_at) WHERE embedding IS NOT NULL;
+CREATE INDEX idx_response_cache_expires_at ON response_cache(expires_at);
@@ -0,0 +1,8 @@
+-- Drop trigger
+DROP TRIGGER IF EXISTS update_connection_pools_updated_at ON connection_pools;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_connection_pools_user_id;
+
+-- Drop tables
+DROP TABLE IF EXISTS connection_pools;
@@ -0,0 +1,19 @@
+-- Create connection pools table (equivalent connections the LLM router load balances across)
+CREATE TABLE IF NOT EXISTS connection_pools (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    strategy VARCHAR(50) NOT NULL DEFAULT 'round_robin', -- round_robin, least_outstanding, ewma_latency, weighted_random, health_score
+    members JSONB NOT NULL DEFAULT '[]', -- [{connection_id, weight}]
+    enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, name)
+);
+
+-- Create indexes for better query performance
+CREATE INDEX idx_connection_pools_user_id ON connection_pools(user_id);
+
+-- Add trigger to update updated_at timestamp
+CREATE TRIGGER update_connection_pools_updated_at BEFORE UPDATE ON connection_pools 
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,12 @@
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_batch_items_updated_at ON batch_items;
+DROP TRIGGER IF EXISTS update_batch_jobs_updated_at ON batch_jobs;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_batch_items_job_status;
+DROP INDEX IF EXISTS idx_batch_jobs_status;
+DROP INDEX IF EXISTS idx_batch_jobs_user_created;
+
+-- Drop tables
+DROP TABLE IF EXISTS batch_items;
+DROP TABLE IF EXISTS batch_jobs;
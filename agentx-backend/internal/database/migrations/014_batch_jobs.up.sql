@@ -0,0 +1,43 @@
+-- Create batch jobs table (JSONL completion requests processed in the background)
+CREATE TABLE IF NOT EXISTS batch_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'cancelled')),
+    concurrency INTEGER NOT NULL DEFAULT 4, -- Items in flight per connection
+    max_attempts INTEGER NOT NULL DEFAULT 3,
+    total_items INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create batch items table (one row per JSONL line)
+CREATE TABLE IF NOT EXISTS batch_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    job_id UUID NOT NULL REFERENCES batch_jobs(id) ON DELETE CASCADE,
+    line INTEGER NOT NULL, -- Position in the uploaded JSONL, from 1
+    custom_id VARCHAR(255) NOT NULL DEFAULT '',
+    request JSONB NOT NULL, -- llm.CompletionRequest
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'succeeded', 'failed', 'cancelled')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response JSONB, -- llm.CompletionResponse of a succeeded item
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE(job_id, line)
+);
+
+-- Create indexes for better query performance
+CREATE INDEX idx_batch_jobs_user_created ON batch_jobs(user_id, created_at);
+CREATE INDEX idx_batch_jobs_status ON batch_jobs(status) WHERE status IN ('queued', 'running');
+CREATE INDEX idx_batch_items_job_status ON batch_items(job_id, status);
+
+-- Add triggers to update updated_at timestamp
+CREATE TRIGGER update_batch_jobs_updated_at BEFORE UPDATE ON batch_jobs 
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_batch_items_updated_at BEFORE UPDATE ON batch_items 
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
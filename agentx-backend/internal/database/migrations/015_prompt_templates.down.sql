@@ -0,0 +1,9 @@
+-- Drop triggers
+DROP TRIGGER IF EXISTS update_prompt_templates_updated_at ON prompt_templates;
+
+-- Drop indexes
+DROP INDEX IF EXISTS idx_prompt_templates_default;
+DROP INDEX IF EXISTS idx_prompt_templates_version;
+
+-- Drop tables
+DROP TABLE IF EXISTS prompt_templates;
@@ -0,0 +1,24 @@
+-- Create prompt_templates table (versioned prompts rendered by the built-in LLM tasks)
+CREATE TABLE IF NOT EXISTS prompt_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE, -- NULL for global templates
+    name VARCHAR(100) NOT NULL,
+    version VARCHAR(50) NOT NULL, -- Semantic version
+    description TEXT NOT NULL DEFAULT '',
+    system_template TEXT NOT NULL DEFAULT '', -- Go text/template
+    user_template TEXT NOT NULL DEFAULT '', -- Go text/template
+    is_default BOOLEAN NOT NULL DEFAULT false, -- Version the owner's tasks render
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One row per version and at most one default per owner and name; global
+-- templates have no user_id, so it is coalesced to a fixed UUID
+CREATE UNIQUE INDEX idx_prompt_templates_version 
+    ON prompt_templates(COALESCE(user_id, '00000000-0000-0000-0000-000000000000'::uuid), name, version);
+CREATE UNIQUE INDEX idx_prompt_templates_default 
+    ON prompt_templates(COALESCE(user_id, '00000000-0000-0000-0000-000000000000'::uuid), name) WHERE is_default;
+
+-- Add trigger to update updated_at timestamp
+CREATE TRIGGER update_prompt_templates_updated_at BEFORE UPDATE ON prompt_templates 
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
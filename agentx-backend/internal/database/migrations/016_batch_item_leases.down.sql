@@ -0,0 +1,8 @@
+-- Drop index
+DROP INDEX IF EXISTS idx_batch_items_job_lease;
+
+-- Drop columns
+ALTER TABLE batch_items
+    DROP COLUMN IF EXISTS retry_at,
+    DROP COLUMN IF EXISTS lease_expires_at,
+    DROP COLUMN IF EXISTS lease_owner;
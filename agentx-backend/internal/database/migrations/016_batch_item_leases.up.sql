@@ -0,0 +1,9 @@
+-- Lease batch items to the backend instance processing them, so instances
+-- sharing the database neither run an item twice nor reset each other's work
+ALTER TABLE batch_items
+    ADD COLUMN lease_owner VARCHAR(64) NOT NULL DEFAULT '', -- Instance running the item
+    ADD COLUMN lease_expires_at TIMESTAMP WITH TIME ZONE, -- Renewed while the item runs
+    ADD COLUMN retry_at TIMESTAMP WITH TIME ZONE; -- A requeued item waits until then
+
+-- Create index for claiming pending and expired items
+CREATE INDEX idx_batch_items_job_lease ON batch_items(job_id, status, lease_expires_at);
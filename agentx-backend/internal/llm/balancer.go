@@ -0,0 +1,263 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// Load balancing strategies for connection pools
+const (
+	BalanceRoundRobin       = "round_robin"
+	BalanceLeastOutstanding = "least_outstanding"
+	BalanceEWMALatency      = "ewma_latency"
+	BalanceWeightedRandom   = "weighted_random"
+	BalanceHealthScore      = "health_score"
+)
+
+// BalancingStrategies lists the strategies a connection pool can use
+var BalancingStrategies = []string{
+	BalanceRoundRobin,
+	BalanceLeastOutstanding,
+	BalanceEWMALatency,
+	BalanceWeightedRandom,
+	BalanceHealthScore,
+}
+
+// IsBalancingStrategy reports whether name is a known strategy. An empty
+// name selects round robin.
+func IsBalancingStrategy(name string) bool {
+	return name == "" || containsString(BalancingStrategies, name)
+}
+
+// ConnectionStats tracks in-flight requests and recent outcomes per
+// connection for the load balancers
+type ConnectionStats struct {
+	alpha       float64
+	outstanding map[string]int
+	success     map[string]float64
+	mu          sync.Mutex
+}
+
+// NewConnectionStats creates stats where each outcome carries 20% weight in
+// the success rate
+func NewConnectionStats() *ConnectionStats {
+	return &ConnectionStats{
+		alpha:       0.2,
+		outstanding: make(map[string]int),
+		success:     make(map[string]float64),
+	}
+}
+
+// Begin records a request starting on a connection. The returned function
+// ends it with the call's outcome; cancelled calls leave the success rate
+// untouched.
+func (s *ConnectionStats) Begin(key string) func(err error) {
+	s.mu.Lock()
+	s.outstanding[key]++
+	s.mu.Unlock()
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.outstanding[key]--; s.outstanding[key] <= 0 {
+				delete(s.outstanding, key)
+			}
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			outcome := 1.0
+			if err != nil {
+				outcome = 0
+			}
+			current, exists := s.success[key]
+			if !exists {
+				current = 1
+			}
+			s.success[key] = s.alpha*outcome + (1-s.alpha)*current
+		})
+	}
+}
+
+// Outstanding returns the number of requests in flight on a connection
+func (s *ConnectionStats) Outstanding(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.outstanding[key]
+}
+
+// SuccessRate returns the smoothed share of successful calls, 1 for a
+// connection without any
+func (s *ConnectionStats) SuccessRate(key string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rate, exists := s.success[key]; exists {
+		return rate
+	}
+	return 1
+}
+
+// LeastOutstandingBalancer picks the connection with the fewest requests in
+// flight, preferring the earliest listed on ties
+type LeastOutstandingBalancer struct {
+	stats *ConnectionStats
+}
+
+// SelectProvider selects the least busy provider
+func (b *LeastOutstandingBalancer) SelectProvider(providers []string, req *Request) string {
+	return pickLowest(providers, func(key string) float64 {
+		return float64(b.stats.Outstanding(key))
+	})
+}
+
+// EWMALatencyBalancer picks the connection with the lowest smoothed latency.
+// Connections without measurements are tried first so they get one.
+type EWMALatencyBalancer struct {
+	latency *LatencyTracker
+}
+
+// SelectProvider selects the fastest provider
+func (b *EWMALatencyBalancer) SelectProvider(providers []string, req *Request) string {
+	return pickLowest(providers, func(key string) float64 {
+		latency, _ := b.latency.Estimate(latencyKey(key, ""))
+		return float64(latency)
+	})
+}
+
+// WeightedRandomBalancer picks a connection at random in proportion to its
+// weight. Connections without a weight count as 1.
+type WeightedRandomBalancer struct {
+	weights map[string]float64
+	rand    func() float64
+}
+
+// SelectProvider selects a provider at random by weight
+func (b *WeightedRandomBalancer) SelectProvider(providers []string, req *Request) string {
+	if len(providers) == 0 {
+		return ""
+	}
+	total := 0.0
+	for _, key := range providers {
+		total += b.weight(key)
+	}
+	if total <= 0 {
+		return providers[0]
+	}
+	target := b.rand() * total
+	for _, key := range providers {
+		target -= b.weight(key)
+		if target < 0 {
+			return key
+		}
+	}
+	return providers[len(providers)-1]
+}
+
+func (b *WeightedRandomBalancer) weight(key string) float64 {
+	if weight, ok := b.weights[key]; ok {
+		return weight
+	}
+	return 1
+}
+
+// HealthScoreBalancer picks the connection with the best health score: its
+// recent success rate, scaled down when the last health check found it
+// degraded or unhealthy, and divided among the requests it is serving
+type HealthScoreBalancer struct {
+	stats  *ConnectionStats
+	health func(key string) (HealthStatus, bool)
+}
+
+// SelectProvider selects the healthiest provider
+func (b *HealthScoreBalancer) SelectProvider(providers []string, req *Request) string {
+	return pickLowest(providers, func(key string) float64 {
+		return -b.score(key)
+	})
+}
+
+func (b *HealthScoreBalancer) score(key string) float64 {
+	score := b.stats.SuccessRate(key)
+	if status, ok := b.health(key); ok {
+		switch status.Status {
+		case "degraded":
+			score *= 0.5
+		case "unhealthy":
+			score *= 0.1
+		}
+	}
+	return score / float64(1+b.stats.Outstanding(key))
+}
+
+// pickLowest returns the provider with the lowest cost, the earliest listed
+// on ties
+func pickLowest(providers []string, cost func(key string) float64) string {
+	best := ""
+	bestCost := math.Inf(1)
+	for _, key := range providers {
+		if c := cost(key); c < bestCost {
+			best, bestCost = key, c
+		}
+	}
+	return best
+}
+
+// newPoolBalancer creates the load balancer for a pool of a user's connections
+func (r *Router) newPoolBalancer(userID string, pool ConnectionPool) LoadBalancer {
+	switch pool.Strategy {
+	case BalanceLeastOutstanding:
+		return &LeastOutstandingBalancer{stats: r.stats}
+	case BalanceEWMALatency:
+		return &EWMALatencyBalancer{latency: r.latency}
+	case BalanceWeightedRandom:
+		weights := make(map[string]float64, len(pool.Members))
+		for _, member := range pool.Members {
+			if member.Weight > 0 {
+				weights[r.connectionKey(userID, member.ConnectionID)] = member.Weight
+			}
+		}
+		return &WeightedRandomBalancer{weights: weights, rand: rand.Float64}
+	case BalanceHealthScore:
+		return &HealthScoreBalancer{stats: r.stats, health: r.providers.GetHealthStatusByKey}
+	default:
+		return &RoundRobinBalancer{}
+	}
+}
+
+// poolBalancer returns the cached balancer of a user's pool, so stateful
+// strategies such as round robin keep their position between requests
+func (r *Router) poolBalancer(userID string, pool ConnectionPool) LoadBalancer {
+	cacheKey := userID + "|" + pool.Name + "|" + pool.Strategy
+	r.balancerMu.Lock()
+	defer r.balancerMu.Unlock()
+	if balancer, ok := r.balancers[cacheKey]; ok {
+		return balancer
+	}
+	balancer := r.newPoolBalancer(userID, pool)
+	r.balancers[cacheKey] = balancer
+	return balancer
+}
+
+// poolFor returns the pool a connection belongs to
+func poolFor(routing *RoutingConfig, userID, key string) (ConnectionPool, bool) {
+	if routing == nil {
+		return ConnectionPool{}, false
+	}
+	for _, pool := range routing.Pools {
+		for _, member := range pool.Members {
+			if userID+":"+member.ConnectionID == key {
+				return pool, true
+			}
+		}
+	}
+	return ConnectionPool{}, false
+}
+
+// BeginCall records a request starting on a connection for the load
+// balancers; the returned function ends it with the call's outcome
+func (r *Router) BeginCall(userID, connectionID string) func(err error) {
+	return r.stats.Begin(r.connectionKey(userID, connectionID))
+}
@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadBalancingStrategies(t *testing.T) {
+	keys := []string{"user:a", "user:b", "user:c"}
+	stats := NewConnectionStats()
+
+	// Least outstanding avoids busy connections
+	endA := stats.Begin("user:a")
+	stats.Begin("user:b")
+	assert.Equal(t, "user:c", (&LeastOutstandingBalancer{stats: stats}).SelectProvider(keys, nil))
+	endA(nil)
+	assert.Equal(t, "user:a", (&LeastOutstandingBalancer{stats: stats}).SelectProvider(keys, nil))
+
+	// EWMA latency tries unmeasured connections, then the fastest
+	latency := NewLatencyTracker()
+	latency.Observe(latencyKey("user:a", ""), 900*time.Millisecond)
+	latency.Observe(latencyKey("user:b", ""), 100*time.Millisecond)
+	ewma := &EWMALatencyBalancer{latency: latency}
+	assert.Equal(t, "user:c", ewma.SelectProvider(keys, nil))
+	latency.Observe(latencyKey("user:c", ""), 500*time.Millisecond)
+	assert.Equal(t, "user:b", ewma.SelectProvider(keys, nil))
+
+	// Weighted random lands in proportion to the weights
+	random := &WeightedRandomBalancer{weights: map[string]float64{"user:a": 1, "user:b": 3, "user:c": 0}}
+	random.rand = func() float64 { return 0.2 }
+	assert.Equal(t, "user:a", random.SelectProvider(keys, nil))
+	random.rand = func() float64 { return 0.9 }
+	assert.Equal(t, "user:b", random.SelectProvider(keys, nil))
+
+	// Health score steers away from failing and unhealthy connections
+	healthStats := NewConnectionStats()
+	for i := 0; i < 3; i++ {
+		healthStats.Begin("user:a")(errors.New("boom"))
+	}
+	healthStats.Begin("user:c")(context.Canceled)
+	health := &HealthScoreBalancer{stats: healthStats, health: func(key string) (HealthStatus, bool) {
+		return HealthStatus{Status: "unhealthy"}, key == "user:b"
+	}}
+	assert.Equal(t, "user:c", health.SelectProvider(keys, nil))
+	assert.Equal(t, 1.0, healthStats.SuccessRate("user:c"))
+}
+
+func TestRouteBalancesAcrossPool(t *testing.T) {
+	g := NewGateway(WithMiddleware(&BaseMiddleware{}))
+	g.providers.factory = fakeFactory{"key-1": &fakeProvider{}, "key-2": &fakeProvider{}, "other": &fakeProvider{}}
+	for _, name := range []string{"key-1", "key-2", "other"} {
+		assert.NoError(t, g.RegisterProvider("user", name, ProviderConfig{Name: name, Type: "openai"}))
+	}
+	g.router.SetRoutingStore(&staticRoutingStore{config: &RoutingConfig{
+		Pools: []ConnectionPool{{
+			Name:    "openai-keys",
+			Members: []PoolMember{{ConnectionID: "key-1"}, {ConnectionID: "key-2"}},
+		}},
+	}})
+
+	counts := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		_, info, err := g.router.Route(context.Background(), &Request{
+			UserID:   "user",
+			Messages: []Message{{Role: "user", Content: "hi"}},
+		})
+		assert.NoError(t, err)
+		assert.Contains(t, info.Reason, `pool "openai-keys" (round_robin)`)
+		counts[info.ConnectionID]++
+	}
+	assert.Equal(t, map[string]int{"key-1": 2, "key-2": 2}, counts)
+
+	// Switching strategy takes effect once routing is reloaded
+	g.router.SetRoutingStore(&staticRoutingStore{config: &RoutingConfig{
+		Pools: []ConnectionPool{{
+			Name:     "openai-keys",
+			Strategy: BalanceLeastOutstanding,
+			Members:  []PoolMember{{ConnectionID: "key-1"}, {ConnectionID: "key-2"}},
+		}},
+	}})
+	end := g.router.BeginCall("user", "key-1")
+	defer end(nil)
+	_, info, err := g.router.Route(context.Background(), &Request{
+		UserID:   "user",
+		Messages: []Message{{Role: "user", Content: "hi"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "key-2", info.ConnectionID)
+}
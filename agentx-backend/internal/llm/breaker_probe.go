@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// probeTimeout bounds a single synthetic probe call
+const probeTimeout = 15 * time.Second
+
+// breakerSettings resolves the settings of a userID:connectionID:model
+// breaker key from the connection's config
+func (g *Gateway) breakerSettings(key string) BreakerSettings {
+	parts := strings.SplitN(key, ":", 3)
+	if len(parts) < 3 {
+		return DefaultBreakerSettings()
+	}
+	config, ok := g.providers.GetConfig(parts[0], parts[1])
+	if !ok {
+		return DefaultBreakerSettings()
+	}
+	return config.BreakerSettingsFor(parts[2])
+}
+
+// StartBreakerProbes periodically sends a minimal completion through every
+// breaker that is ready to probe, so a recovered connection is closed again
+// without waiting for user traffic to find out. It runs until Shutdown.
+func (g *Gateway) StartBreakerProbes(interval time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.probeStop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	g.probeStop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				g.ProbeBreakers(context.Background())
+			}
+		}
+	}()
+}
+
+// ProbeBreakers probes every half-open breaker once, and reports how many
+// probes succeeded
+func (g *Gateway) ProbeBreakers(ctx context.Context) int {
+	succeeded := 0
+	for _, snap := range g.circuitBreaker.Snapshot() {
+		if snap.State != StateHalfOpen.String() {
+			continue
+		}
+		if err := g.probeBreaker(ctx, snap.Key); err == nil {
+			succeeded++
+		}
+	}
+	return succeeded
+}
+
+// probeBreaker sends a one-token completion through a breaker, taking one of
+// its half-open probe slots
+func (g *Gateway) probeBreaker(ctx context.Context, key string) error {
+	parts := strings.SplitN(key, ":", 3)
+	if len(parts) < 3 {
+		return fmt.Errorf("invalid breaker key: %s", key)
+	}
+	provider, err := g.providers.GetProvider(parts[0], parts[1])
+	if err != nil {
+		return err
+	}
+
+	maxTokens := 1
+	req := &Request{
+		UserID:       parts[0],
+		ConnectionID: parts[1],
+		Model:        parts[2],
+		Messages:     []Message{{Role: "user", Content: "ping"}},
+		MaxTokens:    &maxTokens,
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	err = g.circuitBreaker.Execute(key, func() error {
+		_, err := provider.Complete(probeCtx, req)
+		return err
+	})
+	if err != nil {
+		fmt.Printf("[Gateway] Probe of %s failed: %v\n", key, err)
+	}
+	return err
+}
+
+// CircuitBreakers returns the state of every circuit breaker
+func (g *Gateway) CircuitBreakers() []BreakerSnapshot {
+	return g.circuitBreaker.Snapshot()
+}
+
+// CircuitBreakerEvents returns recent circuit breaker transitions, newest
+// first
+func (g *Gateway) CircuitBreakerEvents() []BreakerEvent {
+	return g.circuitBreaker.Events()
+}
+
+// OnBreakerTransition registers a listener for circuit breaker transitions
+func (g *Gateway) OnBreakerTransition(listener func(BreakerEvent)) {
+	g.circuitBreaker.OnTransition(listener)
+}
+
+// ResetCircuitBreaker closes one breaker, reporting whether it exists
+func (g *Gateway) ResetCircuitBreaker(key string) bool {
+	return g.circuitBreaker.Reset(key)
+}
+
+// ResetCircuitBreakers closes every breaker
+func (g *Gateway) ResetCircuitBreakers() {
+	g.circuitBreaker.ResetAll()
+}
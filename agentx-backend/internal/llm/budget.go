@@ -0,0 +1,409 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BudgetScope is what a budget caps
+type BudgetScope string
+
+const (
+	BudgetScopeUser       BudgetScope = "user"
+	BudgetScopeAPIKey     BudgetScope = "api_key"
+	BudgetScopeConnection BudgetScope = "connection"
+)
+
+// Budget actions when a spend cap is reached
+const (
+	BudgetActionReject    = "reject"
+	BudgetActionDowngrade = "downgrade"
+)
+
+// Error codes of requests refused by budgets and quotas
+const (
+	ErrCodeBudgetExceeded     = "budget_exceeded"
+	ErrCodeTokenQuotaExceeded = "token_quota_exceeded"
+	ErrCodeMaxCostExceeded    = "max_cost_exceeded"
+)
+
+const (
+	// MetadataAPIKeyID is the request metadata key holding the ID of the API
+	// key that authenticated the request
+	MetadataAPIKeyID = "api_key_id"
+	// MetadataBudgetWarnings holds soft-limit warnings for the response
+	MetadataBudgetWarnings = "budget_warnings"
+)
+
+// Budget is a spend cap and/or token-per-minute quota on a user, API key or
+// connection. Zero limits are not enforced.
+type Budget struct {
+	ID              string
+	Scope           BudgetScope
+	ScopeID         string
+	Period          string  // daily or monthly
+	SpendLimit      float64 // USD per period
+	WarnThreshold   float64 // Fraction of SpendLimit that triggers a warning
+	TokensPerMinute int
+	Action          string // reject or downgrade
+	DowngradeModel  string
+}
+
+// PeriodStart returns the start of the budget period containing now, in UTC
+func (b Budget) PeriodStart(now time.Time) time.Time {
+	now = now.UTC()
+	if b.Period == "daily" {
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// PeriodEnd returns when the budget period containing now resets
+func (b Budget) PeriodEnd(now time.Time) time.Time {
+	start := b.PeriodStart(now)
+	if b.Period == "daily" {
+		return start.AddDate(0, 0, 1)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// BudgetStore loads budgets and the spend recorded against them
+type BudgetStore interface {
+	BudgetsFor(ctx context.Context, userID, apiKeyID, connectionID string) ([]Budget, error)
+	SpendSince(ctx context.Context, budget Budget, since time.Time) (float64, error)
+}
+
+// BudgetError is returned when a request would exceed a hard limit
+type BudgetError struct {
+	Code    string
+	Scope   BudgetScope
+	ScopeID string
+	Period  string
+	Limit   float64
+	Used    float64
+	ResetAt time.Time
+}
+
+func (e *BudgetError) Error() string {
+	switch e.Code {
+	case ErrCodeTokenQuotaExceeded:
+		return fmt.Sprintf("%s token quota exceeded: %.0f of %.0f tokens per minute used", e.Scope, e.Used, e.Limit)
+	case ErrCodeMaxCostExceeded:
+		return fmt.Sprintf("request would cost an estimated $%.4f, above the maximum of $%.4f", e.Used, e.Limit)
+	default:
+		return fmt.Sprintf("%s %s budget exceeded: $%.2f of $%.2f spent", e.Scope, e.Period, e.Used, e.Limit)
+	}
+}
+
+// RouteMiddleware is implemented by middleware that acts on the routing
+// decision, such as budgets scoped to the routed connection
+type RouteMiddleware interface {
+	PostRoute(ctx context.Context, req *Request, route *RouteInfo) (*Request, error)
+}
+
+// FallbackMiddleware is implemented by route middleware that also vets the
+// connections a request fails over or is hedged to
+type FallbackMiddleware interface {
+	PostFallback(ctx context.Context, req *Request, route *RouteInfo) (*Request, error)
+}
+
+// BudgetMiddleware enforces spend caps, token-per-minute quotas and the
+// per-request Requirements.MaxCost
+type BudgetMiddleware struct {
+	BaseMiddleware
+	store    BudgetStore
+	pricing  func() *PricingCatalog
+	cacheTTL time.Duration
+	budgets  map[string]cachedBudgets
+	spend    map[string]cachedSpend
+	tokens   map[string][]tokenUse
+	mu       sync.Mutex
+}
+
+type cachedBudgets struct {
+	budgets  []Budget
+	loadedAt time.Time
+}
+
+type cachedSpend struct {
+	amount   float64
+	loadedAt time.Time
+}
+
+type tokenUse struct {
+	at     time.Time
+	tokens int
+}
+
+// NewBudgetMiddleware creates budget middleware pricing requests with the
+// given catalog
+func NewBudgetMiddleware(pricing func() *PricingCatalog) *BudgetMiddleware {
+	return &BudgetMiddleware{
+		pricing:  pricing,
+		cacheTTL: 15 * time.Second,
+		budgets:  make(map[string]cachedBudgets),
+		spend:    make(map[string]cachedSpend),
+		tokens:   make(map[string][]tokenUse),
+	}
+}
+
+// SetStore sets where budgets and spend are loaded from
+func (m *BudgetMiddleware) SetStore(store BudgetStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+	m.budgets = make(map[string]cachedBudgets)
+	m.spend = make(map[string]cachedSpend)
+}
+
+// Invalidate drops cached budgets and spend so changes apply immediately
+func (m *BudgetMiddleware) Invalidate() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.budgets = make(map[string]cachedBudgets)
+	m.spend = make(map[string]cachedSpend)
+}
+
+// PostRoute checks the budgets of the user, API key and routed connection.
+// Requests over a hard limit are rejected, or moved to the budget's
+// downgrade model; budgets past their warning threshold add warnings.
+func (m *BudgetMiddleware) PostRoute(ctx context.Context, req *Request, route *RouteInfo) (*Request, error) {
+	return m.enforce(ctx, req, route, false)
+}
+
+// PostFallback checks the budgets of a connection the request fails over or
+// is hedged to. The user and API key budgets were checked and charged when
+// the request was routed, so only the connection's own budgets and the
+// per-request cost cap apply.
+func (m *BudgetMiddleware) PostFallback(ctx context.Context, req *Request, route *RouteInfo) (*Request, error) {
+	return m.enforce(ctx, req, route, true)
+}
+
+// enforce checks the budgets that apply to a routed request, or only the
+// connection's budgets for a fallback
+func (m *BudgetMiddleware) enforce(ctx context.Context, req *Request, route *RouteInfo, fallback bool) (*Request, error) {
+	now := time.Now()
+	promptTokens := estimatePromptTokens(req.Messages, req.Tools)
+	maxTokens := 0
+	if req.MaxTokens != nil {
+		maxTokens = *req.MaxTokens
+	}
+
+	// Per-request cost cap
+	if req.Requirements.MaxCost > 0 && m.pricing != nil {
+		if pricing, ok := m.pricing().Lookup(req.Model); ok {
+			estimate := pricing.Cost(Usage{PromptTokens: promptTokens, CompletionTokens: maxTokens})
+			if estimate > req.Requirements.MaxCost {
+				return req, &BudgetError{
+					Code:  ErrCodeMaxCostExceeded,
+					Scope: "request",
+					Limit: req.Requirements.MaxCost,
+					Used:  estimate,
+				}
+			}
+		}
+	}
+
+	apiKeyID, _ := req.Metadata[MetadataAPIKeyID].(string)
+	connectionID := strings.TrimPrefix(route.ConnectionID, req.UserID+":")
+	budgets, err := m.budgetsFor(ctx, req.UserID, apiKeyID, connectionID)
+	if err != nil {
+		// Budgets must not take the gateway down with the database
+		fmt.Printf("[BudgetMiddleware] Failed to load budgets for user %s: %v\n", req.UserID, err)
+		return req, nil
+	}
+
+	if fallback {
+		var connectionBudgets []Budget
+		for _, budget := range budgets {
+			if budget.Scope == BudgetScopeConnection {
+				connectionBudgets = append(connectionBudgets, budget)
+			}
+		}
+		budgets = connectionBudgets
+	}
+
+	var warnings []string
+	for _, budget := range budgets {
+		if budget.TokensPerMinute > 0 {
+			if used, resetAt := m.tokensUsed(budget, now); used+promptTokens > budget.TokensPerMinute {
+				return req, &BudgetError{
+					Code:    ErrCodeTokenQuotaExceeded,
+					Scope:   budget.Scope,
+					ScopeID: budget.ScopeID,
+					Limit:   float64(budget.TokensPerMinute),
+					Used:    float64(used),
+					ResetAt: resetAt,
+				}
+			}
+		}
+
+		if budget.SpendLimit <= 0 {
+			continue
+		}
+		spent, err := m.spent(ctx, budget, now)
+		if err != nil {
+			fmt.Printf("[BudgetMiddleware] Failed to load spend for budget %s: %v\n", budget.ID, err)
+			continue
+		}
+
+		if spent >= budget.SpendLimit {
+			if budget.Action == BudgetActionDowngrade && budget.DowngradeModel != "" {
+				if req.Model != budget.DowngradeModel {
+					req = req.Clone()
+					req.Model = budget.DowngradeModel
+					route.Model = budget.DowngradeModel
+					route.Reason = fmt.Sprintf("%s; downgraded to %s by %s %s budget", route.Reason, budget.DowngradeModel, budget.Scope, budget.Period)
+				}
+				warnings = append(warnings, fmt.Sprintf("%s %s budget exhausted ($%.2f of $%.2f), using %s",
+					budget.Scope, budget.Period, spent, budget.SpendLimit, budget.DowngradeModel))
+				continue
+			}
+			return req, &BudgetError{
+				Code:    ErrCodeBudgetExceeded,
+				Scope:   budget.Scope,
+				ScopeID: budget.ScopeID,
+				Period:  budget.Period,
+				Limit:   budget.SpendLimit,
+				Used:    spent,
+				ResetAt: budget.PeriodEnd(now),
+			}
+		}
+
+		if budget.WarnThreshold > 0 && spent >= budget.WarnThreshold*budget.SpendLimit {
+			warnings = append(warnings, fmt.Sprintf("%s %s budget %.0f%% used ($%.2f of $%.2f)",
+				budget.Scope, budget.Period, 100*spent/budget.SpendLimit, spent, budget.SpendLimit))
+		}
+	}
+
+	// Count the prompt against every token quota that applies
+	for _, budget := range budgets {
+		if budget.TokensPerMinute > 0 {
+			m.addTokens(budget.Scope, budget.ScopeID, promptTokens, now)
+		}
+	}
+
+	// Warnings describe the routed connection only
+	if req.Metadata != nil && !fallback {
+		if len(warnings) > 0 {
+			req.Metadata[MetadataBudgetWarnings] = warnings
+		} else {
+			delete(req.Metadata, MetadataBudgetWarnings)
+		}
+	}
+
+	return req, nil
+}
+
+// Observe counts completion tokens of a finished call against token quotas
+func (m *BudgetMiddleware) Observe(record UsageRecord) {
+	if record.CompletionTokens == 0 {
+		return
+	}
+	now := time.Now()
+	m.addTokens(BudgetScopeUser, record.UserID, record.CompletionTokens, now)
+	if record.APIKeyID != "" {
+		m.addTokens(BudgetScopeAPIKey, record.APIKeyID, record.CompletionTokens, now)
+	}
+	if record.ConnectionID != "" {
+		m.addTokens(BudgetScopeConnection, record.ConnectionID, record.CompletionTokens, now)
+	}
+}
+
+// budgetsFor returns the budgets of a user, API key and connection, caching
+// them briefly
+func (m *BudgetMiddleware) budgetsFor(ctx context.Context, userID, apiKeyID, connectionID string) ([]Budget, error) {
+	key := userID + "|" + apiKeyID + "|" + connectionID
+
+	m.mu.Lock()
+	store := m.store
+	cached, ok := m.budgets[key]
+	m.mu.Unlock()
+
+	if store == nil {
+		return nil, nil
+	}
+	if ok && time.Since(cached.loadedAt) < m.cacheTTL {
+		return cached.budgets, nil
+	}
+
+	budgets, err := store.BudgetsFor(ctx, userID, apiKeyID, connectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.budgets[key] = cachedBudgets{budgets: budgets, loadedAt: time.Now()}
+	m.mu.Unlock()
+	return budgets, nil
+}
+
+// spent returns the spend recorded against a budget in its current period,
+// caching it briefly
+func (m *BudgetMiddleware) spent(ctx context.Context, budget Budget, now time.Time) (float64, error) {
+	since := budget.PeriodStart(now)
+	key := budget.ID + "|" + since.Format(time.RFC3339)
+
+	m.mu.Lock()
+	store := m.store
+	cached, ok := m.spend[key]
+	m.mu.Unlock()
+
+	if ok && time.Since(cached.loadedAt) < m.cacheTTL {
+		return cached.amount, nil
+	}
+
+	amount, err := store.SpendSince(ctx, budget, since)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	m.spend[key] = cachedSpend{amount: amount, loadedAt: time.Now()}
+	m.mu.Unlock()
+	return amount, nil
+}
+
+// tokensUsed returns the tokens counted against a scope in the last minute
+// and when the oldest of them expires
+func (m *BudgetMiddleware) tokensUsed(budget Budget, now time.Time) (int, time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := string(budget.Scope) + ":" + budget.ScopeID
+	uses := pruneTokenUses(m.tokens[key], now)
+	m.tokens[key] = uses
+
+	total := 0
+	for _, use := range uses {
+		total += use.tokens
+	}
+	resetAt := now
+	if len(uses) > 0 {
+		resetAt = uses[0].at.Add(time.Minute)
+	}
+	return total, resetAt
+}
+
+// addTokens counts tokens against a scope
+func (m *BudgetMiddleware) addTokens(scope BudgetScope, scopeID string, tokens int, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := string(scope) + ":" + scopeID
+	uses := append(pruneTokenUses(m.tokens[key], now), tokenUse{at: now, tokens: tokens})
+	m.tokens[key] = uses
+}
+
+// pruneTokenUses drops uses older than a minute
+func pruneTokenUses(uses []tokenUse, now time.Time) []tokenUse {
+	cutoff := now.Add(-time.Minute)
+	i := 0
+	for i < len(uses) && !uses[i].at.After(cutoff) {
+		i++
+	}
+	return uses[i:]
+}
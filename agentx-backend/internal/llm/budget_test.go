@@ -0,0 +1,171 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type staticBudgetStore struct {
+	budgets []Budget
+	spend   map[string]float64
+}
+
+func (s *staticBudgetStore) BudgetsFor(ctx context.Context, userID, apiKeyID, connectionID string) ([]Budget, error) {
+	var result []Budget
+	for _, budget := range s.budgets {
+		switch {
+		case budget.Scope == BudgetScopeUser && budget.ScopeID == userID,
+			budget.Scope == BudgetScopeAPIKey && budget.ScopeID == apiKeyID,
+			budget.Scope == BudgetScopeConnection && budget.ScopeID == connectionID:
+			result = append(result, budget)
+		}
+	}
+	return result, nil
+}
+
+func (s *staticBudgetStore) SpendSince(ctx context.Context, budget Budget, since time.Time) (float64, error) {
+	return s.spend[budget.ID], nil
+}
+
+func budgetRequest() *Request {
+	return &Request{
+		UserID:   "user",
+		Model:    "gpt-4o",
+		Messages: []Message{{Role: "user", Content: "hi"}},
+		Metadata: map[string]interface{}{MetadataAPIKeyID: "key"},
+	}
+}
+
+func newBudgetMiddleware(store BudgetStore) *BudgetMiddleware {
+	m := NewBudgetMiddleware(func() *PricingCatalog { return NewPricingCatalog() })
+	m.SetStore(store)
+	return m
+}
+
+func TestBudgetRejectsExhaustedSpendCap(t *testing.T) {
+	m := newBudgetMiddleware(&staticBudgetStore{
+		budgets: []Budget{{ID: "b1", Scope: BudgetScopeAPIKey, ScopeID: "key", Period: "monthly", SpendLimit: 10, Action: BudgetActionReject}},
+		spend:   map[string]float64{"b1": 10.5},
+	})
+
+	_, err := m.PostRoute(context.Background(), budgetRequest(), &RouteInfo{ConnectionID: "user:conn", Model: "gpt-4o"})
+
+	var budgetErr *BudgetError
+	assert.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, ErrCodeBudgetExceeded, budgetErr.Code)
+	assert.Equal(t, BudgetScopeAPIKey, budgetErr.Scope)
+	assert.Equal(t, 10.0, budgetErr.Limit)
+	assert.True(t, budgetErr.ResetAt.After(time.Now()))
+}
+
+func TestBudgetDowngradesExhaustedSpendCap(t *testing.T) {
+	m := newBudgetMiddleware(&staticBudgetStore{
+		budgets: []Budget{{ID: "b1", Scope: BudgetScopeConnection, ScopeID: "conn", Period: "daily", SpendLimit: 5,
+			Action: BudgetActionDowngrade, DowngradeModel: "gpt-4o-mini"}},
+		spend: map[string]float64{"b1": 6},
+	})
+	req := budgetRequest()
+	route := &RouteInfo{ConnectionID: "user:conn", Model: "gpt-4o"}
+
+	downgraded, err := m.PostRoute(context.Background(), req, route)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "gpt-4o-mini", downgraded.Model)
+	assert.Equal(t, "gpt-4o-mini", route.Model)
+	assert.Equal(t, "gpt-4o", req.Model, "caller's request is not modified")
+	assert.Len(t, budgetWarnings(downgraded), 1)
+}
+
+func TestBudgetWarnsPastThreshold(t *testing.T) {
+	m := newBudgetMiddleware(&staticBudgetStore{
+		budgets: []Budget{{ID: "b1", Scope: BudgetScopeUser, ScopeID: "user", Period: "monthly", SpendLimit: 100, WarnThreshold: 0.8}},
+		spend:   map[string]float64{"b1": 85},
+	})
+
+	req, err := m.PostRoute(context.Background(), budgetRequest(), &RouteInfo{Model: "gpt-4o"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user monthly budget 85% used ($85.00 of $100.00)"}, budgetWarnings(req))
+}
+
+func TestBudgetEnforcesTokensPerMinute(t *testing.T) {
+	m := newBudgetMiddleware(&staticBudgetStore{
+		budgets: []Budget{{ID: "b1", Scope: BudgetScopeUser, ScopeID: "user", TokensPerMinute: 100}},
+	})
+
+	_, err := m.PostRoute(context.Background(), budgetRequest(), &RouteInfo{Model: "gpt-4o"})
+	assert.NoError(t, err)
+
+	m.Observe(UsageRecord{UserID: "user", CompletionTokens: 100})
+	_, err = m.PostRoute(context.Background(), budgetRequest(), &RouteInfo{Model: "gpt-4o"})
+
+	var budgetErr *BudgetError
+	assert.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, ErrCodeTokenQuotaExceeded, budgetErr.Code)
+}
+
+func TestBudgetEnforcesMaxCost(t *testing.T) {
+	m := newBudgetMiddleware(nil)
+	req := budgetRequest()
+	maxTokens := 1000000
+	req.MaxTokens = &maxTokens
+	req.Requirements.MaxCost = 1
+
+	_, err := m.PostRoute(context.Background(), req, &RouteInfo{Model: "gpt-4o"})
+
+	var budgetErr *BudgetError
+	assert.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, ErrCodeMaxCostExceeded, budgetErr.Code)
+}
+
+func TestCompleteReturnsBudgetError(t *testing.T) {
+	g := newTestGateway(t, fakeFactory{"primary": &fakeProvider{chunks: []string{"hi"}}})
+	g.middleware = append(g.middleware, g.budgets)
+	g.SetBudgetStore(&staticBudgetStore{
+		budgets: []Budget{{ID: "b1", Scope: BudgetScopeUser, ScopeID: "user", Period: "monthly", SpendLimit: 1}},
+		spend:   map[string]float64{"b1": 2},
+	})
+
+	_, err := g.Complete(context.Background(), &Request{
+		UserID:   "user",
+		Messages: []Message{{Role: "user", Content: "hi"}},
+		Metadata: map[string]interface{}{},
+	})
+
+	var budgetErr *BudgetError
+	assert.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, ErrCodeBudgetExceeded, budgetErr.Code)
+}
+
+func TestFallbackChecksConnectionBudget(t *testing.T) {
+	capped := &fakeProvider{}
+	open := &fakeProvider{}
+	g := newTestGateway(t, fakeFactory{
+		"primary": &fakeProvider{failErr: errors.New("boom")},
+		"capped":  capped,
+		"open":    open,
+	})
+	g.middleware = append(g.middleware, g.budgets)
+	g.SetBudgetStore(&staticBudgetStore{
+		budgets: []Budget{{ID: "b1", Scope: BudgetScopeConnection, ScopeID: "capped", Period: "monthly", SpendLimit: 1}},
+		spend:   map[string]float64{"b1": 2},
+	})
+	g.router.SetRoutingStore(&staticRoutingStore{config: &RoutingConfig{
+		Chains: []FallbackChain{{PrimaryConnectionID: "primary", Steps: []FallbackStep{{ConnectionID: "capped"}, {ConnectionID: "open"}}}},
+	}})
+
+	resp, err := g.Complete(context.Background(), &Request{
+		UserID:       "user",
+		ConnectionID: "primary",
+		Messages:     []Message{{Role: "user", Content: "hi"}},
+		Metadata:     map[string]interface{}{},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "open", resp.Metadata.ConnectionID)
+	assert.Nil(t, capped.lastReq, "the capped connection is never called")
+	assert.NotNil(t, open.lastReq)
+}
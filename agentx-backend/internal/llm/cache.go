@@ -0,0 +1,416 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache statuses reported in response metadata
+const (
+	CacheMiss        = "miss"
+	CacheHit         = "hit"
+	CacheSemanticHit = "semantic_hit"
+	CacheCoalesced   = "coalesced"
+	CacheBypass      = "bypass"
+)
+
+// MetadataNoCache in request metadata skips the response cache
+const MetadataNoCache = "no_cache"
+
+const (
+	// DefaultCacheTTL applies to connections that opt in to semantic caching
+	// without a cache_ttl setting
+	DefaultCacheTTL = 5 * time.Minute
+	// CoalescedCallTimeout bounds a provider call shared by identical
+	// concurrent requests, which no single caller's context may cancel
+	CoalescedCallTimeout = 5 * time.Minute
+	// DefaultSemanticCacheThreshold is the cosine similarity a cached prompt
+	// needs to answer a new one when semantic caching is enabled
+	DefaultSemanticCacheThreshold = 0.95
+
+	defaultCacheEntries = 10000
+)
+
+// CacheEntry is a response stored in the cache. Key identifies the exact
+// request; Scope identifies everything but the final prompt, so semantic
+// matches only reuse answers given in the same conversation and settings.
+// Both embed the user ID, so entries are never shared across users.
+type CacheEntry struct {
+	Key       string
+	Scope     string
+	UserID    string
+	Embedding []float32
+	Response  *Response
+	ExpiresAt time.Time
+}
+
+// CacheStore persists cached responses
+type CacheStore interface {
+	// Get returns the unexpired entry for a key, or nil
+	Get(ctx context.Context, key string) (*CacheEntry, error)
+	// Put stores an entry, replacing any entry with the same key
+	Put(ctx context.Context, entry *CacheEntry) error
+	// Nearest returns the user's unexpired entry in scope whose embedding is
+	// most similar to the given one, with its cosine similarity
+	Nearest(ctx context.Context, userID, scope string, embedding []float32) (*CacheEntry, float64, error)
+}
+
+// Embedder turns prompts into vectors for semantic cache matching
+type Embedder interface {
+	Embed(ctx context.Context, userID, text string) ([]float32, error)
+}
+
+// CacheResult reports how the cache handled a request
+type CacheResult struct {
+	Status     string
+	Similarity float64
+}
+
+// Served reports whether the response came from the cache or another
+// caller's provider call rather than a call of its own
+func (r CacheResult) Served() bool {
+	return r.Status == CacheHit || r.Status == CacheSemanticHit || r.Status == CacheCoalesced
+}
+
+// ResponseCache answers repeated completions from a cache and coalesces
+// identical concurrent requests into a single provider call. Caching is
+// opt-in per connection, since repeated prompts are not always meant to get
+// the same answer; semantic matching is a further opt-in.
+type ResponseCache struct {
+	mu       sync.RWMutex
+	store    CacheStore
+	embedder Embedder
+	flights  singleflight.Group
+}
+
+// NewResponseCache creates a response cache backed by memory, with a local
+// hashing embedder for semantic matching
+func NewResponseCache() *ResponseCache {
+	return &ResponseCache{
+		store:    NewMemoryCacheStore(defaultCacheEntries),
+		embedder: NewHashingEmbedder(512),
+	}
+}
+
+// SetStore replaces where cached responses are kept
+func (c *ResponseCache) SetStore(store CacheStore) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store = store
+}
+
+// SetEmbedder replaces how prompts are embedded for semantic matching
+func (c *ResponseCache) SetEmbedder(embedder Embedder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.embedder = embedder
+}
+
+// Do returns a cached response for the request when one matches, otherwise
+// runs call once for all identical concurrent requests and caches a
+// successful result for the connection's TTL. The shared call runs on a
+// context of its own, so a caller that gives up only stops waiting for it.
+func (c *ResponseCache) Do(ctx context.Context, req *Request, config ProviderConfig, connectionID string, call func(context.Context) (*Response, error)) (*Response, CacheResult, error) {
+	ttl := config.CacheTTL
+	if ttl == 0 && config.SemanticCache {
+		ttl = DefaultCacheTTL
+	}
+	if ttl <= 0 || req.Stream {
+		resp, err := call(ctx)
+		return resp, CacheResult{}, err
+	}
+	if noCache, _ := req.Metadata[MetadataNoCache].(bool); noCache {
+		resp, err := call(ctx)
+		return resp, CacheResult{Status: CacheBypass}, err
+	}
+
+	c.mu.RLock()
+	store, embedder := c.store, c.embedder
+	c.mu.RUnlock()
+
+	key, scope, prompt := cacheKeys(req, connectionID)
+	if entry, err := store.Get(ctx, key); err != nil {
+		fmt.Printf("[ResponseCache] Warning: lookup failed: %v\n", err)
+	} else if entry != nil {
+		return cloneResponse(entry.Response), CacheResult{Status: CacheHit, Similarity: 1}, nil
+	}
+
+	var embedding []float32
+	if config.SemanticCache && embedder != nil && prompt != "" {
+		var err error
+		embedding, err = embedder.Embed(ctx, req.UserID, prompt)
+		if err != nil {
+			fmt.Printf("[ResponseCache] Warning: embedding failed: %v\n", err)
+		} else {
+			threshold := config.SemanticCacheThreshold
+			if threshold <= 0 {
+				threshold = DefaultSemanticCacheThreshold
+			}
+			entry, similarity, err := store.Nearest(ctx, req.UserID, scope, embedding)
+			if err != nil {
+				fmt.Printf("[ResponseCache] Warning: semantic lookup failed: %v\n", err)
+			} else if entry != nil && similarity >= threshold {
+				return cloneResponse(entry.Response), CacheResult{Status: CacheSemanticHit, Similarity: similarity}, nil
+			}
+		}
+	}
+
+	// The shared response is never modified; every caller gets its own copy.
+	// Only the leader's function runs, so leader is set for it alone and is
+	// read after the result is received.
+	leader := false
+	results := c.flights.DoChan(key, func() (interface{}, error) {
+		leader = true
+		callCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), CoalescedCallTimeout)
+		defer cancel()
+
+		resp, err := call(callCtx)
+		if err != nil || resp == nil {
+			return resp, err
+		}
+		resp = cloneResponse(resp)
+		entry := &CacheEntry{
+			Key:       key,
+			Scope:     scope,
+			UserID:    req.UserID,
+			Embedding: embedding,
+			Response:  resp,
+			ExpiresAt: time.Now().Add(ttl),
+		}
+		if err := store.Put(callCtx, entry); err != nil {
+			fmt.Printf("[ResponseCache] Warning: failed to store response: %v\n", err)
+		}
+		return resp, nil
+	})
+
+	select {
+	case result := <-results:
+		resp, _ := result.Val.(*Response)
+		status := CacheCoalesced
+		if leader {
+			status = CacheMiss
+		}
+		return cloneResponse(resp), CacheResult{Status: status}, result.Err
+	case <-ctx.Done():
+		return nil, CacheResult{}, ctx.Err()
+	}
+}
+
+// cacheKey holds the parts of a request that determine its answer
+type cacheKey struct {
+	UserID           string            `json:"user_id"`
+	ConnectionID     string            `json:"connection_id"`
+	Model            string            `json:"model"`
+	Messages         []Message         `json:"messages"`
+	Temperature      *float32          `json:"temperature,omitempty"`
+	MaxTokens        *int              `json:"max_tokens,omitempty"`
+	TopP             *float32          `json:"top_p,omitempty"`
+	FrequencyPenalty *float32          `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float32          `json:"presence_penalty,omitempty"`
+	Stop             []string          `json:"stop,omitempty"`
+	N                *int              `json:"n,omitempty"`
+	Tools            []Tool            `json:"tools,omitempty"`
+	ToolChoice       interface{}       `json:"tool_choice,omitempty"`
+	ResponseFormat   *ResponseFormat   `json:"response_format,omitempty"`
+	Reasoning        *ReasoningOptions `json:"reasoning,omitempty"`
+}
+
+// cacheKeys returns the exact key and semantic scope of a request and the
+// final user prompt that semantic matching compares
+func cacheKeys(req *Request, connectionID string) (key, scope, prompt string) {
+	k := cacheKey{
+		UserID:           req.UserID,
+		ConnectionID:     connectionID,
+		Model:            req.Model,
+		Messages:         req.Messages,
+		Temperature:      req.Temperature,
+		MaxTokens:        req.MaxTokens,
+		TopP:             req.TopP,
+		FrequencyPenalty: req.FrequencyPenalty,
+		PresencePenalty:  req.PresencePenalty,
+		Stop:             req.Stop,
+		N:                req.N,
+		Tools:            req.Tools,
+		ToolChoice:       req.ToolChoice,
+		ResponseFormat:   req.ResponseFormat,
+		Reasoning:        req.Reasoning,
+	}
+	key = hashKey(k)
+
+	// Prompts with images never match semantically; the text alone does
+	// not determine the answer
+	if last := len(req.Messages) - 1; last >= 0 && req.Messages[last].Role == "user" && len(req.Messages[last].Images) == 0 {
+		prompt = req.Messages[last].Content
+		k.Messages = req.Messages[:last]
+	}
+	scope = hashKey(k)
+	return key, scope, prompt
+}
+
+func hashKey(k cacheKey) string {
+	data, _ := json.Marshal(k)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// cloneResponse copies a response so cached entries are never modified by
+// the callers they are served to
+func cloneResponse(resp *Response) *Response {
+	if resp == nil {
+		return nil
+	}
+	clone := *resp
+	clone.Choices = append([]Choice(nil), resp.Choices...)
+	clone.Metadata.BudgetWarnings = nil
+	clone.Metadata.Extra = nil
+	return &clone
+}
+
+// MemoryCacheStore keeps cached responses in process memory, evicting the
+// entries closest to expiry once full
+type MemoryCacheStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*CacheEntry
+}
+
+// NewMemoryCacheStore creates an in-memory store holding up to maxEntries
+func NewMemoryCacheStore(maxEntries int) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*CacheEntry),
+	}
+}
+
+// Get implements CacheStore
+func (s *MemoryCacheStore) Get(ctx context.Context, key string) (*CacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(entry.ExpiresAt) {
+		delete(s.entries, key)
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// Put implements CacheStore
+func (s *MemoryCacheStore) Put(ctx context.Context, entry *CacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[entry.Key] = entry
+	if s.maxEntries > 0 && len(s.entries) > s.maxEntries {
+		s.evict()
+	}
+	return nil
+}
+
+// Nearest implements CacheStore
+func (s *MemoryCacheStore) Nearest(ctx context.Context, userID, scope string, embedding []float32) (*CacheEntry, float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var best *CacheEntry
+	bestSimilarity := -1.0
+	for _, entry := range s.entries {
+		if entry.UserID != userID || entry.Scope != scope || len(entry.Embedding) == 0 || now.After(entry.ExpiresAt) {
+			continue
+		}
+		if similarity := CosineSimilarity(embedding, entry.Embedding); similarity > bestSimilarity {
+			best, bestSimilarity = entry, similarity
+		}
+	}
+	return best, bestSimilarity, nil
+}
+
+// evict drops expired entries, then the earliest-expiring tenth of the store
+func (s *MemoryCacheStore) evict() {
+	now := time.Now()
+	remaining := make([]*CacheEntry, 0, len(s.entries))
+	for key, entry := range s.entries {
+		if now.After(entry.ExpiresAt) {
+			delete(s.entries, key)
+			continue
+		}
+		remaining = append(remaining, entry)
+	}
+	if len(s.entries) <= s.maxEntries {
+		return
+	}
+
+	sort.Slice(remaining, func(i, j int) bool {
+		return remaining[i].ExpiresAt.Before(remaining[j].ExpiresAt)
+	})
+	for _, entry := range remaining[:len(remaining)-s.maxEntries*9/10] {
+		delete(s.entries, entry.Key)
+	}
+}
+
+// HashingEmbedder embeds text locally by hashing its words and word pairs
+// into a fixed number of dimensions. It catches rephrasings that share most
+// of their words without calling an embedding model.
+type HashingEmbedder struct {
+	dimensions int
+}
+
+// NewHashingEmbedder creates a hashing embedder with the given dimensions
+func NewHashingEmbedder(dimensions int) *HashingEmbedder {
+	return &HashingEmbedder{dimensions: dimensions}
+}
+
+// Embed implements Embedder
+func (e *HashingEmbedder) Embed(ctx context.Context, userID, text string) ([]float32, error) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	vector := make([]float32, e.dimensions)
+	add := func(feature string, weight float32) {
+		h := fnv.New32a()
+		h.Write([]byte(feature))
+		vector[h.Sum32()%uint32(e.dimensions)] += weight
+	}
+	for i, word := range words {
+		add(word, 1)
+		if i > 0 {
+			add(words[i-1]+" "+word, 0.5)
+		}
+	}
+	return vector, nil
+}
+
+// CosineSimilarity returns the cosine similarity of two vectors, or 0 when
+// their lengths differ or either is zero
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,187 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingProvider answers every call, optionally waiting for release first
+type countingProvider struct {
+	fakeProvider
+	calls   int32
+	release chan struct{}
+}
+
+func (p *countingProvider) Complete(ctx context.Context, req *Request) (*Response, error) {
+	atomic.AddInt32(&p.calls, 1)
+	if p.release != nil {
+		<-p.release
+	}
+	return &Response{
+		Choices: []Choice{{Message: Message{Role: "assistant", Content: "answer to " + req.Messages[len(req.Messages)-1].Content}}},
+		Usage:   Usage{PromptTokens: 10, CompletionTokens: 10, TotalTokens: 20},
+	}, nil
+}
+
+func newCacheGateway(t *testing.T, provider *countingProvider, settings map[string]interface{}) *Gateway {
+	g := NewGateway(WithMiddleware(&BaseMiddleware{}))
+	g.providers.factory = fakeFactory{"cached": provider}
+	config := ProviderConfig{Name: "cached", Type: "openai"}
+	config.ApplyConnectionSettings(settings)
+	for _, user := range []string{"alice", "bob"} {
+		assert.NoError(t, g.RegisterProvider(user, "cached", config))
+	}
+	return g
+}
+
+// cacheSettings opts a connection in to caching
+var cacheSettings = map[string]interface{}{"cache_ttl": "5m"}
+
+func cacheRequest(userID, prompt string) *Request {
+	return &Request{
+		UserID:   userID,
+		Messages: []Message{{Role: "user", Content: prompt}},
+		Metadata: map[string]interface{}{},
+	}
+}
+
+func TestCacheServesRepeatedPrompt(t *testing.T) {
+	provider := &countingProvider{}
+	g := newCacheGateway(t, provider, cacheSettings)
+
+	first, err := g.Complete(context.Background(), cacheRequest("alice", "what is go?"))
+	assert.NoError(t, err)
+	assert.Equal(t, CacheMiss, first.Metadata.Cache)
+
+	second, err := g.Complete(context.Background(), cacheRequest("alice", "what is go?"))
+	assert.NoError(t, err)
+	assert.Equal(t, CacheHit, second.Metadata.Cache)
+	assert.Equal(t, first.Content, second.Content)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&provider.calls))
+}
+
+func TestCacheIsolatesUsers(t *testing.T) {
+	provider := &countingProvider{}
+	g := newCacheGateway(t, provider, map[string]interface{}{"semantic_cache": true})
+
+	_, err := g.Complete(context.Background(), cacheRequest("alice", "what is go?"))
+	assert.NoError(t, err)
+	resp, err := g.Complete(context.Background(), cacheRequest("bob", "what is go?"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, CacheMiss, resp.Metadata.Cache)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&provider.calls))
+}
+
+func TestCacheRespectsConnectionSettings(t *testing.T) {
+	// Caching is off unless the connection opts in
+	for _, settings := range []map[string]interface{}{nil, {"cache_ttl": float64(0)}} {
+		provider := &countingProvider{}
+		g := newCacheGateway(t, provider, settings)
+		for i := 0; i < 2; i++ {
+			resp, err := g.Complete(context.Background(), cacheRequest("alice", "what is go?"))
+			assert.NoError(t, err)
+			assert.Empty(t, resp.Metadata.Cache)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&provider.calls))
+	}
+
+	req := cacheRequest("alice", "what is go?")
+	req.Metadata[MetadataNoCache] = true
+	resp, err := newCacheGateway(t, &countingProvider{}, cacheSettings).Complete(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, CacheBypass, resp.Metadata.Cache)
+}
+
+func TestSemanticCacheMatchesSimilarPrompts(t *testing.T) {
+	provider := &countingProvider{}
+	g := newCacheGateway(t, provider, map[string]interface{}{
+		"semantic_cache":           true,
+		"semantic_cache_threshold": 0.9,
+	})
+
+	_, err := g.Complete(context.Background(), cacheRequest("alice", "What is the capital of France?"))
+	assert.NoError(t, err)
+
+	similar, err := g.Complete(context.Background(), cacheRequest("alice", "what is the capital of france"))
+	assert.NoError(t, err)
+	assert.Equal(t, CacheSemanticHit, similar.Metadata.Cache)
+	assert.GreaterOrEqual(t, similar.Metadata.CacheSimilarity, 0.9)
+
+	different, err := g.Complete(context.Background(), cacheRequest("alice", "How do I bake sourdough bread?"))
+	assert.NoError(t, err)
+	assert.Equal(t, CacheMiss, different.Metadata.Cache)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&provider.calls))
+}
+
+func TestCacheCoalescesConcurrentRequests(t *testing.T) {
+	provider := &countingProvider{release: make(chan struct{})}
+	g := newCacheGateway(t, provider, cacheSettings)
+
+	statuses := make(chan string, 3)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := g.Complete(context.Background(), cacheRequest("alice", "what is go?"))
+			if assert.NoError(t, err) {
+				statuses <- resp.Metadata.Cache
+			}
+		}()
+	}
+
+	// Let the followers join the leader's call before it returns
+	time.Sleep(50 * time.Millisecond)
+	close(provider.release)
+	wg.Wait()
+	close(statuses)
+
+	counts := make(map[string]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&provider.calls))
+	assert.Equal(t, 1, counts[CacheMiss])
+	assert.Equal(t, 2, counts[CacheCoalesced])
+}
+
+func TestCacheCallerLeavesSharedCallRunning(t *testing.T) {
+	provider := &countingProvider{release: make(chan struct{})}
+	g := newCacheGateway(t, provider, cacheSettings)
+
+	// The leader gives up while its call is in flight
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := g.Complete(ctx, cacheRequest("alice", "what is go?"))
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("cancelled caller kept waiting for the shared call")
+	}
+
+	// The call it started still finishes and answers the next caller
+	followers := make(chan *Response, 1)
+	go func() {
+		resp, err := g.Complete(context.Background(), cacheRequest("alice", "what is go?"))
+		assert.NoError(t, err)
+		followers <- resp
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(provider.release)
+	resp := <-followers
+	assert.Equal(t, "answer to what is go?", resp.Content)
+	assert.Equal(t, CacheCoalesced, resp.Metadata.Cache)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&provider.calls))
+}
@@ -1,28 +1,117 @@
 package llm
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/agentx/agentx-backend/internal/metrics"
 )
 
+// ErrCircuitOpen is returned when a request is rejected by an open breaker
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// maxBreakerEvents bounds the transition history kept for operators
+const maxBreakerEvents = 200
+
 // CircuitBreaker implements the circuit breaker pattern
 type CircuitBreaker struct {
 	breakers map[string]*Breaker
+	settings func(key string) BreakerSettings
 	mu       sync.RWMutex
+
+	// State transitions, delivered to listeners and kept for inspection
+	listeners []func(BreakerEvent)
+	events    []BreakerEvent
+	eventMu   sync.RWMutex
+}
+
+// BreakerSettings tunes when a breaker opens and how it recovers
+type BreakerSettings struct {
+	FailureRatio   float64       `json:"failure_ratio,omitempty"`    // Share of failed calls in the window that opens the breaker
+	Window         time.Duration `json:"window,omitempty"`           // Rolling window the ratio is computed over
+	MinRequests    int           `json:"min_requests,omitempty"`     // Calls needed in the window before the ratio counts
+	OpenDuration   time.Duration `json:"open_duration,omitempty"`    // Time spent open before probing
+	HalfOpenProbes int           `json:"half_open_probes,omitempty"` // Concurrent trial calls while half-open; that many successes close the breaker
+}
+
+// DefaultBreakerSettings opens a breaker when half of at least five calls in
+// the last minute failed, and probes it again after 30 seconds
+func DefaultBreakerSettings() BreakerSettings {
+	return BreakerSettings{
+		FailureRatio:   0.5,
+		Window:         time.Minute,
+		MinRequests:    5,
+		OpenDuration:   30 * time.Second,
+		HalfOpenProbes: 2,
+	}
+}
+
+// Merge returns the settings with every field set in override replaced
+func (s BreakerSettings) Merge(override BreakerSettings) BreakerSettings {
+	if override.FailureRatio > 0 {
+		s.FailureRatio = override.FailureRatio
+	}
+	if override.Window > 0 {
+		s.Window = override.Window
+	}
+	if override.MinRequests > 0 {
+		s.MinRequests = override.MinRequests
+	}
+	if override.OpenDuration > 0 {
+		s.OpenDuration = override.OpenDuration
+	}
+	if override.HalfOpenProbes > 0 {
+		s.HalfOpenProbes = override.HalfOpenProbes
+	}
+	return s
+}
+
+// BreakerEvent reports a breaker changing state
+type BreakerEvent struct {
+	Key          string       `json:"key"`
+	Connection   string       `json:"connection"`
+	Model        string       `json:"model"`
+	From         BreakerState `json:"-"`
+	To           BreakerState `json:"-"`
+	FromState    string       `json:"from"`
+	ToState      string       `json:"to"`
+	Reason       string       `json:"reason"`
+	FailureRatio float64      `json:"failure_ratio"`
+	Requests     int          `json:"requests"`
+	At           time.Time    `json:"at"`
+}
+
+// BreakerSnapshot is the inspectable state of one breaker
+type BreakerSnapshot struct {
+	Key          string          `json:"key"`
+	Connection   string          `json:"connection"`
+	Model        string          `json:"model"`
+	State        string          `json:"state"`
+	FailureRatio float64         `json:"failure_ratio"`
+	Requests     int             `json:"requests"`
+	Failures     int             `json:"failures"`
+	OpenedAt     *time.Time      `json:"opened_at,omitempty"`
+	ProbeAt      *time.Time      `json:"probe_at,omitempty"` // When an open breaker lets probes through
+	Settings     BreakerSettings `json:"settings"`
 }
 
 // Breaker represents a single circuit breaker
 type Breaker struct {
-	failureThreshold uint32
-	successThreshold uint32
-	timeout          time.Duration
-	
-	failures    uint32
-	successes   uint32
-	lastFailure time.Time
-	state       BreakerState
-	mu          sync.RWMutex
+	key      string
+	settings BreakerSettings
+	notify   func(BreakerEvent)
+
+	window    rollingWindow
+	state     BreakerState
+	openedAt  time.Time
+	probes    int // Trial calls in flight while half-open
+	successes int // Successful trial calls since going half-open
+	mu        sync.Mutex
 }
 
 // BreakerState represents the circuit breaker state
@@ -34,150 +123,374 @@ const (
 	StateHalfOpen
 )
 
+// String returns the state name
+func (s BreakerState) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
 // NewCircuitBreaker creates a new circuit breaker
 func NewCircuitBreaker() *CircuitBreaker {
-	return &CircuitBreaker{
+	cb := &CircuitBreaker{
 		breakers: make(map[string]*Breaker),
 	}
+	cb.OnTransition(func(e BreakerEvent) {
+		fmt.Printf("[CircuitBreaker] %s: %s -> %s (%s)\n", e.Key, e.FromState, e.ToState, e.Reason)
+	})
+	return cb
+}
+
+// SetSettingsResolver sets how settings are looked up for a breaker key.
+// Existing breakers pick up new settings when they are reset or reloaded.
+func (cb *CircuitBreaker) SetSettingsResolver(resolve func(key string) BreakerSettings) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.settings = resolve
+}
+
+// OnTransition registers a listener for breaker state changes. Listeners are
+// called synchronously while the breaker is locked, so they must not block or
+// call back into the circuit breaker.
+func (cb *CircuitBreaker) OnTransition(listener func(BreakerEvent)) {
+	cb.eventMu.Lock()
+	defer cb.eventMu.Unlock()
+	cb.listeners = append(cb.listeners, listener)
+}
+
+// Events returns the most recent state transitions, newest first
+func (cb *CircuitBreaker) Events() []BreakerEvent {
+	cb.eventMu.RLock()
+	defer cb.eventMu.RUnlock()
+
+	events := make([]BreakerEvent, len(cb.events))
+	for i, e := range cb.events {
+		events[len(cb.events)-1-i] = e
+	}
+	return events
+}
+
+// emit records a transition and hands it to the listeners
+func (cb *CircuitBreaker) emit(event BreakerEvent) {
+	cb.eventMu.Lock()
+	cb.events = append(cb.events, event)
+	if len(cb.events) > maxBreakerEvents {
+		cb.events = cb.events[len(cb.events)-maxBreakerEvents:]
+	}
+	listeners := append([]func(BreakerEvent){}, cb.listeners...)
+	cb.eventMu.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
 }
 
 // Execute executes a function with circuit breaker protection
 func (cb *CircuitBreaker) Execute(key string, fn func() error) error {
 	breaker := cb.getOrCreateBreaker(key)
-	
-	// Check breaker state
-	state := breaker.getState()
-	if state == StateOpen {
-		return fmt.Errorf("circuit breaker is open for %s", key)
+
+	// Reject calls while open, and beyond the probe limit while half-open
+	probe, ok := breaker.allow()
+	if !ok {
+		return fmt.Errorf("%w for %s", ErrCircuitOpen, key)
 	}
-	
+
 	// Execute function
 	err := fn()
-	
+
 	// Update breaker state based on result
-	if err != nil {
-		breaker.recordFailure()
-	} else {
-		breaker.recordSuccess()
-	}
-	
+	breaker.record(err, probe)
+
 	return err
 }
 
+// Allow admits a call made outside Execute, such as a stream that outlives
+// the function opening it, and returns the function that records its
+// outcome. The outcome must be recorded exactly once; context.Canceled only
+// frees a half-open probe slot.
+func (cb *CircuitBreaker) Allow(key string) (func(error), error) {
+	breaker := cb.getOrCreateBreaker(key)
+
+	probe, ok := breaker.allow()
+	if !ok {
+		return nil, fmt.Errorf("%w for %s", ErrCircuitOpen, key)
+	}
+	return func(err error) {
+		breaker.record(err, probe)
+	}, nil
+}
+
 // getOrCreateBreaker gets or creates a breaker for a key
 func (cb *CircuitBreaker) getOrCreateBreaker(key string) *Breaker {
 	cb.mu.RLock()
 	breaker, exists := cb.breakers[key]
 	cb.mu.RUnlock()
-	
+
 	if exists {
 		return breaker
 	}
-	
+
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
-	
+
 	// Double-check after acquiring write lock
 	if breaker, exists := cb.breakers[key]; exists {
 		return breaker
 	}
-	
-	// Create new breaker with default settings
+
 	breaker = &Breaker{
-		failureThreshold: 5,
-		successThreshold: 2,
-		timeout:          30 * time.Second,
-		state:            StateClosed,
+		key:      key,
+		settings: cb.settingsFor(key),
+		notify:   cb.emit,
+		state:    StateClosed,
 	}
-	
+	breaker.window.reset(breaker.settings.Window)
+
 	cb.breakers[key] = breaker
 	return breaker
 }
 
-// getState returns the current state of the breaker
-func (b *Breaker) getState() BreakerState {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-	
-	// Check if we should transition from Open to HalfOpen
-	if b.state == StateOpen {
-		if time.Since(b.lastFailure) > b.timeout {
-			b.mu.RUnlock()
-			b.mu.Lock()
-			b.state = StateHalfOpen
-			b.failures = 0
-			b.successes = 0
-			b.mu.Unlock()
-			b.mu.RLock()
-		}
+// settingsFor resolves the settings of a breaker key. The caller must hold
+// the lock.
+func (cb *CircuitBreaker) settingsFor(key string) BreakerSettings {
+	if cb.settings == nil {
+		return DefaultBreakerSettings()
 	}
-	
-	return b.state
+	return DefaultBreakerSettings().Merge(cb.settings(key))
 }
 
-// recordFailure records a failure
-func (b *Breaker) recordFailure() {
+// allow reports whether a call may proceed, and whether it is a half-open
+// probe
+func (b *Breaker) allow() (probe bool, ok bool) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	
-	b.failures++
-	b.lastFailure = time.Now()
-	
+
+	b.refresh()
 	switch b.state {
-	case StateClosed:
-		if b.failures >= b.failureThreshold {
-			b.state = StateOpen
-			fmt.Printf("[CircuitBreaker] Opening circuit breaker after %d failures\n", b.failures)
-		}
+	case StateOpen:
+		return false, false
 	case StateHalfOpen:
-		b.state = StateOpen
-		fmt.Printf("[CircuitBreaker] Re-opening circuit breaker after failure in half-open state\n")
+		if b.probes >= b.settings.HalfOpenProbes {
+			return false, false
+		}
+		b.probes++
+		return true, true
+	default:
+		return false, true
 	}
 }
 
-// recordSuccess records a success
-func (b *Breaker) recordSuccess() {
+// record updates the breaker with the outcome of a call. Calls cancelled by
+// the caller, such as the losing attempt of a hedged request, say nothing
+// about the provider's health.
+func (b *Breaker) record(err error, probe bool) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	
-	b.successes++
-	
+
+	if probe && b.probes > 0 {
+		b.probes--
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	now := time.Now()
+	b.window.add(now, err != nil)
+
 	switch b.state {
 	case StateClosed:
-		b.failures = 0 // Reset failure count on success
+		requests, failures := b.window.counts(now)
+		if err != nil && requests >= b.settings.MinRequests && failureRatio(requests, failures) >= b.settings.FailureRatio {
+			b.setState(StateOpen, fmt.Sprintf("%d of %d calls failed within %s", failures, requests, b.settings.Window))
+		}
 	case StateHalfOpen:
-		if b.successes >= b.successThreshold {
-			b.state = StateClosed
-			b.failures = 0
-			b.successes = 0
-			fmt.Printf("[CircuitBreaker] Closing circuit breaker after %d successes\n", b.successes)
+		if err != nil {
+			b.setState(StateOpen, fmt.Sprintf("probe failed: %v", err))
+			return
+		}
+		b.successes++
+		if b.successes >= b.settings.HalfOpenProbes {
+			b.setState(StateClosed, fmt.Sprintf("%d probes succeeded", b.successes))
 		}
 	}
 }
 
-// GetState returns the state of a specific breaker
+// refresh moves an open breaker to half-open once its open duration has
+// passed. The caller must hold the lock.
+func (b *Breaker) refresh() {
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.settings.OpenDuration {
+		b.setState(StateHalfOpen, fmt.Sprintf("open for %s", b.settings.OpenDuration))
+	}
+}
+
+// getState returns the current state of the breaker
+func (b *Breaker) getState() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh()
+	return b.state
+}
+
+// setState moves the breaker to a new state, exports the transition and
+// emits it as an event. The caller must hold the lock.
+func (b *Breaker) setState(state BreakerState, reason string) {
+	if b.state == state {
+		return
+	}
+	now := time.Now()
+	requests, failures := b.window.counts(now)
+	connection, model := breakerLabels(b.key)
+	metrics.CircuitBreakerTransitions.WithLabelValues(connection, model, b.state.String(), state.String()).Inc()
+	metrics.CircuitBreakerState.WithLabelValues(connection, model).Set(float64(state))
+
+	event := BreakerEvent{
+		Key:          b.key,
+		Connection:   connection,
+		Model:        model,
+		From:         b.state,
+		To:           state,
+		FromState:    b.state.String(),
+		ToState:      state.String(),
+		Reason:       reason,
+		FailureRatio: failureRatio(requests, failures),
+		Requests:     requests,
+		At:           now,
+	}
+
+	b.state = state
+	b.probes = 0
+	b.successes = 0
+	switch state {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		b.window.reset(b.settings.Window)
+	}
+
+	if b.notify != nil {
+		b.notify(event)
+	}
+}
+
+// snapshot returns the inspectable state of the breaker
+func (b *Breaker) snapshot() BreakerSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refresh()
+	requests, failures := b.window.counts(time.Now())
+	connection, model := breakerLabels(b.key)
+	snap := BreakerSnapshot{
+		Key:          b.key,
+		Connection:   connection,
+		Model:        model,
+		State:        b.state.String(),
+		FailureRatio: failureRatio(requests, failures),
+		Requests:     requests,
+		Failures:     failures,
+		Settings:     b.settings,
+	}
+	if b.state != StateClosed {
+		openedAt := b.openedAt
+		probeAt := b.openedAt.Add(b.settings.OpenDuration)
+		snap.OpenedAt = &openedAt
+		snap.ProbeAt = &probeAt
+	}
+	return snap
+}
+
+// breakerKey identifies the breaker of a model on a user's connection. The
+// connection ID may be either bare or already prefixed with the user ID.
+func breakerKey(userID, connectionID, model string) string {
+	if !strings.HasPrefix(connectionID, userID+":") {
+		connectionID = userID + ":" + connectionID
+	}
+	return connectionID + ":" + model
+}
+
+// breakerLabels splits a userID:connectionID:model breaker key into the
+// connection and model metric labels
+func breakerLabels(key string) (connection, model string) {
+	parts := strings.SplitN(key, ":", 3)
+	if len(parts) < 3 {
+		return key, ""
+	}
+	return parts[1], parts[2]
+}
+
+// failureRatio returns the share of failed calls
+func failureRatio(requests, failures int) float64 {
+	if requests == 0 {
+		return 0
+	}
+	return float64(failures) / float64(requests)
+}
+
+// GetState returns the state of a breaker
 func (cb *CircuitBreaker) GetState(key string) BreakerState {
 	cb.mu.RLock()
 	breaker, exists := cb.breakers[key]
 	cb.mu.RUnlock()
-	
+
 	if !exists {
 		return StateClosed
 	}
-	
+
 	return breaker.getState()
 }
 
-// Reset resets a specific breaker
-func (cb *CircuitBreaker) Reset(key string) {
+// Snapshot returns the state of every breaker, sorted by key
+func (cb *CircuitBreaker) Snapshot() []BreakerSnapshot {
+	cb.mu.RLock()
+	breakers := make([]*Breaker, 0, len(cb.breakers))
+	for _, breaker := range cb.breakers {
+		breakers = append(breakers, breaker)
+	}
+	cb.mu.RUnlock()
+
+	snapshots := make([]BreakerSnapshot, 0, len(breakers))
+	for _, breaker := range breakers {
+		snapshots = append(snapshots, breaker.snapshot())
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Key < snapshots[j].Key })
+	return snapshots
+}
+
+// Reset closes a specific breaker, clears its history and reloads its
+// settings. It reports whether the breaker exists.
+func (cb *CircuitBreaker) Reset(key string) bool {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
-	
-	if breaker, exists := cb.breakers[key]; exists {
+
+	breaker, exists := cb.breakers[key]
+	if exists {
+		cb.resetBreaker(breaker)
+	}
+	return exists
+}
+
+// ReloadSettings re-resolves the settings of every breaker whose key starts
+// with prefix, keeping their state
+func (cb *CircuitBreaker) ReloadSettings(prefix string) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	for key, breaker := range cb.breakers {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		settings := cb.settingsFor(key)
 		breaker.mu.Lock()
-		breaker.state = StateClosed
-		breaker.failures = 0
-		breaker.successes = 0
+		if settings.Window != breaker.settings.Window {
+			breaker.window.reset(settings.Window)
+		}
+		breaker.settings = settings
 		breaker.mu.Unlock()
 	}
 }
@@ -186,12 +499,66 @@ func (cb *CircuitBreaker) Reset(key string) {
 func (cb *CircuitBreaker) ResetAll() {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
-	
+
 	for _, breaker := range cb.breakers {
-		breaker.mu.Lock()
-		breaker.state = StateClosed
-		breaker.failures = 0
-		breaker.successes = 0
-		breaker.mu.Unlock()
+		cb.resetBreaker(breaker)
 	}
-}
\ No newline at end of file
+}
+
+// resetBreaker closes a breaker. The caller must hold the lock.
+func (cb *CircuitBreaker) resetBreaker(breaker *Breaker) {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	breaker.settings = cb.settingsFor(breaker.key)
+	breaker.setState(StateClosed, "reset")
+	breaker.window.reset(breaker.settings.Window)
+}
+
+// rollingWindow counts calls and failures over a sliding time window split
+// into buckets
+type rollingWindow struct {
+	bucketSize time.Duration
+	buckets    [10]windowBucket
+}
+
+// windowBucket holds the counts of one slice of the window
+type windowBucket struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+// reset empties the window and sizes its buckets
+func (w *rollingWindow) reset(window time.Duration) {
+	w.bucketSize = window / time.Duration(len(w.buckets))
+	if w.bucketSize <= 0 {
+		w.bucketSize = time.Second
+	}
+	w.buckets = [10]windowBucket{}
+}
+
+// add counts a call at the given time
+func (w *rollingWindow) add(now time.Time, failed bool) {
+	start := now.Truncate(w.bucketSize)
+	bucket := &w.buckets[(start.UnixNano()/int64(w.bucketSize))%int64(len(w.buckets))]
+	if !bucket.start.Equal(start) {
+		*bucket = windowBucket{start: start}
+	}
+	bucket.requests++
+	if failed {
+		bucket.failures++
+	}
+}
+
+// counts returns the calls and failures within the window
+func (w *rollingWindow) counts(now time.Time) (requests, failures int) {
+	oldest := now.Add(-w.bucketSize * time.Duration(len(w.buckets)))
+	for _, bucket := range w.buckets {
+		if bucket.start.After(oldest) {
+			requests += bucket.requests
+			failures += bucket.failures
+		}
+	}
+	return requests, failures
+}
@@ -0,0 +1,121 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerOpensOnFailureRatio(t *testing.T) {
+	cb := NewCircuitBreaker()
+	cb.SetSettingsResolver(func(key string) BreakerSettings {
+		return BreakerSettings{FailureRatio: 0.5, MinRequests: 4}
+	})
+	boom := errors.New("boom")
+	fail := func() error { return boom }
+	succeed := func() error { return nil }
+
+	// Too few calls to judge, however many failed
+	for i := 0; i < 3; i++ {
+		cb.Execute("user:a:model", fail)
+	}
+	assert.Equal(t, StateClosed, cb.GetState("user:a:model"))
+
+	// Failures below the ratio keep the breaker closed
+	for _, fn := range []func() error{succeed, succeed, fail, succeed} {
+		cb.Execute("user:b:model", fn)
+	}
+	assert.Equal(t, StateClosed, cb.GetState("user:b:model"))
+	cb.Execute("user:b:model", fail)
+	assert.Equal(t, StateClosed, cb.GetState("user:b:model"))
+	cb.Execute("user:b:model", fail)
+	assert.Equal(t, StateOpen, cb.GetState("user:b:model"))
+	assert.ErrorIs(t, cb.Execute("user:b:model", succeed), ErrCircuitOpen)
+
+	// Cancelled calls do not count
+	for i := 0; i < 5; i++ {
+		cb.Execute("user:c:model", func() error { return context.Canceled })
+	}
+	assert.Equal(t, StateClosed, cb.GetState("user:c:model"))
+}
+
+func TestCircuitBreakerLimitsHalfOpenProbes(t *testing.T) {
+	cb := NewCircuitBreaker()
+	cb.SetSettingsResolver(func(key string) BreakerSettings {
+		return BreakerSettings{MinRequests: 1, OpenDuration: 10 * time.Millisecond, HalfOpenProbes: 1}
+	})
+	var events []BreakerEvent
+	cb.OnTransition(func(e BreakerEvent) { events = append(events, e) })
+
+	key := "user:probed:model-b"
+	cb.Execute(key, func() error { return errors.New("boom") })
+	assert.Equal(t, StateOpen, cb.GetState(key))
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, StateHalfOpen, cb.GetState(key))
+
+	// Only one probe at a time while half-open
+	err := cb.Execute(key, func() error {
+		assert.ErrorIs(t, cb.Execute(key, func() error { return nil }), ErrCircuitOpen)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, StateClosed, cb.GetState(key))
+
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, "probed", events[0].Connection)
+		assert.Equal(t, "model-b", events[0].Model)
+		assert.Equal(t, StateOpen, events[0].To)
+		assert.Equal(t, StateHalfOpen, events[1].To)
+		assert.Equal(t, StateClosed, events[2].To)
+	}
+	recent := cb.Events()
+	if assert.Len(t, recent, 3) {
+		assert.Equal(t, "closed", recent[0].ToState)
+	}
+}
+
+func TestGatewayProbesOpenBreakers(t *testing.T) {
+	provider := &fakeProvider{failErr: errors.New("boom")}
+	g := newTestGateway(t, fakeFactory{"recovering": provider})
+	config := ProviderConfig{Name: "recovering"}
+	config.ApplyConnectionSettings(map[string]interface{}{
+		"circuit_breaker": map[string]interface{}{
+			"min_requests":  float64(5),
+			"open_duration": "10ms",
+			"models": map[string]interface{}{
+				"model-c": map[string]interface{}{"min_requests": float64(2), "half_open_probes": float64(1)},
+			},
+		},
+	})
+	assert.NoError(t, g.RegisterProvider("user", "recovering", config))
+
+	req := &Request{UserID: "user", ConnectionID: "recovering", Model: "model-c", Messages: []Message{{Role: "user", Content: "hi"}}}
+	for i := 0; i < 2; i++ {
+		_, err := g.Complete(context.Background(), req.Clone())
+		assert.Error(t, err)
+	}
+	breakers := g.CircuitBreakers()
+	if assert.Len(t, breakers, 1) {
+		assert.Equal(t, "user:recovering:model-c", breakers[0].Key)
+		assert.Equal(t, "open", breakers[0].State)
+		assert.Equal(t, 2, breakers[0].Settings.MinRequests)
+		assert.Equal(t, 10*time.Millisecond, breakers[0].Settings.OpenDuration)
+	}
+
+	// Probes leave a breaker open while the connection keeps failing, and
+	// close it once it recovers
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 0, g.ProbeBreakers(context.Background()))
+	assert.Equal(t, StateOpen, g.circuitBreaker.GetState("user:recovering:model-c"))
+
+	provider.failErr = nil
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 1, g.ProbeBreakers(context.Background()))
+	assert.Equal(t, StateClosed, g.circuitBreaker.GetState("user:recovering:model-c"))
+
+	assert.True(t, g.ResetCircuitBreaker("user:recovering:model-c"))
+	assert.False(t, g.ResetCircuitBreaker("user:missing:model-c"))
+}
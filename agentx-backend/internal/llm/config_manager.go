@@ -150,6 +150,8 @@ func (cm *ConfigManager) GetDefaultModel(provider string) string {
 		return "gpt-3.5-turbo"
 	case "anthropic":
 		return "claude-3-sonnet-20240229"
+	case "gemini":
+		return "gemini-1.5-flash"
 	default:
 		return ""
 	}
@@ -0,0 +1,385 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/agentx/agentx-backend/internal/providers"
+)
+
+// ContextStrategy decides which messages give way when a prompt does not fit
+// the routed model's context window
+type ContextStrategy string
+
+const (
+	// ContextSlidingWindow drops the oldest turns, keeping system messages
+	ContextSlidingWindow ContextStrategy = "sliding_window"
+	// ContextKeepSystemLastN keeps system messages and the last N turns
+	ContextKeepSystemLastN ContextStrategy = "keep_system_last_n"
+	// ContextDropToolOutputs blanks old tool results before dropping turns
+	ContextDropToolOutputs ContextStrategy = "drop_tool_outputs"
+	// ContextSummary replaces dropped turns with the latest session summary
+	ContextSummary ContextStrategy = "summary"
+)
+
+// MetadataContextStrategy is the request metadata key that overrides the
+// budgeter's strategy for a single request
+const MetadataContextStrategy = "context_strategy"
+
+// omittedToolOutput replaces tool results dropped by ContextDropToolOutputs
+const omittedToolOutput = "[tool output omitted to fit the context window]"
+
+// SummaryProvider returns the latest stored summary of a session
+type SummaryProvider interface {
+	LatestSummary(ctx context.Context, userID, sessionID string) (string, error)
+}
+
+// ContextFit describes how a request was fitted to a model's context window
+type ContextFit struct {
+	Strategy      ContextStrategy
+	Window        int
+	PromptTokens  int
+	TokensTrimmed int
+	Dropped       int
+}
+
+// ContextBudgeter fits request messages into the context window of the
+// routed model. Windows come from the model families below, the known model
+// capabilities and the sizes connections report; prompts for models whose
+// window is unknown are left as they are.
+type ContextBudgeter struct {
+	strategy  ContextStrategy
+	keepLastN int
+	windows   map[string]int
+	summaries SummaryProvider
+	mu        sync.RWMutex
+}
+
+// NewContextBudgeter creates a budgeter using the given strategy
+func NewContextBudgeter(strategy ContextStrategy) *ContextBudgeter {
+	return &ContextBudgeter{
+		strategy:  strategy,
+		keepLastN: 10,
+		windows: map[string]int{
+			// OpenAI
+			"gpt-5":         400000,
+			"gpt-4.1":       1047576,
+			"gpt-4.5":       128000,
+			"gpt-4o":        128000,
+			"gpt-4-turbo":   128000,
+			"gpt-4":         8192,
+			"gpt-3.5-turbo": 16385,
+			"o1":            200000,
+			"o1-mini":       128000,
+			"o3":            200000,
+			"o4-mini":       200000,
+
+			// Anthropic
+			"claude": 200000,
+
+			// Google
+			"gemini-1.5-pro":   2000000,
+			"gemini-1.5-flash": 1000000,
+			"gemini-2.0-flash": 1000000,
+			"gemini-2.5":       1048576,
+
+			// Common local models; self-hosted connections report the
+			// size they actually run with
+			"llama3":   8192,
+			"llama3.1": 128000,
+			"llama3.2": 128000,
+			"llama3.3": 128000,
+			"llama4":   1000000,
+			"mistral":  32768,
+			"qwen2.5":  32768,
+			"qwen3":    40960,
+			"phi3":     4096,
+			"phi4":     16384,
+			"gemma2":   8192,
+			"gemma3":   128000,
+		},
+	}
+}
+
+// ParseContextStrategy validates a strategy name
+func ParseContextStrategy(name string) (ContextStrategy, error) {
+	switch strategy := ContextStrategy(name); strategy {
+	case ContextSlidingWindow, ContextKeepSystemLastN, ContextDropToolOutputs, ContextSummary:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown context strategy %q", name)
+}
+
+// SetStrategy changes the default strategy
+func (b *ContextBudgeter) SetStrategy(strategy ContextStrategy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.strategy = strategy
+}
+
+// SetKeepLastN sets how many turns ContextKeepSystemLastN keeps
+func (b *ContextBudgeter) SetKeepLastN(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.keepLastN = n
+}
+
+// SetSummaryProvider sets where ContextSummary looks up session summaries
+func (b *ContextBudgeter) SetSummaryProvider(summaries SummaryProvider) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.summaries = summaries
+}
+
+// SetContextWindow sets the context window of a model or model prefix, such
+// as one a connection reports for its models
+func (b *ContextBudgeter) SetContextWindow(model string, tokens int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.windows[model] = tokens
+}
+
+// ContextWindow returns the context window of a model in tokens, matching the
+// longest known prefix so dated variants resolve to their family. It returns
+// 0 for models whose window is unknown.
+func (b *ContextBudgeter) ContextWindow(model string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if tokens, ok := b.windows[model]; ok {
+		return tokens
+	}
+	if caps, ok := providers.DefaultModelCapabilities[model]; ok {
+		if caps.ContextWindow > 0 {
+			return caps.ContextWindow
+		}
+		if caps.Capabilities.MaxContextSize > 0 {
+			return caps.Capabilities.MaxContextSize
+		}
+	}
+
+	var best string
+	for prefix := range b.windows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	return b.windows[best]
+}
+
+// knowsModel reports whether a model's window was set for it by name rather
+// than inferred from its family
+func (b *ContextBudgeter) knowsModel(model string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	_, ok := b.windows[model]
+	return ok
+}
+
+// EstimateTokens approximates the token count of a text at four characters
+// per token, which is close enough for budgeting across tokenizers
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// ImageTokenEstimate approximates the prompt tokens of one image: about what
+// a 1024x1024 image costs at high detail on OpenAI, and in the range other
+// providers charge for typical images
+const ImageTokenEstimate = 765
+
+// estimateMessageTokens approximates a message including its role, images
+// and tool call overhead
+func estimateMessageTokens(msg Message) int {
+	tokens := 4 + EstimateTokens(msg.Content) + len(msg.Images)*ImageTokenEstimate
+	for _, tc := range msg.ToolCalls {
+		tokens += 4 + EstimateTokens(tc.Function.Name) + EstimateTokens(tc.Function.Arguments)
+	}
+	return tokens
+}
+
+// estimatePromptTokens approximates the prompt size of messages and tools
+func estimatePromptTokens(messages []Message, tools []Tool) int {
+	tokens := 3
+	for _, msg := range messages {
+		tokens += estimateMessageTokens(msg)
+	}
+	if len(tools) > 0 {
+		if data, err := json.Marshal(tools); err == nil {
+			tokens += EstimateTokens(string(data))
+		}
+	}
+	return tokens
+}
+
+// Trimmed reports whether any part of the prompt was dropped
+func (f ContextFit) Trimmed() bool {
+	return f.TokensTrimmed > 0 || f.Dropped > 0
+}
+
+// Fit returns the request's messages trimmed so the prompt plus the completion
+// budget fits the model's context window. The request is not modified, and
+// nothing is trimmed when the window is unknown.
+func (b *ContextBudgeter) Fit(ctx context.Context, req *Request, model string) ([]Message, ContextFit) {
+	b.mu.RLock()
+	strategy := b.strategy
+	keepLastN := b.keepLastN
+	summaries := b.summaries
+	b.mu.RUnlock()
+
+	if name, ok := req.Metadata[MetadataContextStrategy].(string); ok && name != "" {
+		if parsed, err := ParseContextStrategy(name); err == nil {
+			strategy = parsed
+		}
+	}
+
+	window := b.ContextWindow(model)
+	fit := ContextFit{
+		Strategy:     strategy,
+		Window:       window,
+		PromptTokens: estimatePromptTokens(req.Messages, req.Tools),
+	}
+	if window <= 0 {
+		return req.Messages, fit
+	}
+
+	// Leave room for the completion
+	reserve := window / 4
+	if reserve > 4096 {
+		reserve = 4096
+	}
+	if req.MaxTokens != nil && *req.MaxTokens > 0 && *req.MaxTokens < window {
+		reserve = *req.MaxTokens
+	}
+	budget := window - reserve
+	if fit.PromptTokens <= budget {
+		return req.Messages, fit
+	}
+
+	messages := append([]Message(nil), req.Messages...)
+	toolTokens := estimatePromptTokens(nil, req.Tools)
+	over := func(messages []Message) bool {
+		return estimatePromptTokens(messages, nil)+toolTokens > budget
+	}
+
+	switch strategy {
+	case ContextKeepSystemLastN:
+		messages = keepSystemAndLastN(messages, keepLastN)
+	case ContextDropToolOutputs:
+		messages = dropToolOutputs(messages, over)
+	case ContextSummary:
+		if summaries != nil && req.SessionID != "" {
+			summary, err := summaries.LatestSummary(ctx, req.UserID, req.SessionID)
+			if err != nil {
+				fmt.Printf("[ContextBudgeter] Failed to load summary for session %s: %v\n", req.SessionID, err)
+			} else if summary != "" {
+				messages = insertSummary(messages, summary)
+			}
+		}
+	}
+
+	// Every strategy falls back to sliding the window until the prompt fits
+	messages = slideWindow(messages, over)
+
+	after := estimatePromptTokens(messages, req.Tools)
+	fit.TokensTrimmed = fit.PromptTokens - after
+	if fit.TokensTrimmed < 0 {
+		fit.TokensTrimmed = 0
+	}
+	fit.Dropped = len(req.Messages) - len(messages)
+	if fit.Dropped < 0 {
+		fit.Dropped = 0
+	}
+	fit.PromptTokens = after
+
+	return messages, fit
+}
+
+// firstTurn returns the index of the first non-system message
+func firstTurn(messages []Message) int {
+	for i, msg := range messages {
+		if msg.Role != "system" {
+			return i
+		}
+	}
+	return len(messages)
+}
+
+// slideWindow drops the oldest non-system messages until the prompt fits,
+// always keeping the latest message
+func slideWindow(messages []Message, over func([]Message) bool) []Message {
+	for over(messages) {
+		start := firstTurn(messages)
+		if start >= len(messages)-1 {
+			break
+		}
+		messages = append(messages[:start:start], messages[start+1:]...)
+		messages = dropOrphanedToolResults(messages)
+	}
+	return messages
+}
+
+// dropOrphanedToolResults removes tool results whose assistant tool call was
+// dropped, which providers reject
+func dropOrphanedToolResults(messages []Message) []Message {
+	start := firstTurn(messages)
+	for start < len(messages)-1 && messages[start].Role == "tool" {
+		messages = append(messages[:start:start], messages[start+1:]...)
+	}
+	return messages
+}
+
+// keepSystemAndLastN keeps every system message and the last n other messages
+func keepSystemAndLastN(messages []Message, n int) []Message {
+	var system, turns []Message
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			system = append(system, msg)
+		} else {
+			turns = append(turns, msg)
+		}
+	}
+	if n < 1 {
+		n = 1
+	}
+	if len(turns) > n {
+		turns = turns[len(turns)-n:]
+	}
+	return dropOrphanedToolResults(append(system, turns...))
+}
+
+// dropToolOutputs blanks tool results oldest first until the prompt fits,
+// leaving results after the last user message intact
+func dropToolOutputs(messages []Message, over func([]Message) bool) []Message {
+	lastUser := len(messages)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			lastUser = i
+			break
+		}
+	}
+	for i := 0; i < lastUser && over(messages); i++ {
+		if messages[i].Role == "tool" && messages[i].Content != omittedToolOutput {
+			messages[i].Content = omittedToolOutput
+		}
+	}
+	return messages
+}
+
+// insertSummary adds the session summary to the system prompt so it survives
+// the sliding window that drops the turns it stands in for. It is appended to
+// the last leading system message rather than added as another one, since
+// some providers accept a single system prompt only.
+func insertSummary(messages []Message, summary string) []Message {
+	text := "Summary of the earlier conversation:\n" + summary
+	start := firstTurn(messages)
+	fitted := append([]Message(nil), messages[:start]...)
+	if start > 0 {
+		fitted[start-1].Content += "\n\n" + text
+	} else {
+		fitted = append(fitted, Message{Role: "system", Content: text})
+	}
+	return append(fitted, messages[start:]...)
+}
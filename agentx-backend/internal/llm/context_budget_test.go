@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type staticSummaries string
+
+func (s staticSummaries) LatestSummary(ctx context.Context, userID, sessionID string) (string, error) {
+	return string(s), nil
+}
+
+// longHistory builds a system prompt followed by n user/assistant turns of
+// roughly 250 tokens each
+func longHistory(n int) []Message {
+	messages := []Message{{Role: "system", Content: "You are helpful."}}
+	for i := 0; i < n; i++ {
+		messages = append(messages,
+			Message{Role: "user", Content: strings.Repeat("q", 1000)},
+			Message{Role: "assistant", Content: strings.Repeat("a", 1000)},
+		)
+	}
+	return append(messages, Message{Role: "user", Content: "latest question"})
+}
+
+func TestContextBudgeterStrategies(t *testing.T) {
+	b := NewContextBudgeter(ContextSlidingWindow)
+	b.SetContextWindow("tiny", 4000)
+
+	// Fits: nothing trimmed
+	req := &Request{UserID: "user", Messages: longHistory(2)}
+	messages, fit := b.Fit(context.Background(), req, "tiny")
+	assert.False(t, fit.Trimmed())
+	assert.Len(t, messages, len(req.Messages))
+
+	// Sliding window keeps the system prompt and the latest message
+	req = &Request{UserID: "user", Messages: longHistory(20)}
+	messages, fit = b.Fit(context.Background(), req, "tiny")
+	assert.True(t, fit.Trimmed())
+	assert.Greater(t, fit.TokensTrimmed, 0)
+	assert.LessOrEqual(t, fit.PromptTokens, 3000)
+	assert.Equal(t, "system", messages[0].Role)
+	assert.Equal(t, "latest question", messages[len(messages)-1].Content)
+	assert.Len(t, req.Messages, 42, "request must not be modified")
+
+	// Keep system and last N
+	b.SetKeepLastN(3)
+	req.Metadata = map[string]interface{}{MetadataContextStrategy: string(ContextKeepSystemLastN)}
+	messages, fit = b.Fit(context.Background(), req, "tiny")
+	assert.Equal(t, ContextKeepSystemLastN, fit.Strategy)
+	assert.Len(t, messages, 4)
+
+	// Summary replaces dropped turns, as part of the one system prompt
+	b.SetSummaryProvider(staticSummaries("they discussed q and a"))
+	req.SessionID = "session"
+	req.Metadata[MetadataContextStrategy] = string(ContextSummary)
+	messages, _ = b.Fit(context.Background(), req, "tiny")
+	assert.Equal(t, "system", messages[0].Role)
+	assert.Equal(t, "You are helpful.\n\nSummary of the earlier conversation:\nthey discussed q and a", messages[0].Content)
+	assert.NotEqual(t, "system", messages[1].Role)
+	assert.Equal(t, "latest question", messages[len(messages)-1].Content)
+	assert.Equal(t, "You are helpful.", req.Messages[0].Content, "request must not be modified")
+}
+
+func TestContextBudgeterDropsToolOutputs(t *testing.T) {
+	b := NewContextBudgeter(ContextDropToolOutputs)
+	b.SetContextWindow("tiny", 4000)
+
+	call := ToolCall{ID: "call_1", Type: "function"}
+	call.Function.Name = "search"
+	req := &Request{UserID: "user", Messages: []Message{
+		{Role: "user", Content: "search for it"},
+		{Role: "assistant", ToolCalls: []ToolCall{call}},
+		{Role: "tool", ToolCallID: "call_1", Content: strings.Repeat("r", 12000)},
+		{Role: "assistant", Content: "found it"},
+		{Role: "user", Content: "thanks"},
+	}}
+
+	messages, fit := b.Fit(context.Background(), req, "tiny")
+	assert.True(t, fit.Trimmed())
+	assert.Len(t, messages, 5, "tool output is blanked rather than dropped")
+	assert.Equal(t, omittedToolOutput, messages[2].Content)
+	assert.Len(t, req.Messages[2].Content, 12000)
+}
+
+func TestContextBudgeterWindowLookup(t *testing.T) {
+	b := NewContextBudgeter(ContextSlidingWindow)
+	assert.Equal(t, 128000, b.ContextWindow("gpt-4o-2024-08-06"))
+	assert.Equal(t, 200000, b.ContextWindow("claude-3-5-sonnet-20241022"))
+	assert.Equal(t, 1047576, b.ContextWindow("gpt-4.1-mini"), "gpt-4.1 is not gpt-4")
+	assert.Equal(t, 200000, b.ContextWindow("claude-sonnet-4-20250514"))
+	assert.Equal(t, 1048576, b.ContextWindow("gemini-2.5-pro"))
+	assert.Equal(t, 0, b.ContextWindow("unknown-model"))
+}
+
+func TestContextBudgeterLeavesUnknownModelsUntrimmed(t *testing.T) {
+	b := NewContextBudgeter(ContextSlidingWindow)
+	req := &Request{Messages: longHistory(20)}
+
+	messages, fit := b.Fit(context.Background(), req, "unknown-model")
+	assert.Len(t, messages, len(req.Messages))
+	assert.False(t, fit.Trimmed())
+}
+
+func TestEstimateMessageTokensCountsImages(t *testing.T) {
+	text := Message{Role: "user", Content: "what is this?"}
+	withImages := text
+	withImages.Images = []Image{{URL: "https://example.com/a.png"}, {Data: "iVBORw0KGgo=", MimeType: "image/png"}}
+	assert.Equal(t, estimateMessageTokens(text)+2*ImageTokenEstimate, estimateMessageTokens(withImages))
+}
+
+func TestCompleteReportsTrimmedTokens(t *testing.T) {
+	provider := &fakeProvider{}
+	g := newTestGateway(t, fakeFactory{"primary": provider})
+	g.ContextBudgeter().SetContextWindow("tiny", 4000)
+
+	req := &Request{UserID: "user", Model: "tiny", Messages: longHistory(20)}
+	resp, err := g.Complete(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Greater(t, resp.Metadata.TokensTrimmed, 0)
+	assert.Equal(t, string(ContextSlidingWindow), resp.Metadata.ContextStrategy)
+	assert.Less(t, len(provider.lastReq.Messages), len(req.Messages))
+}
+
+func TestCompleteFitsTheWindowTheConnectionReports(t *testing.T) {
+	provider := &fakeProvider{models: []string{"custom-model"}, windows: map[string]int{"custom-model": 4000}}
+	g := newTestGateway(t, fakeFactory{"primary": provider})
+
+	req := &Request{UserID: "user", Model: "custom-model", Messages: longHistory(20)}
+	resp, err := g.Complete(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Greater(t, resp.Metadata.TokensTrimmed, 0)
+	assert.Equal(t, 4000, g.ContextBudgeter().ContextWindow("custom-model"))
+}
@@ -0,0 +1,349 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// DefaultEmbeddingBatchSize is how many inputs go into one provider call
+// when the provider does not declare a limit
+const DefaultEmbeddingBatchSize = 256
+
+// ErrEmbeddingsUnsupported is returned when no connection of the user can
+// embed text
+var ErrEmbeddingsUnsupported = errors.New("no connection supports embeddings")
+
+// EmbeddingProvider is implemented by providers that can embed text
+type EmbeddingProvider interface {
+	// Embed returns one vector per input, in input order
+	Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error)
+}
+
+// EmbeddingRequest asks for vectors of a list of texts
+type EmbeddingRequest struct {
+	Input        []string               `json:"input"`
+	Model        string                 `json:"model,omitempty"`
+	Dimensions   int                    `json:"dimensions,omitempty"` // Truncate vectors, where the model supports it
+	UserID       string                 `json:"user_id"`
+	ConnectionID string                 `json:"connection_id,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// EmbeddingResponse holds one vector per input of an EmbeddingRequest
+type EmbeddingResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+	Model      string      `json:"model"`
+	Dimensions int         `json:"dimensions"`
+	Usage      Usage       `json:"usage"`
+	Metadata   Metadata    `json:"metadata"`
+}
+
+// Validate checks the embedding request
+func (r *EmbeddingRequest) Validate() error {
+	if r.UserID == "" {
+		return fmt.Errorf("user_id is required")
+	}
+	if len(r.Input) == 0 {
+		return fmt.Errorf("at least one input is required")
+	}
+	for i, input := range r.Input {
+		if strings.TrimSpace(input) == "" {
+			return fmt.Errorf("input %d is empty", i)
+		}
+	}
+	if r.Dimensions < 0 {
+		return fmt.Errorf("dimensions must not be negative")
+	}
+	return nil
+}
+
+// routingRequest returns the request the router sees for an embedding call.
+// Inputs become messages so routing rules and token budgets measure them
+// like prompts.
+func (r *EmbeddingRequest) routingRequest() *Request {
+	messages := make([]Message, len(r.Input))
+	for i, input := range r.Input {
+		messages[i] = Message{Role: "user", Content: input}
+	}
+	metadata := make(map[string]interface{}, len(r.Metadata)+1)
+	for k, v := range r.Metadata {
+		metadata[k] = v
+	}
+	metadata[MetadataTaskType] = string(TaskEmbeddings)
+
+	return &Request{
+		Messages:     messages,
+		Model:        r.Model,
+		UserID:       r.UserID,
+		ConnectionID: r.ConnectionID,
+		Preferences:  Preferences{Capabilities: []string{"embeddings"}},
+		Metadata:     metadata,
+	}
+}
+
+// embeddingDimensions lists the vector size of well-known embedding models
+var embeddingDimensions = map[string]int{
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+	"text-embedding-ada-002": 1536,
+	"text-embedding-004":     768,
+	"nomic-embed-text":       768,
+	"mxbai-embed-large":      1024,
+	"all-minilm":             384,
+	"snowflake-arctic-embed": 1024,
+	"bge-m3":                 1024,
+}
+
+// EmbeddingDimensions returns the vector size of a known embedding model,
+// ignoring tags such as ":latest", and 0 for other models
+func EmbeddingDimensions(model string) int {
+	if i := strings.Index(model, ":"); i >= 0 {
+		model = model[:i]
+	}
+	return embeddingDimensions[model]
+}
+
+// supportsEmbeddings reports whether a provider can embed text
+func supportsEmbeddings(provider Provider) (EmbeddingProvider, bool) {
+	embedder, ok := provider.(EmbeddingProvider)
+	if !ok || !provider.GetCapabilities().Embeddings {
+		return nil, false
+	}
+	return embedder, true
+}
+
+// Embed turns texts into vectors through the routed connection. Inputs are
+// split into batches the provider accepts, every batch goes through the
+// connection's retry policy and circuit breaker, and the call is recorded in
+// the usage ledger and metrics like a completion.
+func (g *Gateway) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	startTime := time.Now()
+	ctx, span := tracing.Start(ctx, "gateway.embed")
+	defer span.End()
+
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+
+	// Embeddings pass the same middleware as completions, e.g. rate limits
+	routeReq := req.routingRequest()
+	for _, mw := range g.middleware {
+		var err error
+		ctx, routeReq, err = mw.PreProcess(ctx, routeReq)
+		if err != nil {
+			tracing.RecordError(span, err)
+			return nil, fmt.Errorf("middleware pre-process: %w", err)
+		}
+	}
+
+	embedder, routeInfo, err := g.routeEmbedding(ctx, routeReq)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	fmt.Printf("[Gateway] Routed embeddings to provider=%s, model=%s, connection=%s, reason=%s\n",
+		routeInfo.Provider, routeInfo.Model, routeInfo.ConnectionID, routeInfo.Reason)
+
+	// Budgets apply to embeddings too; their downgrade models are chat
+	// models, so the embedding model is kept
+	model := routeInfo.Model
+	if _, err := g.applyRouteMiddleware(ctx, routeReq, routeInfo); err != nil {
+		tracing.RecordError(span, err)
+		return nil, fmt.Errorf("middleware post-route: %w", err)
+	}
+	routeInfo.Model = model
+
+	resp, retries, err := g.embedBatches(ctx, req, embedder, routeInfo)
+	span.SetAttributes(attribute.Int(attrRetries, retries))
+
+	record := UsageRecord{
+		UserID:       req.UserID,
+		ConnectionID: routeInfo.ConnectionID,
+		Provider:     routeInfo.Provider,
+		Model:        routeInfo.Model,
+		APIKeyID:     routeReq.APIKeyID(),
+		TaskType:     string(TaskEmbeddings),
+		Latency:      time.Since(startTime),
+		Success:      err == nil,
+		Error:        errorText(err),
+	}
+	var usage Usage
+	if resp != nil {
+		usage = resp.Usage
+		if resp.Model != "" {
+			record.Model = resp.Model
+		}
+	}
+	cost := g.recordUsage(ctx, record, usage)
+	tracing.RecordError(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Usage.EstimatedCost = cost
+	resp.Metadata = Metadata{
+		Provider:       routeInfo.Provider,
+		Model:          resp.Model,
+		ConnectionID:   routeInfo.ConnectionID,
+		LatencyMs:      time.Since(startTime).Milliseconds(),
+		Retries:        retries,
+		RoutingReason:  routeInfo.Reason,
+		RoutingScore:   routeInfo.Score,
+		BudgetWarnings: budgetWarnings(routeReq),
+	}
+	return resp, nil
+}
+
+// routeEmbedding routes an embedding request. When the router picks a
+// connection that cannot embed and the caller did not name one, the first of
+// the user's connections that can is used instead.
+func (g *Gateway) routeEmbedding(ctx context.Context, req *Request) (EmbeddingProvider, *RouteInfo, error) {
+	provider, routeInfo, err := g.route(ctx, req)
+	if err == nil {
+		if embedder, ok := supportsEmbeddings(provider); ok {
+			return embedder, routeInfo, nil
+		}
+		if req.ConnectionID != "" {
+			return nil, nil, fmt.Errorf("%w: connection %s", ErrEmbeddingsUnsupported, req.ConnectionID)
+		}
+	}
+
+	userProviders := g.providers.GetUserProviders(req.UserID)
+	keys := make([]string, 0, len(userProviders))
+	for key := range userProviders {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if embedder, ok := supportsEmbeddings(userProviders[key]); ok {
+			return embedder, &RouteInfo{
+				Provider:     key,
+				ConnectionID: key,
+				Model:        req.Model,
+				Reason:       "first connection that supports embeddings",
+			}, nil
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("routing failed: %w", err)
+	}
+	return nil, nil, ErrEmbeddingsUnsupported
+}
+
+// embedBatches sends the inputs to the provider in batches and joins the
+// results. Providers that do not report token usage are estimated.
+func (g *Gateway) embedBatches(ctx context.Context, req *EmbeddingRequest, embedder EmbeddingProvider, routeInfo *RouteInfo) (*EmbeddingResponse, int, error) {
+	batchSize := DefaultEmbeddingBatchSize
+	if provider, ok := embedder.(Provider); ok {
+		if max := provider.GetCapabilities().MaxEmbeddingBatch; max > 0 {
+			batchSize = max
+		}
+	}
+
+	cbKey := breakerKey(req.UserID, routeInfo.ConnectionID, routeInfo.Model)
+	policy := g.retryPolicyFor(req.UserID, routeInfo.ConnectionID)
+	result := &EmbeddingResponse{
+		Embeddings: make([][]float32, 0, len(req.Input)),
+		Model:      routeInfo.Model,
+	}
+	totalRetries := 0
+
+	for start := 0; start < len(req.Input); start += batchSize {
+		end := start + batchSize
+		if end > len(req.Input) {
+			end = len(req.Input)
+		}
+		batch := &EmbeddingRequest{
+			Input:        req.Input[start:end],
+			Model:        routeInfo.Model,
+			Dimensions:   req.Dimensions,
+			UserID:       req.UserID,
+			ConnectionID: routeInfo.ConnectionID,
+			Metadata:     req.Metadata,
+		}
+
+		var resp *EmbeddingResponse
+		retries, err := g.withRetry(ctx, policy, func(attemptCtx context.Context) error {
+			return g.circuitBreaker.Execute(cbKey, func() error {
+				var execErr error
+				callStart := time.Now()
+				callCtx, callSpan := g.startProviderSpan(attemptCtx, "provider.embed", req.UserID, routeInfo.ConnectionID, routeInfo.Model)
+				done := g.router.BeginCall(req.UserID, routeInfo.ConnectionID)
+				resp, execErr = embedder.Embed(callCtx, batch)
+				done(execErr)
+				tracing.RecordError(callSpan, execErr)
+				callSpan.End()
+				if execErr == nil {
+					g.router.ObserveLatency(req.UserID, routeInfo.ConnectionID, routeInfo.Model, time.Since(callStart))
+				}
+				return execErr
+			})
+		})
+		totalRetries += retries
+		if err != nil {
+			return result, totalRetries, err
+		}
+		if len(resp.Embeddings) != len(batch.Input) {
+			return result, totalRetries, fmt.Errorf("provider returned %d embeddings for %d inputs", len(resp.Embeddings), len(batch.Input))
+		}
+
+		usage := resp.Usage
+		if usage.PromptTokens == 0 {
+			for _, input := range batch.Input {
+				usage.PromptTokens += EstimateTokens(input)
+			}
+		}
+		if usage.TotalTokens == 0 {
+			usage.TotalTokens = usage.PromptTokens
+		}
+		result.Usage = addUsage(result.Usage, usage)
+		result.Embeddings = append(result.Embeddings, resp.Embeddings...)
+		if resp.Model != "" {
+			result.Model = resp.Model
+		}
+	}
+
+	g.observeRetries(req.UserID, routeInfo.ConnectionID, routeInfo.Model, totalRetries)
+	if len(result.Embeddings) > 0 {
+		result.Dimensions = len(result.Embeddings[0])
+	}
+	return result, totalRetries, nil
+}
+
+// GatewayEmbedder embeds semantic cache prompts through the gateway with the
+// user's own embedding connection. Users without one fall back to another
+// embedder, so their semantic cache keeps working.
+type GatewayEmbedder struct {
+	gateway  *Gateway
+	model    string
+	fallback Embedder
+}
+
+// NewGatewayEmbedder creates a cache embedder backed by the gateway. An
+// empty model leaves the choice to the provider.
+func NewGatewayEmbedder(gateway *Gateway, model string, fallback Embedder) *GatewayEmbedder {
+	return &GatewayEmbedder{gateway: gateway, model: model, fallback: fallback}
+}
+
+// Embed implements Embedder
+func (e *GatewayEmbedder) Embed(ctx context.Context, userID, text string) ([]float32, error) {
+	resp, err := e.gateway.Embed(ctx, &EmbeddingRequest{
+		Input:  []string{text},
+		Model:  e.model,
+		UserID: userID,
+	})
+	if errors.Is(err, ErrEmbeddingsUnsupported) && e.fallback != nil {
+		return e.fallback.Embed(ctx, userID, text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Embeddings[0], nil
+}
@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// embeddingProvider embeds each input as a vector of its length
+type embeddingProvider struct {
+	fakeProvider
+	batches [][]string
+}
+
+func (p *embeddingProvider) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	p.batches = append(p.batches, req.Input)
+	embeddings := make([][]float32, len(req.Input))
+	for i, input := range req.Input {
+		embeddings[i] = []float32{float32(len(input)), 1, 0}
+	}
+	return &EmbeddingResponse{Embeddings: embeddings, Model: "text-embedding-3-small"}, nil
+}
+
+func (p *embeddingProvider) GetCapabilities() ProviderCapabilities {
+	return ProviderCapabilities{Embeddings: true, MaxEmbeddingBatch: 2}
+}
+
+func TestEmbedBatchesInputsAndRecordsUsage(t *testing.T) {
+	recorder := &memoryRecorder{}
+	vectors := &embeddingProvider{}
+	g := newTestGateway(t, fakeFactory{"chat": &fakeProvider{}, "vectors": vectors})
+	g.SetUsageRecorder(recorder)
+
+	input := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+	resp, err := g.Embed(context.Background(), &EmbeddingRequest{UserID: "user", Input: input})
+	assert.NoError(t, err)
+
+	// Routed past the chat-only connection, three batches of at most two
+	assert.Equal(t, [][]string{{"a", "bb"}, {"ccc", "dddd"}, {"eeeee"}}, vectors.batches)
+	if assert.Len(t, resp.Embeddings, 5) {
+		for i, embedding := range resp.Embeddings {
+			assert.Equal(t, float32(len(input[i])), embedding[0])
+		}
+	}
+	assert.Equal(t, 3, resp.Dimensions)
+	assert.Equal(t, "text-embedding-3-small", resp.Model)
+
+	// Usage the provider did not report is estimated and priced
+	estimated := 0
+	for _, text := range input {
+		estimated += EstimateTokens(text)
+	}
+	assert.Equal(t, estimated, resp.Usage.PromptTokens)
+	assert.Equal(t, estimated, resp.Usage.TotalTokens)
+	assert.InDelta(t, float64(estimated)*0.02/1e6, resp.Usage.EstimatedCost, 1e-12)
+
+	if assert.Len(t, recorder.records, 1) {
+		record := recorder.records[0]
+		assert.Equal(t, "vectors", record.ConnectionID)
+		assert.Equal(t, string(TaskEmbeddings), record.TaskType)
+		assert.Equal(t, "text-embedding-3-small", record.Model)
+		assert.Equal(t, estimated, record.PromptTokens)
+		assert.True(t, record.Success)
+	}
+}
+
+func TestEmbedRejectsConnectionWithoutEmbeddings(t *testing.T) {
+	g := newTestGateway(t, fakeFactory{"chat": &fakeProvider{}, "vectors": &embeddingProvider{}})
+
+	_, err := g.Embed(context.Background(), &EmbeddingRequest{UserID: "user", ConnectionID: "chat", Input: []string{"hi"}})
+	assert.True(t, errors.Is(err, ErrEmbeddingsUnsupported))
+
+	_, err = g.Embed(context.Background(), &EmbeddingRequest{UserID: "user", Input: []string{"hi", " "}})
+	assert.ErrorContains(t, err, "input 1 is empty")
+}
+
+// limitMiddleware rejects requests once a user has made too many
+type limitMiddleware struct {
+	BaseMiddleware
+	remaining int
+}
+
+func (m *limitMiddleware) PreProcess(ctx context.Context, req *Request) (context.Context, *Request, error) {
+	if m.remaining == 0 {
+		return ctx, req, errors.New("rate limit exceeded for user " + req.UserID)
+	}
+	m.remaining--
+	return ctx, req, nil
+}
+
+func TestEmbedRunsMiddleware(t *testing.T) {
+	vectors := &embeddingProvider{}
+	g := newTestGateway(t, fakeFactory{"vectors": vectors})
+	g.middleware = append(g.middleware, &limitMiddleware{remaining: 1})
+
+	req := &EmbeddingRequest{UserID: "user", Input: []string{"hi"}}
+	_, err := g.Embed(context.Background(), req)
+	assert.NoError(t, err)
+	_, err = g.Embed(context.Background(), req)
+	assert.ErrorContains(t, err, "rate limit exceeded")
+	assert.Len(t, vectors.batches, 1)
+}
+
+func TestEmbeddingDimensions(t *testing.T) {
+	assert.Equal(t, 768, EmbeddingDimensions("nomic-embed-text:latest"))
+	assert.Equal(t, 3072, EmbeddingDimensions("text-embedding-3-large"))
+	assert.Equal(t, 0, EmbeddingDimensions("gpt-4o"))
+}
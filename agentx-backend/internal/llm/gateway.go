@@ -2,22 +2,38 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/agentx/agentx-backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Gateway is the centralized service for all LLM interactions
 type Gateway struct {
-	providers      *ProviderManager
-	config         *ConfigManager
-	router         *Router
-	middleware     []Middleware
-	circuitBreaker *CircuitBreaker
-	metrics        *MetricsCollector
-	mu             sync.RWMutex
+	providers         *ProviderManager
+	config            *ConfigManager
+	router            *Router
+	middleware        []Middleware
+	circuitBreaker    *CircuitBreaker
+	metrics           *MetricsCollector
+	retryPolicy       RetryPolicy
+	streamIdleTimeout time.Duration
+	contextBudgeter   *ContextBudgeter
+	usage             UsageRecorder
+	budgets           *BudgetMiddleware
+	cache             *ResponseCache
+	probeStop         chan struct{} // Closed to stop the breaker probe loop
+	mu                sync.RWMutex
 }
 
+// ErrStreamStalled is reported when a provider stops sending stream data
+var ErrStreamStalled = errors.New("stream stalled")
+
 // GatewayOption is a functional option for configuring the Gateway
 type GatewayOption func(*Gateway)
 
@@ -42,15 +58,42 @@ func WithMetrics(m *MetricsCollector) GatewayOption {
 	}
 }
 
+// WithRetryPolicy configures the default retry policy
+func WithRetryPolicy(p RetryPolicy) GatewayOption {
+	return func(g *Gateway) {
+		g.retryPolicy = p
+	}
+}
+
+// WithStreamIdleTimeout sets how long a stream may go without data before
+// the gateway treats it as failed
+func WithStreamIdleTimeout(d time.Duration) GatewayOption {
+	return func(g *Gateway) {
+		g.streamIdleTimeout = d
+	}
+}
+
+// WithContextBudgeter configures how prompts are fitted to the routed
+// model's context window
+func WithContextBudgeter(b *ContextBudgeter) GatewayOption {
+	return func(g *Gateway) {
+		g.contextBudgeter = b
+	}
+}
+
 // NewGateway creates a new LLM Gateway
 func NewGateway(opts ...GatewayOption) *Gateway {
 	g := &Gateway{
-		providers:      NewProviderManager(),
-		config:         NewConfigManager(),
-		router:         NewRouter(),
-		middleware:     []Middleware{},
-		circuitBreaker: NewCircuitBreaker(),
-		metrics:        NewMetricsCollector(),
+		providers:         NewProviderManager(),
+		config:            NewConfigManager(),
+		router:            NewRouter(),
+		middleware:        []Middleware{},
+		circuitBreaker:    NewCircuitBreaker(),
+		metrics:           NewMetricsCollector(),
+		retryPolicy:       DefaultRetryPolicy(),
+		streamIdleTimeout: 60 * time.Second,
+		contextBudgeter:   NewContextBudgeter(ContextSlidingWindow),
+		cache:             NewResponseCache(),
 	}
 
 	// Apply options
@@ -58,6 +101,13 @@ func NewGateway(opts ...GatewayOption) *Gateway {
 		opt(g)
 	}
 
+	// Wire the router to the provider and config managers
+	g.router.SetProviderManager(g.providers)
+	g.router.SetConfigManager(g.config)
+	g.router.SetContextBudgeter(g.contextBudgeter)
+	g.circuitBreaker.SetSettingsResolver(g.breakerSettings)
+	g.budgets = NewBudgetMiddleware(g.router.PricingCatalog)
+
 	// Setup default middleware pipeline if none provided
 	if len(g.middleware) == 0 {
 		g.setupDefaultMiddleware()
@@ -73,6 +123,7 @@ func (g *Gateway) setupDefaultMiddleware() {
 		NewMetricsMiddleware(g.metrics),
 		NewValidationMiddleware(),
 		NewRateLimitMiddleware(),
+		g.budgets,
 		NewRetryMiddleware(),
 	}
 }
@@ -80,6 +131,8 @@ func (g *Gateway) setupDefaultMiddleware() {
 // Complete handles non-streaming completions
 func (g *Gateway) Complete(ctx context.Context, req *Request) (*Response, error) {
 	startTime := time.Now()
+	ctx, span := tracing.Start(ctx, "gateway.complete")
+	defer span.End()
 
 	// Validate request
 	if err := req.Validate(); err != nil {
@@ -96,34 +149,49 @@ func (g *Gateway) Complete(ctx context.Context, req *Request) (*Response, error)
 	}
 
 	// Route to provider
-	provider, routeInfo, err := g.router.Route(ctx, req)
+	provider, routeInfo, err := g.route(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("routing failed: %w", err)
 	}
 
 	// Log routing decision
-	fmt.Printf("[Gateway] Routed request to provider=%s, model=%s, connection=%s\n",
-		routeInfo.Provider, routeInfo.Model, routeInfo.ConnectionID)
-
-	// Execute with circuit breaker
-	var resp *Response
-	cbKey := fmt.Sprintf("%s:%s", routeInfo.Provider, routeInfo.Model)
-	
-	err = g.circuitBreaker.Execute(cbKey, func() error {
-		var execErr error
-		resp, execErr = provider.Complete(ctx, req)
-		return execErr
-	})
+	fmt.Printf("[Gateway] Routed request to provider=%s, model=%s, connection=%s, reason=%s\n",
+		routeInfo.Provider, routeInfo.Model, routeInfo.ConnectionID, routeInfo.Reason)
+	if routeInfo.Model != "" {
+		req.Model = routeInfo.Model
+	}
 
-	// Handle circuit breaker errors and try fallback
+	// Apply middleware that depends on the routed connection
+	req, err = g.applyRouteMiddleware(ctx, req, routeInfo)
 	if err != nil {
-		if fallbackProvider := g.router.GetFallback(routeInfo.Provider); fallbackProvider != nil {
-			fmt.Printf("[Gateway] Primary provider failed, trying fallback\n")
-			resp, err = fallbackProvider.Complete(ctx, req)
-			if err == nil {
-				resp.Metadata.FallbackUsed = true
-			}
-		}
+		return nil, fmt.Errorf("middleware post-route: %w", err)
+	}
+
+	// Fit the prompt into the routed model's context window
+	req, fit := g.fitContext(ctx, req, provider, routeInfo.ConnectionID)
+
+	// Serve repeated prompts from the response cache; identical concurrent
+	// requests share a single provider call. The shared call may outlive
+	// this request, so it hands back how it ran under a lock.
+	var mu sync.Mutex
+	start := completionCall{route: routeInfo, fit: fit}
+	executed := &start
+	config, _ := g.providers.GetConfig(req.UserID, routeInfo.ConnectionID)
+	resp, cached, err := g.cache.Do(ctx, req, config, routeInfo.ConnectionID, func(ctx context.Context) (*Response, error) {
+		shared := start
+		resp, err := g.executeStructured(ctx, req, provider, &shared)
+		mu.Lock()
+		executed = &shared
+		mu.Unlock()
+		return resp, err
+	})
+	mu.Lock()
+	call := executed
+	mu.Unlock()
+	retries, fallbackUsed, routeInfo, fit := call.retries, call.fallbackUsed, call.route, call.fit
+	span.SetAttributes(attribute.Int(attrRetries, retries), attribute.String(attrCache, cached.Status))
+	if call.hedge != nil {
+		span.SetAttributes(attribute.String(attrHedgeWinner, call.hedge.winner))
 	}
 
 	// Apply middleware post-processing
@@ -137,12 +205,29 @@ func (g *Gateway) Complete(ctx context.Context, req *Request) (*Response, error)
 		resp.Metadata.Model = routeInfo.Model
 		resp.Metadata.ConnectionID = routeInfo.ConnectionID
 		resp.Metadata.LatencyMs = time.Since(startTime).Milliseconds()
-		
+		resp.Metadata.Retries = retries
+		resp.Metadata.FallbackUsed = fallbackUsed
+		resp.Metadata.RoutingReason = routeInfo.Reason
+		resp.Metadata.RoutingScore = routeInfo.Score
+		if fit.Trimmed() {
+			resp.Metadata.TokensTrimmed = fit.TokensTrimmed
+			resp.Metadata.ContextStrategy = string(fit.Strategy)
+		}
+		resp.Metadata.BudgetWarnings = budgetWarnings(req)
+		resp.Metadata.Cache = cached.Status
+		if cached.Status == CacheSemanticHit {
+			resp.Metadata.CacheSimilarity = cached.Similarity
+		}
+		if call.hedge != nil {
+			resp.Metadata.Hedged = true
+			resp.Metadata.HedgeWinner = call.hedge.winner
+		}
+
 		// Populate convenience fields for direct access
 		resp.Content = resp.GetContent()
 		resp.Role = resp.GetRole()
 		resp.Provider = routeInfo.Provider
-		
+
 		// Debug: Log response structure
 		fmt.Printf("[Gateway] Response debug - Content: '%s', Choices count: %d\n", resp.Content, len(resp.Choices))
 		if len(resp.Choices) > 0 {
@@ -150,19 +235,169 @@ func (g *Gateway) Complete(ctx context.Context, req *Request) (*Response, error)
 		}
 	}
 
-	// Record metrics
-	if g.metrics != nil {
-		g.metrics.RecordRequest(routeInfo.Provider, routeInfo.Model, err == nil, time.Since(startTime))
-		if resp != nil {
-			g.metrics.RecordUsage(routeInfo.Provider, resp.Usage)
+	// Record the call in the usage ledger
+	record := UsageRecord{
+		UserID:       req.UserID,
+		ConnectionID: routeInfo.ConnectionID,
+		Provider:     routeInfo.Provider,
+		Model:        routeInfo.Model,
+		APIKeyID:     req.APIKeyID(),
+		TaskType:     req.TaskType(),
+		Latency:      time.Since(startTime),
+		Success:      err == nil,
+		Error:        errorText(err),
+		FallbackUsed: fallbackUsed,
+	}
+	var usage Usage
+	if resp != nil {
+		// Answers served from the cache cost nothing
+		if !cached.Served() {
+			usage = resp.Usage
 		}
+		if resp.Model != "" {
+			record.Model = resp.Model
+		}
+	}
+	cost := g.recordUsage(ctx, record, usage)
+	if resp != nil && resp.Usage.EstimatedCost == 0 {
+		resp.Usage.EstimatedCost = cost
 	}
+	tracing.RecordError(span, err)
 
 	return resp, err
 }
 
+// completionCall tracks how a completion was executed
+type completionCall struct {
+	route        *RouteInfo
+	fit          ContextFit
+	retries      int
+	fallbackUsed bool
+	hedge        *hedgeOutcome
+}
+
+// execute calls the routed provider, retrying transient failures, and walks
+// the fallback chain if it still fails. Latency-sensitive requests are hedged
+// to a second connection. The call records the retries, any fallback or hedge
+// taken and the route that answered.
+func (g *Gateway) execute(ctx context.Context, req *Request, provider Provider, call *completionCall) (*Response, error) {
+	var resp *Response
+	var err error
+	routeInfo := call.route
+
+	if target, ok := g.hedgeTarget(ctx, req, routeInfo); ok {
+		resp, err = g.hedgeComplete(ctx, req, provider, target, call)
+	} else {
+		resp, call.retries, err = g.completeRoute(ctx, req, provider, routeInfo)
+	}
+
+	g.observeRetries(req.UserID, routeInfo.ConnectionID, routeInfo.Model, call.retries)
+
+	// Handle circuit breaker errors and walk the fallback chain
+	if err != nil {
+		for _, fallback := range g.router.FallbackChain(ctx, req, routeInfo.ConnectionID) {
+			if call.hedge != nil && call.hedge.target == fallback.Key {
+				continue
+			}
+			fmt.Printf("[Gateway] Provider %s failed, trying fallback %s: %v\n", routeInfo.Provider, fallback.Key, err)
+
+			var fallbackReq *Request
+			var fallbackFit ContextFit
+			var fallbackResp *Response
+			fallbackResp, fallbackReq, fallbackFit, err = g.completeFallback(ctx, req, fallback)
+			if err == nil {
+				resp = fallbackResp
+				call.fallbackUsed = true
+				g.observeFallback(req.UserID, routeInfo.ConnectionID, routeInfo.Model)
+				call.fit.TokensTrimmed += fallbackFit.TokensTrimmed
+				call.fit.Strategy = fallbackFit.Strategy
+				call.route = &RouteInfo{
+					Provider:     fallback.Key,
+					ConnectionID: fallback.ConnectionID(req.UserID),
+					Model:        fallbackReq.Model,
+					Reason:       fmt.Sprintf("fallback after %s failed (%s)", routeInfo.Provider, routeInfo.Reason),
+				}
+				break
+			}
+		}
+	}
+
+	return resp, err
+}
+
+// completeRoute calls the routed provider through its circuit breaker,
+// retrying transient failures, and returns the number of retries performed
+func (g *Gateway) completeRoute(ctx context.Context, req *Request, provider Provider, routeInfo *RouteInfo) (*Response, int, error) {
+	var resp *Response
+	cbKey := breakerKey(req.UserID, routeInfo.ConnectionID, routeInfo.Model)
+	policy := g.retryPolicyFor(req.UserID, routeInfo.ConnectionID)
+
+	retries, err := g.withRetry(ctx, policy, func(attemptCtx context.Context) error {
+		return g.circuitBreaker.Execute(cbKey, func() error {
+			var execErr error
+			callStart := time.Now()
+			callCtx, callSpan := g.startProviderSpan(attemptCtx, "provider.complete", req.UserID, routeInfo.ConnectionID, req.Model)
+			done := g.router.BeginCall(req.UserID, routeInfo.ConnectionID)
+			resp, execErr = completeStructured(callCtx, provider, req)
+			done(execErr)
+			tracing.RecordError(callSpan, execErr)
+			callSpan.End()
+			if execErr == nil {
+				g.router.ObserveLatency(req.UserID, routeInfo.ConnectionID, req.Model, time.Since(callStart))
+			}
+			return execErr
+		})
+	})
+	return resp, retries, err
+}
+
+// completeFallback calls a connection from the fallback chain once, with the
+// prompt fitted to the fallback's model. It returns the request that was sent.
+func (g *Gateway) completeFallback(ctx context.Context, req *Request, fallback FallbackTarget) (*Response, *Request, ContextFit, error) {
+	var resp *Response
+	fallbackReq := req
+	if fallback.Model != "" {
+		fallbackReq = req.Clone()
+		fallbackReq.Model = fallback.Model
+	}
+	fallbackReq, err := g.applyFallbackMiddleware(ctx, fallbackReq, fallback)
+	if err != nil {
+		return nil, fallbackReq, ContextFit{}, err
+	}
+	fallbackReq, fallbackFit := g.fitContext(ctx, fallbackReq, fallback.Provider, fallback.Key)
+	fallbackKey := breakerKey(req.UserID, fallback.Key, fallbackReq.Model)
+	err = g.circuitBreaker.Execute(fallbackKey, func() error {
+		var execErr error
+		callStart := time.Now()
+		callCtx, callSpan := g.startProviderSpan(ctx, "provider.complete", req.UserID, fallback.Key, fallbackReq.Model)
+		done := g.router.BeginCall(req.UserID, fallback.Key)
+		resp, execErr = completeStructured(callCtx, fallback.Provider, fallbackReq)
+		done(execErr)
+		tracing.RecordError(callSpan, execErr)
+		callSpan.End()
+		if execErr == nil {
+			g.router.ObserveLatency(req.UserID, fallback.Key, fallbackReq.Model, time.Since(callStart))
+		}
+		return execErr
+	})
+	return resp, fallbackReq, fallbackFit, err
+}
+
 // StreamComplete handles streaming completions
 func (g *Gateway) StreamComplete(ctx context.Context, req *Request) (<-chan *StreamChunk, error) {
+	// The span stays open until every leg of the stream has finished
+	ctx, span := tracing.Start(ctx, "gateway.stream")
+	out, err := g.streamComplete(ctx, span, req)
+	if err != nil {
+		tracing.RecordError(span, err)
+		span.End()
+	}
+	return out, err
+}
+
+// streamComplete opens the stream and pumps it until it ends, failing over
+// between providers; span is ended once the stream is done
+func (g *Gateway) streamComplete(ctx context.Context, span trace.Span, req *Request) (<-chan *StreamChunk, error) {
 	// Validate request
 	if err := req.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid request: %w", err)
@@ -178,66 +413,484 @@ func (g *Gateway) StreamComplete(ctx context.Context, req *Request) (<-chan *Str
 	}
 
 	// Route to provider
-	provider, routeInfo, err := g.router.Route(ctx, req)
+	provider, routeInfo, err := g.route(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("routing failed: %w", err)
 	}
 
 	// Log routing decision
-	fmt.Printf("[Gateway] Streaming request routed to provider=%s, model=%s, connection=%s\n",
-		routeInfo.Provider, routeInfo.Model, routeInfo.ConnectionID)
+	fmt.Printf("[Gateway] Streaming request routed to provider=%s, model=%s, connection=%s, reason=%s\n",
+		routeInfo.Provider, routeInfo.Model, routeInfo.ConnectionID, routeInfo.Reason)
+	if routeInfo.Model != "" {
+		req.Model = routeInfo.Model
+	}
 
-	// Get stream from provider
-	providerStream, err := provider.StreamComplete(ctx, req)
+	// Apply middleware that depends on the routed connection
+	req, err = g.applyRouteMiddleware(ctx, req, routeInfo)
 	if err != nil {
-		// Try fallback
-		if fallbackProvider := g.router.GetFallback(routeInfo.Provider); fallbackProvider != nil {
-			fmt.Printf("[Gateway] Primary provider stream failed, trying fallback\n")
-			providerStream, err = fallbackProvider.StreamComplete(ctx, req)
+		return nil, fmt.Errorf("middleware post-route: %w", err)
+	}
+
+	// Fit the prompt into the routed model's context window
+	req, fit := g.fitContext(ctx, req, provider, routeInfo.ConnectionID)
+
+	// Get stream from provider, retrying failures to open it
+	leg := &streamLeg{
+		provider:   routeInfo.Provider,
+		model:      routeInfo.Model,
+		userID:     req.UserID,
+		connection: routeInfo.ConnectionID,
+		fit:        fit,
+		warnings:   budgetWarnings(req),
+	}
+	policy := g.retryPolicyFor(req.UserID, routeInfo.ConnectionID)
+	retries, err := g.withRetry(ctx, policy, func(_ context.Context) error {
+		// The stream outlives the attempt, so only the caller's context applies
+		return g.openLeg(ctx, leg, provider, req)
+	})
+	g.observeRetries(req.UserID, routeInfo.ConnectionID, routeInfo.Model, retries)
+	span.SetAttributes(attribute.Int(attrRetries, retries))
+	fallbacks := g.router.FallbackChain(ctx, req, routeInfo.ConnectionID)
+	if err == nil {
+		// Race a second connection if the first token is slow to arrive
+		if target, ok := g.hedgeTarget(ctx, req, routeInfo); ok {
+			var fired bool
+			if leg, fired = g.hedgeStream(ctx, req, leg, target); fired {
+				fallbacks = withoutFallback(fallbacks, target.Key)
+			}
 		}
-		if err != nil {
+	}
+	if err != nil {
+		// Try the fallback chain
+		fmt.Printf("[Gateway] Primary provider stream failed, trying fallbacks\n")
+		next, fallbackErr := g.resumeStream(ctx, req, leg, "", &fallbacks)
+		if fallbackErr != nil {
+			g.recordUsage(ctx, UsageRecord{
+				UserID:       req.UserID,
+				ConnectionID: routeInfo.ConnectionID,
+				Provider:     routeInfo.Provider,
+				Model:        routeInfo.Model,
+				APIKeyID:     req.APIKeyID(),
+				TaskType:     req.TaskType(),
+				Stream:       true,
+				Error:        errorText(err),
+			}, Usage{})
 			return nil, err
 		}
+		leg = next
 	}
 
 	// Create output channel
 	out := make(chan *StreamChunk)
 
-	// Process stream in goroutine
+	// Process stream in goroutine, failing over if the provider dies mid-stream
 	go func() {
+		defer span.End()
 		defer close(out)
-		startTime := time.Now()
-		var totalTokens int
-
-		for chunk := range providerStream {
-			// Add metadata to chunk
-			chunk.Model = routeInfo.Model
-			
-			// Track metrics
-			if g.metrics != nil && chunk.Usage != nil {
-				totalTokens += chunk.Usage.TotalTokens
+		var partial strings.Builder
+		failedOver := false
+
+		for {
+			startTime := time.Now()
+			streamErr := g.pumpStream(ctx, leg, out, &partial)
+			if ctx.Err() == nil {
+				leg.settle(streamErr)
+			}
+			leg.finish(streamErr)
+
+			g.recordUsage(ctx, UsageRecord{
+				UserID:       leg.userID,
+				ConnectionID: leg.connection,
+				Provider:     leg.provider,
+				Model:        leg.model,
+				APIKeyID:     req.APIKeyID(),
+				TaskType:     req.TaskType(),
+				Stream:       true,
+				Latency:      time.Since(startTime),
+				Success:      streamErr == nil,
+				Error:        errorText(streamErr),
+				FallbackUsed: failedOver,
+			}, leg.usageEstimate())
+
+			if ctx.Err() != nil || streamErr == nil {
+				return
+			}
+
+			fmt.Printf("[Gateway] Stream from %s failed after %d chars: %v\n", leg.provider, partial.Len(), streamErr)
+
+			next, err := g.resumeStream(ctx, req, leg, partial.String(), &fallbacks)
+			if err != nil {
+				tracing.RecordError(span, streamErr)
+				select {
+				case out <- &StreamChunk{
+					Type:     "error",
+					Model:    leg.model,
+					Provider: leg.provider,
+					Error:    streamErr,
+				}:
+				case <-ctx.Done():
+				}
+				return
 			}
 
-			// Send chunk
+			// Tell the client which provider continues the answer
 			select {
-			case out <- chunk:
+			case out <- &StreamChunk{
+				Type:     "failover",
+				Model:    next.model,
+				Provider: next.provider,
+				Metadata: map[string]interface{}{
+					"from_provider": leg.provider,
+					"from_model":    leg.model,
+					"to_provider":   next.provider,
+					"to_model":      next.model,
+					"reason":        streamErr.Error(),
+					"resumed_chars": partial.Len(),
+				},
+			}:
 			case <-ctx.Done():
+				next.close()
 				return
 			}
-		}
 
-		// Record final metrics
-		if g.metrics != nil {
-			g.metrics.RecordRequest(routeInfo.Provider, routeInfo.Model, true, time.Since(startTime))
+			leg = next
+			failedOver = true
 		}
 	}()
 
 	return out, nil
 }
 
+// streamLeg is one provider stream within a possibly failed-over response
+type streamLeg struct {
+	provider   string
+	model      string
+	userID     string
+	connection string
+	stream     <-chan *StreamChunk
+	cancel     context.CancelFunc
+	span       trace.Span
+	done       func(error) // Ends the call in the router's connection stats
+	breaker    func(error) // Records the outcome with the leg's circuit breaker
+	opened     time.Time
+	firstChunk bool
+	fit        ContextFit
+	warnings   []string
+	hedged     bool            // Opened as the hedge of a slow leg
+	overlap    *overlapTrimmer // Drops what a resumed leg repeats of the partial answer
+	// Usage reported by the provider, or counted for an estimate
+	usage           *Usage
+	promptTokens    int
+	completionChars int
+}
+
+// breakerKey returns the circuit breaker key for the leg
+func (l *streamLeg) breakerKey() string {
+	return breakerKey(l.userID, l.connection, l.model)
+}
+
+// open starts the leg's stream under its own cancellable context, traced by
+// a provider span that ends when the leg is closed. The leg's circuit breaker
+// must admit the call; a failure to open counts against it.
+func (g *Gateway) openLeg(ctx context.Context, l *streamLeg, provider Provider, req *Request) error {
+	record, err := g.circuitBreaker.Allow(l.breakerKey())
+	if err != nil {
+		return err
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	streamCtx, span := g.startProviderSpan(streamCtx, "provider.stream", l.userID, l.connection, req.Model)
+	done := g.router.BeginCall(l.userID, l.connection)
+	stream, err := provider.StreamComplete(streamCtx, req)
+	if err != nil {
+		tracing.RecordError(span, err)
+		span.End()
+		done(err)
+		record(err)
+		cancel()
+		return err
+	}
+	l.stream = stream
+	l.cancel = cancel
+	l.span = span
+	l.done = done
+	l.breaker = record
+	l.opened = time.Now()
+	l.promptTokens = estimatePromptTokens(req.Messages, req.Tools)
+	return nil
+}
+
+// usageEstimate returns the usage reported by the provider, or an estimate
+// from the prompt and the streamed content when none was reported
+func (l *streamLeg) usageEstimate() Usage {
+	if l.usage != nil {
+		return *l.usage
+	}
+	completion := (l.completionChars + 3) / 4
+	return Usage{
+		PromptTokens:     l.promptTokens,
+		CompletionTokens: completion,
+		TotalTokens:      l.promptTokens + completion,
+	}
+}
+
+// finish records how the leg's stream ended and closes it
+func (l *streamLeg) finish(err error) {
+	if l.span != nil {
+		tracing.RecordError(l.span, err)
+	}
+	if l.done != nil {
+		l.done(err)
+	}
+	l.close()
+}
+
+// settle records how the leg's stream ended with its circuit breaker, once
+func (l *streamLeg) settle(err error) {
+	if l.breaker != nil {
+		l.breaker(err)
+		l.breaker = nil
+	}
+}
+
+// close cancels the leg and drains whatever the provider still sends. A leg
+// closed before it finished says nothing about the connection's health.
+func (l *streamLeg) close() {
+	if l.cancel != nil {
+		l.cancel()
+	}
+	l.settle(context.Canceled)
+	if l.done != nil {
+		l.done(context.Canceled)
+		l.done = nil
+	}
+	if l.span != nil {
+		l.span.End()
+		l.span = nil
+	}
+	if l.stream != nil {
+		go func(stream <-chan *StreamChunk) {
+			for range stream {
+			}
+		}(l.stream)
+		l.stream = nil
+	}
+}
+
+// pumpStream forwards chunks from a leg until it ends, errors or stalls
+func (g *Gateway) pumpStream(ctx context.Context, leg *streamLeg, out chan<- *StreamChunk, partial *strings.Builder) error {
+	idle := time.NewTimer(g.streamIdleTimeout)
+	defer idle.Stop()
+
+	// Tool calls are assembled per leg; a failover discards unfinished ones
+	tools := &toolCallAssembler{}
+	send := func(chunks ...*StreamChunk) error {
+		for _, chunk := range chunks {
+			chunk.Model = leg.model
+			chunk.Provider = leg.provider
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case chunk, ok := <-leg.stream:
+			if !ok {
+				if leg.overlap != nil {
+					if rest := leg.overlap.Flush(); rest != "" {
+						partial.WriteString(rest)
+						if err := send(&StreamChunk{Type: "content", Content: rest}); err != nil {
+							return err
+						}
+					}
+				}
+				return send(tools.Finish()...)
+			}
+			if chunk.Type == "error" || chunk.Error != nil {
+				if chunk.Error == nil {
+					chunk.Error = fmt.Errorf("stream error from %s", leg.provider)
+				}
+				return chunk.Error
+			}
+
+			if !idle.Stop() {
+				select {
+				case <-idle.C:
+				default:
+				}
+			}
+			idle.Reset(g.streamIdleTimeout)
+
+			// Time to first chunk is the latency that matters for streams
+			if !leg.firstChunk {
+				leg.firstChunk = true
+				g.router.ObserveLatency(leg.userID, leg.connection, leg.model, time.Since(leg.opened))
+
+				// Report context trimming once per leg
+				if leg.fit.Trimmed() {
+					if chunk.Metadata == nil {
+						chunk.Metadata = make(map[string]interface{})
+					}
+					chunk.Metadata["tokens_trimmed"] = leg.fit.TokensTrimmed
+					chunk.Metadata["context_strategy"] = string(leg.fit.Strategy)
+				}
+				if len(leg.warnings) > 0 {
+					if chunk.Metadata == nil {
+						chunk.Metadata = make(map[string]interface{})
+					}
+					chunk.Metadata["budget_warnings"] = leg.warnings
+				}
+				if leg.hedged {
+					if chunk.Metadata == nil {
+						chunk.Metadata = make(map[string]interface{})
+					}
+					chunk.Metadata[MetadataHedged] = true
+				}
+			}
+
+			// Replace raw tool-call fragments with typed events
+			var events []*StreamChunk
+			for i := range chunk.Choices {
+				choice := &chunk.Choices[i]
+				if len(choice.Delta.ToolCalls) > 0 {
+					events = append(events, tools.Add(choice.Delta.ToolCalls)...)
+					choice.Delta.ToolCalls = nil
+				}
+				if choice.FinishReason != "" {
+					events = append(events, tools.Finish()...)
+				}
+			}
+			for _, event := range events {
+				if event.Type == StreamToolCallDelta {
+					leg.completionChars += len(event.ToolCall.Function.Arguments)
+				}
+			}
+
+			// Drop what a resumed leg repeats of the answer so far
+			if leg.overlap != nil && !leg.overlap.TrimChunk(chunk) && len(events) == 0 {
+				continue
+			}
+			partial.WriteString(chunk.Content)
+			leg.completionChars += len(chunk.Content) + len(chunk.Reasoning)
+			if chunk.Usage != nil {
+				leg.usage = chunk.Usage
+			}
+
+			// Send chunk, then the tool-call events it produced
+			if err := send(append([]*StreamChunk{chunk}, events...)...); err != nil {
+				return err
+			}
+
+		case <-idle.C:
+			return fmt.Errorf("%w: no data for %s", ErrStreamStalled, g.streamIdleTimeout)
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// resumeStream opens the next usable provider in the fallback chain, asking
+// it to continue from the partial output the failed provider already produced.
+// Entries are consumed from the chain as they are tried.
+func (g *Gateway) resumeStream(ctx context.Context, req *Request, failed *streamLeg, partial string, fallbacks *[]FallbackTarget) (*streamLeg, error) {
+	for len(*fallbacks) > 0 {
+		fallback := (*fallbacks)[0]
+		*fallbacks = (*fallbacks)[1:]
+
+		config, _ := g.providers.GetConfig(req.UserID, fallback.Key)
+		continuation, overlap := continuationRequest(req, partial, supportsPrefill(config))
+		continuation.Model = req.Model
+		if fallback.Model != "" {
+			continuation.Model = fallback.Model
+		}
+
+		checked, err := g.applyFallbackMiddleware(ctx, continuation, fallback)
+		if err != nil {
+			fmt.Printf("[Gateway] Fallback %s refused: %v\n", fallback.Key, err)
+			continue
+		}
+		fitted, fit := g.fitContext(ctx, checked, fallback.Provider, fallback.Key)
+		next := &streamLeg{
+			provider:   fallback.Key,
+			model:      fitted.Model,
+			userID:     req.UserID,
+			connection: fallback.ConnectionID(req.UserID),
+			fit:        fit,
+			overlap:    overlap,
+		}
+		if err := g.openLeg(ctx, next, fallback.Provider, fitted); err != nil {
+			fmt.Printf("[Gateway] Fallback %s failed to open: %v\n", fallback.Key, err)
+			continue
+		}
+
+		fmt.Printf("[Gateway] Resuming stream on fallback %s\n", fallback.Key)
+		g.observeFallback(failed.userID, failed.connection, failed.model)
+		return next, nil
+	}
+
+	return nil, fmt.Errorf("no fallback available for %s", failed.provider)
+}
+
+// route picks the provider for a request under its own span
+func (g *Gateway) route(ctx context.Context, req *Request) (Provider, *RouteInfo, error) {
+	ctx, span := tracing.Start(ctx, "gateway.route")
+	defer span.End()
+
+	provider, routeInfo, err := g.router.Route(ctx, req)
+	tracing.RecordError(span, err)
+	traceRoute(span, req.UserID, routeInfo)
+	return provider, routeInfo, err
+}
+
+// retryPolicyFor returns the retry policy for a routed connection
+func (g *Gateway) retryPolicyFor(userID, connectionID string) RetryPolicy {
+	policy := g.retryPolicy
+	if config, ok := g.providers.GetConfig(userID, connectionID); ok {
+		policy = policy.ForConfig(config)
+	}
+	return policy
+}
+
+// withRetry runs fn until it succeeds, fails permanently or the policy is
+// exhausted, and returns the number of retries performed
+func (g *Gateway) withRetry(ctx context.Context, policy RetryPolicy, fn func(context.Context) error) (int, error) {
+	retries := 0
+	for {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if policy.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		}
+		err := fn(attemptCtx)
+		cancel()
+
+		if err == nil || retries >= policy.MaxRetries || ctx.Err() != nil || !IsRetryableError(err) {
+			return retries, err
+		}
+
+		delay := policy.Backoff(retries, err)
+		fmt.Printf("[Gateway] Transient error (attempt %d/%d), retrying in %s: %v\n",
+			retries+1, policy.MaxRetries+1, delay, err)
+		if sleepErr := sleepContext(ctx, delay); sleepErr != nil {
+			return retries, err
+		}
+		retries++
+	}
+}
+
 // RegisterProvider registers a provider for a user connection
 func (g *Gateway) RegisterProvider(userID, connectionID string, config ProviderConfig) error {
-	return g.providers.RegisterProvider(userID, connectionID, config)
+	if err := g.providers.RegisterProvider(userID, connectionID, config); err != nil {
+		return err
+	}
+	// Existing breakers of the connection pick up changed settings
+	g.circuitBreaker.ReloadSettings(g.router.connectionKey(userID, connectionID) + ":")
+	return nil
 }
 
 // RemoveProvider removes a provider registration
@@ -245,6 +898,115 @@ func (g *Gateway) RemoveProvider(userID, connectionID string) error {
 	return g.providers.RemoveProvider(userID, connectionID)
 }
 
+// PricingCatalog returns the catalog used to price calls and route by cost
+func (g *Gateway) PricingCatalog() *PricingCatalog {
+	return g.router.PricingCatalog()
+}
+
+// ContextBudgeter returns the budgeter that fits prompts to context windows
+func (g *Gateway) ContextBudgeter() *ContextBudgeter {
+	return g.contextBudgeter
+}
+
+// applyRouteMiddleware runs middleware that acts on the routing decision
+func (g *Gateway) applyRouteMiddleware(ctx context.Context, req *Request, route *RouteInfo) (*Request, error) {
+	for _, mw := range g.middleware {
+		if rm, ok := mw.(RouteMiddleware); ok {
+			var err error
+			req, err = rm.PostRoute(ctx, req, route)
+			if err != nil {
+				return req, err
+			}
+		}
+	}
+	return req, nil
+}
+
+// applyFallbackMiddleware runs the middleware that vets the connections a
+// request fails over or is hedged to, such as their budgets
+func (g *Gateway) applyFallbackMiddleware(ctx context.Context, req *Request, fallback FallbackTarget) (*Request, error) {
+	route := &RouteInfo{
+		Provider:     fallback.Key,
+		ConnectionID: fallback.ConnectionID(req.UserID),
+		Model:        req.Model,
+		Reason:       "fallback",
+	}
+	for _, mw := range g.middleware {
+		if fm, ok := mw.(FallbackMiddleware); ok {
+			var err error
+			req, err = fm.PostFallback(ctx, req, route)
+			if err != nil {
+				return req, err
+			}
+		}
+	}
+	return req, nil
+}
+
+// SetBudgetStore sets where budgets and spend are loaded from
+func (g *Gateway) SetBudgetStore(store BudgetStore) {
+	g.budgets.SetStore(store)
+}
+
+// InvalidateBudgets drops cached budgets after they change
+func (g *Gateway) InvalidateBudgets() {
+	g.budgets.Invalidate()
+}
+
+// SetCacheStore sets where cached responses are kept
+func (g *Gateway) SetCacheStore(store CacheStore) {
+	g.cache.SetStore(store)
+}
+
+// SetCacheEmbedder sets how prompts are embedded for semantic caching
+func (g *Gateway) SetCacheEmbedder(embedder Embedder) {
+	g.cache.SetEmbedder(embedder)
+}
+
+// budgetWarnings returns the soft-limit warnings budget middleware attached
+// to the request
+func budgetWarnings(req *Request) []string {
+	warnings, _ := req.Metadata[MetadataBudgetWarnings].([]string)
+	return warnings
+}
+
+// fitContext trims the request to the context window of its model on a
+// connection. Models not known by name have the connection's model list
+// consulted for the window it reports. A trimmed copy is returned so callers
+// keep their full message history.
+func (g *Gateway) fitContext(ctx context.Context, req *Request, provider Provider, connectionID string) (*Request, ContextFit) {
+	if g.contextBudgeter == nil {
+		return req, ContextFit{}
+	}
+
+	model := req.Model
+	if model != "" && provider != nil && !g.contextBudgeter.knowsModel(model) {
+		g.router.learnContextWindows(ctx, req.UserID, connectionID, provider)
+	}
+
+	messages, fit := g.contextBudgeter.Fit(ctx, req, model)
+	if !fit.Trimmed() {
+		return req, fit
+	}
+
+	fmt.Printf("[Gateway] Trimmed %d tokens (%d messages) to fit model=%s window=%d using %s\n",
+		fit.TokensTrimmed, fit.Dropped, model, fit.Window, fit.Strategy)
+	fitted := req.Clone()
+	fitted.Messages = messages
+	return fitted, fit
+}
+
+// SetRoutingStore sets where declarative routing rules and fallback chains are loaded from
+func (g *Gateway) SetRoutingStore(store RoutingStore) {
+	g.router.SetRoutingStore(store)
+}
+
+// InvalidateRouting drops the cached routing configuration of a user, or of
+// all users when userID is empty
+func (g *Gateway) InvalidateRouting(userID string) {
+	g.router.InvalidateRouting(userID)
+}
+
 // GetAvailableModels returns all available models
 func (g *Gateway) GetAvailableModels(ctx context.Context, userID string) ([]ModelInfo, error) {
 	return g.providers.GetAvailableModels(ctx, userID)
@@ -268,6 +1030,12 @@ func (g *Gateway) Shutdown(ctx context.Context) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	// Stop probing breakers
+	if g.probeStop != nil {
+		close(g.probeStop)
+		g.probeStop = nil
+	}
+
 	// Shutdown providers
 	if err := g.providers.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown providers: %w", err)
@@ -279,4 +1047,4 @@ func (g *Gateway) Shutdown(ctx context.Context) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
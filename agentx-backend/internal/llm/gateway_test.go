@@ -0,0 +1,202 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProvider streams a fixed set of chunks and optionally fails or stalls
+type fakeProvider struct {
+	chunks  []string
+	failErr error
+	stall   bool
+	models  []string
+	windows map[string]int // Context lengths reported with the models
+	vision  bool
+	lastReq *Request
+}
+
+func (p *fakeProvider) Complete(ctx context.Context, req *Request) (*Response, error) {
+	p.lastReq = req
+	if p.failErr != nil {
+		return nil, p.failErr
+	}
+	return &Response{Choices: []Choice{{Message: Message{Role: "assistant", Content: "ok"}}}}, nil
+}
+
+func (p *fakeProvider) StreamComplete(ctx context.Context, req *Request) (<-chan *StreamChunk, error) {
+	p.lastReq = req
+	out := make(chan *StreamChunk)
+	go func() {
+		defer close(out)
+		for _, c := range p.chunks {
+			out <- &StreamChunk{Type: "content", Content: c}
+		}
+		if p.stall {
+			<-ctx.Done()
+			return
+		}
+		if p.failErr != nil {
+			out <- &StreamChunk{Type: "error", Error: p.failErr}
+		}
+	}()
+	return out, nil
+}
+
+func (p *fakeProvider) GetModels(ctx context.Context) ([]ModelInfo, error) {
+	var models []ModelInfo
+	for _, id := range p.models {
+		models = append(models, ModelInfo{ID: id, ContextLength: p.windows[id]})
+	}
+	return models, nil
+}
+func (p *fakeProvider) HealthCheck(ctx context.Context) error { return nil }
+func (p *fakeProvider) GetCapabilities() ProviderCapabilities {
+	return ProviderCapabilities{Streaming: true, FunctionCalling: true, Vision: p.vision}
+}
+func (p *fakeProvider) Close() error { return nil }
+
+// fakeFactory hands out pre-built providers by config name
+type fakeFactory map[string]Provider
+
+func (f fakeFactory) CreateProvider(config ProviderConfig) (Provider, error) {
+	return f[config.Name], nil
+}
+
+func newTestGateway(t *testing.T, providers fakeFactory) *Gateway {
+	g := NewGateway(WithMiddleware(&BaseMiddleware{}), WithStreamIdleTimeout(50*time.Millisecond))
+	g.providers.factory = providers
+	for name := range providers {
+		assert.NoError(t, g.RegisterProvider("user", name, ProviderConfig{Name: name}))
+	}
+	return g
+}
+
+func collect(stream <-chan *StreamChunk) []*StreamChunk {
+	var chunks []*StreamChunk
+	for c := range stream {
+		chunks = append(chunks, c)
+	}
+	return chunks
+}
+
+func TestStreamCompleteFailsOverMidStream(t *testing.T) {
+	primary := &fakeProvider{chunks: []string{"Hello, "}, failErr: errors.New("connection reset")}
+	fallback := &fakeProvider{chunks: []string{"world"}}
+	g := newTestGateway(t, fakeFactory{"primary": primary, "fallback": fallback})
+	g.router.SetFallback("primary", "user:fallback")
+
+	stream, err := g.StreamComplete(context.Background(), &Request{
+		UserID:       "user",
+		ConnectionID: "primary",
+		Messages:     []Message{{Role: "user", Content: "hi"}},
+		Stream:       true,
+	})
+	assert.NoError(t, err)
+
+	chunks := collect(stream)
+	var content string
+	var failovers int
+	for _, c := range chunks {
+		content += c.Content
+		if c.Type == "failover" {
+			failovers++
+			assert.Equal(t, "primary", c.Metadata["from_provider"])
+		}
+	}
+	assert.Equal(t, "Hello, world", content)
+	assert.Equal(t, 1, failovers)
+
+	// The fallback is asked to continue from the partial answer
+	messages := fallback.lastReq.Messages
+	assert.Equal(t, Message{Role: "assistant", Content: "Hello, "}, messages[len(messages)-2])
+	assert.Equal(t, Message{Role: "user", Content: resumeInstruction}, messages[len(messages)-1])
+}
+
+func TestStreamCompleteResumesWithoutRepeating(t *testing.T) {
+	primary := &fakeProvider{chunks: []string{"The quick brown fox "}, failErr: errors.New("connection reset")}
+	fallback := &fakeProvider{chunks: []string{"brown ", "fox jumps", " over the lazy dog."}}
+	prefilled := &fakeProvider{chunks: []string{"jumps over the lazy dog."}}
+	g := newTestGateway(t, fakeFactory{"primary": primary, "fallback": fallback, "prefilled": prefilled})
+	assert.NoError(t, g.RegisterProvider("user", "prefilled", ProviderConfig{Name: "prefilled", Type: "anthropic"}))
+
+	stream := func(fallbackKey string) string {
+		g.router.SetFallback("primary", fallbackKey)
+		stream, err := g.StreamComplete(context.Background(), &Request{
+			UserID:       "user",
+			ConnectionID: "primary",
+			Messages:     []Message{{Role: "user", Content: "hi"}},
+			Stream:       true,
+		})
+		assert.NoError(t, err)
+		var content string
+		for _, c := range collect(stream) {
+			content += c.Content
+		}
+		return content
+	}
+
+	// The repeated end of the partial answer is dropped
+	assert.Equal(t, "The quick brown fox jumps over the lazy dog.", stream("user:fallback"))
+
+	// Providers that support it are prefilled with the partial answer instead
+	assert.Equal(t, "The quick brown fox jumps over the lazy dog.", stream("user:prefilled"))
+	last := prefilled.lastReq.Messages[len(prefilled.lastReq.Messages)-1]
+	assert.Equal(t, Message{Role: "assistant", Content: "The quick brown fox"}, last)
+}
+
+func TestStreamCompleteDetectsStall(t *testing.T) {
+	primary := &fakeProvider{chunks: []string{"partial"}, stall: true}
+	g := newTestGateway(t, fakeFactory{"primary": primary})
+
+	stream, err := g.StreamComplete(context.Background(), &Request{
+		UserID:       "user",
+		ConnectionID: "primary",
+		Messages:     []Message{{Role: "user", Content: "hi"}},
+		Stream:       true,
+	})
+	assert.NoError(t, err)
+
+	chunks := collect(stream)
+	last := chunks[len(chunks)-1]
+	assert.Equal(t, "error", last.Type)
+	assert.ErrorIs(t, last.Error, ErrStreamStalled)
+}
+
+func TestStreamCompleteRespectsOpenBreaker(t *testing.T) {
+	primary := &fakeProvider{chunks: []string{"Hello, "}, failErr: errors.New("connection reset")}
+	fallback := &fakeProvider{chunks: []string{"world"}}
+	g := newTestGateway(t, fakeFactory{"primary": primary, "fallback": fallback})
+	config := ProviderConfig{Name: "primary"}
+	config.ApplyConnectionSettings(map[string]interface{}{
+		"circuit_breaker": map[string]interface{}{"min_requests": float64(1)},
+	})
+	assert.NoError(t, g.RegisterProvider("user", "primary", config))
+	g.router.SetFallback("primary", "user:fallback")
+
+	req := &Request{
+		UserID:       "user",
+		ConnectionID: "primary",
+		Messages:     []Message{{Role: "user", Content: "hi"}},
+		Stream:       true,
+	}
+	stream, err := g.StreamComplete(context.Background(), req.Clone())
+	assert.NoError(t, err)
+	collect(stream)
+	assert.Equal(t, StateOpen, g.circuitBreaker.GetState(breakerKey("user", "primary", "")))
+
+	// The open breaker keeps the next stream off the failing connection
+	primary.lastReq = nil
+	stream, err = g.StreamComplete(context.Background(), req.Clone())
+	assert.NoError(t, err)
+	var content string
+	for _, c := range collect(stream) {
+		content += c.Content
+	}
+	assert.Equal(t, "world", content)
+	assert.Nil(t, primary.lastReq)
+}
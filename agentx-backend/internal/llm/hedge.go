@@ -0,0 +1,357 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Attempts of a hedged request
+const (
+	HedgePrimary = "primary"
+	HedgeSecond  = "hedge"
+)
+
+// Outcomes recorded for each attempt of a hedged request
+const (
+	HedgeWon       = "won"
+	HedgeCancelled = "cancelled"
+	HedgeFailed    = "failed"
+)
+
+// MetadataHedged marks the first chunk of a stream answered by a hedge
+const MetadataHedged = "hedged"
+
+// hedgeOutcome describes a request that was hedged to a second connection
+type hedgeOutcome struct {
+	target string
+	winner string // HedgePrimary, HedgeSecond or empty when both failed
+}
+
+// hedgeAttempt is the result of one attempt of a hedged completion
+type hedgeAttempt struct {
+	attempt string
+	resp    *Response
+	req     *Request
+	fit     ContextFit
+	retries int
+	err     error
+}
+
+// hedgeTarget returns the connection a latency-sensitive request is hedged
+// to: the first entry of the routed connection's fallback chain whose circuit
+// is not open. Requests without Requirements.MaxLatency are never hedged.
+func (g *Gateway) hedgeTarget(ctx context.Context, req *Request, route *RouteInfo) (FallbackTarget, bool) {
+	if req.Requirements.MaxLatency <= 0 {
+		return FallbackTarget{}, false
+	}
+	for _, target := range g.router.FallbackChain(ctx, req, route.ConnectionID) {
+		model := target.Model
+		if model == "" {
+			model = req.Model
+		}
+		if g.circuitBreaker.GetState(breakerKey(req.UserID, target.Key, model)) == StateOpen {
+			continue
+		}
+		return target, true
+	}
+	return FallbackTarget{}, false
+}
+
+// hedgeComplete calls the routed provider and, if it has not answered within
+// Requirements.MaxLatency, sends the same request to target. The first
+// successful answer wins and the other attempt is cancelled. If both fail the
+// primary's error is returned.
+func (g *Gateway) hedgeComplete(ctx context.Context, req *Request, provider Provider, target FallbackTarget, call *completionCall) (*Response, error) {
+	routeInfo := call.route
+	delay := req.Requirements.MaxLatency
+	results := make(chan hedgeAttempt, 2)
+
+	primaryCtx, cancelPrimary := context.WithCancel(ctx)
+	defer cancelPrimary()
+	primaryStart := time.Now()
+	go func() {
+		a := hedgeAttempt{attempt: HedgePrimary, req: req}
+		a.resp, a.retries, a.err = g.completeRoute(primaryCtx, req, provider, routeInfo)
+		results <- a
+	}()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case a := <-results:
+		call.retries = a.retries
+		return a.resp, a.err
+	case <-timer.C:
+	}
+
+	fmt.Printf("[Gateway] No response from %s within %s, hedging to %s\n", routeInfo.Provider, delay, target.Key)
+	hedgeCtx, cancelHedge := context.WithCancel(ctx)
+	defer cancelHedge()
+	hedgeStart := time.Now()
+	go func() {
+		a := hedgeAttempt{attempt: HedgeSecond}
+		// Providers may adjust the request, so the hedge gets its own copy
+		a.resp, a.req, a.fit, a.err = g.completeFallback(hedgeCtx, req.Clone(), target)
+		results <- a
+	}()
+
+	// Take the first success, or wait for both attempts to fail
+	attempts := make(map[string]hedgeAttempt, 2)
+	var winner *hedgeAttempt
+	for len(attempts) < 2 && winner == nil {
+		a := <-results
+		attempts[a.attempt] = a
+		if a.err == nil {
+			winner = &a
+		}
+	}
+
+	primary, primaryDone := attempts[HedgePrimary]
+	hedgeModel := req.Model
+	if target.Model != "" {
+		hedgeModel = target.Model
+	}
+	primaryResult := hedgeAttemptOutcome(attempts, HedgePrimary, winner)
+	secondResult := hedgeAttemptOutcome(attempts, HedgeSecond, winner)
+	g.observeHedge(req.UserID, routeInfo.ConnectionID, routeInfo.Model, HedgePrimary, primaryResult, time.Since(primaryStart))
+	g.observeHedge(req.UserID, target.Key, hedgeModel, HedgeSecond, secondResult, time.Since(hedgeStart))
+
+	call.hedge = &hedgeOutcome{target: target.Key}
+	if primaryDone {
+		call.retries = primary.retries
+	}
+	if winner == nil {
+		return nil, primary.err
+	}
+
+	// The winner is recorded with the call. A cancelled loser never reports
+	// its usage, but its prompt was sent all the same.
+	prompt := estimatePromptTokens(req.Messages, req.Tools)
+	promptOnly := Usage{PromptTokens: prompt, TotalTokens: prompt}
+	call.hedge.winner = winner.attempt
+	if winner.attempt == HedgePrimary {
+		g.recordHedgeLoser(ctx, req, target.Key, target.Key, hedgeModel, false, secondResult, promptOnly, time.Since(hedgeStart))
+	}
+	if winner.attempt == HedgeSecond {
+		g.recordHedgeLoser(ctx, req, routeInfo.ConnectionID, routeInfo.Provider, routeInfo.Model, false, primaryResult, promptOnly, time.Since(primaryStart))
+		call.fit.TokensTrimmed += winner.fit.TokensTrimmed
+		call.fit.Strategy = winner.fit.Strategy
+		call.route = &RouteInfo{
+			Provider:     target.Key,
+			ConnectionID: target.ConnectionID(req.UserID),
+			Model:        winner.req.Model,
+			Reason:       fmt.Sprintf("hedged after %s gave no response within %s (%s)", routeInfo.Provider, delay, routeInfo.Reason),
+		}
+	}
+	return winner.resp, nil
+}
+
+// hedgeAttemptOutcome classifies one attempt of a hedged completion
+func hedgeAttemptOutcome(attempts map[string]hedgeAttempt, attempt string, winner *hedgeAttempt) string {
+	if winner != nil && winner.attempt == attempt {
+		return HedgeWon
+	}
+	if a, ok := attempts[attempt]; ok && a.err != nil {
+		return HedgeFailed
+	}
+	return HedgeCancelled
+}
+
+// hedgeStream waits up to Requirements.MaxLatency for the leg's first chunk.
+// If none arrives the request is also streamed from target, and whichever leg
+// produces content first is returned; the other is closed. The returned leg
+// replays the chunk it was raced on. If both legs fail, neither answers within
+// the stream idle timeout or the caller goes away, the primary leg is returned
+// so the caller fails over or stops as usual. fired reports whether the hedge
+// was sent.
+func (g *Gateway) hedgeStream(ctx context.Context, req *Request, leg *streamLeg, target FallbackTarget) (kept *streamLeg, fired bool) {
+	delay := req.Requirements.MaxLatency
+	primaryFirst := leg.peek()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case first := <-primaryFirst:
+		leg.unread(replay(first))
+		return leg, false
+	case <-timer.C:
+	case <-ctx.Done():
+		leg.unread(primaryFirst)
+		return leg, false
+	}
+
+	hedgeReq := req.Clone()
+	if target.Model != "" {
+		hedgeReq.Model = target.Model
+	}
+	hedgeReq, err := g.applyFallbackMiddleware(ctx, hedgeReq, target)
+	if err != nil {
+		fmt.Printf("[Gateway] Not hedging stream to %s: %v\n", target.Key, err)
+		leg.unread(primaryFirst)
+		return leg, false
+	}
+	hedgeReq, fit := g.fitContext(ctx, hedgeReq, target.Provider, target.Key)
+	hedge := &streamLeg{
+		provider:   target.Key,
+		model:      hedgeReq.Model,
+		userID:     req.UserID,
+		connection: target.ConnectionID(req.UserID),
+		fit:        fit,
+		warnings:   leg.warnings,
+		hedged:     true,
+	}
+	fmt.Printf("[Gateway] No first token from %s within %s, hedging stream to %s\n", leg.provider, delay, target.Key)
+	hedgeStart := time.Now()
+	pending := map[*streamLeg]<-chan legChunk{leg: primaryFirst}
+	if err := g.openLeg(ctx, hedge, target.Provider, hedgeReq); err == nil {
+		pending[hedge] = hedge.peek()
+	}
+
+	// Keep the first leg to produce content, or the primary if both fail
+	deadline := time.NewTimer(g.streamIdleTimeout)
+	defer deadline.Stop()
+	reported := make(map[*streamLeg]legChunk, 2)
+	var winner *streamLeg
+race:
+	for len(pending) > 0 && winner == nil {
+		var first legChunk
+		select {
+		case first = <-pending[leg]:
+		case first = <-pending[hedge]:
+		case <-deadline.C:
+			fmt.Printf("[Gateway] No first token from %s or %s within %s\n", leg.provider, hedge.provider, g.streamIdleTimeout)
+			break race
+		case <-ctx.Done():
+			break race
+		}
+		delete(pending, first.leg)
+		reported[first.leg] = first
+		if first.content() {
+			winner = first.leg
+		}
+	}
+
+	// Put back what was read, including chunks still on their way, before
+	// the losing leg is closed
+	for l, first := range reported {
+		l.unread(replay(first))
+	}
+	for l, first := range pending {
+		l.unread(first)
+	}
+
+	g.observeHedge(leg.userID, leg.connection, leg.model, HedgePrimary, hedgeLegOutcome(leg, reported, winner), time.Since(leg.opened))
+	g.observeHedge(hedge.userID, hedge.connection, hedge.model, HedgeSecond, hedgeLegOutcome(hedge, reported, winner), time.Since(hedgeStart))
+	kept, lost := leg, hedge
+	if winner == hedge {
+		kept, lost = hedge, leg
+	}
+	if lost.stream != nil {
+		g.recordHedgeLoser(ctx, req, lost.connection, lost.provider, lost.model, true, hedgeLegOutcome(lost, reported, winner), lost.usageEstimate(), time.Since(lost.opened))
+	}
+	lost.close()
+	return kept, true
+}
+
+// legChunk is the first thing read from a leg's stream
+type legChunk struct {
+	leg   *streamLeg
+	chunk *StreamChunk
+	ok    bool
+}
+
+// content reports whether the chunk carries part of an answer rather than an
+// error or the end of the stream
+func (c legChunk) content() bool {
+	return c.ok && c.chunk.Type != "error" && c.chunk.Error == nil
+}
+
+// peek reads the first chunk of the leg's stream in the background. The
+// stream is captured because the leg may be closed while peek waits.
+func (l *streamLeg) peek() <-chan legChunk {
+	first := make(chan legChunk, 1)
+	go func(stream <-chan *StreamChunk) {
+		chunk, ok := <-stream
+		first <- legChunk{leg: l, chunk: chunk, ok: ok}
+	}(l.stream)
+	return first
+}
+
+// replay returns a first chunk that was already read as a pending one
+func replay(first legChunk) <-chan legChunk {
+	pending := make(chan legChunk, 1)
+	pending <- first
+	return pending
+}
+
+// unread puts the chunk peeked off the leg's stream back in front of it,
+// waiting for it if it has not arrived yet
+func (l *streamLeg) unread(first <-chan legChunk) {
+	rest := l.stream
+	stream := make(chan *StreamChunk)
+	go func() {
+		defer close(stream)
+		peeked := <-first
+		if !peeked.ok {
+			return
+		}
+		stream <- peeked.chunk
+		for chunk := range rest {
+			stream <- chunk
+		}
+	}()
+	l.stream = stream
+}
+
+// hedgeLegOutcome classifies one leg of a hedged stream from the first
+// chunks read before the race was decided
+func hedgeLegOutcome(leg *streamLeg, reported map[*streamLeg]legChunk, winner *streamLeg) string {
+	if leg == winner {
+		return HedgeWon
+	}
+	if first, ok := reported[leg]; ok && !first.content() || leg.stream == nil {
+		return HedgeFailed
+	}
+	return HedgeCancelled
+}
+
+// withoutFallback drops a connection from a fallback chain
+func withoutFallback(targets []FallbackTarget, key string) []FallbackTarget {
+	kept := make([]FallbackTarget, 0, len(targets))
+	for _, target := range targets {
+		if target.Key != key {
+			kept = append(kept, target)
+		}
+	}
+	return kept
+}
+
+// recordHedgeLoser records the attempt that lost a hedged request in the
+// usage ledger. A failed attempt used nothing; a cancelled one is charged
+// usage, which the caller estimates.
+func (g *Gateway) recordHedgeLoser(ctx context.Context, req *Request, connectionID, provider, model string, stream bool, outcome string, usage Usage, latency time.Duration) {
+	if outcome == HedgeFailed {
+		usage = Usage{}
+	}
+	g.recordUsage(ctx, UsageRecord{
+		UserID:       req.UserID,
+		ConnectionID: connectionID,
+		Provider:     provider,
+		Model:        model,
+		APIKeyID:     req.APIKeyID(),
+		TaskType:     req.TaskType(),
+		Stream:       stream,
+		Latency:      latency,
+		Error:        "hedge " + outcome,
+	}, usage)
+}
+
+// observeHedge records one attempt of a hedged request
+func (g *Gateway) observeHedge(userID, connectionID, model, attempt, outcome string, latency time.Duration) {
+	if g.metrics == nil {
+		return
+	}
+	provider, connection := g.connectionLabels(userID, connectionID)
+	g.metrics.ObserveHedge(provider, model, connection, attempt, outcome, latency)
+}
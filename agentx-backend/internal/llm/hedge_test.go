@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowProvider answers only after delay, reporting if it was cancelled first
+type slowProvider struct {
+	fakeProvider
+	delay     time.Duration
+	cancelled chan struct{}
+}
+
+func (p *slowProvider) Complete(ctx context.Context, req *Request) (*Response, error) {
+	select {
+	case <-time.After(p.delay):
+		return &Response{Choices: []Choice{{Message: Message{Role: "assistant", Content: "slow"}}}}, nil
+	case <-ctx.Done():
+		close(p.cancelled)
+		return nil, ctx.Err()
+	}
+}
+
+func hedgeRequest(maxLatency time.Duration) *Request {
+	return &Request{
+		UserID:       "user",
+		ConnectionID: "primary",
+		Messages:     []Message{{Role: "user", Content: "hi"}},
+		Requirements: Requirements{MaxLatency: maxLatency},
+	}
+}
+
+func TestCompleteHedgesSlowPrimary(t *testing.T) {
+	primary := &slowProvider{delay: time.Second, cancelled: make(chan struct{})}
+	g := newTestGateway(t, fakeFactory{"primary": primary, "backup": &fakeProvider{}})
+	g.router.SetFallback("primary", "user:backup")
+	recorder := &memoryRecorder{}
+	g.SetUsageRecorder(recorder)
+
+	resp, err := g.Complete(context.Background(), hedgeRequest(20*time.Millisecond))
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", resp.Content)
+	assert.True(t, resp.Metadata.Hedged)
+	assert.Equal(t, HedgeSecond, resp.Metadata.HedgeWinner)
+	assert.Equal(t, "backup", resp.Metadata.ConnectionID)
+
+	select {
+	case <-primary.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("losing primary was not cancelled")
+	}
+	// Cancelling the loser must not count against its circuit breaker
+	assert.Equal(t, StateClosed, g.circuitBreaker.GetState("user:primary:"))
+
+	hedges := g.GetMetrics()["hedges"].(map[string]map[string]int64)
+	assert.Equal(t, int64(1), hedges["primary:"][HedgePrimary+"_"+HedgeCancelled])
+	assert.Equal(t, int64(1), hedges["user:backup:"][HedgeSecond+"_"+HedgeWon])
+
+	// Both attempts are in the ledger; the loser with its prompt
+	if assert.Len(t, recorder.records, 2) {
+		loser := recorder.records[0]
+		assert.Equal(t, "primary", loser.ConnectionID)
+		assert.False(t, loser.Success)
+		assert.Equal(t, "hedge "+HedgeCancelled, loser.Error)
+		assert.Equal(t, estimatePromptTokens(hedgeRequest(0).Messages, nil), loser.PromptTokens)
+		assert.Equal(t, "backup", recorder.records[1].ConnectionID)
+		assert.True(t, recorder.records[1].Success)
+	}
+}
+
+func TestCompleteSkipsHedgeWhenPrimaryIsFast(t *testing.T) {
+	backup := &countingProvider{}
+	g := newTestGateway(t, fakeFactory{"primary": &fakeProvider{}, "backup": backup})
+	g.router.SetFallback("primary", "user:backup")
+
+	resp, err := g.Complete(context.Background(), hedgeRequest(time.Second))
+	assert.NoError(t, err)
+	assert.False(t, resp.Metadata.Hedged)
+	assert.Equal(t, "primary", resp.Metadata.ConnectionID)
+	assert.Equal(t, int32(0), backup.calls)
+}
+
+func TestStreamCompleteHedgesSlowFirstToken(t *testing.T) {
+	primary := &fakeProvider{stall: true}
+	backup := &fakeProvider{chunks: []string{"fast ", "answer"}}
+	g := newTestGateway(t, fakeFactory{"primary": primary, "backup": backup})
+	g.router.SetFallback("primary", "user:backup")
+	recorder := &memoryRecorder{}
+	g.SetUsageRecorder(recorder)
+
+	req := hedgeRequest(20 * time.Millisecond)
+	req.Stream = true
+	stream, err := g.StreamComplete(context.Background(), req)
+	assert.NoError(t, err)
+
+	chunks := collect(stream)
+	var content string
+	for _, c := range chunks {
+		content += c.Content
+		assert.NotEqual(t, "error", c.Type)
+	}
+	assert.Equal(t, "fast answer", content)
+	if assert.NotEmpty(t, chunks) {
+		assert.Equal(t, "user:backup", chunks[0].Provider)
+		assert.Equal(t, true, chunks[0].Metadata[MetadataHedged])
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if assert.Len(t, recorder.records, 2) {
+		loser := recorder.records[0]
+		assert.Equal(t, "primary", loser.ConnectionID)
+		assert.Equal(t, "hedge "+HedgeCancelled, loser.Error)
+		assert.Positive(t, loser.PromptTokens, "the closed leg is charged its prompt")
+		assert.Equal(t, "backup", recorder.records[1].ConnectionID)
+	}
+}
+
+func TestStreamCompleteHedgeGivesUpOnStalledLegs(t *testing.T) {
+	primary := &fakeProvider{stall: true}
+	backup := &fakeProvider{stall: true}
+	g := newTestGateway(t, fakeFactory{"primary": primary, "backup": backup})
+	g.router.SetFallback("primary", "user:backup")
+
+	req := hedgeRequest(20 * time.Millisecond)
+	req.Stream = true
+	stream, err := g.StreamComplete(context.Background(), req.Clone())
+	assert.NoError(t, err)
+	chunks := collect(stream)
+	if assert.NotEmpty(t, chunks) {
+		assert.ErrorIs(t, chunks[len(chunks)-1].Error, ErrStreamStalled)
+	}
+}
@@ -14,10 +14,6 @@ func InitializeGateway(providerRegistry *providers.Registry) (*Gateway, error) {
 		WithCircuitBreaker(NewCircuitBreaker()),
 	)
 	
-	// Configure router
-	gateway.router.SetProviderManager(gateway.providers)
-	gateway.router.SetConfigManager(gateway.config)
-	
 	// Add default routing rules
 	gateway.router.AddRoutingRule(RoutingRule{
 		Name:     "prefer-fast-models",
@@ -103,6 +99,8 @@ func determineProviderType(provider providers.Provider) string {
 		return "openai"
 	case "anthropic", "claude":
 		return "anthropic"
+	case "gemini", "google":
+		return "gemini"
 	case "ollama", "local", "llama":
 		return "local"
 	default:
@@ -0,0 +1,44 @@
+package llm
+
+import (
+	"sync"
+	"time"
+)
+
+// LatencyTracker keeps an exponentially weighted moving average of observed
+// latencies per connection and model
+type LatencyTracker struct {
+	alpha float64
+	stats map[string]time.Duration
+	mu    sync.RWMutex
+}
+
+// NewLatencyTracker creates a tracker where each observation carries 30% weight
+func NewLatencyTracker() *LatencyTracker {
+	return &LatencyTracker{
+		alpha: 0.3,
+		stats: make(map[string]time.Duration),
+	}
+}
+
+// Observe records a latency sample
+func (t *LatencyTracker) Observe(key string, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current, exists := t.stats[key]
+	if !exists {
+		t.stats[key] = latency
+		return
+	}
+	t.stats[key] = time.Duration(t.alpha*float64(latency) + (1-t.alpha)*float64(current))
+}
+
+// Estimate returns the smoothed latency for a key, if any samples exist
+func (t *LatencyTracker) Estimate(key string) (time.Duration, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	latency, exists := t.stats[key]
+	return latency, exists
+}
@@ -2,28 +2,44 @@ package llm
 
 import (
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/agentx/agentx-backend/internal/metrics"
 )
 
-// MetricsCollector collects metrics for LLM operations
+// MetricsCollector collects metrics for LLM operations. Calls, tokens, cost,
+// retries, fallbacks and hedges are also exported as Prometheus metrics.
 type MetricsCollector struct {
 	requests   map[string]int64
 	tokens     map[string]int64
 	costs      map[string]float64
-	latencies  map[string][]time.Duration
+	latencies  map[string]latencyTotal
 	errors     map[string]int64
+	hedges     map[string]map[string]int64
 	mu         sync.RWMutex
 }
 
+// latencyTotal accumulates latencies for averaging
+type latencyTotal struct {
+	sum   time.Duration
+	count int64
+}
+
+func (l latencyTotal) add(latency time.Duration) latencyTotal {
+	return latencyTotal{sum: l.sum + latency, count: l.count + 1}
+}
+
 // NewMetricsCollector creates a new metrics collector
 func NewMetricsCollector() *MetricsCollector {
 	return &MetricsCollector{
 		requests:  make(map[string]int64),
 		tokens:    make(map[string]int64),
 		costs:     make(map[string]float64),
-		latencies: make(map[string][]time.Duration),
+		latencies: make(map[string]latencyTotal),
 		errors:    make(map[string]int64),
+		hedges:    make(map[string]map[string]int64),
 	}
 }
 
@@ -39,14 +55,64 @@ func (mc *MetricsCollector) RecordRequest(provider, model string, success bool,
 		mc.errors[key]++
 	}
 	
-	mc.latencies[key] = append(mc.latencies[key], latency)
-	
-	// Keep only last 100 latencies
-	if len(mc.latencies[key]) > 100 {
-		mc.latencies[key] = mc.latencies[key][1:]
+	mc.latencies[key] = mc.latencies[key].add(latency)
+}
+
+// ObserveCall records a finished gateway call, as priced for the usage
+// ledger, in the snapshot and the Prometheus metrics
+func (mc *MetricsCollector) ObserveCall(record UsageRecord) {
+	mc.RecordRequest(record.Provider, record.Model, record.Success, record.Latency)
+
+	mc.mu.Lock()
+	mc.tokens[record.Provider] += int64(record.PromptTokens + record.CompletionTokens)
+	mc.costs[record.Provider] += record.Cost
+	mc.mu.Unlock()
+
+	stream := strconv.FormatBool(record.Stream)
+	status := "success"
+	if !record.Success {
+		status = "error"
+	}
+	metrics.GatewayRequests.WithLabelValues(record.Provider, record.Model, record.ConnectionID, stream, status).Inc()
+	metrics.GatewayRequestDuration.WithLabelValues(record.Provider, record.Model, record.ConnectionID, stream).Observe(record.Latency.Seconds())
+	if record.PromptTokens > 0 {
+		metrics.GatewayTokens.WithLabelValues(record.Provider, record.Model, record.ConnectionID, "prompt").Add(float64(record.PromptTokens))
+	}
+	if record.CompletionTokens > 0 {
+		metrics.GatewayTokens.WithLabelValues(record.Provider, record.Model, record.ConnectionID, "completion").Add(float64(record.CompletionTokens))
+	}
+	if record.Cost > 0 {
+		metrics.GatewayCost.WithLabelValues(record.Provider, record.Model, record.ConnectionID).Add(record.Cost)
+	}
+}
+
+// ObserveRetries records retries of transient failures on a connection
+func (mc *MetricsCollector) ObserveRetries(provider, model, connection string, retries int) {
+	if retries > 0 {
+		metrics.GatewayRetries.WithLabelValues(provider, model, connection).Add(float64(retries))
 	}
 }
 
+// ObserveFallback records a call moving off a failed connection
+func (mc *MetricsCollector) ObserveFallback(provider, model, connection string) {
+	metrics.GatewayFallbacks.WithLabelValues(provider, model, connection).Inc()
+}
+
+// ObserveHedge records one attempt of a hedged request: the primary or the
+// hedge, and whether it won, failed or was cancelled
+func (mc *MetricsCollector) ObserveHedge(provider, model, connection, attempt, outcome string, latency time.Duration) {
+	mc.mu.Lock()
+	key := provider + ":" + model
+	if mc.hedges[key] == nil {
+		mc.hedges[key] = make(map[string]int64)
+	}
+	mc.hedges[key][attempt+"_"+outcome]++
+	mc.mu.Unlock()
+
+	metrics.GatewayHedges.WithLabelValues(provider, model, connection, attempt, outcome).Inc()
+	metrics.GatewayHedgeDuration.WithLabelValues(provider, model, connection, attempt).Observe(latency.Seconds())
+}
+
 // RecordTokens records token usage
 func (mc *MetricsCollector) RecordTokens(provider string, tokens int) {
 	mc.mu.Lock()
@@ -68,12 +134,7 @@ func (mc *MetricsCollector) RecordLatency(provider string, latency time.Duration
 	mc.mu.Lock()
 	defer mc.mu.Unlock()
 	
-	mc.latencies[provider] = append(mc.latencies[provider], latency)
-	
-	// Keep only last 100 latencies
-	if len(mc.latencies[provider]) > 100 {
-		mc.latencies[provider] = mc.latencies[provider][1:]
-	}
+	mc.latencies[provider] = mc.latencies[provider].add(latency)
 }
 
 // RecordUsage records usage statistics
@@ -124,13 +185,9 @@ func (mc *MetricsCollector) GetSnapshot() map[string]interface{} {
 	
 	// Calculate average latencies
 	avgLatencies := make(map[string]float64)
-	for k, latencies := range mc.latencies {
-		if len(latencies) > 0 {
-			var total time.Duration
-			for _, l := range latencies {
-				total += l
-			}
-			avgLatencies[k] = float64(total.Milliseconds()) / float64(len(latencies))
+	for k, latency := range mc.latencies {
+		if latency.count > 0 {
+			avgLatencies[k] = float64(latency.sum.Milliseconds()) / float64(latency.count)
 		}
 	}
 	snapshot["avg_latency_ms"] = avgLatencies
@@ -142,6 +199,16 @@ func (mc *MetricsCollector) GetSnapshot() map[string]interface{} {
 	}
 	snapshot["errors"] = errors
 	
+	// Copy hedge outcomes
+	hedges := make(map[string]map[string]int64)
+	for k, outcomes := range mc.hedges {
+		hedges[k] = make(map[string]int64)
+		for outcome, v := range outcomes {
+			hedges[k][outcome] = v
+		}
+	}
+	snapshot["hedges"] = hedges
+	
 	return snapshot
 }
 
@@ -153,8 +220,9 @@ func (mc *MetricsCollector) Reset() {
 	mc.requests = make(map[string]int64)
 	mc.tokens = make(map[string]int64)
 	mc.costs = make(map[string]float64)
-	mc.latencies = make(map[string][]time.Duration)
+	mc.latencies = make(map[string]latencyTotal)
 	mc.errors = make(map[string]int64)
+	mc.hedges = make(map[string]map[string]int64)
 }
 
 // Flush flushes metrics (for persistent storage)
@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/agentx/agentx-backend/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompleteExportsPrometheusMetrics(t *testing.T) {
+	recorder := &memoryRecorder{}
+	g := NewGateway(WithMiddleware(&BaseMiddleware{}))
+	g.providers.factory = fakeFactory{"metered": &usageProvider{}}
+	assert.NoError(t, g.RegisterProvider("user", "metered", ProviderConfig{Name: "metered", Type: "openai"}))
+	g.SetUsageRecorder(recorder)
+
+	_, err := g.Complete(context.Background(), &Request{
+		UserID:   "user",
+		Messages: []Message{{Role: "user", Content: "hi"}},
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, recorder.records, 1)
+	model := recorder.records[0].Model
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.GatewayRequests.WithLabelValues("openai", model, "metered", "false", "success")))
+	assert.Equal(t, 1000000.0, testutil.ToFloat64(metrics.GatewayTokens.WithLabelValues("openai", model, "metered", "completion")))
+	assert.InDelta(t, recorder.records[0].Cost, testutil.ToFloat64(metrics.GatewayCost.WithLabelValues("openai", model, "metered")), 1e-9)
+}
+
+func TestCircuitBreakerExportsTransitions(t *testing.T) {
+	cb := NewCircuitBreaker()
+	for i := 0; i < 5; i++ {
+		_ = cb.Execute("user:flaky:model-a", func() error { return errors.New("boom") })
+	}
+
+	assert.Equal(t, StateOpen, cb.GetState("user:flaky:model-a"))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.CircuitBreakerTransitions.WithLabelValues("flaky", "model-a", "closed", "open")))
+	assert.Equal(t, float64(StateOpen), testutil.ToFloat64(metrics.CircuitBreakerState.WithLabelValues("flaky", "model-a")))
+}
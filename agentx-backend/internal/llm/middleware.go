@@ -3,6 +3,7 @@ package llm
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -124,34 +125,20 @@ func (m *RateLimitMiddleware) PreProcess(ctx context.Context, req *Request) (con
 	return ctx, req, nil
 }
 
-// RetryMiddleware handles retries with exponential backoff
+// RetryMiddleware classifies failed requests for the gateway's retry loop
 type RetryMiddleware struct {
 	BaseMiddleware
-	maxRetries int
-	baseDelay  time.Duration
 }
 
 func NewRetryMiddleware() Middleware {
-	return &RetryMiddleware{
-		maxRetries: 3,
-		baseDelay:  time.Second,
-	}
+	return &RetryMiddleware{}
 }
 
 func (m *RetryMiddleware) PostProcess(ctx context.Context, req *Request, resp *Response, err error) (*Response, error) {
-	// Only retry on certain errors
-	if err != nil && m.shouldRetry(err) {
-		retries := 0
-		if r, ok := req.Metadata["retries"].(int); ok {
-			retries = r
-		}
-		
-		if retries < m.maxRetries {
-			req.Metadata["retries"] = retries + 1
-			// Note: Actual retry logic would be handled at Gateway level
-			// This just marks that a retry should be attempted
-			return resp, fmt.Errorf("retry_needed: %w", err)
-		}
+	// Record whether the final error was transient so callers can decide
+	// whether resubmitting later is worthwhile
+	if err != nil && req.Metadata != nil {
+		req.Metadata["retryable"] = m.shouldRetry(err)
 	}
 	
 	return resp, err
@@ -159,12 +146,9 @@ func (m *RetryMiddleware) PostProcess(ctx context.Context, req *Request, resp *R
 
 func (m *RetryMiddleware) shouldRetry(err error) bool {
 	// Check if error is retryable (network errors, rate limits, etc.)
-	errStr := err.Error()
-	return contains(errStr, "timeout") || 
-	       contains(errStr, "connection") ||
-	       contains(errStr, "rate limit")
+	return IsRetryableError(err)
 }
 
 func contains(s, substr string) bool {
-	return len(s) >= len(substr) && s[:len(substr)] == substr
-}
\ No newline at end of file
+	return strings.Contains(s, substr)
+}
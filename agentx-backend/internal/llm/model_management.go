@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/agentx/agentx-backend/internal/providers"
+)
+
+// ErrModelManagementUnsupported is returned for connections whose provider
+// cannot install or remove models, such as hosted APIs
+var ErrModelManagementUnsupported = errors.New("connection does not support model management")
+
+// ModelManager returns the model manager of a connection's provider
+func (a *ProviderAdapter) ModelManager() (providers.ModelManager, bool) {
+	manager, ok := a.provider.(providers.ModelManager)
+	return manager, ok
+}
+
+// ModelManager returns the model manager of a user's connection
+func (g *Gateway) ModelManager(userID, connectionID string) (providers.ModelManager, error) {
+	provider, err := g.providers.GetProvider(userID, connectionID)
+	if err != nil {
+		return nil, err
+	}
+	adapter, ok := provider.(interface {
+		ModelManager() (providers.ModelManager, bool)
+	})
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrModelManagementUnsupported, connectionID)
+	}
+	manager, ok := adapter.ModelManager()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrModelManagementUnsupported, connectionID)
+	}
+	return manager, nil
+}
+
+// HasConnection reports whether a user's connection is registered
+func (g *Gateway) HasConnection(userID, connectionID string) bool {
+	_, err := g.providers.GetProvider(userID, connectionID)
+	return err == nil
+}
+
+// ConnectionModels returns the models of one of a user's connections
+func (g *Gateway) ConnectionModels(ctx context.Context, userID, connectionID string) ([]ModelInfo, error) {
+	provider, err := g.providers.GetProvider(userID, connectionID)
+	if err != nil {
+		return nil, err
+	}
+	return provider.GetModels(ctx)
+}
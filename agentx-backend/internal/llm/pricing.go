@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"strings"
+	"sync"
+)
+
+// ModelPricing is the list price of a model in USD per million tokens
+type ModelPricing struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+
+	// Prices of prompt tokens read from and written to the prompt cache;
+	// zero means they cost the same as other input
+	CacheReadPerMillion  float64 `json:"cache_read_per_million,omitempty"`
+	CacheWritePerMillion float64 `json:"cache_write_per_million,omitempty"`
+}
+
+// Blended returns a single per-million price assuming a 3:1 input/output mix
+func (p ModelPricing) Blended() float64 {
+	return (3*p.InputPerMillion + p.OutputPerMillion) / 4
+}
+
+// Cost returns the price of the given usage
+func (p ModelPricing) Cost(usage Usage) float64 {
+	readPrice, writePrice := p.InputPerMillion, p.InputPerMillion
+	if p.CacheReadPerMillion > 0 {
+		readPrice = p.CacheReadPerMillion
+	}
+	if p.CacheWritePerMillion > 0 {
+		writePrice = p.CacheWritePerMillion
+	}
+	uncached := usage.PromptTokens - usage.CacheReadTokens - usage.CacheCreationTokens
+	if uncached < 0 {
+		uncached = 0
+	}
+	return (float64(uncached)*p.InputPerMillion +
+		float64(usage.CacheReadTokens)*readPrice +
+		float64(usage.CacheCreationTokens)*writePrice +
+		float64(usage.CompletionTokens)*p.OutputPerMillion) / 1e6
+}
+
+// PricingCatalog maps model IDs (or ID prefixes) to prices
+type PricingCatalog struct {
+	defaults map[string]ModelPricing
+	prices   map[string]ModelPricing
+	mu       sync.RWMutex
+}
+
+// defaultPricing is shared by components that have no gateway to ask
+var defaultPricing = NewPricingCatalog()
+
+// DefaultPricingCatalog returns the process-wide catalog used by the gateway
+// unless another one is configured
+func DefaultPricingCatalog() *PricingCatalog {
+	return defaultPricing
+}
+
+// NewPricingCatalog creates a catalog seeded with list prices of common models
+func NewPricingCatalog() *PricingCatalog {
+	c := &PricingCatalog{
+		defaults: map[string]ModelPricing{
+			// OpenAI
+			"gpt-4o":        {InputPerMillion: 2.50, OutputPerMillion: 10.00},
+			"gpt-4o-mini":   {InputPerMillion: 0.15, OutputPerMillion: 0.60},
+			"gpt-4-turbo":   {InputPerMillion: 10.00, OutputPerMillion: 30.00},
+			"gpt-4":         {InputPerMillion: 30.00, OutputPerMillion: 60.00},
+			"gpt-3.5-turbo": {InputPerMillion: 0.50, OutputPerMillion: 1.50},
+			"o1":            {InputPerMillion: 15.00, OutputPerMillion: 60.00},
+			"o1-mini":       {InputPerMillion: 3.00, OutputPerMillion: 12.00},
+
+			// OpenAI embeddings
+			"text-embedding-3-small": {InputPerMillion: 0.02},
+			"text-embedding-3-large": {InputPerMillion: 0.13},
+			"text-embedding-ada-002": {InputPerMillion: 0.10},
+
+			// Anthropic; cache reads cost a tenth of input, writes a quarter more
+			"claude-opus-4":     {InputPerMillion: 15.00, OutputPerMillion: 75.00, CacheReadPerMillion: 1.50, CacheWritePerMillion: 18.75},
+			"claude-sonnet-4":   {InputPerMillion: 3.00, OutputPerMillion: 15.00, CacheReadPerMillion: 0.30, CacheWritePerMillion: 3.75},
+			"claude-3-opus":     {InputPerMillion: 15.00, OutputPerMillion: 75.00, CacheReadPerMillion: 1.50, CacheWritePerMillion: 18.75},
+			"claude-3-sonnet":   {InputPerMillion: 3.00, OutputPerMillion: 15.00},
+			"claude-3-5-sonnet": {InputPerMillion: 3.00, OutputPerMillion: 15.00, CacheReadPerMillion: 0.30, CacheWritePerMillion: 3.75},
+			"claude-3-7-sonnet": {InputPerMillion: 3.00, OutputPerMillion: 15.00, CacheReadPerMillion: 0.30, CacheWritePerMillion: 3.75},
+			"claude-3-haiku":    {InputPerMillion: 0.25, OutputPerMillion: 1.25, CacheReadPerMillion: 0.03, CacheWritePerMillion: 0.30},
+			"claude-3-5-haiku":  {InputPerMillion: 0.80, OutputPerMillion: 4.00, CacheReadPerMillion: 0.08, CacheWritePerMillion: 1.00},
+
+			// Google
+			"gemini-1.5-pro":   {InputPerMillion: 1.25, OutputPerMillion: 5.00},
+			"gemini-1.5-flash": {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+			"gemini-2.0-flash": {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+		},
+		prices: make(map[string]ModelPricing),
+	}
+	for model, pricing := range c.defaults {
+		c.prices[model] = pricing
+	}
+	return c
+}
+
+// Set adds or replaces the price of a model or model prefix
+func (c *PricingCatalog) Set(model string, pricing ModelPricing) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prices[model] = pricing
+}
+
+// Reset restores the list price of a model, or removes it if the catalog
+// was not seeded with one
+func (c *PricingCatalog) Reset(model string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pricing, ok := c.defaults[model]; ok {
+		c.prices[model] = pricing
+		return
+	}
+	delete(c.prices, model)
+}
+
+// All returns a copy of every price in the catalog
+func (c *PricingCatalog) All() map[string]ModelPricing {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	prices := make(map[string]ModelPricing, len(c.prices))
+	for model, pricing := range c.prices {
+		prices[model] = pricing
+	}
+	return prices
+}
+
+// Lookup returns the price of a model, matching the longest known prefix so
+// dated variants such as "gpt-4o-2024-08-06" resolve to "gpt-4o"
+func (c *PricingCatalog) Lookup(model string) (ModelPricing, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if pricing, ok := c.prices[model]; ok {
+		return pricing, true
+	}
+
+	var best string
+	for prefix := range c.prices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return ModelPricing{}, false
+	}
+	return c.prices[best], true
+}
+
+// PriceFor returns the price of a model served by the given connection.
+// Local connections are free; per-connection prices override the catalog.
+func (c *PricingCatalog) PriceFor(config ProviderConfig, model string) (ModelPricing, bool) {
+	if config.IsLocal() {
+		return ModelPricing{}, true
+	}
+	if price, ok := config.PricePerToken[model]; ok {
+		return ModelPricing{InputPerMillion: price * 1e6, OutputPerMillion: price * 1e6}, true
+	}
+	return c.Lookup(model)
+}
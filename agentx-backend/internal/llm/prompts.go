@@ -0,0 +1,444 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Prompt template names used by the built-in tasks
+const (
+	PromptTitleGeneration  = "title_generation"
+	PromptSessionSummary   = "session_summary"
+	PromptWebSearchResults = "web_search_results"
+	PromptTranslation      = "translation"
+	PromptCodeGeneration   = "code_generation"
+	PromptExtraction       = "structured_extraction"
+)
+
+// Where a resolved prompt template came from
+const (
+	PromptSourceUser    = "user"
+	PromptSourceGlobal  = "global"
+	PromptSourceBuiltin = "builtin"
+	PromptSourceInline  = "inline"
+)
+
+// ErrUnknownPrompt is returned when no template exists for a prompt name
+var ErrUnknownPrompt = errors.New("unknown prompt template")
+
+// PromptTemplate is a named, versioned pair of system and user prompts
+// written as Go text/template templates
+type PromptTemplate struct {
+	Name      string   `json:"name"`
+	Version   string   `json:"version"`
+	Source    string   `json:"source"` // user, global, builtin or inline
+	System    string   `json:"system,omitempty"`
+	User      string   `json:"user,omitempty"`
+	Variables []string `json:"variables,omitempty"` // Variables the built-in task passes
+}
+
+// RenderedPrompt is the output of a template together with the version that
+// produced it
+type RenderedPrompt struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Source  string `json:"source"`
+	System  string `json:"system"`
+	User    string `json:"user"`
+}
+
+// PromptStore loads stored prompt templates
+type PromptStore interface {
+	// ResolvePrompt returns the version of a template a user gets, their own
+	// default before the global one, or nil if neither is stored
+	ResolvePrompt(ctx context.Context, userID, name string) (*PromptTemplate, error)
+}
+
+// PromptLibrary resolves the prompts of the built-in tasks, preferring
+// stored templates over the ones compiled in so prompts can change and be
+// rolled back without a redeploy
+type PromptLibrary struct {
+	builtins map[string]PromptTemplate
+	store    PromptStore
+	cacheTTL time.Duration
+	cache    map[string]cachedPrompt
+	mu       sync.Mutex
+}
+
+type cachedPrompt struct {
+	template *PromptTemplate
+	expires  time.Time
+}
+
+// NewPromptLibrary creates a library holding the built-in templates
+func NewPromptLibrary() *PromptLibrary {
+	l := &PromptLibrary{
+		builtins: make(map[string]PromptTemplate),
+		cacheTTL: 30 * time.Second,
+		cache:    make(map[string]cachedPrompt),
+	}
+	for _, tmpl := range builtinPrompts {
+		l.builtins[tmpl.Name] = tmpl
+	}
+	return l
+}
+
+// SetStore sets where stored templates are loaded from
+func (l *PromptLibrary) SetStore(store PromptStore) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.store = store
+	l.cache = make(map[string]cachedPrompt)
+}
+
+// Invalidate drops resolved templates so changes to the store apply to the
+// next render
+func (l *PromptLibrary) Invalidate() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cache = make(map[string]cachedPrompt)
+}
+
+// Builtin returns the compiled-in template for a name
+func (l *PromptLibrary) Builtin(name string) (PromptTemplate, bool) {
+	tmpl, ok := l.builtins[name]
+	return tmpl, ok
+}
+
+// Builtins returns the compiled-in templates ordered by name
+func (l *PromptLibrary) Builtins() []PromptTemplate {
+	result := make([]PromptTemplate, 0, len(l.builtins))
+	for _, tmpl := range l.builtins {
+		result = append(result, tmpl)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Resolve returns the template a user gets for a name: a stored one if
+// there is one, otherwise the built-in. Store errors fall back to the
+// built-in so tasks keep working while the database is unavailable.
+func (l *PromptLibrary) Resolve(ctx context.Context, userID, name string) (PromptTemplate, error) {
+	if stored := l.resolveStored(ctx, userID, name); stored != nil {
+		return *stored, nil
+	}
+	if tmpl, ok := l.builtins[name]; ok {
+		return tmpl, nil
+	}
+	return PromptTemplate{}, fmt.Errorf("%w: %s", ErrUnknownPrompt, name)
+}
+
+func (l *PromptLibrary) resolveStored(ctx context.Context, userID, name string) *PromptTemplate {
+	key := userID + "\x00" + name
+
+	l.mu.Lock()
+	store := l.store
+	if cached, ok := l.cache[key]; ok && time.Now().Before(cached.expires) {
+		l.mu.Unlock()
+		return cached.template
+	}
+	l.mu.Unlock()
+
+	if store == nil {
+		return nil
+	}
+
+	tmpl, err := store.ResolvePrompt(ctx, userID, name)
+	if err != nil {
+		fmt.Printf("[PromptLibrary] Failed to load template %s: %v\n", name, err)
+		return nil
+	}
+
+	l.mu.Lock()
+	l.cache[key] = cachedPrompt{template: tmpl, expires: time.Now().Add(l.cacheTTL)}
+	l.mu.Unlock()
+	return tmpl
+}
+
+// Render resolves a template for a user and executes it with vars. A stored
+// template that fails to render falls back to the built-in one.
+func (l *PromptLibrary) Render(ctx context.Context, userID, name string, vars map[string]interface{}) (*RenderedPrompt, error) {
+	tmpl, err := l.Resolve(ctx, userID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, err := RenderPrompt(tmpl, vars)
+	if err == nil || tmpl.Source == PromptSourceBuiltin {
+		return rendered, err
+	}
+
+	builtin, ok := l.builtins[name]
+	if !ok {
+		return nil, err
+	}
+	fmt.Printf("[PromptLibrary] Template %s %s failed to render, using built-in: %v\n", name, tmpl.Version, err)
+	return RenderPrompt(builtin, vars)
+}
+
+// RenderPrompt executes a template's system and user prompts with vars.
+// Referencing a variable that was not passed is an error.
+func RenderPrompt(tmpl PromptTemplate, vars map[string]interface{}) (*RenderedPrompt, error) {
+	system, err := executePrompt(tmpl.Name+".system", tmpl.System, vars)
+	if err != nil {
+		return nil, err
+	}
+	user, err := executePrompt(tmpl.Name+".user", tmpl.User, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RenderedPrompt{
+		Name:    tmpl.Name,
+		Version: tmpl.Version,
+		Source:  tmpl.Source,
+		System:  system,
+		User:    user,
+	}, nil
+}
+
+// ValidatePrompt checks that a template's prompts parse
+func ValidatePrompt(tmpl PromptTemplate) error {
+	if _, err := parsePrompt(tmpl.Name+".system", tmpl.System); err != nil {
+		return err
+	}
+	_, err := parsePrompt(tmpl.Name+".user", tmpl.User)
+	return err
+}
+
+func parsePrompt(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %w", name, err)
+	}
+	return t, nil
+}
+
+func executePrompt(name, text string, vars map[string]interface{}) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	t, err := parsePrompt(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// promptVersion is a parsed semantic version; build metadata is dropped
+type promptVersion struct {
+	major, minor, patch int
+	pre                 []string
+}
+
+func parsePromptVersion(v string) (promptVersion, error) {
+	var pv promptVersion
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	core := v
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		core = v[:i]
+		pv.pre = strings.Split(v[i+1:], ".")
+		for _, id := range pv.pre {
+			if id == "" {
+				return pv, fmt.Errorf("invalid version %q: empty pre-release identifier", v)
+			}
+		}
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return pv, fmt.Errorf("invalid version %q: want MAJOR.MINOR.PATCH", v)
+	}
+	nums := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (len(part) > 1 && part[0] == '0') {
+			return pv, fmt.Errorf("invalid version %q: %q is not a version number", v, part)
+		}
+		nums[i] = n
+	}
+	pv.major, pv.minor, pv.patch = nums[0], nums[1], nums[2]
+	return pv, nil
+}
+
+// ValidatePromptVersion checks that v is a semantic version such as 1.2.0
+// or 2.0.0-rc.1
+func ValidatePromptVersion(v string) error {
+	_, err := parsePromptVersion(v)
+	return err
+}
+
+// ComparePromptVersions compares two semantic versions, returning -1, 0 or
+// +1. Invalid versions sort before valid ones.
+func ComparePromptVersions(a, b string) int {
+	va, errA := parsePromptVersion(a)
+	vb, errB := parsePromptVersion(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+
+	for _, d := range []int{va.major - vb.major, va.minor - vb.minor, va.patch - vb.patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+
+	// A release sorts after its pre-releases
+	switch {
+	case len(va.pre) == 0 && len(vb.pre) == 0:
+		return 0
+	case len(va.pre) == 0:
+		return 1
+	case len(vb.pre) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(va.pre) && i < len(vb.pre); i++ {
+		x, y := va.pre[i], vb.pre[i]
+		nx, errX := strconv.Atoi(x)
+		ny, errY := strconv.Atoi(y)
+		switch {
+		case errX == nil && errY == nil:
+			if nx != ny {
+				return sign(nx - ny)
+			}
+		case errX == nil:
+			return -1
+		case errY == nil:
+			return 1
+		default:
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+		}
+	}
+	return sign(len(va.pre) - len(vb.pre))
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// builtinPrompts are the compiled-in templates, used until a version is
+// stored for their name
+var builtinPrompts = []PromptTemplate{
+	{
+		Name:      PromptTitleGeneration,
+		Version:   "1.0.0",
+		Source:    PromptSourceBuiltin,
+		Variables: []string{"Conversation", "Messages"},
+		System:    "You are a helpful assistant that generates concise, descriptive titles for conversations. Generate a title that captures the main topic or purpose of the conversation in 5-10 words maximum. Respond with ONLY the title, nothing else.",
+		User: `Generate a concise, descriptive title for this conversation. The title should:
+- Be 3-7 words maximum
+- Capture the main topic or purpose of the conversation
+- Be clear and specific
+- Not include phrases like "Chat about" or "Discussion of"
+- Not use quotation marks
+
+Conversation:
+{{.Conversation}}
+
+Title:`,
+	},
+	{
+		Name:      PromptSessionSummary,
+		Version:   "1.0.0",
+		Source:    PromptSourceBuiltin,
+		Variables: []string{"Conversation", "MessageCount"},
+		User: `Create a concise summary of this conversation that can replace the full messages in an LLM context.
+
+Include ONLY the essential information needed for context continuity:
+- Key facts established
+- Important decisions made
+- Critical technical details (errors, code snippets if crucial)
+- Current task/question being worked on
+- User preferences discovered
+
+Format as a brief narrative (max 200 words) that provides context for continuing the conversation.
+
+Conversation to summarize:
+{{.Conversation}}
+
+Summary:`,
+	},
+	{
+		Name:      PromptWebSearchResults,
+		Version:   "1.0.0",
+		Source:    PromptSourceBuiltin,
+		Variables: []string{"Results", "Provider"},
+		User: `Based on current web information:
+
+{{range .Results}}• According to {{.Domain}}: {{.Snippet}}
+{{end}}{{if .Provider}}_Source: {{.Provider}}_{{end}}`,
+	},
+	{
+		Name:      PromptTranslation,
+		Version:   "1.0.0",
+		Source:    PromptSourceBuiltin,
+		Variables: []string{"Text", "SourceLanguage", "TargetLanguage", "Glossary"},
+		System:    "You are a professional translator. Translate faithfully, preserving the meaning, tone and formatting of the text, including Markdown and code. Do not add notes or explanations.",
+		User: `{{if .SourceLanguage}}Translate the following text from {{.SourceLanguage}} to {{.TargetLanguage}}.{{else}}Detect the language of the following text and translate it to {{.TargetLanguage}}.{{end}}
+Report the language of the original text as source_language, an ISO 639-1 code, and the translation as translation.
+{{if .Glossary}}
+Always translate these terms as given:
+{{range $term, $translation := .Glossary}}- {{$term}} → {{$translation}}
+{{end}}{{end}}
+Text:
+{{.Text}}`,
+	},
+	{
+		Name:      PromptCodeGeneration,
+		Version:   "1.0.0",
+		Source:    PromptSourceBuiltin,
+		Variables: []string{"Instructions", "Language", "Style", "Code"},
+		System:    "You are an expert {{.Language}} programmer. Write correct, idiomatic and complete code. Reply with the code in a single fenced code block tagged with the language, followed by at most a short explanation.",
+		User: `{{.Instructions}}
+{{if .Style}}
+Follow these constraints:
+{{range .Style}}- {{.}}
+{{end}}{{end}}{{if .Code}}
+Existing code:
+` + "```" + `{{.Language}}
+{{.Code}}
+` + "```" + `
+{{end}}`,
+	},
+	{
+		Name:      PromptExtraction,
+		Version:   "1.0.0",
+		Source:    PromptSourceBuiltin,
+		Variables: []string{"Text", "Instructions", "SchemaName"},
+		System:    "You extract structured data from text. Use only information stated in the text and leave out optional fields it does not mention rather than guessing.",
+		User: `{{if .Instructions}}{{.Instructions}}
+
+{{end}}Extract the {{.SchemaName}} data from this text:
+
+{{.Text}}`,
+	},
+}
@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePromptStore struct {
+	templates map[string]*PromptTemplate
+	err       error
+	calls     int
+}
+
+func (s *fakePromptStore) ResolvePrompt(ctx context.Context, userID, name string) (*PromptTemplate, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.templates[userID+"/"+name], nil
+}
+
+func TestComparePromptVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.2.0", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-rc.2", "1.0.0-rc.10", -1},
+		{"1.0.0-beta", "1.0.0-1", 1},
+		{"1.0.0+build.5", "1.0.0", 0},
+		{"latest", "0.0.1", -1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ComparePromptVersions(tt.a, tt.b), "%s vs %s", tt.a, tt.b)
+		assert.Equal(t, -tt.want, ComparePromptVersions(tt.b, tt.a), "%s vs %s", tt.b, tt.a)
+	}
+
+	assert.NoError(t, ValidatePromptVersion("0.1.0-rc.1+sha.5114f85"))
+	for _, v := range []string{"1.0", "v1.0.0", "01.0.0", "1.0.0-", "1.0.0-a..b"} {
+		assert.Error(t, ValidatePromptVersion(v), v)
+	}
+}
+
+func TestPromptLibraryRendersBuiltinSearchResults(t *testing.T) {
+	library := NewPromptLibrary()
+	rendered, err := library.Render(context.Background(), "user-1", PromptWebSearchResults, map[string]interface{}{
+		"Results": []map[string]interface{}{
+			{"Domain": "go.dev", "Snippet": "Go 1.22 is released."},
+			{"Domain": "example.com", "Snippet": "Release notes."},
+		},
+		"Provider": "searxng",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, PromptSourceBuiltin, rendered.Source)
+	assert.Equal(t, "Based on current web information:\n\n"+
+		"• According to go.dev: Go 1.22 is released.\n"+
+		"• According to example.com: Release notes.\n"+
+		"_Source: searxng_", rendered.User)
+}
+
+func TestPromptLibraryPrefersStoredTemplates(t *testing.T) {
+	store := &fakePromptStore{templates: map[string]*PromptTemplate{
+		"user-1/" + PromptTitleGeneration: {
+			Name: PromptTitleGeneration, Version: "2.0.0", Source: PromptSourceUser,
+			System: "Title in French.", User: "{{len .Messages}} messages:\n{{.Conversation}}",
+		},
+	}}
+	library := NewPromptLibrary()
+	library.SetStore(store)
+	vars := map[string]interface{}{"Conversation": "user: hi", "Messages": []string{"user: hi"}}
+
+	rendered, err := library.Render(context.Background(), "user-1", PromptTitleGeneration, vars)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0.0", rendered.Version)
+	assert.Equal(t, "Title in French.", rendered.System)
+	assert.Equal(t, "1 messages:\nuser: hi", rendered.User)
+
+	// Other users keep the built-in, and lookups are cached until invalidated
+	rendered, err = library.Render(context.Background(), "user-2", PromptTitleGeneration, vars)
+	assert.NoError(t, err)
+	assert.Equal(t, PromptSourceBuiltin, rendered.Source)
+	_, _ = library.Render(context.Background(), "user-1", PromptTitleGeneration, vars)
+	assert.Equal(t, 2, store.calls)
+	library.Invalidate()
+	_, _ = library.Render(context.Background(), "user-1", PromptTitleGeneration, vars)
+	assert.Equal(t, 3, store.calls)
+}
+
+func TestPromptLibraryFallsBackToBuiltin(t *testing.T) {
+	store := &fakePromptStore{templates: map[string]*PromptTemplate{
+		"user-1/" + PromptSessionSummary: {
+			Name: PromptSessionSummary, Version: "1.1.0", Source: PromptSourceUser,
+			User: "Summarize {{.Transcript}}",
+		},
+	}}
+	library := NewPromptLibrary()
+	library.SetStore(store)
+	vars := map[string]interface{}{"Conversation": "User: hi\n\n", "MessageCount": 1}
+
+	// A stored template referencing a variable the task does not pass
+	rendered, err := library.Render(context.Background(), "user-1", PromptSessionSummary, vars)
+	assert.NoError(t, err)
+	assert.Equal(t, PromptSourceBuiltin, rendered.Source)
+	assert.Contains(t, rendered.User, "Conversation to summarize:\nUser: hi")
+
+	// An unavailable store
+	store.err = errors.New("connection refused")
+	library.Invalidate()
+	rendered, err = library.Render(context.Background(), "user-1", PromptSessionSummary, vars)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", rendered.Version)
+
+	_, err = library.Render(context.Background(), "user-1", "no_such_prompt", vars)
+	assert.ErrorIs(t, err, ErrUnknownPrompt)
+}
@@ -2,6 +2,10 @@ package llm
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
 	"time"
 	
 	"github.com/agentx/agentx-backend/internal/config"
@@ -45,6 +49,59 @@ type ProviderConfig struct {
 	
 	// Cost tracking
 	PricePerToken map[string]float64 `json:"price_per_token,omitempty"`
+	
+	// Local marks connections that keep data on the user's own infrastructure
+	Local bool `json:"local,omitempty"`
+	
+	// Response caching, off unless opted in: TTL of cached answers (0 for
+	// no caching, or DefaultCacheTTL with SemanticCache) and matching of
+	// similar prompts
+	CacheTTL               time.Duration `json:"cache_ttl,omitempty"`
+	SemanticCache          bool          `json:"semantic_cache,omitempty"`
+	SemanticCacheThreshold float64       `json:"semantic_cache_threshold,omitempty"`
+	
+	// Circuit breaker tuning for the connection, with per-model overrides.
+	// Unset fields fall back to DefaultBreakerSettings.
+	Breaker       BreakerSettings            `json:"circuit_breaker,omitempty"`
+	ModelBreakers map[string]BreakerSettings `json:"model_circuit_breakers,omitempty"`
+}
+
+// BreakerSettingsFor returns the circuit breaker settings of a model on
+// this connection
+func (c ProviderConfig) BreakerSettingsFor(model string) BreakerSettings {
+	settings := DefaultBreakerSettings().Merge(c.Breaker)
+	if override, ok := c.ModelBreakers[model]; ok {
+		settings = settings.Merge(override)
+	}
+	return settings
+}
+
+// IsLocal reports whether requests to this connection stay on local
+// infrastructure, judged by the host of its endpoint (loopback or private
+// addresses) unless the connection is explicitly flagged as local. The
+// provider type alone says nothing: an Ollama server may well be remote.
+func (c ProviderConfig) IsLocal() bool {
+	if c.Local {
+		return true
+	}
+	baseURL := c.BaseURL
+	if baseURL == "" && c.Type == "ollama" {
+		baseURL = "http://localhost:11434" // The Ollama provider's default endpoint
+	}
+	if baseURL == "" {
+		return false
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" || host == "host.docker.internal" || strings.HasSuffix(host, ".local") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate())
 }
 
 // ToProviderConfig converts to the providers package config format
@@ -58,6 +115,101 @@ func (c ProviderConfig) ToProviderConfig() config.ProviderConfig {
 	}
 }
 
+// ApplyConnectionSettings reads per-connection policies from a connection's
+// stored config. "max_retries" is a count (0 disables retries), "timeout"
+// is either seconds or a duration string such as "45s", and "local" marks a
+// self-hosted connection for privacy-aware routing. "cache_ttl" takes the
+// same forms as "timeout" and opts in to caching answers for that long,
+// "semantic_cache" opts in to matching similar prompts as well and
+// "semantic_cache_threshold" is the cosine similarity they need. "circuit_breaker" is an object with
+// "failure_ratio", "window", "min_requests", "open_duration" and
+// "half_open_probes", plus a "models" object of per-model overrides.
+func (c *ProviderConfig) ApplyConnectionSettings(settings map[string]interface{}) {
+	switch v := settings["max_retries"].(type) {
+	case nil:
+	case float64, int:
+		c.MaxRetries = settingInt(v)
+		if c.MaxRetries == 0 {
+			c.MaxRetries = -1 // Explicitly disabled; 0 means "use the default"
+		}
+	default:
+		fmt.Printf("[ProviderConfig] Ignoring max_retries %v of connection %s, it is not a number\n", v, c.Name)
+	}
+
+	if local, ok := settings["local"].(bool); ok {
+		c.Local = local
+	}
+
+	if d, ok := settingDuration(settings["timeout"]); ok {
+		c.Timeout = d
+	}
+
+	if d, ok := settingDuration(settings["cache_ttl"]); ok {
+		c.CacheTTL = d
+	}
+	if semantic, ok := settings["semantic_cache"].(bool); ok {
+		c.SemanticCache = semantic
+	}
+	if threshold, ok := settings["semantic_cache_threshold"].(float64); ok {
+		c.SemanticCacheThreshold = threshold
+	}
+
+	if breaker, ok := settings["circuit_breaker"].(map[string]interface{}); ok {
+		c.Breaker = breakerSettings(breaker)
+		if models, ok := breaker["models"].(map[string]interface{}); ok {
+			c.ModelBreakers = make(map[string]BreakerSettings, len(models))
+			for model, v := range models {
+				if override, ok := v.(map[string]interface{}); ok {
+					c.ModelBreakers[model] = breakerSettings(override)
+				}
+			}
+		}
+	}
+}
+
+// breakerSettings reads circuit breaker settings from a connection's config
+func breakerSettings(settings map[string]interface{}) BreakerSettings {
+	var b BreakerSettings
+	if ratio, ok := settings["failure_ratio"].(float64); ok {
+		b.FailureRatio = ratio
+	}
+	if d, ok := settingDuration(settings["window"]); ok {
+		b.Window = d
+	}
+	b.MinRequests = settingInt(settings["min_requests"])
+	if d, ok := settingDuration(settings["open_duration"]); ok {
+		b.OpenDuration = d
+	}
+	b.HalfOpenProbes = settingInt(settings["half_open_probes"])
+	return b
+}
+
+// settingInt reads a count decoded from JSON or set directly
+func settingInt(v interface{}) int {
+	switch v := v.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+// settingDuration reads a duration given in seconds or as a duration string
+func settingDuration(v interface{}) (time.Duration, bool) {
+	switch v := v.(type) {
+	case float64:
+		return time.Duration(v * float64(time.Second)), true
+	case int:
+		return time.Duration(v) * time.Second, true
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
 // ProviderCapabilities describes what a provider can do
 type ProviderCapabilities struct {
 	Streaming        bool     `json:"streaming"`
@@ -67,6 +219,9 @@ type ProviderCapabilities struct {
 	AudioOutput      bool     `json:"audio_output"`
 	MaxTokens        int      `json:"max_tokens"`
 	SupportedModels  []string `json:"supported_models"`
+	StructuredOutput string   `json:"structured_output,omitempty"` // Native structured-output mode: json_schema, tool or empty
+	Embeddings       bool     `json:"embeddings"`
+	MaxEmbeddingBatch int     `json:"max_embedding_batch,omitempty"` // Inputs per embedding call; 0 for DefaultEmbeddingBatchSize
 }
 
 // ModelInfo contains information about a model
@@ -77,6 +232,11 @@ type ModelInfo struct {
 	Description  string               `json:"description"`
 	Capabilities []string             `json:"capabilities"`
 	MaxTokens    int                  `json:"max_tokens"`
+	ContextLength int                 `json:"context_length,omitempty"` // Context window the provider reports, 0 if unknown
+	Dimensions   int                  `json:"dimensions,omitempty"` // Vector size of embedding models
+	ParameterSize string              `json:"parameter_size,omitempty"` // e.g. "8.0B", for self-hosted models
+	Quantization string               `json:"quantization,omitempty"`   // e.g. "Q4_K_M"
+	Family       string               `json:"family,omitempty"`
 	PricingTier  string               `json:"pricing_tier"`
 	Status       ModelStatus          `json:"status"`
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
@@ -2,7 +2,9 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 	
 	"github.com/agentx/agentx-backend/internal/providers"
@@ -71,6 +73,42 @@ func (a *ProviderAdapter) GetModels(ctx context.Context) ([]ModelInfo, error) {
 				LastCheck: time.Now(),
 			},
 		}
+		
+		// Self-hosted models describe their build and real context size
+		if pm.Extra != nil {
+			models[i].Metadata = pm.Extra
+			if n, ok := pm.Extra["context_length"].(int); ok && n > 0 {
+				models[i].MaxTokens = n
+				models[i].ContextLength = n
+			}
+			models[i].ParameterSize, _ = pm.Extra["parameter_size"].(string)
+			models[i].Quantization, _ = pm.Extra["quantization_level"].(string)
+			models[i].Family, _ = pm.Extra["family"].(string)
+			if caps, ok := pm.Extra["capabilities"].([]string); ok {
+				for _, c := range caps {
+					switch c {
+					case "vision", "tools":
+						models[i].Capabilities = append(models[i].Capabilities, c)
+					case "embedding":
+						models[i].Capabilities = append(models[i].Capabilities, "embeddings")
+					}
+				}
+			}
+		}
+		
+		// Vision models accept image input
+		if isVisionModel(pm.ID) && !containsString(models[i].Capabilities, "vision") {
+			models[i].Capabilities = append(models[i].Capabilities, "vision")
+		}
+		
+		// Embedding models report the size of their vectors
+		if dims := EmbeddingDimensions(pm.ID); dims > 0 || strings.Contains(pm.ID, "embed") {
+			if !containsString(models[i].Capabilities, "embeddings") {
+				models[i].Capabilities = append(models[i].Capabilities, "embeddings")
+			}
+			models[i].Dimensions = dims
+			models[i].Description = fmt.Sprintf("%s embedding model", pm.ID)
+		}
 	}
 	
 	return models, nil
@@ -95,10 +133,17 @@ func (a *ProviderAdapter) GetCapabilities() ProviderCapabilities {
 		MaxTokens:       4096,
 	}
 	
+	// Embeddings are available wherever the underlying provider implements them
+	if _, ok := a.provider.(providers.Embedder); ok {
+		caps.Embeddings = true
+	}
+	
 	switch a.config.Type {
-	case "openai":
+	case "openai", "azure-openai":
 		caps.FunctionCalling = true
 		caps.Vision = true
+		caps.StructuredOutput = StructuredJSONSchema
+		caps.MaxEmbeddingBatch = 2048
 		caps.MaxTokens = 128000 // GPT-4 Turbo
 		caps.SupportedModels = []string{
 			"gpt-4-turbo-preview",
@@ -109,14 +154,31 @@ func (a *ProviderAdapter) GetCapabilities() ProviderCapabilities {
 	case "anthropic":
 		caps.FunctionCalling = true
 		caps.Vision = true
+		caps.StructuredOutput = StructuredTool
 		caps.MaxTokens = 200000 // Claude 3
 		caps.SupportedModels = []string{
 			"claude-3-opus-20240229",
 			"claude-3-sonnet-20240229",
 			"claude-3-haiku-20240307",
 		}
-	case "local":
-		// Local provider capabilities depend on the model
+	case "gemini":
+		caps.FunctionCalling = true
+		caps.Vision = true
+		caps.MaxEmbeddingBatch = 100
+		caps.MaxTokens = 1000000 // Gemini 1.5 Flash
+		caps.SupportedModels = []string{
+			"gemini-1.5-pro",
+			"gemini-1.5-flash",
+			"gemini-2.0-flash",
+		}
+	case "local", "ollama":
+		// Local provider capabilities depend on the model; Ollama reports
+		// each model's real context size through GetModels
+		caps.FunctionCalling = true
+		caps.Vision = true // llava, llama3.2-vision and similar
+		if a.config.Type == "ollama" {
+			caps.StructuredOutput = StructuredJSONSchema // Native "format" schemas
+		}
 		caps.MaxTokens = 8192
 		caps.SupportedModels = []string{
 			"llama2",
@@ -129,6 +191,36 @@ func (a *ProviderAdapter) GetCapabilities() ProviderCapabilities {
 	return caps
 }
 
+// Embed embeds a batch of texts using the underlying provider
+func (a *ProviderAdapter) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	embedder, ok := a.provider.(providers.Embedder)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrEmbeddingsUnsupported, a.config.Type)
+	}
+	
+	providerResp, err := embedder.Embed(ctx, providers.EmbeddingRequest{
+		Input:      req.Input,
+		Model:      req.Model,
+		Dimensions: req.Dimensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	resp := &EmbeddingResponse{
+		Embeddings: providerResp.Embeddings,
+		Model:      providerResp.Model,
+		Usage: Usage{
+			PromptTokens: providerResp.Usage.PromptTokens,
+			TotalTokens:  providerResp.Usage.TotalTokens,
+		},
+	}
+	if len(resp.Embeddings) > 0 {
+		resp.Dimensions = len(resp.Embeddings[0])
+	}
+	return resp, nil
+}
+
 // Close closes the underlying provider connection
 func (a *ProviderAdapter) Close() error {
 	// Most providers don't need explicit closing
@@ -142,9 +234,25 @@ func (a *ProviderAdapter) convertRequest(req *Request) providers.CompletionReque
 	messages := make([]providers.Message, len(req.Messages))
 	for i, msg := range req.Messages {
 		messages[i] = providers.Message{
-			Role:    msg.Role,
-			Content: msg.Content,
-			// Name field doesn't exist in providers.Message
+			Role:               msg.Role,
+			Content:            msg.Content,
+			ToolCallID:         msg.ToolCallID,
+			Name:               msg.Name,
+			Cacheable:          msg.Cacheable,
+			Reasoning:          msg.Reasoning,
+			ReasoningSignature: msg.ReasoningSignature,
+			RedactedReasoning:  msg.RedactedReasoning,
+		}
+		
+		// Images travel as content parts alongside the text
+		if len(msg.Images) > 0 {
+			messages[i].Content = ""
+			if msg.Content != "" {
+				messages[i].ContentArray = append(messages[i].ContentArray, providers.TextContent(msg.Content))
+			}
+			for _, image := range msg.Images {
+				messages[i].ContentArray = append(messages[i].ContentArray, providers.ImageContent(image.DataURL()))
+			}
 		}
 		
 		// Convert tool calls if any
@@ -194,6 +302,27 @@ func (a *ProviderAdapter) convertRequest(req *Request) providers.CompletionReque
 		}
 	}
 	
+	if req.Reasoning != nil {
+		providerReq.Reasoning = &providers.Reasoning{BudgetTokens: req.Reasoning.BudgetTokens}
+	}
+	if req.PromptCache != nil {
+		providerReq.PromptCache = &providers.PromptCache{System: req.PromptCache.System, Tools: req.PromptCache.Tools}
+	}
+	
+	// Ask for structured output natively where the provider supports it;
+	// the gateway prompts for it everywhere else
+	if req.ResponseFormat.Structured() && a.GetCapabilities().StructuredOutput != "" {
+		providerReq.ResponseFormat = &providers.ResponseFormat{Type: req.ResponseFormat.Type}
+		if schema := req.ResponseFormat.JSONSchema; schema != nil {
+			providerReq.ResponseFormat.JSONSchema = &providers.JSONSchema{
+				Name:        req.ResponseFormat.SchemaName(),
+				Description: schema.Description,
+				Schema:      schema.Schema,
+				Strict:      schema.Strict,
+			}
+		}
+	}
+	
 	return providerReq
 }
 
@@ -203,8 +332,11 @@ func (a *ProviderAdapter) convertResponse(resp *providers.CompletionResponse) *R
 	choices := make([]Choice, len(resp.Choices))
 	for i, c := range resp.Choices {
 		msg := Message{
-			Role:    c.Message.Role,
-			Content: c.Message.Content,
+			Role:               c.Message.Role,
+			Content:            c.Message.Content,
+			Reasoning:          c.Message.Reasoning,
+			ReasoningSignature: c.Message.ReasoningSignature,
+			RedactedReasoning:  c.Message.RedactedReasoning,
 		}
 		
 		// Convert tool calls if any
@@ -238,11 +370,7 @@ func (a *ProviderAdapter) convertResponse(resp *providers.CompletionResponse) *R
 		Created: time.Unix(resp.Created, 0),
 		Model:   resp.Model,
 		Choices: choices,
-		Usage: Usage{
-			PromptTokens:     resp.Usage.PromptTokens,
-			CompletionTokens: resp.Usage.CompletionTokens,
-			TotalTokens:      resp.Usage.TotalTokens,
-		},
+		Usage:   convertUsage(resp.Usage),
 		Metadata: Metadata{
 			Provider: a.config.Type,
 			Model:    resp.Model,
@@ -254,8 +382,11 @@ func (a *ProviderAdapter) convertResponse(resp *providers.CompletionResponse) *R
 func (a *ProviderAdapter) convertStreamChunk(chunk providers.StreamChunk) *StreamChunk {
 	// The provider StreamChunk is simpler - convert to our unified format
 	delta := MessageDelta{
-		Role:    chunk.Role,
-		Content: chunk.Delta,
+		Role:               chunk.Role,
+		Content:            chunk.Delta,
+		Reasoning:          chunk.Reasoning,
+		ReasoningSignature: chunk.ReasoningSignature,
+		RedactedReasoning:  chunk.RedactedReasoning,
 	}
 	
 	// Convert tool calls if any
@@ -272,6 +403,7 @@ func (a *ProviderAdapter) convertStreamChunk(chunk providers.StreamChunk) *Strea
 					Name:      tc.Function.Name,
 					Arguments: tc.Function.Arguments,
 				},
+				Index: tc.Index,
 			}
 		}
 	}
@@ -292,7 +424,36 @@ func (a *ProviderAdapter) convertStreamChunk(chunk providers.StreamChunk) *Strea
 		},
 	}
 	
-	// Note: provider StreamChunk doesn't have Usage field
+	// Thinking streams as its own chunk type, apart from the answer
+	if chunk.Reasoning != "" || chunk.ReasoningSignature != "" || len(chunk.RedactedReasoning) > 0 {
+		streamChunk.Type = "reasoning"
+		streamChunk.Reasoning = chunk.Reasoning
+	}
+	
+	// Surface provider stream failures so the gateway can fail over
+	if chunk.Error != "" {
+		streamChunk.Type = "error"
+		streamChunk.Error = chunk.Err
+		if streamChunk.Error == nil {
+			streamChunk.Error = errors.New(chunk.Error)
+		}
+	}
+	
+	if chunk.Usage != nil {
+		usage := convertUsage(*chunk.Usage)
+		streamChunk.Usage = &usage
+	}
 	
 	return streamChunk
+}
+
+// convertUsage converts provider token usage, including prompt cache counts
+func convertUsage(usage providers.Usage) Usage {
+	return Usage{
+		PromptTokens:        usage.PromptTokens,
+		CompletionTokens:    usage.CompletionTokens,
+		TotalTokens:         usage.TotalTokens,
+		CacheReadTokens:     usage.CacheReadTokens,
+		CacheCreationTokens: usage.CacheCreationTokens,
+	}
 }
\ No newline at end of file
@@ -3,6 +3,7 @@ package llm
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
@@ -163,6 +164,27 @@ func (pm *ProviderManager) GetUserProviders(userID string) map[string]Provider {
 	return userProviders
 }
 
+// FindUserProviderByType returns the user's first provider of the given type
+// (e.g. "openai", "local") and its key, or nil if there is none
+func (pm *ProviderManager) FindUserProviderByType(userID, providerType string) (Provider, string) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	var keys []string
+	for key, config := range pm.configs {
+		if pm.isUserProvider(key, userID) && config.Type == providerType {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, ""
+	}
+
+	// Pick deterministically so the same rule keeps hitting the same connection
+	sort.Strings(keys)
+	return pm.providers[keys[0]], keys[0]
+}
+
 // HealthCheck performs health checks on all providers
 func (pm *ProviderManager) HealthCheck(ctx context.Context) map[string]HealthStatus {
 	pm.mu.Lock()
@@ -210,6 +232,28 @@ func (pm *ProviderManager) GetHealthStatus(userID, connectionID string) (HealthS
 	return status, exists
 }
 
+// GetHealthStatusByKey returns the health status for a provider key
+func (pm *ProviderManager) GetHealthStatusByKey(key string) (HealthStatus, bool) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	status, exists := pm.health[key]
+	return status, exists
+}
+
+// GetConfig returns the config for a connection. The connection ID may be
+// either bare or already prefixed with the user ID.
+func (pm *ProviderManager) GetConfig(userID, connectionID string) (ProviderConfig, bool) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	if config, exists := pm.configs[pm.makeKey(userID, connectionID)]; exists {
+		return config, true
+	}
+	config, exists := pm.configs[connectionID]
+	return config, exists
+}
+
 // Shutdown gracefully shuts down all providers
 func (pm *ProviderManager) Shutdown(ctx context.Context) error {
 	pm.mu.Lock()
@@ -29,7 +29,9 @@ type Request struct {
 	// Advanced features
 	Tools         []Tool                 `json:"tools,omitempty"`
 	ToolChoice    interface{}            `json:"tool_choice,omitempty"`
-	ResponseFormat map[string]interface{} `json:"response_format,omitempty"`
+	ResponseFormat *ResponseFormat         `json:"response_format,omitempty"`
+	Reasoning     *ReasoningOptions      `json:"reasoning,omitempty"`
+	PromptCache   *PromptCacheOptions    `json:"prompt_cache,omitempty"`
 
 	// Routing and requirements
 	Preferences  Preferences `json:"preferences,omitempty"`
@@ -126,6 +128,11 @@ func (r *Request) Validate() error {
 		return fmt.Errorf("top_p must be between 0 and 1")
 	}
 	
+	// Validate response format
+	if err := r.ResponseFormat.Validate(); err != nil {
+		return err
+	}
+	
 	return nil
 }
 
@@ -160,6 +167,17 @@ func (r *Request) Clone() *Request {
 		clone.TopP = &topP
 	}
 	
+	// Copy remaining generation parameters and advanced features
+	clone.FrequencyPenalty = r.FrequencyPenalty
+	clone.PresencePenalty = r.PresencePenalty
+	clone.N = r.N
+	clone.Stop = append([]string(nil), r.Stop...)
+	clone.Tools = append([]Tool(nil), r.Tools...)
+	clone.ToolChoice = r.ToolChoice
+	clone.ResponseFormat = r.ResponseFormat
+	clone.Reasoning = r.Reasoning
+	clone.PromptCache = r.PromptCache
+	
 	// Copy metadata
 	if r.Metadata != nil {
 		clone.Metadata = make(map[string]interface{})
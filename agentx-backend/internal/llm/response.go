@@ -19,6 +19,9 @@ type Response struct {
 	Role     string `json:"role"`     // Direct access to first choice role
 	Provider string `json:"provider"` // Provider that handled the request
 	
+	// Answer decoded and validated against the requested response format
+	Parsed interface{} `json:"parsed,omitempty"`
+	
 	// Usage statistics
 	Usage Usage `json:"usage"`
 	
@@ -60,11 +63,14 @@ type StreamChunk struct {
 	Created  time.Time      `json:"created"`
 	Model    string         `json:"model"`
 	Provider string         `json:"provider"`
-	Type     string         `json:"type"` // "content", "error", "done"
+	Type     string         `json:"type"` // "content", "reasoning", "error", "failover", "done", or a tool_call_* event
 	Content  string         `json:"content,omitempty"`
+	Reasoning string        `json:"reasoning,omitempty"` // Set on reasoning chunks
+	ToolCall *ToolCall      `json:"tool_call,omitempty"` // Set on tool_call_* events
 	Choices  []StreamChoice `json:"choices"`
 	Usage    *Usage         `json:"usage,omitempty"`
 	Error    error          `json:"error,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
 // StreamChoice represents a choice in a streaming chunk
@@ -80,6 +86,10 @@ type MessageDelta struct {
 	Role      string     `json:"role,omitempty"`
 	Content   string     `json:"content,omitempty"`
 	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+
+	Reasoning          string   `json:"reasoning,omitempty"`
+	ReasoningSignature string   `json:"reasoning_signature,omitempty"`
+	RedactedReasoning  []string `json:"redacted_reasoning,omitempty"`
 }
 
 // GetContent returns the content from the first choice
@@ -98,6 +108,14 @@ func (r *Response) GetRole() string {
 	return ""
 }
 
+// GetReasoning returns the thinking that preceded the first choice
+func (r *Response) GetReasoning() string {
+	if len(r.Choices) > 0 {
+		return r.Choices[0].Message.Reasoning
+	}
+	return ""
+}
+
 // HasToolCalls checks if the response contains tool calls
 func (r *Response) HasToolCalls() bool {
 	if len(r.Choices) > 0 {
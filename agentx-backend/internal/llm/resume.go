@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// resumeInstruction asks a provider that cannot be prefilled to pick up
+	// a cut-off answer where it stopped
+	resumeInstruction = "Your previous reply was cut off. Continue it from exactly where it stopped, " +
+		"without repeating anything already written and without any preamble."
+
+	// resumeTailChars is how much of the partial answer a resumed stream is
+	// checked against for repetition
+	resumeTailChars = 200
+
+	// minResumeOverlap is the shortest repetition that is dropped, so a
+	// continuation that happens to start like the answer ended is kept
+	minResumeOverlap = 6
+)
+
+// supportsPrefill reports whether a connection continues a trailing
+// assistant message verbatim instead of starting a new reply
+func supportsPrefill(config ProviderConfig) bool {
+	return config.Type == "anthropic"
+}
+
+// continuationRequest asks a fallback to continue the partial answer of a
+// failed stream. Providers that support it are prefilled with the partial
+// answer; others get it as a previous turn with an instruction to continue,
+// and their output is trimmed of any repetition by the returned trimmer.
+func continuationRequest(req *Request, partial string, prefill bool) (*Request, *overlapTrimmer) {
+	continuation := req.Clone()
+	if partial == "" {
+		return continuation, nil
+	}
+
+	if prefill {
+		// Prefilled turns may not end in whitespace
+		continuation.Messages = append(continuation.Messages, Message{
+			Role:    "assistant",
+			Content: strings.TrimRightFunc(partial, isSpace),
+		})
+		return continuation, nil
+	}
+
+	continuation.Messages = append(continuation.Messages,
+		Message{Role: "assistant", Content: partial},
+		Message{Role: "user", Content: resumeInstruction},
+	)
+	return continuation, newOverlapTrimmer(partial)
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\n' || r == '\t' || r == '\r'
+}
+
+// overlapTrimmer drops the start of a resumed stream when it repeats the end
+// of the partial answer the client already has
+type overlapTrimmer struct {
+	tail    string
+	held    string
+	settled bool
+}
+
+// newOverlapTrimmer checks a resumed stream against the end of partial
+func newOverlapTrimmer(partial string) *overlapTrimmer {
+	tail := partial
+	if len(tail) > resumeTailChars {
+		tail = tail[len(tail)-resumeTailChars:]
+		for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+			tail = tail[1:]
+		}
+	}
+	return &overlapTrimmer{tail: tail}
+}
+
+// Trim returns the part of content to forward. Content that may still turn
+// out to repeat the partial answer is held back until that is decided.
+func (t *overlapTrimmer) Trim(content string) string {
+	if t.settled {
+		return content
+	}
+	t.held += content
+	if t.mayRepeat() {
+		return ""
+	}
+	return t.Flush()
+}
+
+// TrimChunk trims a chunk's content, flushing what is held back when the
+// chunk finishes the answer, and reports whether the chunk still carries
+// anything to forward
+func (t *overlapTrimmer) TrimChunk(chunk *StreamChunk) bool {
+	finished := false
+	for _, choice := range chunk.Choices {
+		finished = finished || choice.FinishReason != ""
+	}
+
+	chunk.Content = t.Trim(chunk.Content)
+	if finished {
+		chunk.Content += t.Flush()
+	}
+	for i := range chunk.Choices {
+		chunk.Choices[i].Delta.Content = chunk.Content
+	}
+	return chunk.Content != "" || chunk.Reasoning != "" || chunk.Usage != nil || finished ||
+		chunk.Type != "content" || len(chunk.Metadata) > 0
+}
+
+// Flush returns the content still held back, without any repetition, once
+// the stream ends or no more repetition is possible
+func (t *overlapTrimmer) Flush() string {
+	if t.settled {
+		return ""
+	}
+	t.settled = true
+	return t.held[t.overlap():]
+}
+
+// mayRepeat reports whether the held content could be the start of a longer
+// repetition of the tail
+func (t *overlapTrimmer) mayRepeat() bool {
+	for i := 0; i < len(t.tail); i++ {
+		if len(t.tail)-i > len(t.held) && strings.HasPrefix(t.tail[i:], t.held) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlap returns the length of the longest end of the tail the held
+// content starts with
+func (t *overlapTrimmer) overlap() int {
+	for i := 0; i < len(t.tail); i++ {
+		n := len(t.tail) - i
+		if n < minResumeOverlap {
+			break
+		}
+		if strings.HasPrefix(t.held, t.tail[i:]) {
+			return n
+		}
+	}
+	return 0
+}
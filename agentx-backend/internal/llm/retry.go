@@ -0,0 +1,149 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how the gateway retries transient provider failures
+type RetryPolicy struct {
+	MaxRetries int           // Retries after the first attempt
+	BaseDelay  time.Duration // Delay before the first retry
+	MaxDelay   time.Duration // Upper bound for a single backoff
+	Timeout    time.Duration // Per-attempt timeout (0 = no limit)
+}
+
+// DefaultRetryPolicy returns the policy used when a connection sets none
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  500 * time.Millisecond,
+		MaxDelay:   30 * time.Second,
+	}
+}
+
+// ForConfig applies the per-connection overrides from a provider config.
+// A zero MaxRetries keeps the default; a negative value disables retries.
+func (p RetryPolicy) ForConfig(config ProviderConfig) RetryPolicy {
+	if config.MaxRetries > 0 {
+		p.MaxRetries = config.MaxRetries
+	} else if config.MaxRetries < 0 {
+		p.MaxRetries = 0
+	}
+	if config.Timeout > 0 {
+		p.Timeout = config.Timeout
+	}
+	return p
+}
+
+// Backoff returns the wait before the given retry (0-based). Provider hints
+// such as Retry-After win over the computed exponential backoff with jitter.
+func (p RetryPolicy) Backoff(attempt int, err error) time.Duration {
+	if hint := RetryAfter(err); hint > 0 {
+		if p.MaxDelay > 0 && hint > p.MaxDelay {
+			return p.MaxDelay
+		}
+		return hint
+	}
+
+	delay := p.BaseDelay << uint(attempt)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	// Equal jitter: half fixed, half random
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryableStatus is implemented by errors that carry an HTTP status code
+type retryableStatus interface {
+	Status() int
+}
+
+// retryDelayHint is implemented by errors that carry a provider wait hint
+type retryDelayHint interface {
+	RetryDelay() time.Duration
+}
+
+// RetryAfter returns the provider-requested wait carried by err, if any
+func RetryAfter(err error) time.Duration {
+	var hint retryDelayHint
+	if errors.As(err, &hint) {
+		return hint.RetryDelay()
+	}
+	return 0
+}
+
+// IsRetryableError reports whether err is a transient failure worth retrying
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// Never retry caller cancellations or an open breaker
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+
+	var status retryableStatus
+	if errors.As(err, &status) && status.Status() > 0 {
+		code := status.Status()
+		return code == 408 || code == 409 || code == 425 || code == 429 || code >= 500
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	errStr := strings.ToLower(err.Error())
+	for _, marker := range []string{
+		"timeout",
+		"timed out",
+		"connection reset",
+		"connection refused",
+		"broken pipe",
+		"rate limit",
+		"too many requests",
+		"overloaded",
+		"temporarily unavailable",
+		"service unavailable",
+		"bad gateway",
+		"gateway timeout",
+	} {
+		if contains(errStr, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/providers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	assert.True(t, IsRetryableError(errors.New("dial tcp: connection refused")))
+	assert.True(t, IsRetryableError(errors.New("upstream request timeout")))
+	assert.True(t, IsRetryableError(providers.NewHTTPError("openai", 429, errors.New("slow down"), nil)))
+	assert.True(t, IsRetryableError(providers.NewHTTPError("anthropic", 529, errors.New("overloaded"), nil)))
+
+	assert.False(t, IsRetryableError(providers.NewHTTPError("openai", 401, errors.New("bad key"), nil)))
+	assert.False(t, IsRetryableError(context.Canceled))
+	assert.False(t, IsRetryableError(ErrCircuitOpen))
+}
+
+func TestRetryPolicyHonorsRetryAfter(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "2")
+	err := providers.NewHTTPError("openai", 429, errors.New("rate limited"), header)
+
+	policy := DefaultRetryPolicy()
+	assert.Equal(t, 2*time.Second, policy.Backoff(0, err))
+
+	policy.MaxDelay = time.Second
+	assert.Equal(t, time.Second, policy.Backoff(0, err))
+}
+
+func TestGatewayWithRetry(t *testing.T) {
+	g := NewGateway()
+	policy := RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	calls := 0
+	retries, err := g.withRetry(context.Background(), policy, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection reset by peer")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, retries)
+
+	calls = 0
+	retries, err = g.withRetry(context.Background(), policy, func(context.Context) error {
+		calls++
+		return errors.New("invalid api key")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 0, retries)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryPolicyForConnectionSettings(t *testing.T) {
+	policy := func(settings map[string]interface{}) RetryPolicy {
+		var config ProviderConfig
+		config.ApplyConnectionSettings(settings)
+		return DefaultRetryPolicy().ForConfig(config)
+	}
+
+	assert.Equal(t, 5, policy(map[string]interface{}{"max_retries": float64(5)}).MaxRetries)
+	assert.Equal(t, 0, policy(map[string]interface{}{"max_retries": float64(0)}).MaxRetries)
+
+	// A malformed setting keeps the default instead of disabling retries
+	assert.Equal(t, DefaultRetryPolicy().MaxRetries, policy(map[string]interface{}{"max_retries": "five"}).MaxRetries)
+	assert.Equal(t, DefaultRetryPolicy().MaxRetries, policy(nil).MaxRetries)
+}
@@ -3,7 +3,11 @@ package llm
 import (
 	"context"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Router handles intelligent routing of requests to providers
@@ -12,8 +16,40 @@ type Router struct {
 	config        *ConfigManager
 	routingRules  []RoutingRule
 	fallbacks     map[string]string // provider -> fallback provider
-	loadBalancer  LoadBalancer
 	mu            sync.RWMutex
+
+	// Load balancing across the connections of a pool
+	stats      *ConnectionStats
+	balancers  map[string]LoadBalancer
+	balancerMu sync.Mutex
+
+	// Declarative per-user routing loaded from a RoutingStore
+	routingStore RoutingStore
+	routingTTL   time.Duration
+	userRouting  map[string]cachedRouting
+	routingMu    sync.Mutex
+
+	// Inputs for preference-based (speed/cost/privacy) scoring
+	pricing    *PricingCatalog
+	latency    *LatencyTracker
+	modelCache map[string]cachedModels
+	modelMu    sync.Mutex
+
+	// Receives the context windows connections report for their models
+	budgeter *ContextBudgeter
+}
+
+// cachedModels is the model list of a connection and when it was fetched
+type cachedModels struct {
+	models    []string
+	vision    map[string]bool // Models the provider reports as accepting images
+	fetchedAt time.Time
+}
+
+// cachedRouting is a user's routing configuration and when it was loaded
+type cachedRouting struct {
+	config   *RoutingConfig
+	loadedAt time.Time
 }
 
 // RouteInfo contains information about routing decision
@@ -42,10 +78,116 @@ type LoadBalancer interface {
 func NewRouter() *Router {
 	return &Router{
 		fallbacks:    make(map[string]string),
-		loadBalancer: &RoundRobinBalancer{},
+		stats:        NewConnectionStats(),
+		balancers:    make(map[string]LoadBalancer),
+		routingTTL:   time.Minute,
+		userRouting:  make(map[string]cachedRouting),
+		pricing:      DefaultPricingCatalog(),
+		latency:      NewLatencyTracker(),
+		modelCache:   make(map[string]cachedModels),
+	}
+}
+
+// SetPricingCatalog replaces the catalog used for cost-aware routing
+func (r *Router) SetPricingCatalog(catalog *PricingCatalog) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pricing = catalog
+}
+
+// PricingCatalog returns the catalog used for cost-aware routing
+func (r *Router) PricingCatalog() *PricingCatalog {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pricing
+}
+
+// ObserveLatency records a measured latency for a connection and model, and
+// for the connection as a whole
+func (r *Router) ObserveLatency(userID, connectionID, model string, latency time.Duration) {
+	key := r.connectionKey(userID, connectionID)
+	r.latency.Observe(latencyKey(key, model), latency)
+	if model != "" {
+		r.latency.Observe(latencyKey(key, ""), latency)
+	}
+}
+
+// connectionKey returns the provider key for a bare or already prefixed connection ID
+func (r *Router) connectionKey(userID, connectionID string) string {
+	if strings.HasPrefix(connectionID, userID+":") {
+		return connectionID
+	}
+	return fmt.Sprintf("%s:%s", userID, connectionID)
+}
+
+// latencyKey identifies a connection and model in the latency tracker; an
+// empty model stands for the connection across all models
+func latencyKey(providerKey, model string) string {
+	return providerKey + "|" + model
+}
+
+// SetRoutingStore sets the store declarative rules and fallback chains are loaded from
+func (r *Router) SetRoutingStore(store RoutingStore) {
+	r.mu.Lock()
+	r.routingStore = store
+	r.mu.Unlock()
+
+	r.InvalidateRouting("")
+}
+
+// InvalidateRouting drops the cached routing configuration of a user, or of
+// all users when userID is empty
+func (r *Router) InvalidateRouting(userID string) {
+	r.routingMu.Lock()
+	if userID == "" {
+		r.userRouting = make(map[string]cachedRouting)
+	} else {
+		delete(r.userRouting, userID)
+	}
+	r.routingMu.Unlock()
+
+	// Pools may have changed members or weights
+	r.balancerMu.Lock()
+	defer r.balancerMu.Unlock()
+	for key := range r.balancers {
+		if userID == "" || strings.HasPrefix(key, userID+"|") {
+			delete(r.balancers, key)
+		}
 	}
 }
 
+// routingFor returns the user's declarative routing configuration, loading
+// it from the store when the cached copy is missing or stale
+func (r *Router) routingFor(ctx context.Context, userID string) *RoutingConfig {
+	r.mu.RLock()
+	store := r.routingStore
+	r.mu.RUnlock()
+	if store == nil || userID == "" {
+		return nil
+	}
+
+	r.routingMu.Lock()
+	cached, ok := r.userRouting[userID]
+	r.routingMu.Unlock()
+	if ok && time.Since(cached.loadedAt) < r.routingTTL {
+		return cached.config
+	}
+
+	config, err := store.LoadRouting(ctx, userID)
+	if err != nil {
+		fmt.Printf("[Router] Failed to load routing config for user %s: %v\n", userID, err)
+		// Keep serving the stale copy rather than dropping the user's rules
+		return cached.config
+	}
+
+	r.routingMu.Lock()
+	r.userRouting[userID] = cachedRouting{config: config, loadedAt: time.Now()}
+	r.routingMu.Unlock()
+
+	return config
+}
+
+
 // SetProviderManager sets the provider manager
 func (r *Router) SetProviderManager(pm *ProviderManager) {
 	r.mu.Lock()
@@ -60,13 +202,33 @@ func (r *Router) SetConfigManager(cm *ConfigManager) {
 	r.config = cm
 }
 
-// Route determines the best provider for a request
+// SetContextBudgeter sets the budgeter that learns the context windows
+// connections report when their models are listed
+func (r *Router) SetContextBudgeter(b *ContextBudgeter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.budgeter = b
+}
+
+// Route determines the best provider for a request. The connection ID of the
+// returned route is always bare, without the user prefix.
 func (r *Router) Route(ctx context.Context, req *Request) (Provider, *RouteInfo, error) {
+	provider, info, err := r.resolve(ctx, req)
+	if info != nil {
+		info.ConnectionID = strings.TrimPrefix(info.ConnectionID, req.UserID+":")
+	}
+	return provider, info, err
+}
+
+// resolve picks the provider for a request in priority order
+func (r *Router) resolve(ctx context.Context, req *Request) (Provider, *RouteInfo, error) {
+	routing := r.routingFor(ctx, req.UserID)
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	// Priority 1: Use explicit connection ID if provided
-	if req.ConnectionID != "" {
+	if req.ConnectionID != "" && r.admits(req, req.ConnectionID) {
 		provider, err := r.providers.GetProvider(req.UserID, req.ConnectionID)
 		if err == nil {
 			return provider, &RouteInfo{
@@ -79,42 +241,70 @@ func (r *Router) Route(ctx context.Context, req *Request) (Provider, *RouteInfo,
 	}
 
 	// Priority 2: Use preferences if specified
-	if req.Preferences.ConnectionID != "" {
+	if req.Preferences.ConnectionID != "" && r.admits(req, req.Preferences.ConnectionID) {
 		provider, err := r.providers.GetProvider(req.UserID, req.Preferences.ConnectionID)
 		if err == nil {
 			model := req.Model
 			if model == "" {
 				model = req.Preferences.Model
 			}
-			return provider, &RouteInfo{
+			info := &RouteInfo{
 				Provider:     req.Preferences.Provider,
 				ConnectionID: req.Preferences.ConnectionID,
 				Model:        model,
 				Reason:       "user preferences",
-			}, nil
+			}
+			
+			// Let speed/cost preferences pick the model on the chosen connection
+			if model == "" && (req.Preferences.HasTradeoffs() || req.HasImages()) {
+				key := r.connectionKey(req.UserID, req.Preferences.ConnectionID)
+				if best, score, reasons := r.bestModel(ctx, req, key, provider); best != "" {
+					info.Model = best
+					info.Score = score
+					info.Reason = "user preferences: " + strings.Join(reasons, ", ")
+				}
+			}
+			return provider, info, nil
 		}
 	}
 
-	// Priority 3: Apply routing rules
+	// Priority 3: Apply the user's declarative routing rules
+	if routing != nil {
+		for _, rule := range routing.Rules {
+			if !rule.Match.Matches(req) {
+				continue
+			}
+			if provider, info := r.resolveRuleTarget(req, rule); provider != nil && r.admits(req, info.ConnectionID) {
+				return provider, info, nil
+			}
+		}
+	}
+
+	// Priority 4: Apply built-in routing rules
 	for _, rule := range r.routingRules {
 		if rule.Condition(req) {
 			if info := rule.Action(req); info != nil {
 				key := fmt.Sprintf("%s:%s", req.UserID, info.ConnectionID)
 				provider, err := r.providers.GetProviderByKey(key)
-				if err == nil {
+				if err == nil && r.admits(req, key) {
 					return provider, info, nil
 				}
 			}
 		}
 	}
 
-	// Priority 4: Find best provider based on requirements
-	bestProvider, bestInfo := r.findBestProvider(ctx, req)
+	// Priority 5: Find best provider based on requirements
+	bestProvider, bestInfo := r.findBestProvider(ctx, req, routing)
 	if bestProvider != nil {
 		return bestProvider, bestInfo, nil
 	}
 
-	// Priority 5: Get default provider for user
+	// Never fall back to a cloud provider when the user asked for local processing
+	if req.Preferences.Privacy == "local" {
+		return nil, nil, fmt.Errorf("no local provider available for user %s (privacy=local)", req.UserID)
+	}
+
+	// Priority 6: Get default provider for user
 	userProviders := r.providers.GetUserProviders(req.UserID)
 	if len(userProviders) > 0 {
 		// Take the first available provider
@@ -131,28 +321,134 @@ func (r *Router) Route(ctx context.Context, req *Request) (Provider, *RouteInfo,
 	return nil, nil, fmt.Errorf("no suitable provider found for user %s", req.UserID)
 }
 
-// findBestProvider finds the best provider based on requirements
-func (r *Router) findBestProvider(ctx context.Context, req *Request) (Provider, *RouteInfo) {
+// admits reports whether a connection may serve the request at all. Requests
+// with privacy=local only go to connections on local infrastructure, however
+// they were selected.
+func (r *Router) admits(req *Request, connectionID string) bool {
+	if req.Preferences.Privacy == "local" {
+		config, ok := r.providers.GetConfig(req.UserID, connectionID)
+		if !ok || !config.IsLocal() {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveRuleTarget returns the provider a matched declarative rule points
+// at, or nil if the target is not registered for the user
+func (r *Router) resolveRuleTarget(req *Request, rule DeclarativeRule) (Provider, *RouteInfo) {
+	var provider Provider
+	var key string
+
+	switch {
+	case rule.Target.ConnectionID != "":
+		p, err := r.providers.GetProvider(req.UserID, rule.Target.ConnectionID)
+		if err != nil {
+			return nil, nil
+		}
+		provider, key = p, rule.Target.ConnectionID
+	case rule.Target.ProviderType != "":
+		provider, key = r.providers.FindUserProviderByType(req.UserID, rule.Target.ProviderType)
+		if provider == nil {
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+
+	model := rule.Target.Model
+	if model == "" {
+		model = req.RequestedModel()
+	}
+
+	return provider, &RouteInfo{
+		Provider:     key,
+		ConnectionID: key,
+		Model:        model,
+		Reason:       rule.Describe(),
+	}
+}
+
+// findBestProvider finds the best provider based on requirements. When the
+// request carries speed/cost/privacy preferences and no explicit model, every
+// connection+model pair is scored and the best pair wins. If the winner
+// belongs to a connection pool, the pool's strategy picks among its eligible
+// members instead.
+func (r *Router) findBestProvider(ctx context.Context, req *Request, routing *RoutingConfig) (Provider, *RouteInfo) {
 	userProviders := r.providers.GetUserProviders(req.UserID)
 	if len(userProviders) == 0 {
 		return nil, nil
 	}
 
+	// Iterate in a stable order so ties resolve consistently
+	keys := make([]string, 0, len(userProviders))
+	for key := range userProviders {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
 	var bestProvider Provider
 	var bestInfo *RouteInfo
 	bestScore := -1.0
+	eligible := make(map[string]*RouteInfo)
 
-	for key, provider := range userProviders {
+	for _, key := range keys {
+		if !r.admits(req, key) {
+			continue
+		}
+		provider := userProviders[key]
 		score := r.scoreProvider(provider, req)
+		if score < 0 {
+			continue
+		}
+
+		model := req.Model
+		reason := "best match for requirements"
+		if req.Preferences.HasTradeoffs() || req.HasImages() {
+			best, modelScore, reasons := r.bestModel(ctx, req, key, provider)
+			if best == "" {
+				continue
+			}
+			if req.RequestedModel() == "" {
+				model = best
+			}
+			score += modelScore
+			reason = "preferences: " + strings.Join(reasons, ", ")
+		}
+
+		info := &RouteInfo{
+			Provider:     key,
+			ConnectionID: key,
+			Model:        model,
+			Score:        score,
+			Reason:       reason,
+		}
+		eligible[key] = info
 		if score > bestScore {
 			bestScore = score
 			bestProvider = provider
-			bestInfo = &RouteInfo{
-				Provider:     key,
-				ConnectionID: key,
-				Model:        req.Model,
-				Score:        score,
-				Reason:       "best match for requirements",
+			bestInfo = info
+		}
+	}
+
+	// Spread traffic across the pool the best connection belongs to
+	if bestInfo != nil {
+		if pool, ok := poolFor(routing, req.UserID, bestInfo.ConnectionID); ok {
+			var members []string
+			for _, member := range pool.Members {
+				key := r.connectionKey(req.UserID, member.ConnectionID)
+				if _, ok := eligible[key]; ok {
+					members = append(members, key)
+				}
+			}
+			if selected := r.poolBalancer(req.UserID, pool).SelectProvider(members, req); selected != "" {
+				info := *eligible[selected]
+				strategy := pool.Strategy
+				if strategy == "" {
+					strategy = BalanceRoundRobin
+				}
+				info.Reason = fmt.Sprintf("pool %q (%s): %s", pool.Name, strategy, info.Reason)
+				return userProviders[selected], &info
 			}
 		}
 	}
@@ -160,6 +456,213 @@ func (r *Router) findBestProvider(ctx context.Context, req *Request) (Provider,
 	return bestProvider, bestInfo
 }
 
+// bestModel scores the models a connection offers against the request's
+// speed/cost/privacy preferences and returns the best one. An explicitly
+// requested model is the only candidate when set; otherwise requests with
+// images only consider vision models.
+func (r *Router) bestModel(ctx context.Context, req *Request, key string, provider Provider) (string, float64, []string) {
+	config, _ := r.providers.GetConfig(req.UserID, key)
+
+	requested := req.RequestedModel()
+	candidates := []string{requested}
+	if requested == "" {
+		if models := r.candidateModels(ctx, key, provider); len(models) > 0 {
+			candidates = models
+		}
+	}
+
+	var best string
+	var bestReasons []string
+	bestScore := math.Inf(-1)
+	for _, model := range candidates {
+		if requested == "" && req.HasImages() && !r.acceptsImages(key, model) {
+			continue
+		}
+		score, reasons, ok := r.scoreModel(req.Preferences, config, key, model)
+		if ok && score > bestScore {
+			best, bestScore, bestReasons = model, score, reasons
+		}
+	}
+	if best == "" {
+		return "", 0, nil
+	}
+	if requested == "" && req.HasImages() {
+		bestReasons = append([]string{"vision"}, bestReasons...)
+	}
+	return best, bestScore, bestReasons
+}
+
+// scoreModel scores one connection+model pair using the pricing catalog,
+// measured latency and the connection's local/cloud flag. It returns false
+// when the pair violates a hard preference such as privacy=local.
+func (r *Router) scoreModel(prefs Preferences, config ProviderConfig, key, model string) (float64, []string, bool) {
+	score := 0.0
+	var reasons []string
+
+	pricing, priced := r.pricing.PriceFor(config, model)
+	blended := pricing.Blended()
+
+	switch prefs.Privacy {
+	case "local":
+		if !config.IsLocal() {
+			return 0, nil, false
+		}
+		score += 50
+		reasons = append(reasons, "privacy=local")
+	case "cloud":
+		if config.IsLocal() {
+			score -= 20
+		} else {
+			score += 10
+			reasons = append(reasons, "privacy=cloud")
+		}
+	}
+
+	switch prefs.Cost {
+	case "economy":
+		if priced {
+			score += 30 / (1 + blended)
+			reasons = append(reasons, fmt.Sprintf("cost=economy ($%.2f/1M tokens)", blended))
+		}
+	case "premium":
+		if priced {
+			score += math.Min(20, 5*math.Log2(1+blended))
+			reasons = append(reasons, fmt.Sprintf("cost=premium ($%.2f/1M tokens)", blended))
+		}
+	}
+
+	switch prefs.Speed {
+	case "fast":
+		if latency, ok := r.latency.Estimate(latencyKey(key, model)); ok {
+			score += 30 * 1000 / (1000 + float64(latency.Milliseconds()))
+			reasons = append(reasons, fmt.Sprintf("speed=fast (%dms measured)", latency.Milliseconds()))
+		} else if priced {
+			// Without measurements, cheaper models are usually the faster ones
+			score += 10 / (1 + blended)
+			reasons = append(reasons, "speed=fast (unmeasured)")
+		}
+	case "quality":
+		if priced {
+			score += math.Min(30, 8*math.Log2(1+blended))
+			reasons = append(reasons, "speed=quality")
+		}
+	}
+
+	if model != "" {
+		reasons = append(reasons, "model="+model)
+	}
+	return score, reasons, true
+}
+
+// candidateModels returns the chat models a connection offers, cached for a
+// few minutes. Falls back to the provider's static list if listing fails.
+// Context windows reported with the models are passed to the budgeter.
+func (r *Router) candidateModels(ctx context.Context, key string, provider Provider) []string {
+	r.modelMu.Lock()
+	cached, ok := r.modelCache[key]
+	r.modelMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < 5*time.Minute {
+		return cached.models
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	r.mu.RLock()
+	budgeter := r.budgeter
+	r.mu.RUnlock()
+
+	var models []string
+	vision := make(map[string]bool)
+	if infos, err := provider.GetModels(listCtx); err == nil {
+		for _, info := range infos {
+			if info.ContextLength > 0 && budgeter != nil {
+				budgeter.SetContextWindow(info.ID, info.ContextLength)
+			}
+			if isChatModel(info.ID) {
+				models = append(models, info.ID)
+			}
+			if containsString(info.Capabilities, "vision") {
+				vision[info.ID] = true
+			}
+		}
+	}
+	if len(models) == 0 {
+		models = provider.GetCapabilities().SupportedModels
+	}
+
+	r.modelMu.Lock()
+	r.modelCache[key] = cachedModels{models: models, vision: vision, fetchedAt: time.Now()}
+	r.modelMu.Unlock()
+
+	return models
+}
+
+// learnContextWindows lists a connection's models unless they were listed
+// recently, so the budgeter knows the windows the connection reports
+func (r *Router) learnContextWindows(ctx context.Context, userID, connectionID string, provider Provider) {
+	r.candidateModels(ctx, r.connectionKey(userID, connectionID), provider)
+}
+
+// acceptsImages reports whether a connection's model accepts image input,
+// as reported by the provider or known from its model family
+func (r *Router) acceptsImages(key, model string) bool {
+	r.modelMu.Lock()
+	cached, ok := r.modelCache[key]
+	r.modelMu.Unlock()
+	if ok && cached.vision[model] {
+		return true
+	}
+	return isVisionModel(model)
+}
+
+// servesImages reports whether a connection can answer a request with
+// images using the given model, or the request's own model when empty
+func (r *Router) servesImages(key string, provider Provider, model string) bool {
+	if !provider.GetCapabilities().Vision {
+		return false
+	}
+	return model == "" || r.acceptsImages(key, model)
+}
+
+// isChatModel filters out embedding, audio and image models from model listings
+func isChatModel(model string) bool {
+	lower := strings.ToLower(model)
+	for _, marker := range []string{"embed", "whisper", "tts", "dall-e", "moderation", "davinci", "babbage", "audio", "realtime", "transcribe", "image"} {
+		if strings.Contains(lower, marker) {
+			return false
+		}
+	}
+	return true
+}
+
+// isVisionModel reports whether a model accepts image input, judged by its
+// model family
+func isVisionModel(model string) bool {
+	lower := strings.ToLower(model)
+	for _, marker := range []string{"o1-mini", "o1-preview", "o3-mini", "gpt-4o-audio", "gpt-4o-realtime", "embed"} {
+		if strings.Contains(lower, marker) {
+			return false
+		}
+	}
+	for _, prefix := range []string{"o1", "o3", "o4"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	for _, marker := range []string{
+		"gpt-4o", "gpt-4-turbo", "gpt-4.1", "gpt-4.5", "gpt-5", "vision",
+		"claude-3", "claude-sonnet-4", "claude-opus-4", "claude-haiku-4",
+		"gemini", "llava", "bakllava", "moondream", "minicpm-v", "gemma3",
+		"pixtral", "qwen2-vl", "qwen2.5vl", "qwen2.5-vl",
+	} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
 // scoreProvider scores a provider based on request requirements
 func (r *Router) scoreProvider(provider Provider, req *Request) float64 {
 	score := 0.0
@@ -179,6 +682,11 @@ func (r *Router) scoreProvider(provider Provider, req *Request) float64 {
 		return -1.0 // Disqualify
 	}
 
+	// Images can only go to providers that accept them
+	if req.HasImages() && !caps.Vision {
+		return -1.0 // Disqualify
+	}
+
 	// Check model support
 	if req.Model != "" {
 		for _, model := range caps.SupportedModels {
@@ -205,6 +713,10 @@ func (r *Router) scoreProvider(provider Provider, req *Request) float64 {
 			if caps.AudioInput || caps.AudioOutput {
 				score += 5.0
 			}
+		case "embeddings":
+			if !caps.Embeddings {
+				return -1.0 // Disqualify
+			}
 		}
 	}
 
@@ -229,6 +741,66 @@ func (r *Router) GetFallback(providerKey string) Provider {
 	return provider
 }
 
+// FallbackChain returns the providers to try, in order, after the given
+// connection fails on the request. The user's persisted chain for that
+// connection wins over their default chain; in-memory fallbacks set via
+// SetFallback come last. Connections the request may not use, and those
+// that cannot read its images, are skipped.
+func (r *Router) FallbackChain(ctx context.Context, req *Request, connectionID string) []FallbackTarget {
+	userID := req.UserID
+	routing := r.routingFor(ctx, userID)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	connectionID = strings.TrimPrefix(connectionID, userID+":")
+	primaryKey := fmt.Sprintf("%s:%s", userID, connectionID)
+	seen := map[string]bool{primaryKey: true}
+	var targets []FallbackTarget
+
+	add := func(key, model string) {
+		if seen[key] {
+			return
+		}
+		provider, err := r.providers.GetProviderByKey(key)
+		if err != nil || !r.admits(req, key) {
+			return
+		}
+		if req.HasImages() && !r.servesImages(key, provider, model) {
+			return
+		}
+		seen[key] = true
+		targets = append(targets, FallbackTarget{Key: key, Model: model, Provider: provider})
+	}
+
+	if routing != nil {
+		var chain *FallbackChain
+		for i := range routing.Chains {
+			c := &routing.Chains[i]
+			if c.PrimaryConnectionID == connectionID {
+				chain = c
+				break
+			}
+			if c.PrimaryConnectionID == "" && chain == nil {
+				chain = c
+			}
+		}
+		if chain != nil {
+			for _, step := range chain.Steps {
+				add(fmt.Sprintf("%s:%s", userID, step.ConnectionID), step.Model)
+			}
+		}
+	}
+
+	if fallbackKey, exists := r.fallbacks[primaryKey]; exists {
+		add(fallbackKey, "")
+	} else if fallbackKey, exists := r.fallbacks[connectionID]; exists {
+		add(fallbackKey, "")
+	}
+
+	return targets
+}
+
 // SetFallback sets a fallback provider
 func (r *Router) SetFallback(primary, fallback string) {
 	r.mu.Lock()
@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newPreferenceGateway(t *testing.T) *Gateway {
+	g := NewGateway(WithMiddleware(&BaseMiddleware{}))
+	g.providers.factory = fakeFactory{
+		"cloud": &fakeProvider{models: []string{"gpt-4o", "gpt-4o-mini", "text-embedding-3-small"}},
+		"local": &fakeProvider{models: []string{"llama3"}},
+	}
+	assert.NoError(t, g.RegisterProvider("user", "cloud", ProviderConfig{Name: "cloud", Type: "openai"}))
+	assert.NoError(t, g.RegisterProvider("user", "local", ProviderConfig{Name: "local", Type: "openai", BaseURL: "http://localhost:11434/v1"}))
+	return g
+}
+
+func TestRoutePreferences(t *testing.T) {
+	g := newPreferenceGateway(t)
+	route := func(prefs Preferences) *RouteInfo {
+		_, info, err := g.router.Route(context.Background(), &Request{
+			UserID:      "user",
+			Messages:    []Message{{Role: "user", Content: "hi"}},
+			Preferences: prefs,
+		})
+		assert.NoError(t, err)
+		return info
+	}
+
+	info := route(Preferences{Privacy: "local"})
+	assert.Equal(t, "local", info.ConnectionID)
+	assert.Equal(t, "llama3", info.Model)
+	assert.Contains(t, info.Reason, "privacy=local")
+
+	info = route(Preferences{Privacy: "cloud", Cost: "economy"})
+	assert.Equal(t, "cloud", info.ConnectionID)
+	assert.Equal(t, "gpt-4o-mini", info.Model)
+	assert.Greater(t, info.Score, 0.0)
+
+	info = route(Preferences{Privacy: "cloud", Speed: "quality"})
+	assert.Equal(t, "gpt-4o", info.Model)
+
+	// Measured latency beats the price heuristic
+	g.router.ObserveLatency("user", "cloud", "gpt-4o", 200*time.Millisecond)
+	g.router.ObserveLatency("user", "cloud", "gpt-4o-mini", 5*time.Second)
+	info = route(Preferences{Privacy: "cloud", Speed: "fast"})
+	assert.Equal(t, "gpt-4o", info.Model)
+	assert.Contains(t, info.Reason, "200ms measured")
+}
+
+func TestRoutePrivacyLocalNeverUsesCloud(t *testing.T) {
+	g := NewGateway(WithMiddleware(&BaseMiddleware{}))
+	g.providers.factory = fakeFactory{"cloud": &fakeProvider{models: []string{"gpt-4o"}}}
+	assert.NoError(t, g.RegisterProvider("user", "cloud", ProviderConfig{Name: "cloud", Type: "openai"}))
+
+	_, _, err := g.router.Route(context.Background(), &Request{
+		UserID:      "user",
+		Messages:    []Message{{Role: "user", Content: "hi"}},
+		Preferences: Preferences{Privacy: "local"},
+	})
+	assert.Error(t, err)
+
+	// Neither an explicit connection nor a remote Ollama server counts as local
+	g.providers.factory = fakeFactory{"cloud": &fakeProvider{}, "remote-ollama": &fakeProvider{}}
+	assert.NoError(t, g.RegisterProvider("user", "remote-ollama", ProviderConfig{Name: "remote-ollama", Type: "ollama", BaseURL: "https://ollama.example.com"}))
+	_, _, err = g.router.Route(context.Background(), &Request{
+		UserID:       "user",
+		ConnectionID: "cloud",
+		Messages:     []Message{{Role: "user", Content: "hi"}},
+		Preferences:  Preferences{Privacy: "local"},
+	})
+	assert.Error(t, err)
+}
+
+func TestFallbackChainKeepsPrivacyLocal(t *testing.T) {
+	g := newPreferenceGateway(t)
+	g.router.SetFallback("user:local", "user:cloud")
+	req := &Request{UserID: "user", Preferences: Preferences{Privacy: "local"}}
+
+	assert.Empty(t, g.router.FallbackChain(context.Background(), req, "local"))
+	req.Preferences.Privacy = ""
+	assert.Len(t, g.router.FallbackChain(context.Background(), req, "local"), 1)
+}
+
+func TestRouteImagesToVisionModel(t *testing.T) {
+	g := NewGateway(WithMiddleware(&BaseMiddleware{}))
+	g.providers.factory = fakeFactory{
+		"cloud": &fakeProvider{models: []string{"gpt-3.5-turbo", "gpt-4o"}, vision: true},
+		"text":  &fakeProvider{models: []string{"mistral"}},
+	}
+	assert.NoError(t, g.RegisterProvider("user", "cloud", ProviderConfig{Name: "cloud", Type: "openai"}))
+	assert.NoError(t, g.RegisterProvider("user", "text", ProviderConfig{Name: "text", Type: "openai"}))
+
+	req := &Request{
+		UserID: "user",
+		Messages: []Message{{Role: "user", Content: "What is this?", Images: []Image{
+			{Data: "aGk=", MimeType: "image/png"},
+		}}},
+	}
+	assert.Contains(t, req.RequiredCapabilities(), "vision")
+
+	_, info, err := g.router.Route(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "cloud", info.ConnectionID)
+	assert.Equal(t, "gpt-4o", info.Model)
+	assert.Contains(t, info.Reason, "vision")
+}
+
+func TestFallbackChainSkipsConnectionsWithoutVision(t *testing.T) {
+	g := NewGateway(WithMiddleware(&BaseMiddleware{}))
+	g.providers.factory = fakeFactory{
+		"primary":   &fakeProvider{vision: true},
+		"text-only": &fakeProvider{},
+		"vision":    &fakeProvider{vision: true},
+	}
+	for _, name := range []string{"primary", "text-only", "vision"} {
+		assert.NoError(t, g.RegisterProvider("user", name, ProviderConfig{Name: name}))
+	}
+	g.router.SetRoutingStore(&staticRoutingStore{config: &RoutingConfig{
+		Chains: []FallbackChain{{
+			PrimaryConnectionID: "primary",
+			Steps:               []FallbackStep{{ConnectionID: "text-only"}, {ConnectionID: "vision", Model: "text-embedding-3-small"}, {ConnectionID: "vision", Model: "gpt-4o"}},
+		}},
+	}})
+
+	req := &Request{UserID: "user", Messages: []Message{{Role: "user", Content: "what is this?", Images: []Image{{URL: "https://example.com/cat.png"}}}}}
+	targets := g.router.FallbackChain(context.Background(), req, "primary")
+	if assert.Len(t, targets, 1) {
+		assert.Equal(t, "user:vision", targets[0].Key)
+		assert.Equal(t, "gpt-4o", targets[0].Model)
+	}
+}
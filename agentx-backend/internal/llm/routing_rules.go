@@ -0,0 +1,243 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// MetadataTaskType is the request metadata key carrying the task type
+// (e.g. "chat", "generate_title") that routing rules can match on
+const MetadataTaskType = "task_type"
+
+// RuleMatch holds the conditions of a declarative routing rule. Unset fields
+// match any request; every set field must match.
+type RuleMatch struct {
+	Models          []string `json:"models,omitempty"`            // Model names or globs such as "gpt-4*"
+	Capabilities    []string `json:"capabilities,omitempty"`      // Required capabilities, e.g. "tools", "vision"
+	MinMessageChars int      `json:"min_message_chars,omitempty"` // Total message size lower bound
+	MaxMessageChars int      `json:"max_message_chars,omitempty"` // Total message size upper bound
+	TaskTypes       []string `json:"task_types,omitempty"`
+	UserIDs         []string `json:"user_ids,omitempty"`
+}
+
+// RuleTarget describes where a matching request is sent
+type RuleTarget struct {
+	ConnectionID string `json:"connection_id,omitempty"`
+	ProviderType string `json:"provider_type,omitempty"` // Any of the user's connections of this type
+	Model        string `json:"model,omitempty"`         // Overrides the requested model when set
+}
+
+// DeclarativeRule is a data-driven routing rule loaded from storage
+type DeclarativeRule struct {
+	ID       string
+	Name     string
+	Priority int
+	Match    RuleMatch
+	Target   RuleTarget
+}
+
+// FallbackStep is one entry of an ordered fallback chain
+type FallbackStep struct {
+	ConnectionID string `json:"connection_id"`
+	Model        string `json:"model,omitempty"` // Empty keeps the requested model
+}
+
+// FallbackChain lists the connections to try, in order, when the primary fails
+type FallbackChain struct {
+	Name                string
+	PrimaryConnectionID string // Empty applies the chain to any primary
+	Steps               []FallbackStep
+}
+
+// PoolMember is one connection of a connection pool
+type PoolMember struct {
+	ConnectionID string  `json:"connection_id"`
+	Weight       float64 `json:"weight,omitempty"` // Used by weighted_random; defaults to 1
+}
+
+// ConnectionPool groups equivalent connections, such as several keys for the
+// same provider, that the router spreads traffic across
+type ConnectionPool struct {
+	Name     string
+	Strategy string // One of BalancingStrategies; empty is round robin
+	Members  []PoolMember
+}
+
+// RoutingConfig is the declarative routing configuration of a user
+type RoutingConfig struct {
+	Rules  []DeclarativeRule // Sorted by descending priority
+	Chains []FallbackChain
+	Pools  []ConnectionPool
+}
+
+// RoutingStore loads declarative routing configuration for a user
+type RoutingStore interface {
+	LoadRouting(ctx context.Context, userID string) (*RoutingConfig, error)
+}
+
+// FallbackTarget is a provider to try after the routed one fails
+type FallbackTarget struct {
+	Key      string // Provider key (userID:connectionID)
+	Model    string
+	Provider Provider
+}
+
+// ConnectionID returns the target's connection ID without the user prefix
+func (t FallbackTarget) ConnectionID(userID string) string {
+	return strings.TrimPrefix(t.Key, userID+":")
+}
+
+// Matches reports whether the rule conditions apply to the request
+func (m RuleMatch) Matches(req *Request) bool {
+	if len(m.UserIDs) > 0 && !containsString(m.UserIDs, req.UserID) {
+		return false
+	}
+
+	if len(m.TaskTypes) > 0 && !containsString(m.TaskTypes, req.TaskType()) {
+		return false
+	}
+
+	if len(m.Models) > 0 {
+		model := req.RequestedModel()
+		matched := false
+		for _, pattern := range m.Models {
+			if ok, err := path.Match(pattern, model); (err == nil && ok) || pattern == model {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(m.Capabilities) > 0 {
+		required := req.RequiredCapabilities()
+		for _, capability := range m.Capabilities {
+			if !containsString(required, capability) {
+				return false
+			}
+		}
+	}
+
+	if m.MinMessageChars > 0 || m.MaxMessageChars > 0 {
+		size := req.MessageChars()
+		if m.MinMessageChars > 0 && size < m.MinMessageChars {
+			return false
+		}
+		if m.MaxMessageChars > 0 && size > m.MaxMessageChars {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Describe returns a short human-readable form of the rule for RouteInfo.Reason
+func (r DeclarativeRule) Describe() string {
+	var conditions []string
+	if len(r.Match.Models) > 0 {
+		conditions = append(conditions, "model="+strings.Join(r.Match.Models, "|"))
+	}
+	if len(r.Match.Capabilities) > 0 {
+		conditions = append(conditions, "capabilities="+strings.Join(r.Match.Capabilities, ","))
+	}
+	if r.Match.MinMessageChars > 0 {
+		conditions = append(conditions, fmt.Sprintf("size>=%d", r.Match.MinMessageChars))
+	}
+	if r.Match.MaxMessageChars > 0 {
+		conditions = append(conditions, fmt.Sprintf("size<=%d", r.Match.MaxMessageChars))
+	}
+	if len(r.Match.TaskTypes) > 0 {
+		conditions = append(conditions, "task="+strings.Join(r.Match.TaskTypes, "|"))
+	}
+	if len(r.Match.UserIDs) > 0 {
+		conditions = append(conditions, "user")
+	}
+
+	if len(conditions) == 0 {
+		return fmt.Sprintf("routing rule %q", r.Name)
+	}
+	return fmt.Sprintf("routing rule %q (%s)", r.Name, strings.Join(conditions, ", "))
+}
+
+// TaskType returns the task type recorded in the request metadata
+func (r *Request) TaskType() string {
+	if r.Metadata == nil {
+		return ""
+	}
+	task, _ := r.Metadata[MetadataTaskType].(string)
+	return task
+}
+
+// APIKeyID returns the ID of the API key that authenticated the request, if any
+func (r *Request) APIKeyID() string {
+	if r.Metadata == nil {
+		return ""
+	}
+	id, _ := r.Metadata[MetadataAPIKeyID].(string)
+	return id
+}
+
+// RequestedModel returns the explicitly requested model, falling back to
+// the preferred one
+func (r *Request) RequestedModel() string {
+	if r.Model != "" {
+		return r.Model
+	}
+	return r.Preferences.Model
+}
+
+// RequiredCapabilities lists the capabilities the request needs from a provider
+func (r *Request) RequiredCapabilities() []string {
+	var caps []string
+	if r.Stream {
+		caps = append(caps, "streaming")
+	}
+	if len(r.Tools) > 0 || r.Requirements.RequireTools {
+		caps = append(caps, "tools")
+	}
+	if r.ResponseFormat.Structured() {
+		caps = append(caps, "json_mode")
+	}
+	if r.HasImages() {
+		caps = append(caps, "vision")
+	}
+	for _, capability := range r.Preferences.Capabilities {
+		if !containsString(caps, capability) {
+			caps = append(caps, capability)
+		}
+	}
+	return caps
+}
+
+// HasImages reports whether any message carries images
+func (r *Request) HasImages() bool {
+	for _, msg := range r.Messages {
+		if len(msg.Images) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// MessageChars returns the total size of the message contents in characters
+func (r *Request) MessageChars() int {
+	size := 0
+	for _, msg := range r.Messages {
+		size += len(msg.Content)
+	}
+	return size
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// staticRoutingStore serves a fixed routing configuration
+type staticRoutingStore struct {
+	config *RoutingConfig
+	loads  int
+}
+
+func (s *staticRoutingStore) LoadRouting(ctx context.Context, userID string) (*RoutingConfig, error) {
+	s.loads++
+	return s.config, nil
+}
+
+func TestRuleMatch(t *testing.T) {
+	req := &Request{
+		UserID:   "user",
+		Model:    "gpt-4o-mini",
+		Messages: []Message{{Role: "user", Content: strings.Repeat("x", 500)}},
+		Tools:    []Tool{{Type: "function"}},
+		Metadata: map[string]interface{}{MetadataTaskType: "chat"},
+	}
+
+	assert.True(t, RuleMatch{}.Matches(req))
+	assert.True(t, RuleMatch{Models: []string{"gpt-4o*"}}.Matches(req))
+	assert.False(t, RuleMatch{Models: []string{"claude-*"}}.Matches(req))
+	assert.True(t, RuleMatch{Capabilities: []string{"tools"}}.Matches(req))
+	assert.False(t, RuleMatch{Capabilities: []string{"vision"}}.Matches(req))
+	assert.True(t, RuleMatch{MinMessageChars: 100, MaxMessageChars: 1000}.Matches(req))
+	assert.False(t, RuleMatch{MinMessageChars: 1000}.Matches(req))
+	assert.True(t, RuleMatch{TaskTypes: []string{"chat"}}.Matches(req))
+	assert.False(t, RuleMatch{TaskTypes: []string{"generate_title"}}.Matches(req))
+	assert.False(t, RuleMatch{UserIDs: []string{"someone-else"}}.Matches(req))
+}
+
+func TestRouteAppliesDeclarativeRules(t *testing.T) {
+	g := newTestGateway(t, fakeFactory{"cloud": &fakeProvider{}, "local": &fakeProvider{}})
+	store := &staticRoutingStore{config: &RoutingConfig{
+		Rules: []DeclarativeRule{{
+			Name:   "big-prompts-local",
+			Match:  RuleMatch{MinMessageChars: 100},
+			Target: RuleTarget{ConnectionID: "local", Model: "llama3"},
+		}},
+	}}
+	g.router.SetRoutingStore(store)
+
+	_, info, err := g.router.Route(context.Background(), &Request{
+		UserID:   "user",
+		Messages: []Message{{Role: "user", Content: strings.Repeat("x", 200)}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "local", info.ConnectionID)
+	assert.Equal(t, "llama3", info.Model)
+	assert.Contains(t, info.Reason, "big-prompts-local")
+
+	// Cached until invalidated
+	g.router.Route(context.Background(), &Request{UserID: "user", Messages: []Message{{Role: "user", Content: "hi"}}})
+	assert.Equal(t, 1, store.loads)
+	g.router.InvalidateRouting("user")
+	g.router.Route(context.Background(), &Request{UserID: "user", Messages: []Message{{Role: "user", Content: "hi"}}})
+	assert.Equal(t, 2, store.loads)
+}
+
+func TestCompleteWalksFallbackChain(t *testing.T) {
+	primary := &fakeProvider{failErr: errors.New("invalid api key")}
+	secondary := &fakeProvider{failErr: errors.New("invalid api key")}
+	local := &fakeProvider{}
+	g := newTestGateway(t, fakeFactory{"primary": primary, "secondary": secondary, "local": local})
+	g.router.SetRoutingStore(&staticRoutingStore{config: &RoutingConfig{
+		Chains: []FallbackChain{{
+			PrimaryConnectionID: "primary",
+			Steps: []FallbackStep{
+				{ConnectionID: "secondary"},
+				{ConnectionID: "local", Model: "llama3"},
+			},
+		}},
+	}})
+
+	resp, err := g.Complete(context.Background(), &Request{
+		UserID:       "user",
+		ConnectionID: "primary",
+		Model:        "gpt-4o",
+		Messages:     []Message{{Role: "user", Content: "hi"}},
+	})
+	assert.NoError(t, err)
+	assert.True(t, resp.Metadata.FallbackUsed)
+	assert.Equal(t, "local", resp.Metadata.ConnectionID)
+	assert.Equal(t, "llama3", local.lastReq.Model)
+	assert.NotNil(t, secondary.lastReq)
+}
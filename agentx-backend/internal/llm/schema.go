@@ -0,0 +1,328 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ValidateSchema checks a decoded JSON value against a JSON Schema and
+// returns every violation found, each prefixed with the path to the offending
+// value. It covers the keywords structured-output schemas use in practice:
+// type, enum, const, properties, required, additionalProperties, items,
+// length, size and range limits, pattern, allOf/anyOf/oneOf and local $ref
+// pointers into $defs or definitions.
+func ValidateSchema(schema map[string]interface{}, value interface{}) []string {
+	// Schemas built in Go may hold ints and typed slices; decode them the way
+	// they would arrive over the wire
+	if data, err := json.Marshal(schema); err == nil {
+		var normalized map[string]interface{}
+		if json.Unmarshal(data, &normalized) == nil {
+			schema = normalized
+		}
+	}
+	v := &schemaValidator{root: schema}
+	v.validate(schema, value, "$")
+	return v.errors
+}
+
+// schemaValidator collects violations while walking a schema
+type schemaValidator struct {
+	root   map[string]interface{}
+	errors []string
+	depth  int
+}
+
+func (v *schemaValidator) fail(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *schemaValidator) validate(schema map[string]interface{}, value interface{}, path string) {
+	if schema == nil {
+		return
+	}
+	// Guard against schemas that reference themselves without consuming input
+	v.depth++
+	defer func() { v.depth-- }()
+	if v.depth > 64 {
+		v.fail(path, "schema nesting too deep")
+		return
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := v.resolve(ref)
+		if err != nil {
+			v.fail(path, "%v", err)
+			return
+		}
+		v.validate(target, value, path)
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !matchesAnyType(types, value) {
+		v.fail(path, "expected %s, got %s", strings.Join(types, " or "), jsonType(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if jsonEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "value %s is not one of %s", jsonText(value), jsonText(enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !jsonEqual(constant, value) {
+		v.fail(path, "value must be %s", jsonText(constant))
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(schema, value, path)
+	case []interface{}:
+		v.validateArray(schema, value, path)
+	case string:
+		v.validateString(schema, value, path)
+	case float64:
+		v.validateNumber(schema, value, path)
+	}
+
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			v.validate(asSchema(sub), value, path)
+		}
+	}
+	if any, ok := schema["anyOf"].([]interface{}); ok {
+		if v.countMatches(any, value, path) == 0 {
+			v.fail(path, "value does not match any of the allowed schemas")
+		}
+	}
+	if one, ok := schema["oneOf"].([]interface{}); ok {
+		if n := v.countMatches(one, value, path); n != 1 {
+			v.fail(path, "value matches %d of the schemas, expected exactly one", n)
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(schema map[string]interface{}, value map[string]interface{}, path string) {
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, exists := value[name]; !exists {
+					v.fail(path, "missing required property %q", name)
+				}
+			}
+		}
+	}
+
+	// Walk properties in a stable order so errors are reproducible
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		propPath := path + "." + name
+		if prop, ok := properties[name]; ok {
+			v.validate(asSchema(prop), value[name], propPath)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(path, "unexpected property %q", name)
+			}
+		case map[string]interface{}:
+			v.validate(additional, value[name], propPath)
+		}
+	}
+
+	if min, ok := schemaInt(schema["minProperties"]); ok && len(value) < min {
+		v.fail(path, "expected at least %d properties, got %d", min, len(value))
+	}
+	if max, ok := schemaInt(schema["maxProperties"]); ok && len(value) > max {
+		v.fail(path, "expected at most %d properties, got %d", max, len(value))
+	}
+}
+
+func (v *schemaValidator) validateArray(schema map[string]interface{}, value []interface{}, path string) {
+	if items := asSchema(schema["items"]); items != nil {
+		for i, item := range value {
+			v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+	if min, ok := schemaInt(schema["minItems"]); ok && len(value) < min {
+		v.fail(path, "expected at least %d items, got %d", min, len(value))
+	}
+	if max, ok := schemaInt(schema["maxItems"]); ok && len(value) > max {
+		v.fail(path, "expected at most %d items, got %d", max, len(value))
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range value {
+			for j := i + 1; j < len(value); j++ {
+				if jsonEqual(value[i], value[j]) {
+					v.fail(path, "items %d and %d are equal", i, j)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) validateString(schema map[string]interface{}, value string, path string) {
+	length := len([]rune(value))
+	if min, ok := schemaInt(schema["minLength"]); ok && length < min {
+		v.fail(path, "expected at least %d characters, got %d", min, length)
+	}
+	if max, ok := schemaInt(schema["maxLength"]); ok && length > max {
+		v.fail(path, "expected at most %d characters, got %d", max, length)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			v.fail(path, "invalid pattern %q in schema", pattern)
+		} else if !re.MatchString(value) {
+			v.fail(path, "value %q does not match pattern %q", value, pattern)
+		}
+	}
+}
+
+func (v *schemaValidator) validateNumber(schema map[string]interface{}, value float64, path string) {
+	if min, ok := schema["minimum"].(float64); ok && value < min {
+		v.fail(path, "value %v is less than the minimum %v", value, min)
+	}
+	if max, ok := schema["maximum"].(float64); ok && value > max {
+		v.fail(path, "value %v is greater than the maximum %v", value, max)
+	}
+	if min, ok := schema["exclusiveMinimum"].(float64); ok && value <= min {
+		v.fail(path, "value %v must be greater than %v", value, min)
+	}
+	if max, ok := schema["exclusiveMaximum"].(float64); ok && value >= max {
+		v.fail(path, "value %v must be less than %v", value, max)
+	}
+	if multiple, ok := schema["multipleOf"].(float64); ok && multiple > 0 {
+		if q := value / multiple; math.Abs(q-math.Round(q)) > 1e-9 {
+			v.fail(path, "value %v is not a multiple of %v", value, multiple)
+		}
+	}
+}
+
+// countMatches returns how many of the schemas the value satisfies
+func (v *schemaValidator) countMatches(schemas []interface{}, value interface{}, path string) int {
+	matches := 0
+	for _, sub := range schemas {
+		probe := &schemaValidator{root: v.root, depth: v.depth}
+		probe.validate(asSchema(sub), value, path)
+		if len(probe.errors) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+// resolve follows a local JSON pointer such as #/$defs/address
+func (v *schemaValidator) resolve(ref string) (map[string]interface{}, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported schema reference %q", ref)
+	}
+	var node interface{} = v.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		obj, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolved schema reference %q", ref)
+		}
+		if node, ok = obj[part]; !ok {
+			return nil, fmt.Errorf("unresolved schema reference %q", ref)
+		}
+	}
+	target := asSchema(node)
+	if target == nil {
+		return nil, fmt.Errorf("schema reference %q is not a schema", ref)
+	}
+	return target, nil
+}
+
+// schemaTypes reads the type keyword, which is a name or a list of names
+func schemaTypes(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		types := make([]string, 0, len(v))
+		for _, t := range v {
+			if t, ok := t.(string); ok {
+				types = append(types, t)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func matchesAnyType(types []string, value interface{}) bool {
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func asSchema(v interface{}) map[string]interface{} {
+	schema, _ := v.(map[string]interface{})
+	return schema
+}
+
+func schemaInt(v interface{}) (int, bool) {
+	f, ok := v.(float64)
+	return int(f), ok
+}
+
+func jsonEqual(a, b interface{}) bool {
+	return jsonText(a) == jsonText(b)
+}
+
+// jsonText encodes a value for comparison and error messages; object keys
+// are sorted by encoding/json, so equal values encode equally
+func jsonText(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
@@ -24,6 +24,7 @@ type Service struct {
 	gateway         *Gateway
 	sessionProvider SessionProvider
 	taskHandlers    map[TaskType]TaskHandler
+	prompts         *PromptLibrary
 }
 
 // NewService creates a new LLM service
@@ -32,6 +33,7 @@ func NewService(gateway *Gateway, sessionProvider SessionProvider) *Service {
 		gateway:         gateway,
 		sessionProvider: sessionProvider,
 		taskHandlers:    make(map[TaskType]TaskHandler),
+		prompts:         NewPromptLibrary(),
 	}
 	
 	// Register task handlers
@@ -43,6 +45,10 @@ func NewService(gateway *Gateway, sessionProvider SessionProvider) *Service {
 // registerTaskHandlers registers all task-specific handlers
 func (s *Service) registerTaskHandlers() {
 	s.taskHandlers[TaskGenerateTitle] = &TitleGenerationHandler{service: s}
+	s.taskHandlers[TaskTranslate] = &TranslationHandler{service: s}
+	s.taskHandlers[TaskCodeGeneration] = &CodeGenerationHandler{service: s}
+	s.taskHandlers[TaskExtract] = &ExtractionHandler{service: s}
+	s.taskHandlers[TaskCustom] = &GenericHandler{service: s}
 	// Add more handlers as needed
 	// s.taskHandlers[TaskSummarize] = &SummarizationHandler{service: s}
 }
@@ -151,4 +157,9 @@ func (s *Service) GetSupportedTasks() []TaskType {
 func (s *Service) RegisterTaskHandler(taskType TaskType, handler TaskHandler) {
 	s.taskHandlers[taskType] = handler
 	fmt.Printf("[LLM Service] Registered handler for task: %s\n", taskType)
-}
\ No newline at end of file
+}
+// Prompts returns the prompt template library the built-in tasks render
+// their prompts from
+func (s *Service) Prompts() *PromptLibrary {
+	return s.prompts
+}
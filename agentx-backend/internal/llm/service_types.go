@@ -14,6 +14,8 @@ const (
 	TaskTranslate      TaskType = "translate"
 	TaskCodeGeneration TaskType = "code_generation"
 	TaskCustom         TaskType = "custom"
+	TaskEmbeddings     TaskType = "embeddings"
+	TaskExtract        TaskType = "extract"
 )
 
 // CompletionRequest represents a general LLM completion request
@@ -78,6 +80,11 @@ var (
 	ErrNoConnectionFound  = &LLMError{Code: "NO_CONNECTION_FOUND", Message: "no suitable connection found"}
 	ErrInvalidConnection  = &LLMError{Code: "INVALID_CONNECTION", Message: "invalid connection specified"}
 	ErrPromptRequired     = &LLMError{Code: "PROMPT_REQUIRED", Message: "at least one prompt is required"}
+	ErrTextRequired       = &LLMError{Code: "TEXT_REQUIRED", Message: "text is required"}
+	ErrTargetLanguageRequired = &LLMError{Code: "TARGET_LANGUAGE_REQUIRED", Message: "target_language is required"}
+	ErrLanguageRequired   = &LLMError{Code: "LANGUAGE_REQUIRED", Message: "language is required"}
+	ErrInstructionsRequired = &LLMError{Code: "INSTRUCTIONS_REQUIRED", Message: "instructions are required"}
+	ErrSchemaRequired     = &LLMError{Code: "SCHEMA_REQUIRED", Message: "schema is required"}
 )
 
 // LLMError represents a structured error for LLM operations
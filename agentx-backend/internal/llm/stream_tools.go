@@ -0,0 +1,100 @@
+package llm
+
+import "fmt"
+
+// Stream event types for tool calls, assembled by the gateway from the
+// provider's fragments
+const (
+	StreamToolCallStart = "tool_call_start" // Carries the call's ID and name
+	StreamToolCallDelta = "tool_call_delta" // Carries a fragment of the arguments
+	StreamToolCallEnd   = "tool_call_end"   // Carries the complete call
+)
+
+// toolCallAssembler assembles the tool-call fragments of one provider stream
+// into complete calls. Fragments with an index (OpenAI tool_calls deltas,
+// Anthropic content blocks) are matched by it; fragments without one start a
+// new call when they carry an ID and continue the latest call otherwise.
+type toolCallAssembler struct {
+	calls   []*ToolCall
+	byIndex map[int]*ToolCall
+	ended   int // Number of calls already ended
+}
+
+// Add merges streamed fragments and returns the events they produce
+func (a *toolCallAssembler) Add(fragments []ToolCall) []*StreamChunk {
+	var events []*StreamChunk
+	for _, fragment := range fragments {
+		call := a.match(fragment)
+		if call == nil {
+			call = a.start(fragment)
+			events = append(events, toolCallEvent(StreamToolCallStart, call, ""))
+		} else if fragment.Function.Name != "" && call.Function.Name == "" {
+			call.Function.Name = fragment.Function.Name
+		}
+
+		if fragment.Function.Arguments != "" {
+			call.Function.Arguments += fragment.Function.Arguments
+			events = append(events, toolCallEvent(StreamToolCallDelta, call, fragment.Function.Arguments))
+		}
+	}
+	return events
+}
+
+// Finish returns an end event for every call not yet ended
+func (a *toolCallAssembler) Finish() []*StreamChunk {
+	var events []*StreamChunk
+	for _, call := range a.calls[a.ended:] {
+		end := *call
+		if end.Function.Arguments == "" {
+			end.Function.Arguments = "{}"
+		}
+		events = append(events, &StreamChunk{Type: StreamToolCallEnd, ToolCall: &end})
+	}
+	a.ended = len(a.calls)
+	return events
+}
+
+// match returns the call a fragment continues, or nil if it starts a new one
+func (a *toolCallAssembler) match(fragment ToolCall) *ToolCall {
+	if fragment.Index != nil {
+		call := a.byIndex[*fragment.Index]
+		if call != nil && fragment.ID != "" && fragment.ID != call.ID {
+			return nil
+		}
+		return call
+	}
+	if fragment.ID != "" || len(a.calls) == a.ended {
+		return nil
+	}
+	return a.calls[len(a.calls)-1]
+}
+
+// start records a new call from its first fragment
+func (a *toolCallAssembler) start(fragment ToolCall) *ToolCall {
+	position := len(a.calls)
+	call := &ToolCall{ID: fragment.ID, Type: fragment.Type, Index: &position}
+	if call.ID == "" {
+		call.ID = fmt.Sprintf("call_%d", position)
+	}
+	if call.Type == "" {
+		call.Type = "function"
+	}
+	call.Function.Name = fragment.Function.Name
+
+	a.calls = append(a.calls, call)
+	if fragment.Index != nil {
+		if a.byIndex == nil {
+			a.byIndex = make(map[int]*ToolCall)
+		}
+		a.byIndex[*fragment.Index] = call
+	}
+	return call
+}
+
+// toolCallEvent builds a tool_call_start or tool_call_delta event
+func toolCallEvent(eventType string, call *ToolCall, arguments string) *StreamChunk {
+	event := &ToolCall{ID: call.ID, Type: call.Type, Index: call.Index}
+	event.Function.Name = call.Function.Name
+	event.Function.Arguments = arguments
+	return &StreamChunk{Type: eventType, ToolCall: event}
+}
@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fragment(index *int, id, name, arguments string) ToolCall {
+	call := ToolCall{ID: id, Index: index}
+	call.Function.Name = name
+	call.Function.Arguments = arguments
+	return call
+}
+
+func intPtr(i int) *int { return &i }
+
+func TestToolCallAssemblerMatchesInterleavedFragmentsByIndex(t *testing.T) {
+	a := &toolCallAssembler{}
+	var events []*StreamChunk
+	events = append(events, a.Add([]ToolCall{
+		fragment(intPtr(0), "call_a", "get_weather", ""),
+		fragment(intPtr(1), "call_b", "get_time", `{"tz":`),
+	})...)
+	events = append(events, a.Add([]ToolCall{fragment(intPtr(0), "", "", `{"city":"Paris"}`)})...)
+	events = append(events, a.Add([]ToolCall{fragment(intPtr(1), "", "", `"UTC"}`)})...)
+	events = append(events, a.Finish()...)
+
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []string{
+		StreamToolCallStart, StreamToolCallStart, StreamToolCallDelta,
+		StreamToolCallDelta, StreamToolCallDelta, StreamToolCallEnd, StreamToolCallEnd,
+	}, types)
+
+	ends := events[len(events)-2:]
+	assert.Equal(t, "call_a", ends[0].ToolCall.ID)
+	assert.Equal(t, `{"city":"Paris"}`, ends[0].ToolCall.Function.Arguments)
+	assert.Equal(t, "call_b", ends[1].ToolCall.ID)
+	assert.Equal(t, `{"tz":"UTC"}`, ends[1].ToolCall.Function.Arguments)
+	assert.Empty(t, a.Finish(), "calls end once")
+}
+
+func TestToolCallAssemblerContinuesLatestCallWithoutIndex(t *testing.T) {
+	a := &toolCallAssembler{}
+	a.Add([]ToolCall{fragment(nil, "", "search", "")})
+	a.Add([]ToolCall{fragment(nil, "", "", `{"q":`)})
+	a.Add([]ToolCall{fragment(nil, "", "", `"go"}`)})
+	a.Add([]ToolCall{fragment(nil, "call_2", "lookup", "")})
+
+	ends := a.Finish()
+	if assert.Len(t, ends, 2) {
+		assert.Equal(t, "call_0", ends[0].ToolCall.ID, "missing IDs are generated")
+		assert.Equal(t, "function", ends[0].ToolCall.Type)
+		assert.Equal(t, `{"q":"go"}`, ends[0].ToolCall.Function.Arguments)
+		assert.Equal(t, "{}", ends[1].ToolCall.Function.Arguments)
+	}
+}
+
+// toolStreamProvider streams a tool call as OpenAI-style fragments
+type toolStreamProvider struct {
+	fakeProvider
+}
+
+func (p *toolStreamProvider) StreamComplete(ctx context.Context, req *Request) (<-chan *StreamChunk, error) {
+	out := make(chan *StreamChunk, 3)
+	out <- &StreamChunk{Type: "content", Choices: []StreamChoice{{Delta: MessageDelta{
+		ToolCalls: []ToolCall{fragment(intPtr(0), "call_1", "get_weather", "")},
+	}}}}
+	out <- &StreamChunk{Type: "content", Choices: []StreamChoice{{Delta: MessageDelta{
+		ToolCalls: []ToolCall{fragment(intPtr(0), "", "", `{"city":"Rome"}`)},
+	}}}}
+	out <- &StreamChunk{Type: "content", Choices: []StreamChoice{{FinishReason: "tool_calls"}}}
+	close(out)
+	return out, nil
+}
+
+func TestStreamCompleteEmitsAssembledToolCalls(t *testing.T) {
+	g := newTestGateway(t, fakeFactory{"primary": &toolStreamProvider{}})
+
+	stream, err := g.StreamComplete(context.Background(), &Request{
+		UserID:       "user",
+		ConnectionID: "primary",
+		Messages:     []Message{{Role: "user", Content: "Weather in Rome?"}},
+		Stream:       true,
+	})
+	assert.NoError(t, err)
+
+	var ends []*ToolCall
+	for _, chunk := range collect(stream) {
+		for _, choice := range chunk.Choices {
+			assert.Empty(t, choice.Delta.ToolCalls, "raw fragments are not forwarded")
+		}
+		if chunk.Type == StreamToolCallEnd {
+			ends = append(ends, chunk.ToolCall)
+		}
+	}
+	if assert.Len(t, ends, 1) {
+		assert.Equal(t, "get_weather", ends[0].Function.Name)
+		assert.Equal(t, `{"city":"Rome"}`, ends[0].Function.Arguments)
+	}
+}
@@ -0,0 +1,299 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Response format types
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// How a provider produces structured output, reported in
+// Metadata.StructuredOutput. Providers declare their native mode in
+// ProviderCapabilities.StructuredOutput; all others are prompted.
+const (
+	StructuredJSONSchema = "json_schema" // Native schema-constrained decoding
+	StructuredTool       = "tool"        // A forced tool call whose input is the schema
+	StructuredPrompt     = "prompt"      // Schema instructions in the system prompt
+)
+
+// DefaultSchemaRepairs is how many times an answer that fails validation is
+// sent back for repair when the format does not say
+const DefaultSchemaRepairs = 2
+
+// MaxSchemaRepairs caps the repair round-trips a format may ask for, since
+// each one is another full provider call
+const MaxSchemaRepairs = 5
+
+// ErrSchemaValidation is returned when an answer still does not match the
+// requested schema after every repair attempt
+var ErrSchemaValidation = errors.New("response does not match schema")
+
+// ResponseFormat asks for JSON output, optionally constrained by a schema
+type ResponseFormat struct {
+	Type       string      `json:"type"` // text, json_object or json_schema
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+	// Repair round-trips for answers that fail validation; nil for
+	// DefaultSchemaRepairs, 0 to disable, at most MaxSchemaRepairs
+	MaxRepairs *int `json:"max_repairs,omitempty"`
+}
+
+// JSONSchema names and describes the schema an answer must match
+type JSONSchema struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema"`
+	Strict      bool                   `json:"strict,omitempty"`
+}
+
+// SchemaError reports an answer that does not match the requested format
+type SchemaError struct {
+	Errors  []string // Validation failures of the last answer
+	Content string   // The last answer's raw text
+	Repairs int      // Repair round-trips attempted
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%v after %d repair attempts: %s", ErrSchemaValidation, e.Repairs, strings.Join(e.Errors, "; "))
+}
+
+func (e *SchemaError) Unwrap() error {
+	return ErrSchemaValidation
+}
+
+// Structured reports whether the format asks for JSON
+func (f *ResponseFormat) Structured() bool {
+	return f != nil && (f.Type == ResponseFormatJSONObject || f.Type == ResponseFormatJSONSchema)
+}
+
+// Schema returns the schema answers must match, nil for any JSON object
+func (f *ResponseFormat) Schema() map[string]interface{} {
+	if f == nil || f.Type != ResponseFormatJSONSchema || f.JSONSchema == nil {
+		return nil
+	}
+	return f.JSONSchema.Schema
+}
+
+// SchemaName returns the name of the schema, defaulting to "response"
+func (f *ResponseFormat) SchemaName() string {
+	if f != nil && f.JSONSchema != nil && f.JSONSchema.Name != "" {
+		return f.JSONSchema.Name
+	}
+	return "response"
+}
+
+// Repairs returns how many repair round-trips the format allows
+func (f *ResponseFormat) Repairs() int {
+	if f == nil || f.MaxRepairs == nil {
+		return DefaultSchemaRepairs
+	}
+	if *f.MaxRepairs < 0 {
+		return 0
+	}
+	if *f.MaxRepairs > MaxSchemaRepairs {
+		return MaxSchemaRepairs
+	}
+	return *f.MaxRepairs
+}
+
+// Validate checks that a JSON Schema format carries a schema
+func (f *ResponseFormat) Validate() error {
+	if f == nil {
+		return nil
+	}
+	switch f.Type {
+	case "", ResponseFormatText, ResponseFormatJSONObject:
+		return nil
+	case ResponseFormatJSONSchema:
+		if f.JSONSchema == nil || len(f.JSONSchema.Schema) == 0 {
+			return fmt.Errorf("response_format json_schema requires a schema")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported response_format type: %s", f.Type)
+	}
+}
+
+// Parse extracts the JSON answer from raw model output and validates it.
+// Code fences and text around the JSON value are tolerated.
+func (f *ResponseFormat) Parse(content string) (interface{}, []string) {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(extractJSON(content)), &parsed); err != nil {
+		return nil, []string{fmt.Sprintf("$: not valid JSON: %v", err)}
+	}
+	if schema := f.Schema(); schema != nil {
+		if errs := ValidateSchema(schema, parsed); len(errs) > 0 {
+			return nil, errs
+		}
+	} else if _, ok := parsed.(map[string]interface{}); !ok {
+		return nil, []string{fmt.Sprintf("$: expected object, got %s", jsonType(parsed))}
+	}
+	return parsed, nil
+}
+
+// extractJSON returns the JSON value in model output, dropping Markdown code
+// fences and any prose before or after it
+func extractJSON(content string) string {
+	text := strings.TrimSpace(content)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if newline := strings.Index(text, "\n"); newline >= 0 {
+			text = text[newline+1:]
+		}
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+	}
+	if json.Valid([]byte(text)) {
+		return text
+	}
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return text
+	}
+	closing := "}"
+	if text[start] == '[' {
+		closing = "]"
+	}
+	if end := strings.LastIndex(text, closing); end > start {
+		return text[start : end+1]
+	}
+	return text
+}
+
+// structuredMode returns how a provider produces structured output
+func structuredMode(provider Provider) string {
+	if mode := provider.GetCapabilities().StructuredOutput; mode != "" {
+		return mode
+	}
+	return StructuredPrompt
+}
+
+// structuredRequestFor returns the request as sent to a provider. Providers
+// without a native structured-output mode get the format as instructions in
+// the system prompt, and so does every request for a plain JSON object:
+// OpenAI's json_object mode refuses prompts that do not mention JSON.
+func structuredRequestFor(req *Request, provider Provider) *Request {
+	format := req.ResponseFormat
+	if !format.Structured() {
+		return req
+	}
+	if structuredMode(provider) == StructuredPrompt || format.Schema() == nil {
+		return withFormatInstructions(req, format)
+	}
+	return req
+}
+
+// completeStructured calls a provider with the request prepared for the
+// provider's own structured-output mode, and records the mode used. Each
+// connection a request is routed, hedged or failed over to is prepared
+// separately.
+func completeStructured(ctx context.Context, provider Provider, req *Request) (*Response, error) {
+	resp, err := provider.Complete(ctx, structuredRequestFor(req, provider))
+	if err == nil && resp != nil && req.ResponseFormat.Structured() {
+		resp.Metadata.StructuredOutput = structuredMode(provider)
+	}
+	return resp, err
+}
+
+// executeStructured runs a completion and, when the request asks for JSON,
+// checks the answer against the requested format. Answers that fail are sent
+// back with the validation errors for repair, up to the format's limit.
+func (g *Gateway) executeStructured(ctx context.Context, req *Request, provider Provider, call *completionCall) (*Response, error) {
+	format := req.ResponseFormat
+	if !format.Structured() {
+		return g.execute(ctx, req, provider, call)
+	}
+
+	var usage Usage
+	for repairs := 0; ; repairs++ {
+		resp, err := g.execute(ctx, req, provider, call)
+		if err != nil {
+			return nil, err
+		}
+		usage = addUsage(usage, resp.Usage)
+
+		// Turns that call tools are answered later in the conversation
+		if len(resp.GetToolCalls()) > 0 {
+			resp.Usage = usage
+			return resp, nil
+		}
+
+		content := resp.GetContent()
+		parsed, errs := format.Parse(content)
+		if len(errs) == 0 {
+			resp.Parsed = parsed
+			resp.Usage = usage
+			resp.Metadata.SchemaRepairs = repairs
+			return resp, nil
+		}
+		if repairs >= format.Repairs() {
+			return nil, &SchemaError{Errors: errs, Content: content, Repairs: repairs}
+		}
+
+		// The errors quote the response, so only their count is logged
+		fmt.Printf("[Gateway] Response does not match %s schema (%d errors), requesting repair %d/%d\n",
+			format.SchemaName(), len(errs), repairs+1, format.Repairs())
+		req = withRepairRequest(req, format, content, errs)
+	}
+}
+
+// withFormatInstructions returns a copy of the request whose system prompt
+// tells the model to answer with JSON matching the format
+func withFormatInstructions(req *Request, format *ResponseFormat) *Request {
+	instructions := "Respond only with a single JSON object, without code fences or any other text."
+	if schema := format.Schema(); schema != nil {
+		encoded, _ := json.MarshalIndent(schema, "", "  ")
+		instructions = fmt.Sprintf("Respond only with a single JSON value that matches this JSON Schema, without code fences or any other text.\n\nSchema %q", format.SchemaName())
+		if format.JSONSchema.Description != "" {
+			instructions += " (" + format.JSONSchema.Description + ")"
+		}
+		instructions += ":\n" + string(encoded)
+	}
+
+	clone := req.Clone()
+	for i, msg := range clone.Messages {
+		if msg.Role == "system" {
+			clone.Messages[i].Content = strings.TrimSpace(msg.Content + "\n\n" + instructions)
+			return clone
+		}
+	}
+	clone.Messages = append([]Message{{Role: "system", Content: instructions}}, clone.Messages...)
+	return clone
+}
+
+// withRepairRequest returns a copy of the request that continues the
+// conversation with the invalid answer and asks for a corrected one
+func withRepairRequest(req *Request, format *ResponseFormat, content string, errs []string) *Request {
+	var b strings.Builder
+	b.WriteString("Your previous response did not match the required format:\n")
+	for _, e := range errs {
+		b.WriteString("- " + e + "\n")
+	}
+	if schema := format.Schema(); schema != nil {
+		encoded, _ := json.Marshal(schema)
+		b.WriteString("\nThe required JSON Schema is:\n" + string(encoded) + "\n")
+	}
+	b.WriteString("\nReply again with only the corrected JSON.")
+
+	clone := req.Clone()
+	clone.Messages = append(clone.Messages,
+		Message{Role: "assistant", Content: content},
+		Message{Role: "user", Content: b.String()},
+	)
+	return clone
+}
+
+// addUsage sums the token counts of two calls
+func addUsage(a, b Usage) Usage {
+	a.PromptTokens += b.PromptTokens
+	a.CompletionTokens += b.CompletionTokens
+	a.TotalTokens += b.TotalTokens
+	a.EstimatedCost += b.EstimatedCost
+	return a
+}
@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptedProvider answers each call with the next of its replies
+type scriptedProvider struct {
+	fakeProvider
+	replies  []string
+	requests []*Request
+	mode     string
+}
+
+func (p *scriptedProvider) Complete(ctx context.Context, req *Request) (*Response, error) {
+	p.requests = append(p.requests, req)
+	reply := p.replies[len(p.requests)-1]
+	return &Response{
+		Choices: []Choice{{Message: Message{Role: "assistant", Content: reply}}},
+		Usage:   Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}, nil
+}
+
+func (p *scriptedProvider) GetCapabilities() ProviderCapabilities {
+	return ProviderCapabilities{StructuredOutput: p.mode}
+}
+
+var personSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"name": map[string]interface{}{"type": "string", "minLength": 1},
+		"age":  map[string]interface{}{"type": "integer", "minimum": 0},
+		"tags": map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/$defs/tag"}},
+	},
+	"required":             []string{"name", "age"},
+	"additionalProperties": false,
+	"$defs": map[string]interface{}{
+		"tag": map[string]interface{}{"type": "string", "enum": []string{"admin", "user"}},
+	},
+}
+
+func structuredRequest(repairs *int) *Request {
+	return &Request{
+		UserID:       "user",
+		ConnectionID: "json",
+		Messages:     []Message{{Role: "user", Content: "Who is Ada?"}},
+		ResponseFormat: &ResponseFormat{
+			Type:       ResponseFormatJSONSchema,
+			JSONSchema: &JSONSchema{Name: "person", Schema: personSchema},
+			MaxRepairs: repairs,
+		},
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	format := &ResponseFormat{Type: ResponseFormatJSONSchema, JSONSchema: &JSONSchema{Schema: personSchema}}
+
+	parsed, errs := format.Parse("```json\n{\"name\": \"Ada\", \"age\": 36, \"tags\": [\"admin\"]}\n```")
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string]interface{}{"name": "Ada", "age": 36.0, "tags": []interface{}{"admin"}}, parsed)
+
+	_, errs = format.Parse(`Sure! {"name": "", "age": 1.5, "tags": ["root"], "email": "a@b"}`)
+	assert.Equal(t, []string{
+		`$.age: expected integer, got number`,
+		`$: unexpected property "email"`,
+		`$.name: expected at least 1 characters, got 0`,
+		`$.tags[0]: value "root" is not one of ["admin","user"]`,
+	}, errs)
+
+	_, errs = format.Parse(`{"age": -1}`)
+	assert.Equal(t, []string{`$: missing required property "name"`, `$.age: value -1 is less than the minimum 0`}, errs)
+
+	_, errs = format.Parse("no json here")
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0], "not valid JSON")
+}
+
+func TestCompleteRepairsInvalidStructuredOutput(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{`{"name": "Ada"}`, `{"name": "Ada", "age": 36}`}}
+	g := newTestGateway(t, fakeFactory{"json": provider})
+
+	resp, err := g.Complete(context.Background(), structuredRequest(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "Ada", "age": 36.0}, resp.Parsed)
+	assert.Equal(t, `{"name": "Ada", "age": 36}`, resp.Content)
+	assert.Equal(t, StructuredPrompt, resp.Metadata.StructuredOutput)
+	assert.Equal(t, 1, resp.Metadata.SchemaRepairs)
+	assert.Equal(t, 30, resp.Usage.TotalTokens)
+
+	// The schema is prompted for, and the repair shows the model its mistake
+	if assert.Len(t, provider.requests, 2) {
+		first := provider.requests[0].Messages
+		assert.Equal(t, "system", first[0].Role)
+		assert.Contains(t, first[0].Content, `Schema "person"`)
+		repair := provider.requests[1].Messages
+		assert.Equal(t, `{"name": "Ada"}`, repair[len(repair)-2].Content)
+		assert.Contains(t, repair[len(repair)-1].Content, `missing required property "age"`)
+	}
+}
+
+func TestCompleteStructuredOutputGivesUpAfterRepairs(t *testing.T) {
+	none := 0
+	provider := &scriptedProvider{replies: []string{`{"name": "Ada"}`}, mode: StructuredJSONSchema}
+	g := newTestGateway(t, fakeFactory{"json": provider})
+
+	_, err := g.Complete(context.Background(), structuredRequest(&none))
+	assert.ErrorIs(t, err, ErrSchemaValidation)
+	var schemaErr *SchemaError
+	if assert.True(t, errors.As(err, &schemaErr)) {
+		assert.Equal(t, `{"name": "Ada"}`, schemaErr.Content)
+		assert.Equal(t, 0, schemaErr.Repairs)
+	}
+	// Native modes are not prompted for the schema
+	if assert.Len(t, provider.requests, 1) {
+		assert.Len(t, provider.requests[0].Messages, 1)
+	}
+}
+
+func TestCompleteJSONObjectIsAlwaysInstructed(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{`{"ok": true}`}, mode: StructuredJSONSchema}
+	g := newTestGateway(t, fakeFactory{"json": provider})
+
+	req := structuredRequest(nil)
+	req.ResponseFormat = &ResponseFormat{Type: ResponseFormatJSONObject}
+	resp, err := g.Complete(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, StructuredJSONSchema, resp.Metadata.StructuredOutput)
+
+	// Native JSON object modes reject prompts that do not ask for JSON
+	if assert.Len(t, provider.requests, 1) {
+		assert.Equal(t, "system", provider.requests[0].Messages[0].Role)
+		assert.Contains(t, provider.requests[0].Messages[0].Content, "JSON object")
+	}
+}
+
+func TestCompleteStructuredPreparesEachFallback(t *testing.T) {
+	primary := &fakeProvider{failErr: errors.New("connection reset")}
+	fallback := &scriptedProvider{replies: []string{`{"name": "Ada", "age": 36}`}, mode: StructuredJSONSchema}
+	g := newTestGateway(t, fakeFactory{"json": primary, "fallback": fallback})
+	g.router.SetFallback("json", "user:fallback")
+
+	resp, err := g.Complete(context.Background(), structuredRequest(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, StructuredJSONSchema, resp.Metadata.StructuredOutput)
+
+	// The prompted primary got the schema, the native fallback did not
+	assert.Contains(t, primary.lastReq.Messages[0].Content, `Schema "person"`)
+	if assert.Len(t, fallback.requests, 1) {
+		assert.Len(t, fallback.requests[0].Messages, 1)
+	}
+}
+
+func TestRepairsAreCapped(t *testing.T) {
+	many, negative := 100, -1
+	assert.Equal(t, DefaultSchemaRepairs, (&ResponseFormat{}).Repairs())
+	assert.Equal(t, MaxSchemaRepairs, (&ResponseFormat{MaxRepairs: &many}).Repairs())
+	assert.Equal(t, 0, (&ResponseFormat{MaxRepairs: &negative}).Repairs())
+}
@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// codeFencePattern matches Markdown fenced code blocks and their info string
+var codeFencePattern = regexp.MustCompile("(?s)```([^\\n`]*)\\n(.*?)```")
+
+// codeLanguageAliases maps common fence tags to the language name they stand for
+var codeLanguageAliases = map[string]string{
+	"golang":     "go",
+	"js":         "javascript",
+	"jsx":        "javascript",
+	"ts":         "typescript",
+	"tsx":        "typescript",
+	"py":         "python",
+	"python3":    "python",
+	"rb":         "ruby",
+	"rs":         "rust",
+	"sh":         "bash",
+	"shell":      "bash",
+	"zsh":        "bash",
+	"c++":        "cpp",
+	"cs":         "csharp",
+	"c#":         "csharp",
+	"kt":         "kotlin",
+	"yml":        "yaml",
+	"postgresql": "sql",
+}
+
+// CodeBlock is a fenced code block in model output
+type CodeBlock struct {
+	Language string `json:"language,omitempty"`
+	Code     string `json:"code"`
+}
+
+// ExtractCodeBlocks returns the fenced code blocks in model output in order
+func ExtractCodeBlocks(content string) []CodeBlock {
+	var blocks []CodeBlock
+	for _, match := range codeFencePattern.FindAllStringSubmatch(content, -1) {
+		info := strings.Fields(match[1])
+		block := CodeBlock{Code: strings.TrimRight(match[2], "\n")}
+		if len(info) > 0 {
+			block.Language = info[0]
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// normalizeCodeLanguage lowercases a language name and resolves aliases
+func normalizeCodeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if alias, ok := codeLanguageAliases[language]; ok {
+		return alias
+	}
+	return language
+}
+
+// CodeGenerationHandler handles code generation tasks. The context carries
+// the instructions, the target language, optional style constraints and
+// optional existing code to change.
+type CodeGenerationHandler struct {
+	service *Service
+}
+
+// Handle processes code generation requests
+func (h *CodeGenerationHandler) Handle(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	language := contextString(req, "language")
+	style := contextStrings(req, "style")
+
+	fmt.Printf("[CodeGenerationHandler] Generating %s code with %d style constraints\n", language, len(style))
+
+	prompt, err := h.service.renderTaskPrompt(ctx, req, PromptCodeGeneration, map[string]interface{}{
+		"Instructions": contextString(req, "instructions"),
+		"Language":     language,
+		"Style":        style,
+		"Code":         contextString(req, "code"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.service.gateway.Complete(ctx, newTaskRequest(req, prompt, 4096, 0.2))
+	if err != nil {
+		return nil, fmt.Errorf("gateway request failed: %w", err)
+	}
+
+	code, explanation, blocks := selectCodeBlock(resp.Content, language)
+	if len(blocks) == 0 {
+		fmt.Printf("[CodeGenerationHandler] Response has no fenced code block, returning it as code\n")
+	}
+
+	metadata := map[string]interface{}{
+		"task":           string(TaskCodeGeneration),
+		"language":       language,
+		"blocks":         blocks,
+		"prompt_version": prompt.Version,
+	}
+	if explanation != "" {
+		metadata["explanation"] = explanation
+	}
+
+	return &CompletionResponse{
+		Result:       code,
+		Provider:     resp.Provider,
+		ConnectionID: req.ConnectionID,
+		Metadata:     metadata,
+		Usage:        taskUsage(resp.Usage),
+	}, nil
+}
+
+// ValidateRequest validates code generation requests
+func (h *CodeGenerationHandler) ValidateRequest(req CompletionRequest) error {
+	if contextString(req, "instructions") == "" && req.Parameters.UserPrompt == "" {
+		return ErrInstructionsRequired
+	}
+	if contextString(req, "language") == "" {
+		return ErrLanguageRequired
+	}
+	return nil
+}
+
+// selectCodeBlock picks the generated code from model output: the first
+// block tagged with the requested language, else the first block, else the
+// whole output. The text outside the code blocks is the explanation.
+func selectCodeBlock(content, language string) (string, string, []CodeBlock) {
+	blocks := ExtractCodeBlocks(content)
+	if len(blocks) == 0 {
+		return strings.TrimSpace(content), "", blocks
+	}
+
+	code := blocks[0].Code
+	want := normalizeCodeLanguage(language)
+	for _, block := range blocks {
+		if normalizeCodeLanguage(block.Language) == want {
+			code = block.Code
+			break
+		}
+	}
+
+	explanation := strings.TrimSpace(codeFencePattern.ReplaceAllString(content, ""))
+	return code, explanation, blocks
+}
@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// ExtractionHandler handles structured extraction tasks. The context carries
+// the text, a JSON Schema the extracted data must match, an optional
+// schema_name and optional instructions. Answers that fail the schema are
+// repaired by the gateway; the validated data is returned as the result.
+type ExtractionHandler struct {
+	service *Service
+}
+
+// Handle processes structured extraction requests
+func (h *ExtractionHandler) Handle(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	format := extractionFormat(req)
+
+	fmt.Printf("[ExtractionHandler] Extracting %s data from %d chars\n", format.SchemaName(), len(contextString(req, "text")))
+
+	prompt, err := h.service.renderTaskPrompt(ctx, req, PromptExtraction, map[string]interface{}{
+		"Text":         contextString(req, "text"),
+		"Instructions": contextString(req, "instructions"),
+		"SchemaName":   format.SchemaName(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	gatewayReq := newTaskRequest(req, prompt, 2048, 0)
+	gatewayReq.ResponseFormat = format
+
+	resp, err := h.service.gateway.Complete(ctx, gatewayReq)
+	if err != nil {
+		return nil, fmt.Errorf("gateway request failed: %w", err)
+	}
+	if resp.Parsed == nil {
+		return nil, fmt.Errorf("extraction response was not structured")
+	}
+
+	// The gateway validated the answer; re-encode it without any fences or prose
+	result, err := json.Marshal(resp.Parsed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode extracted data: %w", err)
+	}
+
+	return &CompletionResponse{
+		Result:       string(result),
+		Provider:     resp.Provider,
+		ConnectionID: req.ConnectionID,
+		Metadata: map[string]interface{}{
+			"task":              string(TaskExtract),
+			"schema_name":       format.SchemaName(),
+			"data":              resp.Parsed,
+			"structured_output": resp.Metadata.StructuredOutput,
+			"schema_repairs":    resp.Metadata.SchemaRepairs,
+			"prompt_version":    prompt.Version,
+		},
+		Usage: taskUsage(resp.Usage),
+	}, nil
+}
+
+// ValidateRequest validates structured extraction requests
+func (h *ExtractionHandler) ValidateRequest(req CompletionRequest) error {
+	if contextString(req, "text") == "" {
+		return ErrTextRequired
+	}
+	schema := contextObject(req, "schema")
+	if len(schema) == 0 {
+		return ErrSchemaRequired
+	}
+	if t, ok := schema["type"]; ok && len(schemaTypes(t)) == 0 {
+		return &LLMError{Code: "INVALID_SCHEMA", Message: "schema type must be a string or an array of strings"}
+	}
+	return nil
+}
+
+// extractionFormat builds the response format that constrains an
+// extraction to the request's schema
+func extractionFormat(req CompletionRequest) *ResponseFormat {
+	name := contextString(req, "schema_name")
+	if name == "" {
+		name = "extraction"
+	}
+	format := &ResponseFormat{
+		Type:       ResponseFormatJSONSchema,
+		JSONSchema: &JSONSchema{Name: name, Schema: contextObject(req, "schema")},
+	}
+	var repairs int
+	switch v := req.Context["max_repairs"].(type) {
+	case float64:
+		repairs = int(math.Min(v, MaxSchemaRepairs))
+	case int:
+		repairs = v
+	default:
+		return format
+	}
+	repairs = clampRepairs(repairs)
+	format.MaxRepairs = &repairs
+	return format
+}
+
+// clampRepairs limits requested repair round-trips to what the gateway allows
+func clampRepairs(repairs int) int {
+	if repairs < 0 {
+		return 0
+	}
+	if repairs > MaxSchemaRepairs {
+		return MaxSchemaRepairs
+	}
+	return repairs
+}
@@ -60,7 +60,10 @@ func (h *TitleGenerationHandler) Handle(ctx context.Context, req CompletionReque
 		len(sessionMessages), sessionID, len(messages))
 	
 	// Build prompt
-	prompt := h.buildTitlePrompt(messages, req.Parameters)
+	prompt, err := h.buildTitlePrompt(ctx, userID, messages, req.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build title prompt: %w", err)
+	}
 	fmt.Printf("[TitleHandler] Built prompt - System: %d chars, User: %d chars\n", 
 		len(prompt.System), len(prompt.User))
 	
@@ -79,6 +82,7 @@ func (h *TitleGenerationHandler) Handle(ctx context.Context, req CompletionReque
 		MaxTokens:   h.defaultInt(req.Parameters.MaxTokens, 50),
 		Temperature: h.defaultFloat(req.Parameters.Temperature, 0.7),
 		UserID:      userID,
+		Metadata:    map[string]interface{}{MetadataTaskType: string(req.Task)},
 	}
 	
 	// Set connection preference if specified
@@ -136,35 +140,33 @@ func (h *TitleGenerationHandler) ValidateRequest(req CompletionRequest) error {
 	return nil
 }
 
-// buildTitlePrompt constructs the prompt for title generation
-func (h *TitleGenerationHandler) buildTitlePrompt(messages []string, params Parameters) struct{ System, User string } {
-	systemPrompt := params.SystemPrompt
-	if systemPrompt == "" {
-		systemPrompt = "You are a helpful assistant that generates concise, descriptive titles for conversations. Generate a title that captures the main topic or purpose of the conversation in 5-10 words maximum. Respond with ONLY the title, nothing else."
-	}
-	
-	// Convert messages to conversation string
-	conversation := strings.Join(messages, "\n")
-	
-	userPrompt := params.UserPrompt
-	if userPrompt == "" {
-		userPrompt = fmt.Sprintf(`Generate a concise, descriptive title for this conversation. The title should:
-- Be 3-7 words maximum
-- Capture the main topic or purpose of the conversation
-- Be clear and specific
-- Not include phrases like "Chat about" or "Discussion of"
-- Not use quotation marks
-
-Conversation:
-%s
-
-Title:`, conversation)
+// buildTitlePrompt constructs the prompt for title generation from the
+// user's title_generation template; prompts passed in the parameters win
+func (h *TitleGenerationHandler) buildTitlePrompt(ctx context.Context, userID string, messages []string, params Parameters) (struct{ System, User string }, error) {
+	prompt := struct{ System, User string }{
+		System: params.SystemPrompt,
+		User:   params.UserPrompt,
+	}
+	if prompt.System != "" && prompt.User != "" {
+		return prompt, nil
+	}
+	
+	rendered, err := h.service.prompts.Render(ctx, userID, PromptTitleGeneration, map[string]interface{}{
+		"Conversation": strings.Join(messages, "\n"),
+		"Messages":     messages,
+	})
+	if err != nil {
+		return prompt, err
 	}
+	fmt.Printf("[TitleHandler] Using %s prompt template %s\n", rendered.Source, rendered.Version)
 	
-	return struct{ System, User string }{
-		System: systemPrompt,
-		User:   userPrompt,
+	if prompt.System == "" {
+		prompt.System = rendered.System
+	}
+	if prompt.User == "" {
+		prompt.User = rendered.User
 	}
+	return prompt, nil
 }
 
 // GenericHandler handles custom/generic LLM tasks
@@ -205,6 +207,7 @@ func (h *GenericHandler) Handle(ctx context.Context, req CompletionRequest) (*Co
 		Temperature: h.defaultFloat(req.Parameters.Temperature, 0.7),
 		TopP:        req.Parameters.TopP,
 		UserID:      userID,
+		Metadata:    map[string]interface{}{MetadataTaskType: string(req.Task)},
 	}
 	
 	if req.ConnectionID != "" && req.ConnectionID != "auto-selected" {
@@ -264,4 +267,107 @@ func (h *GenericHandler) defaultFloat(val *float32, def float32) *float32 {
 		return &def
 	}
 	return val
-}
\ No newline at end of file
+}
+// renderTaskPrompt renders a built-in task's prompt template for the user;
+// prompts passed in the parameters win
+func (s *Service) renderTaskPrompt(ctx context.Context, req CompletionRequest, name string, vars map[string]interface{}) (*RenderedPrompt, error) {
+	userID, _ := req.Context["user_id"].(string)
+	rendered, err := s.prompts.Render(ctx, userID, name, vars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s prompt: %w", name, err)
+	}
+	
+	if req.Parameters.SystemPrompt != "" {
+		rendered.System = req.Parameters.SystemPrompt
+	}
+	if req.Parameters.UserPrompt != "" {
+		rendered.User = req.Parameters.UserPrompt
+	}
+	return rendered, nil
+}
+
+// newTaskRequest builds the gateway request of a built-in task from its
+// prompt, with the task's defaults for parameters the caller left out
+func newTaskRequest(req CompletionRequest, prompt *RenderedPrompt, maxTokens int, temperature float32) *Request {
+	userID, _ := req.Context["user_id"].(string)
+	
+	var messages []Message
+	if prompt.System != "" {
+		messages = append(messages, Message{Role: "system", Content: prompt.System})
+	}
+	messages = append(messages, Message{Role: "user", Content: prompt.User})
+	
+	if req.Parameters.MaxTokens != nil {
+		maxTokens = *req.Parameters.MaxTokens
+	}
+	if req.Parameters.Temperature != nil {
+		temperature = *req.Parameters.Temperature
+	}
+	
+	gatewayReq := &Request{
+		Messages:    messages,
+		MaxTokens:   &maxTokens,
+		Temperature: &temperature,
+		TopP:        req.Parameters.TopP,
+		UserID:      userID,
+		Metadata:    map[string]interface{}{MetadataTaskType: string(req.Task)},
+	}
+	
+	if req.ConnectionID != "" && req.ConnectionID != "auto-selected" {
+		gatewayReq.Preferences.ConnectionID = req.ConnectionID
+	}
+	return gatewayReq
+}
+
+// taskUsage converts gateway usage to task usage
+func taskUsage(usage Usage) *TaskUsage {
+	return &TaskUsage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
+
+// contextString returns a string value from a request's context, trimmed
+func contextString(req CompletionRequest, key string) string {
+	return strings.TrimSpace(contextText(req, key))
+}
+
+// contextText returns a string value from a request's context as given, for
+// text whose leading and trailing whitespace must be kept
+func contextText(req CompletionRequest, key string) string {
+	v, _ := req.Context[key].(string)
+	return v
+}
+
+// contextStrings returns a list of strings from a request's context, given
+// either as a single string or as an array
+func contextStrings(req CompletionRequest, key string) []string {
+	var values []string
+	switch v := req.Context[key].(type) {
+	case string:
+		values = []string{v}
+	case []string:
+		values = v
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// contextObject returns a JSON object from a request's context
+func contextObject(req CompletionRequest, key string) map[string]interface{} {
+	v, _ := req.Context[key].(map[string]interface{})
+	return v
+}
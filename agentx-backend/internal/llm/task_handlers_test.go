@@ -0,0 +1,126 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTaskTestService(t *testing.T, provider *scriptedProvider) *Service {
+	return NewService(newTestGateway(t, fakeFactory{"json": provider}), nil)
+}
+
+func taskRequest(task TaskType, context map[string]interface{}) CompletionRequest {
+	context["user_id"] = "user"
+	return CompletionRequest{Task: task, Context: context, ConnectionID: "json"}
+}
+
+func TestTranslationDetectsLanguageAndChecksGlossary(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{`{"source_language": "en", "translation": "Ouvrez le tableau de bord et la facture."}`}}
+	service := newTaskTestService(t, provider)
+	handler := service.taskHandlers[TaskTranslate]
+
+	req := taskRequest(TaskTranslate, map[string]interface{}{
+		"text":            "  Open the dashboard and the invoice.\n",
+		"source_language": "auto",
+		"target_language": "fr",
+		"glossary":        map[string]interface{}{"dashboard": "tableau de bord", "invoice": "note", "user": "utilisateur"},
+	})
+	assert.NoError(t, handler.ValidateRequest(req))
+
+	resp, err := handler.Handle(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "Ouvrez le tableau de bord et la facture.", resp.Result)
+	assert.Equal(t, "en", resp.Metadata["source_language"])
+	assert.Equal(t, []string{"invoice"}, resp.Metadata["glossary_missing"], "only terms in the text are checked")
+
+	user := provider.requests[0].Messages[1].Content
+	assert.Contains(t, user, "Detect the language of the following text and translate it to fr.")
+	assert.Contains(t, user, "- dashboard → tableau de bord\n")
+	assert.Contains(t, user, "Text:\n  Open the dashboard and the invoice.\n", "the text is translated as given")
+}
+
+func TestTaskValidation(t *testing.T) {
+	service := NewService(nil, nil)
+	tests := []struct {
+		task    TaskType
+		context map[string]interface{}
+		want    string
+	}{
+		{TaskTranslate, map[string]interface{}{"target_language": "de"}, "TEXT_REQUIRED"},
+		{TaskTranslate, map[string]interface{}{"text": "hi"}, "TARGET_LANGUAGE_REQUIRED"},
+		{TaskTranslate, map[string]interface{}{"text": "hi", "target_language": "de", "glossary": map[string]interface{}{"hi": 1.0}}, "INVALID_GLOSSARY"},
+		{TaskCodeGeneration, map[string]interface{}{"language": "go"}, "INSTRUCTIONS_REQUIRED"},
+		{TaskCodeGeneration, map[string]interface{}{"instructions": "Reverse a string"}, "LANGUAGE_REQUIRED"},
+		{TaskExtract, map[string]interface{}{"text": "Ada, 36"}, "SCHEMA_REQUIRED"},
+		{TaskExtract, map[string]interface{}{"text": "Ada, 36", "schema": map[string]interface{}{"type": 1.0}}, "INVALID_SCHEMA"},
+	}
+	for _, tt := range tests {
+		err := service.taskHandlers[tt.task].ValidateRequest(taskRequest(tt.task, tt.context))
+		var llmErr *LLMError
+		if assert.True(t, errors.As(err, &llmErr), "%s %v", tt.task, tt.context) {
+			assert.Equal(t, tt.want, llmErr.Code)
+		}
+	}
+}
+
+func TestCodeGenerationExtractsFencedCode(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{"Here is the test first:\n\n```go\n" +
+		"func TestReverse(t *testing.T) {}\n```\n\n```golang\nfunc Reverse(s string) string {\n\treturn s\n}\n```\n" +
+		"Runes keep multi-byte characters intact."}}
+	service := newTaskTestService(t, provider)
+
+	req := taskRequest(TaskCodeGeneration, map[string]interface{}{
+		"instructions": "Reverse a string",
+		"language":     "Go",
+		"style":        []interface{}{"no external packages", " "},
+	})
+	resp, err := service.taskHandlers[TaskCodeGeneration].Handle(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "func TestReverse(t *testing.T) {}", resp.Result, "the first block in the language wins")
+	assert.Len(t, resp.Metadata["blocks"], 2)
+	assert.Equal(t, "Here is the test first:\n\n\n\n\nRunes keep multi-byte characters intact.", resp.Metadata["explanation"])
+	assert.Contains(t, provider.requests[0].Messages[0].Content, "expert Go programmer")
+	assert.Contains(t, provider.requests[0].Messages[1].Content, "Follow these constraints:\n- no external packages\n")
+
+	code, explanation, blocks := selectCodeBlock("func main() {}", "go")
+	assert.Equal(t, "func main() {}", code)
+	assert.Empty(t, explanation)
+	assert.Empty(t, blocks)
+}
+
+func TestExtractionReturnsValidatedData(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{`{"name": "Ada"}`, "```json\n{\"name\": \"Ada\", \"age\": 36}\n```"}}
+	service := newTaskTestService(t, provider)
+
+	req := taskRequest(TaskExtract, map[string]interface{}{
+		"text":        "Ada Lovelace was 36.",
+		"schema":      personSchema,
+		"schema_name": "person",
+	})
+	resp, err := service.taskHandlers[TaskExtract].Handle(context.Background(), req)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name": "Ada", "age": 36}`, resp.Result)
+	assert.Equal(t, map[string]interface{}{"name": "Ada", "age": 36.0}, resp.Metadata["data"])
+	assert.Equal(t, 1, resp.Metadata["schema_repairs"])
+	assert.Contains(t, provider.requests[0].Messages[1].Content, "Extract the person data from this text:\n\nAda Lovelace was 36.")
+
+	// Answers that never match fail instead of returning unvalidated data
+	provider = &scriptedProvider{replies: []string{`{"name": "Ada"}`}}
+	service = newTaskTestService(t, provider)
+	req.Context["max_repairs"] = 0.0
+	_, err = service.taskHandlers[TaskExtract].Handle(context.Background(), req)
+	assert.ErrorIs(t, err, ErrSchemaValidation)
+}
+
+func TestExtractionFormatCapsRepairs(t *testing.T) {
+	for given, want := range map[interface{}]int{1e18: MaxSchemaRepairs, 100: MaxSchemaRepairs, -3.0: 0, 1.0: 1} {
+		format := extractionFormat(taskRequest(TaskExtract, map[string]interface{}{"max_repairs": given}))
+		if assert.NotNil(t, format.MaxRepairs) {
+			assert.Equal(t, want, *format.MaxRepairs, "max_repairs %v", given)
+		}
+	}
+	assert.Nil(t, extractionFormat(taskRequest(TaskExtract, map[string]interface{}{})).MaxRepairs)
+}
@@ -0,0 +1,139 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// translationSchema is the answer the translation task asks for, so the
+// detected source language comes back alongside the translation
+var translationSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"source_language": map[string]interface{}{"type": "string", "minLength": 1},
+		"translation":     map[string]interface{}{"type": "string"},
+	},
+	"required": []interface{}{"source_language", "translation"},
+}
+
+// TranslationHandler handles translation tasks. The context carries the
+// text, target_language, an optional source_language (detected when empty
+// or "auto") and an optional glossary of term translations to enforce.
+type TranslationHandler struct {
+	service *Service
+}
+
+// Handle processes translation requests
+func (h *TranslationHandler) Handle(ctx context.Context, req CompletionRequest) (*CompletionResponse, error) {
+	source := contextString(req, "source_language")
+	if strings.EqualFold(source, "auto") {
+		source = ""
+	}
+	target := contextString(req, "target_language")
+	glossary := translationGlossary(req)
+
+	fmt.Printf("[TranslationHandler] Translating %d chars from %q to %q with %d glossary terms\n",
+		len(contextText(req, "text")), source, target, len(glossary))
+
+	prompt, err := h.service.renderTaskPrompt(ctx, req, PromptTranslation, map[string]interface{}{
+		"Text":           contextText(req, "text"),
+		"SourceLanguage": source,
+		"TargetLanguage": target,
+		"Glossary":       glossary,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	gatewayReq := newTaskRequest(req, prompt, 4096, 0.3)
+	gatewayReq.ResponseFormat = &ResponseFormat{
+		Type:       ResponseFormatJSONSchema,
+		JSONSchema: &JSONSchema{Name: "translation", Schema: translationSchema},
+	}
+
+	resp, err := h.service.gateway.Complete(ctx, gatewayReq)
+	if err != nil {
+		return nil, fmt.Errorf("gateway request failed: %w", err)
+	}
+
+	answer, ok := resp.Parsed.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("translation response was not structured")
+	}
+	translation, _ := answer["translation"].(string)
+	detected, _ := answer["source_language"].(string)
+
+	metadata := map[string]interface{}{
+		"task":              string(TaskTranslate),
+		"source_language":   source,
+		"detected_language": detected,
+		"target_language":   target,
+		"prompt_version":    prompt.Version,
+	}
+	if source == "" {
+		metadata["source_language"] = detected
+	}
+	if missing := missingGlossaryTerms(glossary, contextText(req, "text"), translation); len(missing) > 0 {
+		fmt.Printf("[TranslationHandler] Translation does not use the glossary for: %s\n", strings.Join(missing, ", "))
+		metadata["glossary_missing"] = missing
+	}
+
+	return &CompletionResponse{
+		Result:       translation,
+		Provider:     resp.Provider,
+		ConnectionID: req.ConnectionID,
+		Metadata:     metadata,
+		Usage:        taskUsage(resp.Usage),
+	}, nil
+}
+
+// ValidateRequest validates translation requests
+func (h *TranslationHandler) ValidateRequest(req CompletionRequest) error {
+	if contextString(req, "text") == "" {
+		return ErrTextRequired
+	}
+	if contextString(req, "target_language") == "" {
+		return ErrTargetLanguageRequired
+	}
+	if glossary, ok := req.Context["glossary"]; ok && glossary != nil {
+		terms, ok := glossary.(map[string]interface{})
+		if !ok {
+			return &LLMError{Code: "INVALID_GLOSSARY", Message: "glossary must map terms to their translations"}
+		}
+		for term, translation := range terms {
+			if _, ok := translation.(string); !ok {
+				return &LLMError{Code: "INVALID_GLOSSARY", Message: fmt.Sprintf("glossary translation of %q must be a string", term)}
+			}
+		}
+	}
+	return nil
+}
+
+// translationGlossary returns the request's glossary without empty entries
+func translationGlossary(req CompletionRequest) map[string]string {
+	glossary := make(map[string]string)
+	for term, v := range contextObject(req, "glossary") {
+		translation, _ := v.(string)
+		term, translation = strings.TrimSpace(term), strings.TrimSpace(translation)
+		if term != "" && translation != "" {
+			glossary[term] = translation
+		}
+	}
+	return glossary
+}
+
+// missingGlossaryTerms returns the glossary terms used in the text whose
+// required translation does not appear in the translated text
+func missingGlossaryTerms(glossary map[string]string, text, translation string) []string {
+	text, translation = strings.ToLower(text), strings.ToLower(translation)
+	var missing []string
+	for term, want := range glossary {
+		if strings.Contains(text, strings.ToLower(term)) && !strings.Contains(translation, strings.ToLower(want)) {
+			missing = append(missing, term)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"context"
+	"strings"
+
+	"github.com/agentx/agentx-backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attributes set by the gateway. Model and token names follow the
+// OpenTelemetry GenAI conventions.
+const (
+	attrProvider         = "gen_ai.system"
+	attrRequestModel     = "gen_ai.request.model"
+	attrResponseModel    = "gen_ai.response.model"
+	attrPromptTokens     = "gen_ai.usage.input_tokens"
+	attrCompletionTokens = "gen_ai.usage.output_tokens"
+	attrConnection       = "agentx.connection_id"
+	attrRetries          = "agentx.retries"
+	attrFallbackUsed     = "agentx.fallback_used"
+	attrCost             = "agentx.cost_usd"
+	attrRouteReason      = "agentx.route_reason"
+	attrCache            = "agentx.cache"
+	attrHedgeWinner      = "agentx.hedge_winner"
+)
+
+// startProviderSpan starts the span around one call to a provider
+func (g *Gateway) startProviderSpan(ctx context.Context, name, userID, connectionID, model string) (context.Context, trace.Span) {
+	provider, connection := g.connectionLabels(userID, connectionID)
+	return tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(attrProvider, provider),
+			attribute.String(attrConnection, connection),
+			attribute.String(attrRequestModel, model),
+		),
+	)
+}
+
+// traceRoute records the routing decision on the route span
+func traceRoute(span trace.Span, userID string, route *RouteInfo) {
+	if route == nil {
+		return
+	}
+	span.SetAttributes(
+		attribute.String(attrConnection, strings.TrimPrefix(route.ConnectionID, userID+":")),
+		attribute.String(attrRequestModel, route.Model),
+		attribute.String(attrRouteReason, route.Reason),
+	)
+}
+
+// traceUsage records the outcome of a call on the gateway span in ctx
+func traceUsage(ctx context.Context, record UsageRecord) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		attribute.String(attrProvider, record.Provider),
+		attribute.String(attrConnection, record.ConnectionID),
+		attribute.String(attrResponseModel, record.Model),
+		attribute.Int(attrPromptTokens, record.PromptTokens),
+		attribute.Int(attrCompletionTokens, record.CompletionTokens),
+		attribute.Float64(attrCost, record.Cost),
+		attribute.Bool(attrFallbackUsed, record.FallbackUsed),
+	)
+}
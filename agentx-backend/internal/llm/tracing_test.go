@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestCompleteRecordsSpans(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	defer otel.SetTracerProvider(previous)
+
+	g := NewGateway(WithMiddleware(&BaseMiddleware{}))
+	g.providers.factory = fakeFactory{"traced": &usageProvider{}}
+	assert.NoError(t, g.RegisterProvider("user", "traced", ProviderConfig{Name: "traced", Type: "openai"}))
+
+	_, err := g.Complete(context.Background(), &Request{
+		UserID:   "user",
+		Messages: []Message{{Role: "user", Content: "hi"}},
+	})
+	assert.NoError(t, err)
+
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range spans.Ended() {
+		byName[span.Name()] = span
+	}
+	assert.Contains(t, byName, "gateway.route")
+	assert.Contains(t, byName, "provider.complete")
+	if !assert.Contains(t, byName, "gateway.complete") {
+		return
+	}
+
+	root := byName["gateway.complete"]
+	assert.Equal(t, root.SpanContext().SpanID(), byName["provider.complete"].Parent().SpanID())
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range root.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	assert.Equal(t, "traced", attrs[attrConnection].AsString())
+	assert.Equal(t, int64(0), attrs[attrRetries].AsInt64())
+	assert.Equal(t, int64(1000000), attrs[attrCompletionTokens].AsInt64())
+}
@@ -11,7 +11,45 @@ type Message struct {
 	Name       string                 `json:"name,omitempty"`
 	ToolCalls  []ToolCall            `json:"tool_calls,omitempty"`
 	ToolCallID string                `json:"tool_call_id,omitempty"`
+	Images     []Image                `json:"images,omitempty"`
 	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+
+	// Cacheable marks the prompt up to and including this message as a
+	// cacheable prefix, e.g. a long document asked about repeatedly
+	Cacheable bool `json:"cacheable,omitempty"`
+
+	// Thinking that preceded an assistant message, kept apart from the answer.
+	// The signature lets it be sent back on later turns; redacted thinking is
+	// encrypted by the provider and sent back as is.
+	Reasoning          string   `json:"reasoning,omitempty"`
+	ReasoningSignature string   `json:"reasoning_signature,omitempty"`
+	RedactedReasoning  []string `json:"redacted_reasoning,omitempty"`
+}
+
+// ReasoningOptions enables extended thinking on models that support it
+type ReasoningOptions struct {
+	BudgetTokens int `json:"budget_tokens"` // Tokens the model may spend thinking
+}
+
+// PromptCacheOptions selects request prefixes for providers to cache
+type PromptCacheOptions struct {
+	System bool `json:"system,omitempty"` // Cache the system prompt
+	Tools  bool `json:"tools,omitempty"`  // Cache the tool definitions
+}
+
+// Image is an image attached to a message, by URL or as inline base64 data
+type Image struct {
+	URL      string `json:"url,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+}
+
+// DataURL returns the image as a URL, encoding inline images as data URLs
+func (i Image) DataURL() string {
+	if i.URL != "" {
+		return i.URL
+	}
+	return "data:" + i.MimeType + ";base64," + i.Data
 }
 
 // ToolCall represents a tool invocation
@@ -22,6 +60,7 @@ type ToolCall struct {
 		Name      string `json:"name"`
 		Arguments string `json:"arguments"`
 	} `json:"function"`
+	Index *int `json:"index,omitempty"` // Position of the call within a streamed response
 }
 
 // Tool represents an available tool/function
@@ -43,6 +82,18 @@ type Preferences struct {
 	Model        string   `json:"model,omitempty"`
 	ConnectionID string   `json:"connection_id,omitempty"`
 	Capabilities []string `json:"capabilities,omitempty"`
+
+	// Trade-offs used to pick a connection and model automatically
+	Speed   string `json:"speed,omitempty"`   // fast, balanced, quality
+	Cost    string `json:"cost,omitempty"`    // economy, standard, premium
+	Privacy string `json:"privacy,omitempty"` // local, cloud
+}
+
+// HasTradeoffs reports whether speed, cost or privacy preferences are set
+func (p Preferences) HasTradeoffs() bool {
+	return (p.Speed != "" && p.Speed != "balanced") ||
+		(p.Cost != "" && p.Cost != "standard") ||
+		p.Privacy != ""
 }
 
 // Requirements for the request
@@ -59,6 +110,11 @@ type Usage struct {
 	CompletionTokens int     `json:"completion_tokens"`
 	TotalTokens      int     `json:"total_tokens"`
 	EstimatedCost    float64 `json:"estimated_cost,omitempty"`
+
+	// Prompt tokens read from or written to the provider's prompt cache;
+	// both are included in PromptTokens
+	CacheReadTokens     int `json:"cache_read_tokens,omitempty"`
+	CacheCreationTokens int `json:"cache_creation_tokens,omitempty"`
 }
 
 // Metadata about the request/response
@@ -69,6 +125,17 @@ type Metadata struct {
 	LatencyMs     int64         `json:"latency_ms,omitempty"`
 	Retries       int           `json:"retries,omitempty"`
 	FallbackUsed  bool          `json:"fallback_used,omitempty"`
+	RoutingReason string        `json:"routing_reason,omitempty"`
+	RoutingScore  float64       `json:"routing_score,omitempty"`
+	TokensTrimmed int           `json:"tokens_trimmed,omitempty"`
+	ContextStrategy string      `json:"context_strategy,omitempty"`
+	BudgetWarnings []string     `json:"budget_warnings,omitempty"`
+	Cache         string        `json:"cache,omitempty"`            // hit, semantic_hit, coalesced, miss or bypass
+	CacheSimilarity float64     `json:"cache_similarity,omitempty"` // Prompt similarity of a semantic hit
+	Hedged        bool          `json:"hedged,omitempty"`           // A hedge was sent to a second connection
+	HedgeWinner   string        `json:"hedge_winner,omitempty"`     // primary or hedge
+	StructuredOutput string     `json:"structured_output,omitempty"` // json_schema, tool or prompt
+	SchemaRepairs int           `json:"schema_repairs,omitempty"`    // Repair round-trips before the answer matched
 	CircuitBreaker string       `json:"circuit_breaker_status,omitempty"`
 	Extra         map[string]interface{} `json:"extra,omitempty"`
 }
\ No newline at end of file
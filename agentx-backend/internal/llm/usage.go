@@ -0,0 +1,112 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// UsageRecord describes one gateway call for the usage ledger
+type UsageRecord struct {
+	UserID           string
+	ConnectionID     string // Bare connection ID, without the user prefix
+	APIKeyID         string
+	Provider         string
+	Model            string
+	TaskType         string
+	Stream           bool
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+	Latency          time.Duration
+	Success          bool
+	Error            string
+	FallbackUsed     bool
+	CreatedAt        time.Time
+}
+
+// UsageRecorder persists usage records. Implementations must not block the
+// request path.
+type UsageRecorder interface {
+	RecordUsage(ctx context.Context, record UsageRecord)
+}
+
+// recordUsage prices a call with the router's pricing catalog and hands it
+// to the usage recorder, if any. It returns the cost of the call.
+func (g *Gateway) recordUsage(ctx context.Context, record UsageRecord, usage Usage) float64 {
+	config, _ := g.providers.GetConfig(record.UserID, record.ConnectionID)
+	if pricing, ok := g.router.PricingCatalog().PriceFor(config, record.Model); ok {
+		record.Cost = pricing.Cost(usage)
+	}
+	if config.Type != "" {
+		record.Provider = config.Type
+	}
+	record.ConnectionID = strings.TrimPrefix(record.ConnectionID, record.UserID+":")
+	record.PromptTokens = usage.PromptTokens
+	record.CompletionTokens = usage.CompletionTokens
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+
+	g.mu.RLock()
+	recorder := g.usage
+	g.mu.RUnlock()
+	if recorder != nil {
+		recorder.RecordUsage(ctx, record)
+	}
+	if g.budgets != nil {
+		g.budgets.Observe(record)
+	}
+	if g.metrics != nil {
+		g.metrics.ObserveCall(record)
+	}
+	traceUsage(ctx, record)
+	return record.Cost
+}
+
+// connectionLabels returns the provider type and bare connection ID of a
+// routed connection for metrics
+func (g *Gateway) connectionLabels(userID, connectionID string) (provider, connection string) {
+	provider = connectionID
+	if config, ok := g.providers.GetConfig(userID, connectionID); ok && config.Type != "" {
+		provider = config.Type
+	}
+	return provider, strings.TrimPrefix(connectionID, userID+":")
+}
+
+// observeRetries records retries on a routed connection
+func (g *Gateway) observeRetries(userID, connectionID, model string, retries int) {
+	if g.metrics == nil || retries == 0 {
+		return
+	}
+	provider, connection := g.connectionLabels(userID, connectionID)
+	g.metrics.ObserveRetries(provider, model, connection, retries)
+}
+
+// observeFallback records a call moving off a failed connection
+func (g *Gateway) observeFallback(userID, connectionID, model string) {
+	if g.metrics == nil {
+		return
+	}
+	provider, connection := g.connectionLabels(userID, connectionID)
+	g.metrics.ObserveFallback(provider, model, connection)
+}
+
+// SetUsageRecorder sets where the gateway records the usage of every call
+func (g *Gateway) SetUsageRecorder(recorder UsageRecorder) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.usage = recorder
+}
+
+// errorText returns a bounded description of a failed call for the ledger
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	text := err.Error()
+	if len(text) > 500 {
+		text = text[:500]
+	}
+	return text
+}
@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryRecorder struct {
+	records []UsageRecord
+	mu      sync.Mutex
+}
+
+func (r *memoryRecorder) RecordUsage(ctx context.Context, record UsageRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, record)
+}
+
+type usageProvider struct {
+	fakeProvider
+}
+
+func (p *usageProvider) Complete(ctx context.Context, req *Request) (*Response, error) {
+	resp, err := p.fakeProvider.Complete(ctx, req)
+	if resp != nil {
+		resp.Model = "gpt-4o-mini"
+		resp.Usage = Usage{PromptTokens: 1000000, CompletionTokens: 1000000, TotalTokens: 2000000}
+	}
+	return resp, err
+}
+
+func TestCompleteRecordsUsage(t *testing.T) {
+	recorder := &memoryRecorder{}
+	g := newTestGateway(t, fakeFactory{"primary": &usageProvider{}})
+	g.SetUsageRecorder(recorder)
+
+	resp, err := g.Complete(context.Background(), &Request{
+		UserID:   "user",
+		Messages: []Message{{Role: "user", Content: "hi"}},
+		Metadata: map[string]interface{}{MetadataTaskType: "chat"},
+	})
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.75, resp.Usage.EstimatedCost, 1e-9)
+
+	assert.Len(t, recorder.records, 1)
+	record := recorder.records[0]
+	assert.Equal(t, "user", record.UserID)
+	assert.Equal(t, "primary", record.ConnectionID)
+	assert.Equal(t, "gpt-4o-mini", record.Model)
+	assert.Equal(t, "chat", record.TaskType)
+	assert.True(t, record.Success)
+	assert.InDelta(t, 0.75, record.Cost, 1e-9)
+}
+
+func TestStreamCompleteRecordsFailedLegs(t *testing.T) {
+	recorder := &memoryRecorder{}
+	primary := &fakeProvider{chunks: []string{"Hello, "}, failErr: errors.New("connection reset")}
+	fallback := &fakeProvider{chunks: []string{"world"}}
+	g := newTestGateway(t, fakeFactory{"primary": primary, "fallback": fallback})
+	g.SetUsageRecorder(recorder)
+	g.router.SetFallback("primary", "user:fallback")
+
+	stream, err := g.StreamComplete(context.Background(), &Request{
+		UserID:       "user",
+		ConnectionID: "primary",
+		Messages:     []Message{{Role: "user", Content: "hi"}},
+		Stream:       true,
+	})
+	assert.NoError(t, err)
+	collect(stream)
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	assert.Len(t, recorder.records, 2)
+	assert.False(t, recorder.records[0].Success)
+	assert.Equal(t, "connection reset", recorder.records[0].Error)
+	assert.True(t, recorder.records[1].Success)
+	assert.True(t, recorder.records[1].FallbackUsed)
+	assert.Equal(t, "fallback", recorder.records[1].ConnectionID)
+	assert.Greater(t, recorder.records[1].CompletionTokens, 0)
+}
+
+func TestCostPricesCachedPromptTokens(t *testing.T) {
+	pricing, ok := NewPricingCatalog().Lookup("claude-sonnet-4-20250514")
+	assert.True(t, ok)
+
+	cost := pricing.Cost(Usage{
+		PromptTokens:        3000000,
+		CompletionTokens:    1000000,
+		CacheReadTokens:     1000000,
+		CacheCreationTokens: 1000000,
+	})
+	// 1M uncached at $3, 1M read at $0.30, 1M written at $3.75, 1M out at $15
+	assert.InDelta(t, 22.05, cost, 1e-9)
+
+	uncached := ModelPricing{InputPerMillion: 2, OutputPerMillion: 8}
+	assert.InDelta(t, 10.0, uncached.Cost(Usage{PromptTokens: 1000000, CompletionTokens: 1000000, CacheReadTokens: 500000}), 1e-9)
+}
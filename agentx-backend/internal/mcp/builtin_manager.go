@@ -1,16 +1,21 @@
 package mcp
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sync"
+	"time"
 
+	"github.com/agentx/agentx-backend/internal/metrics"
 	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/agentx/agentx-backend/internal/tracing"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // BuiltinMCPServer represents a built-in MCP server configuration
@@ -145,8 +150,9 @@ func (m *BuiltinMCPManager) GetBuiltinServer(serverID string) (*BuiltinMCPServer
 	return server, nil
 }
 
-// SetUserServerEnabled enables or disables a built-in server for a specific user
-func (m *BuiltinMCPManager) SetUserServerEnabled(userID uuid.UUID, serverID string, enabled bool) error {
+// SetUserServerEnabled enables or disables a built-in server for a specific
+// user. A server process started here joins the trace in ctx.
+func (m *BuiltinMCPManager) SetUserServerEnabled(ctx context.Context, userID uuid.UUID, serverID string, enabled bool) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -166,7 +172,7 @@ func (m *BuiltinMCPManager) SetUserServerEnabled(userID uuid.UUID, serverID stri
 
 	// Start or stop the server process if needed
 	if enabled {
-		return m.startServerForUser(userID, server)
+		return m.startServerForUser(ctx, userID, server)
 	} else {
 		return m.stopServerForUser(userID, serverID)
 	}
@@ -204,7 +210,7 @@ func (m *BuiltinMCPManager) GetUserEnabledServers(userID uuid.UUID) []BuiltinMCP
 }
 
 // startServerForUser starts a built-in MCP server process for a user
-func (m *BuiltinMCPManager) startServerForUser(userID uuid.UUID, server *BuiltinMCPServer) error {
+func (m *BuiltinMCPManager) startServerForUser(ctx context.Context, userID uuid.UUID, server *BuiltinMCPServer) error {
 	processKey := fmt.Sprintf("%s-%s", server.ID, userID.String())
 	
 	// Check if client already exists
@@ -229,6 +235,7 @@ func (m *BuiltinMCPManager) startServerForUser(userID uuid.UUID, server *Builtin
 	if cmd == nil {
 		return fmt.Errorf("failed to create process for %s", server.Name)
 	}
+	tracing.InjectEnv(ctx, cmd)
 
 	// Create simple MCP client
 	client := NewSimpleMCPClient(server.ID, userID, cmd, m.logger)
@@ -445,7 +452,7 @@ func (m *BuiltinMCPManager) Cleanup() {
 }
 
 // CallTool calls a tool on a built-in MCP server
-func (m *BuiltinMCPManager) CallTool(userID uuid.UUID, serverID string, toolName string, arguments json.RawMessage) (interface{}, error) {
+func (m *BuiltinMCPManager) CallTool(ctx context.Context, userID uuid.UUID, serverID string, toolName string, arguments json.RawMessage) (interface{}, error) {
 	processKey := fmt.Sprintf("%s-%s", serverID, userID.String())
 	
 	m.mu.RLock()
@@ -457,8 +464,17 @@ func (m *BuiltinMCPManager) CallTool(userID uuid.UUID, serverID string, toolName
 	}
 	
 	// Call the tool
+	_, span := tracing.Start(ctx, "mcp.tools/call",
+		attribute.String("mcp.server", serverID),
+		attribute.String("mcp.tool", toolName),
+	)
+	defer span.End()
+	start := time.Now()
 	result, err := client.CallTool(toolName, arguments)
+	metrics.MCPToolCallDuration.WithLabelValues(serverID, toolName).Observe(time.Since(start).Seconds())
+	metrics.MCPToolCalls.WithLabelValues(serverID, toolName, metrics.Status(err)).Inc()
 	if err != nil {
+		tracing.RecordError(span, err)
 		return nil, err
 	}
 	
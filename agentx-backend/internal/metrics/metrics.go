@@ -0,0 +1,173 @@
+// Package metrics defines the Prometheus metrics exposed on /metrics for the
+// LLM gateway, MCP tool calls and HTTP requests.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "agentx"
+
+// Registry holds every AgentX metric plus the Go runtime and process
+// collectors
+var Registry = prometheus.NewRegistry()
+
+// Latency buckets in seconds. LLM calls run far longer than typical HTTP
+// requests, so the gateway buckets reach two minutes.
+var (
+	gatewayBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120}
+	toolBuckets    = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	httpBuckets    = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+)
+
+// Gateway metrics, labeled by provider type, model and connection ID
+var (
+	GatewayRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "gateway",
+		Name:      "requests_total",
+		Help:      "LLM gateway calls by provider, model, connection, stream and status.",
+	}, []string{"provider", "model", "connection", "stream", "status"})
+
+	GatewayRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "gateway",
+		Name:      "request_duration_seconds",
+		Help:      "LLM gateway call latency in seconds.",
+		Buckets:   gatewayBuckets,
+	}, []string{"provider", "model", "connection", "stream"})
+
+	GatewayTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "gateway",
+		Name:      "tokens_total",
+		Help:      "Tokens processed by the LLM gateway, by type (prompt or completion).",
+	}, []string{"provider", "model", "connection", "type"})
+
+	GatewayCost = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "gateway",
+		Name:      "cost_usd_total",
+		Help:      "Estimated cost of LLM gateway calls in USD.",
+	}, []string{"provider", "model", "connection"})
+
+	GatewayRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "gateway",
+		Name:      "retries_total",
+		Help:      "Retries of transient provider failures.",
+	}, []string{"provider", "model", "connection"})
+
+	GatewayFallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "gateway",
+		Name:      "fallbacks_total",
+		Help:      "Calls moved to a fallback connection, by the connection that failed.",
+	}, []string{"provider", "model", "connection"})
+
+	GatewayHedges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "gateway",
+		Name:      "hedge_attempts_total",
+		Help:      "Attempts of hedged calls, by attempt (primary or hedge) and outcome (won, failed or cancelled).",
+	}, []string{"provider", "model", "connection", "attempt", "outcome"})
+
+	GatewayHedgeDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "gateway",
+		Name:      "hedge_attempt_duration_seconds",
+		Help:      "Time each attempt of a hedged call ran before the race was decided.",
+		Buckets:   gatewayBuckets,
+	}, []string{"provider", "model", "connection", "attempt"})
+
+	CircuitBreakerTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "gateway",
+		Name:      "circuit_breaker_transitions_total",
+		Help:      "Circuit breaker state changes.",
+	}, []string{"connection", "model", "from", "to"})
+
+	CircuitBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "gateway",
+		Name:      "circuit_breaker_state",
+		Help:      "Current circuit breaker state: 0 closed, 1 open, 2 half-open.",
+	}, []string{"connection", "model"})
+)
+
+// MCP tool call metrics, labeled by server and tool
+var (
+	MCPToolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mcp",
+		Name:      "tool_calls_total",
+		Help:      "MCP tool calls by server, tool and status.",
+	}, []string{"server", "tool", "status"})
+
+	MCPToolCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "mcp",
+		Name:      "tool_call_duration_seconds",
+		Help:      "MCP tool call latency in seconds.",
+		Buckets:   toolBuckets,
+	}, []string{"server", "tool"})
+)
+
+// HTTP metrics, labeled by method, route template and status code
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency in seconds.",
+		Buckets:   httpBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		GatewayRequests,
+		GatewayRequestDuration,
+		GatewayTokens,
+		GatewayCost,
+		GatewayRetries,
+		GatewayFallbacks,
+		GatewayHedges,
+		GatewayHedgeDuration,
+		CircuitBreakerTransitions,
+		CircuitBreakerState,
+		MCPToolCalls,
+		MCPToolCallDuration,
+		HTTPRequests,
+		HTTPRequestDuration,
+	)
+}
+
+// Status returns the status label for an outcome
+func Status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// Handler serves the registry in the Prometheus text or OpenMetrics format,
+// as negotiated with the scraper
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Batch job statuses
+const (
+	BatchJobQueued    = "queued"
+	BatchJobRunning   = "running"
+	BatchJobCompleted = "completed"
+	BatchJobCancelled = "cancelled"
+)
+
+// Batch item statuses
+const (
+	BatchItemPending   = "pending"
+	BatchItemRunning   = "running"
+	BatchItemSucceeded = "succeeded"
+	BatchItemFailed    = "failed"
+	BatchItemCancelled = "cancelled"
+)
+
+// BatchJob is a set of completion requests uploaded as JSONL and processed in
+// the background
+type BatchJob struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
+	Name        string     `json:"name" db:"name"`
+	Status      string     `json:"status" db:"status"`           // queued, running, completed or cancelled
+	Concurrency int        `json:"concurrency" db:"concurrency"` // Items in flight per connection
+	MaxAttempts int        `json:"max_attempts" db:"max_attempts"`
+	TotalItems  int        `json:"total_items" db:"total_items"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// BatchItem is one request of a batch job, a line of the uploaded JSONL
+type BatchItem struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	JobID       uuid.UUID       `json:"job_id" db:"job_id"`
+	Line        int             `json:"line" db:"line"`
+	CustomID    string          `json:"custom_id,omitempty" db:"custom_id"`
+	Request     json.RawMessage `json:"request" db:"request"`
+	Status      string          `json:"status" db:"status"` // pending, running, succeeded, failed or cancelled
+	Attempts    int             `json:"attempts" db:"attempts"`
+	Response    json.RawMessage `json:"response,omitempty" db:"response"`
+	Error       string          `json:"error,omitempty" db:"error"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// BatchJobCreateRequest holds the options of a new batch job; the items come
+// from the uploaded JSONL
+type BatchJobCreateRequest struct {
+	Name        string `json:"name,omitempty"`
+	Concurrency int    `json:"concurrency,omitempty"`
+	MaxAttempts int    `json:"max_attempts,omitempty"`
+}
+
+// BatchProgress counts a job's items by status
+type BatchProgress struct {
+	Pending   int `json:"pending"`
+	Running   int `json:"running"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Cancelled int `json:"cancelled"`
+}
+
+// BatchJobStatus is a batch job together with the progress of its items
+type BatchJobStatus struct {
+	BatchJob
+	Progress BatchProgress `json:"progress"`
+}
+
+// BatchResult is a line of a job's downloadable JSONL results
+type BatchResult struct {
+	CustomID string          `json:"custom_id,omitempty"`
+	Line     int             `json:"line"`
+	Status   string          `json:"status"`
+	Attempts int             `json:"attempts"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Budget is a spend cap and/or token-per-minute quota on a user, API key or
+// connection, enforced by the LLM gateway
+type Budget struct {
+	ID              uuid.UUID `json:"id" db:"id"`
+	UserID          uuid.UUID `json:"user_id" db:"user_id"`   // Owner of the capped user, API key or connection
+	Scope           string    `json:"scope" db:"scope"`       // user, api_key or connection
+	ScopeID         uuid.UUID `json:"scope_id" db:"scope_id"` // ID of the capped user, API key or connection
+	Period          string    `json:"period" db:"period"`     // daily or monthly
+	SpendLimit      float64   `json:"spend_limit" db:"spend_limit"`
+	WarnThreshold   float64   `json:"warn_threshold" db:"warn_threshold"`
+	TokensPerMinute int       `json:"tokens_per_minute" db:"tokens_per_minute"`
+	Action          string    `json:"action" db:"action"` // reject or downgrade
+	DowngradeModel  string    `json:"downgrade_model,omitempty" db:"downgrade_model"`
+	Enabled         bool      `json:"enabled" db:"enabled"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// BudgetCreateRequest represents a request to create a budget
+type BudgetCreateRequest struct {
+	Scope           string    `json:"scope" validate:"required,oneof=user api_key connection"`
+	ScopeID         uuid.UUID `json:"scope_id" validate:"required"`
+	Period          string    `json:"period,omitempty"`
+	SpendLimit      float64   `json:"spend_limit"`
+	WarnThreshold   *float64  `json:"warn_threshold,omitempty"`
+	TokensPerMinute int       `json:"tokens_per_minute"`
+	Action          string    `json:"action,omitempty"`
+	DowngradeModel  string    `json:"downgrade_model,omitempty"`
+	Enabled         *bool     `json:"enabled,omitempty"`
+}
+
+// BudgetUpdateRequest represents a request to update a budget
+type BudgetUpdateRequest struct {
+	Period          *string  `json:"period,omitempty"`
+	SpendLimit      *float64 `json:"spend_limit,omitempty"`
+	WarnThreshold   *float64 `json:"warn_threshold,omitempty"`
+	TokensPerMinute *int     `json:"tokens_per_minute,omitempty"`
+	Action          *string  `json:"action,omitempty"`
+	DowngradeModel  *string  `json:"downgrade_model,omitempty"`
+	Enabled         *bool    `json:"enabled,omitempty"`
+}
+
+// BudgetStatus is a budget together with the spend recorded in its current
+// period
+type BudgetStatus struct {
+	Budget
+	Spent       float64   `json:"spent"`
+	Remaining   float64   `json:"remaining"`
+	PeriodStart time.Time `json:"period_start"`
+	ResetAt     time.Time `json:"reset_at"`
+}
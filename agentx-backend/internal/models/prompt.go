@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PromptTemplate is a stored version of a named prompt. Templates without a
+// user are global and apply to every user without an override of their own.
+type PromptTemplate struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	UserID         *uuid.UUID `json:"user_id,omitempty" db:"user_id"` // nil for global templates
+	Name           string     `json:"name" db:"name"`
+	Version        string     `json:"version" db:"version"` // Semantic version
+	Description    string     `json:"description,omitempty" db:"description"`
+	SystemTemplate string     `json:"system" db:"system_template"` // Go text/template
+	UserTemplate   string     `json:"user" db:"user_template"`     // Go text/template
+	IsDefault      bool       `json:"is_default" db:"is_default"`  // Version the owner's tasks render
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// PromptTemplateCreateRequest represents a request to store a new version of
+// a prompt template. Versions are immutable; changes are new versions.
+type PromptTemplateCreateRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Version     string `json:"version" validate:"required"`
+	Description string `json:"description,omitempty"`
+	System      string `json:"system,omitempty"`
+	User        string `json:"user,omitempty"`
+	Global      bool   `json:"global,omitempty"`  // Admin only
+	Default     *bool  `json:"default,omitempty"` // Defaults to true for an owner's first version
+}
+
+// PromptTemplateUpdateRequest represents a request to update a stored
+// version. Making an older version the default rolls back to it.
+type PromptTemplateUpdateRequest struct {
+	Description *string `json:"description,omitempty"`
+	Default     *bool   `json:"default,omitempty"`
+}
+
+// PromptRenderRequest represents a request to preview a prompt template. It
+// renders the inline system and user templates when given, otherwise the
+// stored template picked by id or by name and version, otherwise the version
+// the user's tasks would render for the name.
+type PromptRenderRequest struct {
+	Name      string                 `json:"name,omitempty"`
+	Version   string                 `json:"version,omitempty"`
+	ID        *uuid.UUID             `json:"id,omitempty"`
+	System    string                 `json:"system,omitempty"`
+	User      string                 `json:"user,omitempty"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
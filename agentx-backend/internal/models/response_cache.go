@@ -0,0 +1,20 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ResponseCacheEntry is an LLM gateway response kept for reuse by repeated
+// or, with semantic caching, similar prompts from the same user
+type ResponseCacheEntry struct {
+	Key       string          `json:"key" db:"cache_key"`
+	UserID    string          `json:"user_id" db:"user_id"`
+	Scope     string          `json:"scope" db:"scope"`
+	Embedding pq.Float32Array `json:"embedding,omitempty" db:"embedding"`
+	Response  json.RawMessage `json:"response" db:"response"`
+	ExpiresAt time.Time       `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,208 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RoutingRule represents a declarative routing rule
+type RoutingRule struct {
+	ID                 uuid.UUID         `json:"id" db:"id"`
+	UserID             *uuid.UUID        `json:"user_id,omitempty" db:"user_id"` // nil for global rules
+	Name               string            `json:"name" db:"name"`
+	Description        string            `json:"description" db:"description"`
+	Priority           int               `json:"priority" db:"priority"`
+	Enabled            bool              `json:"enabled" db:"enabled"`
+	Conditions         RoutingConditions `json:"conditions" db:"conditions"`
+	TargetConnectionID *uuid.UUID        `json:"target_connection_id,omitempty" db:"target_connection_id"`
+	TargetProvider     string            `json:"target_provider,omitempty" db:"target_provider"`
+	TargetModel        string            `json:"target_model,omitempty" db:"target_model"`
+	CreatedAt          time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// IsGlobal reports whether the rule applies to all users
+func (r *RoutingRule) IsGlobal() bool {
+	return r.UserID == nil
+}
+
+// RoutingConditions are the match conditions of a routing rule. Unset
+// fields match any request.
+type RoutingConditions struct {
+	Models          []string `json:"models,omitempty"`
+	Capabilities    []string `json:"capabilities,omitempty"`
+	MinMessageChars int      `json:"min_message_chars,omitempty"`
+	MaxMessageChars int      `json:"max_message_chars,omitempty"`
+	TaskTypes       []string `json:"task_types,omitempty"`
+	UserIDs         []string `json:"user_ids,omitempty"`
+}
+
+// Value implements the driver.Valuer interface
+func (c RoutingConditions) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface
+func (c *RoutingConditions) Scan(value interface{}) error {
+	if value == nil {
+		*c = RoutingConditions{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+
+	return json.Unmarshal(bytes, c)
+}
+
+// RoutingRuleCreateRequest represents a request to create a routing rule
+type RoutingRuleCreateRequest struct {
+	Name               string            `json:"name" validate:"required,min=1,max=255"`
+	Description        string            `json:"description"`
+	Priority           int               `json:"priority"`
+	Enabled            *bool             `json:"enabled,omitempty"`
+	Global             bool              `json:"global"` // Admin only
+	Conditions         RoutingConditions `json:"conditions"`
+	TargetConnectionID *uuid.UUID        `json:"target_connection_id,omitempty"`
+	TargetProvider     string            `json:"target_provider,omitempty"`
+	TargetModel        string            `json:"target_model,omitempty"`
+}
+
+// RoutingRuleUpdateRequest represents a request to update a routing rule
+type RoutingRuleUpdateRequest struct {
+	Name               string             `json:"name,omitempty" validate:"omitempty,min=1,max=255"`
+	Description        *string            `json:"description,omitempty"`
+	Priority           *int               `json:"priority,omitempty"`
+	Enabled            *bool              `json:"enabled,omitempty"`
+	Conditions         *RoutingConditions `json:"conditions,omitempty"`
+	TargetConnectionID *uuid.UUID         `json:"target_connection_id,omitempty"`
+	TargetProvider     *string            `json:"target_provider,omitempty"`
+	TargetModel        *string            `json:"target_model,omitempty"`
+}
+
+// FallbackChain represents an ordered list of connections to try when the
+// primary connection fails
+type FallbackChain struct {
+	ID                  uuid.UUID     `json:"id" db:"id"`
+	UserID              uuid.UUID     `json:"user_id" db:"user_id"`
+	Name                string        `json:"name" db:"name"`
+	PrimaryConnectionID *uuid.UUID    `json:"primary_connection_id,omitempty" db:"primary_connection_id"` // nil applies to any primary
+	Steps               FallbackSteps `json:"steps" db:"steps"`
+	Enabled             bool          `json:"enabled" db:"enabled"`
+	CreatedAt           time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// FallbackStep is one entry of a fallback chain
+type FallbackStep struct {
+	ConnectionID uuid.UUID `json:"connection_id"`
+	Model        string    `json:"model,omitempty"` // Empty keeps the requested model
+}
+
+// FallbackSteps is an ordered list of fallback steps stored as JSONB
+type FallbackSteps []FallbackStep
+
+// Value implements the driver.Valuer interface
+func (s FallbackSteps) Value() (driver.Value, error) {
+	if s == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(s)
+}
+
+// Scan implements the sql.Scanner interface
+func (s *FallbackSteps) Scan(value interface{}) error {
+	if value == nil {
+		*s = FallbackSteps{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+
+	return json.Unmarshal(bytes, s)
+}
+
+// FallbackChainCreateRequest represents a request to create a fallback chain
+type FallbackChainCreateRequest struct {
+	Name                string         `json:"name" validate:"required,min=1,max=255"`
+	PrimaryConnectionID *uuid.UUID     `json:"primary_connection_id,omitempty"`
+	Steps               []FallbackStep `json:"steps" validate:"required,min=1"`
+	Enabled             *bool          `json:"enabled,omitempty"`
+}
+
+// FallbackChainUpdateRequest represents a request to update a fallback chain
+type FallbackChainUpdateRequest struct {
+	Name                string         `json:"name,omitempty" validate:"omitempty,min=1,max=255"`
+	PrimaryConnectionID *uuid.UUID     `json:"primary_connection_id,omitempty"`
+	Steps               []FallbackStep `json:"steps,omitempty"`
+	Enabled             *bool          `json:"enabled,omitempty"`
+}
+
+// ConnectionPool represents a group of equivalent connections, such as
+// several keys for the same provider, that the router load balances across
+type ConnectionPool struct {
+	ID        uuid.UUID   `json:"id" db:"id"`
+	UserID    uuid.UUID   `json:"user_id" db:"user_id"`
+	Name      string      `json:"name" db:"name"`
+	Strategy  string      `json:"strategy" db:"strategy"` // round_robin, least_outstanding, ewma_latency, weighted_random, health_score
+	Members   PoolMembers `json:"members" db:"members"`
+	Enabled   bool        `json:"enabled" db:"enabled"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// PoolMember is one connection of a pool
+type PoolMember struct {
+	ConnectionID uuid.UUID `json:"connection_id"`
+	Weight       float64   `json:"weight,omitempty"` // Used by weighted_random; defaults to 1
+}
+
+// PoolMembers is the list of pool members stored as JSONB
+type PoolMembers []PoolMember
+
+// Value implements the driver.Valuer interface
+func (m PoolMembers) Value() (driver.Value, error) {
+	if m == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(m)
+}
+
+// Scan implements the sql.Scanner interface
+func (m *PoolMembers) Scan(value interface{}) error {
+	if value == nil {
+		*m = PoolMembers{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+
+	return json.Unmarshal(bytes, m)
+}
+
+// ConnectionPoolCreateRequest represents a request to create a connection pool
+type ConnectionPoolCreateRequest struct {
+	Name     string       `json:"name" validate:"required,min=1,max=255"`
+	Strategy string       `json:"strategy,omitempty"`
+	Members  []PoolMember `json:"members" validate:"required,min=1"`
+	Enabled  *bool        `json:"enabled,omitempty"`
+}
+
+// ConnectionPoolUpdateRequest represents a request to update a connection pool
+type ConnectionPoolUpdateRequest struct {
+	Name     string       `json:"name,omitempty" validate:"omitempty,min=1,max=255"`
+	Strategy *string      `json:"strategy,omitempty"`
+	Members  []PoolMember `json:"members,omitempty"`
+	Enabled  *bool        `json:"enabled,omitempty"`
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UsageRecord is one gateway call in the usage ledger
+type UsageRecord struct {
+	ID               uuid.UUID  `json:"id" db:"id"`
+	UserID           *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	ConnectionID     *uuid.UUID `json:"connection_id,omitempty" db:"connection_id"`
+	APIKeyID         *uuid.UUID `json:"api_key_id,omitempty" db:"api_key_id"`
+	Provider         string     `json:"provider" db:"provider"`
+	Model            string     `json:"model" db:"model"`
+	TaskType         string     `json:"task_type" db:"task_type"`
+	Stream           bool       `json:"stream" db:"stream"`
+	PromptTokens     int        `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens" db:"completion_tokens"`
+	Cost             float64    `json:"cost" db:"cost"`
+	LatencyMs        int64      `json:"latency_ms" db:"latency_ms"`
+	Success          bool       `json:"success" db:"success"`
+	Error            string     `json:"error,omitempty" db:"error"`
+	FallbackUsed     bool       `json:"fallback_used" db:"fallback_used"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+}
+
+// UsageQuery filters and groups usage aggregations
+type UsageQuery struct {
+	UserID       *uuid.UUID
+	ConnectionID *uuid.UUID
+	APIKeyID     *uuid.UUID
+	Model        string
+	From         time.Time
+	To           time.Time
+	GroupBy      []string // Any of user, day, model, provider, connection, api_key
+}
+
+// UsageAggregate holds the totals of one group of usage records. Only the
+// fields named in the query's GroupBy are set.
+type UsageAggregate struct {
+	UserID           *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	Day              *time.Time `json:"day,omitempty" db:"day"`
+	Model            *string    `json:"model,omitempty" db:"model"`
+	Provider         *string    `json:"provider,omitempty" db:"provider"`
+	ConnectionID     *uuid.UUID `json:"connection_id,omitempty" db:"connection_id"`
+	APIKeyID         *uuid.UUID `json:"api_key_id,omitempty" db:"api_key_id"`
+	Requests         int64      `json:"requests" db:"requests"`
+	Errors           int64      `json:"errors" db:"errors"`
+	ErrorRate        float64    `json:"error_rate" db:"error_rate"`
+	PromptTokens     int64      `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int64      `json:"completion_tokens" db:"completion_tokens"`
+	TotalTokens      int64      `json:"total_tokens" db:"total_tokens"`
+	Cost             float64    `json:"cost" db:"cost"`
+	LatencyP50Ms     float64    `json:"latency_p50_ms" db:"latency_p50_ms"`
+	LatencyP95Ms     float64    `json:"latency_p95_ms" db:"latency_p95_ms"`
+}
+
+// UsageReport is the response of the usage aggregation endpoints
+type UsageReport struct {
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	GroupBy []string         `json:"group_by"`
+	Totals  UsageAggregate   `json:"totals"`
+	Rows    []UsageAggregate `json:"rows"`
+}
+
+// ModelPrice is an entry of the editable pricing catalog
+type ModelPrice struct {
+	Model            string    `json:"model" db:"model"`
+	InputPerMillion  float64   `json:"input_per_million" db:"input_per_million"`
+	OutputPerMillion float64   `json:"output_per_million" db:"output_per_million"`
+	Custom           bool      `json:"custom" db:"-"` // Set by an admin rather than built in
+	UpdatedAt        time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
@@ -18,6 +18,11 @@ import (
 const (
 	anthropicAPIURL = "https://api.anthropic.com/v1/messages"
 	anthropicVersion = "2023-06-01"
+
+	// minThinkingBudget is the smallest thinking budget the API accepts
+	minThinkingBudget = 1024
+	// maxCacheBreakpoints is how many cache_control markers a request may carry
+	maxCacheBreakpoints = 4
 )
 
 // Provider implements the Anthropic provider
@@ -34,9 +39,21 @@ type AnthropicRequest struct {
 	MaxTokens   int                      `json:"max_tokens"`
 	Temperature *float32                 `json:"temperature,omitempty"`
 	Stream      bool                     `json:"stream,omitempty"`
-	System      string                   `json:"system,omitempty"`
+	System      []AnthropicContent       `json:"system,omitempty"`
 	Tools       []AnthropicTool          `json:"tools,omitempty"`
 	ToolChoice  *AnthropicToolChoice     `json:"tool_choice,omitempty"`
+	Thinking    *AnthropicThinking       `json:"thinking,omitempty"`
+}
+
+// AnthropicThinking enables extended thinking within a token budget
+type AnthropicThinking struct {
+	Type         string `json:"type"` // enabled
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// AnthropicCacheControl ends a cacheable prompt prefix
+type AnthropicCacheControl struct {
+	Type string `json:"type"` // ephemeral
 }
 
 // AnthropicMessage represents a message in Anthropic format
@@ -52,6 +69,21 @@ type AnthropicContent struct {
 	ID        string                 `json:"id,omitempty"`
 	Name      string                 `json:"name,omitempty"`
 	Input     json.RawMessage        `json:"input,omitempty"`
+	ToolUseID string                 `json:"tool_use_id,omitempty"`
+	Content   string                 `json:"content,omitempty"`
+	Source    *AnthropicImageSource  `json:"source,omitempty"`
+	Thinking  string                 `json:"thinking,omitempty"`
+	Signature string                 `json:"signature,omitempty"`
+	Data      string                 `json:"data,omitempty"` // Encrypted redacted_thinking
+	CacheControl *AnthropicCacheControl `json:"cache_control,omitempty"`
+}
+
+// AnthropicImageSource is the base64 data or URL of an image block
+type AnthropicImageSource struct {
+	Type      string `json:"type"` // base64 or url
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
 }
 
 // AnthropicTool represents a tool definition
@@ -59,6 +91,7 @@ type AnthropicTool struct {
 	Name        string                 `json:"name"`
 	Description string                 `json:"description"`
 	InputSchema map[string]interface{} `json:"input_schema"`
+	CacheControl *AnthropicCacheControl `json:"cache_control,omitempty"`
 }
 
 // AnthropicToolChoice represents tool selection
@@ -83,6 +116,9 @@ type AnthropicResponse struct {
 type AnthropicUsage struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
+	// Prompt tokens served from or written to the cache, not part of InputTokens
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
 }
 
 // AnthropicStreamEvent represents a streaming event
@@ -102,6 +138,8 @@ type AnthropicStreamDelta struct {
 	StopReason   string          `json:"stop_reason,omitempty"`
 	StopSequence string          `json:"stop_sequence,omitempty"`
 	PartialJSON  json.RawMessage `json:"partial_json,omitempty"`
+	Thinking     string          `json:"thinking,omitempty"`
+	Signature    string          `json:"signature,omitempty"`
 }
 
 // NewProvider creates a new Anthropic provider
@@ -147,7 +185,8 @@ func (p *Provider) Complete(ctx context.Context, req providers.CompletionRequest
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Anthropic API error: %s - %s", resp.Status, string(bodyBytes))
+		return nil, providers.NewHTTPError("anthropic", resp.StatusCode,
+			fmt.Errorf("Anthropic API error: %s - %s", resp.Status, string(bodyBytes)), resp.Header)
 	}
 
 	var anthropicResp AnthropicResponse
@@ -155,46 +194,51 @@ func (p *Provider) Complete(ctx context.Context, req providers.CompletionRequest
 		return nil, err
 	}
 
-	return p.convertResponse(&anthropicResp), nil
+	completion := p.convertResponse(&anthropicResp)
+	if name, _, ok := structuredTool(req.ResponseFormat); ok {
+		unwrapStructuredTool(completion, name)
+	}
+	return completion, nil
 }
 
 // StreamComplete performs a streaming completion
 func (p *Provider) StreamComplete(ctx context.Context, req providers.CompletionRequest) (<-chan providers.StreamChunk, error) {
-	chunks := make(chan providers.StreamChunk)
-
-	go func() {
-		defer close(chunks)
+	anthropicReq := p.convertRequest(req)
+	anthropicReq.Stream = true
+	_, _, structured := structuredTool(req.ResponseFormat)
 
-		anthropicReq := p.convertRequest(req)
-		anthropicReq.Stream = true
+	body, err := json.Marshal(anthropicReq)
+	if err != nil {
+		return nil, err
+	}
 
-		body, err := json.Marshal(anthropicReq)
-		if err != nil {
-			chunks <- providers.StreamChunk{Error: err.Error()}
-			return
-		}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", anthropicAPIURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
 
-		httpReq, err := http.NewRequestWithContext(ctx, "POST", anthropicAPIURL, bytes.NewReader(body))
-		if err != nil {
-			chunks <- providers.StreamChunk{Error: err.Error()}
-			return
-		}
+	p.setHeaders(httpReq)
 
-		p.setHeaders(httpReq)
+	// A rejected request fails the call itself, so it can be retried
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, providers.NewHTTPError("anthropic", resp.StatusCode,
+			fmt.Errorf("Anthropic API error: %s - %s", resp.Status, string(bodyBytes)), resp.Header)
+	}
 
-		resp, err := p.client.Do(httpReq)
-		if err != nil {
-			chunks <- providers.StreamChunk{Error: err.Error()}
-			return
-		}
+	chunks := make(chan providers.StreamChunk)
+	go func() {
+		defer close(chunks)
 		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			chunks <- providers.StreamChunk{Error: fmt.Sprintf("Anthropic API error: %s - %s", resp.Status, string(bodyBytes))}
-			return
-		}
-
+		// Input and cache usage arrive with message_start, output usage
+		// with message_delta
+		var usage AnthropicUsage
 		reader := bufio.NewReader(resp.Body)
 		for {
 			line, err := reader.ReadString('\n')
@@ -223,6 +267,20 @@ func (p *Provider) StreamComplete(ctx context.Context, req providers.CompletionR
 			}
 
 			chunk := p.convertStreamEvent(&event)
+			if structured {
+				chunk = convertStructuredEvent(&event, chunk)
+			}
+			switch {
+			case event.Type == "message_start" && event.Message != nil:
+				usage = event.Message.Usage
+			case event.Type == "message_delta" && event.Usage != nil:
+				usage.OutputTokens = event.Usage.OutputTokens
+				if chunk == nil {
+					chunk = &providers.StreamChunk{}
+				}
+				total := convertUsage(usage)
+				chunk.Usage = &total
+			}
 			if chunk != nil {
 				chunks <- *chunk
 			}
@@ -236,6 +294,9 @@ func (p *Provider) StreamComplete(ctx context.Context, req providers.CompletionR
 func (p *Provider) GetModels(ctx context.Context) ([]providers.Model, error) {
 	// Anthropic doesn't have a models endpoint, so we return hardcoded models
 	models := []providers.Model{
+		{ID: "claude-opus-4-20250514", Object: "model", OwnedBy: "anthropic"},
+		{ID: "claude-sonnet-4-20250514", Object: "model", OwnedBy: "anthropic"},
+		{ID: "claude-3-7-sonnet-20250219", Object: "model", OwnedBy: "anthropic"},
 		{ID: "claude-3-opus-20240229", Object: "model", OwnedBy: "anthropic"},
 		{ID: "claude-3-sonnet-20240229", Object: "model", OwnedBy: "anthropic"},
 		{ID: "claude-3-haiku-20240307", Object: "model", OwnedBy: "anthropic"},
@@ -272,26 +333,75 @@ func (p *Provider) convertRequest(req providers.CompletionRequest) AnthropicRequ
 		anthropicReq.MaxTokens = *req.MaxTokens
 	}
 
+	// Thinking cannot be combined with a forced tool, which structured
+	// output relies on
+	_, _, structured := structuredTool(req.ResponseFormat)
+	forcedTool := structured || (req.ToolChoice != nil && req.ToolChoice.Type == "function")
+	if req.Reasoning != nil && req.Reasoning.BudgetTokens > 0 && !forcedTool {
+		budget := req.Reasoning.BudgetTokens
+		if budget < minThinkingBudget {
+			budget = minThinkingBudget
+		}
+		anthropicReq.Thinking = &AnthropicThinking{Type: "enabled", BudgetTokens: budget}
+		// The budget counts towards max_tokens, so leave room for the answer
+		if anthropicReq.MaxTokens <= budget {
+			anthropicReq.MaxTokens += budget
+		}
+		anthropicReq.Temperature = nil // Thinking requires the default temperature
+	}
+
 	// Convert messages
 	anthropicMessages := []AnthropicMessage{}
 	var systemMessage string
+	var cacheable []int // Messages that end a cacheable prefix
 
 	for _, msg := range req.Messages {
+		// Anthropic takes a single system prompt, so join them all
 		if msg.Role == "system" {
-			systemMessage = msg.Content
+			if systemMessage != "" && msg.Content != "" {
+				systemMessage += "\n\n"
+			}
+			systemMessage += msg.Content
 			continue
 		}
 
 		content := []AnthropicContent{{Type: "text", Text: msg.Content}}
+		if len(msg.ContentArray) > 0 {
+			content = convertContent(msg)
+		}
 		
 		// Handle tool calls in assistant messages
 		if msg.Role == "assistant" && len(msg.ToolCalls) > 0 {
+			// Anthropic rejects empty text blocks
+			if msg.Content == "" {
+				content = []AnthropicContent{}
+			}
+			// With thinking on, a tool-use turn must replay the thinking
+			// that led to it, redacted blocks included
+			if anthropicReq.Thinking != nil {
+				var thinking []AnthropicContent
+				if msg.ReasoningSignature != "" {
+					thinking = append(thinking, AnthropicContent{
+						Type:      "thinking",
+						Thinking:  msg.Reasoning,
+						Signature: msg.ReasoningSignature,
+					})
+				}
+				for _, data := range msg.RedactedReasoning {
+					thinking = append(thinking, AnthropicContent{Type: "redacted_thinking", Data: data})
+				}
+				content = append(thinking, content...)
+			}
 			for _, tc := range msg.ToolCalls {
+				input := tc.Function.Arguments
+				if input == "" {
+					input = "{}"
+				}
 				content = append(content, AnthropicContent{
 					Type: "tool_use",
 					ID:   tc.ID,
 					Name: tc.Function.Name,
-					Input: json.RawMessage(tc.Function.Arguments),
+					Input: json.RawMessage(input),
 				})
 			}
 		}
@@ -299,13 +409,16 @@ func (p *Provider) convertRequest(req providers.CompletionRequest) AnthropicRequ
 		// Handle tool responses
 		if msg.Role == "tool" && msg.ToolCallID != "" {
 			content = []AnthropicContent{{
-				Type:  "tool_result",
-				ID:    msg.ToolCallID,
-				Input: json.RawMessage(msg.Content),
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   msg.Content,
 			}}
 			msg.Role = "user" // Anthropic expects tool results as user messages
 		}
 
+		if msg.Cacheable && len(content) > 0 {
+			cacheable = append(cacheable, len(anthropicMessages))
+		}
 		anthropicMessages = append(anthropicMessages, AnthropicMessage{
 			Role:    msg.Role,
 			Content: content,
@@ -314,7 +427,7 @@ func (p *Provider) convertRequest(req providers.CompletionRequest) AnthropicRequ
 
 	anthropicReq.Messages = anthropicMessages
 	if systemMessage != "" {
-		anthropicReq.System = systemMessage
+		anthropicReq.System = []AnthropicContent{{Type: "text", Text: systemMessage}}
 	}
 
 	// Convert tools
@@ -340,6 +453,8 @@ func (p *Provider) convertRequest(req providers.CompletionRequest) AnthropicRequ
 		}
 	}
 
+	applyCacheControl(&anthropicReq, req.PromptCache, cacheable)
+
 	// Convert tool choice
 	if req.ToolChoice != nil {
 		switch req.ToolChoice.Type {
@@ -357,9 +472,125 @@ func (p *Provider) convertRequest(req providers.CompletionRequest) AnthropicRequ
 		}
 	}
 
+	// Anthropic has no JSON mode; structured output is a forced call to a
+	// tool whose input schema is the requested schema
+	if name, schema, ok := structuredTool(req.ResponseFormat); ok {
+		description := "Respond with the requested JSON"
+		if req.ResponseFormat.JSONSchema != nil && req.ResponseFormat.JSONSchema.Description != "" {
+			description = req.ResponseFormat.JSONSchema.Description
+		}
+		anthropicReq.Tools = append(anthropicReq.Tools, AnthropicTool{
+			Name:        name,
+			Description: description,
+			InputSchema: schema,
+		})
+		anthropicReq.ToolChoice = &AnthropicToolChoice{Type: "tool", Name: name}
+	}
+
 	return anthropicReq
 }
 
+// applyCacheControl marks the requested prompt prefixes as cacheable. The
+// cache covers everything up to a marker, in the order tools, system,
+// messages; markers on the latest messages win when there are too many.
+func applyCacheControl(anthropicReq *AnthropicRequest, cache *providers.PromptCache, cacheable []int) {
+	ephemeral := &AnthropicCacheControl{Type: "ephemeral"}
+	breakpoints := maxCacheBreakpoints
+
+	if cache != nil && cache.Tools && len(anthropicReq.Tools) > 0 {
+		anthropicReq.Tools[len(anthropicReq.Tools)-1].CacheControl = ephemeral
+		breakpoints--
+	}
+	if cache != nil && cache.System && len(anthropicReq.System) > 0 {
+		anthropicReq.System[len(anthropicReq.System)-1].CacheControl = ephemeral
+		breakpoints--
+	}
+	for i := len(cacheable) - 1; i >= 0 && breakpoints > 0; i-- {
+		content := anthropicReq.Messages[cacheable[i]].Content
+		content[len(content)-1].CacheControl = ephemeral
+		breakpoints--
+	}
+}
+
+// convertContent converts the text and images of a multimodal message to
+// content blocks
+func convertContent(msg providers.Message) []AnthropicContent {
+	var content []AnthropicContent
+	for _, part := range msg.Parts() {
+		switch {
+		case !part.IsImage():
+			content = append(content, AnthropicContent{Type: "text", Text: part.Text})
+		case part.ImageURL != "":
+			content = append(content, AnthropicContent{Type: "image", Source: &AnthropicImageSource{
+				Type: "url",
+				URL:  part.ImageURL,
+			}})
+		default:
+			content = append(content, AnthropicContent{Type: "image", Source: &AnthropicImageSource{
+				Type:      "base64",
+				MediaType: part.MediaType,
+				Data:      part.Data,
+			}})
+		}
+	}
+	return content
+}
+
+// structuredTool returns the tool that carries a JSON response format
+func structuredTool(format *providers.ResponseFormat) (name string, schema map[string]interface{}, ok bool) {
+	if format == nil || (format.Type != "json_object" && format.Type != "json_schema") {
+		return "", nil, false
+	}
+	name, schema = "response", map[string]interface{}{"type": "object"}
+	if format.JSONSchema != nil {
+		if format.JSONSchema.Name != "" {
+			name = format.JSONSchema.Name
+		}
+		if len(format.JSONSchema.Schema) > 0 {
+			schema = format.JSONSchema.Schema
+		}
+	}
+	return name, schema, true
+}
+
+// unwrapStructuredTool turns the forced structured-output tool call into the
+// message content
+func unwrapStructuredTool(resp *providers.CompletionResponse, name string) {
+	for i := range resp.Choices {
+		msg := &resp.Choices[i].Message
+		for j, call := range msg.ToolCalls {
+			if call.Function.Name != name {
+				continue
+			}
+			msg.Content = call.Function.Arguments
+			msg.ToolCalls = append(msg.ToolCalls[:j], msg.ToolCalls[j+1:]...)
+			if len(msg.ToolCalls) == 0 {
+				msg.ToolCalls = nil
+			}
+			resp.Choices[i].FinishReason = "stop"
+			break
+		}
+	}
+}
+
+// convertStructuredEvent streams the input of the forced structured-output
+// tool call as content
+func convertStructuredEvent(event *AnthropicStreamEvent, chunk *providers.StreamChunk) *providers.StreamChunk {
+	switch {
+	case event.Type == "content_block_start" && event.ContentBlock != nil && event.ContentBlock.Type == "tool_use":
+		return nil
+	case event.Type == "content_block_delta" && event.Delta != nil && event.Delta.Type == "input_json_delta":
+		var partial string
+		if err := json.Unmarshal(event.Delta.PartialJSON, &partial); err != nil {
+			partial = string(event.Delta.PartialJSON)
+		}
+		return &providers.StreamChunk{Delta: partial}
+	case chunk != nil && chunk.FinishReason == "tool_calls":
+		chunk.FinishReason = "stop"
+	}
+	return chunk
+}
+
 // convertResponse converts Anthropic response to internal response
 func (p *Provider) convertResponse(resp *AnthropicResponse) *providers.CompletionResponse {
 	message := providers.Message{
@@ -372,6 +603,11 @@ func (p *Provider) convertResponse(resp *AnthropicResponse) *providers.Completio
 		switch content.Type {
 		case "text":
 			textContent.WriteString(content.Text)
+		case "thinking":
+			message.Reasoning += content.Thinking
+			message.ReasoningSignature = content.Signature
+		case "redacted_thinking":
+			message.RedactedReasoning = append(message.RedactedReasoning, content.Data)
 		case "tool_use":
 			if message.ToolCalls == nil {
 				message.ToolCalls = []providers.ToolCall{}
@@ -401,11 +637,20 @@ func (p *Provider) convertResponse(resp *AnthropicResponse) *providers.Completio
 				FinishReason: p.convertStopReason(resp.StopReason),
 			},
 		},
-		Usage: providers.Usage{
-			PromptTokens:     resp.Usage.InputTokens,
-			CompletionTokens: resp.Usage.OutputTokens,
-			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
-		},
+		Usage: convertUsage(resp.Usage),
+	}
+}
+
+// convertUsage converts token usage; cached prompt tokens count towards the
+// prompt like any other
+func convertUsage(usage AnthropicUsage) providers.Usage {
+	prompt := usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens
+	return providers.Usage{
+		PromptTokens:        prompt,
+		CompletionTokens:    usage.OutputTokens,
+		TotalTokens:         prompt + usage.OutputTokens,
+		CacheReadTokens:     usage.CacheReadInputTokens,
+		CacheCreationTokens: usage.CacheCreationInputTokens,
 	}
 }
 
@@ -423,7 +668,11 @@ func (p *Provider) convertStreamEvent(event *AnthropicStreamEvent) *providers.St
 		}
 
 	case "content_block_start":
+		if event.ContentBlock != nil && event.ContentBlock.Type == "redacted_thinking" {
+			return &providers.StreamChunk{RedactedReasoning: []string{event.ContentBlock.Data}}
+		}
 		if event.ContentBlock != nil && event.ContentBlock.Type == "tool_use" {
+			index := event.Index
 			return &providers.StreamChunk{
 				ToolCalls: []providers.ToolCall{
 					{
@@ -432,6 +681,7 @@ func (p *Provider) convertStreamEvent(event *AnthropicStreamEvent) *providers.St
 						Function: providers.FunctionCall{
 							Name: event.ContentBlock.Name,
 						},
+						Index: &index,
 					},
 				},
 			}
@@ -443,14 +693,29 @@ func (p *Provider) convertStreamEvent(event *AnthropicStreamEvent) *providers.St
 				return &providers.StreamChunk{
 					Delta: event.Delta.Text,
 				}
+			} else if event.Delta.Type == "thinking_delta" && event.Delta.Thinking != "" {
+				return &providers.StreamChunk{
+					Reasoning: event.Delta.Thinking,
+				}
+			} else if event.Delta.Type == "signature_delta" && event.Delta.Signature != "" {
+				return &providers.StreamChunk{
+					ReasoningSignature: event.Delta.Signature,
+				}
 			} else if event.Delta.Type == "input_json_delta" && len(event.Delta.PartialJSON) > 0 {
-				// Handle partial JSON for tool calls
+				// Handle partial JSON for tool calls; the fragment arrives
+				// as a JSON string, keyed by its content block
+				var partial string
+				if err := json.Unmarshal(event.Delta.PartialJSON, &partial); err != nil {
+					partial = string(event.Delta.PartialJSON)
+				}
+				index := event.Index
 				return &providers.StreamChunk{
 					ToolCalls: []providers.ToolCall{
 						{
 							Function: providers.FunctionCall{
-								Arguments: string(event.Delta.PartialJSON),
+								Arguments: partial,
 							},
+							Index: &index,
 						},
 					},
 				}
@@ -0,0 +1,138 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/agentx/agentx-backend/internal/config"
+	"github.com/agentx/agentx-backend/internal/providers"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProvider(t *testing.T) *Provider {
+	p, err := NewProvider("anthropic", config.ProviderConfig{Type: "anthropic", Name: "anthropic", APIKey: "test"})
+	assert.NoError(t, err)
+	return p
+}
+
+func TestConvertRequestEnablesThinkingAndReplaysIt(t *testing.T) {
+	p := newTestProvider(t)
+	maxTokens := 1000
+	temperature := float32(0.3)
+
+	req := p.convertRequest(providers.CompletionRequest{
+		Model:       "claude-sonnet-4-20250514",
+		MaxTokens:   &maxTokens,
+		Temperature: &temperature,
+		Reasoning:   &providers.Reasoning{BudgetTokens: 500},
+		Messages: []providers.Message{
+			{Role: "user", Content: "Weather in Paris?"},
+			{Role: "assistant", Reasoning: "I should look it up.", ReasoningSignature: "sig", RedactedReasoning: []string{"EncryptedThinking"}, ToolCalls: []providers.ToolCall{{
+				ID: "toolu_1", Type: "function",
+				Function: providers.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`},
+			}}},
+			{Role: "tool", ToolCallID: "toolu_1", Content: "sunny"},
+		},
+	})
+
+	if assert.NotNil(t, req.Thinking) {
+		assert.Equal(t, minThinkingBudget, req.Thinking.BudgetTokens, "budgets are raised to the minimum")
+	}
+	assert.Equal(t, 1000+minThinkingBudget, req.MaxTokens, "the answer keeps its own room")
+	assert.Nil(t, req.Temperature)
+
+	blocks := req.Messages[1].Content
+	if assert.Len(t, blocks, 3) {
+		assert.Equal(t, AnthropicContent{Type: "thinking", Thinking: "I should look it up.", Signature: "sig"}, blocks[0])
+		assert.Equal(t, AnthropicContent{Type: "redacted_thinking", Data: "EncryptedThinking"}, blocks[1])
+		assert.Equal(t, "tool_use", blocks[2].Type)
+	}
+}
+
+func TestConvertRequestSkipsThinkingForStructuredOutput(t *testing.T) {
+	p := newTestProvider(t)
+	req := p.convertRequest(providers.CompletionRequest{
+		Model:          "claude-sonnet-4-20250514",
+		Reasoning:      &providers.Reasoning{BudgetTokens: 2048},
+		ResponseFormat: &providers.ResponseFormat{Type: "json_object"},
+		Messages:       []providers.Message{{Role: "user", Content: "List three colors"}},
+	})
+	assert.Nil(t, req.Thinking)
+}
+
+func TestConvertRequestMarksCacheablePrefixes(t *testing.T) {
+	p := newTestProvider(t)
+	tool := providers.Tool{Type: "function", Function: providers.Function{Name: "search"}}
+
+	var messages []providers.Message
+	messages = append(messages, providers.Message{Role: "system", Content: "You are terse."})
+	for i := 0; i < 4; i++ {
+		messages = append(messages, providers.Message{Role: "user", Content: "document", Cacheable: true})
+	}
+
+	req := p.convertRequest(providers.CompletionRequest{
+		Model:       "claude-sonnet-4-20250514",
+		Messages:    messages,
+		Tools:       []providers.Tool{tool, tool},
+		PromptCache: &providers.PromptCache{System: true, Tools: true},
+	})
+
+	ephemeral := &AnthropicCacheControl{Type: "ephemeral"}
+	assert.Nil(t, req.Tools[0].CacheControl)
+	assert.Equal(t, ephemeral, req.Tools[1].CacheControl)
+	assert.Equal(t, ephemeral, req.System[0].CacheControl)
+
+	var marked []int
+	for i, msg := range req.Messages {
+		if msg.Content[len(msg.Content)-1].CacheControl != nil {
+			marked = append(marked, i)
+		}
+	}
+	assert.Equal(t, []int{2, 3}, marked, "the latest messages get the remaining breakpoints")
+}
+
+func TestConvertResponseSeparatesThinkingAndCacheUsage(t *testing.T) {
+	p := newTestProvider(t)
+	var resp AnthropicResponse
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"id": "msg_1", "model": "claude-sonnet-4-20250514", "stop_reason": "end_turn",
+		"content": [
+			{"type": "thinking", "thinking": "Two plus two.", "signature": "sig"},
+			{"type": "redacted_thinking", "data": "EncryptedThinking"},
+			{"type": "text", "text": "4"}
+		],
+		"usage": {"input_tokens": 10, "output_tokens": 5, "cache_read_input_tokens": 1000, "cache_creation_input_tokens": 200}
+	}`), &resp))
+
+	converted := p.convertResponse(&resp)
+	msg := converted.Choices[0].Message
+	assert.Equal(t, "4", msg.Content)
+	assert.Equal(t, "Two plus two.", msg.Reasoning)
+	assert.Equal(t, "sig", msg.ReasoningSignature)
+	assert.Equal(t, []string{"EncryptedThinking"}, msg.RedactedReasoning)
+	assert.Equal(t, providers.Usage{
+		PromptTokens:        1210,
+		CompletionTokens:    5,
+		TotalTokens:         1215,
+		CacheReadTokens:     1000,
+		CacheCreationTokens: 200,
+	}, converted.Usage)
+}
+
+func TestConvertRequestJoinsSystemMessages(t *testing.T) {
+	p := newTestProvider(t)
+
+	req := p.convertRequest(providers.CompletionRequest{
+		Model: "claude-sonnet-4-20250514",
+		Messages: []providers.Message{
+			{Role: "system", Content: "You are helpful."},
+			{Role: "system", Content: "Summary of the earlier conversation:\nthey discussed q and a"},
+			{Role: "user", Content: "latest question"},
+		},
+	})
+
+	if assert.Len(t, req.System, 1) {
+		assert.Equal(t, "You are helpful.\n\nSummary of the earlier conversation:\nthey discussed q and a", req.System[0].Text)
+	}
+	assert.Len(t, req.Messages, 1)
+}
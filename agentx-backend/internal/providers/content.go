@@ -0,0 +1,146 @@
+package providers
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ContentPart is a text or image part of a multimodal message
+type ContentPart struct {
+	Type      string // "text" or "image"
+	Text      string
+	ImageURL  string // Remote image URL; empty for inline images
+	MediaType string // MIME type of inline images
+	Data      string // Base64 data of inline images
+}
+
+// IsImage reports whether the part is an image
+func (p ContentPart) IsImage() bool {
+	return p.Type == "image"
+}
+
+// URL returns the image as a URL, encoding inline images as data URLs
+func (p ContentPart) URL() string {
+	if p.ImageURL != "" {
+		return p.ImageURL
+	}
+	return "data:" + p.MediaType + ";base64," + p.Data
+}
+
+// TextContent returns a content array item for text
+func TextContent(text string) map[string]interface{} {
+	return map[string]interface{}{"type": "text", "text": text}
+}
+
+// ImageContent returns a content array item for an image URL or data URL,
+// in the OpenAI image_url shape every provider accepts
+func ImageContent(url string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":      "image_url",
+		"image_url": map[string]interface{}{"url": url},
+	}
+}
+
+// ImagePart parses an image URL into a part; data URLs are split into
+// their media type and base64 data
+func ImagePart(url string) (ContentPart, bool) {
+	if url == "" {
+		return ContentPart{}, false
+	}
+	if !strings.HasPrefix(url, "data:") {
+		return ContentPart{Type: "image", ImageURL: url}, true
+	}
+	meta, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") || data == "" {
+		return ContentPart{}, false
+	}
+	return ContentPart{
+		Type:      "image",
+		MediaType: strings.TrimSuffix(meta, ";base64"),
+		Data:      data,
+	}, true
+}
+
+// Parts returns a message's text and images in order: Content first, then
+// the content array. Array items may be in OpenAI (text, image_url) or
+// Anthropic (text, image) shape.
+func (m Message) Parts() []ContentPart {
+	var parts []ContentPart
+	if m.Content != "" {
+		parts = append(parts, ContentPart{Type: "text", Text: m.Content})
+	}
+
+	for _, item := range m.ContentArray {
+		var block struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			ImageURL struct {
+				URL string `json:"url"`
+			} `json:"image_url"`
+			Source struct {
+				Type      string `json:"type"`
+				MediaType string `json:"media_type"`
+				Data      string `json:"data"`
+				URL       string `json:"url"`
+			} `json:"source"`
+		}
+		data, err := json.Marshal(item)
+		if err != nil || json.Unmarshal(data, &block) != nil {
+			continue
+		}
+
+		switch block.Type {
+		case "text":
+			if block.Text != "" {
+				parts = append(parts, ContentPart{Type: "text", Text: block.Text})
+			}
+		case "image":
+			if block.Source.URL != "" {
+				parts = append(parts, ContentPart{Type: "image", ImageURL: block.Source.URL})
+			} else if block.Source.Data != "" {
+				parts = append(parts, ContentPart{
+					Type:      "image",
+					MediaType: block.Source.MediaType,
+					Data:      block.Source.Data,
+				})
+			}
+		case "image_url":
+			if part, ok := ImagePart(block.ImageURL.URL); ok {
+				parts = append(parts, part)
+			}
+		}
+	}
+	return parts
+}
+
+// HasImages reports whether the message carries any images
+func (m Message) HasImages() bool {
+	for _, part := range m.Parts() {
+		if part.IsImage() {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenAIParts converts the text and images of a multimodal message to the
+// content parts of OpenAI-compatible chat APIs
+func (m Message) OpenAIParts() []openai.ChatMessagePart {
+	var parts []openai.ChatMessagePart
+	for _, part := range m.Parts() {
+		if !part.IsImage() {
+			parts = append(parts, openai.ChatMessagePart{
+				Type: openai.ChatMessagePartTypeText,
+				Text: part.Text,
+			})
+			continue
+		}
+		parts = append(parts, openai.ChatMessagePart{
+			Type:     openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{URL: part.URL()},
+		})
+	}
+	return parts
+}
@@ -0,0 +1,146 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ProviderError describes a failed upstream API call
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	RetryAfter time.Duration // Zero when the provider gave no hint
+	Err        error
+}
+
+// NewHTTPError wraps an upstream error with its HTTP status and rate-limit hints
+func NewHTTPError(provider string, statusCode int, err error, header http.Header) *ProviderError {
+	return &ProviderError{
+		Provider:   provider,
+		StatusCode: statusCode,
+		RetryAfter: ParseRetryAfter(statusCode, header, time.Now()),
+		Err:        err,
+	}
+}
+
+func (e *ProviderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Status returns the HTTP status code of the failed call
+func (e *ProviderError) Status() int {
+	return e.StatusCode
+}
+
+// RetryDelay returns how long the provider asked callers to wait
+func (e *ProviderError) RetryDelay() time.Duration {
+	return e.RetryAfter
+}
+
+// ParseRetryAfter extracts the wait time from Retry-After and, for 429
+// responses, provider rate-limit reset headers. Providers send the reset
+// headers with every response, so other failures keep their own backoff.
+func ParseRetryAfter(statusCode int, header http.Header, now time.Time) time.Duration {
+	if header == nil {
+		return 0
+	}
+
+	if v := header.Get("retry-after-ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+
+	if v := header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+			return time.Duration(secs * float64(time.Second))
+		}
+		if t, err := http.ParseTime(v); err == nil && t.After(now) {
+			return t.Sub(now)
+		}
+	}
+
+	if statusCode != http.StatusTooManyRequests {
+		return 0
+	}
+
+	// OpenAI reports resets as durations, e.g. "1s" or "6m0s"
+	for _, key := range []string{"x-ratelimit-reset-requests", "x-ratelimit-reset-tokens"} {
+		if v := header.Get(key); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				return d
+			}
+		}
+	}
+
+	// Anthropic reports resets as RFC 3339 timestamps
+	for _, key := range []string{"anthropic-ratelimit-requests-reset", "anthropic-ratelimit-tokens-reset"} {
+		if v := header.Get(key); v != "" {
+			if t, err := time.Parse(time.RFC3339, v); err == nil && t.After(now) {
+				return t.Sub(now)
+			}
+		}
+	}
+
+	return 0
+}
+
+// HeaderSink records the headers of the last upstream response
+type HeaderSink struct {
+	mu     sync.Mutex
+	header http.Header
+}
+
+// Header returns the captured headers, or nil if none were recorded
+func (s *HeaderSink) Header() http.Header {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.header
+}
+
+func (s *HeaderSink) set(header http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.header = header.Clone()
+}
+
+type headerSinkKey struct{}
+
+// WithHeaderSink returns a context whose upstream response headers are
+// captured by HeaderCaptureTransport
+func WithHeaderSink(ctx context.Context) (context.Context, *HeaderSink) {
+	sink := &HeaderSink{}
+	return context.WithValue(ctx, headerSinkKey{}, sink), sink
+}
+
+// HeaderCaptureTransport stores response headers in the request's HeaderSink
+// so SDK clients that hide them can still surface rate-limit hints
+type HeaderCaptureTransport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *HeaderCaptureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err == nil {
+		if sink, ok := req.Context().Value(headerSinkKey{}).(*HeaderSink); ok {
+			sink.set(resp.Header)
+		}
+	}
+	return resp, err
+}
@@ -0,0 +1,25 @@
+package providers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRetryAfterHonorsResetHeadersOnlyWhenRateLimited(t *testing.T) {
+	now := time.Now()
+	header := http.Header{}
+	header.Set("x-ratelimit-reset-requests", "20s")
+	header.Set("anthropic-ratelimit-tokens-reset", now.Add(time.Minute).Format(time.RFC3339))
+
+	assert.Equal(t, 20*time.Second, ParseRetryAfter(http.StatusTooManyRequests, header, now))
+	assert.Zero(t, ParseRetryAfter(http.StatusServiceUnavailable, header, now), "server errors keep their backoff")
+
+	header.Set("Retry-After", "3")
+	assert.Equal(t, 3*time.Second, ParseRetryAfter(http.StatusServiceUnavailable, header, now))
+
+	header.Set("retry-after-ms", "1500")
+	assert.Equal(t, 1500*time.Millisecond, ParseRetryAfter(http.StatusBadGateway, header, now))
+}
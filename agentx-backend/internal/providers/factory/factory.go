@@ -6,7 +6,9 @@ import (
 	"github.com/agentx/agentx-backend/internal/config"
 	"github.com/agentx/agentx-backend/internal/providers"
 	"github.com/agentx/agentx-backend/internal/providers/anthropic"
+	"github.com/agentx/agentx-backend/internal/providers/gemini"
 	"github.com/agentx/agentx-backend/internal/providers/local"
+	"github.com/agentx/agentx-backend/internal/providers/ollama"
 	"github.com/agentx/agentx-backend/internal/providers/openai"
 )
 
@@ -15,13 +17,16 @@ func CreateProvider(id string, cfg config.ProviderConfig) (providers.Provider, e
 	switch cfg.Type {
 	case "openai":
 		return openai.NewProvider(id, cfg)
+	case "azure-openai":
+		return openai.NewAzureProvider(id, cfg)
 	case "anthropic":
 		return anthropic.NewProvider(id, cfg)
-	case "openai-compatible":
+	case "gemini":
+		return gemini.NewProvider(id, cfg)
+	case "openai-compatible", "local":
 		return local.NewOpenAICompatibleProvider(id, cfg)
 	case "ollama":
-		// Ollama is OpenAI-compatible
-		return local.NewOpenAICompatibleProvider(id, cfg)
+		return ollama.NewProvider(id, cfg)
 	default:
 		return nil, fmt.Errorf("unknown provider type: %s", cfg.Type)
 	}
@@ -0,0 +1,766 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/config"
+	"github.com/agentx/agentx-backend/internal/providers"
+)
+
+const (
+	// DefaultBaseURL is the Gemini API endpoint; config.BaseURL overrides it
+	DefaultBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+	// DefaultModel is used when a request names no model
+	DefaultModel = "gemini-1.5-flash"
+
+	// DefaultEmbeddingModel is used when an embedding request names no model
+	DefaultEmbeddingModel = "text-embedding-004"
+)
+
+// Provider implements the Google Gemini provider
+type Provider struct {
+	id      string
+	config  config.ProviderConfig
+	client  *http.Client
+	baseURL string
+}
+
+// GeminiRequest represents a generateContent request
+type GeminiRequest struct {
+	Contents          []GeminiContent         `json:"contents"`
+	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
+	Tools             []GeminiTool            `json:"tools,omitempty"`
+	ToolConfig        *GeminiToolConfig       `json:"toolConfig,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// GeminiContent is one turn of a conversation
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"` // user or model
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart is one piece of a turn; exactly one field is set
+type GeminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *GeminiBlob             `json:"inlineData,omitempty"`
+	FileData         *GeminiFileData         `json:"fileData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// GeminiBlob is inline binary data such as an image
+type GeminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // Base64
+}
+
+// GeminiFileData references media by URI
+type GeminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+// GeminiFunctionCall is a tool call made by the model
+type GeminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+// GeminiFunctionResponse returns a tool result to the model
+type GeminiFunctionResponse struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+// GeminiTool declares the functions the model may call
+type GeminiTool struct {
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+// GeminiFunctionDeclaration describes one callable function
+type GeminiFunctionDeclaration struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// GeminiToolConfig controls whether and which functions are called
+type GeminiToolConfig struct {
+	FunctionCallingConfig GeminiFunctionCallingConfig `json:"functionCallingConfig"`
+}
+
+// GeminiFunctionCallingConfig is the function calling mode
+type GeminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode"` // AUTO, ANY or NONE
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+// GeminiGenerationConfig holds sampling parameters
+type GeminiGenerationConfig struct {
+	Temperature      *float32 `json:"temperature,omitempty"`
+	MaxOutputTokens  *int     `json:"maxOutputTokens,omitempty"`
+	ResponseMimeType string   `json:"responseMimeType,omitempty"`
+}
+
+// GeminiResponse represents a generateContent response, and each event of a
+// streamed one
+type GeminiResponse struct {
+	Candidates    []GeminiCandidate `json:"candidates"`
+	UsageMetadata *GeminiUsage      `json:"usageMetadata,omitempty"`
+	ModelVersion  string            `json:"modelVersion,omitempty"`
+	ResponseID    string            `json:"responseId,omitempty"`
+}
+
+// GeminiCandidate is one generated answer
+type GeminiCandidate struct {
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+	Index        int           `json:"index"`
+}
+
+// GeminiUsage represents token usage
+type GeminiUsage struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// GeminiError is the error body of the Gemini API
+type GeminiError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// NewProvider creates a new Gemini provider
+func NewProvider(id string, cfg config.ProviderConfig) (*Provider, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("Gemini API key is required")
+	}
+
+	baseURL := DefaultBaseURL
+	if cfg.BaseURL != "" {
+		baseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+
+	return &Provider{
+		id:      id,
+		config:  cfg,
+		client:  &http.Client{},
+		baseURL: baseURL,
+	}, nil
+}
+
+// Name returns the provider name
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// Complete performs a non-streaming completion
+func (p *Provider) Complete(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+	model := p.model(req.Model)
+	resp, err := p.post(ctx, p.modelURL(model, "generateContent"), p.convertRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var geminiResp GeminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
+		return nil, err
+	}
+
+	return p.convertResponse(&geminiResp, model), nil
+}
+
+// StreamComplete performs a streaming completion
+func (p *Provider) StreamComplete(ctx context.Context, req providers.CompletionRequest) (<-chan providers.StreamChunk, error) {
+	model := p.model(req.Model)
+	resp, err := p.post(ctx, p.modelURL(model, "streamGenerateContent")+"?alt=sse", p.convertRequest(req))
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan providers.StreamChunk)
+	go func() {
+		defer close(chunks)
+		defer resp.Body.Close()
+
+		chunks <- providers.StreamChunk{
+			Object: "chat.completion.chunk",
+			Model:  model,
+			Role:   "assistant",
+		}
+
+		finishReason := ""
+		toolCalls := 0
+		reader := bufio.NewReader(resp.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil && err != io.EOF {
+				chunks <- providers.StreamChunk{Error: err.Error()}
+				return
+			}
+
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "data: ") {
+				var event GeminiResponse
+				if jsonErr := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); jsonErr == nil {
+					for _, chunk := range p.convertStreamEvent(&event, &toolCalls) {
+						chunks <- chunk
+					}
+					if len(event.Candidates) > 0 && event.Candidates[0].FinishReason != "" {
+						finishReason = convertFinishReason(event.Candidates[0].FinishReason)
+					}
+				}
+			}
+
+			if err == io.EOF {
+				break
+			}
+		}
+
+		if toolCalls > 0 && finishReason == "stop" {
+			finishReason = "tool_calls"
+		}
+		if finishReason == "" {
+			finishReason = "stop"
+		}
+		chunks <- providers.StreamChunk{FinishReason: finishReason}
+	}()
+
+	return chunks, nil
+}
+
+// GetModels returns the models that can generate content or embeddings
+func (p *Provider) GetModels(ctx context.Context) ([]providers.Model, error) {
+	var models []providers.Model
+	pageToken := ""
+	for {
+		endpoint := p.baseURL + "/models?pageSize=1000"
+		if pageToken != "" {
+			endpoint += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		p.setHeaders(httpReq)
+
+		resp, err := p.client.Do(httpReq)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := apiError(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var page struct {
+			Models []struct {
+				Name                       string   `json:"name"`
+				DisplayName                string   `json:"displayName"`
+				Description                string   `json:"description"`
+				InputTokenLimit            int      `json:"inputTokenLimit"`
+				OutputTokenLimit           int      `json:"outputTokenLimit"`
+				SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+			} `json:"models"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range page.Models {
+			if !supportsAny(m.SupportedGenerationMethods, "generateContent", "embedContent") {
+				continue
+			}
+			models = append(models, providers.Model{
+				ID:      strings.TrimPrefix(m.Name, "models/"),
+				Object:  "model",
+				OwnedBy: "google",
+				Extra: map[string]interface{}{
+					"display_name":                 m.DisplayName,
+					"description":                  m.Description,
+					"input_token_limit":            m.InputTokenLimit,
+					"output_token_limit":           m.OutputTokenLimit,
+					"supported_generation_methods": m.SupportedGenerationMethods,
+				},
+			})
+		}
+
+		if page.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// Embed embeds a batch of texts with batchEmbedContents
+func (p *Provider) Embed(ctx context.Context, req providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = DefaultEmbeddingModel
+	}
+
+	type embedContentRequest struct {
+		Model                string        `json:"model"`
+		Content              GeminiContent `json:"content"`
+		OutputDimensionality int           `json:"outputDimensionality,omitempty"`
+	}
+	requests := make([]embedContentRequest, len(req.Input))
+	for i, input := range req.Input {
+		requests[i] = embedContentRequest{
+			Model:                "models/" + model,
+			Content:              GeminiContent{Parts: []GeminiPart{{Text: input}}},
+			OutputDimensionality: req.Dimensions,
+		}
+	}
+
+	resp, err := p.post(ctx, p.modelURL(model, "batchEmbedContents"), map[string]interface{}{"requests": requests})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var embedResp struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, err
+	}
+
+	embeddings := make([][]float32, len(embedResp.Embeddings))
+	for i, e := range embedResp.Embeddings {
+		embeddings[i] = e.Values
+	}
+	// Gemini does not report token usage for embeddings
+	return &providers.EmbeddingResponse{Model: model, Embeddings: embeddings}, nil
+}
+
+// ValidateConfig validates the provider configuration
+func (p *Provider) ValidateConfig() error {
+	if p.config.APIKey == "" {
+		return errors.New("API key is required")
+	}
+	return nil
+}
+
+// model returns the requested model or the connection's default
+func (p *Provider) model(model string) string {
+	if model == "" {
+		model = p.config.DefaultModel
+	}
+	if model == "" {
+		model = DefaultModel
+	}
+	return strings.TrimPrefix(model, "models/")
+}
+
+// modelURL returns the URL of a model method such as generateContent
+func (p *Provider) modelURL(model, method string) string {
+	return fmt.Sprintf("%s/models/%s:%s", p.baseURL, url.PathEscape(model), method)
+}
+
+// setHeaders sets the required headers for the Gemini API
+func (p *Provider) setHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", p.config.APIKey)
+}
+
+// post sends a JSON request and returns the response of a successful call
+func (p *Provider) post(ctx context.Context, endpoint string, payload interface{}) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, apiError(resp)
+	}
+	return resp, nil
+}
+
+// apiError turns a failed response into a provider error. The message leads
+// with the error code callers classify errors by, such as
+// rate_limit_exceeded or model_not_found.
+func apiError(resp *http.Response) error {
+	bodyBytes, _ := io.ReadAll(resp.Body)
+
+	var body GeminiError
+	message := strings.TrimSpace(string(bodyBytes))
+	if json.Unmarshal(bodyBytes, &body) == nil && body.Error.Message != "" {
+		message = body.Error.Message
+	}
+
+	code := ErrorCode(resp.StatusCode, body.Error.Status, message)
+	return providers.NewHTTPError("gemini", resp.StatusCode,
+		fmt.Errorf("Gemini API error (%s): %s - %s", code, resp.Status, message), resp.Header)
+}
+
+// ErrorCode maps a Gemini API failure to the error codes shared by all
+// providers: rate_limit_exceeded, invalid_api_key, model_not_found and
+// context_length_exceeded. Other failures keep Gemini's status in lower
+// case, e.g. invalid_argument.
+func ErrorCode(statusCode int, status, message string) string {
+	lower := strings.ToLower(message)
+	switch {
+	case statusCode == http.StatusTooManyRequests || status == "RESOURCE_EXHAUSTED":
+		return "rate_limit_exceeded"
+	case statusCode == http.StatusUnauthorized || status == "UNAUTHENTICATED" ||
+		strings.Contains(lower, "api key not valid") || strings.Contains(lower, "api_key_invalid"):
+		return "invalid_api_key"
+	case statusCode == http.StatusForbidden || status == "PERMISSION_DENIED":
+		return "invalid_api_key"
+	case statusCode == http.StatusNotFound || status == "NOT_FOUND":
+		return "model_not_found"
+	case strings.Contains(lower, "exceeds the maximum number of tokens") ||
+		strings.Contains(lower, "input token count") && strings.Contains(lower, "exceeds"):
+		return "context_length_exceeded"
+	case status != "":
+		return strings.ToLower(status)
+	default:
+		return "unknown_error"
+	}
+}
+
+// convertRequest converts internal request to Gemini request
+func (p *Provider) convertRequest(req providers.CompletionRequest) GeminiRequest {
+	geminiReq := GeminiRequest{}
+
+	// Tool results refer to calls by ID, but Gemini matches them by name
+	callNames := make(map[string]string)
+	var system []string
+
+	for _, msg := range req.Messages {
+		var content GeminiContent
+		switch msg.Role {
+		case "system":
+			system = append(system, msg.Content)
+			continue
+
+		case "assistant":
+			content.Role = "model"
+			if msg.Content != "" {
+				content.Parts = append(content.Parts, GeminiPart{Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				callNames[tc.ID] = tc.Function.Name
+				args := tc.Function.Arguments
+				if args == "" {
+					args = "{}"
+				}
+				content.Parts = append(content.Parts, GeminiPart{FunctionCall: &GeminiFunctionCall{
+					ID:   tc.ID,
+					Name: tc.Function.Name,
+					Args: json.RawMessage(args),
+				}})
+			}
+
+		case "tool":
+			// Results whose call is no longer in the history, e.g. after the
+			// context was trimmed, still name their tool
+			name, ok := callNames[msg.ToolCallID]
+			if !ok {
+				name = msg.Name
+			}
+			content.Role = "user"
+			content.Parts = []GeminiPart{{FunctionResponse: &GeminiFunctionResponse{
+				ID:       msg.ToolCallID,
+				Name:     name,
+				Response: toolResult(msg.Content),
+			}}}
+
+		default:
+			content.Role = "user"
+			content.Parts = convertParts(msg)
+		}
+
+		if len(content.Parts) == 0 {
+			continue
+		}
+		// Gemini wants turns to alternate, so parallel tool results and
+		// consecutive messages of one role share a turn
+		if n := len(geminiReq.Contents); n > 0 && geminiReq.Contents[n-1].Role == content.Role {
+			geminiReq.Contents[n-1].Parts = append(geminiReq.Contents[n-1].Parts, content.Parts...)
+			continue
+		}
+		geminiReq.Contents = append(geminiReq.Contents, content)
+	}
+
+	if len(system) > 0 {
+		geminiReq.SystemInstruction = &GeminiContent{
+			Parts: []GeminiPart{{Text: strings.Join(system, "\n\n")}},
+		}
+	}
+
+	// Convert tools
+	var declarations []GeminiFunctionDeclaration
+	for _, fn := range req.Functions {
+		declarations = append(declarations, GeminiFunctionDeclaration{
+			Name:        fn.Name,
+			Description: fn.Description,
+			Parameters:  cleanSchema(fn.Parameters),
+		})
+	}
+	for _, tool := range req.Tools {
+		declarations = append(declarations, GeminiFunctionDeclaration{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  cleanSchema(tool.Function.Parameters),
+		})
+	}
+	if len(declarations) > 0 {
+		geminiReq.Tools = []GeminiTool{{FunctionDeclarations: declarations}}
+	}
+
+	// Convert tool choice
+	if req.ToolChoice != nil {
+		config := GeminiFunctionCallingConfig{}
+		switch req.ToolChoice.Type {
+		case "auto":
+			config.Mode = "AUTO"
+		case "none":
+			config.Mode = "NONE"
+		case "function":
+			config.Mode = "ANY"
+			if req.ToolChoice.Function != nil {
+				config.AllowedFunctionNames = []string{req.ToolChoice.Function.Name}
+			}
+		}
+		if config.Mode != "" {
+			geminiReq.ToolConfig = &GeminiToolConfig{FunctionCallingConfig: config}
+		}
+	}
+
+	generation := GeminiGenerationConfig{
+		Temperature:     req.Temperature,
+		MaxOutputTokens: req.MaxTokens,
+	}
+	if req.ResponseFormat != nil && (req.ResponseFormat.Type == "json_object" || req.ResponseFormat.Type == "json_schema") {
+		generation.ResponseMimeType = "application/json"
+	}
+	if generation != (GeminiGenerationConfig{}) {
+		geminiReq.GenerationConfig = &generation
+	}
+
+	return geminiReq
+}
+
+// convertParts converts the text and images of a user message
+func convertParts(msg providers.Message) []GeminiPart {
+	var parts []GeminiPart
+	for _, part := range msg.Parts() {
+		switch {
+		case !part.IsImage():
+			parts = append(parts, GeminiPart{Text: part.Text})
+		case part.ImageURL == "":
+			parts = append(parts, GeminiPart{InlineData: &GeminiBlob{
+				MimeType: part.MediaType,
+				Data:     part.Data,
+			}})
+		default:
+			// Remote images are referenced by URI
+			mimeType := ""
+			if u, err := url.Parse(part.ImageURL); err == nil {
+				mimeType = mime.TypeByExtension(path.Ext(u.Path))
+			}
+			parts = append(parts, GeminiPart{FileData: &GeminiFileData{MimeType: mimeType, FileURI: part.ImageURL}})
+		}
+	}
+	return parts
+}
+
+// toolResult wraps a tool's output in the object Gemini expects
+func toolResult(content string) map[string]interface{} {
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &result); err == nil && result != nil {
+		return result
+	}
+	return map[string]interface{}{"content": content}
+}
+
+// cleanSchema drops the JSON Schema keywords Gemini's OpenAPI subset rejects
+func cleanSchema(schema map[string]interface{}) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+	cleaned := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		switch key {
+		case "$schema", "$id", "additionalProperties":
+			continue
+		}
+		switch value := value.(type) {
+		case map[string]interface{}:
+			cleaned[key] = cleanSchema(value)
+		case []interface{}:
+			items := make([]interface{}, len(value))
+			for i, item := range value {
+				if sub, ok := item.(map[string]interface{}); ok {
+					items[i] = cleanSchema(sub)
+				} else {
+					items[i] = item
+				}
+			}
+			cleaned[key] = items
+		default:
+			cleaned[key] = value
+		}
+	}
+	return cleaned
+}
+
+// convertResponse converts Gemini response to internal response
+func (p *Provider) convertResponse(resp *GeminiResponse, model string) *providers.CompletionResponse {
+	message := providers.Message{Role: "assistant"}
+	finishReason := "stop"
+
+	if len(resp.Candidates) > 0 {
+		candidate := resp.Candidates[0]
+		var text strings.Builder
+		for i, part := range candidate.Content.Parts {
+			if part.Text != "" {
+				text.WriteString(part.Text)
+			}
+			if part.FunctionCall != nil {
+				message.ToolCalls = append(message.ToolCalls, convertFunctionCall(part.FunctionCall, i))
+			}
+		}
+		message.Content = text.String()
+		if candidate.FinishReason != "" {
+			finishReason = convertFinishReason(candidate.FinishReason)
+		}
+	}
+	if len(message.ToolCalls) > 0 && finishReason == "stop" {
+		finishReason = "tool_calls"
+	}
+
+	if resp.ModelVersion != "" {
+		model = resp.ModelVersion
+	}
+	completion := &providers.CompletionResponse{
+		ID:      resp.ResponseID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []providers.Choice{
+			{
+				Index:        0,
+				Message:      message,
+				FinishReason: finishReason,
+			},
+		},
+	}
+	if resp.UsageMetadata != nil {
+		completion.Usage = providers.Usage{
+			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      resp.UsageMetadata.TotalTokenCount,
+		}
+	}
+	return completion
+}
+
+// convertStreamEvent converts one streamed response into chunks. Gemini
+// sends each function call whole, so it becomes a single tool call chunk.
+func (p *Provider) convertStreamEvent(event *GeminiResponse, toolCalls *int) []providers.StreamChunk {
+	if len(event.Candidates) == 0 {
+		return nil
+	}
+
+	var chunks []providers.StreamChunk
+	for _, part := range event.Candidates[0].Content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			chunks = append(chunks, providers.StreamChunk{
+				ToolCalls: []providers.ToolCall{convertFunctionCall(part.FunctionCall, *toolCalls)},
+			})
+			*toolCalls++
+		case part.Text != "":
+			chunks = append(chunks, providers.StreamChunk{Delta: part.Text})
+		}
+	}
+	return chunks
+}
+
+// convertFunctionCall converts a Gemini function call to a tool call. Older
+// models do not assign call IDs, so one is derived from the name and position.
+func convertFunctionCall(call *GeminiFunctionCall, index int) providers.ToolCall {
+	id := call.ID
+	if id == "" {
+		id = fmt.Sprintf("call_%s_%d", call.Name, index)
+	}
+	args := string(call.Args)
+	if args == "" {
+		args = "{}"
+	}
+	return providers.ToolCall{
+		ID:   id,
+		Type: "function",
+		Function: providers.FunctionCall{
+			Name:      call.Name,
+			Arguments: args,
+		},
+	}
+}
+
+// convertFinishReason converts Gemini finish reason to OpenAI format
+func convertFinishReason(reason string) string {
+	switch reason {
+	case "STOP":
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return "content_filter"
+	default:
+		return strings.ToLower(reason)
+	}
+}
+
+// supportsAny reports whether a model supports any of the methods
+func supportsAny(supported []string, methods ...string) bool {
+	for _, s := range supported {
+		for _, m := range methods {
+			if s == m {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agentx/agentx-backend/internal/config"
+	"github.com/agentx/agentx-backend/internal/providers"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestProvider points a provider at a local stand-in for the Gemini API
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *Provider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	p, err := NewProvider("gemini", config.ProviderConfig{
+		Type:    "gemini",
+		Name:    "gemini",
+		BaseURL: server.URL,
+		APIKey:  "test-key",
+	})
+	assert.NoError(t, err)
+	return p
+}
+
+func TestCompleteSendsToolResultsAndReturnsFunctionCalls(t *testing.T) {
+	var got GeminiRequest
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/models/gemini-1.5-pro:generateContent", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-goog-api-key"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		fmt.Fprint(w, `{
+			"candidates": [{
+				"content": {"role": "model", "parts": [
+					{"text": "Checking."},
+					{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}}
+				]},
+				"finishReason": "STOP"
+			}],
+			"usageMetadata": {"promptTokenCount": 12, "candidatesTokenCount": 5, "totalTokenCount": 17},
+			"responseId": "resp-1"
+		}`)
+	})
+
+	resp, err := p.Complete(context.Background(), providers.CompletionRequest{
+		Model: "gemini-1.5-pro",
+		Messages: []providers.Message{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "Weather in Rome?", ContentArray: []interface{}{
+				map[string]interface{}{"type": "image_url", "image_url": map[string]string{"url": "data:image/png;base64,aGk="}},
+			}},
+			{Role: "assistant", ToolCalls: []providers.ToolCall{{
+				ID: "call_1", Type: "function",
+				Function: providers.FunctionCall{Name: "get_weather", Arguments: `{"city":"Rome"}`},
+			}}},
+			{Role: "tool", ToolCallID: "call_1", Content: "sunny"},
+		},
+		Tools: []providers.Tool{{Type: "function", Function: providers.Function{
+			Name: "get_weather",
+			Parameters: map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties":           map[string]interface{}{"city": map[string]interface{}{"type": "string"}},
+			},
+		}}},
+	})
+	assert.NoError(t, err)
+
+	// The request in Gemini's shape
+	if assert.NotNil(t, got.SystemInstruction) {
+		assert.Equal(t, "Be brief.", got.SystemInstruction.Parts[0].Text)
+	}
+	if assert.Len(t, got.Contents, 3) {
+		assert.Equal(t, "user", got.Contents[0].Role)
+		assert.Equal(t, "Weather in Rome?", got.Contents[0].Parts[0].Text)
+		assert.Equal(t, &GeminiBlob{MimeType: "image/png", Data: "aGk="}, got.Contents[0].Parts[1].InlineData)
+		assert.Equal(t, "model", got.Contents[1].Role)
+		assert.Equal(t, "get_weather", got.Contents[1].Parts[0].FunctionCall.Name)
+		assert.Equal(t, "user", got.Contents[2].Role)
+		assert.Equal(t, "call_1", got.Contents[1].Parts[0].FunctionCall.ID)
+		assert.Equal(t, &GeminiFunctionResponse{
+			ID:       "call_1",
+			Name:     "get_weather",
+			Response: map[string]interface{}{"content": "sunny"},
+		}, got.Contents[2].Parts[0].FunctionResponse)
+	}
+	if assert.Len(t, got.Tools, 1) {
+		assert.NotContains(t, got.Tools[0].FunctionDeclarations[0].Parameters, "additionalProperties")
+	}
+
+	// The response in ours
+	assert.Equal(t, "resp-1", resp.ID)
+	assert.Equal(t, "Checking.", resp.Choices[0].Message.Content)
+	assert.Equal(t, "tool_calls", resp.Choices[0].FinishReason)
+	if assert.Len(t, resp.Choices[0].Message.ToolCalls, 1) {
+		call := resp.Choices[0].Message.ToolCalls[0]
+		assert.Equal(t, "get_weather", call.Function.Name)
+		assert.JSONEq(t, `{"city":"Paris"}`, call.Function.Arguments)
+		assert.NotEmpty(t, call.ID)
+	}
+	assert.Equal(t, providers.Usage{PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17}, resp.Usage)
+}
+
+func TestConvertRequestNamesResultsOfTrimmedCalls(t *testing.T) {
+	p := newTestProvider(t, nil)
+	req := p.convertRequest(providers.CompletionRequest{
+		Model: "gemini-1.5-pro",
+		Messages: []providers.Message{
+			{Role: "tool", ToolCallID: "call_1", Name: "get_weather", Content: "sunny"},
+			{Role: "user", Content: "And tomorrow?"},
+		},
+	})
+
+	if assert.Len(t, req.Contents, 1) {
+		assert.Equal(t, &GeminiFunctionResponse{
+			ID:       "call_1",
+			Name:     "get_weather",
+			Response: map[string]interface{}{"content": "sunny"},
+		}, req.Contents[0].Parts[0].FunctionResponse)
+	}
+}
+
+func TestStreamCompleteEmitsTextAndFinishReason(t *testing.T) {
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/models/gemini-1.5-flash:streamGenerateContent", r.URL.Path)
+		assert.Equal(t, "sse", r.URL.Query().Get("alt"))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Hel\"}]}}]}\n\n")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"lo\"}]},\"finishReason\":\"MAX_TOKENS\"}]}\n\n")
+	})
+
+	chunks, err := p.StreamComplete(context.Background(), providers.CompletionRequest{
+		Messages: []providers.Message{{Role: "user", Content: "Hi"}},
+	})
+	assert.NoError(t, err)
+
+	text, finishReason := "", ""
+	for chunk := range chunks {
+		assert.Empty(t, chunk.Error)
+		text += chunk.Delta
+		if chunk.FinishReason != "" {
+			finishReason = chunk.FinishReason
+		}
+	}
+	assert.Equal(t, "Hello", text)
+	assert.Equal(t, "length", finishReason)
+}
+
+func TestErrorsCarrySharedErrorCodes(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		code   string
+	}{
+		{429, `{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}`, "rate_limit_exceeded"},
+		{400, `{"error":{"code":400,"message":"API key not valid. Please pass a valid API key.","status":"INVALID_ARGUMENT"}}`, "invalid_api_key"},
+		{404, `{"error":{"code":404,"message":"models/nope is not found","status":"NOT_FOUND"}}`, "model_not_found"},
+		{400, `{"error":{"code":400,"message":"The input token count (2000001) exceeds the maximum number of tokens allowed (1048576).","status":"INVALID_ARGUMENT"}}`, "context_length_exceeded"},
+	}
+
+	for _, tt := range tests {
+		p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		})
+
+		_, err := p.Complete(context.Background(), providers.CompletionRequest{
+			Messages: []providers.Message{{Role: "user", Content: "Hi"}},
+		})
+		assert.ErrorContains(t, err, tt.code)
+
+		var providerErr *providers.ProviderError
+		if assert.True(t, errors.As(err, &providerErr)) {
+			assert.Equal(t, tt.status, providerErr.StatusCode)
+		}
+	}
+}
+
+func TestGetModelsListsGenerativeModels(t *testing.T) {
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/models", r.URL.Path)
+		if r.URL.Query().Get("pageToken") == "" {
+			fmt.Fprint(w, `{"models":[
+				{"name":"models/gemini-1.5-pro","supportedGenerationMethods":["generateContent","countTokens"]},
+				{"name":"models/aqa","supportedGenerationMethods":["generateAnswer"]}
+			],"nextPageToken":"next"}`)
+			return
+		}
+		fmt.Fprint(w, `{"models":[{"name":"models/text-embedding-004","supportedGenerationMethods":["embedContent"]}]}`)
+	})
+
+	models, err := p.GetModels(context.Background())
+	assert.NoError(t, err)
+
+	var ids []string
+	for _, m := range models {
+		ids = append(ids, m.ID)
+	}
+	assert.Equal(t, []string{"gemini-1.5-pro", "text-embedding-004"}, ids)
+}
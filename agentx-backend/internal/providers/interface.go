@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"time"
 )
 
 // Provider defines the interface for all LLM providers
@@ -22,6 +23,53 @@ type Provider interface {
 	ValidateConfig() error
 }
 
+// Embedder is implemented by providers that can embed text
+type Embedder interface {
+	// Embed returns one vector per input, in input order
+	Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error)
+}
+
+// ModelManager is implemented by providers that host models themselves and
+// can install, remove and report the models that are loaded
+type ModelManager interface {
+	// PullModel downloads a model, reporting progress until the channel closes
+	PullModel(ctx context.Context, model string) (<-chan PullProgress, error)
+
+	// DeleteModel removes a model
+	DeleteModel(ctx context.Context, model string) error
+
+	// RunningModels returns the models currently loaded in memory
+	RunningModels(ctx context.Context) ([]RunningModel, error)
+}
+
+// PullProgress reports the progress of a model download
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RunningModel is a model loaded in memory
+type RunningModel struct {
+	Name          string       `json:"name"`
+	Size          int64        `json:"size"`
+	SizeVRAM      int64        `json:"size_vram"`
+	ContextLength int          `json:"context_length,omitempty"`
+	ExpiresAt     time.Time    `json:"expires_at"`
+	Details       ModelDetails `json:"details"`
+}
+
+// ModelDetails describes the build of a locally hosted model
+type ModelDetails struct {
+	Format            string   `json:"format,omitempty"`
+	Family            string   `json:"family,omitempty"`
+	Families          []string `json:"families,omitempty"`
+	ParameterSize     string   `json:"parameter_size,omitempty"`
+	QuantizationLevel string   `json:"quantization_level,omitempty"`
+}
+
 // CompletionRequest represents a chat completion request
 type CompletionRequest struct {
 	Messages        []Message        `json:"messages"`
@@ -33,6 +81,20 @@ type CompletionRequest struct {
 	ToolChoice      *ToolChoice      `json:"tool_choice,omitempty"`
 	Tools           []Tool           `json:"tools,omitempty"`
 	ResponseFormat  *ResponseFormat  `json:"response_format,omitempty"`
+	Reasoning       *Reasoning       `json:"reasoning,omitempty"`
+	PromptCache     *PromptCache     `json:"prompt_cache,omitempty"`
+}
+
+// Reasoning asks models that can think before answering to do so
+type Reasoning struct {
+	BudgetTokens int `json:"budget_tokens"` // Tokens the model may spend thinking
+}
+
+// PromptCache marks stable prompt prefixes as cacheable, for providers
+// with prompt caching. Long documents are marked per message.
+type PromptCache struct {
+	System bool `json:"system,omitempty"` // The system prompt
+	Tools  bool `json:"tools,omitempty"`  // The tool definitions
 }
 
 // Message represents a chat message
@@ -43,6 +105,14 @@ type Message struct {
 	FunctionCall *FunctionCall  `json:"function_call,omitempty"`
 	ToolCalls    []ToolCall     `json:"tool_calls,omitempty"`
 	ToolCallID   string         `json:"tool_call_id,omitempty"`
+	Name         string         `json:"name,omitempty"` // The tool a tool result answers
+	Cacheable    bool           `json:"cacheable,omitempty"` // Cache the prompt up to and including this message
+	// Thinking that preceded an assistant message; the signature lets it be
+	// sent back to the provider in tool-use turns. Thinking the provider
+	// encrypted is kept opaque and sent back unchanged.
+	Reasoning          string   `json:"reasoning,omitempty"`
+	ReasoningSignature string   `json:"reasoning_signature,omitempty"`
+	RedactedReasoning  []string `json:"redacted_reasoning,omitempty"`
 }
 
 // Function represents a function that can be called
@@ -69,6 +139,7 @@ type ToolCall struct {
 	ID       string       `json:"id"`
 	Type     string       `json:"type"`
 	Function FunctionCall `json:"function"`
+	Index    *int         `json:"index,omitempty"` // Position of a streamed fragment's call
 }
 
 // ToolChoice represents tool selection preference
@@ -102,6 +173,10 @@ type Usage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	// Prompt tokens read from or written to the provider's prompt cache,
+	// included in PromptTokens
+	CacheReadTokens     int `json:"cache_read_tokens,omitempty"`
+	CacheCreationTokens int `json:"cache_creation_tokens,omitempty"`
 }
 
 // StreamChunk represents a chunk in a streaming response
@@ -116,11 +191,29 @@ type StreamChunk struct {
 	ToolCalls    []ToolCall    `json:"tool_calls,omitempty"`
 	FinishReason string        `json:"finish_reason,omitempty"`
 	Error        string        `json:"error,omitempty"`
+	// Thinking streamed before the answer, the signature that closes it and
+	// any encrypted thinking, which arrives whole
+	Reasoning          string   `json:"reasoning,omitempty"`
+	ReasoningSignature string   `json:"reasoning_signature,omitempty"`
+	RedactedReasoning  []string `json:"redacted_reasoning,omitempty"`
+	Usage              *Usage   `json:"usage,omitempty"` // Reported once, near the end of the stream
+	// The typed error behind Error, such as a *ProviderError, so status and
+	// retry hints survive the stream
+	Err error `json:"-"`
 }
 
 // ResponseFormat represents the desired response format
 type ResponseFormat struct {
-	Type string `json:"type"` // "text", "json_object"
+	Type       string      `json:"type"` // "text", "json_object", "json_schema"
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+// JSONSchema constrains a json_schema response format
+type JSONSchema struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema"`
+	Strict      bool                   `json:"strict,omitempty"`
 }
 
 // Model represents an available model
@@ -131,4 +224,18 @@ type Model struct {
 	OwnedBy     string                 `json:"owned_by"`
 	Permissions []interface{}         `json:"permissions,omitempty"`
 	Extra       map[string]interface{} `json:"extra,omitempty"`
-}
\ No newline at end of file
+}
+
+// EmbeddingRequest represents a request to embed a batch of texts
+type EmbeddingRequest struct {
+	Input      []string `json:"input"`
+	Model      string   `json:"model"`
+	Dimensions int      `json:"dimensions,omitempty"` // Truncate vectors, where the model supports it
+}
+
+// EmbeddingResponse holds the vectors of an embedding request
+type EmbeddingResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float32 `json:"embeddings"`
+	Usage      Usage       `json:"usage"`
+}
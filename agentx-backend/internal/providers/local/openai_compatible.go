@@ -35,6 +35,7 @@ func NewOpenAICompatibleProvider(id string, cfg config.ProviderConfig) (*OpenAIC
 	
 	clientConfig := openai.DefaultConfig(apiKey)
 	clientConfig.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/") + "/v1"
+	clientConfig.HTTPClient = &http.Client{Transport: &providers.HeaderCaptureTransport{}}
 
 	client := openai.NewClientWithConfig(clientConfig)
 
@@ -55,9 +56,10 @@ func (p *OpenAICompatibleProvider) Complete(ctx context.Context, req providers.C
 	openAIReq := p.convertRequest(req)
 	openAIReq.Stream = false
 
+	ctx, sink := providers.WithHeaderSink(ctx)
 	resp, err := p.client.CreateChatCompletion(ctx, openAIReq)
 	if err != nil {
-		return nil, err
+		return nil, p.wrapError(err, sink)
 	}
 
 	return p.convertResponse(&resp), nil
@@ -65,19 +67,19 @@ func (p *OpenAICompatibleProvider) Complete(ctx context.Context, req providers.C
 
 // StreamComplete performs a streaming completion
 func (p *OpenAICompatibleProvider) StreamComplete(ctx context.Context, req providers.CompletionRequest) (<-chan providers.StreamChunk, error) {
-	chunks := make(chan providers.StreamChunk)
+	openAIReq := p.convertRequest(req)
+	openAIReq.Stream = true
+
+	// Open errors carry the status and rate-limit hints, as in Complete
+	ctx, sink := providers.WithHeaderSink(ctx)
+	stream, err := p.client.CreateChatCompletionStream(ctx, openAIReq)
+	if err != nil {
+		return nil, p.wrapError(err, sink)
+	}
 
+	chunks := make(chan providers.StreamChunk)
 	go func() {
 		defer close(chunks)
-
-		openAIReq := p.convertRequest(req)
-		openAIReq.Stream = true
-
-		stream, err := p.client.CreateChatCompletionStream(ctx, openAIReq)
-		if err != nil {
-			chunks <- providers.StreamChunk{Error: err.Error()}
-			return
-		}
 		defer stream.Close()
 
 		for {
@@ -194,6 +196,45 @@ func (p *OpenAICompatibleProvider) GetModels(ctx context.Context) ([]providers.M
 	return modelsResp.Data, nil
 }
 
+// DefaultEmbeddingModel is used when an embedding request names no model
+const DefaultEmbeddingModel = "nomic-embed-text"
+
+// Embed embeds a batch of texts through the /v1/embeddings endpoint
+func (p *OpenAICompatibleProvider) Embed(ctx context.Context, req providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = DefaultEmbeddingModel
+	}
+
+	ctx, sink := providers.WithHeaderSink(ctx)
+	resp, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input:      req.Input,
+		Model:      openai.EmbeddingModel(model),
+		Dimensions: req.Dimensions,
+	})
+	if err != nil {
+		return nil, p.wrapError(err, sink)
+	}
+
+	embeddings := make([][]float32, len(req.Input))
+	for _, e := range resp.Data {
+		if e.Index >= 0 && e.Index < len(embeddings) {
+			embeddings[e.Index] = e.Embedding
+		}
+	}
+	if resp.Model == "" {
+		resp.Model = openai.EmbeddingModel(model)
+	}
+	return &providers.EmbeddingResponse{
+		Model:      string(resp.Model),
+		Embeddings: embeddings,
+		Usage: providers.Usage{
+			PromptTokens: resp.Usage.PromptTokens,
+			TotalTokens:  resp.Usage.TotalTokens,
+		},
+	}, nil
+}
+
 // ValidateConfig validates the provider configuration
 func (p *OpenAICompatibleProvider) ValidateConfig() error {
 	if p.config.BaseURL == "" {
@@ -210,6 +251,11 @@ func (p *OpenAICompatibleProvider) convertRequest(req providers.CompletionReques
 			Role:    msg.Role,
 			Content: msg.Content,
 		}
+		if len(msg.ContentArray) > 0 {
+			// Content and MultiContent are mutually exclusive
+			messages[i].Content = ""
+			messages[i].MultiContent = msg.OpenAIParts()
+		}
 
 		if msg.FunctionCall != nil {
 			messages[i].FunctionCall = &openai.FunctionCall{
@@ -355,7 +401,20 @@ func (p *OpenAICompatibleProvider) convertToolCalls(toolCalls []openai.ToolCall)
 				Name:      tc.Function.Name,
 				Arguments: tc.Function.Arguments,
 			},
+			Index: tc.Index,
 		}
 	}
 	return result
-}
\ No newline at end of file
+}
+// wrapError attaches the HTTP status and rate-limit hints to an upstream error
+func (p *OpenAICompatibleProvider) wrapError(err error, sink *providers.HeaderSink) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode > 0 {
+		return providers.NewHTTPError(p.id, apiErr.HTTPStatusCode, err, sink.Header())
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) && reqErr.HTTPStatusCode > 0 {
+		return providers.NewHTTPError(p.id, reqErr.HTTPStatusCode, err, sink.Header())
+	}
+	return err
+}
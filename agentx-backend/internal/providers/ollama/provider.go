@@ -0,0 +1,652 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/config"
+	"github.com/agentx/agentx-backend/internal/providers"
+)
+
+// DefaultBaseURL is the address of a local Ollama server
+const DefaultBaseURL = "http://localhost:11434"
+
+// Provider implements a native Ollama provider on Ollama's own API. Besides
+// completions and embeddings it manages the server's models.
+//
+// Connection settings may set "num_ctx" (context window in tokens),
+// "keep_alive" (how long models stay loaded, e.g. "10m" or -1) and
+// "options", an object of further Ollama model options.
+type Provider struct {
+	id      string
+	config  config.ProviderConfig
+	client  *http.Client
+	baseURL string
+
+	mu    sync.Mutex
+	shown map[string]*ShowResponse // By model digest
+}
+
+// ChatRequest represents an /api/chat request
+type ChatRequest struct {
+	Model     string                 `json:"model"`
+	Messages  []ChatMessage          `json:"messages"`
+	Tools     []ChatTool             `json:"tools,omitempty"`
+	Format    interface{}            `json:"format,omitempty"` // "json" or a JSON Schema
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive interface{}            `json:"keep_alive,omitempty"`
+	Stream    bool                   `json:"stream"`
+}
+
+// ChatMessage is a message of an /api/chat conversation
+type ChatMessage struct {
+	Role      string         `json:"role"`
+	Content   string         `json:"content"`
+	Images    []string       `json:"images,omitempty"` // Base64, without data URL prefix
+	ToolCalls []ChatToolCall `json:"tool_calls,omitempty"`
+	ToolName  string         `json:"tool_name,omitempty"`
+}
+
+// ChatTool declares a function the model may call
+type ChatTool struct {
+	Type     string             `json:"type"`
+	Function providers.Function `json:"function"`
+}
+
+// ChatToolCall is a function call made by the model
+type ChatToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"` // An object, not a string
+	} `json:"function"`
+}
+
+// ChatResponse represents an /api/chat response, and each line of a
+// streamed one
+type ChatResponse struct {
+	Model           string      `json:"model"`
+	CreatedAt       time.Time   `json:"created_at"`
+	Message         ChatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	DoneReason      string      `json:"done_reason,omitempty"`
+	PromptEvalCount int         `json:"prompt_eval_count,omitempty"`
+	EvalCount       int         `json:"eval_count,omitempty"`
+	Error           string      `json:"error,omitempty"`
+}
+
+// ShowResponse represents an /api/show response
+type ShowResponse struct {
+	Details      providers.ModelDetails `json:"details"`
+	ModelInfo    map[string]interface{} `json:"model_info"`
+	Capabilities []string               `json:"capabilities"`
+}
+
+// ContextLength returns the context window the model was trained with, or 0
+func (s *ShowResponse) ContextLength() int {
+	arch, _ := s.ModelInfo["general.architecture"].(string)
+	if n, ok := s.ModelInfo[arch+".context_length"].(float64); ok {
+		return int(n)
+	}
+	return 0
+}
+
+// NewProvider creates a new Ollama provider
+func NewProvider(id string, cfg config.ProviderConfig) (*Provider, error) {
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	// Connections set up for the OpenAI-compatible API point at /v1
+	baseURL = strings.TrimSuffix(baseURL, "/v1")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &Provider{
+		id:      id,
+		config:  cfg,
+		client:  &http.Client{},
+		baseURL: baseURL,
+		shown:   make(map[string]*ShowResponse),
+	}, nil
+}
+
+// Name returns the provider name
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// Complete performs a non-streaming completion
+func (p *Provider) Complete(ctx context.Context, req providers.CompletionRequest) (*providers.CompletionResponse, error) {
+	chatReq := p.convertRequest(req)
+	chatReq.Stream = false
+
+	resp, err := p.do(ctx, "POST", "/api/chat", chatReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, err
+	}
+
+	return p.convertResponse(&chatResp), nil
+}
+
+// StreamComplete performs a streaming completion
+func (p *Provider) StreamComplete(ctx context.Context, req providers.CompletionRequest) (<-chan providers.StreamChunk, error) {
+	chatReq := p.convertRequest(req)
+	chatReq.Stream = true
+
+	resp, err := p.do(ctx, "POST", "/api/chat", chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan providers.StreamChunk)
+	go func() {
+		defer close(chunks)
+		defer resp.Body.Close()
+
+		chunks <- providers.StreamChunk{
+			Object: "chat.completion.chunk",
+			Model:  chatReq.Model,
+			Role:   "assistant",
+		}
+
+		// Ollama streams one JSON object per line
+		toolCalls := 0
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var event ChatResponse
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				continue
+			}
+			if event.Error != "" {
+				chunks <- providers.StreamChunk{Error: event.Error}
+				return
+			}
+
+			if event.Message.Content != "" {
+				chunks <- providers.StreamChunk{Delta: event.Message.Content}
+			}
+			if len(event.Message.ToolCalls) > 0 {
+				calls := convertToolCalls(event.Message.ToolCalls, toolCalls)
+				toolCalls += len(calls)
+				chunks <- providers.StreamChunk{ToolCalls: calls}
+			}
+
+			if event.Done {
+				chunks <- providers.StreamChunk{
+					FinishReason: finishReason(event.DoneReason, toolCalls > 0),
+					Usage:        convertUsage(&event),
+				}
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			chunks <- providers.StreamChunk{Error: err.Error()}
+			return
+		}
+		chunks <- providers.StreamChunk{FinishReason: "stop"}
+	}()
+
+	return chunks, nil
+}
+
+// GetModels returns the installed models with their real context sizes,
+// parameter sizes and quantization
+func (p *Provider) GetModels(ctx context.Context) ([]providers.Model, error) {
+	resp, err := p.do(ctx, "GET", "/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tags struct {
+		Models []struct {
+			Name       string                 `json:"name"`
+			ModifiedAt time.Time              `json:"modified_at"`
+			Size       int64                  `json:"size"`
+			Digest     string                 `json:"digest"`
+			Details    providers.ModelDetails `json:"details"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, err
+	}
+
+	models := make([]providers.Model, len(tags.Models))
+	for i, m := range tags.Models {
+		extra := map[string]interface{}{
+			"size":               m.Size,
+			"digest":             m.Digest,
+			"format":             m.Details.Format,
+			"family":             m.Details.Family,
+			"parameter_size":     m.Details.ParameterSize,
+			"quantization_level": m.Details.QuantizationLevel,
+		}
+		// Details beyond the listing are best effort
+		if show, err := p.showCached(ctx, m.Name, m.Digest); err == nil {
+			if n := show.ContextLength(); n > 0 {
+				// Ollama only uses as much of it as num_ctx allows
+				if numCtx := p.numCtx(); numCtx > 0 && numCtx < n {
+					n = numCtx
+				}
+				extra["context_length"] = n
+			}
+			if len(show.Capabilities) > 0 {
+				extra["capabilities"] = show.Capabilities
+			}
+		}
+
+		models[i] = providers.Model{
+			ID:      m.Name,
+			Object:  "model",
+			Created: m.ModifiedAt.Unix(),
+			OwnedBy: "ollama",
+			Extra:   extra,
+		}
+	}
+	return models, nil
+}
+
+// ShowModel returns the details of an installed model
+func (p *Provider) ShowModel(ctx context.Context, model string) (*ShowResponse, error) {
+	resp, err := p.do(ctx, "POST", "/api/show", map[string]string{"model": model})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var show ShowResponse
+	if err := json.NewDecoder(resp.Body).Decode(&show); err != nil {
+		return nil, err
+	}
+	return &show, nil
+}
+
+// showCached returns model details, asking the server once per model build
+func (p *Provider) showCached(ctx context.Context, model, digest string) (*ShowResponse, error) {
+	p.mu.Lock()
+	show, ok := p.shown[digest]
+	p.mu.Unlock()
+	if ok {
+		return show, nil
+	}
+
+	show, err := p.ShowModel(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.shown[digest] = show
+	p.mu.Unlock()
+	return show, nil
+}
+
+// PullModel downloads a model, streaming Ollama's progress reports
+func (p *Provider) PullModel(ctx context.Context, model string) (<-chan providers.PullProgress, error) {
+	resp, err := p.do(ctx, "POST", "/api/pull", map[string]interface{}{"model": model, "stream": true})
+	if err != nil {
+		return nil, err
+	}
+
+	progress := make(chan providers.PullProgress)
+	go func() {
+		defer close(progress)
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var event providers.PullProgress
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				continue
+			}
+			select {
+			case progress <- event:
+			case <-ctx.Done():
+				return
+			}
+			if event.Error != "" {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			select {
+			case progress <- providers.PullProgress{Status: "error", Error: err.Error()}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return progress, nil
+}
+
+// DeleteModel removes an installed model
+func (p *Provider) DeleteModel(ctx context.Context, model string) error {
+	resp, err := p.do(ctx, "DELETE", "/api/delete", map[string]string{"model": model})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// RunningModels returns the models loaded in memory
+func (p *Provider) RunningModels(ctx context.Context) ([]providers.RunningModel, error) {
+	resp, err := p.do(ctx, "GET", "/api/ps", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var ps struct {
+		Models []providers.RunningModel `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ps); err != nil {
+		return nil, err
+	}
+	return ps.Models, nil
+}
+
+// Embed embeds a batch of texts with /api/embed
+func (p *Provider) Embed(ctx context.Context, req providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = "nomic-embed-text"
+	}
+
+	body := map[string]interface{}{"model": model, "input": req.Input}
+	if req.Dimensions > 0 {
+		body["dimensions"] = req.Dimensions
+	}
+	if keepAlive, ok := p.config.Extra["keep_alive"]; ok {
+		body["keep_alive"] = keepAlive
+	}
+
+	resp, err := p.do(ctx, "POST", "/api/embed", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var embedResp struct {
+		Model           string      `json:"model"`
+		Embeddings      [][]float32 `json:"embeddings"`
+		PromptEvalCount int         `json:"prompt_eval_count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, err
+	}
+
+	return &providers.EmbeddingResponse{
+		Model:      embedResp.Model,
+		Embeddings: embedResp.Embeddings,
+		Usage: providers.Usage{
+			PromptTokens: embedResp.PromptEvalCount,
+			TotalTokens:  embedResp.PromptEvalCount,
+		},
+	}, nil
+}
+
+// ValidateConfig validates the provider configuration
+func (p *Provider) ValidateConfig() error {
+	if p.baseURL == "" {
+		return errors.New("base URL is required")
+	}
+	return nil
+}
+
+// do sends a request to the Ollama API and returns the response of a
+// successful call
+func (p *Provider) do(ctx context.Context, method, path string, payload interface{}) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	// Ollama needs no key, but proxies in front of it may
+	if p.config.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, apiError(resp)
+	}
+	return resp, nil
+}
+
+// apiError turns a failed response into a provider error whose message leads
+// with the shared error code, such as model_not_found
+func apiError(resp *http.Response) error {
+	bodyBytes, _ := io.ReadAll(resp.Body)
+
+	message := strings.TrimSpace(string(bodyBytes))
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(bodyBytes, &body) == nil && body.Error != "" {
+		message = body.Error
+	}
+
+	lower := strings.ToLower(message)
+	code := "unknown_error"
+	switch {
+	case strings.HasPrefix(lower, "model ") && strings.Contains(lower, "not found"):
+		// e.g. model "llama3" not found, try pulling it first
+		code = "model_not_found"
+	case resp.StatusCode == http.StatusTooManyRequests:
+		code = "rate_limit_exceeded"
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		code = "invalid_api_key"
+	case strings.Contains(lower, "context length") || strings.Contains(lower, "exceeds the context"):
+		code = "context_length_exceeded"
+	}
+
+	return providers.NewHTTPError("ollama", resp.StatusCode,
+		fmt.Errorf("Ollama API error (%s): %s - %s", code, resp.Status, message), resp.Header)
+}
+
+// convertRequest converts internal request to an /api/chat request
+func (p *Provider) convertRequest(req providers.CompletionRequest) ChatRequest {
+	chatReq := ChatRequest{
+		Model:     req.Model,
+		KeepAlive: p.config.Extra["keep_alive"],
+		Options:   p.options(req),
+	}
+	if chatReq.Model == "" {
+		chatReq.Model = p.config.DefaultModel
+	}
+
+	// Tool results refer to calls by ID, but Ollama matches them by name
+	callNames := make(map[string]string)
+	for _, msg := range req.Messages {
+		chatMsg := ChatMessage{Role: msg.Role, Content: msg.Content}
+
+		for _, tc := range msg.ToolCalls {
+			callNames[tc.ID] = tc.Function.Name
+			call := ChatToolCall{}
+			call.Function.Name = tc.Function.Name
+			call.Function.Arguments = json.RawMessage(tc.Function.Arguments)
+			if !json.Valid(call.Function.Arguments) {
+				call.Function.Arguments = json.RawMessage("{}")
+			}
+			chatMsg.ToolCalls = append(chatMsg.ToolCalls, call)
+		}
+		if msg.Role == "tool" {
+			chatMsg.ToolName = callNames[msg.ToolCallID]
+		}
+
+		if len(msg.ContentArray) > 0 {
+			// Ollama cannot fetch remote images, so only inline ones are sent
+			var text []string
+			for _, part := range msg.Parts() {
+				switch {
+				case !part.IsImage():
+					text = append(text, part.Text)
+				case part.Data != "":
+					chatMsg.Images = append(chatMsg.Images, part.Data)
+				}
+			}
+			chatMsg.Content = strings.Join(text, "\n")
+		}
+
+		chatReq.Messages = append(chatReq.Messages, chatMsg)
+	}
+
+	for _, fn := range req.Functions {
+		chatReq.Tools = append(chatReq.Tools, ChatTool{Type: "function", Function: fn})
+	}
+	for _, tool := range req.Tools {
+		chatReq.Tools = append(chatReq.Tools, ChatTool{Type: "function", Function: tool.Function})
+	}
+
+	if req.ResponseFormat != nil {
+		switch {
+		case req.ResponseFormat.JSONSchema != nil && req.ResponseFormat.JSONSchema.Schema != nil:
+			chatReq.Format = req.ResponseFormat.JSONSchema.Schema
+		case req.ResponseFormat.Type == "json_object" || req.ResponseFormat.Type == "json_schema":
+			chatReq.Format = "json"
+		}
+	}
+
+	return chatReq
+}
+
+// options merges the connection's model options with the request's
+// sampling parameters
+func (p *Provider) options(req providers.CompletionRequest) map[string]interface{} {
+	options := make(map[string]interface{})
+	if extra, ok := p.config.Extra["options"].(map[string]interface{}); ok {
+		for k, v := range extra {
+			options[k] = v
+		}
+	}
+	if numCtx, ok := p.config.Extra["num_ctx"]; ok {
+		options["num_ctx"] = numCtx
+	}
+	if req.Temperature != nil {
+		options["temperature"] = *req.Temperature
+	}
+	if req.MaxTokens != nil {
+		options["num_predict"] = *req.MaxTokens
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+// numCtx returns the context window the connection sets through "num_ctx"
+// or its "options", or 0 for the server's default
+func (p *Provider) numCtx() int {
+	value := p.config.Extra["num_ctx"]
+	if value == nil {
+		if options, ok := p.config.Extra["options"].(map[string]interface{}); ok {
+			value = options["num_ctx"]
+		}
+	}
+	switch n := value.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	case string:
+		v, _ := strconv.Atoi(n)
+		return v
+	}
+	return 0
+}
+
+// convertResponse converts an /api/chat response to internal response
+func (p *Provider) convertResponse(resp *ChatResponse) *providers.CompletionResponse {
+	message := providers.Message{
+		Role:      "assistant",
+		Content:   resp.Message.Content,
+		ToolCalls: convertToolCalls(resp.Message.ToolCalls, 0),
+	}
+
+	return &providers.CompletionResponse{
+		ID:      fmt.Sprintf("ollama-%d", resp.CreatedAt.UnixNano()),
+		Object:  "chat.completion",
+		Created: resp.CreatedAt.Unix(),
+		Model:   resp.Model,
+		Choices: []providers.Choice{
+			{
+				Index:        0,
+				Message:      message,
+				FinishReason: finishReason(resp.DoneReason, len(message.ToolCalls) > 0),
+			},
+		},
+		Usage: *convertUsage(resp),
+	}
+}
+
+// convertUsage reads the token counts Ollama reports with a finished answer
+func convertUsage(resp *ChatResponse) *providers.Usage {
+	return &providers.Usage{
+		PromptTokens:     resp.PromptEvalCount,
+		CompletionTokens: resp.EvalCount,
+		TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+	}
+}
+
+// convertToolCalls converts Ollama tool calls, which carry no IDs, assigning
+// IDs from the calls' positions
+func convertToolCalls(calls []ChatToolCall, offset int) []providers.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	result := make([]providers.ToolCall, len(calls))
+	for i, call := range calls {
+		args := string(call.Function.Arguments)
+		if args == "" || args == "null" {
+			args = "{}"
+		}
+		result[i] = providers.ToolCall{
+			ID:   fmt.Sprintf("call_%s_%d", call.Function.Name, offset+i),
+			Type: "function",
+			Function: providers.FunctionCall{
+				Name:      call.Function.Name,
+				Arguments: args,
+			},
+		}
+	}
+	return result
+}
+
+// finishReason converts Ollama's done reason to OpenAI format
+func finishReason(reason string, toolCalls bool) string {
+	switch {
+	case toolCalls:
+		return "tool_calls"
+	case reason == "length":
+		return "length"
+	default:
+		return "stop"
+	}
+}
@@ -0,0 +1,179 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/config"
+	"github.com/agentx/agentx-backend/internal/providers"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestProvider points a provider at a local stand-in for Ollama
+func newTestProvider(t *testing.T, extra map[string]interface{}, handler http.HandlerFunc) *Provider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	p, err := NewProvider("ollama", config.ProviderConfig{
+		Type:    "ollama",
+		Name:    "ollama",
+		BaseURL: server.URL + "/v1",
+		Extra:   extra,
+	})
+	assert.NoError(t, err)
+	return p
+}
+
+func TestCompleteSendsOptionsAndReturnsToolCalls(t *testing.T) {
+	var got ChatRequest
+	p := newTestProvider(t, map[string]interface{}{"num_ctx": float64(16384), "keep_alive": "10m"}, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		fmt.Fprint(w, `{"model":"llama3.1:8b","created_at":"2024-07-01T10:00:00Z",
+			"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Paris"}}}]},
+			"done":true,"done_reason":"stop","prompt_eval_count":20,"eval_count":7}`)
+	})
+
+	temperature := float32(0.2)
+	resp, err := p.Complete(context.Background(), providers.CompletionRequest{
+		Model:       "llama3.1:8b",
+		Temperature: &temperature,
+		Messages: []providers.Message{
+			{Role: "user", Content: "Weather?", ContentArray: []interface{}{
+				map[string]interface{}{"type": "image_url", "image_url": map[string]string{"url": "data:image/png;base64,aGk="}},
+			}},
+			{Role: "assistant", ToolCalls: []providers.ToolCall{{
+				ID: "call_1", Type: "function",
+				Function: providers.FunctionCall{Name: "get_weather", Arguments: `{"city":"Rome"}`},
+			}}},
+			{Role: "tool", ToolCallID: "call_1", Content: "sunny"},
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.False(t, got.Stream)
+	assert.Equal(t, "10m", got.KeepAlive)
+	assert.Equal(t, float64(16384), got.Options["num_ctx"])
+	assert.InDelta(t, 0.2, got.Options["temperature"], 1e-6)
+	if assert.Len(t, got.Messages, 3) {
+		assert.Equal(t, []string{"aGk="}, got.Messages[0].Images)
+		assert.JSONEq(t, `{"city":"Rome"}`, string(got.Messages[1].ToolCalls[0].Function.Arguments))
+		assert.Equal(t, "get_weather", got.Messages[2].ToolName)
+	}
+
+	assert.Equal(t, "tool_calls", resp.Choices[0].FinishReason)
+	if assert.Len(t, resp.Choices[0].Message.ToolCalls, 1) {
+		assert.JSONEq(t, `{"city":"Paris"}`, resp.Choices[0].Message.ToolCalls[0].Function.Arguments)
+	}
+	assert.Equal(t, providers.Usage{PromptTokens: 20, CompletionTokens: 7, TotalTokens: 27}, resp.Usage)
+}
+
+func TestGetModelsReportsContextSizeAndQuantization(t *testing.T) {
+	shows := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			fmt.Fprint(w, `{"models":[{"name":"llama3.1:8b","size":4920753328,"digest":"abc",
+				"details":{"format":"gguf","family":"llama","parameter_size":"8.0B","quantization_level":"Q4_K_M"}}]}`)
+		case "/api/show":
+			shows++
+			fmt.Fprint(w, `{"details":{"family":"llama"},"capabilities":["completion","tools"],
+				"model_info":{"general.architecture":"llama","llama.context_length":131072}}`)
+		}
+	}
+	p := newTestProvider(t, nil, handler)
+
+	for i := 0; i < 2; i++ {
+		models, err := p.GetModels(context.Background())
+		assert.NoError(t, err)
+		if assert.Len(t, models, 1) {
+			assert.Equal(t, "llama3.1:8b", models[0].ID)
+			assert.Equal(t, 131072, models[0].Extra["context_length"])
+			assert.Equal(t, "8.0B", models[0].Extra["parameter_size"])
+			assert.Equal(t, "Q4_K_M", models[0].Extra["quantization_level"])
+		}
+	}
+	assert.Equal(t, 1, shows, "details are fetched once per model build")
+
+	// A smaller num_ctx is the context the model actually gets
+	p = newTestProvider(t, map[string]interface{}{"options": map[string]interface{}{"num_ctx": float64(8192)}}, handler)
+	models, err := p.GetModels(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, models, 1) {
+		assert.Equal(t, 8192, models[0].Extra["context_length"])
+	}
+}
+
+func TestStreamCompleteReportsUsage(t *testing.T) {
+	p := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Hi"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":3}`)
+	})
+
+	stream, err := p.StreamComplete(context.Background(), providers.CompletionRequest{Model: "llama3"})
+	assert.NoError(t, err)
+	var last providers.StreamChunk
+	for chunk := range stream {
+		last = chunk
+	}
+	assert.Equal(t, "stop", last.FinishReason)
+	assert.Equal(t, &providers.Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}, last.Usage)
+}
+
+func TestPullModelStreamsProgress(t *testing.T) {
+	p := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/pull", r.URL.Path)
+		fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+		fmt.Fprintln(w, `{"status":"downloading","digest":"sha256:1","total":100,"completed":50}`)
+		fmt.Fprintln(w, `{"status":"success"}`)
+	})
+
+	progress, err := p.PullModel(context.Background(), "llama3.1:8b")
+	assert.NoError(t, err)
+
+	var statuses []string
+	for event := range progress {
+		statuses = append(statuses, event.Status)
+	}
+	assert.Equal(t, []string{"pulling manifest", "downloading", "success"}, statuses)
+}
+
+func TestMissingModelMapsToModelNotFound(t *testing.T) {
+	p := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model 'nope' not found"}`)
+	})
+
+	err := p.DeleteModel(context.Background(), "nope")
+	assert.ErrorContains(t, err, "model_not_found")
+
+	// Other missing endpoints are not mistaken for a missing model
+	p = newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	_, err = p.GetModels(context.Background())
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "model_not_found")
+}
+
+func TestStreamOpenErrorKeepsStatusAndRetryHint(t *testing.T) {
+	p := newTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"error":"server busy"}`)
+	})
+
+	stream, err := p.StreamComplete(context.Background(), providers.CompletionRequest{Model: "llama3"})
+	assert.Nil(t, stream)
+	var providerErr *providers.ProviderError
+	if assert.ErrorAs(t, err, &providerErr) {
+		assert.Equal(t, http.StatusServiceUnavailable, providerErr.StatusCode)
+		assert.Equal(t, 3*time.Second, providerErr.RetryAfter)
+	}
+}
@@ -0,0 +1,345 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/config"
+	"github.com/agentx/agentx-backend/internal/providers"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// DefaultAzureAPIVersion is the Azure OpenAI API version used when the
+	// connection sets none
+	DefaultAzureAPIVersion = "2024-10-21"
+
+	// azureDeploymentsAPIVersion is the last data-plane version that lists
+	// deployments
+	azureDeploymentsAPIVersion = "2022-12-01"
+
+	// azureScope is the Entra ID scope of Azure OpenAI tokens
+	azureScope = "https://cognitiveservices.azure.com/.default"
+
+	// defaultAuthorityHost is the Entra ID authority of the public cloud
+	defaultAuthorityHost = "https://login.microsoftonline.com"
+)
+
+// azureSettings holds the Azure specifics of a connection
+type azureSettings struct {
+	endpoint    string
+	apiVersion  string
+	deployments map[string]string // Model name -> deployment name
+	auth        func(req *http.Request)
+	httpClient  *http.Client
+}
+
+// NewAzureProvider creates a provider for an Azure OpenAI resource.
+//
+// The connection's base_url is the resource endpoint, e.g.
+// https://my-resource.openai.azure.com. Its settings may hold
+// "deployments", an object mapping model names to deployment names
+// (unmapped models are used as deployment names), and "api_version". The
+// api_key is sent in the api-key header, unless "auth" is "entra": then
+// tenant_id, client_id and client_secret settings obtain Entra ID tokens,
+// or, without them, the api_key is used as a bearer token.
+func NewAzureProvider(id string, cfg config.ProviderConfig) (*Provider, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("Azure OpenAI endpoint (base_url) is required")
+	}
+
+	settings := &azureSettings{
+		endpoint:    strings.TrimRight(cfg.BaseURL, "/"),
+		apiVersion:  stringSetting(cfg.Extra, "api_version"),
+		deployments: make(map[string]string),
+	}
+	if settings.apiVersion == "" {
+		settings.apiVersion = DefaultAzureAPIVersion
+	}
+	if deployments, ok := cfg.Extra["deployments"].(map[string]interface{}); ok {
+		for model, deployment := range deployments {
+			if name, ok := deployment.(string); ok && name != "" {
+				settings.deployments[model] = name
+			}
+		}
+	}
+
+	var clientConfig openai.ClientConfig
+	transport := &providers.HeaderCaptureTransport{}
+	switch {
+	case stringSetting(cfg.Extra, "auth") != "entra":
+		if cfg.APIKey == "" {
+			return nil, errors.New("Azure OpenAI API key is required")
+		}
+		clientConfig = openai.DefaultAzureConfig(cfg.APIKey, settings.endpoint)
+		settings.auth = func(req *http.Request) { req.Header.Set(openai.AzureAPIKeyHeader, cfg.APIKey) }
+
+	case stringSetting(cfg.Extra, "client_id") != "":
+		source := &entraTokenSource{
+			authorityHost: stringSetting(cfg.Extra, "authority_host"),
+			tenantID:      stringSetting(cfg.Extra, "tenant_id"),
+			clientID:      stringSetting(cfg.Extra, "client_id"),
+			clientSecret:  stringSetting(cfg.Extra, "client_secret"),
+			client:        &http.Client{Timeout: 30 * time.Second},
+		}
+		if source.tenantID == "" || source.clientSecret == "" {
+			return nil, errors.New("Entra ID authentication requires tenant_id, client_id and client_secret")
+		}
+		clientConfig = openai.DefaultAzureConfig("", settings.endpoint)
+		clientConfig.APIType = openai.APITypeAzureAD
+		transport.Base = &entraTransport{source: source}
+		settings.auth = func(req *http.Request) {} // Set by the transport
+
+	default:
+		if cfg.APIKey == "" {
+			return nil, errors.New("Entra ID bearer token (api_key) is required")
+		}
+		clientConfig = openai.DefaultAzureConfig(cfg.APIKey, settings.endpoint)
+		clientConfig.APIType = openai.APITypeAzureAD
+		settings.auth = func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+cfg.APIKey) }
+	}
+
+	settings.httpClient = &http.Client{Transport: transport}
+	clientConfig.APIVersion = settings.apiVersion
+	clientConfig.AzureModelMapperFunc = settings.deployment
+	clientConfig.HTTPClient = settings.httpClient
+
+	return &Provider{
+		id:     id,
+		config: cfg,
+		client: openai.NewClientWithConfig(clientConfig),
+		azure:  settings,
+	}, nil
+}
+
+// deployment returns the deployment serving a model
+func (s *azureSettings) deployment(model string) string {
+	if deployment, ok := s.deployments[model]; ok {
+		return deployment
+	}
+	return model
+}
+
+// azureDeployment is one entry of the deployments listing
+type azureDeployment struct {
+	ID     string `json:"id"`
+	Model  string `json:"model"`
+	Status string `json:"status"`
+}
+
+// getAzureModels lists the resource's deployments as models. Models mapped
+// in the connection are listed under their model name so requests for them
+// route to the deployment. Resources whose API no longer lists deployments
+// fall back to the configured mapping.
+func (p *Provider) getAzureModels(ctx context.Context) ([]providers.Model, error) {
+	deployments, err := p.listAzureDeployments(ctx)
+	if err != nil {
+		if len(p.azure.deployments) == 0 {
+			return nil, err
+		}
+		log.Printf("[Azure OpenAI] Deployment discovery failed, using configured deployments: %v", err)
+		for model, deployment := range p.azure.deployments {
+			deployments = append(deployments, azureDeployment{ID: deployment, Model: model})
+		}
+		sort.Slice(deployments, func(i, j int) bool { return deployments[i].ID < deployments[j].ID })
+	}
+
+	modelFor := make(map[string]string, len(p.azure.deployments))
+	for model, deployment := range p.azure.deployments {
+		modelFor[deployment] = model
+	}
+
+	models := make([]providers.Model, 0, len(deployments))
+	for _, d := range deployments {
+		if d.Status != "" && d.Status != "succeeded" {
+			continue
+		}
+		id := d.ID
+		if model, ok := modelFor[d.ID]; ok {
+			id = model
+		}
+		models = append(models, providers.Model{
+			ID:      id,
+			Object:  "model",
+			OwnedBy: "azure-openai",
+			Extra: map[string]interface{}{
+				"deployment": d.ID,
+				"model":      d.Model,
+			},
+		})
+	}
+	return models, nil
+}
+
+// listAzureDeployments fetches the deployments of the resource
+func (p *Provider) listAzureDeployments(ctx context.Context) ([]azureDeployment, error) {
+	endpoint := fmt.Sprintf("%s/openai/deployments?api-version=%s", p.azure.endpoint, azureDeploymentsAPIVersion)
+	ctx, sink := providers.WithHeaderSink(ctx)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.azure.auth(req)
+
+	resp, err := p.azure.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error openai.APIError `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		apiErr := body.Error
+		apiErr.HTTPStatus = resp.Status
+		apiErr.HTTPStatusCode = resp.StatusCode
+		return nil, p.wrapError(&apiErr, sink)
+	}
+
+	var list struct {
+		Data []azureDeployment `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	return list.Data, nil
+}
+
+// contentFilterError explains a request Azure's content filter rejected.
+// The message leads with the content_filter code callers classify errors by.
+func contentFilterError(apiErr *openai.APIError) error {
+	if apiErr.Code != "content_filter" && (apiErr.InnerError == nil || apiErr.InnerError.Code != "ResponsibleAIPolicyViolation") {
+		return nil
+	}
+
+	var categories []string
+	if apiErr.InnerError != nil {
+		results := apiErr.InnerError.ContentFilterResults
+		for _, c := range []struct {
+			name     string
+			filtered bool
+		}{
+			{"hate", results.Hate.Filtered},
+			{"self_harm", results.SelfHarm.Filtered},
+			{"sexual", results.Sexual.Filtered},
+			{"violence", results.Violence.Filtered},
+			{"jailbreak", results.JailBreak.Filtered},
+			{"profanity", results.Profanity.Filtered},
+		} {
+			if c.filtered {
+				categories = append(categories, c.name)
+			}
+		}
+	}
+	if len(categories) == 0 {
+		return fmt.Errorf("content_filter: prompt blocked by the Azure content filter: %w", apiErr)
+	}
+	return fmt.Errorf("content_filter: prompt blocked by the Azure content filter (%s): %w", strings.Join(categories, ", "), apiErr)
+}
+
+// entraTokenSource obtains Entra ID tokens with the client credentials flow
+// and caches them until shortly before they expire
+type entraTokenSource struct {
+	authorityHost string
+	tenantID      string
+	clientID      string
+	clientSecret  string
+	client        *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Token returns a valid access token
+func (s *entraTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	authority := s.authorityHost
+	if authority == "" {
+		authority = defaultAuthorityHost
+	}
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {s.clientID},
+		"client_secret": {s.clientSecret},
+		"scope":         {azureScope},
+	}
+	endpoint := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimRight(authority, "/"), url.PathEscape(s.tenantID))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get Entra ID token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode Entra ID token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return "", providers.NewHTTPError("azure-openai", resp.StatusCode,
+			fmt.Errorf("invalid_api_key: Entra ID token request failed: %s %s", body.Error, body.ErrorDescription), resp.Header)
+	}
+
+	// Renew a minute early so in-flight requests never carry an expired token
+	s.token = body.AccessToken
+	s.expires = time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
+
+// entraTransport authorizes requests with Entra ID tokens
+type entraTransport struct {
+	base   http.RoundTripper
+	source *entraTokenSource
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *entraTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return base.RoundTrip(req)
+}
+
+// stringSetting reads a string from connection settings
+func stringSetting(settings map[string]interface{}, key string) string {
+	if v, ok := settings[key].(string); ok {
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agentx/agentx-backend/internal/config"
+	"github.com/agentx/agentx-backend/internal/providers"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAzureTestProvider(t *testing.T, extra map[string]interface{}, handler http.HandlerFunc) *Provider {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	p, err := NewAzureProvider("azure", config.ProviderConfig{
+		Type:    "azure-openai",
+		Name:    "azure",
+		BaseURL: server.URL,
+		APIKey:  "azure-key",
+		Extra:   extra,
+	})
+	assert.NoError(t, err)
+	return p
+}
+
+func TestAzureCompleteUsesMappedDeployment(t *testing.T) {
+	p := newAzureTestProvider(t, map[string]interface{}{
+		"deployments": map[string]interface{}{"gpt-4o": "prod-gpt4o"},
+		"api_version": "2024-06-01",
+	}, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/openai/deployments/prod-gpt4o/chat/completions", r.URL.Path)
+		assert.Equal(t, "2024-06-01", r.URL.Query().Get("api-version"))
+		assert.Equal(t, "azure-key", r.Header.Get("api-key"))
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"c1","model":"gpt-4o-2024-08-06","choices":[{"index":0,"message":{"role":"assistant","content":""},"finish_reason":"content_filter"}]}`)
+	})
+
+	resp, err := p.Complete(context.Background(), providers.CompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "Hi"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "content_filter", resp.Choices[0].FinishReason)
+}
+
+func TestAzurePromptFilterErrorCarriesCategories(t *testing.T) {
+	p := newAzureTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"code":"content_filter","message":"The response was filtered","status":400,
+			"innererror":{"code":"ResponsibleAIPolicyViolation","content_filter_result":{"violence":{"filtered":true,"severity":"high"}}}}}`)
+	})
+
+	_, err := p.Complete(context.Background(), providers.CompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "Hi"}},
+	})
+	assert.ErrorContains(t, err, "content_filter")
+	assert.ErrorContains(t, err, "violence")
+
+	providerErr, ok := err.(*providers.ProviderError)
+	if assert.True(t, ok) {
+		assert.Equal(t, "azure-openai", providerErr.Provider)
+		assert.Equal(t, http.StatusBadRequest, providerErr.StatusCode)
+	}
+}
+
+func TestAzureStreamFilterErrorCarriesStatus(t *testing.T) {
+	p := newAzureTestProvider(t, nil, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Once\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"error\":{\"code\":\"content_filter\",\"message\":\"The response was filtered\","+
+			"\"innererror\":{\"code\":\"ResponsibleAIPolicyViolation\",\"content_filter_result\":{\"violence\":{\"filtered\":true}}}}}\n\n")
+	})
+
+	chunks, err := p.StreamComplete(context.Background(), providers.CompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []providers.Message{{Role: "user", Content: "Hi"}},
+	})
+	assert.NoError(t, err)
+
+	var last providers.StreamChunk
+	for chunk := range chunks {
+		last = chunk
+	}
+	assert.Contains(t, last.Error, "violence")
+
+	var providerErr *providers.ProviderError
+	if assert.ErrorAs(t, last.Err, &providerErr) {
+		assert.Equal(t, "azure-openai", providerErr.Provider)
+		assert.Equal(t, http.StatusBadRequest, providerErr.StatusCode)
+	}
+}
+
+func TestAzureGetModelsListsDeployments(t *testing.T) {
+	p := newAzureTestProvider(t, map[string]interface{}{
+		"deployments": map[string]interface{}{"gpt-4o": "prod-gpt4o"},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/openai/deployments", r.URL.Path)
+		fmt.Fprint(w, `{"data":[
+			{"id":"prod-gpt4o","model":"gpt-4o","status":"succeeded"},
+			{"id":"embeddings","model":"text-embedding-3-small","status":"succeeded"},
+			{"id":"pending","model":"gpt-4","status":"running"}
+		]}`)
+	})
+
+	models, err := p.GetModels(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, models, 2) {
+		assert.Equal(t, "gpt-4o", models[0].ID)
+		assert.Equal(t, "prod-gpt4o", models[0].Extra["deployment"])
+		assert.Equal(t, "embeddings", models[1].ID)
+	}
+}
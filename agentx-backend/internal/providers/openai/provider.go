@@ -2,8 +2,10 @@ package openai
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
+	"net/http"
 
 	"github.com/sashabaranov/go-openai"
 	"github.com/agentx/agentx-backend/internal/config"
@@ -15,6 +17,7 @@ type Provider struct {
 	id     string
 	config config.ProviderConfig
 	client *openai.Client
+	azure  *azureSettings // Set for Azure OpenAI connections
 }
 
 // NewProvider creates a new OpenAI provider
@@ -23,7 +26,9 @@ func NewProvider(id string, cfg config.ProviderConfig) (*Provider, error) {
 		return nil, errors.New("OpenAI API key is required")
 	}
 
-	client := openai.NewClient(cfg.APIKey)
+	clientConfig := openai.DefaultConfig(cfg.APIKey)
+	clientConfig.HTTPClient = &http.Client{Transport: &providers.HeaderCaptureTransport{}}
+	client := openai.NewClientWithConfig(clientConfig)
 	
 	return &Provider{
 		id:     id,
@@ -42,9 +47,10 @@ func (p *Provider) Complete(ctx context.Context, req providers.CompletionRequest
 	openAIReq := p.convertRequest(req)
 	openAIReq.Stream = false
 
+	ctx, sink := providers.WithHeaderSink(ctx)
 	resp, err := p.client.CreateChatCompletion(ctx, openAIReq)
 	if err != nil {
-		return nil, err
+		return nil, p.wrapError(err, sink)
 	}
 
 	return p.convertResponse(&resp), nil
@@ -52,29 +58,35 @@ func (p *Provider) Complete(ctx context.Context, req providers.CompletionRequest
 
 // StreamComplete performs a streaming completion
 func (p *Provider) StreamComplete(ctx context.Context, req providers.CompletionRequest) (<-chan providers.StreamChunk, error) {
-	chunks := make(chan providers.StreamChunk)
+	openAIReq := p.convertRequest(req)
+	openAIReq.Stream = true
 	
+	// Failing to open the stream is reported like a failed completion, with
+	// the status and rate-limit hints retries depend on
+	ctx, sink := providers.WithHeaderSink(ctx)
+	stream, err := p.client.CreateChatCompletionStream(ctx, openAIReq)
+	if err != nil {
+		return nil, p.wrapError(err, sink)
+	}
+	
+	chunks := make(chan providers.StreamChunk)
 	go func() {
 		defer close(chunks)
-		
-		openAIReq := p.convertRequest(req)
-		openAIReq.Stream = true
-		
-		stream, err := p.client.CreateChatCompletionStream(ctx, openAIReq)
-		if err != nil {
-			chunks <- providers.StreamChunk{Error: err.Error()}
-			return
-		}
 		defer stream.Close()
 		
+		// Repeat the reason the stream ended with, so a content filter or
+		// tool call is not reported as a plain stop
+		finishReason := "stop"
 		for {
 			response, err := stream.Recv()
 			if errors.Is(err, io.EOF) {
-				chunks <- providers.StreamChunk{FinishReason: "stop"}
+				chunks <- providers.StreamChunk{FinishReason: finishReason}
 				return
 			}
 			if err != nil {
-				chunks <- providers.StreamChunk{Error: err.Error()}
+				// Keep the status and filter details of errors raised mid-stream
+				err = p.wrapError(err, sink)
+				chunks <- providers.StreamChunk{Error: err.Error(), Err: err}
 				return
 			}
 			
@@ -108,6 +120,7 @@ func (p *Provider) StreamComplete(ctx context.Context, req providers.CompletionR
 				
 				if choice.FinishReason != "" {
 					chunk.FinishReason = string(choice.FinishReason)
+					finishReason = chunk.FinishReason
 				}
 				
 				chunks <- chunk
@@ -120,6 +133,10 @@ func (p *Provider) StreamComplete(ctx context.Context, req providers.CompletionR
 
 // GetModels returns available models
 func (p *Provider) GetModels(ctx context.Context) ([]providers.Model, error) {
+	if p.azure != nil {
+		return p.getAzureModels(ctx)
+	}
+
 	modelList, err := p.client.ListModels(ctx)
 	if err != nil {
 		return nil, err
@@ -138,6 +155,29 @@ func (p *Provider) GetModels(ctx context.Context) ([]providers.Model, error) {
 	return models, nil
 }
 
+// DefaultEmbeddingModel is used when an embedding request names no model
+const DefaultEmbeddingModel = "text-embedding-3-small"
+
+// Embed embeds a batch of texts
+func (p *Provider) Embed(ctx context.Context, req providers.EmbeddingRequest) (*providers.EmbeddingResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = DefaultEmbeddingModel
+	}
+
+	ctx, sink := providers.WithHeaderSink(ctx)
+	resp, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input:      req.Input,
+		Model:      openai.EmbeddingModel(model),
+		Dimensions: req.Dimensions,
+	})
+	if err != nil {
+		return nil, p.wrapError(err, sink)
+	}
+
+	return convertEmbeddings(resp, len(req.Input)), nil
+}
+
 // ValidateConfig validates the provider configuration
 func (p *Provider) ValidateConfig() error {
 	if p.config.APIKey == "" {
@@ -154,6 +194,11 @@ func (p *Provider) convertRequest(req providers.CompletionRequest) openai.ChatCo
 			Role:    msg.Role,
 			Content: msg.Content,
 		}
+		if len(msg.ContentArray) > 0 {
+			// Content and MultiContent are mutually exclusive
+			messages[i].Content = ""
+			messages[i].MultiContent = msg.OpenAIParts()
+		}
 		
 		if msg.FunctionCall != nil {
 			messages[i].FunctionCall = &openai.FunctionCall{
@@ -243,9 +288,31 @@ func (p *Provider) convertRequest(req providers.CompletionRequest) openai.ChatCo
 		}
 	}
 
+	// Convert response format
+	if req.ResponseFormat != nil {
+		openAIReq.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatType(req.ResponseFormat.Type),
+		}
+		if schema := req.ResponseFormat.JSONSchema; schema != nil {
+			openAIReq.ResponseFormat.JSONSchema = &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:        schema.Name,
+				Description: schema.Description,
+				Schema:      jsonSchema(schema.Schema),
+				Strict:      schema.Strict,
+			}
+		}
+	}
+
 	return openAIReq
 }
 
+// jsonSchema passes a decoded JSON Schema through to the API unchanged
+type jsonSchema map[string]interface{}
+
+func (s jsonSchema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}(s))
+}
+
 // convertResponse converts OpenAI response to internal response
 func (p *Provider) convertResponse(resp *openai.ChatCompletionResponse) *providers.CompletionResponse {
 	choices := make([]providers.Choice, len(resp.Choices))
@@ -299,7 +366,71 @@ func (p *Provider) convertToolCalls(toolCalls []openai.ToolCall) []providers.Too
 				Name:      tc.Function.Name,
 				Arguments: tc.Function.Arguments,
 			},
+			Index: tc.Index,
 		}
 	}
 	return result
-}
\ No newline at end of file
+}
+// wrapError attaches the HTTP status and rate-limit hints to an OpenAI error
+func (p *Provider) wrapError(err error, sink *providers.HeaderSink) error {
+	provider := "openai"
+	if p.azure != nil {
+		provider = "azure-openai"
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		status := apiErr.HTTPStatusCode
+		if status == 0 {
+			// Errors sent as stream events arrive after a 200 response
+			status = streamErrorStatus(apiErr)
+		}
+		if p.azure != nil {
+			if filterErr := contentFilterError(apiErr); filterErr != nil {
+				err = filterErr
+			}
+		}
+		if status > 0 {
+			return providers.NewHTTPError(provider, status, err, sink.Header())
+		}
+		return err
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) && reqErr.HTTPStatusCode > 0 {
+		return providers.NewHTTPError(provider, reqErr.HTTPStatusCode, err, sink.Header())
+	}
+	return err
+}
+
+// streamErrorStatus returns the HTTP status the API uses for an error that
+// was delivered as a stream event, or 0 when the error type is unknown
+func streamErrorStatus(apiErr *openai.APIError) int {
+	code, _ := apiErr.Code.(string)
+	switch {
+	case code == "content_filter":
+		return http.StatusBadRequest
+	case code == "rate_limit_exceeded" || apiErr.Type == "rate_limit_error":
+		return http.StatusTooManyRequests
+	case apiErr.Type == "server_error":
+		return http.StatusInternalServerError
+	}
+	return 0
+}
+
+// convertEmbeddings orders the vectors of an embeddings response by input
+func convertEmbeddings(resp openai.EmbeddingResponse, inputs int) *providers.EmbeddingResponse {
+	embeddings := make([][]float32, inputs)
+	for _, e := range resp.Data {
+		if e.Index >= 0 && e.Index < inputs {
+			embeddings[e.Index] = e.Embedding
+		}
+	}
+	return &providers.EmbeddingResponse{
+		Model:      string(resp.Model),
+		Embeddings: embeddings,
+		Usage: providers.Usage{
+			PromptTokens: resp.Usage.PromptTokens,
+			TotalTokens:  resp.Usage.TotalTokens,
+		},
+	}
+}
@@ -0,0 +1,328 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// BatchRepository handles database operations for batch jobs and their items
+type BatchRepository struct {
+	db *sqlx.DB
+}
+
+// NewBatchRepository creates a new BatchRepository
+func NewBatchRepository(db *sqlx.DB) *BatchRepository {
+	return &BatchRepository{db: db}
+}
+
+const batchJobColumns = `id, user_id, name, status, concurrency, max_attempts, total_items,
+		       created_at, updated_at, started_at, completed_at`
+
+const batchItemColumns = `id, job_id, line, custom_id, request, status, attempts, response, error,
+		       created_at, updated_at, completed_at`
+
+// CreateJob creates a job together with its items
+func (r *BatchRepository) CreateJob(ctx context.Context, job *models.BatchJob, items []models.BatchItem) error {
+	if job.ID == uuid.Nil {
+		job.ID = uuid.New()
+	}
+	job.TotalItems = len(items)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create batch job: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO batch_jobs (id, user_id, name, status, concurrency, max_attempts, total_items)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at, updated_at`
+
+	err = tx.QueryRowContext(
+		ctx, query,
+		job.ID, job.UserID, job.Name, job.Status, job.Concurrency, job.MaxAttempts, job.TotalItems,
+	).Scan(&job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create batch job: %w", err)
+	}
+
+	stmt, err := tx.PreparexContext(ctx, `
+		INSERT INTO batch_items (id, job_id, line, custom_id, request, status)
+		VALUES ($1, $2, $3, $4, $5, $6)`)
+	if err != nil {
+		return fmt.Errorf("failed to create batch items: %w", err)
+	}
+	defer stmt.Close()
+
+	for i := range items {
+		item := &items[i]
+		if item.ID == uuid.Nil {
+			item.ID = uuid.New()
+		}
+		item.JobID = job.ID
+		item.Status = models.BatchItemPending
+		if _, err := stmt.ExecContext(ctx, item.ID, item.JobID, item.Line, item.CustomID, item.Request, item.Status); err != nil {
+			return fmt.Errorf("failed to create batch item %d: %w", item.Line, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to create batch job: %w", err)
+	}
+	return nil
+}
+
+// GetJob retrieves a job by ID, returning nil if it does not exist
+func (r *BatchRepository) GetJob(ctx context.Context, jobID uuid.UUID) (*models.BatchJob, error) {
+	job := &models.BatchJob{}
+	query := `SELECT ` + batchJobColumns + ` FROM batch_jobs WHERE id = $1`
+
+	err := r.db.GetContext(ctx, job, query, jobID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch job: %w", err)
+	}
+
+	return job, nil
+}
+
+// ListJobs retrieves a user's jobs, newest first
+func (r *BatchRepository) ListJobs(ctx context.Context, userID uuid.UUID) ([]models.BatchJob, error) {
+	query := `SELECT ` + batchJobColumns + ` FROM batch_jobs WHERE user_id = $1 ORDER BY created_at DESC`
+
+	jobs := []models.BatchJob{}
+	if err := r.db.SelectContext(ctx, &jobs, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to list batch jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// ListUnfinished retrieves the jobs that are queued or were running, oldest
+// first, so they can be resumed
+func (r *BatchRepository) ListUnfinished(ctx context.Context) ([]models.BatchJob, error) {
+	query := `
+		SELECT ` + batchJobColumns + `
+		FROM batch_jobs
+		WHERE status IN ('queued', 'running')
+		ORDER BY created_at ASC`
+
+	jobs := []models.BatchJob{}
+	if err := r.db.SelectContext(ctx, &jobs, query); err != nil {
+		return nil, fmt.Errorf("failed to list unfinished batch jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// Progress counts a job's items by status
+func (r *BatchRepository) Progress(ctx context.Context, jobID uuid.UUID) (models.BatchProgress, error) {
+	var progress models.BatchProgress
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE status = 'pending') AS pending,
+			COUNT(*) FILTER (WHERE status = 'running') AS running,
+			COUNT(*) FILTER (WHERE status = 'succeeded') AS succeeded,
+			COUNT(*) FILTER (WHERE status = 'failed') AS failed,
+			COUNT(*) FILTER (WHERE status = 'cancelled') AS cancelled
+		FROM batch_items
+		WHERE job_id = $1`
+
+	err := r.db.QueryRowContext(ctx, query, jobID).Scan(
+		&progress.Pending, &progress.Running, &progress.Succeeded, &progress.Failed, &progress.Cancelled,
+	)
+	if err != nil {
+		return progress, fmt.Errorf("failed to count batch items: %w", err)
+	}
+
+	return progress, nil
+}
+
+// ListItems retrieves a page of a job's items in line order, optionally only
+// those with a status
+func (r *BatchRepository) ListItems(ctx context.Context, jobID uuid.UUID, status string, offset, limit int) ([]models.BatchItem, error) {
+	query := `SELECT ` + batchItemColumns + ` FROM batch_items WHERE job_id = $1`
+	args := []interface{}{jobID}
+	if status != "" {
+		query += ` AND status = $2`
+		args = append(args, status)
+	}
+	query += fmt.Sprintf(` ORDER BY line ASC OFFSET $%d`, len(args)+1)
+	args = append(args, offset)
+	if limit > 0 {
+		query += fmt.Sprintf(` LIMIT $%d`, len(args)+1)
+		args = append(args, limit)
+	}
+
+	items := []models.BatchItem{}
+	if err := r.db.SelectContext(ctx, &items, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list batch items: %w", err)
+	}
+
+	return items, nil
+}
+
+// SetJobStatus moves a job to a status, recording when it started and finished
+func (r *BatchRepository) SetJobStatus(ctx context.Context, jobID uuid.UUID, status string) error {
+	query := `
+		UPDATE batch_jobs
+		SET status = $2::varchar,
+		    started_at = CASE WHEN $2::varchar = 'running' THEN COALESCE(started_at, NOW()) ELSE started_at END,
+		    completed_at = CASE WHEN $2::varchar IN ('completed', 'cancelled') THEN NOW() ELSE NULL END
+		WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, jobID, status); err != nil {
+		return fmt.Errorf("failed to update batch job: %w", err)
+	}
+	return nil
+}
+
+// ClaimItems leases up to limit of a job's items routed to a connection to
+// an owner and counts an attempt for each: pending items whose retry time has
+// come, and running items whose lease expired because the instance running
+// them stopped. Rows another claim holds are skipped, so concurrent claims
+// never return the same item. Automatically routed items have connection "".
+func (r *BatchRepository) ClaimItems(ctx context.Context, jobID uuid.UUID, connectionID, owner string, lease time.Duration, limit int) ([]models.BatchItem, error) {
+	query := `
+		UPDATE batch_items
+		SET status = 'running', attempts = attempts + 1, retry_at = NULL,
+		    lease_owner = $3, lease_expires_at = NOW() + make_interval(secs => $4)
+		WHERE id IN (
+			SELECT id FROM batch_items
+			WHERE job_id = $1 AND COALESCE(request->>'connection_id', '') = $2
+			  AND ` + claimableBatchItem + `
+			ORDER BY line ASC
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + batchItemColumns
+
+	items := []models.BatchItem{}
+	if err := r.db.SelectContext(ctx, &items, query, jobID, connectionID, owner, lease.Seconds(), limit); err != nil {
+		return nil, fmt.Errorf("failed to claim batch items: %w", err)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Line < items[j].Line })
+
+	return items, nil
+}
+
+// claimableBatchItem matches the items a claim may take
+const claimableBatchItem = `((status = 'pending' AND (retry_at IS NULL OR retry_at <= NOW()))
+			       OR (status = 'running' AND lease_expires_at < NOW()))`
+
+// ClaimableConnections returns the connections a job has claimable items
+// routed to
+func (r *BatchRepository) ClaimableConnections(ctx context.Context, jobID uuid.UUID) ([]string, error) {
+	query := `
+		SELECT DISTINCT COALESCE(request->>'connection_id', '')
+		FROM batch_items
+		WHERE job_id = $1 AND ` + claimableBatchItem
+
+	connections := []string{}
+	if err := r.db.SelectContext(ctx, &connections, query, jobID); err != nil {
+		return nil, fmt.Errorf("failed to list batch connections: %w", err)
+	}
+	return connections, nil
+}
+
+// ExtendLeases renews the leases an owner holds on a job's running items
+func (r *BatchRepository) ExtendLeases(ctx context.Context, jobID uuid.UUID, owner string, lease time.Duration) error {
+	query := `
+		UPDATE batch_items
+		SET lease_expires_at = NOW() + make_interval(secs => $3)
+		WHERE job_id = $1 AND lease_owner = $2 AND status = 'running'`
+
+	if _, err := r.db.ExecContext(ctx, query, jobID, owner, lease.Seconds()); err != nil {
+		return fmt.Errorf("failed to extend batch item leases: %w", err)
+	}
+	return nil
+}
+
+// FinishItem records the outcome of an item's last attempt, unless the
+// owner's lease on it was lost
+func (r *BatchRepository) FinishItem(ctx context.Context, itemID uuid.UUID, owner, status string, response []byte, errMsg string) error {
+	query := `
+		UPDATE batch_items
+		SET status = $3, response = $4, error = $5, completed_at = NOW(),
+		    lease_owner = '', lease_expires_at = NULL
+		WHERE id = $1 AND lease_owner = $2`
+
+	if _, err := r.db.ExecContext(ctx, query, itemID, owner, status, response, errMsg); err != nil {
+		return fmt.Errorf("failed to update batch item: %w", err)
+	}
+	return nil
+}
+
+// RequeueItem returns an item the owner holds to pending for another
+// attempt no earlier than retryAt, keeping the error of the last one
+func (r *BatchRepository) RequeueItem(ctx context.Context, itemID uuid.UUID, owner, errMsg string, retryAt time.Time) error {
+	query := `
+		UPDATE batch_items
+		SET status = 'pending', error = $3, retry_at = $4, lease_owner = '', lease_expires_at = NULL
+		WHERE id = $1 AND lease_owner = $2`
+
+	if _, err := r.db.ExecContext(ctx, query, itemID, owner, errMsg, retryAt); err != nil {
+		return fmt.Errorf("failed to requeue batch item: %w", err)
+	}
+	return nil
+}
+
+// CompleteJob marks a running job completed. Jobs cancelled or requeued
+// meanwhile keep their status; it reports whether the job was completed.
+func (r *BatchRepository) CompleteJob(ctx context.Context, jobID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE batch_jobs
+		SET status = 'completed', completed_at = NOW()
+		WHERE id = $1 AND status = 'running'`
+
+	result, err := r.db.ExecContext(ctx, query, jobID)
+	if err != nil {
+		return false, fmt.Errorf("failed to complete batch job: %w", err)
+	}
+	completed, _ := result.RowsAffected()
+	return completed > 0, nil
+}
+
+// CancelJob cancels a job and its unfinished items
+func (r *BatchRepository) CancelJob(ctx context.Context, jobID uuid.UUID) error {
+	query := `
+		UPDATE batch_items
+		SET status = 'cancelled', completed_at = NOW(), lease_owner = '', lease_expires_at = NULL
+		WHERE job_id = $1 AND status IN ('pending', 'running')`
+
+	if _, err := r.db.ExecContext(ctx, query, jobID); err != nil {
+		return fmt.Errorf("failed to cancel batch items: %w", err)
+	}
+	return r.SetJobStatus(ctx, jobID, models.BatchJobCancelled)
+}
+
+// RequeueFailed returns a job's failed and cancelled items to pending with a
+// fresh attempt count and queues the job again. It returns how many items
+// were requeued.
+func (r *BatchRepository) RequeueFailed(ctx context.Context, jobID uuid.UUID) (int64, error) {
+	query := `
+		UPDATE batch_items
+		SET status = 'pending', attempts = 0, error = '', response = NULL, completed_at = NULL, retry_at = NULL
+		WHERE job_id = $1 AND status IN ('failed', 'cancelled')`
+
+	result, err := r.db.ExecContext(ctx, query, jobID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue batch items: %w", err)
+	}
+	requeued, _ := result.RowsAffected()
+	if requeued == 0 {
+		return 0, nil
+	}
+
+	return requeued, r.SetJobStatus(ctx, jobID, models.BatchJobQueued)
+}
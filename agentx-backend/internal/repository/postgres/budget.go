@@ -0,0 +1,203 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// BudgetRepository handles database operations for budgets
+type BudgetRepository struct {
+	db *sqlx.DB
+}
+
+// NewBudgetRepository creates a new BudgetRepository
+func NewBudgetRepository(db *sqlx.DB) *BudgetRepository {
+	return &BudgetRepository{db: db}
+}
+
+const budgetColumns = `id, user_id, scope, scope_id, period, spend_limit, warn_threshold,
+		       tokens_per_minute, action, downgrade_model, enabled, created_at, updated_at`
+
+// budgetScopeColumns maps budget scopes to the usage ledger column they cap
+var budgetScopeColumns = map[string]string{
+	"user":       "user_id",
+	"api_key":    "api_key_id",
+	"connection": "connection_id",
+}
+
+// Create creates a new budget
+func (r *BudgetRepository) Create(ctx context.Context, budget *models.Budget) error {
+	if budget.ID == uuid.Nil {
+		budget.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO budgets (
+			id, user_id, scope, scope_id, period, spend_limit, warn_threshold,
+			tokens_per_minute, action, downgrade_model, enabled
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+		) RETURNING created_at, updated_at`
+
+	err := r.db.QueryRowContext(
+		ctx, query,
+		budget.ID, budget.UserID, budget.Scope, budget.ScopeID, budget.Period, budget.SpendLimit,
+		budget.WarnThreshold, budget.TokensPerMinute, budget.Action, budget.DowngradeModel, budget.Enabled,
+	).Scan(&budget.CreatedAt, &budget.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create budget: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves a budget by ID
+func (r *BudgetRepository) Get(ctx context.Context, budgetID uuid.UUID) (*models.Budget, error) {
+	budget := &models.Budget{}
+	query := `SELECT ` + budgetColumns + ` FROM budgets WHERE id = $1`
+
+	err := r.db.GetContext(ctx, budget, query, budgetID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("budget not found")
+		}
+		return nil, fmt.Errorf("failed to get budget: %w", err)
+	}
+
+	return budget, nil
+}
+
+// List retrieves all budgets, optionally only those owned by a user
+func (r *BudgetRepository) List(ctx context.Context, userID *uuid.UUID) ([]models.Budget, error) {
+	query := `SELECT ` + budgetColumns + ` FROM budgets`
+	args := []interface{}{}
+	if userID != nil {
+		query += ` WHERE user_id = $1`
+		args = append(args, *userID)
+	}
+	query += ` ORDER BY created_at ASC`
+
+	budgets := []models.Budget{}
+	if err := r.db.SelectContext(ctx, &budgets, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list budgets: %w", err)
+	}
+
+	return budgets, nil
+}
+
+// ListApplicable retrieves the enabled budgets on a user and, when given, an
+// API key and a connection
+func (r *BudgetRepository) ListApplicable(ctx context.Context, userID uuid.UUID, apiKeyID, connectionID *uuid.UUID) ([]models.Budget, error) {
+	query := `
+		SELECT ` + budgetColumns + `
+		FROM budgets
+		WHERE enabled = true AND (
+			(scope = 'user' AND scope_id = $1) OR
+			(scope = 'api_key' AND scope_id = $2) OR
+			(scope = 'connection' AND scope_id = $3)
+		)
+		ORDER BY created_at ASC`
+
+	budgets := []models.Budget{}
+	err := r.db.SelectContext(ctx, &budgets, query, userID, apiKeyID, connectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applicable budgets: %w", err)
+	}
+
+	return budgets, nil
+}
+
+// Update saves all editable fields of a budget
+func (r *BudgetRepository) Update(ctx context.Context, budget *models.Budget) error {
+	query := `
+		UPDATE budgets
+		SET period = $2, spend_limit = $3, warn_threshold = $4, tokens_per_minute = $5,
+		    action = $6, downgrade_model = $7, enabled = $8
+		WHERE id = $1
+		RETURNING updated_at`
+
+	err := r.db.QueryRowContext(
+		ctx, query,
+		budget.ID, budget.Period, budget.SpendLimit, budget.WarnThreshold, budget.TokensPerMinute,
+		budget.Action, budget.DowngradeModel, budget.Enabled,
+	).Scan(&budget.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("budget not found")
+		}
+		return fmt.Errorf("failed to update budget: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes a budget
+func (r *BudgetRepository) Delete(ctx context.Context, budgetID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM budgets WHERE id = $1`, budgetID)
+	if err != nil {
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("budget not found")
+	}
+
+	return nil
+}
+
+// SpendSince sums the cost recorded in the usage ledger against a scope
+// since the given time
+func (r *BudgetRepository) SpendSince(ctx context.Context, scope string, scopeID uuid.UUID, since time.Time) (float64, error) {
+	column, ok := budgetScopeColumns[scope]
+	if !ok {
+		return 0, fmt.Errorf("invalid budget scope: %s", scope)
+	}
+
+	query := `
+		SELECT COALESCE(SUM(cost), 0)
+		FROM usage_records
+		WHERE ` + column + ` = $1 AND created_at >= $2`
+
+	var spent float64
+	if err := r.db.GetContext(ctx, &spent, query, scopeID, since); err != nil {
+		return 0, fmt.Errorf("failed to sum spend: %w", err)
+	}
+
+	return spent, nil
+}
+
+// ScopeOwner returns the user that owns the user, API key or connection a
+// budget caps
+func (r *BudgetRepository) ScopeOwner(ctx context.Context, scope string, scopeID uuid.UUID) (uuid.UUID, error) {
+	var query string
+	switch scope {
+	case "user":
+		query = `SELECT id FROM users WHERE id = $1`
+	case "api_key":
+		query = `SELECT user_id FROM api_keys WHERE id = $1`
+	case "connection":
+		query = `SELECT user_id FROM provider_connections WHERE id = $1`
+	default:
+		return uuid.Nil, fmt.Errorf("invalid budget scope: %s", scope)
+	}
+
+	var owner uuid.UUID
+	if err := r.db.GetContext(ctx, &owner, query, scopeID); err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, fmt.Errorf("%s not found", scope)
+		}
+		return uuid.Nil, fmt.Errorf("failed to get budget owner: %w", err)
+	}
+
+	return owner, nil
+}
@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// PromptRepository handles database operations for prompt templates
+type PromptRepository struct {
+	db *sqlx.DB
+}
+
+// NewPromptRepository creates a new PromptRepository
+func NewPromptRepository(db *sqlx.DB) *PromptRepository {
+	return &PromptRepository{db: db}
+}
+
+const promptColumns = `id, user_id, name, version, description, system_template, user_template,
+		       is_default, created_at, updated_at`
+
+// Create stores a new template version. If it is the default, the owner's
+// previous default for the name is unset.
+func (r *PromptRepository) Create(ctx context.Context, tmpl *models.PromptTemplate) error {
+	if tmpl.ID == uuid.Nil {
+		tmpl.ID = uuid.New()
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create prompt template: %w", err)
+	}
+	defer tx.Rollback()
+
+	if tmpl.IsDefault {
+		if err := clearPromptDefault(ctx, tx, tmpl.UserID, tmpl.Name); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO prompt_templates (
+			id, user_id, name, version, description, system_template, user_template, is_default
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8
+		) RETURNING created_at, updated_at`
+
+	err = tx.QueryRowContext(
+		ctx, query,
+		tmpl.ID, tmpl.UserID, tmpl.Name, tmpl.Version, tmpl.Description,
+		tmpl.SystemTemplate, tmpl.UserTemplate, tmpl.IsDefault,
+	).Scan(&tmpl.CreatedAt, &tmpl.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create prompt template: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to create prompt template: %w", err)
+	}
+	return nil
+}
+
+// Get retrieves a template version by ID, returning nil if it does not exist
+func (r *PromptRepository) Get(ctx context.Context, id uuid.UUID) (*models.PromptTemplate, error) {
+	tmpl := &models.PromptTemplate{}
+	query := `SELECT ` + promptColumns + ` FROM prompt_templates WHERE id = $1`
+
+	err := r.db.GetContext(ctx, tmpl, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// ListVisible retrieves the global templates and a user's own, optionally
+// only those with a name
+func (r *PromptRepository) ListVisible(ctx context.Context, userID uuid.UUID, name string) ([]models.PromptTemplate, error) {
+	query := `SELECT ` + promptColumns + ` FROM prompt_templates WHERE (user_id IS NULL OR user_id = $1)`
+	args := []interface{}{userID}
+	if name != "" {
+		query += ` AND name = $2`
+		args = append(args, name)
+	}
+	query += ` ORDER BY name ASC, user_id NULLS FIRST, created_at ASC`
+
+	templates := []models.PromptTemplate{}
+	if err := r.db.SelectContext(ctx, &templates, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+
+	return templates, nil
+}
+
+// ListVersions retrieves an owner's versions of a template; a nil owner
+// lists the global versions
+func (r *PromptRepository) ListVersions(ctx context.Context, userID *uuid.UUID, name string) ([]models.PromptTemplate, error) {
+	query := `
+		SELECT ` + promptColumns + `
+		FROM prompt_templates
+		WHERE user_id IS NOT DISTINCT FROM $1 AND name = $2
+		ORDER BY created_at ASC`
+
+	templates := []models.PromptTemplate{}
+	if err := r.db.SelectContext(ctx, &templates, query, userID, name); err != nil {
+		return nil, fmt.Errorf("failed to list prompt template versions: %w", err)
+	}
+
+	return templates, nil
+}
+
+// GetDefault retrieves an owner's default version of a template, returning
+// nil if there is none
+func (r *PromptRepository) GetDefault(ctx context.Context, userID *uuid.UUID, name string) (*models.PromptTemplate, error) {
+	tmpl := &models.PromptTemplate{}
+	query := `
+		SELECT ` + promptColumns + `
+		FROM prompt_templates
+		WHERE user_id IS NOT DISTINCT FROM $1 AND name = $2 AND is_default`
+
+	err := r.db.GetContext(ctx, tmpl, query, userID, name)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get default prompt template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// FindVersion retrieves an owner's version of a template, returning nil if
+// it does not exist
+func (r *PromptRepository) FindVersion(ctx context.Context, userID *uuid.UUID, name, version string) (*models.PromptTemplate, error) {
+	tmpl := &models.PromptTemplate{}
+	query := `
+		SELECT ` + promptColumns + `
+		FROM prompt_templates
+		WHERE user_id IS NOT DISTINCT FROM $1 AND name = $2 AND version = $3`
+
+	err := r.db.GetContext(ctx, tmpl, query, userID, name, version)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt template version: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// Update updates a template version's description and default flag. If it
+// becomes the default, the owner's previous default for the name is unset.
+func (r *PromptRepository) Update(ctx context.Context, tmpl *models.PromptTemplate) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update prompt template: %w", err)
+	}
+	defer tx.Rollback()
+
+	if tmpl.IsDefault {
+		if err := clearPromptDefault(ctx, tx, tmpl.UserID, tmpl.Name); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		UPDATE prompt_templates
+		SET description = $2, is_default = $3
+		WHERE id = $1
+		RETURNING updated_at`
+
+	if err := tx.QueryRowContext(ctx, query, tmpl.ID, tmpl.Description, tmpl.IsDefault).Scan(&tmpl.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to update prompt template: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update prompt template: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes a template version
+func (r *PromptRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM prompt_templates WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete prompt template: %w", err)
+	}
+	return nil
+}
+
+// clearPromptDefault unsets an owner's default version of a template
+func clearPromptDefault(ctx context.Context, tx *sqlx.Tx, userID *uuid.UUID, name string) error {
+	query := `
+		UPDATE prompt_templates
+		SET is_default = false
+		WHERE user_id IS NOT DISTINCT FROM $1 AND name = $2 AND is_default`
+
+	if _, err := tx.ExecContext(ctx, query, userID, name); err != nil {
+		return fmt.Errorf("failed to clear default prompt template: %w", err)
+	}
+	return nil
+}
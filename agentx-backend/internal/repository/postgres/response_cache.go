@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// ResponseCacheRepository handles database operations for cached LLM
+// responses
+type ResponseCacheRepository struct {
+	db *sqlx.DB
+}
+
+// NewResponseCacheRepository creates a new ResponseCacheRepository
+func NewResponseCacheRepository(db *sqlx.DB) *ResponseCacheRepository {
+	return &ResponseCacheRepository{db: db}
+}
+
+// Get retrieves an unexpired entry by key, or nil if there is none
+func (r *ResponseCacheRepository) Get(ctx context.Context, key string) (*models.ResponseCacheEntry, error) {
+	var entry models.ResponseCacheEntry
+	query := `
+		SELECT cache_key, user_id, scope, embedding, response, expires_at, created_at
+		FROM response_cache
+		WHERE cache_key = $1 AND expires_at > NOW()`
+
+	err := r.db.GetContext(ctx, &entry, query, key)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached response: %w", err)
+	}
+
+	return &entry, nil
+}
+
+// Put stores an entry, replacing any entry with the same key
+func (r *ResponseCacheRepository) Put(ctx context.Context, entry *models.ResponseCacheEntry) error {
+	query := `
+		INSERT INTO response_cache (cache_key, user_id, scope, embedding, response, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (cache_key) DO UPDATE SET
+			embedding = EXCLUDED.embedding,
+			response = EXCLUDED.response,
+			expires_at = EXCLUDED.expires_at,
+			created_at = CURRENT_TIMESTAMP`
+
+	var embedding interface{}
+	if len(entry.Embedding) > 0 {
+		embedding = entry.Embedding
+	}
+
+	_, err := r.db.ExecContext(ctx, query,
+		entry.Key, entry.UserID, entry.Scope, embedding, entry.Response, entry.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store cached response: %w", err)
+	}
+
+	return nil
+}
+
+// ListEmbedded retrieves a user's unexpired entries in a scope that carry a
+// prompt embedding, newest first
+func (r *ResponseCacheRepository) ListEmbedded(ctx context.Context, userID, scope string, limit int) ([]models.ResponseCacheEntry, error) {
+	query := `
+		SELECT cache_key, user_id, scope, embedding, response, expires_at, created_at
+		FROM response_cache
+		WHERE user_id = $1 AND scope = $2 AND embedding IS NOT NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+		LIMIT $3`
+
+	entries := []models.ResponseCacheEntry{}
+	if err := r.db.SelectContext(ctx, &entries, query, userID, scope, limit); err != nil {
+		return nil, fmt.Errorf("failed to list cached responses: %w", err)
+	}
+
+	return entries, nil
+}
+
+// DeleteExpired removes expired entries and returns how many were removed
+func (r *ResponseCacheRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM response_cache WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired cached responses: %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,357 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrRoutingRuleNotFound is returned for rules that do not exist or are not visible to the user
+	ErrRoutingRuleNotFound = errors.New("routing rule not found")
+	// ErrFallbackChainNotFound is returned for chains that do not exist or belong to another user
+	ErrFallbackChainNotFound = errors.New("fallback chain not found")
+	// ErrConnectionPoolNotFound is returned for pools that do not exist or belong to another user
+	ErrConnectionPoolNotFound = errors.New("connection pool not found")
+)
+
+// RoutingRepository handles database operations for routing rules, fallback
+// chains and connection pools
+type RoutingRepository struct {
+	db *sqlx.DB
+}
+
+// NewRoutingRepository creates a new RoutingRepository
+func NewRoutingRepository(db *sqlx.DB) *RoutingRepository {
+	return &RoutingRepository{db: db}
+}
+
+const routingRuleColumns = `id, user_id, name, description, priority, enabled, conditions,
+		       target_connection_id, target_provider, target_model, created_at, updated_at`
+
+const fallbackChainColumns = `id, user_id, name, primary_connection_id, steps, enabled, created_at, updated_at`
+
+// CreateRule creates a new routing rule
+func (r *RoutingRepository) CreateRule(ctx context.Context, rule *models.RoutingRule) error {
+	if rule.ID == uuid.Nil {
+		rule.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO routing_rules (
+			id, user_id, name, description, priority, enabled, conditions,
+			target_connection_id, target_provider, target_model
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+		) RETURNING created_at, updated_at`
+
+	err := r.db.QueryRowContext(
+		ctx, query,
+		rule.ID, rule.UserID, rule.Name, rule.Description, rule.Priority, rule.Enabled,
+		rule.Conditions, rule.TargetConnectionID, rule.TargetProvider, rule.TargetModel,
+	).Scan(&rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create routing rule: %w", err)
+	}
+
+	return nil
+}
+
+// GetRule retrieves a routing rule visible to the user (their own or global)
+func (r *RoutingRepository) GetRule(ctx context.Context, userID, ruleID uuid.UUID) (*models.RoutingRule, error) {
+	rule := &models.RoutingRule{}
+	query := `
+		SELECT ` + routingRuleColumns + `
+		FROM routing_rules
+		WHERE id = $1 AND (user_id = $2 OR user_id IS NULL)`
+
+	err := r.db.GetContext(ctx, rule, query, ruleID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRoutingRuleNotFound
+		}
+		return nil, fmt.Errorf("failed to get routing rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+// ListRules retrieves the user's routing rules and all global rules,
+// highest priority first
+func (r *RoutingRepository) ListRules(ctx context.Context, userID uuid.UUID) ([]models.RoutingRule, error) {
+	query := `
+		SELECT ` + routingRuleColumns + `
+		FROM routing_rules
+		WHERE user_id = $1 OR user_id IS NULL
+		ORDER BY priority DESC, created_at ASC`
+
+	rules := []models.RoutingRule{}
+	err := r.db.SelectContext(ctx, &rules, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routing rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// UpdateRule saves all editable fields of a routing rule
+func (r *RoutingRepository) UpdateRule(ctx context.Context, rule *models.RoutingRule) error {
+	query := `
+		UPDATE routing_rules
+		SET name = $2, description = $3, priority = $4, enabled = $5, conditions = $6,
+		    target_connection_id = $7, target_provider = $8, target_model = $9
+		WHERE id = $1
+		RETURNING updated_at`
+
+	err := r.db.QueryRowContext(
+		ctx, query,
+		rule.ID, rule.Name, rule.Description, rule.Priority, rule.Enabled, rule.Conditions,
+		rule.TargetConnectionID, rule.TargetProvider, rule.TargetModel,
+	).Scan(&rule.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrRoutingRuleNotFound
+		}
+		return fmt.Errorf("failed to update routing rule: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRule deletes a routing rule
+func (r *RoutingRepository) DeleteRule(ctx context.Context, ruleID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM routing_rules WHERE id = $1`, ruleID)
+	if err != nil {
+		return fmt.Errorf("failed to delete routing rule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrRoutingRuleNotFound
+	}
+
+	return nil
+}
+
+// CreateChain creates a new fallback chain
+func (r *RoutingRepository) CreateChain(ctx context.Context, chain *models.FallbackChain) error {
+	if chain.ID == uuid.Nil {
+		chain.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO fallback_chains (
+			id, user_id, name, primary_connection_id, steps, enabled
+		) VALUES (
+			$1, $2, $3, $4, $5, $6
+		) RETURNING created_at, updated_at`
+
+	err := r.db.QueryRowContext(
+		ctx, query,
+		chain.ID, chain.UserID, chain.Name, chain.PrimaryConnectionID, chain.Steps, chain.Enabled,
+	).Scan(&chain.CreatedAt, &chain.UpdatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("fallback chain with name '%s' already exists", chain.Name)
+		}
+		return fmt.Errorf("failed to create fallback chain: %w", err)
+	}
+
+	return nil
+}
+
+// GetChain retrieves a fallback chain by ID
+func (r *RoutingRepository) GetChain(ctx context.Context, userID, chainID uuid.UUID) (*models.FallbackChain, error) {
+	chain := &models.FallbackChain{}
+	query := `
+		SELECT ` + fallbackChainColumns + `
+		FROM fallback_chains
+		WHERE id = $1 AND user_id = $2`
+
+	err := r.db.GetContext(ctx, chain, query, chainID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrFallbackChainNotFound
+		}
+		return nil, fmt.Errorf("failed to get fallback chain: %w", err)
+	}
+
+	return chain, nil
+}
+
+// ListChains retrieves all fallback chains for a user
+func (r *RoutingRepository) ListChains(ctx context.Context, userID uuid.UUID) ([]models.FallbackChain, error) {
+	query := `
+		SELECT ` + fallbackChainColumns + `
+		FROM fallback_chains
+		WHERE user_id = $1
+		ORDER BY created_at ASC`
+
+	chains := []models.FallbackChain{}
+	err := r.db.SelectContext(ctx, &chains, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fallback chains: %w", err)
+	}
+
+	return chains, nil
+}
+
+// UpdateChain saves all editable fields of a fallback chain
+func (r *RoutingRepository) UpdateChain(ctx context.Context, chain *models.FallbackChain) error {
+	query := `
+		UPDATE fallback_chains
+		SET name = $3, primary_connection_id = $4, steps = $5, enabled = $6
+		WHERE id = $1 AND user_id = $2
+		RETURNING updated_at`
+
+	err := r.db.QueryRowContext(
+		ctx, query,
+		chain.ID, chain.UserID, chain.Name, chain.PrimaryConnectionID, chain.Steps, chain.Enabled,
+	).Scan(&chain.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrFallbackChainNotFound
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("fallback chain with name '%s' already exists", chain.Name)
+		}
+		return fmt.Errorf("failed to update fallback chain: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteChain deletes a fallback chain
+func (r *RoutingRepository) DeleteChain(ctx context.Context, userID, chainID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM fallback_chains WHERE id = $1 AND user_id = $2`, chainID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete fallback chain: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrFallbackChainNotFound
+	}
+
+	return nil
+}
+
+const connectionPoolColumns = `id, user_id, name, strategy, members, enabled, created_at, updated_at`
+
+// CreatePool creates a new connection pool
+func (r *RoutingRepository) CreatePool(ctx context.Context, pool *models.ConnectionPool) error {
+	if pool.ID == uuid.Nil {
+		pool.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO connection_pools (
+			id, user_id, name, strategy, members, enabled
+		) VALUES (
+			$1, $2, $3, $4, $5, $6
+		) RETURNING created_at, updated_at`
+
+	err := r.db.QueryRowContext(
+		ctx, query,
+		pool.ID, pool.UserID, pool.Name, pool.Strategy, pool.Members, pool.Enabled,
+	).Scan(&pool.CreatedAt, &pool.UpdatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("connection pool with name '%s' already exists", pool.Name)
+		}
+		return fmt.Errorf("failed to create connection pool: %w", err)
+	}
+
+	return nil
+}
+
+// GetPool retrieves a connection pool by ID
+func (r *RoutingRepository) GetPool(ctx context.Context, userID, poolID uuid.UUID) (*models.ConnectionPool, error) {
+	pool := &models.ConnectionPool{}
+	query := `
+		SELECT ` + connectionPoolColumns + `
+		FROM connection_pools
+		WHERE id = $1 AND user_id = $2`
+
+	err := r.db.GetContext(ctx, pool, query, poolID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrConnectionPoolNotFound
+		}
+		return nil, fmt.Errorf("failed to get connection pool: %w", err)
+	}
+
+	return pool, nil
+}
+
+// ListPools retrieves all connection pools for a user
+func (r *RoutingRepository) ListPools(ctx context.Context, userID uuid.UUID) ([]models.ConnectionPool, error) {
+	query := `
+		SELECT ` + connectionPoolColumns + `
+		FROM connection_pools
+		WHERE user_id = $1
+		ORDER BY created_at ASC`
+
+	pools := []models.ConnectionPool{}
+	err := r.db.SelectContext(ctx, &pools, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list connection pools: %w", err)
+	}
+
+	return pools, nil
+}
+
+// UpdatePool saves all editable fields of a connection pool
+func (r *RoutingRepository) UpdatePool(ctx context.Context, pool *models.ConnectionPool) error {
+	query := `
+		UPDATE connection_pools
+		SET name = $3, strategy = $4, members = $5, enabled = $6
+		WHERE id = $1 AND user_id = $2
+		RETURNING updated_at`
+
+	err := r.db.QueryRowContext(
+		ctx, query,
+		pool.ID, pool.UserID, pool.Name, pool.Strategy, pool.Members, pool.Enabled,
+	).Scan(&pool.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrConnectionPoolNotFound
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("connection pool with name '%s' already exists", pool.Name)
+		}
+		return fmt.Errorf("failed to update connection pool: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePool deletes a connection pool
+func (r *RoutingRepository) DeletePool(ctx context.Context, userID, poolID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM connection_pools WHERE id = $1 AND user_id = $2`, poolID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete connection pool: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrConnectionPoolNotFound
+	}
+
+	return nil
+}
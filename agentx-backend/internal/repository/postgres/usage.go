@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// UsageRepository handles database operations for the usage ledger and
+// pricing catalog
+type UsageRepository struct {
+	db *sqlx.DB
+}
+
+// NewUsageRepository creates a new UsageRepository
+func NewUsageRepository(db *sqlx.DB) *UsageRepository {
+	return &UsageRepository{db: db}
+}
+
+// usageGroups maps group names to their select and group-by expressions
+var usageGroups = map[string][2]string{
+	"user":       {"user_id", "user_id"},
+	"day":        {"date_trunc('day', created_at) AS day", "date_trunc('day', created_at)"},
+	"model":      {"model", "model"},
+	"provider":   {"provider", "provider"},
+	"connection": {"connection_id", "connection_id"},
+	"api_key":    {"api_key_id", "api_key_id"},
+}
+
+// ValidUsageGroup reports whether usage can be grouped by the given name
+func ValidUsageGroup(name string) bool {
+	_, ok := usageGroups[name]
+	return ok
+}
+
+// CreateRecord appends a record to the usage ledger
+func (r *UsageRepository) CreateRecord(ctx context.Context, record *models.UsageRecord) error {
+	if record.ID == uuid.Nil {
+		record.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO usage_records (
+			id, user_id, connection_id, api_key_id, provider, model, task_type, stream,
+			prompt_tokens, completion_tokens, cost, latency_ms, success, error,
+			fallback_used, created_at
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
+		)`
+
+	_, err := r.db.ExecContext(
+		ctx, query,
+		record.ID, record.UserID, record.ConnectionID, record.APIKeyID, record.Provider, record.Model,
+		record.TaskType, record.Stream, record.PromptTokens, record.CompletionTokens,
+		record.Cost, record.LatencyMs, record.Success, record.Error, record.FallbackUsed,
+		record.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create usage record: %w", err)
+	}
+
+	return nil
+}
+
+// Aggregate computes usage totals, error rates and latency percentiles for
+// the records matching the query, grouped as requested
+func (r *UsageRepository) Aggregate(ctx context.Context, q models.UsageQuery) ([]models.UsageAggregate, error) {
+	var selects, groups, order []string
+	for i, name := range q.GroupBy {
+		group, ok := usageGroups[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid usage group: %s", name)
+		}
+		selects = append(selects, group[0])
+		groups = append(groups, group[1])
+		order = append(order, fmt.Sprintf("%d", i+1))
+	}
+
+	selects = append(selects,
+		"COUNT(*) AS requests",
+		"COUNT(*) FILTER (WHERE NOT success) AS errors",
+		"COALESCE(COUNT(*) FILTER (WHERE NOT success)::float8 / NULLIF(COUNT(*), 0), 0) AS error_rate",
+		"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens",
+		"COALESCE(SUM(completion_tokens), 0) AS completion_tokens",
+		"COALESCE(SUM(prompt_tokens + completion_tokens), 0) AS total_tokens",
+		"COALESCE(SUM(cost), 0)::float8 AS cost",
+		"COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY latency_ms) FILTER (WHERE success), 0) AS latency_p50_ms",
+		"COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY latency_ms) FILTER (WHERE success), 0) AS latency_p95_ms",
+	)
+
+	where := []string{"created_at >= $1", "created_at < $2"}
+	args := []interface{}{q.From, q.To}
+	if q.UserID != nil {
+		args = append(args, *q.UserID)
+		where = append(where, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	if q.ConnectionID != nil {
+		args = append(args, *q.ConnectionID)
+		where = append(where, fmt.Sprintf("connection_id = $%d", len(args)))
+	}
+	if q.APIKeyID != nil {
+		args = append(args, *q.APIKeyID)
+		where = append(where, fmt.Sprintf("api_key_id = $%d", len(args)))
+	}
+	if q.Model != "" {
+		args = append(args, q.Model)
+		where = append(where, fmt.Sprintf("model = $%d", len(args)))
+	}
+
+	query := "SELECT " + strings.Join(selects, ", ") +
+		" FROM usage_records WHERE " + strings.Join(where, " AND ")
+	if len(groups) > 0 {
+		query += " GROUP BY " + strings.Join(groups, ", ") + " ORDER BY " + strings.Join(order, ", ")
+	}
+
+	rows := []models.UsageAggregate{}
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to aggregate usage: %w", err)
+	}
+
+	return rows, nil
+}
+
+// ListPrices retrieves all admin-defined model prices
+func (r *UsageRepository) ListPrices(ctx context.Context) ([]models.ModelPrice, error) {
+	query := `
+		SELECT model, input_per_million::float8 AS input_per_million,
+		       output_per_million::float8 AS output_per_million, updated_at
+		FROM model_pricing
+		ORDER BY model ASC`
+
+	prices := []models.ModelPrice{}
+	if err := r.db.SelectContext(ctx, &prices, query); err != nil {
+		return nil, fmt.Errorf("failed to list model prices: %w", err)
+	}
+
+	return prices, nil
+}
+
+// UpsertPrice creates or replaces the price of a model
+func (r *UsageRepository) UpsertPrice(ctx context.Context, price *models.ModelPrice) error {
+	query := `
+		INSERT INTO model_pricing (model, input_per_million, output_per_million)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (model) DO UPDATE
+		SET input_per_million = EXCLUDED.input_per_million,
+		    output_per_million = EXCLUDED.output_per_million
+		RETURNING updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, price.Model, price.InputPerMillion, price.OutputPerMillion).Scan(&price.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save model price: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePrice removes the admin-defined price of a model
+func (r *UsageRepository) DeletePrice(ctx context.Context, model string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM model_pricing WHERE model = $1`, model)
+	if err != nil {
+		return fmt.Errorf("failed to delete model price: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("model price not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/api/models"
+	"github.com/agentx/agentx-backend/internal/llm"
+	"github.com/agentx/agentx-backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// AgentLoopConfig bounds the server-side tool-calling loop
+type AgentLoopConfig struct {
+	MaxSteps int           // Maximum number of tool-call rounds per request
+	Timeout  time.Duration // Wall-clock budget for the whole loop
+}
+
+// DefaultAgentLoopConfig returns the default agent loop budgets
+func DefaultAgentLoopConfig() AgentLoopConfig {
+	return AgentLoopConfig{
+		MaxSteps: 8,
+		Timeout:  2 * time.Minute,
+	}
+}
+
+// AgentLoopConfigFromEnv returns the default budgets with environment overrides applied
+func AgentLoopConfigFromEnv() AgentLoopConfig {
+	cfg := DefaultAgentLoopConfig()
+
+	if v := os.Getenv("AGENTX_AGENT_MAX_STEPS"); v != "" {
+		if steps, err := strconv.Atoi(v); err == nil && steps >= 0 {
+			cfg.MaxSteps = steps
+		}
+	}
+	if v := os.Getenv("AGENTX_AGENT_TIMEOUT"); v != "" {
+		if timeout, err := time.ParseDuration(v); err == nil && timeout > 0 {
+			cfg.Timeout = timeout
+		}
+	}
+
+	return cfg
+}
+
+// agentGateway runs the completions of the tool-calling loop; llm.Gateway
+// implements it
+type agentGateway interface {
+	Complete(ctx context.Context, req *llm.Request) (*llm.Response, error)
+	StreamComplete(ctx context.Context, req *llm.Request) (<-chan *llm.StreamChunk, error)
+}
+
+// agentToolExecutor runs the MCP tool calls of the loop;
+// MCPToolIntegration implements it
+type agentToolExecutor interface {
+	InvokeToolForUser(ctx context.Context, userID uuid.UUID, invocation *ToolInvocation) (*ToolResult, error)
+	FormatToolResultForModel(result *ToolResult) string
+}
+
+// SetAgentLoopConfig overrides the budgets used by the tool-calling loop
+func (o *OrchestrationService) SetAgentLoopConfig(cfg AgentLoopConfig) {
+	o.agentLoop = cfg
+}
+
+// agentRun tracks the state of a single tool-calling loop
+type agentRun struct {
+	toolset  *AgentToolset
+	maxSteps int
+	deadline time.Time
+	steps    int
+	stop     string
+	// transcript holds the intermediate assistant/tool messages in order
+	transcript []llm.Message
+}
+
+// prepareAgentRun attaches the user's MCP tools to the request and returns
+// the loop state, or nil when the loop is disabled for this request
+func (o *OrchestrationService) prepareAgentRun(ctx context.Context, userID uuid.UUID, req models.UnifiedChatRequest, gatewayReq *llm.Request) *agentRun {
+	if o.mcpTools == nil || userID == uuid.Nil {
+		return nil
+	}
+
+	maxSteps := o.agentLoop.MaxSteps
+	if req.MaxToolSteps != nil {
+		maxSteps = *req.MaxToolSteps
+	}
+	if maxSteps <= 0 {
+		return nil
+	}
+
+	toolset := o.mcpTools.GetToolsetForUser(ctx, userID)
+	if toolset.Len() == 0 {
+		return nil
+	}
+
+	// Client-declared tools take precedence over MCP tools with the same name
+	declared := make(map[string]bool, len(gatewayReq.Tools))
+	for _, tool := range gatewayReq.Tools {
+		declared[tool.Function.Name] = true
+	}
+	for _, tool := range toolset.Definitions() {
+		if declared[tool.Function.Name] {
+			toolset.Remove(tool.Function.Name)
+			continue
+		}
+		gatewayReq.Tools = append(gatewayReq.Tools, tool)
+	}
+
+	return &agentRun{
+		toolset:  toolset,
+		maxSteps: maxSteps,
+		deadline: time.Now().Add(o.agentLoop.Timeout),
+	}
+}
+
+// canExecute reports whether every requested tool call can be served by MCP
+// and the loop still has budget for another round
+func (r *agentRun) canExecute(toolCalls []llm.ToolCall) bool {
+	if len(toolCalls) == 0 {
+		r.stop = "completed"
+		return false
+	}
+	for _, tc := range toolCalls {
+		if _, ok := r.toolset.Resolve(tc.Function.Name); !ok {
+			// Unknown tools belong to the client; hand them back
+			r.stop = "client_tool_calls"
+			return false
+		}
+	}
+	if r.steps >= r.maxSteps {
+		r.stop = "max_steps"
+		return false
+	}
+	if time.Now().After(r.deadline) {
+		r.stop = "timeout"
+		return false
+	}
+	return true
+}
+
+// executeStep runs the tool calls of an assistant message and appends the
+// message and the tool results to both the request and transcript. It stops
+// and returns false when onResult reports that the caller has gone away.
+func (o *OrchestrationService) executeStep(ctx context.Context, userID uuid.UUID, run *agentRun, gatewayReq *llm.Request, assistantMsg llm.Message, onResult func(llm.ToolCall, string) bool) bool {
+	run.steps++
+
+	toolCalls := assistantMsg.ToolCalls
+	gatewayReq.Messages = append(gatewayReq.Messages, assistantMsg)
+	run.transcript = append(run.transcript, assistantMsg)
+
+	toolCtx, cancel := context.WithDeadline(ctx, run.deadline)
+	defer cancel()
+
+	for _, tc := range toolCalls {
+		invocation, _ := run.toolset.Resolve(tc.Function.Name)
+		invocation.Arguments = json.RawMessage(tc.Function.Arguments)
+		if len(invocation.Arguments) == 0 {
+			invocation.Arguments = json.RawMessage("{}")
+		}
+
+		fmt.Printf("[OrchestrationService] Agent step %d: calling %s on %s\n", run.steps, invocation.ToolName, invocation.ServerID)
+
+		result, err := o.agentTools.InvokeToolForUser(toolCtx, userID, invocation)
+		if err != nil {
+			result = &ToolResult{Success: false, Error: err.Error()}
+		}
+		output := o.agentTools.FormatToolResultForModel(result)
+
+		toolMsg := llm.Message{
+			Role:       "tool",
+			Content:    output,
+			Name:       tc.Function.Name,
+			ToolCallID: tc.ID,
+		}
+		gatewayReq.Messages = append(gatewayReq.Messages, toolMsg)
+		run.transcript = append(run.transcript, toolMsg)
+
+		if onResult != nil && !onResult(tc, output) {
+			return false
+		}
+	}
+	return true
+}
+
+// runAgentLoop sends the request through the gateway and keeps resolving MCP
+// tool calls until the model produces a final answer or the budget runs out
+func (o *OrchestrationService) runAgentLoop(ctx context.Context, userID uuid.UUID, run *agentRun, gatewayReq *llm.Request) (*llm.Response, error) {
+	for {
+		resp, err := o.agentGateway.Complete(ctx, gatewayReq)
+		if err != nil {
+			return nil, err
+		}
+
+		if run == nil || !run.canExecute(resp.GetToolCalls()) {
+			return resp, nil
+		}
+
+		// Thinking goes back with the tool calls it led to
+		step := llm.Message{Role: "assistant", Content: resp.Content, ToolCalls: resp.GetToolCalls()}
+		if len(resp.Choices) > 0 {
+			step.Reasoning = resp.Choices[0].Message.Reasoning
+			step.ReasoningSignature = resp.Choices[0].Message.ReasoningSignature
+			step.RedactedReasoning = resp.Choices[0].Message.RedactedReasoning
+		}
+		o.executeStep(ctx, userID, run, gatewayReq, step, nil)
+	}
+}
+
+// saveTranscript persists assistant and tool messages in order so the
+// conversation can be replayed with tool_calls/tool_call_id intact
+func (o *OrchestrationService) saveTranscript(ctx context.Context, sessionID string, transcript []llm.Message) {
+	for _, msg := range transcript {
+		record := repository.Message{
+			ID:        uuid.New().String(),
+			SessionID: sessionID,
+			Role:      msg.Role,
+			Content:   msg.Content,
+			CreatedAt: time.Now(),
+		}
+		if len(msg.ToolCalls) > 0 {
+			if data, err := json.Marshal(msg.ToolCalls); err == nil {
+				record.ToolCalls = sql.NullString{String: string(data), Valid: true}
+			}
+		}
+		if msg.ToolCallID != "" {
+			record.ToolCallID = sql.NullString{String: msg.ToolCallID, Valid: true}
+		}
+		// Thinking is stored apart from the answer it preceded
+		if msg.Reasoning != "" {
+			if data, err := json.Marshal(map[string]interface{}{"reasoning": msg.Reasoning}); err == nil {
+				record.Metadata = data
+			}
+		}
+
+		if _, err := o.messageRepo.Create(ctx, record); err != nil {
+			fmt.Printf("[OrchestrationService] Failed to save message: %v\n", err)
+		}
+	}
+}
+
+// convertToolCallsToResponse converts gateway tool calls for the unified API
+func convertToolCallsToResponse(toolCalls []llm.ToolCall) []models.ToolResponse {
+	if len(toolCalls) == 0 {
+		return nil
+	}
+
+	tools := make([]models.ToolResponse, len(toolCalls))
+	for i, tc := range toolCalls {
+		tools[i] = models.ToolResponse{
+			ID:   tc.ID,
+			Type: tc.Type,
+			Function: models.FunctionResponse{
+				Name:      tc.Function.Name,
+				Arguments: tc.Function.Arguments,
+			},
+		}
+	}
+	return tools
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/api/models"
+	"github.com/agentx/agentx-backend/internal/llm"
+	"github.com/agentx/agentx-backend/internal/providers"
+	"github.com/agentx/agentx-backend/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubAgentGateway answers each round with the next scripted response or
+// stream; a round without a stream fails to open
+type stubAgentGateway struct {
+	responses []*llm.Response
+	streams   [][]*llm.StreamChunk
+	rounds    int
+}
+
+func (g *stubAgentGateway) Complete(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	resp := g.responses[g.rounds]
+	g.rounds++
+	return resp, nil
+}
+
+func (g *stubAgentGateway) StreamComplete(ctx context.Context, req *llm.Request) (<-chan *llm.StreamChunk, error) {
+	if g.rounds >= len(g.streams) {
+		return nil, errors.New("provider unavailable")
+	}
+	chunks := g.streams[g.rounds]
+	g.rounds++
+	return streamOf(chunks...), nil
+}
+
+func streamOf(chunks ...*llm.StreamChunk) <-chan *llm.StreamChunk {
+	out := make(chan *llm.StreamChunk, len(chunks))
+	for _, chunk := range chunks {
+		out <- chunk
+	}
+	close(out)
+	return out
+}
+
+// stubToolExecutor records the tools it is asked to run
+type stubToolExecutor struct {
+	invoked []ToolInvocation
+}
+
+func (e *stubToolExecutor) InvokeToolForUser(ctx context.Context, userID uuid.UUID, invocation *ToolInvocation) (*ToolResult, error) {
+	e.invoked = append(e.invoked, *invocation)
+	return &ToolResult{Success: true, Result: "sunny"}, nil
+}
+
+func (e *stubToolExecutor) FormatToolResultForModel(result *ToolResult) string {
+	return result.Result.(string)
+}
+
+// recordingMessageRepo keeps the messages saved to a session
+type recordingMessageRepo struct {
+	saved []repository.Message
+}
+
+func (r *recordingMessageRepo) Create(ctx context.Context, message repository.Message) (string, error) {
+	r.saved = append(r.saved, message)
+	return message.ID, nil
+}
+
+func (r *recordingMessageRepo) ListBySession(ctx context.Context, sessionID string) ([]repository.Message, error) {
+	return r.saved, nil
+}
+
+func (r *recordingMessageRepo) Delete(ctx context.Context, id string) error { return nil }
+
+func newTestAgentRun(maxSteps int) *agentRun {
+	toolset := &AgentToolset{routes: make(map[string]ToolInvocation)}
+	toolset.add("weather", "get_weather", "Current weather", nil, ToolInvocation{Type: "custom", ServerID: "weather", ToolName: "get_weather"})
+	return &agentRun{toolset: toolset, maxSteps: maxSteps, deadline: time.Now().Add(time.Minute)}
+}
+
+func toolCall(id, name, arguments string) llm.ToolCall {
+	call := llm.ToolCall{ID: id, Type: "function"}
+	call.Function.Name = name
+	call.Function.Arguments = arguments
+	return call
+}
+
+func weatherCall(id string) llm.ToolCall {
+	return toolCall(id, "get_weather", `{"city":"Paris"}`)
+}
+
+func toolCallResponse(calls ...llm.ToolCall) *llm.Response {
+	return &llm.Response{Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", ToolCalls: calls}}}}
+}
+
+func chunkTypes(chunks []models.UnifiedStreamChunk) []string {
+	var types []string
+	for _, chunk := range chunks {
+		types = append(types, chunk.Type)
+	}
+	return types
+}
+
+func TestAgentRunStopReasons(t *testing.T) {
+	run := newTestAgentRun(2)
+	assert.True(t, run.canExecute([]llm.ToolCall{weatherCall("call_1")}))
+
+	assert.False(t, run.canExecute(nil))
+	assert.Equal(t, "completed", run.stop)
+
+	clientCall := toolCall("call_2", "open_file", "{}")
+	assert.False(t, run.canExecute([]llm.ToolCall{weatherCall("call_1"), clientCall}))
+	assert.Equal(t, "client_tool_calls", run.stop, "calls the server cannot serve all go back to the client")
+
+	run.steps = 2
+	assert.False(t, run.canExecute([]llm.ToolCall{weatherCall("call_1")}))
+	assert.Equal(t, "max_steps", run.stop)
+
+	run = newTestAgentRun(2)
+	run.deadline = time.Now().Add(-time.Second)
+	assert.False(t, run.canExecute([]llm.ToolCall{weatherCall("call_1")}))
+	assert.Equal(t, "timeout", run.stop)
+}
+
+func TestRunAgentLoopExecutesToolsUntilAnswered(t *testing.T) {
+	tools := &stubToolExecutor{}
+	o := &OrchestrationService{
+		agentGateway: &stubAgentGateway{responses: []*llm.Response{
+			toolCallResponse(weatherCall("call_1")),
+			{Choices: []llm.Choice{{Message: llm.Message{Role: "assistant", Content: "It is sunny."}}}},
+		}},
+		agentTools: tools,
+	}
+	run := newTestAgentRun(4)
+	req := &llm.Request{Messages: []llm.Message{{Role: "user", Content: "Weather in Paris?"}}}
+
+	resp, err := o.runAgentLoop(context.Background(), uuid.New(), run, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "It is sunny.", resp.GetContent())
+	assert.Equal(t, "completed", run.stop)
+	if assert.Len(t, tools.invoked, 1) {
+		assert.Equal(t, "get_weather", tools.invoked[0].ToolName)
+		assert.JSONEq(t, `{"city":"Paris"}`, string(tools.invoked[0].Arguments))
+	}
+	if assert.Len(t, run.transcript, 2) {
+		assert.Equal(t, "call_1", run.transcript[0].ToolCalls[0].ID)
+		assert.Equal(t, llm.Message{Role: "tool", Content: "sunny", Name: "get_weather", ToolCallID: "call_1"}, run.transcript[1])
+	}
+	assert.Len(t, req.Messages, 3, "the next round sees the step and its result")
+}
+
+func TestRunAgentLoopStopsAtMaxSteps(t *testing.T) {
+	tools := &stubToolExecutor{}
+	o := &OrchestrationService{
+		agentGateway: &stubAgentGateway{responses: []*llm.Response{
+			toolCallResponse(weatherCall("call_1")),
+			toolCallResponse(weatherCall("call_2")),
+		}},
+		agentTools: tools,
+	}
+	run := newTestAgentRun(1)
+
+	resp, err := o.runAgentLoop(context.Background(), uuid.New(), run, &llm.Request{})
+	assert.NoError(t, err)
+	assert.Equal(t, "max_steps", run.stop)
+	assert.Len(t, tools.invoked, 1)
+	assert.Equal(t, "call_2", resp.GetToolCalls()[0].ID, "the unexecuted call is returned")
+}
+
+func TestStreamAgentLoopRunsToolSteps(t *testing.T) {
+	call := weatherCall("call_1")
+	gateway := &stubAgentGateway{streams: [][]*llm.StreamChunk{
+		{{Type: "content", Content: "Done: "}},
+	}}
+	o := &OrchestrationService{agentGateway: gateway, agentTools: &stubToolExecutor{}, cache: NewCacheService()}
+	out := make(chan models.UnifiedStreamChunk, 16)
+
+	first := streamOf(&llm.StreamChunk{Type: llm.StreamToolCallEnd, ToolCall: &call})
+	err := o.streamAgentLoop(context.Background(), uuid.New(), models.UnifiedChatRequest{}, newTestAgentRun(4), &llm.Request{}, first, out)
+	close(out)
+	assert.NoError(t, err)
+
+	var chunks []models.UnifiedStreamChunk
+	for chunk := range out {
+		chunks = append(chunks, chunk)
+	}
+	assert.Equal(t, []string{llm.StreamToolCallEnd, "tool_use", "tool_result", "content"}, chunkTypes(chunks))
+	assert.Equal(t, "sunny", chunks[2].Content)
+}
+
+func TestStreamAgentLoopDoesNotResendExecutedCallsWhenFollowUpFails(t *testing.T) {
+	call := weatherCall("call_1")
+	messages := &recordingMessageRepo{}
+	o := &OrchestrationService{
+		agentGateway: &stubAgentGateway{}, // The follow-up round cannot be opened
+		agentTools:   &stubToolExecutor{},
+		messageRepo:  messages,
+		cache:        NewCacheService(),
+	}
+	out := make(chan models.UnifiedStreamChunk, 16)
+	req := models.UnifiedChatRequest{SessionID: "session-1", Messages: []providers.Message{{Role: "user", Content: "Weather in Paris?"}}}
+
+	first := streamOf(&llm.StreamChunk{Type: llm.StreamToolCallEnd, ToolCall: &call})
+	err := o.streamAgentLoop(context.Background(), uuid.New(), req, newTestAgentRun(4), &llm.Request{}, first, out)
+	close(out)
+	assert.EqualError(t, err, "provider unavailable")
+
+	var chunks []models.UnifiedStreamChunk
+	for chunk := range out {
+		chunks = append(chunks, chunk)
+	}
+	assert.Equal(t, []string{llm.StreamToolCallEnd, "tool_use", "tool_result", "error"}, chunkTypes(chunks))
+
+	var withCalls int
+	for _, msg := range messages.saved {
+		if msg.ToolCalls.Valid {
+			withCalls++
+		}
+	}
+	assert.Equal(t, 1, withCalls, "the executed calls are saved once, with their results")
+}
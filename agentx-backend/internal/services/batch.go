@@ -0,0 +1,569 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/llm"
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/agentx/agentx-backend/internal/repository/postgres"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxBatchItems caps the number of requests in one batch job
+	maxBatchItems = 50000
+	// maxBatchLineSize caps the size of one JSONL line
+	maxBatchLineSize = 1 << 20
+
+	defaultBatchConcurrency = 4
+	maxBatchConcurrency     = 32
+	defaultBatchAttempts    = 3
+	maxBatchAttempts        = 10
+
+	// batchItemLease is how long a claimed item stays with this instance
+	// without a heartbeat before another may take it over
+	batchItemLease = 2 * time.Minute
+	// batchPollInterval is how often a job looks for items that became
+	// claimable: retries whose backoff ended and expired leases
+	batchPollInterval = time.Second
+)
+
+var (
+	// ErrInvalidBatch is returned for batch jobs that fail validation
+	ErrInvalidBatch = errors.New("invalid batch")
+	// ErrBatchNotFound is returned for jobs that do not exist or belong to
+	// another user
+	ErrBatchNotFound = errors.New("batch job not found")
+)
+
+// batchCompleter runs one batch item; llm.Service implements it
+type batchCompleter interface {
+	Complete(ctx context.Context, userID string, req llm.CompletionRequest) (*llm.CompletionResponse, error)
+}
+
+// batchConnections registers a job owner's connections with the gateway;
+// OrchestrationService implements it
+type batchConnections interface {
+	InitializeUserConnections(ctx context.Context, userID uuid.UUID) error
+}
+
+// batchStore persists jobs and their items; postgres.BatchRepository
+// implements it
+type batchStore interface {
+	CreateJob(ctx context.Context, job *models.BatchJob, items []models.BatchItem) error
+	GetJob(ctx context.Context, jobID uuid.UUID) (*models.BatchJob, error)
+	ListJobs(ctx context.Context, userID uuid.UUID) ([]models.BatchJob, error)
+	ListUnfinished(ctx context.Context) ([]models.BatchJob, error)
+	Progress(ctx context.Context, jobID uuid.UUID) (models.BatchProgress, error)
+	ListItems(ctx context.Context, jobID uuid.UUID, status string, offset, limit int) ([]models.BatchItem, error)
+	SetJobStatus(ctx context.Context, jobID uuid.UUID, status string) error
+	ClaimItems(ctx context.Context, jobID uuid.UUID, connectionID, owner string, lease time.Duration, limit int) ([]models.BatchItem, error)
+	ClaimableConnections(ctx context.Context, jobID uuid.UUID) ([]string, error)
+	ExtendLeases(ctx context.Context, jobID uuid.UUID, owner string, lease time.Duration) error
+	FinishItem(ctx context.Context, itemID uuid.UUID, owner, status string, response []byte, errMsg string) error
+	RequeueItem(ctx context.Context, itemID uuid.UUID, owner, errMsg string, retryAt time.Time) error
+	CompleteJob(ctx context.Context, jobID uuid.UUID) (bool, error)
+	CancelJob(ctx context.Context, jobID uuid.UUID) error
+	RequeueFailed(ctx context.Context, jobID uuid.UUID) (int64, error)
+}
+
+// BatchService runs batch jobs: completion requests uploaded as JSONL,
+// persisted with per-item status and processed in the background through the
+// LLM service with bounded concurrency per connection. Items are claimed one
+// at a time under a lease this instance renews while it works, so instances
+// sharing the database split a job between them, and items left behind by an
+// instance that stopped are taken over once their lease expires.
+type BatchService struct {
+	repo        batchStore
+	llm         batchCompleter
+	connections batchConnections
+	retry       llm.RetryPolicy
+	owner       string        // Lease owner naming this instance
+	lease       time.Duration // Lease on claimed items
+	poll        time.Duration // Interval between looks for claimable items
+	mu          sync.Mutex
+	running     map[uuid.UUID]*batchRun
+}
+
+// batchRun is a job being processed
+type batchRun struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// batchLine is a line of an uploaded JSONL: a completion request with an
+// optional ID the caller uses to match results
+type batchLine struct {
+	CustomID string `json:"custom_id,omitempty"`
+	llm.CompletionRequest
+}
+
+// NewBatchService creates a new batch service and resumes unfinished jobs
+func NewBatchService(repo *postgres.BatchRepository, llmService *llm.Service, connections batchConnections) *BatchService {
+	s := &BatchService{
+		repo:        repo,
+		llm:         llmService,
+		connections: connections,
+		retry:       llm.DefaultRetryPolicy(),
+		owner:       uuid.NewString(),
+		lease:       batchItemLease,
+		poll:        batchPollInterval,
+		running:     make(map[uuid.UUID]*batchRun),
+	}
+	go s.resume(context.Background())
+	return s
+}
+
+// ParseBatchInput reads batch items from JSONL, one completion request per
+// line. Blank lines are skipped; line numbers count them.
+func ParseBatchInput(r io.Reader) ([]models.BatchItem, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBatchLineSize)
+
+	var items []models.BatchItem
+	for line := 1; scanner.Scan(); line++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		var parsed batchLine
+		if err := json.Unmarshal(raw, &parsed); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidBatch, line, err)
+		}
+		if err := parsed.CompletionRequest.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidBatch, line, err)
+		}
+		request, err := json.Marshal(parsed.CompletionRequest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidBatch, line, err)
+		}
+
+		items = append(items, models.BatchItem{
+			Line:     line,
+			CustomID: parsed.CustomID,
+			Request:  request,
+		})
+		if len(items) > maxBatchItems {
+			return nil, fmt.Errorf("%w: more than %d requests", ErrInvalidBatch, maxBatchItems)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBatch, err)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: no requests", ErrInvalidBatch)
+	}
+
+	return items, nil
+}
+
+// Create persists a job for the user's JSONL requests and starts it
+func (s *BatchService) Create(ctx context.Context, userID uuid.UUID, req models.BatchJobCreateRequest, input io.Reader) (*models.BatchJobStatus, error) {
+	job := &models.BatchJob{
+		UserID:      userID,
+		Name:        req.Name,
+		Status:      models.BatchJobQueued,
+		Concurrency: req.Concurrency,
+		MaxAttempts: req.MaxAttempts,
+	}
+	if job.Concurrency == 0 {
+		job.Concurrency = defaultBatchConcurrency
+	}
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = defaultBatchAttempts
+	}
+	if job.Concurrency < 1 || job.Concurrency > maxBatchConcurrency {
+		return nil, fmt.Errorf("%w: concurrency must be between 1 and %d", ErrInvalidBatch, maxBatchConcurrency)
+	}
+	if job.MaxAttempts < 1 || job.MaxAttempts > maxBatchAttempts {
+		return nil, fmt.Errorf("%w: max_attempts must be between 1 and %d", ErrInvalidBatch, maxBatchAttempts)
+	}
+
+	items, err := ParseBatchInput(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateJob(ctx, job, items); err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("[BatchService] Created job %s with %d items for user %s\n", job.ID, len(items), userID)
+	s.start(*job)
+
+	return &models.BatchJobStatus{
+		BatchJob: *job,
+		Progress: models.BatchProgress{Pending: len(items)},
+	}, nil
+}
+
+// List returns the user's jobs with their progress
+func (s *BatchService) List(ctx context.Context, userID uuid.UUID) ([]models.BatchJobStatus, error) {
+	jobs, err := s.repo.ListJobs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]models.BatchJobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		progress, err := s.repo.Progress(ctx, job.ID)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, models.BatchJobStatus{BatchJob: job, Progress: progress})
+	}
+	return statuses, nil
+}
+
+// Get returns one of the user's jobs with its progress
+func (s *BatchService) Get(ctx context.Context, userID, jobID uuid.UUID) (*models.BatchJobStatus, error) {
+	job, err := s.ownedJob(ctx, userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := s.repo.Progress(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.BatchJobStatus{BatchJob: *job, Progress: progress}, nil
+}
+
+// Items returns a page of a job's items, optionally only those with a status
+func (s *BatchService) Items(ctx context.Context, userID, jobID uuid.UUID, status string, offset, limit int) ([]models.BatchItem, error) {
+	if _, err := s.ownedJob(ctx, userID, jobID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListItems(ctx, jobID, status, offset, limit)
+}
+
+// WriteResults writes a job's results as JSONL in line order, optionally only
+// the items with a status
+func (s *BatchService) WriteResults(ctx context.Context, userID, jobID uuid.UUID, status string, w io.Writer) error {
+	if _, err := s.ownedJob(ctx, userID, jobID); err != nil {
+		return err
+	}
+
+	const page = 1000
+	encoder := json.NewEncoder(w)
+	for offset := 0; ; offset += page {
+		items, err := s.repo.ListItems(ctx, jobID, status, offset, page)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := encoder.Encode(models.BatchResult{
+				CustomID: item.CustomID,
+				Line:     item.Line,
+				Status:   item.Status,
+				Attempts: item.Attempts,
+				Response: item.Response,
+				Error:    item.Error,
+			}); err != nil {
+				return err
+			}
+		}
+		if len(items) < page {
+			return nil
+		}
+	}
+}
+
+// Cancel stops a job; items not yet finished are cancelled
+func (s *BatchService) Cancel(ctx context.Context, userID, jobID uuid.UUID) (*models.BatchJobStatus, error) {
+	job, err := s.ownedJob(ctx, userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == models.BatchJobCompleted || job.Status == models.BatchJobCancelled {
+		return nil, fmt.Errorf("%w: job is already %s", ErrInvalidBatch, job.Status)
+	}
+
+	s.stop(jobID)
+	if err := s.repo.CancelJob(ctx, jobID); err != nil {
+		return nil, err
+	}
+	fmt.Printf("[BatchService] Cancelled job %s\n", jobID)
+
+	return s.Get(ctx, userID, jobID)
+}
+
+// Retry requeues a finished job's failed and cancelled items and runs them
+// again
+func (s *BatchService) Retry(ctx context.Context, userID, jobID uuid.UUID) (*models.BatchJobStatus, error) {
+	job, err := s.ownedJob(ctx, userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == models.BatchJobQueued || job.Status == models.BatchJobRunning {
+		return nil, fmt.Errorf("%w: job is still %s", ErrInvalidBatch, job.Status)
+	}
+
+	requeued, err := s.repo.RequeueFailed(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if requeued == 0 {
+		return nil, fmt.Errorf("%w: no failed or cancelled items to retry", ErrInvalidBatch)
+	}
+
+	fmt.Printf("[BatchService] Retrying %d items of job %s\n", requeued, jobID)
+	job.Status = models.BatchJobQueued
+	s.start(*job)
+
+	return s.Get(ctx, userID, jobID)
+}
+
+// ownedJob loads a job and checks that it belongs to the user
+func (s *BatchService) ownedJob(ctx context.Context, userID, jobID uuid.UUID) (*models.BatchJob, error) {
+	job, err := s.repo.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil || job.UserID != userID {
+		return nil, ErrBatchNotFound
+	}
+	return job, nil
+}
+
+// resume joins the jobs that are queued or running. Items another instance
+// is working on keep their lease; those of a stopped instance are claimed
+// once it expires.
+func (s *BatchService) resume(ctx context.Context) {
+	jobs, err := s.repo.ListUnfinished(ctx)
+	if err != nil {
+		fmt.Printf("[BatchService] Warning: Failed to load unfinished jobs: %v\n", err)
+		return
+	}
+	for _, job := range jobs {
+		fmt.Printf("[BatchService] Resuming job %s\n", job.ID)
+		s.start(job)
+	}
+}
+
+// start processes a job in the background unless it is already running
+func (s *BatchService) start(job models.BatchJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.running[job.ID]; ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &batchRun{cancel: cancel, done: make(chan struct{})}
+	s.running[job.ID] = run
+
+	go func() {
+		defer close(run.done)
+		finished := s.process(ctx, job)
+
+		// Leave the running set before the job is marked completed, so a
+		// retry that sees the completed job can start it again
+		s.mu.Lock()
+		delete(s.running, job.ID)
+		s.mu.Unlock()
+
+		if finished {
+			completed, err := s.repo.CompleteJob(context.Background(), job.ID)
+			if err != nil {
+				fmt.Printf("[BatchService] Failed to complete job %s: %v\n", job.ID, err)
+				return
+			}
+			if completed {
+				fmt.Printf("[BatchService] Completed job %s\n", job.ID)
+			}
+		}
+	}()
+}
+
+// stop cancels a running job and waits for its in-flight items to settle
+func (s *BatchService) stop(jobID uuid.UUID) {
+	s.mu.Lock()
+	run, ok := s.running[jobID]
+	s.mu.Unlock()
+	if ok {
+		run.cancel()
+		<-run.done
+	}
+}
+
+// process runs a job's items until none are left and reports whether it got
+// there. Each connection the items are routed to gets its own workers, which
+// claim their next item as soon as they are free, so a slow or rate-limited
+// connection never holds up items routed elsewhere. Items to be retried
+// return to pending with a time before which they are not claimed, and the
+// job polls for them and for expired leases; when nothing is claimable but
+// items are still leased elsewhere, it waits for them to finish or their
+// lease to expire.
+func (s *BatchService) process(ctx context.Context, job models.BatchJob) bool {
+	if err := s.repo.SetJobStatus(ctx, job.ID, models.BatchJobRunning); err != nil {
+		fmt.Printf("[BatchService] Failed to start job %s: %v\n", job.ID, err)
+		return false
+	}
+
+	// Items run as the job's owner, through the owner's connections
+	if err := s.connections.InitializeUserConnections(ctx, job.UserID); err != nil {
+		fmt.Printf("[BatchService] Warning: Failed to initialize connections for job %s: %v\n", job.ID, err)
+	}
+
+	// Renew the leases on claimed items while the job runs
+	heartbeat, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go s.renewLeases(heartbeat, job.ID)
+
+	// Connections with workers running; each reports on finished when its
+	// workers found nothing left to claim
+	active := make(map[string]bool)
+	finished := make(chan string)
+	defer func() {
+		// Let in-flight items settle before the job is left
+		for len(active) > 0 {
+			delete(active, <-finished)
+		}
+	}()
+
+	for {
+		connections, err := s.repo.ClaimableConnections(ctx, job.ID)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Printf("[BatchService] Failed to load items of job %s: %v\n", job.ID, err)
+			}
+			return false
+		}
+		for _, connectionID := range connections {
+			if active[connectionID] {
+				continue
+			}
+			active[connectionID] = true
+			go func(connectionID string) {
+				s.processConnection(ctx, job, connectionID)
+				finished <- connectionID
+			}(connectionID)
+		}
+
+		if len(active) == 0 {
+			progress, err := s.repo.Progress(ctx, job.ID)
+			if err != nil {
+				if ctx.Err() == nil {
+					fmt.Printf("[BatchService] Failed to load progress of job %s: %v\n", job.ID, err)
+				}
+				return false
+			}
+			if progress.Pending == 0 && progress.Running == 0 {
+				return true
+			}
+		}
+
+		timer := time.NewTimer(s.poll)
+		select {
+		case connectionID := <-finished:
+			delete(active, connectionID)
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false // Cancelled; Cancel settles the remaining items
+		}
+		timer.Stop()
+	}
+}
+
+// processConnection runs the items routed to a connection on the job's
+// number of workers until none is claimable
+func (s *BatchService) processConnection(ctx context.Context, job models.BatchJob, connectionID string) {
+	var wg sync.WaitGroup
+	for i := 0; i < job.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				items, err := s.repo.ClaimItems(ctx, job.ID, connectionID, s.owner, s.lease, 1)
+				if err != nil {
+					if ctx.Err() == nil {
+						fmt.Printf("[BatchService] Failed to claim items of job %s: %v\n", job.ID, err)
+					}
+					return
+				}
+				if len(items) == 0 {
+					return
+				}
+				s.processItem(ctx, job, items[0])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// renewLeases extends the leases on a job's claimed items until ctx ends
+func (s *BatchService) renewLeases(ctx context.Context, jobID uuid.UUID) {
+	ticker := time.NewTicker(s.lease / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.repo.ExtendLeases(ctx, jobID, s.owner, s.lease); err != nil && ctx.Err() == nil {
+				fmt.Printf("[BatchService] Warning: Failed to renew leases of job %s: %v\n", jobID, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// processItem makes one attempt at a claimed item and records the outcome
+func (s *BatchService) processItem(ctx context.Context, job models.BatchJob, item models.BatchItem) {
+	var req llm.CompletionRequest
+	if err := json.Unmarshal(item.Request, &req); err != nil {
+		s.finishItem(item, models.BatchItemFailed, nil, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+
+	resp, err := s.llm.Complete(ctx, job.UserID.String(), req)
+	if err == nil {
+		response, err := json.Marshal(resp)
+		if err != nil {
+			s.finishItem(item, models.BatchItemFailed, nil, fmt.Sprintf("failed to encode response: %v", err))
+			return
+		}
+		s.finishItem(item, models.BatchItemSucceeded, response, "")
+		return
+	}
+
+	// Cancelled mid-attempt; Cancel settles the item
+	if ctx.Err() != nil {
+		return
+	}
+
+	// Requeue rather than wait out the backoff, so the worker moves on to
+	// the next item meanwhile
+	if item.Attempts < job.MaxAttempts && retryableBatchError(err) {
+		retryAt := time.Now().Add(s.retry.Backoff(item.Attempts-1, err))
+		if err := s.repo.RequeueItem(ctx, item.ID, s.owner, err.Error(), retryAt); err != nil {
+			fmt.Printf("[BatchService] Failed to requeue item %s: %v\n", item.ID, err)
+		}
+		return
+	}
+
+	s.finishItem(item, models.BatchItemFailed, nil, err.Error())
+}
+
+// finishItem records an item's outcome; it outlives cancellation so finished
+// work is never lost
+func (s *BatchService) finishItem(item models.BatchItem, status string, response []byte, errMsg string) {
+	if err := s.repo.FinishItem(context.Background(), item.ID, s.owner, status, response, errMsg); err != nil {
+		fmt.Printf("[BatchService] Failed to record item %s: %v\n", item.ID, err)
+	}
+}
+
+// retryableBatchError reports whether a failed item is worth another attempt:
+// transient provider failures and open circuit breakers, which close again
+// while the rest of the batch runs
+func retryableBatchError(err error) bool {
+	return llm.IsRetryableError(err) || errors.Is(err, llm.ErrCircuitOpen)
+}
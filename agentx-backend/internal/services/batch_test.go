@@ -0,0 +1,537 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/llm"
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBatchInputReadsRequestsWithLineNumbers(t *testing.T) {
+	input := strings.Join([]string{
+		`{"custom_id":"ticket-1","task":"custom","parameters":{"user_prompt":"Classify: refund"}}`,
+		``,
+		`{"task":"summarize","connection_id":"conn-a","context":{"session_id":"s1"}}`,
+	}, "\n")
+
+	items, err := ParseBatchInput(strings.NewReader(input))
+	assert.NoError(t, err)
+	if assert.Len(t, items, 2) {
+		assert.Equal(t, "ticket-1", items[0].CustomID)
+		assert.Equal(t, 1, items[0].Line)
+		assert.Equal(t, 3, items[1].Line, "blank lines keep their numbers")
+
+		var req llm.CompletionRequest
+		assert.NoError(t, json.Unmarshal(items[0].Request, &req))
+		assert.Equal(t, llm.TaskCustom, req.Task)
+		assert.Equal(t, "Classify: refund", req.Parameters.UserPrompt)
+		assert.NotContains(t, string(items[0].Request), "custom_id")
+		assert.Contains(t, string(items[1].Request), `"connection_id":"conn-a"`, "items are claimed by the connection they name")
+	}
+}
+
+func TestParseBatchInputRejectsInvalidLines(t *testing.T) {
+	_, err := ParseBatchInput(strings.NewReader("{\"task\":\"custom\"}\n{\"parameters\":{}}\n"))
+	assert.True(t, errors.Is(err, ErrInvalidBatch))
+	assert.ErrorContains(t, err, "line 2")
+
+	_, err = ParseBatchInput(strings.NewReader("not json"))
+	assert.ErrorContains(t, err, "line 1")
+
+	_, err = ParseBatchInput(strings.NewReader("\n\n"))
+	assert.ErrorContains(t, err, "no requests")
+}
+
+// memBatchStore keeps jobs and items in memory, leasing items like the
+// Postgres repository does
+type memBatchStore struct {
+	mu      sync.Mutex
+	jobs    map[uuid.UUID]*models.BatchJob
+	items   []*models.BatchItem
+	owners  map[uuid.UUID]string
+	leases  map[uuid.UUID]time.Time
+	retries map[uuid.UUID]time.Time
+}
+
+func newMemBatchStore() *memBatchStore {
+	return &memBatchStore{
+		jobs:    make(map[uuid.UUID]*models.BatchJob),
+		owners:  make(map[uuid.UUID]string),
+		leases:  make(map[uuid.UUID]time.Time),
+		retries: make(map[uuid.UUID]time.Time),
+	}
+}
+
+func (m *memBatchStore) CreateJob(ctx context.Context, job *models.BatchJob, items []models.BatchItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.ID = uuid.New()
+	job.TotalItems = len(items)
+	stored := *job
+	m.jobs[job.ID] = &stored
+	for i := range items {
+		items[i].ID = uuid.New()
+		items[i].JobID = job.ID
+		items[i].Status = models.BatchItemPending
+		item := items[i]
+		m.items = append(m.items, &item)
+	}
+	return nil
+}
+
+func (m *memBatchStore) GetJob(ctx context.Context, jobID uuid.UUID) (*models.BatchJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[jobID]
+	if !ok {
+		return nil, nil
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (m *memBatchStore) ListJobs(ctx context.Context, userID uuid.UUID) ([]models.BatchJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var jobs []models.BatchJob
+	for _, job := range m.jobs {
+		if job.UserID == userID {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs, nil
+}
+
+func (m *memBatchStore) ListUnfinished(ctx context.Context) ([]models.BatchJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var jobs []models.BatchJob
+	for _, job := range m.jobs {
+		if job.Status == models.BatchJobQueued || job.Status == models.BatchJobRunning {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs, nil
+}
+
+func (m *memBatchStore) Progress(ctx context.Context, jobID uuid.UUID) (models.BatchProgress, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var progress models.BatchProgress
+	for _, item := range m.items {
+		if item.JobID != jobID {
+			continue
+		}
+		switch item.Status {
+		case models.BatchItemPending:
+			progress.Pending++
+		case models.BatchItemRunning:
+			progress.Running++
+		case models.BatchItemSucceeded:
+			progress.Succeeded++
+		case models.BatchItemFailed:
+			progress.Failed++
+		case models.BatchItemCancelled:
+			progress.Cancelled++
+		}
+	}
+	return progress, nil
+}
+
+func (m *memBatchStore) ListItems(ctx context.Context, jobID uuid.UUID, status string, offset, limit int) ([]models.BatchItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var items []models.BatchItem
+	for _, item := range m.items {
+		if item.JobID == jobID && (status == "" || item.Status == status) {
+			items = append(items, *item)
+		}
+	}
+	if offset > len(items) {
+		offset = len(items)
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func (m *memBatchStore) SetJobStatus(ctx context.Context, jobID uuid.UUID, status string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[jobID].Status = status
+	return nil
+}
+
+func (m *memBatchStore) ClaimItems(ctx context.Context, jobID uuid.UUID, connectionID, owner string, lease time.Duration, limit int) ([]models.BatchItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var claimed []models.BatchItem
+	for _, item := range m.items {
+		if len(claimed) == limit {
+			break
+		}
+		if item.JobID != jobID || itemConnection(item) != connectionID || !m.claimable(item) {
+			continue
+		}
+		item.Status = models.BatchItemRunning
+		item.Attempts++
+		m.owners[item.ID] = owner
+		m.leases[item.ID] = time.Now().Add(lease)
+		delete(m.retries, item.ID)
+		claimed = append(claimed, *item)
+	}
+	return claimed, nil
+}
+
+func (m *memBatchStore) ClaimableConnections(ctx context.Context, jobID uuid.UUID) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seen := make(map[string]bool)
+	var connections []string
+	for _, item := range m.items {
+		if connection := itemConnection(item); item.JobID == jobID && m.claimable(item) && !seen[connection] {
+			seen[connection] = true
+			connections = append(connections, connection)
+		}
+	}
+	return connections, nil
+}
+
+// claimable reports whether an item is pending and due, or its lease expired
+func (m *memBatchStore) claimable(item *models.BatchItem) bool {
+	switch item.Status {
+	case models.BatchItemPending:
+		return !time.Now().Before(m.retries[item.ID])
+	case models.BatchItemRunning:
+		return time.Now().After(m.leases[item.ID])
+	}
+	return false
+}
+
+func itemConnection(item *models.BatchItem) string {
+	var req llm.CompletionRequest
+	_ = json.Unmarshal(item.Request, &req)
+	return req.ConnectionID
+}
+
+func (m *memBatchStore) ExtendLeases(ctx context.Context, jobID uuid.UUID, owner string, lease time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, item := range m.items {
+		if item.JobID == jobID && item.Status == models.BatchItemRunning && m.owners[item.ID] == owner {
+			m.leases[item.ID] = time.Now().Add(lease)
+		}
+	}
+	return nil
+}
+
+func (m *memBatchStore) FinishItem(ctx context.Context, itemID uuid.UUID, owner, status string, response []byte, errMsg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if item := m.leased(itemID, owner); item != nil {
+		item.Status, item.Response, item.Error = status, response, errMsg
+		delete(m.owners, itemID)
+	}
+	return nil
+}
+
+func (m *memBatchStore) RequeueItem(ctx context.Context, itemID uuid.UUID, owner, errMsg string, retryAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if item := m.leased(itemID, owner); item != nil {
+		item.Status, item.Error = models.BatchItemPending, errMsg
+		m.retries[itemID] = retryAt
+		delete(m.owners, itemID)
+	}
+	return nil
+}
+
+// leased returns an item the owner holds, nil once the lease was lost
+func (m *memBatchStore) leased(itemID uuid.UUID, owner string) *models.BatchItem {
+	if m.owners[itemID] != owner {
+		return nil
+	}
+	for _, item := range m.items {
+		if item.ID == itemID {
+			return item
+		}
+	}
+	return nil
+}
+
+func (m *memBatchStore) CompleteJob(ctx context.Context, jobID uuid.UUID) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.jobs[jobID].Status != models.BatchJobRunning {
+		return false, nil
+	}
+	m.jobs[jobID].Status = models.BatchJobCompleted
+	return true, nil
+}
+
+func (m *memBatchStore) CancelJob(ctx context.Context, jobID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, item := range m.items {
+		if item.JobID == jobID && (item.Status == models.BatchItemPending || item.Status == models.BatchItemRunning) {
+			item.Status = models.BatchItemCancelled
+			delete(m.owners, item.ID)
+		}
+	}
+	m.jobs[jobID].Status = models.BatchJobCancelled
+	return nil
+}
+
+func (m *memBatchStore) RequeueFailed(ctx context.Context, jobID uuid.UUID) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var requeued int64
+	for _, item := range m.items {
+		if item.JobID == jobID && (item.Status == models.BatchItemFailed || item.Status == models.BatchItemCancelled) {
+			item.Status, item.Attempts, item.Error, item.Response = models.BatchItemPending, 0, "", nil
+			delete(m.retries, item.ID)
+			requeued++
+		}
+	}
+	if requeued > 0 {
+		m.jobs[jobID].Status = models.BatchJobQueued
+	}
+	return requeued, nil
+}
+
+// scriptedCompleter answers each prompt with the errors listed for it, in
+// turn, and then succeeds. A nil script entry blocks until cancelled.
+type scriptedCompleter struct {
+	mu      sync.Mutex
+	scripts map[string][]error
+	calls   map[string]int
+}
+
+func (c *scriptedCompleter) Complete(ctx context.Context, userID string, req llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	prompt := req.Parameters.UserPrompt
+	c.mu.Lock()
+	call := c.calls[prompt]
+	c.calls[prompt]++
+	script := c.scripts[prompt]
+	c.mu.Unlock()
+
+	if call < len(script) {
+		if script[call] == nil {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return nil, script[call]
+	}
+	return &llm.CompletionResponse{Result: "done: " + prompt}, nil
+}
+
+// countingConnections records whose connections were initialized
+type countingConnections struct {
+	mu    sync.Mutex
+	users []uuid.UUID
+}
+
+func (c *countingConnections) InitializeUserConnections(ctx context.Context, userID uuid.UUID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.users = append(c.users, userID)
+	return nil
+}
+
+func newTestBatchService(store *memBatchStore, completer *scriptedCompleter) (*BatchService, *countingConnections) {
+	if completer.calls == nil {
+		completer.calls = make(map[string]int)
+	}
+	connections := &countingConnections{}
+	return &BatchService{
+		repo:        store,
+		llm:         completer,
+		connections: connections,
+		owner:       "test-instance",
+		lease:       40 * time.Millisecond,
+		poll:        5 * time.Millisecond,
+		running:     make(map[uuid.UUID]*batchRun),
+	}, connections
+}
+
+func batchInput(prompts ...string) io.Reader {
+	return batchInputOn("", prompts...)
+}
+
+// batchInputOn builds requests routed to a connection
+func batchInputOn(connectionID string, prompts ...string) io.Reader {
+	var lines []string
+	for _, prompt := range prompts {
+		lines = append(lines, fmt.Sprintf(`{"custom_id":%q,"task":"custom","connection_id":%q,"parameters":{"user_prompt":%q}}`, prompt, connectionID, prompt))
+	}
+	return strings.NewReader(strings.Join(lines, "\n"))
+}
+
+// waitForJob waits until a job is no longer queued or running
+func waitForJob(t *testing.T, s *BatchService, userID, jobID uuid.UUID) *models.BatchJobStatus {
+	var status *models.BatchJobStatus
+	assert.Eventually(t, func() bool {
+		var err error
+		status, err = s.Get(context.Background(), userID, jobID)
+		return err == nil && status.Status != models.BatchJobQueued && status.Status != models.BatchJobRunning
+	}, 2*time.Second, 5*time.Millisecond)
+	return status
+}
+
+func itemsByCustomID(t *testing.T, store *memBatchStore, jobID uuid.UUID) map[string]models.BatchItem {
+	items, err := store.ListItems(context.Background(), jobID, "", 0, 0)
+	assert.NoError(t, err)
+	byID := make(map[string]models.BatchItem)
+	for _, item := range items {
+		byID[item.CustomID] = item
+	}
+	return byID
+}
+
+func TestBatchProcessRetriesTransientFailuresPerItem(t *testing.T) {
+	store := newMemBatchStore()
+	s, connections := newTestBatchService(store, &scriptedCompleter{scripts: map[string][]error{
+		"flaky":   {errors.New("503 service unavailable")},
+		"invalid": {errors.New("invalid_request: bad prompt")},
+		"down":    {errors.New("connection refused"), errors.New("connection refused")},
+	}})
+	userID := uuid.New()
+
+	created, err := s.Create(context.Background(), userID, models.BatchJobCreateRequest{MaxAttempts: 2}, batchInput("ok", "flaky", "invalid", "down"))
+	assert.NoError(t, err)
+	status := waitForJob(t, s, userID, created.ID)
+
+	assert.Equal(t, models.BatchJobCompleted, status.Status)
+	assert.Equal(t, models.BatchProgress{Succeeded: 2, Failed: 2}, status.Progress)
+	assert.Equal(t, []uuid.UUID{userID}, connections.users, "the owner's connections are initialized once per job")
+
+	items := itemsByCustomID(t, store, created.ID)
+	assert.Equal(t, 1, items["ok"].Attempts)
+	assert.Equal(t, models.BatchItemSucceeded, items["flaky"].Status)
+	assert.Equal(t, 2, items["flaky"].Attempts)
+	assert.Equal(t, models.BatchItemFailed, items["invalid"].Status)
+	assert.Equal(t, 1, items["invalid"].Attempts, "permanent errors are not retried")
+	assert.Equal(t, models.BatchItemFailed, items["down"].Status)
+	assert.Equal(t, 2, items["down"].Attempts, "retries stop at max_attempts")
+	assert.Equal(t, "connection refused", items["down"].Error)
+}
+
+func TestBatchProcessMovesOnWhileARetryBacksOff(t *testing.T) {
+	store := newMemBatchStore()
+	s, _ := newTestBatchService(store, &scriptedCompleter{scripts: map[string][]error{
+		"flaky": {errors.New("503 service unavailable")},
+	}})
+	s.retry = llm.RetryPolicy{BaseDelay: 400 * time.Millisecond}
+	userID := uuid.New()
+
+	created, err := s.Create(context.Background(), userID, models.BatchJobCreateRequest{Concurrency: 1}, batchInput("flaky", "next"))
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return itemsByCustomID(t, store, created.ID)["next"].Status == models.BatchItemSucceeded
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, models.BatchItemPending, itemsByCustomID(t, store, created.ID)["flaky"].Status,
+		"the only worker runs the next item while the retry waits")
+
+	status := waitForJob(t, s, userID, created.ID)
+	assert.Equal(t, models.BatchJobCompleted, status.Status)
+	assert.Equal(t, models.BatchProgress{Succeeded: 2}, status.Progress)
+}
+
+func TestBatchProcessKeepsConnectionsIndependent(t *testing.T) {
+	store := newMemBatchStore()
+	s, _ := newTestBatchService(store, &scriptedCompleter{scripts: map[string][]error{
+		"stuck": {nil},
+		"b":     {errors.New("503 service unavailable")},
+	}})
+	userID := uuid.New()
+
+	items, err := ParseBatchInput(io.MultiReader(batchInputOn("slow", "stuck", "queued"), strings.NewReader("\n"), batchInputOn("fast", "a", "b", "c")))
+	assert.NoError(t, err)
+	job := &models.BatchJob{UserID: userID, Status: models.BatchJobQueued, Concurrency: 1, MaxAttempts: 2}
+	assert.NoError(t, store.CreateJob(context.Background(), job, items))
+	s.start(*job)
+
+	assert.Eventually(t, func() bool {
+		byID := itemsByCustomID(t, store, job.ID)
+		return byID["a"].Status == models.BatchItemSucceeded && byID["b"].Status == models.BatchItemSucceeded &&
+			byID["c"].Status == models.BatchItemSucceeded
+	}, 2*time.Second, 5*time.Millisecond, "the fast connection drains, retries included, while the slow one is stuck")
+	byID := itemsByCustomID(t, store, job.ID)
+	assert.Equal(t, 2, byID["b"].Attempts)
+	assert.Equal(t, models.BatchItemPending, byID["queued"].Status)
+
+	status, err := s.Cancel(context.Background(), userID, job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.BatchProgress{Succeeded: 3, Cancelled: 2}, status.Progress)
+}
+
+func TestBatchCancelSettlesUnfinishedItems(t *testing.T) {
+	store := newMemBatchStore()
+	s, _ := newTestBatchService(store, &scriptedCompleter{scripts: map[string][]error{
+		"stuck": {nil},
+	}})
+	userID := uuid.New()
+
+	created, err := s.Create(context.Background(), userID, models.BatchJobCreateRequest{Concurrency: 1}, batchInput("ok", "stuck", "later"))
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return itemsByCustomID(t, store, created.ID)["ok"].Status == models.BatchItemSucceeded
+	}, 2*time.Second, 5*time.Millisecond)
+
+	status, err := s.Cancel(context.Background(), userID, created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.BatchJobCancelled, status.Status)
+	assert.Equal(t, models.BatchProgress{Succeeded: 1, Cancelled: 2}, status.Progress)
+
+	_, err = s.Cancel(context.Background(), userID, created.ID)
+	assert.True(t, errors.Is(err, ErrInvalidBatch), "a cancelled job cannot be cancelled again")
+}
+
+func TestBatchResumeTakesOverExpiredLeases(t *testing.T) {
+	store := newMemBatchStore()
+	s, _ := newTestBatchService(store, &scriptedCompleter{})
+	userID := uuid.New()
+
+	job := &models.BatchJob{UserID: userID, Status: models.BatchJobRunning, Concurrency: 2, MaxAttempts: 3}
+	items, err := ParseBatchInput(batchInput("elsewhere", "abandoned", "pending"))
+	assert.NoError(t, err)
+	assert.NoError(t, store.CreateJob(context.Background(), job, items))
+
+	// One item is being worked on elsewhere, another was left by a stopped
+	// instance
+	_, err = store.ClaimItems(context.Background(), job.ID, "", "other-instance", time.Hour, 1)
+	assert.NoError(t, err)
+	_, err = store.ClaimItems(context.Background(), job.ID, "", "stopped-instance", -time.Second, 1)
+	assert.NoError(t, err)
+
+	s.resume(context.Background())
+	assert.Eventually(t, func() bool {
+		byID := itemsByCustomID(t, store, job.ID)
+		return byID["abandoned"].Status == models.BatchItemSucceeded && byID["pending"].Status == models.BatchItemSucceeded
+	}, 2*time.Second, 5*time.Millisecond)
+
+	byID := itemsByCustomID(t, store, job.ID)
+	assert.Equal(t, models.BatchItemRunning, byID["elsewhere"].Status, "live leases are left alone")
+	assert.Equal(t, 2, byID["abandoned"].Attempts, "the interrupted attempt counts")
+	status, err := s.Get(context.Background(), userID, job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.BatchJobRunning, status.Status, "the job waits for items leased elsewhere")
+
+	// The other instance finishes its item, and the job completes
+	assert.NoError(t, store.FinishItem(context.Background(), byID["elsewhere"].ID, "other-instance", models.BatchItemSucceeded, nil, ""))
+	status = waitForJob(t, s, userID, job.ID)
+	assert.Equal(t, models.BatchJobCompleted, status.Status)
+	assert.Equal(t, models.BatchProgress{Succeeded: 3}, status.Progress)
+}
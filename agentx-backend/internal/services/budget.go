@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/llm"
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/agentx/agentx-backend/internal/repository/postgres"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidBudget is returned for budgets that fail validation
+var ErrInvalidBudget = errors.New("invalid budget")
+
+// BudgetService manages spend caps and token quotas and serves them to the
+// LLM gateway's budget middleware
+type BudgetService struct {
+	repo    *postgres.BudgetRepository
+	gateway *llm.Gateway
+}
+
+// NewBudgetService creates a new budget service and registers it as the
+// gateway's budget store
+func NewBudgetService(repo *postgres.BudgetRepository, gateway *llm.Gateway) *BudgetService {
+	s := &BudgetService{
+		repo:    repo,
+		gateway: gateway,
+	}
+	if gateway != nil {
+		gateway.SetBudgetStore(s)
+	}
+	return s
+}
+
+// BudgetsFor implements llm.BudgetStore
+func (s *BudgetService) BudgetsFor(ctx context.Context, userID, apiKeyID, connectionID string) ([]llm.Budget, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		// Requests without a real user (e.g. internal tasks) carry no budgets
+		return nil, nil
+	}
+
+	var keyID, connID *uuid.UUID
+	if id, err := uuid.Parse(apiKeyID); err == nil {
+		keyID = &id
+	}
+	if id, err := uuid.Parse(connectionID); err == nil {
+		connID = &id
+	}
+
+	budgets, err := s.repo.ListApplicable(ctx, uid, keyID, connID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]llm.Budget, 0, len(budgets))
+	for _, budget := range budgets {
+		result = append(result, toGatewayBudget(budget))
+	}
+	return result, nil
+}
+
+// SpendSince implements llm.BudgetStore
+func (s *BudgetService) SpendSince(ctx context.Context, budget llm.Budget, since time.Time) (float64, error) {
+	scopeID, err := uuid.Parse(budget.ScopeID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid budget scope ID: %w", err)
+	}
+	return s.repo.SpendSince(ctx, string(budget.Scope), scopeID, since)
+}
+
+// List returns all budgets, optionally only those owned by a user
+func (s *BudgetService) List(ctx context.Context, userID *uuid.UUID) ([]models.Budget, error) {
+	return s.repo.List(ctx, userID)
+}
+
+// Create creates a budget on a user, API key or connection
+func (s *BudgetService) Create(ctx context.Context, req *models.BudgetCreateRequest) (*models.Budget, error) {
+	budget := &models.Budget{
+		Scope:           req.Scope,
+		ScopeID:         req.ScopeID,
+		Period:          req.Period,
+		SpendLimit:      req.SpendLimit,
+		WarnThreshold:   0.8,
+		TokensPerMinute: req.TokensPerMinute,
+		Action:          req.Action,
+		DowngradeModel:  req.DowngradeModel,
+		Enabled:         true,
+	}
+	if budget.Period == "" {
+		budget.Period = "monthly"
+	}
+	if budget.Action == "" {
+		budget.Action = llm.BudgetActionReject
+	}
+	if req.WarnThreshold != nil {
+		budget.WarnThreshold = *req.WarnThreshold
+	}
+	if req.Enabled != nil {
+		budget.Enabled = *req.Enabled
+	}
+
+	if err := validateBudget(budget); err != nil {
+		return nil, err
+	}
+	owner, err := s.repo.ScopeOwner(ctx, budget.Scope, budget.ScopeID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBudget, err)
+	}
+	budget.UserID = owner
+
+	if err := s.repo.Create(ctx, budget); err != nil {
+		return nil, err
+	}
+
+	s.gateway.InvalidateBudgets()
+	return budget, nil
+}
+
+// Update updates a budget's limits and action. The scope cannot change.
+func (s *BudgetService) Update(ctx context.Context, budgetID uuid.UUID, req *models.BudgetUpdateRequest) (*models.Budget, error) {
+	budget, err := s.repo.Get(ctx, budgetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Period != nil {
+		budget.Period = *req.Period
+	}
+	if req.SpendLimit != nil {
+		budget.SpendLimit = *req.SpendLimit
+	}
+	if req.WarnThreshold != nil {
+		budget.WarnThreshold = *req.WarnThreshold
+	}
+	if req.TokensPerMinute != nil {
+		budget.TokensPerMinute = *req.TokensPerMinute
+	}
+	if req.Action != nil {
+		budget.Action = *req.Action
+	}
+	if req.DowngradeModel != nil {
+		budget.DowngradeModel = *req.DowngradeModel
+	}
+	if req.Enabled != nil {
+		budget.Enabled = *req.Enabled
+	}
+
+	if err := validateBudget(budget); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, budget); err != nil {
+		return nil, err
+	}
+
+	s.gateway.InvalidateBudgets()
+	return budget, nil
+}
+
+// Delete deletes a budget
+func (s *BudgetService) Delete(ctx context.Context, budgetID uuid.UUID) error {
+	if err := s.repo.Delete(ctx, budgetID); err != nil {
+		return err
+	}
+
+	s.gateway.InvalidateBudgets()
+	return nil
+}
+
+// Status returns the budgets on a user, their API keys and connections
+// together with the spend in each budget's current period
+func (s *BudgetService) Status(ctx context.Context, userID uuid.UUID) ([]models.BudgetStatus, error) {
+	budgets, err := s.repo.List(ctx, &userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	statuses := make([]models.BudgetStatus, 0, len(budgets))
+	for _, budget := range budgets {
+		period := toGatewayBudget(budget)
+		status := models.BudgetStatus{
+			Budget:      budget,
+			PeriodStart: period.PeriodStart(now),
+			ResetAt:     period.PeriodEnd(now),
+		}
+		if budget.SpendLimit > 0 {
+			spent, err := s.repo.SpendSince(ctx, budget.Scope, budget.ScopeID, status.PeriodStart)
+			if err != nil {
+				return nil, err
+			}
+			status.Spent = spent
+			status.Remaining = math.Max(budget.SpendLimit-spent, 0)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// validateBudget checks a budget's scope, period, limits and action
+func validateBudget(budget *models.Budget) error {
+	switch llm.BudgetScope(budget.Scope) {
+	case llm.BudgetScopeUser, llm.BudgetScopeAPIKey, llm.BudgetScopeConnection:
+	default:
+		return fmt.Errorf("%w: scope must be user, api_key or connection", ErrInvalidBudget)
+	}
+	if budget.ScopeID == uuid.Nil {
+		return fmt.Errorf("%w: scope_id is required", ErrInvalidBudget)
+	}
+	if budget.Period != "daily" && budget.Period != "monthly" {
+		return fmt.Errorf("%w: period must be daily or monthly", ErrInvalidBudget)
+	}
+	if budget.SpendLimit < 0 || budget.TokensPerMinute < 0 {
+		return fmt.Errorf("%w: limits cannot be negative", ErrInvalidBudget)
+	}
+	if budget.SpendLimit == 0 && budget.TokensPerMinute == 0 {
+		return fmt.Errorf("%w: a spend_limit or tokens_per_minute is required", ErrInvalidBudget)
+	}
+	if budget.WarnThreshold < 0 || budget.WarnThreshold > 1 {
+		return fmt.Errorf("%w: warn_threshold must be between 0 and 1", ErrInvalidBudget)
+	}
+	switch budget.Action {
+	case llm.BudgetActionReject:
+	case llm.BudgetActionDowngrade:
+		if budget.DowngradeModel == "" {
+			return fmt.Errorf("%w: downgrade_model is required to downgrade", ErrInvalidBudget)
+		}
+	default:
+		return fmt.Errorf("%w: action must be reject or downgrade", ErrInvalidBudget)
+	}
+	return nil
+}
+
+// toGatewayBudget converts a persisted budget into the gateway representation
+func toGatewayBudget(budget models.Budget) llm.Budget {
+	return llm.Budget{
+		ID:              budget.ID.String(),
+		Scope:           llm.BudgetScope(budget.Scope),
+		ScopeID:         budget.ScopeID.String(),
+		Period:          budget.Period,
+		SpendLimit:      budget.SpendLimit,
+		WarnThreshold:   budget.WarnThreshold,
+		TokensPerMinute: budget.TokensPerMinute,
+		Action:          budget.Action,
+		DowngradeModel:  budget.DowngradeModel,
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/api/models"
+	"github.com/agentx/agentx-backend/internal/llm"
+	"github.com/agentx/agentx-backend/internal/providers"
+	"github.com/agentx/agentx-backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+// attachImages moves the request's top-level images onto its last user
+// message, so they are routed, sent and stored with that message
+func attachImages(req models.UnifiedChatRequest) models.UnifiedChatRequest {
+	if len(req.Images) == 0 {
+		return req
+	}
+
+	messages := append([]providers.Message(nil), req.Messages...)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
+			continue
+		}
+		contentArray := append([]interface{}(nil), messages[i].ContentArray...)
+		for _, image := range req.Images {
+			if url := imageURL(image); url != "" {
+				contentArray = append(contentArray, providers.ImageContent(url))
+			}
+		}
+		messages[i].ContentArray = contentArray
+		req.Messages = messages
+		req.Images = nil
+		break
+	}
+	return req
+}
+
+// imageURL returns an image's URL, encoding base64 images as data URLs
+func imageURL(image models.Image) string {
+	if image.URL != "" {
+		return image.URL
+	}
+	if image.Base64 == "" || strings.HasPrefix(image.Base64, "data:") {
+		return image.Base64
+	}
+	mimeType := image.Type
+	if mimeType == "" {
+		mimeType = "image/png"
+	}
+	return "data:" + mimeType + ";base64," + image.Base64
+}
+
+// messageContent splits a message into its text and images
+func messageContent(msg providers.Message) (string, []llm.Image) {
+	if len(msg.ContentArray) == 0 {
+		return msg.Content, nil
+	}
+
+	var text []string
+	var images []llm.Image
+	for _, part := range msg.Parts() {
+		if !part.IsImage() {
+			text = append(text, part.Text)
+			continue
+		}
+		images = append(images, llm.Image{URL: part.ImageURL, Data: part.Data, MimeType: part.MediaType})
+	}
+	return strings.Join(text, "\n"), images
+}
+
+// userMessageRecord builds the stored form of a user message; its images
+// are kept in the message metadata so the session replays them
+func userMessageRecord(sessionID string, msg providers.Message) repository.Message {
+	content, images := messageContent(msg)
+	record := repository.Message{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		Role:      msg.Role,
+		Content:   content,
+		CreatedAt: time.Now(),
+	}
+	if len(images) > 0 {
+		if data, err := json.Marshal(map[string]interface{}{"images": images}); err == nil {
+			record.Metadata = data
+		}
+	}
+	return record
+}
+
+// storedImages returns the images recorded in a stored message's metadata
+// as content array items
+func storedImages(metadata []byte) []interface{} {
+	if len(metadata) == 0 {
+		return nil
+	}
+	var stored struct {
+		Images []llm.Image `json:"images"`
+	}
+	if err := json.Unmarshal(metadata, &stored); err != nil {
+		return nil
+	}
+
+	var items []interface{}
+	for _, image := range stored.Images {
+		items = append(items, providers.ImageContent(image.DataURL()))
+	}
+	return items
+}
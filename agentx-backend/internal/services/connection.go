@@ -252,6 +252,7 @@ func (s *ConnectionService) TestConnection(ctx context.Context, userID uuid.UUID
 		Name:    conn.Name,
 		APIKey:  getStringFromMap(conn.Config, "api_key"),
 		BaseURL: getStringFromMap(conn.Config, "base_url"),
+		Extra:   conn.Config,
 	}
 	
 	provider, err := factory.CreateProvider(conn.ProviderID, providerConfig)
@@ -341,7 +342,9 @@ func (s *ConnectionService) initializeProvider(userID uuid.UUID, conn *repositor
 		APIKey:       getStringFromMap(conn.Config, "api_key"),
 		BaseURL:      getStringFromMap(conn.Config, "base_url"),
 		Organization: getStringFromMap(conn.Config, "organization"),
+		Settings:     conn.Config,
 	}
+	gatewayConfig.ApplyConnectionSettings(conn.Config)
 	
 	// Register with Gateway (SINGLE SOURCE OF TRUTH)
 	fmt.Printf("[initializeProvider] Registering with Gateway - UserID: %s, ConnectionID: %s\n", userID.String(), conn.ID)
@@ -357,9 +360,8 @@ func (s *ConnectionService) initializeProvider(userID uuid.UUID, conn *repositor
 
 // EnsureConnectionInitialized ensures a specific connection is initialized
 func (s *ConnectionService) EnsureConnectionInitialized(ctx context.Context, userID uuid.UUID, connectionID string) error {
-	// Check if already registered with Gateway by trying to get models
-	models, err := s.gateway.GetAvailableModels(ctx, userID.String())
-	if err == nil && len(models) > 0 {
+	// Check if already registered with Gateway
+	if s.gateway.HasConnection(userID.String(), connectionID) {
 		fmt.Printf("[EnsureConnectionInitialized] Connection %s already registered with Gateway for user %s\n", connectionID, userID.String())
 		return nil
 	}
@@ -402,10 +404,14 @@ func (s *ConnectionService) mapProviderType(dbProviderType string) string {
 	switch dbProviderType {
 	case "anthropic-claude":
 		return "anthropic"
-	case "ollama", "local-llm":
+	case "azure", "azure-openai-service":
+		return "azure-openai"
+	case "google", "google-gemini":
+		return "gemini"
+	case "local-llm":
 		return "local"
 	default:
-		// Return as-is for standard types (openai, openai-compatible, anthropic, local)
+		// Return as-is for standard types (openai, azure-openai, openai-compatible, anthropic, gemini, ollama)
 		return dbProviderType
 	}
 }
@@ -275,6 +275,7 @@ func (s *LLMService) InitializeUserConnections(ctx context.Context, userID uuid.
 				BaseURL:      getStringFromConfig(conn.Config, "base_url"),
 				Organization: getStringFromConfig(conn.Config, "organization"),
 			}
+			config.ApplyConnectionSettings(conn.Config)
 			
 			fmt.Printf("[LLMService.InitializeUserConnections] Registering connection %s (%s)\n", conn.Name, conn.ID.String())
 			
@@ -309,6 +310,7 @@ func (s *LLMService) EnsureConnectionInitialized(ctx context.Context, userID uui
 		BaseURL:      getStringFromConfig(conn.Config, "base_url"),
 		Organization: getStringFromConfig(conn.Config, "organization"),
 	}
+	config.ApplyConnectionSettings(conn.Config)
 	
 	return s.gateway.RegisterProvider(userID.String(), connectionID, config)
 }
@@ -464,6 +466,9 @@ func (s *LLMService) Complete(ctx context.Context, req *llm.CompletionRequest) (
 			Temperature: req.Parameters.Temperature,
 			MaxTokens:   req.Parameters.MaxTokens,
 		}
+		if system, _ := req.Context["system"].(string); system != "" {
+			gatewayReq.Messages = append([]llm.Message{{Role: "system", Content: system}}, gatewayReq.Messages...)
+		}
 
 		// Send through gateway
 		resp, err := s.gateway.Complete(ctx, gatewayReq)
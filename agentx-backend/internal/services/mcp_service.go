@@ -4,16 +4,20 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os/exec"
 	"sync"
 	"time"
 
+	"github.com/agentx/agentx-backend/internal/metrics"
 	"github.com/agentx/agentx-backend/internal/models"
 	"github.com/agentx/agentx-backend/internal/repository/postgres"
+	"github.com/agentx/agentx-backend/internal/tracing"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // MCPService handles MCP server management and communication
@@ -122,7 +126,7 @@ func (s *MCPService) CreateServer(ctx context.Context, userID uuid.UUID, req *mo
 
 	// If enabled, try to connect
 	if server.Enabled {
-		go s.connectServer(server)
+		go s.connectServer(context.WithoutCancel(ctx), server)
 	}
 
 	return server, nil
@@ -153,7 +157,7 @@ func (s *MCPService) UpdateServer(ctx context.Context, userID, serverID uuid.UUI
 	if req.Enabled != nil {
 		if *req.Enabled && !exists {
 			// Server was enabled, connect
-			go s.connectServer(server)
+			go s.connectServer(context.WithoutCancel(ctx), server)
 		} else if !*req.Enabled && exists {
 			// Server was disabled, disconnect
 			s.disconnectServer(serverID)
@@ -161,7 +165,7 @@ func (s *MCPService) UpdateServer(ctx context.Context, userID, serverID uuid.UUI
 	} else if exists && (req.Command != "" || req.Args != nil || req.Env != nil) {
 		// Configuration changed, reconnect
 		s.disconnectServer(serverID)
-		go s.connectServer(server)
+		go s.connectServer(context.WithoutCancel(ctx), server)
 	}
 
 	return server, nil
@@ -191,11 +195,16 @@ func (s *MCPService) ToggleServer(ctx context.Context, userID, serverID uuid.UUI
 }
 
 // ConnectServer establishes a connection to an MCP server
-func (s *MCPService) connectServer(server *models.MCPServer) {
+func (s *MCPService) connectServer(ctx context.Context, server *models.MCPServer) {
 	s.logger.WithField("server", server.Name).Info("Connecting to MCP server")
 
+	ctx, span := tracing.Start(ctx, "mcp.connect",
+		attribute.String("mcp.server", server.ID.String()),
+		attribute.String("mcp.command", server.Command),
+	)
+	defer span.End()
+
 	// Update status to connecting
-	ctx := context.Background()
 	s.repo.UpdateStatus(ctx, server.ID, models.MCPServerStatusConnecting)
 
 	// Create command
@@ -210,6 +219,9 @@ func (s *MCPService) connectServer(server *models.MCPServer) {
 			}
 		}
 	}
+	
+	// Let the server join the trace that started it
+	tracing.InjectEnv(ctx, cmd)
 
 	// Create pipes
 	stdin, err := cmd.StdinPipe()
@@ -228,6 +240,7 @@ func (s *MCPService) connectServer(server *models.MCPServer) {
 
 	// Start the process
 	if err := cmd.Start(); err != nil {
+		tracing.RecordError(span, err)
 		s.logger.WithError(err).Error("Failed to start MCP server")
 		s.repo.UpdateStatus(ctx, server.ID, models.MCPServerStatusError)
 		return
@@ -255,6 +268,7 @@ func (s *MCPService) connectServer(server *models.MCPServer) {
 
 	// Initialize the connection
 	if err := s.initializeConnection(ctx, server.ID); err != nil {
+		tracing.RecordError(span, err)
 		s.logger.WithError(err).Error("Failed to initialize MCP connection")
 		s.disconnectServer(server.ID)
 		s.repo.UpdateStatus(ctx, server.ID, models.MCPServerStatusError)
@@ -503,14 +517,26 @@ func (s *MCPService) CallTool(ctx context.Context, req *models.MCPToolCallReques
 	}
 
 	// Send request
+	server := req.ServerID.String()
+	_, span := tracing.Start(ctx, "mcp.tools/call",
+		attribute.String("mcp.server", server),
+		attribute.String("mcp.tool", req.ToolName),
+	)
+	defer span.End()
+	start := time.Now()
 	resp, err := s.sendRequest(req.ServerID, "tools/call", paramsJSON)
+	metrics.MCPToolCallDuration.WithLabelValues(server, req.ToolName).Observe(time.Since(start).Seconds())
 	if err != nil {
+		tracing.RecordError(span, err)
+		metrics.MCPToolCalls.WithLabelValues(server, req.ToolName, "error").Inc()
 		return nil, err
 	}
 
 	// Handle response
 	response := &models.MCPToolCallResponse{}
 	if resp.Error != nil {
+		tracing.RecordError(span, errors.New(resp.Error.Message))
+		metrics.MCPToolCalls.WithLabelValues(server, req.ToolName, "error").Inc()
 		errorMsg := resp.Error.Message
 		response.Error = &errorMsg
 	} else {
@@ -519,6 +545,7 @@ func (s *MCPService) CallTool(ctx context.Context, req *models.MCPToolCallReques
 			return nil, err
 		}
 		response.Result = result
+		metrics.MCPToolCalls.WithLabelValues(server, req.ToolName, "success").Inc()
 	}
 
 	// Update tool usage
@@ -577,7 +604,7 @@ func (s *MCPService) ConnectEnabledServers(ctx context.Context, userID uuid.UUID
 
 	for _, server := range servers {
 		if server.Enabled {
-			go s.connectServer(&server)
+			go s.connectServer(context.WithoutCancel(ctx), &server)
 		}
 	}
 }
\ No newline at end of file
@@ -7,7 +7,9 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/agentx/agentx-backend/internal/llm"
 	"github.com/agentx/agentx-backend/internal/mcp"
+	"github.com/agentx/agentx-backend/internal/models"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
@@ -16,17 +18,19 @@ import (
 type MCPToolIntegration struct {
 	builtinManager *mcp.BuiltinMCPManager
 	mcpService     *MCPService
+	prompts        *llm.PromptLibrary
 	logger         *logrus.Logger
 }
 
 // NewMCPToolIntegration creates a new MCP tool integration service
-func NewMCPToolIntegration(builtinManager *mcp.BuiltinMCPManager, mcpService *MCPService) *MCPToolIntegration {
+func NewMCPToolIntegration(builtinManager *mcp.BuiltinMCPManager, mcpService *MCPService, prompts *llm.PromptLibrary) *MCPToolIntegration {
 	logger := logrus.New()
 	logger.SetLevel(logrus.InfoLevel)
 	
 	return &MCPToolIntegration{
 		builtinManager: builtinManager,
 		mcpService:     mcpService,
+		prompts:        prompts,
 		logger:         logger,
 	}
 }
@@ -289,7 +293,7 @@ func (m *MCPToolIntegration) InvokeToolForUser(ctx context.Context, userID uuid.
 		// Ensure the builtin server is enabled for the user
 		if !m.builtinManager.IsServerEnabledForUser(userID, invocation.ServerID) {
 			// Try to enable it
-			if err := m.builtinManager.SetUserServerEnabled(userID, invocation.ServerID, true); err != nil {
+			if err := m.builtinManager.SetUserServerEnabled(ctx, userID, invocation.ServerID, true); err != nil {
 				return &ToolResult{
 					Success: false,
 					Error:   fmt.Sprintf("Failed to enable %s: %v", invocation.ServerID, err),
@@ -298,7 +302,7 @@ func (m *MCPToolIntegration) InvokeToolForUser(ctx context.Context, userID uuid.
 		}
 		
 		// Call the tool
-		result, err := m.builtinManager.CallTool(userID, invocation.ServerID, invocation.ToolName, invocation.Arguments)
+		result, err := m.builtinManager.CallTool(ctx, userID, invocation.ServerID, invocation.ToolName, invocation.Arguments)
 		if err != nil {
 			return &ToolResult{
 				Success: false,
@@ -319,22 +323,253 @@ func (m *MCPToolIntegration) InvokeToolForUser(ctx context.Context, userID uuid.
 		}, nil
 	}
 	
-	// Handle custom MCP servers (not implemented yet)
+	// Handle custom MCP servers
+	if m.mcpService == nil {
+		return &ToolResult{
+			Success: false,
+			Error:   "MCP service not available",
+		}, nil
+	}
+	
+	serverID, err := uuid.Parse(invocation.ServerID)
+	if err != nil {
+		return &ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid MCP server ID: %s", invocation.ServerID),
+		}, nil
+	}
+	
+	// Make sure the server belongs to the user before calling it
+	if _, err := m.mcpService.GetServer(ctx, userID, serverID); err != nil {
+		return &ToolResult{
+			Success: false,
+			Error:   "MCP server not found",
+		}, nil
+	}
+	
+	var args map[string]interface{}
+	if len(invocation.Arguments) > 0 {
+		if err := json.Unmarshal(invocation.Arguments, &args); err != nil {
+			return &ToolResult{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid tool arguments: %v", err),
+			}, nil
+		}
+	}
+	
+	resp, err := m.mcpService.CallTool(ctx, &models.MCPToolCallRequest{
+		ServerID:  serverID,
+		ToolName:  invocation.ToolName,
+		Arguments: args,
+	})
+	if err != nil {
+		return &ToolResult{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+	if resp.Error != nil {
+		return &ToolResult{
+			Success: false,
+			Error:   *resp.Error,
+		}, nil
+	}
+	
 	return &ToolResult{
-		Success: false,
-		Error:   "Custom MCP servers not yet supported",
+		Success: true,
+		Result:  resp.Result,
 	}, nil
 }
 
-// FormatToolResultForChat formats a tool result for display in chat
-func (m *MCPToolIntegration) FormatToolResultForChat(result *ToolResult, toolName string) string {
+// maxToolOutputLength caps tool output fed back to the model
+const maxToolOutputLength = 16000
+
+// FormatToolResultForModel formats a tool result as the content of a tool message
+func (m *MCPToolIntegration) FormatToolResultForModel(result *ToolResult) string {
+	if !result.Success {
+		data, _ := json.Marshal(map[string]string{"error": result.Error})
+		return string(data)
+	}
+	
+	var output string
+	
+	// MCP results carry a list of content blocks; prefer their text
+	if resultMap, ok := result.Result.(map[string]interface{}); ok {
+		if content, ok := resultMap["content"].([]interface{}); ok {
+			var texts []string
+			for _, c := range content {
+				if block, ok := c.(map[string]interface{}); ok {
+					if text, ok := block["text"].(string); ok && text != "" {
+						texts = append(texts, text)
+					}
+				}
+			}
+			output = strings.Join(texts, "\n\n")
+		}
+	}
+	
+	if output == "" {
+		switch v := result.Result.(type) {
+		case string:
+			output = v
+		default:
+			data, _ := json.Marshal(v)
+			output = string(data)
+		}
+	}
+	
+	if len(output) > maxToolOutputLength {
+		output = output[:maxToolOutputLength] + "\n[truncated]"
+	}
+	
+	return output
+}
+
+// AgentToolset maps tools advertised to the model back to the MCP servers serving them
+type AgentToolset struct {
+	definitions []llm.Tool
+	routes      map[string]ToolInvocation
+}
+
+// Len returns the number of tools in the set
+func (t *AgentToolset) Len() int {
+	return len(t.definitions)
+}
+
+// Definitions returns the tool definitions in gateway format
+func (t *AgentToolset) Definitions() []llm.Tool {
+	return append([]llm.Tool(nil), t.definitions...)
+}
+
+// Resolve returns the invocation template for a tool name
+func (t *AgentToolset) Resolve(name string) (*ToolInvocation, bool) {
+	route, ok := t.routes[name]
+	if !ok {
+		return nil, false
+	}
+	return &route, true
+}
+
+// Remove drops a tool from the set
+func (t *AgentToolset) Remove(name string) {
+	delete(t.routes, name)
+	for i, def := range t.definitions {
+		if def.Function.Name == name {
+			t.definitions = append(t.definitions[:i], t.definitions[i+1:]...)
+			break
+		}
+	}
+}
+
+// add registers a tool, namespacing it with the server name on collision
+func (t *AgentToolset) add(serverName, name, description string, schema json.RawMessage, invocation ToolInvocation) {
+	exposed := sanitizeToolName(name)
+	if _, exists := t.routes[exposed]; exists {
+		exposed = sanitizeToolName(serverName + "_" + name)
+		if _, exists := t.routes[exposed]; exists {
+			return
+		}
+	}
+	
+	parameters := map[string]interface{}{}
+	if len(schema) > 0 {
+		_ = json.Unmarshal(schema, &parameters)
+	}
+	if _, ok := parameters["type"]; !ok {
+		parameters["type"] = "object"
+	}
+	if _, ok := parameters["properties"]; !ok {
+		parameters["properties"] = map[string]interface{}{}
+	}
+	
+	t.definitions = append(t.definitions, llm.Tool{
+		Type: "function",
+		Function: llm.ToolFunction{
+			Name:        exposed,
+			Description: description,
+			Parameters:  parameters,
+		},
+	})
+	t.routes[exposed] = invocation
+}
+
+var toolNameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// sanitizeToolName makes a name acceptable as a provider function name
+func sanitizeToolName(name string) string {
+	name = toolNameSanitizer.ReplaceAllString(name, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// GetToolsetForUser collects the tools of every MCP server the user has enabled
+func (m *MCPToolIntegration) GetToolsetForUser(ctx context.Context, userID uuid.UUID) *AgentToolset {
+	toolset := &AgentToolset{
+		routes: make(map[string]ToolInvocation),
+	}
+	
+	// Built-in servers
+	if m.builtinManager != nil {
+		for _, server := range m.builtinManager.GetUserEnabledServers(userID) {
+			tools, err := m.builtinManager.GetServerTools(userID, server.ID)
+			if err != nil {
+				m.logger.Warnf("Failed to list tools for built-in server %s: %v", server.ID, err)
+				continue
+			}
+			for _, tool := range tools {
+				toolset.add(server.Name, tool.Name, tool.Description, tool.InputSchema, ToolInvocation{
+					Type:     "builtin",
+					ServerID: server.ID,
+					ToolName: tool.Name,
+				})
+			}
+		}
+	}
+	
+	// User-configured servers
+	if m.mcpService != nil {
+		servers, err := m.mcpService.ListServers(ctx, userID)
+		if err != nil {
+			m.logger.Warnf("Failed to list MCP servers for user %s: %v", userID, err)
+			return toolset
+		}
+		for _, server := range servers {
+			if !server.Enabled {
+				continue
+			}
+			tools, err := m.mcpService.repo.GetServerTools(ctx, server.ID)
+			if err != nil {
+				m.logger.Warnf("Failed to list tools for MCP server %s: %v", server.ID, err)
+				continue
+			}
+			for _, tool := range tools {
+				if !tool.Enabled {
+					continue
+				}
+				toolset.add(server.Name, tool.Name, tool.Description, tool.InputSchema, ToolInvocation{
+					Type:     "custom",
+					ServerID: server.ID.String(),
+					ToolName: tool.Name,
+				})
+			}
+		}
+	}
+	
+	return toolset
+}
+
+// FormatToolResultForChat formats a tool result for display in chat, using
+// the user's prompt templates where a tool has one
+func (m *MCPToolIntegration) FormatToolResultForChat(ctx context.Context, userID uuid.UUID, result *ToolResult, toolName string) string {
 	if !result.Success {
 		return fmt.Sprintf("⚠️ Tool error: %s", result.Error)
 	}
 	
 	switch toolName {
 	case "web_search":
-		return m.formatWebSearchResult(result.Result)
+		return m.formatWebSearchResult(ctx, userID, result.Result)
 	case "fetch_page":
 		return m.formatFetchPageResult(result.Result)
 	case "search_and_summarize":
@@ -346,8 +581,9 @@ func (m *MCPToolIntegration) FormatToolResultForChat(result *ToolResult, toolNam
 	}
 }
 
-// formatWebSearchResult formats web search results for chat
-func (m *MCPToolIntegration) formatWebSearchResult(result interface{}) string {
+// formatWebSearchResult formats web search results for chat with the
+// web_search_results prompt template
+func (m *MCPToolIntegration) formatWebSearchResult(ctx context.Context, userID uuid.UUID, result interface{}) string {
 	// Parse the result
 	resultMap, ok := result.(map[string]interface{})
 	if !ok {
@@ -387,15 +623,13 @@ func (m *MCPToolIntegration) formatWebSearchResult(result interface{}) string {
 		return "Search results retrieved."
 	}
 	
-	// Format results as reference material with clear sources
-	var formatted strings.Builder
-	
 	// Don't add any prefix if we don't have valid results
 	if len(results) == 0 {
 		return ""
 	}
 	
-	formatted.WriteString("Based on current web information:\n\n")
+	// Collect results as reference material with clear sources
+	var sources []map[string]interface{}
 	
 	for i, r := range results {
 		if i >= 5 { // Limit to 5 results to avoid overwhelming
@@ -406,7 +640,6 @@ func (m *MCPToolIntegration) formatWebSearchResult(result interface{}) string {
 			continue
 		}
 		
-		title, _ := result["title"].(string)
 		url, _ := result["url"].(string)
 		snippet, _ := result["snippet"].(string)
 		
@@ -436,19 +669,31 @@ func (m *MCPToolIntegration) formatWebSearchResult(result interface{}) string {
 			}
 		}
 		
-		// Format with clear source attribution that LLM should preserve
-		formatted.WriteString(fmt.Sprintf("• According to %s: %s\n", domain, snippet))
+		// Keep clear source attribution that LLM should preserve
+		sources = append(sources, map[string]interface{}{
+			"URL":     url,
+			"Domain":  domain,
+			"Snippet": snippet,
+		})
 	}
 	
 	// Add metadata if available
+	provider := ""
 	if metadata, ok := searchData["metadata"].(map[string]interface{}); ok {
-		if provider, ok := metadata["provider"].(string); ok {
-			formatted.WriteString(fmt.Sprintf("_Source: %s_", provider))
-		}
+		provider, _ = metadata["provider"].(string)
+	}
+	
+	formatted, err := m.prompts.Render(ctx, userID.String(), llm.PromptWebSearchResults, map[string]interface{}{
+		"Results":  sources,
+		"Provider": provider,
+	})
+	if err != nil {
+		m.logger.Warnf("Failed to render search results: %v", err)
+		return ""
 	}
 	
 	// Final safety check - clean the output one more time
-	finalOutput := formatted.String()
+	finalOutput := formatted.User
 	finalOutput = strings.ReplaceAll(finalOutput, "....???........?......?................................ .....", "")
 	finalOutput = strings.ReplaceAll(finalOutput, "We need answer. Use sources.", "")
 	finalOutput = strings.TrimSpace(finalOutput)
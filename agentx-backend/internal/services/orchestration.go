@@ -3,15 +3,18 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/agentx/agentx-backend/internal/api/models"
 	"github.com/agentx/agentx-backend/internal/llm"
 	"github.com/agentx/agentx-backend/internal/providers"
 	"github.com/agentx/agentx-backend/internal/repository"
+	"github.com/agentx/agentx-backend/internal/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // min returns the minimum of two integers
@@ -27,20 +30,23 @@ func min(a, b int) int {
 // and coordinates between different services to fulfill requests
 type OrchestrationService struct {
 	// Core services
-	gateway    *llm.Gateway      // LLM Gateway for all AI operations  
-	llmService *llm.Service      // LLM Service for structured AI tasks
-	cache      *CacheService     // Caching layer for performance
-	db         *DatabaseService  // Unified database access
-	
+	gateway    *llm.Gateway     // LLM Gateway for all AI operations
+	llmService *llm.Service     // LLM Service for structured AI tasks
+	cache      *CacheService    // Caching layer for performance
+	db         *DatabaseService // Unified database access
+
 	// Repository access (will be moved to DatabaseService)
 	sessionRepo repository.SessionRepository
 	messageRepo repository.MessageRepository
-	
+
 	// Supporting services
 	contextMemory *ContextMemoryService
 	connections   *ConnectionService
 	config        *ConfigService
 	mcpTools      *MCPToolIntegration // MCP tool integration
+	agentLoop     AgentLoopConfig     // Budgets for the tool-calling loop
+	agentGateway  agentGateway        // Completions of the tool-calling loop
+	agentTools    agentToolExecutor   // Tool calls of the tool-calling loop
 }
 
 // NewOrchestrationService creates a new orchestration service
@@ -57,7 +63,7 @@ func NewOrchestrationService(
 	return &OrchestrationService{
 		gateway:       gateway,
 		cache:         NewCacheService(), // Will be enhanced with Redis later
-		db:            nil,                // Will be created in phase 2
+		db:            nil,               // Will be created in phase 2
 		sessionRepo:   sessionRepo,
 		messageRepo:   messageRepo,
 		contextMemory: contextMemory,
@@ -65,6 +71,9 @@ func NewOrchestrationService(
 		config:        config,
 		llmService:    llmService,
 		mcpTools:      mcpTools,
+		agentLoop:     DefaultAgentLoopConfig(),
+		agentGateway:  gateway,
+		agentTools:    mcpTools,
 	}
 }
 
@@ -74,227 +83,244 @@ func NewOrchestrationService(
 
 // ChatWithUser handles a chat request through the orchestrator with explicit userID
 func (o *OrchestrationService) ChatWithUser(ctx context.Context, userID uuid.UUID, req models.UnifiedChatRequest) (*models.UnifiedChatResponse, error) {
+	ctx, span := tracing.Start(ctx, "orchestration.chat", chatAttributes(userID, req)...)
+	defer span.End()
+
 	// Initialize user connections if needed
 	if userID != uuid.Nil {
 		if err := o.InitializeUserConnections(ctx, userID); err != nil {
 			fmt.Printf("[OrchestrationService.ChatWithUser] Warning: Failed to initialize connections: %v\n", err)
 		}
 	}
-	
-	// Check cache first
-	cacheKey := o.generateCacheKey("chat", userID.String(), req)
-	if cached := o.cache.Get(cacheKey); cached != nil {
-		if resp, ok := cached.(*models.UnifiedChatResponse); ok {
-			return resp, nil
-		}
-	}
-	
-	// Check if the user's message contains a tool invocation or force web search is enabled
-	if len(req.Messages) > 0 && o.mcpTools != nil {
-		lastMessage := req.Messages[len(req.Messages)-1]
-		if lastMessage.Role == "user" {
-			var invocation *ToolInvocation
-			var err error
-			
-			// Force web search if flag is set
-			if req.ForceWebSearch {
-				// Create a web search invocation for the user's message
-				// Enable includeContent to fetch actual page content, not just snippets
-				args, _ := json.Marshal(map[string]interface{}{
-					"query":         lastMessage.Content,
-					"maxResults":    3, // Reduced to 3 to avoid too much content
-					"includeContent": true, // Fetch actual page content
-				})
-				invocation = &ToolInvocation{
-					Type:      "builtin",
-					ServerID:  "builtin-websearch",
-					ToolName:  "web_search",
-					Arguments: args,
-				}
-			} else {
-				// Detect tool invocation normally
-				invocation, err = o.mcpTools.DetectToolInvocation(lastMessage.Content)
-			}
-			
-			if err == nil && invocation != nil {
-				// Invoke the tool
-				toolResult, err := o.mcpTools.InvokeToolForUser(ctx, userID, invocation)
-				if err == nil && toolResult != nil {
-					// Format the result for chat
-					formattedResult := o.mcpTools.FormatToolResultForChat(toolResult, invocation.ToolName)
-					
-					// For web search, prepend results to the last user message instead of adding as separate message
-					if invocation.ToolName == "web_search" && len(req.Messages) > 0 {
-						// Find the last user message and prepend search context
-						for i := len(req.Messages) - 1; i >= 0; i-- {
-							if req.Messages[i].Role == "user" {
-								// Prepend search results to user's question
-								req.Messages[i].Content = formattedResult + "\n\n" + req.Messages[i].Content
-								break
-							}
-						}
-					} else {
-						// For other tools, add as assistant message
-						req.Messages = append(req.Messages, providers.Message{
-							Role:    "assistant",
-							Content: formattedResult,
-						})
-					}
-					}
-				}
-			}
-		}
-	}
-	
+
+	// Carry attached images on the user's message
+	req = attachImages(req)
+
+	// Run a tool the user asked for explicitly, or web search when forced
+	req = o.invokeDetectedTool(ctx, userID, req)
+
 	// Enrich with context if needed
-	if req.SessionID != "" && o.contextMemory != nil {
-		// Get relevant context
-		messages, err := o.messageRepo.ListBySession(ctx, req.SessionID)
-		if err == nil && len(messages) > 0 {
-			// Add context to request
-			req.Messages = o.enrichWithContext(req.Messages, messages)
-		}
-	}
-	
+	req = o.enrichFromSession(ctx, req)
+
 	// Convert to gateway request
 	gatewayReq := o.convertToGatewayRequest(req, userID.String())
-	
+
+	// Expose the user's MCP tools and resolve tool calls server-side
+	run := o.prepareAgentRun(ctx, userID, req, gatewayReq)
+
 	// Send through gateway
-	resp, err := o.gateway.Complete(ctx, gatewayReq)
+	resp, err := o.runAgentLoop(ctx, userID, run, gatewayReq)
 	if err != nil {
+		tracing.RecordError(span, err)
 		return nil, fmt.Errorf("gateway error: %w", err)
 	}
-	
+
 	// Convert response
 	unifiedResp := o.convertFromGatewayResponse(resp)
-	
-	// Cache successful response
-	o.cache.Set(cacheKey, unifiedResp, 5*time.Minute)
-	
+	var transcript []llm.Message
+	if run != nil {
+		unifiedResp.Metadata.ToolSteps = run.steps
+		unifiedResp.Metadata.StopReason = run.stop
+		transcript = run.transcript
+	}
+
 	// Save messages if session exists
 	if req.SessionID != "" {
-		o.saveMessages(ctx, req, unifiedResp)
+		o.saveMessages(ctx, req, transcript, unifiedResp)
 	}
-	
+
 	return unifiedResp, nil
 }
 
 // StreamChatWithUser handles streaming chat through the orchestrator with explicit userID
 func (o *OrchestrationService) StreamChatWithUser(ctx context.Context, userID uuid.UUID, req models.UnifiedChatRequest) (<-chan models.UnifiedStreamChunk, error) {
+	// The span stays open until the stream is drained
+	ctx, span := tracing.Start(ctx, "orchestration.stream_chat", chatAttributes(userID, req)...)
+
 	// Initialize user connections if needed
 	if userID != uuid.Nil {
 		if err := o.InitializeUserConnections(ctx, userID); err != nil {
 			fmt.Printf("[OrchestrationService.StreamChatWithUser] Warning: Failed to initialize connections: %v\n", err)
 		}
 	}
-	
-	// Check if the user's message contains a tool invocation or force web search is enabled
-	if len(req.Messages) > 0 && o.mcpTools != nil {
-		lastMessage := req.Messages[len(req.Messages)-1]
-		if lastMessage.Role == "user" {
-			var invocation *ToolInvocation
-			var err error
-			
-			// Force web search if flag is set
-			if req.ForceWebSearch {
-				// Create a web search invocation for the user's message
-				// Enable includeContent to fetch actual page content, not just snippets
-				args, _ := json.Marshal(map[string]interface{}{
-					"query":         lastMessage.Content,
-					"maxResults":    3, // Reduced to 3 to avoid too much content
-					"includeContent": true, // Fetch actual page content
-				})
-				invocation = &ToolInvocation{
-					Type:      "builtin",
-					ServerID:  "builtin-websearch",
-					ToolName:  "web_search",
-					Arguments: args,
-				}
-			} else {
-				// Detect tool invocation normally
-				invocation, err = o.mcpTools.DetectToolInvocation(lastMessage.Content)
-			}
-			
-			if err == nil && invocation != nil {
-				// Invoke the tool
-				toolResult, err := o.mcpTools.InvokeToolForUser(ctx, userID, invocation)
-				if err == nil && toolResult != nil {
-					// Format the result for chat
-					formattedResult := o.mcpTools.FormatToolResultForChat(toolResult, invocation.ToolName)
-					
-					// For web search, prepend results to the last user message instead of adding as separate message
-					if invocation.ToolName == "web_search" && len(req.Messages) > 0 {
-						// Find the last user message and prepend search context
-						for i := len(req.Messages) - 1; i >= 0; i-- {
-							if req.Messages[i].Role == "user" {
-								// Prepend search results to user's question
-								req.Messages[i].Content = formattedResult + "\n\n" + req.Messages[i].Content
-								break
-							}
-						}
-					} else {
-						// For other tools, add as assistant message
-						req.Messages = append(req.Messages, providers.Message{
-							Role:    "assistant",
-							Content: formattedResult,
-						})
-					}
-					}
-				}
-			}
-		}
-	}
-	
+
+	// Carry attached images on the user's message
+	req = attachImages(req)
+
+	// Run a tool the user asked for explicitly, or web search when forced
+	req = o.invokeDetectedTool(ctx, userID, req)
+
 	// Enrich with context if needed
-	if req.SessionID != "" && o.contextMemory != nil {
-		messages, err := o.messageRepo.ListBySession(ctx, req.SessionID)
-		if err == nil && len(messages) > 0 {
-			req.Messages = o.enrichWithContext(req.Messages, messages)
-		}
-	}
-	
+	req = o.enrichFromSession(ctx, req)
+
 	// Convert to gateway request
 	gatewayReq := o.convertToGatewayRequest(req, userID.String())
 	gatewayReq.Stream = true
-	
+
+	// Expose the user's MCP tools and resolve tool calls server-side
+	run := o.prepareAgentRun(ctx, userID, req, gatewayReq)
+
 	// Get stream from gateway
 	gatewayStream, err := o.gateway.StreamComplete(ctx, gatewayReq)
 	if err != nil {
+		tracing.RecordError(span, err)
+		span.End()
 		return nil, fmt.Errorf("gateway stream error: %w", err)
 	}
-	
+
 	// Create output channel
 	out := make(chan models.UnifiedStreamChunk)
-	
+
 	// Process stream
 	go func() {
+		defer span.End()
 		defer close(out)
-		var fullContent string
-		
+		if err := o.streamAgentLoop(ctx, userID, req, run, gatewayReq, gatewayStream, out); err != nil {
+			tracing.RecordError(span, err)
+		}
+	}()
+
+	return out, nil
+}
+
+// streamAgentLoop relays a gateway stream to the client and, while the
+// model asks for MCP tools the run can serve, executes them and streams the
+// next round. Calls left unresolved are handed back to the client, and the
+// conversation is saved to the session. It returns the error that ended a
+// follow-up round early, if any.
+func (o *OrchestrationService) streamAgentLoop(ctx context.Context, userID uuid.UUID, req models.UnifiedChatRequest, run *agentRun, gatewayReq *llm.Request, gatewayStream <-chan *llm.StreamChunk, out chan<- models.UnifiedStreamChunk) error {
+	var fullContent, reasoning string
+	var streamErr error
+	var toolCalls []llm.ToolCall
+
+	send := func(chunk models.UnifiedStreamChunk) bool {
+		select {
+		case out <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		var stepContent, stepReasoning, stepSignature string
+		var stepRedacted []string
+		var stepToolCalls []llm.ToolCall
+
 		for chunk := range gatewayStream {
+			// Collect the tool calls the gateway assembled; a failover
+			// restarts the answer, so calls from the failed leg are dropped.
+			// Thinking signatures only verify with the provider that made them.
+			switch chunk.Type {
+			case llm.StreamToolCallEnd:
+				call := *chunk.ToolCall
+				call.Index = nil // Only meaningful within the stream
+				stepToolCalls = append(stepToolCalls, call)
+			case "reasoning":
+				stepReasoning += chunk.Reasoning
+				for _, choice := range chunk.Choices {
+					stepSignature += choice.Delta.ReasoningSignature
+					stepRedacted = append(stepRedacted, choice.Delta.RedactedReasoning...)
+				}
+				if chunk.Reasoning == "" {
+					continue // Signature or redacted thinking; nothing to show
+				}
+			case "failover":
+				stepToolCalls = nil
+				stepReasoning, stepSignature, stepRedacted = "", "", nil
+			}
+
 			// Convert chunk
 			unifiedChunk := o.convertStreamChunk(chunk)
-			
+
 			// Accumulate content
 			if chunk.Type == "content" {
-				fullContent += chunk.Content
+				stepContent += chunk.Content
 			}
-			
+
 			// Send chunk
-			select {
-			case out <- *unifiedChunk:
-			case <-ctx.Done():
-				return
+			if !send(*unifiedChunk) {
+				return nil
 			}
 		}
-		
-		// Save messages if session exists
-		if req.SessionID != "" && fullContent != "" {
-			o.saveStreamedMessages(ctx, req, fullContent)
+
+		toolCalls = stepToolCalls
+		if run == nil || !run.canExecute(toolCalls) {
+			fullContent += stepContent
+			reasoning = stepReasoning
+			break
 		}
-	}()
-	
-	return out, nil
+
+		// Announce each call, run the step, then stream the next round
+		for _, tool := range convertToolCallsToResponse(toolCalls) {
+			tool := tool
+			if !send(models.UnifiedStreamChunk{Type: "tool_use", Tool: &tool}) {
+				return nil
+			}
+		}
+		step := llm.Message{
+			Role:               "assistant",
+			Content:            stepContent,
+			ToolCalls:          toolCalls,
+			Reasoning:          stepReasoning,
+			ReasoningSignature: stepSignature,
+			RedactedReasoning:  stepRedacted,
+		}
+		delivered := o.executeStep(ctx, userID, run, gatewayReq, step, func(tc llm.ToolCall, output string) bool {
+			return send(models.UnifiedStreamChunk{
+				Type:    "tool_result",
+				Content: output,
+				Tool: &models.ToolResponse{
+					ID:       tc.ID,
+					Type:     "function",
+					Function: models.FunctionResponse{Name: tc.Function.Name},
+				},
+			})
+		})
+		if !delivered {
+			return nil
+		}
+
+		var err error
+		gatewayStream, err = o.agentGateway.StreamComplete(ctx, gatewayReq)
+		if err != nil {
+			streamErr = err
+			send(models.UnifiedStreamChunk{
+				Type: "error",
+				Error: &models.UnifiedError{
+					Code:    "gateway_error",
+					Message: err.Error(),
+					Type:    models.ErrorTypeProvider,
+				},
+			})
+			// The executed calls are answered in the transcript already
+			toolCalls = nil
+			break
+		}
+	}
+
+	// Hand unresolved tool calls back to the client
+	for _, tool := range convertToolCallsToResponse(toolCalls) {
+		tool := tool
+		if !send(models.UnifiedStreamChunk{Type: "tool_use", Tool: &tool}) {
+			return nil
+		}
+	}
+
+	// Save messages if session exists
+	var transcript []llm.Message
+	if run != nil {
+		transcript = run.transcript
+	}
+	if req.SessionID != "" && (fullContent != "" || len(transcript) > 0 || len(toolCalls) > 0) {
+		o.saveStreamedMessages(ctx, req, transcript, llm.Message{
+			Role:      "assistant",
+			Content:   fullContent,
+			Reasoning: reasoning,
+			ToolCalls: toolCalls,
+		})
+	}
+	return streamErr
 }
 
 // =====================================
@@ -306,7 +332,7 @@ func (o *OrchestrationService) CreateSession(ctx context.Context, userID uuid.UU
 	if title == "" {
 		title = "New Chat"
 	}
-	
+
 	session := &repository.Session{
 		ID:        uuid.New().String(),
 		UserID:    userID,
@@ -314,14 +340,14 @@ func (o *OrchestrationService) CreateSession(ctx context.Context, userID uuid.UU
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
-	
+
 	if err := o.sessionRepo.Create(ctx, session); err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
-	
+
 	// Invalidate user's session list cache
 	o.cache.Delete(fmt.Sprintf("sessions:%s", userID.String()))
-	
+
 	return session, nil
 }
 
@@ -334,15 +360,15 @@ func (o *OrchestrationService) GetSession(ctx context.Context, userID uuid.UUID,
 			return session, nil
 		}
 	}
-	
+
 	session, err := o.sessionRepo.Get(ctx, userID, sessionID)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	// Cache for future requests
 	o.cache.Set(cacheKey, session, 10*time.Minute)
-	
+
 	return session, nil
 }
 
@@ -355,15 +381,15 @@ func (o *OrchestrationService) ListSessions(ctx context.Context, userID uuid.UUI
 			return sessions, nil
 		}
 	}
-	
+
 	sessions, err := o.sessionRepo.List(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	// Cache for future requests
 	o.cache.Set(cacheKey, sessions, 5*time.Minute)
-	
+
 	return sessions, nil
 }
 
@@ -373,12 +399,12 @@ func (o *OrchestrationService) DeleteSession(ctx context.Context, userID uuid.UU
 	if err := o.sessionRepo.Delete(ctx, userID, sessionID); err != nil {
 		return err
 	}
-	
+
 	// Invalidate caches
 	o.cache.Delete(fmt.Sprintf("session:%s:%s", userID.String(), sessionID))
 	o.cache.Delete(fmt.Sprintf("sessions:%s", userID.String()))
 	o.cache.Delete(fmt.Sprintf("messages:%s", sessionID))
-	
+
 	return nil
 }
 
@@ -387,11 +413,11 @@ func (o *OrchestrationService) UpdateSession(ctx context.Context, userID uuid.UU
 	if err := o.sessionRepo.Update(ctx, userID, sessionID, updates); err != nil {
 		return err
 	}
-	
+
 	// Invalidate caches
 	o.cache.Delete(fmt.Sprintf("session:%s:%s", userID.String(), sessionID))
 	o.cache.Delete(fmt.Sprintf("sessions:%s", userID.String()))
-	
+
 	return nil
 }
 
@@ -408,15 +434,15 @@ func (o *OrchestrationService) GetMessages(ctx context.Context, sessionID string
 			return messages, nil
 		}
 	}
-	
+
 	messages, err := o.messageRepo.ListBySession(ctx, sessionID)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	// Cache for future requests
 	o.cache.Set(cacheKey, messages, 5*time.Minute)
-	
+
 	return messages, nil
 }
 
@@ -424,19 +450,19 @@ func (o *OrchestrationService) GetMessages(ctx context.Context, sessionID string
 func (o *OrchestrationService) SaveMessage(ctx context.Context, message *repository.Message) error {
 	message.ID = uuid.New().String()
 	message.CreatedAt = time.Now()
-	
+
 	if _, err := o.messageRepo.Create(ctx, *message); err != nil {
 		return err
 	}
-	
+
 	// Invalidate message cache for this session
 	o.cache.Delete(fmt.Sprintf("messages:%s", message.SessionID))
-	
+
 	// Update session timestamp
 	o.sessionRepo.Update(ctx, uuid.Nil, message.SessionID, map[string]interface{}{
 		"updated_at": time.Now(),
 	})
-	
+
 	return nil
 }
 
@@ -447,7 +473,7 @@ func (o *OrchestrationService) SaveMessage(ctx context.Context, message *reposit
 // GenerateTitle generates a title for a session
 func (o *OrchestrationService) GenerateTitle(ctx context.Context, userID uuid.UUID, sessionID string, connectionID string) (string, error) {
 	fmt.Printf("[GenerateTitle] Starting for session %s with connectionID: %s\n", sessionID, connectionID)
-	
+
 	// Use the new LLM service for title generation
 	req := llm.CompletionRequest{
 		Task: llm.TaskGenerateTitle,
@@ -461,24 +487,24 @@ func (o *OrchestrationService) GenerateTitle(ctx context.Context, userID uuid.UU
 			Temperature: &[]float32{0.7}[0],
 		},
 	}
-	
+
 	resp, err := o.llmService.Complete(ctx, userID.String(), req)
 	if err != nil {
 		fmt.Printf("[GenerateTitle] LLM service failed: %v\n", err)
 		return "", fmt.Errorf("failed to generate title: %w", err)
 	}
-	
+
 	title := strings.TrimSpace(resp.Result)
 	if title == "" {
 		return "", fmt.Errorf("empty title generated")
 	}
-	
+
 	fmt.Printf("[GenerateTitle] Generated title: %s\n", title)
-	
+
 	// Cache the result
 	cacheKey := fmt.Sprintf("title:%s", sessionID)
 	o.cache.Set(cacheKey, title, 24*time.Hour) // Cache for 24 hours
-	
+
 	return title, nil
 }
 
@@ -504,9 +530,9 @@ func (o *OrchestrationService) Chat(ctx context.Context, req models.UnifiedChatR
 			}
 		}
 	}
-	
+
 	fmt.Printf("[OrchestrationService.Chat] Extracted UserID: %s from ConnectionID: %s\n", userID, req.Preferences.ConnectionID)
-	
+
 	// Pass through to the main ChatWithUser method
 	return o.ChatWithUser(ctx, userID, req)
 }
@@ -524,7 +550,7 @@ func (o *OrchestrationService) StreamChat(ctx context.Context, req models.Unifie
 			}
 		}
 	}
-	
+
 	// Pass through to the main StreamChatWithUser method
 	return o.StreamChatWithUser(ctx, userID, req)
 }
@@ -537,7 +563,7 @@ func (o *OrchestrationService) GetAvailableModels(ctx context.Context) (*models.
 		Models: make([]models.ModelInfo, 0),
 		Total:  0,
 	}
-	
+
 	return response, nil
 }
 
@@ -556,29 +582,29 @@ func (o *OrchestrationService) MaybeAutoLabelSession(ctx context.Context, userID
 	if err != nil {
 		return err
 	}
-	
+
 	// Skip if already has a custom title
 	if session.Title != "New Chat" && session.Title != "" {
 		return nil
 	}
-	
+
 	// Get messages
 	messages, err := o.messageRepo.ListBySession(ctx, sessionID)
 	if err != nil {
 		return err
 	}
-	
+
 	// Need at least one user message to generate title
 	if len(messages) == 0 {
 		return nil
 	}
-	
+
 	// Generate title
 	title, err := o.GenerateTitle(ctx, userID, sessionID, "")
 	if err != nil {
 		return err
 	}
-	
+
 	// Update session with generated title
 	updates := map[string]interface{}{
 		"title": title,
@@ -593,7 +619,7 @@ func (o *OrchestrationService) GenerateTitleForSession(ctx context.Context, user
 	if err != nil {
 		return "", fmt.Errorf("invalid user ID: %w", err)
 	}
-	
+
 	// Use the existing GenerateTitle method
 	return o.GenerateTitle(ctx, uid, session.ID, connectionID)
 }
@@ -609,7 +635,7 @@ func (o *OrchestrationService) InitializeUserConnections(ctx context.Context, us
 	if err != nil {
 		return fmt.Errorf("failed to list connections: %w", err)
 	}
-	
+
 	// Register each enabled connection with the gateway
 	for _, conn := range connections {
 		if conn.Enabled {
@@ -620,13 +646,14 @@ func (o *OrchestrationService) InitializeUserConnections(ctx context.Context, us
 				BaseURL:      getStringFromConfigOrc(conn.Config, "base_url"),
 				Organization: getStringFromConfigOrc(conn.Config, "organization"),
 			}
-			
+			config.ApplyConnectionSettings(conn.Config)
+
 			if err := o.gateway.RegisterProvider(userID.String(), conn.ID.String(), config); err != nil {
 				fmt.Printf("[Orchestrator] Failed to register connection %s: %v\n", conn.Name, err)
 			}
 		}
 	}
-	
+
 	return nil
 }
 
@@ -638,14 +665,36 @@ func (o *OrchestrationService) convertToGatewayRequest(req models.UnifiedChatReq
 	// Convert messages
 	var messages []llm.Message
 	for _, msg := range req.Messages {
-		messages = append(messages, llm.Message{
-			Role:    msg.Role,
-			Content: msg.Content,
-		})
+		message := llm.Message{
+			Role:               msg.Role,
+			ToolCallID:         msg.ToolCallID,
+			Cacheable:          msg.Cacheable,
+			Reasoning:          msg.Reasoning,
+			ReasoningSignature: msg.ReasoningSignature,
+			RedactedReasoning:  msg.RedactedReasoning,
+		}
+		message.Content, message.Images = messageContent(msg)
+		for _, tc := range msg.ToolCalls {
+			toolCall := llm.ToolCall{ID: tc.ID, Type: tc.Type}
+			toolCall.Function.Name = tc.Function.Name
+			toolCall.Function.Arguments = tc.Function.Arguments
+			message.ToolCalls = append(message.ToolCalls, toolCall)
+		}
+		messages = append(messages, message)
 	}
-	
+
 	// Convert tools
 	var tools []llm.Tool
+	for _, tool := range req.Tools {
+		tools = append(tools, llm.Tool{
+			Type: "function",
+			Function: llm.ToolFunction{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters,
+			},
+		})
+	}
 	for _, fn := range req.Functions {
 		tools = append(tools, llm.Tool{
 			Type: "function",
@@ -656,8 +705,8 @@ func (o *OrchestrationService) convertToGatewayRequest(req models.UnifiedChatReq
 			},
 		})
 	}
-	
-	return &llm.Request{
+
+	gatewayReq := &llm.Request{
 		Messages:    messages,
 		Tools:       tools,
 		Temperature: req.Temperature,
@@ -667,32 +716,92 @@ func (o *OrchestrationService) convertToGatewayRequest(req models.UnifiedChatReq
 			Provider:     req.Preferences.Provider,
 			Model:        req.Preferences.Model,
 			ConnectionID: req.Preferences.ConnectionID,
+			Speed:        req.Preferences.Speed,
+			Cost:         req.Preferences.Cost,
+			Privacy:      req.Preferences.Privacy,
 		},
 		Requirements: llm.Requirements{
 			RequireTools: len(tools) > 0,
+			MaxCost:      req.MaxCost,
+			MaxLatency:   time.Duration(req.MaxLatencyMs) * time.Millisecond,
 		},
+		SessionID: req.SessionID,
+		Metadata: map[string]interface{}{
+			llm.MetadataTaskType:        "chat",
+			llm.MetadataContextStrategy: req.ContextStrategy,
+		},
+	}
+	if req.APIKeyID != "" {
+		gatewayReq.Metadata[llm.MetadataAPIKeyID] = req.APIKeyID
 	}
+	if req.NoCache {
+		gatewayReq.Metadata[llm.MetadataNoCache] = true
+	}
+	gatewayReq.ResponseFormat = convertResponseFormat(req.ResponseFormat)
+	if req.Reasoning != nil {
+		gatewayReq.Reasoning = &llm.ReasoningOptions{BudgetTokens: req.Reasoning.BudgetTokens}
+	}
+	if req.PromptCache != nil {
+		gatewayReq.PromptCache = &llm.PromptCacheOptions{System: req.PromptCache.System, Tools: req.PromptCache.Tools}
+	}
+	return gatewayReq
 }
 
 func (o *OrchestrationService) convertFromGatewayResponse(resp *llm.Response) *models.UnifiedChatResponse {
 	return &models.UnifiedChatResponse{
-		ID:      resp.ID,
-		Role:    resp.Role,
-		Content: resp.Content,
+		ID:        resp.ID,
+		Role:      resp.Role,
+		Content:   resp.Content,
+		Reasoning: resp.GetReasoning(),
+		Tools:     convertToolCallsToResponse(resp.GetToolCalls()),
+		Parsed:    resp.Parsed,
 		Usage: models.Usage{
-			PromptTokens:     resp.Usage.PromptTokens,
-			CompletionTokens: resp.Usage.CompletionTokens,
-			TotalTokens:      resp.Usage.TotalTokens,
+			PromptTokens:        resp.Usage.PromptTokens,
+			CompletionTokens:    resp.Usage.CompletionTokens,
+			TotalTokens:         resp.Usage.TotalTokens,
+			EstimatedCost:       resp.Usage.EstimatedCost,
+			CacheReadTokens:     resp.Usage.CacheReadTokens,
+			CacheCreationTokens: resp.Usage.CacheCreationTokens,
 		},
 		Metadata: models.ResponseMetadata{
-			Provider: resp.Provider,
-			Model:    resp.Model,
+			Provider:         resp.Provider,
+			Model:            resp.Model,
+			RoutingReason:    resp.Metadata.RoutingReason,
+			RoutingScore:     resp.Metadata.RoutingScore,
+			TokensTrimmed:    resp.Metadata.TokensTrimmed,
+			ContextStrategy:  resp.Metadata.ContextStrategy,
+			BudgetWarnings:   resp.Metadata.BudgetWarnings,
+			Cache:            resp.Metadata.Cache,
+			CacheSimilarity:  resp.Metadata.CacheSimilarity,
+			Hedged:           resp.Metadata.Hedged,
+			HedgeWinner:      resp.Metadata.HedgeWinner,
+			StructuredOutput: resp.Metadata.StructuredOutput,
+			SchemaRepairs:    resp.Metadata.SchemaRepairs,
 		},
 	}
 }
 
+// convertResponseFormat maps a requested JSON format onto the gateway's;
+// text-like formats are only routing hints
+func convertResponseFormat(format models.ResponseFormat) *llm.ResponseFormat {
+	if !format.IsJSON() {
+		return nil
+	}
+	converted := &llm.ResponseFormat{Type: llm.ResponseFormatJSONObject, MaxRepairs: format.MaxRepairs}
+	if format.Type == llm.ResponseFormatJSONSchema && format.JSONSchema != nil {
+		converted.Type = llm.ResponseFormatJSONSchema
+		converted.JSONSchema = &llm.JSONSchema{
+			Name:        format.JSONSchema.Name,
+			Description: format.JSONSchema.Description,
+			Schema:      format.JSONSchema.Schema,
+			Strict:      format.JSONSchema.Strict,
+		}
+	}
+	return converted
+}
+
 func (o *OrchestrationService) convertStreamChunk(chunk *llm.StreamChunk) *models.UnifiedStreamChunk {
-	return &models.UnifiedStreamChunk{
+	unified := &models.UnifiedStreamChunk{
 		Type:    chunk.Type,
 		Content: chunk.Content,
 		Metadata: &models.ChunkMetadata{
@@ -700,90 +809,209 @@ func (o *OrchestrationService) convertStreamChunk(chunk *llm.StreamChunk) *model
 			Model:    chunk.Model,
 		},
 	}
+
+	if trimmed, ok := chunk.Metadata["tokens_trimmed"].(int); ok {
+		unified.Metadata.TokensTrimmed = trimmed
+	}
+	if warnings, ok := chunk.Metadata[llm.MetadataBudgetWarnings].([]string); ok {
+		unified.Metadata.BudgetWarnings = warnings
+	}
+	if hedged, ok := chunk.Metadata[llm.MetadataHedged].(bool); ok {
+		unified.Metadata.Hedged = hedged
+	}
+
+	switch chunk.Type {
+	case "reasoning":
+		unified.Content = chunk.Reasoning
+	case llm.StreamToolCallStart, llm.StreamToolCallDelta, llm.StreamToolCallEnd:
+		if chunk.ToolCall != nil {
+			unified.Tool = &models.ToolResponse{
+				ID:    chunk.ToolCall.ID,
+				Type:  chunk.ToolCall.Type,
+				Index: chunk.ToolCall.Index,
+				Function: models.FunctionResponse{
+					Name:      chunk.ToolCall.Function.Name,
+					Arguments: chunk.ToolCall.Function.Arguments,
+				},
+			}
+		}
+	case "failover":
+		if from, ok := chunk.Metadata["from_provider"].(string); ok {
+			unified.Metadata.FailoverFrom = from
+		}
+	case "error":
+		if chunk.Error != nil {
+			unified.Error = &models.UnifiedError{
+				Code:    "stream_error",
+				Message: chunk.Error.Error(),
+				Type:    models.ErrorTypeProvider,
+			}
+			var budgetErr *llm.BudgetError
+			if errors.As(chunk.Error, &budgetErr) {
+				unified.Error.Code = budgetErr.Code
+				unified.Error.Type = models.ErrorTypeBudget
+			}
+		}
+	}
+
+	return unified
+}
+
+// chatAttributes describes a chat request on its orchestration span
+func chatAttributes(userID uuid.UUID, req models.UnifiedChatRequest) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("user.id", userID.String()),
+		attribute.String("chat.session_id", req.SessionID),
+		attribute.String("chat.model", req.Preferences.Model),
+		attribute.Int("chat.messages", len(req.Messages)),
+	}
+}
+
+// invokeDetectedTool runs the tool invocation detected in the user's last
+// message, or a web search when forced, and folds the result into the request
+func (o *OrchestrationService) invokeDetectedTool(ctx context.Context, userID uuid.UUID, req models.UnifiedChatRequest) models.UnifiedChatRequest {
+	if len(req.Messages) == 0 || o.mcpTools == nil {
+		return req
+	}
+	ctx, span := tracing.Start(ctx, "orchestration.detect_tools")
+	defer span.End()
+
+	lastMessage := req.Messages[len(req.Messages)-1]
+	if lastMessage.Role == "user" {
+		var invocation *ToolInvocation
+		var err error
+
+		// Force web search if flag is set
+		if req.ForceWebSearch {
+			// Create a web search invocation for the user's message
+			// Enable includeContent to fetch actual page content, not just snippets
+			args, _ := json.Marshal(map[string]interface{}{
+				"query":          lastMessage.Content,
+				"maxResults":     3,    // Reduced to 3 to avoid too much content
+				"includeContent": true, // Fetch actual page content
+			})
+			invocation = &ToolInvocation{
+				Type:      "builtin",
+				ServerID:  "builtin-websearch",
+				ToolName:  "web_search",
+				Arguments: args,
+			}
+		} else {
+			// Detect tool invocation normally
+			invocation, err = o.mcpTools.DetectToolInvocation(lastMessage.Content)
+		}
+
+		if err == nil && invocation != nil {
+			span.SetAttributes(
+				attribute.String("mcp.server", invocation.ServerID),
+				attribute.String("mcp.tool", invocation.ToolName),
+			)
+
+			// Invoke the tool
+			toolResult, err := o.mcpTools.InvokeToolForUser(ctx, userID, invocation)
+			if err == nil && toolResult != nil {
+				// Format the result for chat
+				formattedResult := o.mcpTools.FormatToolResultForChat(ctx, userID, toolResult, invocation.ToolName)
+
+				// For web search, prepend results to the last user message instead of adding as separate message
+				if invocation.ToolName == "web_search" && len(req.Messages) > 0 {
+					// Find the last user message and prepend search context
+					for i := len(req.Messages) - 1; i >= 0; i-- {
+						if req.Messages[i].Role == "user" {
+							// Prepend search results to user's question
+							req.Messages[i].Content = formattedResult + "\n\n" + req.Messages[i].Content
+							break
+						}
+					}
+				} else {
+					// For other tools, add as assistant message
+					req.Messages = append(req.Messages, providers.Message{
+						Role:    "assistant",
+						Content: formattedResult,
+					})
+				}
+			}
+		}
+	}
+	return req
+}
+
+// enrichFromSession replays the session history into the request
+func (o *OrchestrationService) enrichFromSession(ctx context.Context, req models.UnifiedChatRequest) models.UnifiedChatRequest {
+	if req.SessionID == "" || o.contextMemory == nil {
+		return req
+	}
+	ctx, span := tracing.Start(ctx, "orchestration.enrich_context")
+	defer span.End()
+
+	messages, err := o.messageRepo.ListBySession(ctx, req.SessionID)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return req
+	}
+	if len(messages) > 0 {
+		req.Messages = o.enrichWithContext(req.Messages, messages)
+	}
+	span.SetAttributes(attribute.Int("session.messages", len(messages)))
+	return req
 }
 
 func (o *OrchestrationService) enrichWithContext(messages []providers.Message, contextMessages []repository.Message) []providers.Message {
-	// Add relevant context from previous messages
-	// This is a simplified version - can be enhanced with more sophisticated context selection
-	maxContext := 5
-	contextCount := 0
-	
+	// Replay the session history; the gateway trims it to the routed
+	// model's context window
 	var enriched []providers.Message
-	for i := len(contextMessages) - 1; i >= 0 && contextCount < maxContext; i-- {
-		msg := contextMessages[i]
-		if msg.Role == "user" || msg.Role == "assistant" {
-			enriched = append([]providers.Message{{
-				Role:    msg.Role,
-				Content: msg.Content,
-			}}, enriched...)
-			contextCount++
+	for _, msg := range contextMessages {
+		// Tool-call steps are kept in the session but not replayed as context
+		if (msg.Role == "user" || msg.Role == "assistant") && !msg.ToolCalls.Valid {
+			enriched = append(enriched, providers.Message{
+				Role:         msg.Role,
+				Content:      msg.Content,
+				ContentArray: storedImages(msg.Metadata),
+			})
 		}
 	}
-	
+
 	// Append the new messages
 	enriched = append(enriched, messages...)
-	
-	return enriched
-}
 
-func (o *OrchestrationService) generateCacheKey(prefix string, userID string, req interface{}) string {
-	// Generate a cache key based on request parameters
-	// This is simplified - in production would use a hash of the request
-	return fmt.Sprintf("%s:%s:%v", prefix, userID, time.Now().Unix()/60) // 1-minute cache
+	return enriched
 }
 
-func (o *OrchestrationService) saveMessages(ctx context.Context, req models.UnifiedChatRequest, resp *models.UnifiedChatResponse) {
+func (o *OrchestrationService) saveMessages(ctx context.Context, req models.UnifiedChatRequest, transcript []llm.Message, resp *models.UnifiedChatResponse) {
 	// Save user message
 	if len(req.Messages) > 0 {
 		lastMsg := req.Messages[len(req.Messages)-1]
 		if lastMsg.Role == "user" {
-			o.messageRepo.Create(ctx, repository.Message{
-				ID:        uuid.New().String(),
-				SessionID: req.SessionID,
-				Role:      lastMsg.Role,
-				Content:   lastMsg.Content,
-				CreatedAt: time.Now(),
-			})
+			o.messageRepo.Create(ctx, userMessageRecord(req.SessionID, lastMsg))
 		}
 	}
-	
-	// Save assistant response
-	o.messageRepo.Create(ctx, repository.Message{
-		ID:        uuid.New().String(),
-		SessionID: req.SessionID,
-		Role:      resp.Role,
-		Content:   resp.Content,
-		CreatedAt: time.Now(),
-	})
-	
+
+	// Save tool-calling steps followed by the assistant response
+	final := llm.Message{Role: resp.Role, Content: resp.Content, Reasoning: resp.Reasoning}
+	for _, tool := range resp.Tools {
+		toolCall := llm.ToolCall{ID: tool.ID, Type: tool.Type}
+		toolCall.Function.Name = tool.Function.Name
+		toolCall.Function.Arguments = tool.Function.Arguments
+		final.ToolCalls = append(final.ToolCalls, toolCall)
+	}
+	o.saveTranscript(ctx, req.SessionID, append(transcript, final))
+
 	// Invalidate message cache
 	o.cache.Delete(fmt.Sprintf("messages:%s", req.SessionID))
 }
 
-func (o *OrchestrationService) saveStreamedMessages(ctx context.Context, req models.UnifiedChatRequest, content string) {
+func (o *OrchestrationService) saveStreamedMessages(ctx context.Context, req models.UnifiedChatRequest, transcript []llm.Message, final llm.Message) {
 	// Save user message
 	if len(req.Messages) > 0 {
 		lastMsg := req.Messages[len(req.Messages)-1]
 		if lastMsg.Role == "user" {
-			o.messageRepo.Create(ctx, repository.Message{
-				ID:        uuid.New().String(),
-				SessionID: req.SessionID,
-				Role:      lastMsg.Role,
-				Content:   lastMsg.Content,
-				CreatedAt: time.Now(),
-			})
+			o.messageRepo.Create(ctx, userMessageRecord(req.SessionID, lastMsg))
 		}
 	}
-	
-	// Save assistant response
-	o.messageRepo.Create(ctx, repository.Message{
-		ID:        uuid.New().String(),
-		SessionID: req.SessionID,
-		Role:      "assistant",
-		Content:   content,
-		CreatedAt: time.Now(),
-	})
-	
+
+	// Save tool-calling steps followed by the assistant response
+	o.saveTranscript(ctx, req.SessionID, append(transcript, final))
+
 	// Invalidate message cache
 	o.cache.Delete(fmt.Sprintf("messages:%s", req.SessionID))
 }
@@ -804,4 +1032,4 @@ func floatPtrOrc(f float32) *float32 {
 
 func intPtrOrc(i int) *int {
 	return &i
-}
\ No newline at end of file
+}
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/agentx/agentx-backend/internal/llm"
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/agentx/agentx-backend/internal/repository/postgres"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidPrompt is returned for prompt templates that fail validation
+	ErrInvalidPrompt = errors.New("invalid prompt template")
+	// ErrPromptNotFound is returned for templates that do not exist or
+	// belong to another user
+	ErrPromptNotFound = errors.New("prompt template not found")
+	// ErrPromptForbidden is returned when a non-admin writes a global template
+	ErrPromptForbidden = errors.New("only admins can change global prompt templates")
+)
+
+var promptNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,99}$`)
+
+// PromptService manages versioned prompt templates and serves them to the
+// LLM service's prompt library
+type PromptService struct {
+	repo    *postgres.PromptRepository
+	library *llm.PromptLibrary
+}
+
+// NewPromptService creates a new prompt service and registers it as the
+// library's prompt store
+func NewPromptService(repo *postgres.PromptRepository, library *llm.PromptLibrary) *PromptService {
+	s := &PromptService{
+		repo:    repo,
+		library: library,
+	}
+	if library != nil {
+		library.SetStore(s)
+	}
+	return s
+}
+
+// ResolvePrompt implements llm.PromptStore
+func (s *PromptService) ResolvePrompt(ctx context.Context, userID, name string) (*llm.PromptTemplate, error) {
+	if uid, err := uuid.Parse(userID); err == nil {
+		tmpl, err := s.repo.GetDefault(ctx, &uid, name)
+		if err != nil {
+			return nil, err
+		}
+		if tmpl != nil {
+			return toLibraryPrompt(tmpl), nil
+		}
+	}
+
+	tmpl, err := s.repo.GetDefault(ctx, nil, name)
+	if err != nil || tmpl == nil {
+		return nil, err
+	}
+	return toLibraryPrompt(tmpl), nil
+}
+
+// List returns the global templates and the user's own, optionally only the
+// versions of one name
+func (s *PromptService) List(ctx context.Context, userID uuid.UUID, name string) ([]models.PromptTemplate, error) {
+	return s.repo.ListVisible(ctx, userID, name)
+}
+
+// Builtins returns the compiled-in templates stored versions override
+func (s *PromptService) Builtins() []llm.PromptTemplate {
+	return s.library.Builtins()
+}
+
+// Get returns a template version visible to the user
+func (s *PromptService) Get(ctx context.Context, userID, id uuid.UUID) (*models.PromptTemplate, error) {
+	tmpl, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl == nil || (tmpl.UserID != nil && *tmpl.UserID != userID) {
+		return nil, ErrPromptNotFound
+	}
+	return tmpl, nil
+}
+
+// Create stores a new version of a template, global when requested by an
+// admin and the user's own otherwise
+func (s *PromptService) Create(ctx context.Context, userID uuid.UUID, isAdmin bool, req *models.PromptTemplateCreateRequest) (*models.PromptTemplate, error) {
+	tmpl := &models.PromptTemplate{
+		Name:           req.Name,
+		Version:        req.Version,
+		Description:    req.Description,
+		SystemTemplate: req.System,
+		UserTemplate:   req.User,
+	}
+	if req.Global {
+		if !isAdmin {
+			return nil, ErrPromptForbidden
+		}
+	} else {
+		tmpl.UserID = &userID
+	}
+
+	if err := validatePromptTemplate(tmpl); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.ListVersions(ctx, tmpl.UserID, tmpl.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range existing {
+		if version.Version == tmpl.Version {
+			return nil, fmt.Errorf("%w: version %s of %s already exists", ErrInvalidPrompt, tmpl.Version, tmpl.Name)
+		}
+	}
+
+	// An owner's first version is what their tasks render until told otherwise
+	tmpl.IsDefault = len(existing) == 0
+	if req.Default != nil {
+		tmpl.IsDefault = *req.Default
+	}
+
+	if err := s.repo.Create(ctx, tmpl); err != nil {
+		return nil, err
+	}
+
+	s.library.Invalidate()
+	return tmpl, nil
+}
+
+// Update updates a version's description or makes it the default, which
+// rolls the owner's tasks forward or back to it
+func (s *PromptService) Update(ctx context.Context, userID uuid.UUID, isAdmin bool, id uuid.UUID, req *models.PromptTemplateUpdateRequest) (*models.PromptTemplate, error) {
+	tmpl, err := s.writable(ctx, userID, isAdmin, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Description != nil {
+		tmpl.Description = *req.Description
+	}
+	if req.Default != nil {
+		tmpl.IsDefault = *req.Default
+	}
+
+	if err := s.repo.Update(ctx, tmpl); err != nil {
+		return nil, err
+	}
+
+	s.library.Invalidate()
+	return tmpl, nil
+}
+
+// Delete deletes a version. Deleting the default makes the owner's highest
+// remaining version the default.
+func (s *PromptService) Delete(ctx context.Context, userID uuid.UUID, isAdmin bool, id uuid.UUID) error {
+	tmpl, err := s.writable(ctx, userID, isAdmin, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	defer s.library.Invalidate()
+
+	if !tmpl.IsDefault {
+		return nil
+	}
+	remaining, err := s.repo.ListVersions(ctx, tmpl.UserID, tmpl.Name)
+	if err != nil || len(remaining) == 0 {
+		return err
+	}
+	latest := remaining[0]
+	for _, version := range remaining[1:] {
+		if llm.ComparePromptVersions(version.Version, latest.Version) > 0 {
+			latest = version
+		}
+	}
+	latest.IsDefault = true
+	return s.repo.Update(ctx, &latest)
+}
+
+// Render previews a template with the given variables without calling a
+// model
+func (s *PromptService) Render(ctx context.Context, userID uuid.UUID, req *models.PromptRenderRequest) (*llm.RenderedPrompt, error) {
+	var tmpl llm.PromptTemplate
+	switch {
+	case req.System != "" || req.User != "":
+		tmpl = llm.PromptTemplate{
+			Name:    req.Name,
+			Version: req.Version,
+			Source:  llm.PromptSourceInline,
+			System:  req.System,
+			User:    req.User,
+		}
+	case req.ID != nil:
+		stored, err := s.Get(ctx, userID, *req.ID)
+		if err != nil {
+			return nil, err
+		}
+		tmpl = *toLibraryPrompt(stored)
+	case req.Name == "":
+		return nil, fmt.Errorf("%w: name, id or inline templates are required", ErrInvalidPrompt)
+	case req.Version != "":
+		stored, err := s.findVersion(ctx, userID, req.Name, req.Version)
+		if err != nil {
+			return nil, err
+		}
+		tmpl = *toLibraryPrompt(stored)
+	default:
+		resolved, err := s.library.Resolve(ctx, userID.String(), req.Name)
+		if errors.Is(err, llm.ErrUnknownPrompt) {
+			return nil, ErrPromptNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		tmpl = resolved
+	}
+
+	vars := req.Variables
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	rendered, err := llm.RenderPrompt(tmpl, vars)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPrompt, err)
+	}
+	return rendered, nil
+}
+
+// findVersion returns the user's version of a template, or the global one
+func (s *PromptService) findVersion(ctx context.Context, userID uuid.UUID, name, version string) (*models.PromptTemplate, error) {
+	tmpl, err := s.repo.FindVersion(ctx, &userID, name, version)
+	if err != nil || tmpl != nil {
+		return tmpl, err
+	}
+	tmpl, err = s.repo.FindVersion(ctx, nil, name, version)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl == nil {
+		return nil, ErrPromptNotFound
+	}
+	return tmpl, nil
+}
+
+// writable returns a version the user may change: their own, or a global one
+// for admins
+func (s *PromptService) writable(ctx context.Context, userID uuid.UUID, isAdmin bool, id uuid.UUID) (*models.PromptTemplate, error) {
+	tmpl, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl.UserID == nil && !isAdmin {
+		return nil, ErrPromptForbidden
+	}
+	return tmpl, nil
+}
+
+func validatePromptTemplate(tmpl *models.PromptTemplate) error {
+	if !promptNamePattern.MatchString(tmpl.Name) {
+		return fmt.Errorf("%w: name must be lowercase letters, digits, '_', '.' or '-'", ErrInvalidPrompt)
+	}
+	if err := llm.ValidatePromptVersion(tmpl.Version); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPrompt, err)
+	}
+	if tmpl.SystemTemplate == "" && tmpl.UserTemplate == "" {
+		return fmt.Errorf("%w: a system or user template is required", ErrInvalidPrompt)
+	}
+	if err := llm.ValidatePrompt(*toLibraryPrompt(tmpl)); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPrompt, err)
+	}
+	return nil
+}
+
+func toLibraryPrompt(tmpl *models.PromptTemplate) *llm.PromptTemplate {
+	source := llm.PromptSourceUser
+	if tmpl.UserID == nil {
+		source = llm.PromptSourceGlobal
+	}
+	return &llm.PromptTemplate{
+		Name:    tmpl.Name,
+		Version: tmpl.Version,
+		Source:  source,
+		System:  tmpl.SystemTemplate,
+		User:    tmpl.UserTemplate,
+	}
+}
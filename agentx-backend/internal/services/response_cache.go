@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/llm"
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/agentx/agentx-backend/internal/repository/postgres"
+)
+
+// semanticCandidates caps how many cached prompts a semantic lookup compares
+const semanticCandidates = 200
+
+// ResponseCacheService keeps the LLM gateway's response cache in Postgres so
+// it survives restarts and is shared between backend instances
+type ResponseCacheService struct {
+	repo *postgres.ResponseCacheRepository
+}
+
+// NewResponseCacheService creates a new response cache service, registers it
+// as the gateway's cache store and starts removing expired entries
+func NewResponseCacheService(repo *postgres.ResponseCacheRepository, gateway *llm.Gateway) *ResponseCacheService {
+	s := &ResponseCacheService{
+		repo: repo,
+	}
+	if gateway != nil {
+		gateway.SetCacheStore(s)
+	}
+	go s.cleanupExpired()
+	return s
+}
+
+// Get implements llm.CacheStore
+func (s *ResponseCacheService) Get(ctx context.Context, key string) (*llm.CacheEntry, error) {
+	entry, err := s.repo.Get(ctx, key)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return toCacheEntry(entry)
+}
+
+// Put implements llm.CacheStore
+func (s *ResponseCacheService) Put(ctx context.Context, entry *llm.CacheEntry) error {
+	response, err := json.Marshal(entry.Response)
+	if err != nil {
+		return fmt.Errorf("failed to encode cached response: %w", err)
+	}
+	return s.repo.Put(ctx, &models.ResponseCacheEntry{
+		Key:       entry.Key,
+		UserID:    entry.UserID,
+		Scope:     entry.Scope,
+		Embedding: entry.Embedding,
+		Response:  response,
+		ExpiresAt: entry.ExpiresAt,
+	})
+}
+
+// Nearest implements llm.CacheStore
+func (s *ResponseCacheService) Nearest(ctx context.Context, userID, scope string, embedding []float32) (*llm.CacheEntry, float64, error) {
+	entries, err := s.repo.ListEmbedded(ctx, userID, scope, semanticCandidates)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var best *models.ResponseCacheEntry
+	bestSimilarity := -1.0
+	for i := range entries {
+		if similarity := llm.CosineSimilarity(embedding, entries[i].Embedding); similarity > bestSimilarity {
+			best, bestSimilarity = &entries[i], similarity
+		}
+	}
+	if best == nil {
+		return nil, 0, nil
+	}
+
+	entry, err := toCacheEntry(best)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entry, bestSimilarity, nil
+}
+
+// cleanupExpired periodically removes expired entries
+func (s *ResponseCacheService) cleanupExpired() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := s.repo.DeleteExpired(context.Background()); err != nil {
+			fmt.Printf("[ResponseCacheService] Warning: Failed to remove expired entries: %v\n", err)
+		}
+	}
+}
+
+// toCacheEntry converts a stored entry into the gateway representation
+func toCacheEntry(entry *models.ResponseCacheEntry) (*llm.CacheEntry, error) {
+	var response llm.Response
+	if err := json.Unmarshal(entry.Response, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode cached response: %w", err)
+	}
+	return &llm.CacheEntry{
+		Key:       entry.Key,
+		Scope:     entry.Scope,
+		UserID:    entry.UserID,
+		Embedding: entry.Embedding,
+		Response:  &response,
+		ExpiresAt: entry.ExpiresAt,
+	}, nil
+}
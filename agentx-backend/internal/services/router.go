@@ -167,8 +167,8 @@ func (r *RequestRouter) analyzeRequirements(req models.UnifiedChatRequest) provi
 	}
 
 	// Set output format
-	if req.ResponseFormat != "" {
-		requirements.OutputFormat = req.ResponseFormat
+	if req.ResponseFormat.Type != "" {
+		requirements.OutputFormat = req.ResponseFormat.Type
 	}
 
 	return requirements
@@ -0,0 +1,522 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/agentx/agentx-backend/internal/llm"
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/agentx/agentx-backend/internal/repository/postgres"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidRouting is returned for routing rules or chains that fail validation
+	ErrInvalidRouting = errors.New("invalid routing configuration")
+	// ErrRoutingForbidden is returned when a user edits a rule they do not own
+	ErrRoutingForbidden = errors.New("routing rule is not editable by this user")
+
+	// Lookups of rules, chains and pools that do not exist fail with these
+	ErrRoutingRuleNotFound    = postgres.ErrRoutingRuleNotFound
+	ErrFallbackChainNotFound  = postgres.ErrFallbackChainNotFound
+	ErrConnectionPoolNotFound = postgres.ErrConnectionPoolNotFound
+)
+
+// RoutingService manages persisted routing rules, fallback chains and
+// connection pools and serves them to the LLM gateway router
+type RoutingService struct {
+	repo        *postgres.RoutingRepository
+	connections *ConnectionService
+	gateway     *llm.Gateway
+}
+
+// NewRoutingService creates a new routing service and registers it as the
+// gateway's routing store
+func NewRoutingService(repo *postgres.RoutingRepository, connections *ConnectionService, gateway *llm.Gateway) *RoutingService {
+	s := &RoutingService{
+		repo:        repo,
+		connections: connections,
+		gateway:     gateway,
+	}
+	if gateway != nil {
+		gateway.SetRoutingStore(s)
+	}
+	return s
+}
+
+// LoadRouting implements llm.RoutingStore
+func (s *RoutingService) LoadRouting(ctx context.Context, userID string) (*llm.RoutingConfig, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	rules, err := s.repo.ListRules(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	chains, err := s.repo.ListChains(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	pools, err := s.repo.ListPools(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &llm.RoutingConfig{}
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		config.Rules = append(config.Rules, toGatewayRule(rule))
+	}
+	for _, chain := range chains {
+		if !chain.Enabled {
+			continue
+		}
+		config.Chains = append(config.Chains, toGatewayChain(chain))
+	}
+	for _, pool := range pools {
+		if !pool.Enabled {
+			continue
+		}
+		config.Pools = append(config.Pools, toGatewayPool(pool))
+	}
+
+	return config, nil
+}
+
+// ListRules returns the user's routing rules together with global rules
+func (s *RoutingService) ListRules(ctx context.Context, userID uuid.UUID) ([]models.RoutingRule, error) {
+	return s.repo.ListRules(ctx, userID)
+}
+
+// GetRule returns a routing rule visible to the user
+func (s *RoutingService) GetRule(ctx context.Context, userID, ruleID uuid.UUID) (*models.RoutingRule, error) {
+	return s.repo.GetRule(ctx, userID, ruleID)
+}
+
+// CreateRule creates a routing rule. Only admins may create global rules.
+func (s *RoutingService) CreateRule(ctx context.Context, userID uuid.UUID, isAdmin bool, req *models.RoutingRuleCreateRequest) (*models.RoutingRule, error) {
+	rule := &models.RoutingRule{
+		Name:               req.Name,
+		Description:        req.Description,
+		Priority:           req.Priority,
+		Enabled:            true,
+		Conditions:         req.Conditions,
+		TargetConnectionID: req.TargetConnectionID,
+		TargetProvider:     s.connections.mapProviderType(req.TargetProvider),
+		TargetModel:        req.TargetModel,
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if req.Global {
+		if !isAdmin {
+			return nil, ErrRoutingForbidden
+		}
+	} else {
+		rule.UserID = &userID
+	}
+
+	if err := s.validateRule(ctx, userID, rule); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	s.invalidate(rule)
+	return rule, nil
+}
+
+// UpdateRule updates a routing rule. Providing either target field replaces
+// the whole target.
+func (s *RoutingService) UpdateRule(ctx context.Context, userID uuid.UUID, isAdmin bool, ruleID uuid.UUID, req *models.RoutingRuleUpdateRequest) (*models.RoutingRule, error) {
+	rule, err := s.editableRule(ctx, userID, isAdmin, ruleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		rule.Name = req.Name
+	}
+	if req.Description != nil {
+		rule.Description = *req.Description
+	}
+	if req.Priority != nil {
+		rule.Priority = *req.Priority
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if req.Conditions != nil {
+		rule.Conditions = *req.Conditions
+	}
+	if req.TargetConnectionID != nil || req.TargetProvider != nil {
+		rule.TargetConnectionID = req.TargetConnectionID
+		rule.TargetProvider = ""
+		if req.TargetProvider != nil {
+			rule.TargetProvider = s.connections.mapProviderType(*req.TargetProvider)
+		}
+	}
+	if req.TargetModel != nil {
+		rule.TargetModel = *req.TargetModel
+	}
+
+	if err := s.validateRule(ctx, userID, rule); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	s.invalidate(rule)
+	return rule, nil
+}
+
+// DeleteRule deletes a routing rule
+func (s *RoutingService) DeleteRule(ctx context.Context, userID uuid.UUID, isAdmin bool, ruleID uuid.UUID) error {
+	rule, err := s.editableRule(ctx, userID, isAdmin, ruleID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteRule(ctx, ruleID); err != nil {
+		return err
+	}
+
+	s.invalidate(rule)
+	return nil
+}
+
+// ListChains returns the user's fallback chains
+func (s *RoutingService) ListChains(ctx context.Context, userID uuid.UUID) ([]models.FallbackChain, error) {
+	return s.repo.ListChains(ctx, userID)
+}
+
+// GetChain returns a fallback chain
+func (s *RoutingService) GetChain(ctx context.Context, userID, chainID uuid.UUID) (*models.FallbackChain, error) {
+	return s.repo.GetChain(ctx, userID, chainID)
+}
+
+// CreateChain creates a fallback chain
+func (s *RoutingService) CreateChain(ctx context.Context, userID uuid.UUID, req *models.FallbackChainCreateRequest) (*models.FallbackChain, error) {
+	chain := &models.FallbackChain{
+		UserID:              userID,
+		Name:                req.Name,
+		PrimaryConnectionID: req.PrimaryConnectionID,
+		Steps:               req.Steps,
+		Enabled:             true,
+	}
+	if req.Enabled != nil {
+		chain.Enabled = *req.Enabled
+	}
+
+	if err := s.validateChain(ctx, chain); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateChain(ctx, chain); err != nil {
+		return nil, err
+	}
+
+	s.gateway.InvalidateRouting(userID.String())
+	return chain, nil
+}
+
+// UpdateChain updates a fallback chain
+func (s *RoutingService) UpdateChain(ctx context.Context, userID, chainID uuid.UUID, req *models.FallbackChainUpdateRequest) (*models.FallbackChain, error) {
+	chain, err := s.repo.GetChain(ctx, userID, chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		chain.Name = req.Name
+	}
+	if req.PrimaryConnectionID != nil {
+		chain.PrimaryConnectionID = req.PrimaryConnectionID
+	}
+	if req.Steps != nil {
+		chain.Steps = req.Steps
+	}
+	if req.Enabled != nil {
+		chain.Enabled = *req.Enabled
+	}
+
+	if err := s.validateChain(ctx, chain); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateChain(ctx, chain); err != nil {
+		return nil, err
+	}
+
+	s.gateway.InvalidateRouting(userID.String())
+	return chain, nil
+}
+
+// DeleteChain deletes a fallback chain
+func (s *RoutingService) DeleteChain(ctx context.Context, userID, chainID uuid.UUID) error {
+	if err := s.repo.DeleteChain(ctx, userID, chainID); err != nil {
+		return err
+	}
+
+	s.gateway.InvalidateRouting(userID.String())
+	return nil
+}
+
+// ListPools returns the user's connection pools
+func (s *RoutingService) ListPools(ctx context.Context, userID uuid.UUID) ([]models.ConnectionPool, error) {
+	return s.repo.ListPools(ctx, userID)
+}
+
+// GetPool returns a connection pool
+func (s *RoutingService) GetPool(ctx context.Context, userID, poolID uuid.UUID) (*models.ConnectionPool, error) {
+	return s.repo.GetPool(ctx, userID, poolID)
+}
+
+// CreatePool creates a connection pool
+func (s *RoutingService) CreatePool(ctx context.Context, userID uuid.UUID, req *models.ConnectionPoolCreateRequest) (*models.ConnectionPool, error) {
+	pool := &models.ConnectionPool{
+		UserID:   userID,
+		Name:     req.Name,
+		Strategy: req.Strategy,
+		Members:  req.Members,
+		Enabled:  true,
+	}
+	if pool.Strategy == "" {
+		pool.Strategy = llm.BalanceRoundRobin
+	}
+	if req.Enabled != nil {
+		pool.Enabled = *req.Enabled
+	}
+
+	if err := s.validatePool(ctx, pool); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreatePool(ctx, pool); err != nil {
+		return nil, err
+	}
+
+	s.gateway.InvalidateRouting(userID.String())
+	return pool, nil
+}
+
+// UpdatePool updates a connection pool
+func (s *RoutingService) UpdatePool(ctx context.Context, userID, poolID uuid.UUID, req *models.ConnectionPoolUpdateRequest) (*models.ConnectionPool, error) {
+	pool, err := s.repo.GetPool(ctx, userID, poolID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		pool.Name = req.Name
+	}
+	if req.Strategy != nil {
+		pool.Strategy = *req.Strategy
+	}
+	if req.Members != nil {
+		pool.Members = req.Members
+	}
+	if req.Enabled != nil {
+		pool.Enabled = *req.Enabled
+	}
+
+	if err := s.validatePool(ctx, pool); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdatePool(ctx, pool); err != nil {
+		return nil, err
+	}
+
+	s.gateway.InvalidateRouting(userID.String())
+	return pool, nil
+}
+
+// DeletePool deletes a connection pool
+func (s *RoutingService) DeletePool(ctx context.Context, userID, poolID uuid.UUID) error {
+	if err := s.repo.DeletePool(ctx, userID, poolID); err != nil {
+		return err
+	}
+
+	s.gateway.InvalidateRouting(userID.String())
+	return nil
+}
+
+// editableRule loads a rule and checks the user may modify it
+func (s *RoutingService) editableRule(ctx context.Context, userID uuid.UUID, isAdmin bool, ruleID uuid.UUID) (*models.RoutingRule, error) {
+	rule, err := s.repo.GetRule(ctx, userID, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	if rule.IsGlobal() && !isAdmin {
+		return nil, ErrRoutingForbidden
+	}
+	return rule, nil
+}
+
+// validateRule checks that a rule has a usable target
+func (s *RoutingService) validateRule(ctx context.Context, userID uuid.UUID, rule *models.RoutingRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRouting)
+	}
+	if rule.TargetConnectionID == nil && rule.TargetProvider == "" {
+		return fmt.Errorf("%w: target_connection_id or target_provider is required", ErrInvalidRouting)
+	}
+	if rule.TargetConnectionID != nil {
+		// Connections are per user, so a global rule can only target a provider type
+		if rule.IsGlobal() {
+			return fmt.Errorf("%w: global rules must target a provider type, not a connection", ErrInvalidRouting)
+		}
+		if err := s.checkConnection(ctx, userID, *rule.TargetConnectionID); err != nil {
+			return err
+		}
+	}
+	c := rule.Conditions
+	if c.MinMessageChars < 0 || c.MaxMessageChars < 0 || (c.MaxMessageChars > 0 && c.MinMessageChars > c.MaxMessageChars) {
+		return fmt.Errorf("%w: invalid message size bounds", ErrInvalidRouting)
+	}
+	return nil
+}
+
+// validateChain checks that every connection in a chain belongs to its owner
+func (s *RoutingService) validateChain(ctx context.Context, chain *models.FallbackChain) error {
+	if chain.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRouting)
+	}
+	if len(chain.Steps) == 0 {
+		return fmt.Errorf("%w: at least one fallback step is required", ErrInvalidRouting)
+	}
+	if chain.PrimaryConnectionID != nil {
+		if err := s.checkConnection(ctx, chain.UserID, *chain.PrimaryConnectionID); err != nil {
+			return err
+		}
+	}
+	for _, step := range chain.Steps {
+		if chain.PrimaryConnectionID != nil && step.ConnectionID == *chain.PrimaryConnectionID {
+			return fmt.Errorf("%w: a chain cannot fall back to its primary connection", ErrInvalidRouting)
+		}
+		if err := s.checkConnection(ctx, chain.UserID, step.ConnectionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePool checks the pool's strategy and that its connections belong to
+// its owner and to no other enabled pool
+func (s *RoutingService) validatePool(ctx context.Context, pool *models.ConnectionPool) error {
+	if pool.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRouting)
+	}
+	if !llm.IsBalancingStrategy(pool.Strategy) {
+		return fmt.Errorf("%w: unknown strategy %q", ErrInvalidRouting, pool.Strategy)
+	}
+	if len(pool.Members) == 0 {
+		return fmt.Errorf("%w: at least one member is required", ErrInvalidRouting)
+	}
+
+	pooled := make(map[uuid.UUID]string)
+	if pool.Enabled {
+		others, err := s.repo.ListPools(ctx, pool.UserID)
+		if err != nil {
+			return err
+		}
+		for _, other := range others {
+			if other.ID == pool.ID || !other.Enabled {
+				continue
+			}
+			for _, member := range other.Members {
+				pooled[member.ConnectionID] = other.Name
+			}
+		}
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	for _, member := range pool.Members {
+		if seen[member.ConnectionID] {
+			return fmt.Errorf("%w: connection %s is listed twice", ErrInvalidRouting, member.ConnectionID)
+		}
+		seen[member.ConnectionID] = true
+		if member.Weight < 0 {
+			return fmt.Errorf("%w: weights cannot be negative", ErrInvalidRouting)
+		}
+		if other, ok := pooled[member.ConnectionID]; ok {
+			return fmt.Errorf("%w: connection %s already belongs to pool %q", ErrInvalidRouting, member.ConnectionID, other)
+		}
+		if err := s.checkConnection(ctx, pool.UserID, member.ConnectionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkConnection verifies the connection exists and belongs to the user
+func (s *RoutingService) checkConnection(ctx context.Context, userID, connectionID uuid.UUID) error {
+	if _, err := s.connections.GetConnection(ctx, userID, connectionID.String()); err != nil {
+		return fmt.Errorf("%w: connection %s not found", ErrInvalidRouting, connectionID)
+	}
+	return nil
+}
+
+// invalidate drops cached routing for everyone a rule may affect
+func (s *RoutingService) invalidate(rule *models.RoutingRule) {
+	if rule.IsGlobal() {
+		s.gateway.InvalidateRouting("")
+		return
+	}
+	s.gateway.InvalidateRouting(rule.UserID.String())
+}
+
+// toGatewayRule converts a stored rule to the router's representation
+func toGatewayRule(rule models.RoutingRule) llm.DeclarativeRule {
+	converted := llm.DeclarativeRule{
+		ID:       rule.ID.String(),
+		Name:     rule.Name,
+		Priority: rule.Priority,
+		Match: llm.RuleMatch{
+			Models:          rule.Conditions.Models,
+			Capabilities:    rule.Conditions.Capabilities,
+			MinMessageChars: rule.Conditions.MinMessageChars,
+			MaxMessageChars: rule.Conditions.MaxMessageChars,
+			TaskTypes:       rule.Conditions.TaskTypes,
+			UserIDs:         rule.Conditions.UserIDs,
+		},
+		Target: llm.RuleTarget{
+			ProviderType: rule.TargetProvider,
+			Model:        rule.TargetModel,
+		},
+	}
+	if rule.TargetConnectionID != nil {
+		converted.Target.ConnectionID = rule.TargetConnectionID.String()
+	}
+	return converted
+}
+
+// toGatewayChain converts a stored fallback chain to the router's representation
+func toGatewayChain(chain models.FallbackChain) llm.FallbackChain {
+	converted := llm.FallbackChain{Name: chain.Name}
+	if chain.PrimaryConnectionID != nil {
+		converted.PrimaryConnectionID = chain.PrimaryConnectionID.String()
+	}
+	for _, step := range chain.Steps {
+		converted.Steps = append(converted.Steps, llm.FallbackStep{
+			ConnectionID: step.ConnectionID.String(),
+			Model:        step.Model,
+		})
+	}
+	return converted
+}
+
+// toGatewayPool converts a stored connection pool to the router's representation
+func toGatewayPool(pool models.ConnectionPool) llm.ConnectionPool {
+	converted := llm.ConnectionPool{Name: pool.Name, Strategy: pool.Strategy}
+	for _, member := range pool.Members {
+		converted.Members = append(converted.Members, llm.PoolMember{
+			ConnectionID: member.ConnectionID.String(),
+			Weight:       member.Weight,
+		})
+	}
+	return converted
+}
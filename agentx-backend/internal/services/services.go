@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 	
 	"github.com/jmoiron/sqlx"
 	"github.com/agentx/agentx-backend/internal/db"
@@ -50,6 +52,12 @@ type Services struct {
 	Config        *ConfigService
 	Summary       *SummaryService   // Summary generation service
 	MCP           *MCPService       // MCP server management service
+	Routing       *RoutingService   // Persisted routing rules and fallback chains
+	Usage         *UsageService     // Usage ledger and pricing catalog
+	Budgets       *BudgetService    // Spend caps and token quotas
+	ResponseCache *ResponseCacheService // Persisted gateway response cache
+	Batches       *BatchService     // Background batch completion jobs
+	Prompts       *PromptService    // Versioned prompt templates for built-in tasks
 	BuiltinMCP    *mcp.BuiltinMCPManager // Built-in MCP server management
 	
 	// Legacy services (keeping minimal for compatibility)
@@ -83,9 +91,37 @@ func NewServices(
 		fmt.Printf("[Services] LLM Gateway initialized successfully\n")
 	}
 	
+	// Probe open circuit breakers so recovered connections close again
+	// without waiting for user traffic
+	probeInterval := 15 * time.Second
+	if v := os.Getenv("AGENTX_BREAKER_PROBE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			probeInterval = d
+		}
+	}
+	gateway.StartBreakerProbes(probeInterval)
+	
 	// Create ConnectionService with Gateway (SINGLE SOURCE OF TRUTH)
 	connectionService := NewConnectionService(connectionRepo, gateway)
 	
+	// Load declarative routing rules and fallback chains into the gateway router
+	routingService := NewRoutingService(postgres.NewRoutingRepository(sqlDB), connectionService, gateway)
+	
+	// Record every gateway call in the usage ledger
+	usageService := NewUsageService(postgres.NewUsageRepository(sqlDB), gateway)
+	
+	// Enforce spend caps and token quotas in the gateway
+	budgetService := NewBudgetService(postgres.NewBudgetRepository(sqlDB), gateway)
+	
+	// Keep cached gateway responses across restarts
+	responseCache := NewResponseCacheService(postgres.NewResponseCacheRepository(sqlDB), gateway)
+
+	// Match semantic cache prompts with the user's own embedding model when
+	// configured, keeping the hashing embedder for users without one
+	if model := os.Getenv("AGENTX_CACHE_EMBEDDING_MODEL"); model != "" {
+		gateway.SetCacheEmbedder(llm.NewGatewayEmbedder(gateway, model, llm.NewHashingEmbedder(512)))
+	}
+
 	// Create the general LLM service  
 	fmt.Printf("[Services] Creating LLM service\n")
 	
@@ -106,8 +142,11 @@ func NewServices(
 	sessionProvider := &SessionProviderAdapter{orchestrator: nil} // Will be set after orchestrator creation
 	llmService := llm.NewService(gateway, sessionProvider)
 	
+	// Resolve built-in task prompts from stored templates
+	promptService := NewPromptService(postgres.NewPromptRepository(sqlDB), llmService.Prompts())
+	
 	// Create MCP tool integration
-	mcpTools := NewMCPToolIntegration(builtinMCPManager, mcpService)
+	mcpTools := NewMCPToolIntegration(builtinMCPManager, mcpService, llmService.Prompts())
 	
 	// Create the main orchestrator
 	orchestrator := NewOrchestrationService(
@@ -121,9 +160,14 @@ func NewServices(
 		mcpTools,
 	)
 	
+	orchestrator.SetAgentLoopConfig(AgentLoopConfigFromEnv())
+	
 	// Wire up the session provider with the orchestrator
 	sessionProvider.orchestrator = orchestrator
 	
+	// Run batch jobs through the LLM service, resuming unfinished ones
+	batchService := NewBatchService(postgres.NewBatchRepository(sqlDB), llmService, orchestrator)
+	
 	// Create summary service - reuse the existing llmService
 	database := db.NewDatabaseFromSQLX(sqlDB) // Create database wrapper for summary service
 	summaryService := NewSummaryService(database, &LLMService{
@@ -131,7 +175,24 @@ func NewServices(
 		sessionRepo:   sessionRepo,
 		messageRepo:   messageRepo,
 		contextMemory: contextMemory,
-	})
+	}, llmService.Prompts())
+	
+	// Fit prompts to the routed model's context window, substituting
+	// session summaries for dropped history when configured
+	budgeter := gateway.ContextBudgeter()
+	budgeter.SetSummaryProvider(summaryService)
+	if v := os.Getenv("AGENTX_CONTEXT_STRATEGY"); v != "" {
+		if strategy, err := llm.ParseContextStrategy(v); err == nil {
+			budgeter.SetStrategy(strategy)
+		} else {
+			fmt.Printf("[Services] Warning: %v\n", err)
+		}
+	}
+	if v := os.Getenv("AGENTX_CONTEXT_KEEP_LAST_N"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			budgeter.SetKeepLastN(n)
+		}
+	}
 	
 	return &Services{
 		// Primary service
@@ -145,10 +206,25 @@ func NewServices(
 		Config:        configService,
 		Summary:       summaryService,
 		MCP:           mcpService,
+		Routing:       routingService,
+		Usage:         usageService,
+		Budgets:       budgetService,
+		ResponseCache: responseCache,
+		Batches:       batchService,
+		Prompts:       promptService,
 		BuiltinMCP:    builtinMCPManager,
 		
 		// Minimal legacy services for compatibility
 		Chat:      NewChatService(providers, sessionRepo, messageRepo),
 		Providers: providers,
 	}
-}
\ No newline at end of file
+}
+
+// Close flushes work the services keep in the background, such as queued
+// usage records, waiting at most until ctx is done
+func (s *Services) Close(ctx context.Context) error {
+	if s.Usage != nil {
+		return s.Usage.Close(ctx)
+	}
+	return nil
+}
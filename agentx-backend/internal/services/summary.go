@@ -17,12 +17,14 @@ import (
 type SummaryService struct {
 	db         *db.Database
 	llmService *LLMService
+	prompts    *llm.PromptLibrary
 }
 
-func NewSummaryService(database *db.Database, llmService *LLMService) *SummaryService {
+func NewSummaryService(database *db.Database, llmService *LLMService, prompts *llm.PromptLibrary) *SummaryService {
 	return &SummaryService{
 		db:         database,
 		llmService: llmService,
+		prompts:    prompts,
 	}
 }
 
@@ -81,29 +83,22 @@ func (s *SummaryService) GenerateSessionSummary(ctx context.Context, userID, ses
 		conversationBuilder.WriteString(fmt.Sprintf("%s: %s\n\n", strings.Title(msg.Role), msg.Content))
 	}
 
-	// Generate summary using LLM
-	summaryPrompt := fmt.Sprintf(`Create a concise summary of this conversation that can replace the full messages in an LLM context.
-
-Include ONLY the essential information needed for context continuity:
-- Key facts established
-- Important decisions made  
-- Critical technical details (errors, code snippets if crucial)
-- Current task/question being worked on
-- User preferences discovered
-
-Format as a brief narrative (max 200 words) that provides context for continuing the conversation.
-
-Conversation to summarize:
-%s
-
-Summary:`, conversationBuilder.String())
+	// Generate summary using LLM, with the user's session_summary template
+	summaryPrompt, err := s.prompts.Render(ctx, userID, llm.PromptSessionSummary, map[string]interface{}{
+		"Conversation": conversationBuilder.String(),
+		"MessageCount": len(messages),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build summary prompt: %w", err)
+	}
 
 	// Use the LLM service to generate summary
 	summaryReq := &llm.CompletionRequest{
 		Task: "summarize",
 		Context: map[string]interface{}{
 			"session_id": sessionID,
-			"messages":   summaryPrompt,
+			"messages":   summaryPrompt.User,
+			"system":     summaryPrompt.System,
 		},
 		Parameters: llm.Parameters{
 			Temperature: floatPtr(0.7),
@@ -208,6 +203,20 @@ func (s *SummaryService) GetSessionSummaries(ctx context.Context, userID, sessio
 	return summaries, nil
 }
 
+// LatestSummary returns the text of the newest summary of a session, or an
+// empty string if none exists. It lets the gateway substitute summaries for
+// history that does not fit the context window.
+func (s *SummaryService) LatestSummary(ctx context.Context, userID, sessionID string) (string, error) {
+	summaries, err := s.GetSessionSummaries(ctx, userID, sessionID)
+	if err != nil {
+		return "", err
+	}
+	if len(summaries) == 0 {
+		return "", nil
+	}
+	return summaries[0].SummaryText, nil
+}
+
 // AutoGenerateSummary checks if a session needs summarization and generates it
 func (s *SummaryService) AutoGenerateSummary(ctx context.Context, userID, sessionID string) error {
 	// Convert Pool to sqlx.DB
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/agentx/agentx-backend/internal/llm"
+	"github.com/agentx/agentx-backend/internal/models"
+	"github.com/agentx/agentx-backend/internal/repository/postgres"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidUsageQuery is returned for usage queries that fail validation
+	ErrInvalidUsageQuery = errors.New("invalid usage query")
+	// ErrInvalidPrice is returned for model prices that fail validation
+	ErrInvalidPrice = errors.New("invalid model price")
+)
+
+// usageQueueSize is how many usage records may wait to be written
+const usageQueueSize = 1024
+
+// UsageService records every gateway call in the usage ledger, aggregates
+// the ledger and manages the editable pricing catalog
+type UsageService struct {
+	repo    *postgres.UsageRepository
+	gateway *llm.Gateway
+
+	// Records are written by a single worker, in order
+	mu      sync.RWMutex
+	queue   chan *models.UsageRecord
+	drained chan struct{}
+	closed  bool
+}
+
+// NewUsageService creates a new usage service, registers it as the gateway's
+// usage recorder and loads admin-defined prices into the pricing catalog
+func NewUsageService(repo *postgres.UsageRepository, gateway *llm.Gateway) *UsageService {
+	s := &UsageService{
+		repo:    repo,
+		gateway: gateway,
+		queue:   make(chan *models.UsageRecord, usageQueueSize),
+		drained: make(chan struct{}),
+	}
+	go s.writeRecords()
+	if gateway != nil {
+		gateway.SetUsageRecorder(s)
+		if err := s.LoadPricing(context.Background()); err != nil {
+			fmt.Printf("[UsageService] Warning: Failed to load model pricing: %v\n", err)
+		}
+	}
+	return s
+}
+
+// RecordUsage implements llm.UsageRecorder. Records are queued for the
+// background writer so the ledger does not delay a response; when the queue
+// is full the record is written by the caller instead of being dropped.
+func (s *UsageService) RecordUsage(ctx context.Context, record llm.UsageRecord) {
+	entry := &models.UsageRecord{
+		Provider:         record.Provider,
+		Model:            record.Model,
+		TaskType:         record.TaskType,
+		Stream:           record.Stream,
+		PromptTokens:     record.PromptTokens,
+		CompletionTokens: record.CompletionTokens,
+		Cost:             record.Cost,
+		LatencyMs:        record.Latency.Milliseconds(),
+		Success:          record.Success,
+		Error:            record.Error,
+		FallbackUsed:     record.FallbackUsed,
+		CreatedAt:        record.CreatedAt,
+	}
+	if userID, err := uuid.Parse(record.UserID); err == nil {
+		entry.UserID = &userID
+	}
+	if connectionID, err := uuid.Parse(record.ConnectionID); err == nil {
+		entry.ConnectionID = &connectionID
+	}
+	if apiKeyID, err := uuid.Parse(record.APIKeyID); err == nil {
+		entry.APIKeyID = &apiKeyID
+	}
+
+	queued := false
+	s.mu.RLock()
+	if !s.closed {
+		select {
+		case s.queue <- entry:
+			queued = true
+		default:
+		}
+	}
+	s.mu.RUnlock()
+	if !queued {
+		s.writeRecord(entry)
+	}
+}
+
+// writeRecords writes queued records until the queue is closed and empty
+func (s *UsageService) writeRecords() {
+	defer close(s.drained)
+	for entry := range s.queue {
+		s.writeRecord(entry)
+	}
+}
+
+func (s *UsageService) writeRecord(entry *models.UsageRecord) {
+	if err := s.repo.CreateRecord(context.Background(), entry); err != nil {
+		fmt.Printf("[UsageService] Failed to record usage: %v\n", err)
+	}
+}
+
+// Close stops queueing usage records and waits until the queued ones are
+// written or ctx is done. Records made after Close are written directly.
+func (s *UsageService) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("usage records still queued: %w", ctx.Err())
+	}
+}
+
+// Report aggregates the ledger. The window defaults to the last 30 days and
+// the grouping to one row per day.
+func (s *UsageService) Report(ctx context.Context, query models.UsageQuery) (*models.UsageReport, error) {
+	if query.To.IsZero() {
+		query.To = time.Now()
+	}
+	if query.From.IsZero() {
+		query.From = query.To.AddDate(0, 0, -30)
+	}
+	if !query.From.Before(query.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidUsageQuery)
+	}
+	if query.GroupBy == nil {
+		query.GroupBy = []string{"day"}
+	}
+	for _, group := range query.GroupBy {
+		if !postgres.ValidUsageGroup(group) {
+			return nil, fmt.Errorf("%w: cannot group by %q", ErrInvalidUsageQuery, group)
+		}
+	}
+
+	rows, err := s.repo.Aggregate(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	totalsQuery := query
+	totalsQuery.GroupBy = nil
+	totals, err := s.repo.Aggregate(ctx, totalsQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.UsageReport{
+		From:    query.From,
+		To:      query.To,
+		GroupBy: query.GroupBy,
+		Rows:    rows,
+	}
+	if len(totals) > 0 {
+		report.Totals = totals[0]
+	}
+	return report, nil
+}
+
+// LoadPricing applies admin-defined prices to the gateway's pricing catalog
+func (s *UsageService) LoadPricing(ctx context.Context) error {
+	prices, err := s.repo.ListPrices(ctx)
+	if err != nil {
+		return err
+	}
+
+	catalog := s.gateway.PricingCatalog()
+	for _, price := range prices {
+		catalog.Set(price.Model, llm.ModelPricing{
+			InputPerMillion:  price.InputPerMillion,
+			OutputPerMillion: price.OutputPerMillion,
+		})
+	}
+	return nil
+}
+
+// ListPrices returns the effective pricing catalog, marking admin-defined
+// entries as custom
+func (s *UsageService) ListPrices(ctx context.Context) ([]models.ModelPrice, error) {
+	custom, err := s.repo.ListPrices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	updated := make(map[string]time.Time, len(custom))
+	for _, price := range custom {
+		updated[price.Model] = price.UpdatedAt
+	}
+
+	var prices []models.ModelPrice
+	for model, pricing := range s.gateway.PricingCatalog().All() {
+		updatedAt, isCustom := updated[model]
+		prices = append(prices, models.ModelPrice{
+			Model:            model,
+			InputPerMillion:  pricing.InputPerMillion,
+			OutputPerMillion: pricing.OutputPerMillion,
+			Custom:           isCustom,
+			UpdatedAt:        updatedAt,
+		})
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].Model < prices[j].Model
+	})
+	return prices, nil
+}
+
+// SetPrice saves the price of a model or model prefix and applies it to the
+// gateway immediately
+func (s *UsageService) SetPrice(ctx context.Context, price *models.ModelPrice) error {
+	if price.Model == "" {
+		return fmt.Errorf("%w: model is required", ErrInvalidPrice)
+	}
+	if price.InputPerMillion < 0 || price.OutputPerMillion < 0 {
+		return fmt.Errorf("%w: prices cannot be negative", ErrInvalidPrice)
+	}
+
+	if err := s.repo.UpsertPrice(ctx, price); err != nil {
+		return err
+	}
+	price.Custom = true
+
+	s.gateway.PricingCatalog().Set(price.Model, llm.ModelPricing{
+		InputPerMillion:  price.InputPerMillion,
+		OutputPerMillion: price.OutputPerMillion,
+	})
+	return nil
+}
+
+// DeletePrice removes an admin-defined price, restoring the built-in list
+// price if there is one
+func (s *UsageService) DeletePrice(ctx context.Context, model string) error {
+	if err := s.repo.DeletePrice(ctx, model); err != nil {
+		return err
+	}
+
+	s.gateway.PricingCatalog().Reset(model)
+	return nil
+}
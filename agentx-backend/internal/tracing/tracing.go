@@ -0,0 +1,140 @@
+// Package tracing sets up OpenTelemetry tracing for the backend and carries
+// trace context across HTTP requests, the LLM gateway and MCP subprocesses.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName = "agentx-backend"
+	tracerName  = "github.com/agentx/agentx-backend"
+)
+
+// Exporters selectable with AGENTX_TRACING_EXPORTER
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Setup installs the global tracer provider and propagator. The exporter is
+// chosen by AGENTX_TRACING_EXPORTER (otlp, stdout or none); the OTLP exporter
+// reads its endpoint and headers from the standard OTEL_EXPORTER_OTLP_*
+// variables. AGENTX_TRACING_SAMPLE_RATIO sets the fraction of new traces
+// sampled. Spans are created even without an exporter so trace IDs can still
+// be returned to clients and handed to MCP servers.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	ratio := 1.0
+	if v := os.Getenv("AGENTX_TRACING_SAMPLE_RATIO"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			return nil, fmt.Errorf("invalid AGENTX_TRACING_SAMPLE_RATIO %q", v)
+		}
+		ratio = parsed
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("tracing resource: %w", err)
+	}
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	}
+
+	exporter := strings.ToLower(os.Getenv("AGENTX_TRACING_EXPORTER"))
+	switch exporter {
+	case "", ExporterNone:
+	case ExporterOTLP:
+		exp, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("otlp exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exp))
+	case ExporterStdout:
+		exp, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, fmt.Errorf("stdout exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exp))
+	default:
+		return nil, fmt.Errorf("unknown AGENTX_TRACING_EXPORTER %q", exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the backend's tracer from the global provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Start starts an internal span as a child of any span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordError marks a span as failed. A nil error leaves the span untouched.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// TraceID returns the hex trace ID of the span in ctx, or "" if there is none
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
+
+// InjectEnv adds the trace context in ctx to a command's environment as
+// TRACEPARENT and TRACESTATE, so instrumented subprocesses such as MCP
+// servers join the trace. A command with no environment set inherits the
+// parent's, so that is kept.
+func InjectEnv(ctx context.Context, cmd *exec.Cmd) {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return
+	}
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	for key, value := range carrier {
+		cmd.Env = append(cmd.Env, strings.ToUpper(key)+"="+value)
+	}
+}